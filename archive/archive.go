@@ -0,0 +1,216 @@
+// Package archive keeps events in compressed newline-delimited JSON files, see nostr.ReadEvents,
+// and republishes them, e.g. to restore a profile's history onto new relays.
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Compression identifies how an archive is compressed.
+type Compression string
+
+const (
+	None Compression = "none"
+	Gzip Compression = "gzip"
+	Zstd Compression = "zstd"
+)
+
+var ErrUnsupportedCompression = errors.New("unsupported compression")
+
+// Codec creates readers and writers for a compression.
+type Codec struct {
+	NewReader func(r io.Reader) (io.ReadCloser, error)
+	NewWriter func(w io.Writer) (io.WriteCloser, error)
+}
+
+var (
+	codecsMutex sync.RWMutex
+	codecs      = map[Compression]Codec{
+		Gzip: {
+			NewReader: func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+			NewWriter: func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil },
+		},
+	}
+)
+
+// RegisterCodec makes a compression available, replacing the codec already registered for it.
+// Only gzip is built in, zstd archives can be handled by registering a codec built on a zstd
+// library, like github.com/klauspost/compress/zstd.
+func RegisterCodec(compression Compression, codec Codec) {
+	codecsMutex.Lock()
+	defer codecsMutex.Unlock()
+	codecs[compression] = codec
+}
+
+func getCodec(compression Compression) (Codec, error) {
+	codecsMutex.RLock()
+	defer codecsMutex.RUnlock()
+	codec, ok := codecs[compression]
+	if !ok {
+		return codec, fmt.Errorf("%w: %s", ErrUnsupportedCompression, compression)
+	}
+	return codec, nil
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+// Write writes the events from ch to w until ch is closed, compressed with compression.
+func Write(w io.Writer, compression Compression, ch <-chan *nostr.Event, opts ...nostr.JSONLOption) error {
+	var cw io.WriteCloser = nopWriteCloser{w}
+	if compression != None {
+		codec, err := getCodec(compression)
+		if err != nil {
+			// don't leave the sender blocked
+			for range ch {
+			}
+			return err
+		}
+		if cw, err = codec.NewWriter(w); err != nil {
+			for range ch {
+			}
+			return err
+		}
+	}
+
+	if err := nostr.WriteEvents(cw, ch, opts...); err != nil {
+		cw.Close()
+		return err
+	}
+	return cw.Close()
+}
+
+// Detect tells how the archive in r is compressed from its first bytes, returning a reader
+// that still includes them.
+func Detect(r io.Reader) (Compression, io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil && err != io.EOF {
+		return None, br, err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return Gzip, br, nil
+	case bytes.HasPrefix(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return Zstd, br, nil
+	}
+	return None, br, nil
+}
+
+// Read decompresses the archive in r, detecting its compression, and sends its events to the
+// returned channel, see nostr.ReadEvents. With nostr.WithReadContext it stops once the context
+// is done, after at most one more event.
+func Read(r io.Reader, opts ...nostr.JSONLOption) (<-chan *nostr.Event, error) {
+	compression, r, err := Detect(r)
+	if err != nil {
+		return nil, err
+	}
+	if compression == None {
+		return nostr.ReadEvents(r, opts...), nil
+	}
+
+	codec, err := getCodec(compression)
+	if err != nil {
+		return nil, err
+	}
+	cr, err := codec.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	events := nostr.ReadEvents(cr, opts...)
+	ch := make(chan *nostr.Event)
+	go func() {
+		defer close(ch)
+		defer cr.Close()
+		for evt := range events {
+			ch <- evt
+		}
+	}()
+	return ch, nil
+}
+
+// ReplayStats tells how a Replay went.
+type ReplayStats struct {
+	// Events is how many valid events were found in the archive.
+	Events int
+
+	// Accepted is how many events each relay accepted.
+	Accepted map[string]int
+}
+
+// Replay publishes the events from archive to relays, at most rate events per second, or as
+// fast as possible if rate is 0. Events with an invalid id or signature are skipped. It stops
+// when ctx is canceled, returning ctx.Err() along with what was done so far.
+func Replay(ctx context.Context, archive io.Reader, relays []string, rate float64) (ReplayStats, error) {
+	stats := ReplayStats{Accepted: make(map[string]int, len(relays))}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	events, err := Read(archive, nostr.WithEventValidation(), nostr.WithReadContext(ctx))
+	if err != nil {
+		return stats, err
+	}
+	defer func() {
+		// the reader stops once we cancel, it may have one more event for us by then
+		cancel()
+		for range events {
+		}
+	}()
+
+	pool := nostr.NewSimplePool(ctx)
+	defer pool.Close()
+
+	var tick <-chan time.Time
+	if rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	var mu sync.Mutex
+	for evt := range events {
+		if tick != nil && stats.Events > 0 {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+				return stats, ctx.Err()
+			}
+		} else if ctx.Err() != nil {
+			return stats, ctx.Err()
+		}
+		stats.Events++
+
+		wg := sync.WaitGroup{}
+		for _, url := range relays {
+			wg.Add(1)
+			go func(url string) {
+				defer wg.Done()
+				relay, err := pool.EnsureRelay(url)
+				if err != nil {
+					return
+				}
+				if status, _ := relay.Publish(ctx, *evt); status == nostr.PublishStatusSucceeded {
+					mu.Lock()
+					stats.Accepted[url]++
+					mu.Unlock()
+				}
+			}(url)
+		}
+		wg.Wait()
+	}
+
+	return stats, ctx.Err()
+}
@@ -0,0 +1,120 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func signedEvents(n int) []*nostr.Event {
+	sk := nostr.GeneratePrivateKey()
+	pk, _ := nostr.GetPublicKey(sk)
+	events := make([]*nostr.Event, n)
+	for i := range events {
+		events[i] = &nostr.Event{PubKey: nostr.MustPubKeyFromHex(pk), CreatedAt: time.Unix(int64(1000+i), 0), Kind: 1, Content: "hello"}
+		events[i].Sign(sk)
+	}
+	return events
+}
+
+func writeArchive(t *testing.T, compression Compression, events []*nostr.Event) *bytes.Buffer {
+	ch := make(chan *nostr.Event, len(events))
+	for _, evt := range events {
+		ch <- evt
+	}
+	close(ch)
+
+	var buf bytes.Buffer
+	if err := Write(&buf, compression, ch); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestRoundTrip(t *testing.T) {
+	events := signedEvents(3)
+
+	for _, compression := range []Compression{None, Gzip} {
+		buf := writeArchive(t, compression, events)
+		if detected, _, _ := Detect(bytes.NewReader(buf.Bytes())); detected != compression {
+			t.Fatalf("detected %s instead of %s", detected, compression)
+		}
+
+		ch, err := Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var read []*nostr.Event
+		for evt := range ch {
+			read = append(read, evt)
+		}
+		if len(read) != 3 || read[1].ID != events[1].ID {
+			t.Fatalf("wrong events read from %s archive", compression)
+		}
+	}
+
+	zstd := bytes.NewReader([]byte{0x28, 0xb5, 0x2f, 0xfd, 0})
+	if _, err := Read(zstd); !errors.Is(err, ErrUnsupportedCompression) {
+		t.Fatalf("expected ErrUnsupportedCompression, got %v", err)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			var msg []json.RawMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			var label string
+			json.Unmarshal(msg[0], &label)
+			if label != "EVENT" {
+				continue
+			}
+			var evt nostr.Event
+			json.Unmarshal(msg[1], &evt)
+			mu.Lock()
+			received = append(received, evt.ID.Hex())
+			mu.Unlock()
+			conn.WriteJSON([]any{"OK", evt.ID.Hex(), true, ""})
+		}
+	}))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	events := signedEvents(3)
+	buf := writeArchive(t, Gzip, events)
+
+	start := time.Now()
+	stats, err := Replay(context.Background(), buf, []string{url}, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Events != 3 || stats.Accepted[url] != 3 {
+		t.Fatalf("wrong stats %v", stats)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Fatalf("replay was too fast: %s", elapsed)
+	}
+	if len(received) != 3 || received[0] != events[0].ID.Hex() {
+		t.Fatalf("relay got %v", received)
+	}
+}
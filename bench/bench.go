@@ -0,0 +1,248 @@
+// Package bench load-tests relays: it opens many connections to one, publishes synthetic
+// events and sends REQs on them at a steady rate, and reports how long the relay took to
+// answer, how often it failed and how long it took to send "EOSE":
+//
+//	report, err := bench.Run(ctx, bench.Config{
+//		URL:           "wss://relay.example.com",
+//		Connections:   50,
+//		Duration:      time.Minute,
+//		PublishRate:   2,
+//		SubscribeRate: 1,
+//	})
+//	fmt.Print(report)
+//
+// Running it against a local relay is also a way of profiling this library, as every
+// connection goes through the same paths real clients use.
+package bench
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Config is what Run does. Zero values get the defaults in their comments.
+type Config struct {
+	URL         string
+	Connections int           // 1
+	Duration    time.Duration // 10 seconds
+
+	// PublishRate is how many events each connection publishes per second, waiting for the
+	// "OK" of each before sending the next, so slow relays get fewer.
+	PublishRate float64
+	// SubscribeRate is how many REQs each connection sends per second, each one closed after
+	// its "EOSE", with the same waiting as PublishRate.
+	SubscribeRate float64
+
+	Kind        int           // of the events published, 1
+	ContentSize int           // bytes of content of the events published, 100
+	Filters     nostr.Filters // of the REQs, the latest 50 events of Kind
+	SecretKey   string        // signs the events, a random one if empty
+
+	RelayOptions []nostr.RelayOption
+}
+
+func (config *Config) setDefaults() {
+	if config.Connections <= 0 {
+		config.Connections = 1
+	}
+	if config.Duration <= 0 {
+		config.Duration = 10 * time.Second
+	}
+	if config.Kind == 0 {
+		config.Kind = nostr.KindTextNote
+	}
+	if config.ContentSize <= 0 {
+		config.ContentSize = 100
+	}
+	if len(config.Filters) == 0 {
+		config.Filters = nostr.Filters{{Kinds: []int{config.Kind}, Limit: 50}}
+	}
+	if config.SecretKey == "" {
+		config.SecretKey = nostr.GeneratePrivateKey()
+	}
+}
+
+// Run opens the connections and keeps publishing and subscribing on all of them for
+// config.Duration, or until ctx is done, then closes them and reports. It only fails if the
+// configuration is wrong, failures of the relay are in the report.
+func Run(ctx context.Context, config Config) (Report, error) {
+	config.setDefaults()
+	if nostr.NormalizeURL(config.URL) == "" {
+		return Report{}, fmt.Errorf("invalid relay URL '%s'", config.URL)
+	}
+	pk, err := nostr.GetPublicKey(config.SecretKey)
+	if err != nil {
+		return Report{}, fmt.Errorf("invalid secret key: %w", err)
+	}
+
+	b := &bench{config: config, pubkey: nostr.MustPubKeyFromHex(pk)}
+	ctx, cancel := context.WithTimeout(ctx, config.Duration)
+	defer cancel()
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < config.Connections; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.connection(ctx)
+		}()
+	}
+	wg.Wait()
+
+	return Report{
+		URL:         config.URL,
+		Duration:    time.Since(start),
+		Connections: config.Connections,
+		Connect:     b.connect.latencies(),
+		Publish:     b.publish.latencies(),
+		EOSE:        b.eose.latencies(),
+		Events:      b.events,
+	}, nil
+}
+
+type bench struct {
+	config Config
+	pubkey nostr.PubKey
+
+	connect, publish, eose recorder
+
+	mutex  sync.Mutex
+	events int
+}
+
+// connection connects once and keeps publishing and subscribing until ctx is done.
+func (b *bench) connection(ctx context.Context) {
+	start := time.Now()
+	relay, err := nostr.RelayConnect(ctx, b.config.URL, b.config.RelayOptions...)
+	if err != nil {
+		if ctx.Err() == nil {
+			b.connect.failure()
+		}
+		return
+	}
+	defer relay.Close()
+	b.connect.success(time.Since(start))
+
+	var wg sync.WaitGroup
+	if b.config.PublishRate > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			every(ctx, b.config.PublishRate, func() { b.publishOne(ctx, relay) })
+		}()
+	}
+	if b.config.SubscribeRate > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			every(ctx, b.config.SubscribeRate, func() { b.subscribeOne(ctx, relay) })
+		}()
+	}
+	wg.Wait()
+	<-ctx.Done()
+}
+
+func (b *bench) publishOne(ctx context.Context, relay *nostr.Relay) {
+	evt := nostr.Event{
+		PubKey:    b.pubkey,
+		CreatedAt: time.Now(),
+		Kind:      b.config.Kind,
+		Tags:      nostr.Tags{},
+		Content:   content(b.config.ContentSize),
+	}
+	if err := evt.Sign(b.config.SecretKey); err != nil {
+		b.publish.failure()
+		return
+	}
+
+	start := time.Now()
+	_, err := relay.Publish(ctx, evt)
+	if ctx.Err() != nil {
+		// cut by the end of the run, not the relay's fault
+		return
+	}
+	if err != nil {
+		b.publish.failure()
+		return
+	}
+	b.publish.success(time.Since(start))
+}
+
+func (b *bench) subscribeOne(ctx context.Context, relay *nostr.Relay) {
+	start := time.Now()
+	sub, err := relay.Sub(ctx, b.config.Filters)
+	if err != nil {
+		if ctx.Err() == nil {
+			b.eose.failure()
+		}
+		return
+	}
+	defer sub.Unsub()
+
+	events := 0
+	defer func() {
+		b.mutex.Lock()
+		b.events += events
+		b.mutex.Unlock()
+	}()
+	for {
+		select {
+		case _, more := <-sub.Events:
+			if !more {
+				if ctx.Err() == nil {
+					b.eose.failure()
+				}
+				return
+			}
+			events++
+		case <-sub.EndOfStoredEvents:
+			b.eose.success(time.Since(start))
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// every calls fn rate times per second until ctx is done, or as often as it can if fn takes
+// longer than that.
+func every(ctx context.Context, rate float64, fn func()) {
+	interval := time.Duration(float64(time.Second) / rate)
+	next := time.Now()
+	for {
+		fn()
+		next = next.Add(interval)
+		if wait := time.Until(next); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return
+			}
+		} else {
+			// behind, don't try to catch up with a burst
+			next = time.Now()
+			if ctx.Err() != nil {
+				return
+			}
+		}
+	}
+}
+
+const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789 "
+
+// content makes random text, so every event has a different id.
+func content(size int) string {
+	var sb strings.Builder
+	sb.Grow(size)
+	for i := 0; i < size; i++ {
+		sb.WriteByte(alphabet[rand.Intn(len(alphabet))])
+	}
+	return sb.String()
+}
@@ -0,0 +1,79 @@
+package bench
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/server"
+)
+
+func TestRun(t *testing.T) {
+	ts := httptest.NewServer(server.New(nil))
+	defer ts.Close()
+
+	report, err := Run(context.Background(), Config{
+		URL:           "ws" + strings.TrimPrefix(ts.URL, "http"),
+		Connections:   4,
+		Duration:      time.Second,
+		PublishRate:   20,
+		SubscribeRate: 10,
+		Filters:       nostr.Filters{{Kinds: []int{1}, Limit: 10}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Connect.Count != 4 || report.Connect.Errors != 0 {
+		t.Fatalf("unexpected connections: %s", report.Connect)
+	}
+	if report.Publish.Count < 8 || report.Publish.ErrorRate() != 0 {
+		t.Fatalf("unexpected publishing: %s", report.Publish)
+	}
+	if report.EOSE.Count < 4 || report.EOSE.Errors != 0 || report.Events == 0 {
+		t.Fatalf("unexpected subscriptions: %s, %d events", report.EOSE, report.Events)
+	}
+	if l := report.Publish; l.P50 > l.P90 || l.P90 > l.P99 || l.P99 > l.Max || l.Mean > l.Max {
+		t.Fatalf("inconsistent latencies: %s", l)
+	}
+	if !strings.Contains(report.String(), "publish: ") {
+		t.Fatalf("unexpected report:\n%s", report)
+	}
+
+	if _, err := Run(context.Background(), Config{URL: "ftp://relay"}); err == nil {
+		t.Fatalf("invalid URL accepted")
+	}
+}
+
+func TestRunUnreachable(t *testing.T) {
+	ts := httptest.NewServer(nil)
+	ts.Close()
+
+	report, err := Run(context.Background(), Config{
+		URL:         "ws" + strings.TrimPrefix(ts.URL, "http"),
+		Connections: 3,
+		Duration:    time.Second,
+		PublishRate: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Connect.Errors != 3 || report.Connect.ErrorRate() != 1 || report.Publish.Count != 0 {
+		t.Fatalf("unexpected report:\n%s", report)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var r recorder
+	for i := 100; i >= 1; i-- {
+		r.success(time.Duration(i) * time.Millisecond)
+	}
+	r.failure()
+	l := r.latencies()
+	if l.P50 != 50*time.Millisecond || l.P90 != 90*time.Millisecond || l.P99 != 99*time.Millisecond ||
+		l.Max != 100*time.Millisecond || l.Mean != 50500*time.Microsecond || l.Errors != 1 {
+		t.Fatalf("unexpected latencies %+v", l)
+	}
+}
@@ -0,0 +1,122 @@
+package bench
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Report is what happened during a Run.
+type Report struct {
+	URL         string
+	Duration    time.Duration
+	Connections int
+
+	Connect Latencies // from dialing to the connection being open
+	Publish Latencies // from sending "EVENT" to getting its "OK", rejections are errors
+	EOSE    Latencies // from sending "REQ" to getting its "EOSE"
+	Events  int       // received in the REQs
+}
+
+// Latencies are the times some operation took, the operations that failed aren't in them.
+type Latencies struct {
+	Count  int // succeeded
+	Errors int // failed
+	Mean   time.Duration
+	P50    time.Duration
+	P90    time.Duration
+	P99    time.Duration
+	Max    time.Duration
+}
+
+// ErrorRate is the fraction of the operations that failed, from 0 to 1.
+func (l Latencies) ErrorRate() float64 {
+	if l.Count+l.Errors == 0 {
+		return 0
+	}
+	return float64(l.Errors) / float64(l.Count+l.Errors)
+}
+
+func (l Latencies) String() string {
+	if l.Count == 0 {
+		return fmt.Sprintf("0 ok, %d errors", l.Errors)
+	}
+	return fmt.Sprintf("%d ok, %d errors (%.1f%%), mean %s, p50 %s, p90 %s, p99 %s, max %s",
+		l.Count, l.Errors, l.ErrorRate()*100, round(l.Mean), round(l.P50), round(l.P90), round(l.P99), round(l.Max))
+}
+
+func (r Report) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s, %d connections for %s\n", r.URL, r.Connections, round(r.Duration))
+	fmt.Fprintf(&sb, "connect: %s\n", r.Connect)
+	if r.Publish.Count+r.Publish.Errors > 0 {
+		fmt.Fprintf(&sb, "publish: %s, %.1f/s\n", r.Publish, float64(r.Publish.Count)/r.Duration.Seconds())
+	}
+	if r.EOSE.Count+r.EOSE.Errors > 0 {
+		fmt.Fprintf(&sb, "eose:    %s, %d events received\n", r.EOSE, r.Events)
+	}
+	return sb.String()
+}
+
+func round(d time.Duration) time.Duration {
+	switch {
+	case d > time.Second:
+		return d.Round(time.Millisecond)
+	case d > time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	}
+	return d.Round(time.Microsecond)
+}
+
+// recorder keeps the latencies of one operation.
+type recorder struct {
+	mutex   sync.Mutex
+	samples []time.Duration
+	errors  int
+}
+
+func (r *recorder) success(d time.Duration) {
+	r.mutex.Lock()
+	r.samples = append(r.samples, d)
+	r.mutex.Unlock()
+}
+
+func (r *recorder) failure() {
+	r.mutex.Lock()
+	r.errors++
+	r.mutex.Unlock()
+}
+
+func (r *recorder) latencies() Latencies {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	l := Latencies{Count: len(r.samples), Errors: r.errors}
+	if l.Count == 0 {
+		return l
+	}
+	sorted := append([]time.Duration(nil), r.samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	l.Mean = total / time.Duration(l.Count)
+	l.P50 = percentile(sorted, 50)
+	l.P90 = percentile(sorted, 90)
+	l.P99 = percentile(sorted, 99)
+	l.Max = sorted[len(sorted)-1]
+	return l
+}
+
+// percentile is the nearest-rank percentile p of sorted.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,504 @@
+package nostr
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// how many relays from each user's NIP-65 list are used when routing events to them
+const relaysPerUser = 3
+
+// Client ties together a pool of relays, a Signer and a Store, so applications can publish
+// and query without wiring relays, authentication, outbox routing and caching every time.
+type Client struct {
+	Pool   *SimplePool
+	Signer Signer
+	Store  Store // optional
+
+	// Relays are always used, in addition to the ones we find for each user in their NIP-65 lists.
+	Relays []string
+
+	// MaxPowDifficulty is the most proof of work (NIP-13) Publish will do on events it signs for
+	// relays that require it. Relays requiring more are skipped. Zero disables mining.
+	MaxPowDifficulty int
+
+	// PublishHooks run on every event given to Publish, before it is signed, e.g. AddClientTag.
+	PublishHooks []PublishHook
+
+	// Outbox, if set, gets the events Publish couldn't get any relay to accept, to be sent
+	// once the relays are reachable again. Its Run method must be running for that.
+	Outbox *Outbox
+
+	accounts clientAccounts
+	nip05    nip05Cache
+}
+
+// NewClient creates a Client. store can be nil, in which case nothing is cached.
+func NewClient(ctx context.Context, signer Signer, store Store, relays []string) *Client {
+	return &Client{
+		Pool:   NewSimplePool(ctx),
+		Signer: signer,
+		Store:  store,
+		Relays: relays,
+
+		MaxPowDifficulty: 20,
+	}
+}
+
+// Publish signs the event with the Signer if it isn't signed yet and sends it to our relays,
+// the author's write relays and the read relays of everybody it mentions.
+// Events it signs get the proof of work the relays require, up to MaxPowDifficulty.
+// It returns an error if no relay accepted it, unless some couldn't be reached and it was
+// queued in the Outbox for those.
+func (c *Client) Publish(ctx context.Context, evt *Event) error {
+	sign := evt.Sig == ""
+	if sign {
+		if c.Signer == nil {
+			return fmt.Errorf("can't publish unsigned event without a signer")
+		}
+		pk, err := c.Signer.GetPublicKey(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get public key: %w", err)
+		}
+		evt.PubKey = pk
+	}
+	if err := runPublishHooks(ctx, c.PublishHooks, evt, !sign); err != nil {
+		return err
+	}
+
+	urls := append([]string{}, c.Relays...)
+	_, write := c.FetchRelayList(ctx, evt.PubKey)
+	urls = append(urls, write...)
+	for _, tag := range evt.Tags.GetAll([]string{"p", ""}) {
+		if pk, err := PubKeyFromHex(tag.Value()); err == nil {
+			read, _ := c.FetchRelayList(ctx, pk)
+			urls = append(urls, read...)
+		}
+	}
+	urls = c.Pool.FilterRelays(urls)
+
+	if sign {
+		if difficulty := c.requiredPow(ctx, urls); difficulty > 0 {
+			if err := evt.MinePow(ctx, difficulty); err != nil {
+				return fmt.Errorf("failed to do proof of work: %w", err)
+			}
+		}
+		if err := c.Signer.SignEvent(ctx, evt); err != nil {
+			return fmt.Errorf("failed to sign event: %w", err)
+		}
+	}
+
+	if c.Store != nil {
+		c.Store.SaveEvent(ctx, evt)
+	}
+
+	var mu sync.Mutex
+	var errs []string
+	var unreachable []string
+	accepted := false
+	wg := sync.WaitGroup{}
+	for _, url := range urls {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			retry, err := c.publishTo(ctx, url, *evt)
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				accepted = true
+				return
+			}
+			errs = append(errs, err.Error())
+			if retry {
+				unreachable = append(unreachable, url)
+			}
+		}(url)
+	}
+	wg.Wait()
+
+	// relays that rejected the event won't take it later, only the others are worth queueing for
+	if !accepted && len(unreachable) > 0 && c.Outbox != nil {
+		if err := c.Outbox.Enqueue(*evt, unreachable); err == nil {
+			return nil
+		}
+	}
+	if !accepted {
+		return fmt.Errorf("event wasn't accepted by any relay: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// requiredPow is the highest proof of work difficulty, up to MaxPowDifficulty, required by the relays.
+func (c *Client) requiredPow(ctx context.Context, urls []string) int {
+	if c.MaxPowDifficulty <= 0 {
+		return 0
+	}
+
+	var mu sync.Mutex
+	required := 0
+	wg := sync.WaitGroup{}
+	for _, url := range urls {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			relay, err := c.Pool.ensureRelayCtx(ctx, url)
+			if err != nil {
+				return
+			}
+			if lim := relay.limitation(ctx, true); lim != nil && lim.MinPowDifficulty <= c.MaxPowDifficulty {
+				mu.Lock()
+				if lim.MinPowDifficulty > required {
+					required = lim.MinPowDifficulty
+				}
+				mu.Unlock()
+			}
+		}(url)
+	}
+	wg.Wait()
+	return required
+}
+
+// publishTo sends evt to the relay at url, telling if it may take it later when it doesn't now,
+// because it couldn't be reached or asked us to try again.
+func (c *Client) publishTo(ctx context.Context, url string, evt Event) (retry bool, err error) {
+	relay, err := c.Pool.EnsureRelay(url)
+	if err != nil {
+		return true, err
+	}
+
+	if evt.IsProtected() && !relay.AuthenticatedAs(evt.PubKey) && relay.Challenge() != "" {
+		// NIP-70: the relay will only take it from its author
+		if err := c.authenticate(ctx, relay); err != nil {
+			return false, fmt.Errorf("%s: %w", url, err)
+		}
+	}
+
+	status, err := relay.Publish(ctx, evt)
+	if status == PublishStatusFailed && rejectedWith(err, NoticeAuthRequired) {
+		// authenticate and try again
+		if authErr := c.authenticate(ctx, relay); authErr != nil {
+			return false, fmt.Errorf("%s: %w (and %v)", url, err, authErr)
+		}
+		status, err = relay.Publish(ctx, evt)
+	}
+
+	// rejections are about the event, but not answering at all is about the relay
+	switch status {
+	case PublishStatusSucceeded:
+		c.Pool.health.success(relay.URL)
+	case PublishStatusSent:
+		c.Pool.health.failure(relay.URL)
+	}
+
+	if status == PublishStatusSucceeded {
+		return false, nil
+	}
+	if err == nil {
+		err = fmt.Errorf("no answer")
+	}
+	return shouldRetry(status, err), fmt.Errorf("%s: %w", url, err)
+}
+
+// authenticate answers the last NIP-42 challenge sent by relay using the Signer, again if it
+// was answered by another account sharing the connection.
+func (c *Client) authenticate(ctx context.Context, relay *Relay) error {
+	if c.Signer == nil {
+		return fmt.Errorf("relay requires auth but there is no signer")
+	}
+	return relay.AuthenticateAs(ctx, c.Signer)
+}
+
+// Subscribe opens a subscription on our relays and on the write relays of the authors in
+// filters, returning deduplicated events until ctx is canceled.
+// Events received are saved to the Store.
+func (c *Client) Subscribe(ctx context.Context, filters Filters, opts ...SubscriptionOption) chan *Event {
+	return c.subscribe(ctx, filters, c.Pool.SubMany, opts)
+}
+
+// QuerySync is like Subscribe, but returns all the stored events once every relay sent "EOSE".
+// Filters with a Limit only bring that many events, the newest among what all relays sent.
+func (c *Client) QuerySync(ctx context.Context, filters Filters) []*Event {
+	var events []*Event
+	for evt := range c.subscribe(ctx, filters, c.Pool.SubManyEose, nil) {
+		events = append(events, evt)
+	}
+	return filters.ApplyLimits(events)
+}
+
+func (c *Client) subscribe(
+	ctx context.Context,
+	filters Filters,
+	sub func(context.Context, []string, Filters, ...SubscriptionOption) chan *Event,
+	opts []SubscriptionOption,
+) chan *Event {
+	urls := append([]string{}, c.Relays...)
+	for _, filter := range filters {
+		for _, author := range filter.Authors {
+			_, write := c.FetchRelayList(ctx, author)
+			urls = append(urls, write...)
+		}
+	}
+
+	events := sub(ctx, urls, filters, opts...)
+	if c.Store == nil {
+		return events
+	}
+
+	stored := make(chan *Event)
+	go func() {
+		defer close(stored)
+		for evt := range events {
+			c.Store.SaveEvent(ctx, evt)
+			select {
+			case stored <- evt:
+			case <-ctx.Done():
+				// keep draining so the pool can finish
+			}
+		}
+	}()
+	return stored
+}
+
+// FetchRelayList returns the read and write relays pk announced in their NIP-65 list,
+// from the Store if possible. At most a few of each are returned, skipping the ones the pool's
+// Policy doesn't allow.
+func (c *Client) FetchRelayList(ctx context.Context, pk PubKey) (read []string, write []string) {
+	evt := c.fetchLatest(ctx, pk, KindRelayListMetadata, false)
+	if evt == nil {
+		return nil, nil
+	}
+	return c.relayList(evt)
+}
+
+// relayList reads a NIP-65 list as FetchRelayList returns it.
+func (c *Client) relayList(evt *Event) (read []string, write []string) {
+	list, err := ParseRelayList(evt)
+	if err != nil {
+		return nil, nil
+	}
+	return c.allowedRelays(list.Read), c.allowedRelays(list.Write)
+}
+
+// allowedRelays returns the first few of urls the pool's Policy allows.
+func (c *Client) allowedRelays(urls []string) []string {
+	var allowed []string
+	for _, url := range urls {
+		if len(allowed) == relaysPerUser {
+			break
+		}
+		if c.Pool.Policy.Allowed(url) {
+			allowed = append(allowed, url)
+		}
+	}
+	return allowed
+}
+
+// Fetch returns what ptr refers to, from the Store if possible, otherwise from our relays, the
+// relay hints in ptr and the author's write relays. For profiles and addressable events it is
+// the newest version.
+func (c *Client) Fetch(ctx context.Context, ptr Pointer) (*Event, error) {
+	filter, err := ptr.AsFilter()
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Store != nil {
+		if events, err := c.Store.QueryEvents(ctx, filter); err == nil && len(events) > 0 {
+			return events[0], nil
+		}
+	}
+
+	urls := append([]string{}, c.Relays...)
+	for _, url := range ptr.Hints() {
+		if url = NormalizeURL(url); url != "" && c.Pool.Policy.Allowed(url) {
+			urls = append(urls, url)
+		}
+	}
+	for _, author := range filter.Authors {
+		_, write := c.FetchRelayList(ctx, author)
+		urls = append(urls, write...)
+	}
+
+	latest := c.queryNewest(ctx, urls, filter)
+	if latest == nil {
+		return nil, fmt.Errorf("%+v not found", ptr)
+	}
+	return latest, nil
+}
+
+// FetchReference fetches the event an "e", "a" or "q" tag references, trying the relay hinted
+// in the tag, if the pool's Policy allows it, along with the usual ones, see Fetch.
+func (c *Client) FetchReference(ctx context.Context, tag Tag) (*Event, error) {
+	ptr, err := PointerFromTag(tag)
+	if err != nil {
+		return nil, err
+	}
+	return c.Fetch(ctx, ptr)
+}
+
+// FetchEvent finds the event given as a hex id, note, nevent or naddr code. It asks, in order
+// and stopping at the first that has it, the Store, the relays hinted in the code, the
+// author's write relays if the author is known and then our relays. Events that aren't
+// properly signed or don't match are ignored. The relay it came from is returned with it, it is
+// empty when found in the Store.
+func (c *Client) FetchEvent(ctx context.Context, code string) (*Event, string, error) {
+	ptr, err := ParsePointer(code)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, ok := ptr.(ProfilePointer); ok {
+		return nil, "", fmt.Errorf("'%s' is a profile, not an event", code)
+	}
+	filter, err := ptr.AsFilter()
+	if err != nil {
+		return nil, "", err
+	}
+
+	if c.Store != nil {
+		if events, err := c.Store.QueryEvents(ctx, filter); err == nil && len(events) > 0 {
+			return events[0], "", nil
+		}
+	}
+
+	tiers := [][]string{ptr.Hints()}
+	if len(filter.Authors) > 0 {
+		_, write := c.FetchRelayList(ctx, filter.Authors[0])
+		tiers = append(tiers, write)
+	}
+	tiers = append(tiers, c.Relays)
+
+	tried := make(map[string]bool)
+	for _, tier := range tiers {
+		var urls []string
+		for _, url := range tier {
+			if url = NormalizeURL(url); url != "" && !tried[url] {
+				tried[url] = true
+				urls = append(urls, url)
+			}
+		}
+		if len(urls) == 0 {
+			continue
+		}
+		if evt, url := c.fetchFirst(ctx, urls, filter); evt != nil {
+			if c.Store != nil {
+				c.Store.SaveEvent(ctx, evt)
+			}
+			return evt, url, nil
+		}
+		if ctx.Err() != nil {
+			return nil, "", ctx.Err()
+		}
+	}
+	return nil, "", fmt.Errorf("%s not found", code)
+}
+
+// fetchFirst queries urls at the same time for a valid event matching filter. For event ids
+// it returns as soon as one relay has it, otherwise it waits for all and returns the newest.
+func (c *Client) fetchFirst(ctx context.Context, urls []string, filter Filter) (*Event, string) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, 7*time.Second)
+		defer cancel()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type found struct {
+		evt *Event
+		url string
+	}
+	results := make(chan found, len(urls))
+	for _, url := range urls {
+		go func(url string) {
+			var newest *Event
+			if relay, err := c.Pool.ensureRelayCtx(ctx, url); err == nil {
+				events, _ := relay.Query(ctx, filter)
+				for _, evt := range events {
+					if ok, _ := evt.CheckSignature(); !ok || !filter.Matches(evt) {
+						continue
+					}
+					if newest == nil || evt.IsNewerThan(newest) {
+						newest = evt
+					}
+				}
+			}
+			results <- found{newest, url}
+		}(url)
+	}
+
+	var best found
+	for range urls {
+		res := <-results
+		if res.evt == nil {
+			continue
+		}
+		if len(filter.IDs) > 0 {
+			return res.evt, res.url
+		}
+		if best.evt == nil || res.evt.IsNewerThan(best.evt) {
+			best = res
+		}
+	}
+	return best.evt, best.url
+}
+
+// FetchLatest returns the newest replaceable event of the given kind by pk, like a profile or a
+// relay list, from the Store or, if it isn't there, from our relays and the hints. It is nil if
+// none was found.
+func (c *Client) FetchLatest(ctx context.Context, pk PubKey, kind int, hints ...string) *Event {
+	return c.fetchLatest(ctx, pk, kind, false, hints...)
+}
+
+// fetchLatest gets the newest replaceable event of the given kind by pk from the Store,
+// falling back to our relays, the hints and, if useOutbox, the author's write relays.
+func (c *Client) fetchLatest(ctx context.Context, pk PubKey, kind int, useOutbox bool, hints ...string) *Event {
+	filter := Filter{Kinds: []int{kind}, Authors: []PubKey{pk}, Limit: 1}
+
+	if c.Store != nil {
+		if events, err := c.Store.QueryEvents(ctx, filter); err == nil && len(events) > 0 {
+			return events[0]
+		}
+	}
+	return c.fetchLatestFromRelays(ctx, pk, kind, useOutbox, hints...)
+}
+
+// fetchLatestFromRelays is like fetchLatest, but skips the Store, still saving what is found there.
+func (c *Client) fetchLatestFromRelays(ctx context.Context, pk PubKey, kind int, useOutbox bool, hints ...string) *Event {
+	filter := Filter{Kinds: []int{kind}, Authors: []PubKey{pk}, Limit: 1}
+
+	urls := append(append([]string{}, c.Relays...), hints...)
+	if useOutbox {
+		_, write := c.FetchRelayList(ctx, pk)
+		urls = append(urls, write...)
+	}
+
+	return c.queryNewest(ctx, urls, filter)
+}
+
+// queryNewest gets the newest event matching filter from urls, saving it to the Store.
+func (c *Client) queryNewest(ctx context.Context, urls []string, filter Filter) *Event {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, 7*time.Second)
+		defer cancel()
+	}
+
+	var latest *Event
+	for evt := range c.Pool.SubManyEose(ctx, urls, Filters{filter}) {
+		if latest == nil || evt.IsNewerThan(latest) {
+			latest = evt
+		}
+	}
+	if latest != nil && c.Store != nil {
+		c.Store.SaveEvent(ctx, latest)
+	}
+	return latest
+}
+
+// Close disconnects from all relays.
+func (c *Client) Close() {
+	c.Pool.Close()
+}
@@ -0,0 +1,110 @@
+package nostr
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Account is one more identity a Client can act as, see Client.AddAccount and Client.As.
+type Account struct {
+	Signer Signer
+	Store  Store    // optional, the Client's Store is used if nil
+	Relays []string // used instead of the Client's Relays if not empty
+}
+
+// clientAccounts are the accounts of a Client, by public key.
+type clientAccounts struct {
+	mutex    sync.RWMutex
+	accounts map[PubKey]Account
+}
+
+// AddAccount lets the client act as the owner of account.Signer with As, returning their
+// public key. An account already added with the same key is replaced.
+func (c *Client) AddAccount(ctx context.Context, account Account) (PubKey, error) {
+	if account.Signer == nil {
+		return PubKey{}, fmt.Errorf("account has no signer")
+	}
+	pk, err := account.Signer.GetPublicKey(ctx)
+	if err != nil {
+		return PubKey{}, fmt.Errorf("failed to get public key: %w", err)
+	}
+
+	c.accounts.mutex.Lock()
+	defer c.accounts.mutex.Unlock()
+	if c.accounts.accounts == nil {
+		c.accounts.accounts = make(map[PubKey]Account)
+	}
+	c.accounts.accounts[pk] = account
+	return pk, nil
+}
+
+// RemoveAccount forgets the account of pk.
+func (c *Client) RemoveAccount(pk PubKey) {
+	c.accounts.mutex.Lock()
+	defer c.accounts.mutex.Unlock()
+	delete(c.accounts.accounts, pk)
+}
+
+// Accounts returns the public keys of the accounts added with AddAccount.
+func (c *Client) Accounts() []PubKey {
+	c.accounts.mutex.RLock()
+	defer c.accounts.mutex.RUnlock()
+	pks := make([]PubKey, 0, len(c.accounts.accounts))
+	for pk := range c.accounts.accounts {
+		pks = append(pks, pk)
+	}
+	return pks
+}
+
+// As returns a Client acting as the account of pk, as in client.As(pk).Publish(ctx, evt): it
+// signs, authenticates and caches with the account's Signer, Store and Relays, while sharing
+// the relay connections and everything else with c. If there is no such account it can't sign
+// anything, failing with an error saying so.
+func (c *Client) As(pk PubKey) *Client {
+	c.accounts.mutex.RLock()
+	account, ok := c.accounts.accounts[pk]
+	c.accounts.mutex.RUnlock()
+	if !ok {
+		account = Account{Signer: missingAccount(pk)}
+	}
+
+	as := &Client{
+		Pool:             c.Pool,
+		Signer:           account.Signer,
+		Store:            account.Store,
+		Relays:           account.Relays,
+		MaxPowDifficulty: c.MaxPowDifficulty,
+		PublishHooks:     c.PublishHooks,
+		Outbox:           c.Outbox,
+	}
+	if as.Store == nil {
+		as.Store = c.Store
+	}
+	if len(as.Relays) == 0 {
+		as.Relays = c.Relays
+	}
+	return as
+}
+
+// missingAccount is the Signer of accounts that weren't added.
+type missingAccount PubKey
+
+func (m missingAccount) err() error {
+	return fmt.Errorf("no account for %s", PubKey(m).Hex())
+}
+
+func (m missingAccount) GetPublicKey(context.Context) (PubKey, error) { return PubKey{}, m.err() }
+func (m missingAccount) SignEvent(context.Context, *Event) error      { return m.err() }
+func (m missingAccount) NIP04Encrypt(context.Context, string, PubKey) (string, error) {
+	return "", m.err()
+}
+func (m missingAccount) NIP04Decrypt(context.Context, string, PubKey) (string, error) {
+	return "", m.err()
+}
+func (m missingAccount) NIP44Encrypt(context.Context, string, PubKey) (string, error) {
+	return "", m.err()
+}
+func (m missingAccount) NIP44Decrypt(context.Context, string, PubKey) (string, error) {
+	return "", m.err()
+}
@@ -0,0 +1,84 @@
+package nostr
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestClientAccounts(t *testing.T) {
+	main, other := &fakeRelay{}, &fakeRelay{}
+	mainWS, otherWS := newWebsocketServer(main.handle), newWebsocketServer(other.handle)
+	defer mainWS.Close()
+	defer otherWS.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	alice, bob := newTestSigner(t), newTestSigner(t)
+	client := NewClient(context.Background(), alice, nil, []string{mainWS.URL})
+	defer client.Close()
+
+	bobStore := NewMemoryStore()
+	pk, err := client.AddAccount(ctx, Account{Signer: bob, Store: bobStore, Relays: []string{otherWS.URL}})
+	if err != nil || pk != bob.pk {
+		t.Fatalf("added %s, %v", pk, err)
+	}
+	if accounts := client.Accounts(); len(accounts) != 1 || accounts[0] != bob.pk {
+		t.Fatalf("accounts %v", accounts)
+	}
+
+	evt := Event{Kind: KindTextNote, CreatedAt: Now(), Content: "from bob"}
+	if err := client.As(bob.pk).Publish(ctx, &evt); err != nil {
+		t.Fatal(err)
+	}
+	if evt.PubKey != bob.pk {
+		t.Fatalf("signed by %s instead of bob", evt.PubKey)
+	}
+	other.mu.Lock()
+	main.mu.Lock()
+	if len(other.events) != 1 || len(main.events) != 0 {
+		t.Fatalf("bob's event went to %d of his relays and %d of the client's", len(other.events), len(main.events))
+	}
+	main.mu.Unlock()
+	other.mu.Unlock()
+	if events, _ := bobStore.QueryEvents(ctx, Filter{IDs: []ID{evt.ID}}); len(events) != 1 {
+		t.Fatal("bob's event wasn't cached in his store")
+	}
+
+	evt = Event{Kind: KindTextNote, CreatedAt: Now(), Content: "from alice"}
+	if err := client.Publish(ctx, &evt); err != nil || evt.PubKey != alice.pk {
+		t.Fatalf("client didn't publish as alice: %v", err)
+	}
+
+	client.RemoveAccount(bob.pk)
+	evt = Event{Kind: KindTextNote, CreatedAt: Now(), Content: "from nobody"}
+	if err := client.As(bob.pk).Publish(ctx, &evt); err == nil {
+		t.Fatal("published as a removed account")
+	}
+}
+
+func TestClientAccountsAuthenticateEach(t *testing.T) {
+	fr := &fakeRelay{challenge: "challenge"}
+	ws := newWebsocketServer(fr.handle)
+	defer ws.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	bob := newTestSigner(t)
+	client := NewClient(context.Background(), newTestSigner(t), nil, []string{ws.URL})
+	defer client.Close()
+	if _, err := client.AddAccount(ctx, Account{Signer: bob}); err != nil {
+		t.Fatal(err)
+	}
+
+	// both share the connection, but protected events must be authenticated by their author
+	for _, as := range []*Client{client, client.As(bob.pk)} {
+		evt := Event{Kind: KindTextNote, CreatedAt: Now(), Content: "protected"}
+		evt.Protect()
+		if err := as.Publish(ctx, &evt); err != nil {
+			t.Fatalf("couldn't publish a protected event: %v", err)
+		}
+	}
+}
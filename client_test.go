@@ -0,0 +1,286 @@
+package nostr
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil/bech32"
+	"golang.org/x/net/websocket"
+)
+
+// testSigner is a minimal Signer for tests, the real ones live in the keyer package.
+type testSigner struct {
+	sk string
+	pk PubKey
+}
+
+func newTestSigner(t *testing.T) *testSigner {
+	sk, pk := makeKeyPair(t)
+	return &testSigner{sk, pk}
+}
+
+func (ts *testSigner) GetPublicKey(ctx context.Context) (PubKey, error) { return ts.pk, nil }
+func (ts *testSigner) SignEvent(ctx context.Context, evt *Event) error {
+	evt.PubKey = ts.pk
+	return evt.Sign(ts.sk)
+}
+func (ts *testSigner) NIP04Encrypt(context.Context, string, PubKey) (string, error) { return "", nil }
+func (ts *testSigner) NIP04Decrypt(context.Context, string, PubKey) (string, error) { return "", nil }
+func (ts *testSigner) NIP44Encrypt(context.Context, string, PubKey) (string, error) { return "", nil }
+func (ts *testSigner) NIP44Decrypt(context.Context, string, PubKey) (string, error) { return "", nil }
+
+// fakeRelay is a tiny relay that accepts and serves events from memory.
+type fakeRelay struct {
+	mu      sync.Mutex
+	events  []Event
+	reqs    int
+	lastReq Filters
+
+	eoseDelay time.Duration // to simulate slow relays
+	challenge string        // if set, sent as a NIP-42 challenge on connect and any AUTH is accepted
+
+	// if set, EVENT and REQ are refused with "auth-required:" until the connection authenticates,
+	// a challenge is sent along with the refusal
+	requireAuth bool
+
+	reject string // if set, EVENTs are refused with this reason
+}
+
+func (fr *fakeRelay) handle(conn *websocket.Conn) {
+	if fr.challenge != "" {
+		websocket.JSON.Send(conn, []any{"AUTH", fr.challenge})
+	}
+	authed := false
+	for {
+		var raw []json.RawMessage
+		if err := websocket.JSON.Receive(conn, &raw); err != nil {
+			return
+		}
+		var typ string
+		json.Unmarshal(raw[0], &typ)
+		switch typ {
+		case "EVENT":
+			var evt Event
+			json.Unmarshal(raw[1], &evt)
+			if fr.requireAuth && !authed {
+				websocket.JSON.Send(conn, []any{"AUTH", "letmein"})
+				websocket.JSON.Send(conn, []any{"OK", evt.ID, false, "auth-required: who are you?"})
+				continue
+			}
+			if fr.reject != "" {
+				websocket.JSON.Send(conn, []any{"OK", evt.ID, false, fr.reject})
+				continue
+			}
+			fr.mu.Lock()
+			fr.events = append(fr.events, evt)
+			fr.mu.Unlock()
+			websocket.JSON.Send(conn, []any{"OK", evt.ID, true, ""})
+		case "AUTH":
+			var evt Event
+			json.Unmarshal(raw[1], &evt)
+			authed = true
+			websocket.JSON.Send(conn, []any{"OK", evt.ID, true, ""})
+		case "REQ":
+			var subid string
+			json.Unmarshal(raw[1], &subid)
+			if fr.requireAuth && !authed {
+				websocket.JSON.Send(conn, []any{"AUTH", "letmein"})
+				websocket.JSON.Send(conn, []any{"CLOSED", subid, "auth-required: who are you?"})
+				continue
+			}
+			var filters Filters
+			for _, f := range raw[2:] {
+				var filter Filter
+				json.Unmarshal(f, &filter)
+				filters = append(filters, filter)
+			}
+			fr.mu.Lock()
+			fr.reqs++
+			fr.lastReq = filters
+			var matching []Event
+			for _, evt := range fr.events {
+				if filters.Match(&evt) {
+					matching = append(matching, evt)
+				}
+			}
+			fr.mu.Unlock()
+			for _, evt := range matching {
+				websocket.JSON.Send(conn, []any{"EVENT", subid, evt})
+			}
+			time.Sleep(fr.eoseDelay)
+			websocket.JSON.Send(conn, []any{"EOSE", subid})
+		}
+	}
+}
+
+func TestClientPublishAndFetchProfile(t *testing.T) {
+	fr := &fakeRelay{}
+	ws := newWebsocketServer(fr.handle)
+	defer ws.Close()
+
+	signer := newTestSigner(t)
+	client := NewClient(context.Background(), signer, NewMemoryStore(), []string{ws.URL})
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	older := Event{Kind: KindSetMetadata, CreatedAt: time.Unix(1672068534, 0), Content: `{"name":"old"}`}
+	if err := client.Publish(ctx, &older); err != nil {
+		t.Fatalf("publish failed: %s", err)
+	}
+	if ok, _ := older.CheckSignature(); !ok || older.PubKey != signer.pk {
+		t.Fatalf("event wasn't signed by the client signer")
+	}
+
+	// publish directly to the relay, so the client store doesn't know about it
+	newer := Event{Kind: KindSetMetadata, CreatedAt: time.Unix(1672068600, 0), Content: `{"name":"new"}`}
+	signer.SignEvent(ctx, &newer)
+	fr.mu.Lock()
+	fr.events = append(fr.events, newer)
+	fr.mu.Unlock()
+
+	// a client without cache sees the newest
+	fresh := NewClient(context.Background(), nil, nil, []string{ws.URL})
+	defer fresh.Close()
+	profile, err := fresh.LookupProfile(ctx, signer.pk.Hex())
+	if err != nil {
+		t.Fatalf("fetch profile failed: %s", err)
+	}
+	if profile.Metadata.Name != "new" {
+		t.Errorf("expected newest profile, got %s", profile.Metadata.Name)
+	}
+
+	if len(profile.WriteRelays) != 0 {
+		t.Errorf("there is no relay list yet, got %v", profile.WriteRelays)
+	}
+
+	// the first client answers from its store, without asking the relay
+	relayList := Event{Kind: KindRelayListMetadata, CreatedAt: time.Unix(1672068534, 0), Tags: Tags{{"r", ws.URL}}}
+	if err := client.Publish(ctx, &relayList); err != nil {
+		t.Fatalf("publish failed: %s", err)
+	}
+	fr.mu.Lock()
+	reqs := fr.reqs
+	fr.mu.Unlock()
+	profile, err = client.LookupProfile(ctx, signer.pk.Hex())
+	if err != nil {
+		t.Fatalf("fetch profile failed: %s", err)
+	}
+	if profile.Metadata.Name != "old" {
+		t.Errorf("expected cached profile, got %s", profile.Metadata.Name)
+	}
+	if len(profile.WriteRelays) != 1 || profile.WriteRelays[0] != NormalizeURL(ws.URL) {
+		t.Errorf("expected the relay list, got %v", profile.WriteRelays)
+	}
+	if meta, err := client.FetchProfile(ctx, signer.pk); err != nil || meta.Name != "old" {
+		t.Errorf("expected cached metadata, got %v: %v", meta, err)
+	}
+	fr.mu.Lock()
+	if fr.reqs != reqs {
+		t.Errorf("cached profile shouldn't have been requested from the relay")
+	}
+	fr.mu.Unlock()
+}
+
+func TestClientFetchPointers(t *testing.T) {
+	fr := &fakeRelay{}
+	ws := newWebsocketServer(fr.handle)
+	defer ws.Close()
+
+	signer := newTestSigner(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	note := Event{Kind: KindTextNote, CreatedAt: time.Unix(1672068534, 0), Content: "hello"}
+	older := Event{Kind: 30023, CreatedAt: time.Unix(1672068534, 0), Tags: Tags{{"d", "post"}}, Content: "draft"}
+	newer := Event{Kind: 30023, CreatedAt: time.Unix(1672068600, 0), Tags: Tags{{"d", "post"}}, Content: "final"}
+	for _, evt := range []*Event{&note, &older, &newer} {
+		signer.SignEvent(ctx, evt)
+		fr.events = append(fr.events, *evt)
+	}
+
+	// the client knows no relays, only the hints in the pointers lead to the events
+	client := NewClient(context.Background(), nil, NewMemoryStore(), nil)
+	defer client.Close()
+
+	quote := Tag{"q", note.ID.Hex(), ws.URL}
+	evt, err := client.FetchReference(ctx, quote)
+	if err != nil || evt.ID != note.ID {
+		t.Fatalf("failed to fetch the quoted note: %v %v", evt, err)
+	}
+
+	entity := EntityPointer{PublicKey: signer.pk, Kind: 30023, Identifier: "post", Relays: []string{ws.URL}}
+	evt, err = client.Fetch(ctx, entity)
+	if err != nil || evt.Content != "final" {
+		t.Fatalf("should have fetched the newest version: %v %v", evt, err)
+	}
+
+	// now it comes from the store
+	ws.Close()
+	if evt, err := client.Fetch(ctx, entity); err != nil || evt.Content != "final" {
+		t.Fatalf("should have found it in the store: %v %v", evt, err)
+	}
+
+	if _, err := client.Fetch(ctx, EventPointer{}); err == nil {
+		t.Errorf("should fail for an event nobody has")
+	}
+}
+
+// testNevent encodes an nevent code with a relay hint, like nip19.EncodePointer.
+func testNevent(t *testing.T, id ID, relay string) string {
+	data := append([]byte{0, 32}, id[:]...)
+	data = append(data, 1, byte(len(relay)))
+	data = append(data, relay...)
+	bits5, err := bech32.ConvertBits(data, 8, 5, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := bech32.Encode("nevent", bits5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func TestClientFetchEvent(t *testing.T) {
+	hinted := &fakeRelay{}
+	hintedServer := newWebsocketServer(hinted.handle)
+	defer hintedServer.Close()
+	ours := &fakeRelay{}
+	oursServer := newWebsocketServer(ours.handle)
+	defer oursServer.Close()
+
+	signer := newTestSigner(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	note := Event{Kind: KindTextNote, CreatedAt: time.Unix(1672068534, 0), Content: "hello"}
+	signer.SignEvent(ctx, &note)
+	forged := note
+	forged.Content = "goodbye"
+	hinted.events = append(hinted.events, forged)
+	ours.events = append(ours.events, note)
+
+	client := NewClient(context.Background(), nil, nil, []string{oursServer.URL})
+	defer client.Close()
+
+	// the hinted relay only has a forged copy, so it comes from our relay
+	evt, url, err := client.FetchEvent(ctx, testNevent(t, note.ID, hintedServer.URL))
+	if err != nil {
+		t.Fatalf("failed to fetch event: %s", err)
+	}
+	if evt.Content != "hello" || url != NormalizeURL(oursServer.URL) {
+		t.Errorf("got %q from %s", evt.Content, url)
+	}
+
+	if _, _, err := client.FetchEvent(ctx, note.ID.Hex()); err != nil {
+		t.Errorf("failed to fetch by hex id: %s", err)
+	}
+	if _, _, err := client.FetchEvent(ctx, "npub180cvv07tjdrrgpa0j7j7tmnyl2yr6yr7l8j4s3evf6u64th6gkwsyjh6w6"); err == nil {
+		t.Errorf("a profile isn't an event")
+	}
+}
@@ -0,0 +1,52 @@
+package nostr
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc turns a function into a Clock, e.g. one returning a time that tests move forward.
+type ClockFunc func() time.Time
+
+func (f ClockFunc) Now() time.Time { return f() }
+
+// SystemClock is the time of the system.
+var SystemClock Clock = ClockFunc(time.Now)
+
+var (
+	defaultClockMutex sync.RWMutex
+	defaultClock      = SystemClock
+)
+
+// SetClock changes what Now returns, and so what this library uses for the created_at of the
+// events it makes, NIP-40 expiration checks, retry schedules and the times after which cached
+// things go stale. Durations, like latencies and timeouts given to contexts, are still measured
+// with the system clock. A nil clock restores SystemClock. It can be called while others are
+// using the library.
+func SetClock(clock Clock) {
+	if clock == nil {
+		clock = SystemClock
+	}
+	defaultClockMutex.Lock()
+	defer defaultClockMutex.Unlock()
+	defaultClock = clock
+}
+
+// Now returns the time of the clock given to SetClock, or of SystemClock by default.
+func Now() time.Time {
+	defaultClockMutex.RLock()
+	clock := defaultClock
+	defaultClockMutex.RUnlock()
+	return clock.Now()
+}
+
+// SkewedClock is the system clock moved by offset, for systems whose clock is known to be off,
+// e.g. by comparing it with the created_at of events coming from relays.
+func SkewedClock(offset time.Duration) Clock {
+	return ClockFunc(func() time.Time { return time.Now().Add(offset) })
+}
@@ -0,0 +1,39 @@
+package nostr
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSetClock(t *testing.T) {
+	defer SetClock(nil)
+
+	now := time.Unix(1700000000, 0)
+	SetClock(ClockFunc(func() time.Time { return now }))
+
+	if evt := newAuthEvent("wss://relay.example.com", "challenge"); !evt.CreatedAt.Equal(now) {
+		t.Fatalf("created_at is %s instead of the clock's time", evt.CreatedAt)
+	}
+
+	// an event expiring in an hour is accepted until the clock gets there
+	store := NewMemoryStore()
+	expiring := &Event{ID: ID{1}, Kind: 1, CreatedAt: now,
+		Tags: Tags{{"expiration", strconv.FormatInt(now.Add(time.Hour).Unix(), 10)}}}
+	if err := store.SaveEvent(context.Background(), expiring); err != nil {
+		t.Fatal(err)
+	}
+	if events, _ := store.QueryEvents(context.Background(), Filter{}); len(events) != 1 {
+		t.Fatal("event expired too soon")
+	}
+	now = now.Add(2 * time.Hour)
+	if events, _ := store.QueryEvents(context.Background(), Filter{}); len(events) != 0 {
+		t.Fatal("event didn't expire when the clock moved")
+	}
+
+	skewed := SkewedClock(-time.Minute).Now()
+	if diff := time.Since(skewed); diff < time.Minute || diff > time.Minute+time.Second {
+		t.Fatalf("skewed clock is %s behind", diff)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime/pprof"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/bench"
+)
+
+func benchCommand(args []string) error {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	relay := fs.String("relay", "", "relay to load")
+	connections := fs.Int("connections", 10, "number of connections")
+	duration := fs.Duration("duration", 10*time.Second, "how long to run")
+	publishRate := fs.Float64("publish-rate", 1, "events published per second on each connection")
+	subscribeRate := fs.Float64("subscribe-rate", 1, "REQs per second on each connection")
+	kind := fs.Int("kind", 1, "kind of the events published and queried")
+	contentSize := fs.Int("content-size", 100, "bytes of content of the events published")
+	limit := fs.Int("limit", 50, "limit of the REQs")
+	sec := fs.String("sec", "", "secret key (hex or nsec) signing the events, defaults to NOSTR_SECRET_KEY or a random one")
+	cpuProfile := fs.String("cpuprofile", "", "write a CPU profile of this process to this file")
+	fs.Parse(args)
+
+	if *relay == "" {
+		return fmt.Errorf("a --relay is needed")
+	}
+	config := bench.Config{
+		URL:           *relay,
+		Connections:   *connections,
+		Duration:      *duration,
+		PublishRate:   *publishRate,
+		SubscribeRate: *subscribeRate,
+		Kind:          *kind,
+		ContentSize:   *contentSize,
+		Filters:       nostr.Filters{{Kinds: []int{*kind}, Limit: *limit}},
+	}
+	if *sec != "" || os.Getenv("NOSTR_SECRET_KEY") != "" {
+		sk, err := secretKey(*sec)
+		if err != nil {
+			return err
+		}
+		config.SecretKey = sk
+	}
+
+	if *cpuProfile != "" {
+		f, err := os.Create(*cpuProfile)
+		if err != nil {
+			return fmt.Errorf("failed to create profile: %w", err)
+		}
+		defer f.Close()
+		if err := pprof.StartCPUProfile(f); err != nil {
+			return fmt.Errorf("failed to start profile: %w", err)
+		}
+		defer pprof.StopCPUProfile()
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	report, err := bench.Run(ctx, config)
+	if err != nil {
+		return err
+	}
+	fmt.Print(report)
+	return nil
+}
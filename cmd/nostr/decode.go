@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+func decodeCommand(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: nostr decode <nip19 code>")
+	}
+
+	prefix, value, err := nip19.Decode(args[0])
+	if err != nil {
+		return err
+	}
+
+	if s, ok := value.(string); ok {
+		fmt.Printf("%s: %s\n", prefix, s)
+		return nil
+	}
+	j, _ := json.MarshalIndent(value, "", "  ")
+	fmt.Printf("%s: %s\n", prefix, j)
+	return nil
+}
+
+func encodeCommand(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: nostr encode npub|nsec|note <hex>")
+	}
+
+	var code string
+	var err error
+	switch args[0] {
+	case "npub":
+		code, err = nip19.EncodePublicKey(args[1])
+	case "nsec":
+		code, err = nip19.EncodePrivateKey(args[1])
+	case "note":
+		code, err = nip19.EncodeNote(args[1])
+	default:
+		return fmt.Errorf("can't encode '%s'", args[0])
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(code)
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+func keyCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: nostr key generate|public [secret key]|vanity <prefix>")
+	}
+
+	switch args[0] {
+	case "generate":
+		sk := nostr.GeneratePrivateKey()
+		return printKeys(sk)
+	case "public":
+		input := ""
+		if len(args) > 1 {
+			input = args[1]
+		}
+		sk, err := secretKey(input)
+		if err != nil {
+			return err
+		}
+		return printKeys(sk)
+	case "vanity":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: nostr key vanity <prefix>")
+		}
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer cancel()
+		fmt.Fprintf(os.Stderr, "expecting to try about %.0f keys\n", nostr.VanityDifficulty(args[1]))
+		sk, err := nostr.MineVanityKey(ctx, args[1], nostr.WithProgress(func(attempts uint64) {
+			fmt.Fprintf(os.Stderr, "\rtried %d keys", attempts)
+		}, time.Second))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return err
+		}
+		return printKeys(sk)
+	default:
+		return fmt.Errorf("unknown key command '%s'", args[0])
+	}
+}
+
+func printKeys(sk string) error {
+	pk, err := nostr.GetPublicKey(sk)
+	if err != nil {
+		return err
+	}
+	nsec, _ := nip19.EncodePrivateKey(sk)
+	npub, _ := nip19.EncodePublicKey(pk)
+
+	fmt.Printf("secret key: %s\n", sk)
+	fmt.Printf("nsec:       %s\n", nsec)
+	fmt.Printf("public key: %s\n", pk)
+	fmt.Printf("npub:       %s\n", npub)
+	return nil
+}
+
+// secretKey reads a hex or nsec secret key from input or from NOSTR_SECRET_KEY, returning it as hex.
+func secretKey(input string) (string, error) {
+	if input == "" {
+		input = os.Getenv("NOSTR_SECRET_KEY")
+	}
+	if input == "" {
+		return "", fmt.Errorf("no secret key given, use --sec or NOSTR_SECRET_KEY")
+	}
+
+	return nostr.ParsePrivateKey(input)
+}
+
+// publicKey reads a hex or npub public key.
+func publicKey(input string) (nostr.PubKey, error) {
+	return nostr.ParsePublicKey(input)
+}
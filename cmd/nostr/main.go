@@ -0,0 +1,64 @@
+// Command nostr is a small tool for debugging relays and scripting, built on go-nostr.
+//
+//	nostr key generate
+//	nostr key public <nsec or hex secret key>
+//	nostr key vanity <npub prefix>
+//	nostr publish --kind 1 --content hello --tag t=nostr --relay wss://... [--sec ...]
+//	nostr req --authors <npub or hex> --kinds 1 --limit 10 --relay wss://... [--stream]
+//	nostr decode <npub|nsec|note|nprofile|nevent|naddr>
+//	nostr encode npub|nsec|note <hex>
+//	nostr bench --relay wss://... --connections 10 --duration 30s --publish-rate 1 --subscribe-rate 1
+//
+// The secret key used for signing can also be given in the NOSTR_SECRET_KEY environment variable.
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+var commands = map[string]func(args []string) error{
+	"key":     keyCommand,
+	"publish": publishCommand,
+	"req":     reqCommand,
+	"decode":  decodeCommand,
+	"encode":  encodeCommand,
+	"bench":   benchCommand,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	command, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	if err := command(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: nostr <key|publish|req|decode|encode|bench> [arguments]")
+}
+
+// stringList is a flag that can be repeated and also accepts comma-separated values.
+type stringList []string
+
+func (sl *stringList) String() string { return strings.Join(*sl, ",") }
+
+func (sl *stringList) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*sl = append(*sl, v)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func publishCommand(args []string) error {
+	fs := flag.NewFlagSet("publish", flag.ExitOnError)
+	kind := fs.Int("kind", 1, "event kind")
+	content := fs.String("content", "", "event content")
+	sec := fs.String("sec", "", "secret key (hex or nsec), defaults to NOSTR_SECRET_KEY")
+	createdAt := fs.Int64("created-at", 0, "unix timestamp, defaults to now")
+	var tags, relays stringList
+	fs.Var(&tags, "tag", "tag as name=value or name=value;value2..., can be repeated")
+	fs.Var(&relays, "relay", "relay to publish to, can be repeated")
+	fs.Parse(args)
+
+	sk, err := secretKey(*sec)
+	if err != nil {
+		return err
+	}
+
+	pk, _ := nostr.GetPublicKey(sk)
+	evt := nostr.Event{
+		PubKey:    nostr.MustPubKeyFromHex(pk),
+		Kind:      *kind,
+		Content:   *content,
+		CreatedAt: time.Now(),
+		Tags:      nostr.Tags{},
+	}
+	if *createdAt != 0 {
+		evt.CreatedAt = time.Unix(*createdAt, 0)
+	}
+	for _, tag := range tags {
+		name, values, ok := strings.Cut(tag, "=")
+		if !ok {
+			return fmt.Errorf("invalid tag '%s', should be name=value", tag)
+		}
+		evt.Tags = append(evt.Tags, append(nostr.Tag{name}, strings.Split(values, ";")...))
+	}
+	if err := evt.Sign(sk); err != nil {
+		return fmt.Errorf("failed to sign: %w", err)
+	}
+
+	// always print the event, so it can be piped somewhere else even without relays
+	j, _ := evt.MarshalJSON()
+	fmt.Println(string(j))
+
+	for _, url := range relays {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		relay, err := nostr.RelayConnect(ctx, url)
+		if err != nil {
+			cancel()
+			fmt.Printf("%s: failed to connect: %s\n", url, err)
+			continue
+		}
+		status, err := relay.Publish(ctx, evt)
+		if err != nil {
+			fmt.Printf("%s: %s (%s)\n", url, status, err)
+		} else {
+			fmt.Printf("%s: %s\n", url, status)
+		}
+		relay.Close()
+		cancel()
+	}
+	return nil
+}
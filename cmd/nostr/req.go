@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func reqCommand(args []string) error {
+	fs := flag.NewFlagSet("req", flag.ExitOnError)
+	var ids, authors, kinds, tags, relays stringList
+	fs.Var(&ids, "ids", "event ids")
+	fs.Var(&authors, "authors", "authors (hex or npub)")
+	fs.Var(&kinds, "kinds", "kinds")
+	fs.Var(&tags, "tag", "tag filter as name=value, can be repeated")
+	fs.Var(&relays, "relay", "relay to query, can be repeated")
+	since := fs.Int64("since", 0, "unix timestamp")
+	until := fs.Int64("until", 0, "unix timestamp")
+	limit := fs.Int("limit", 0, "maximum number of events")
+	search := fs.String("search", "", "NIP-50 search query")
+	stream := fs.Bool("stream", false, "keep listening for new events after the stored ones")
+	fs.Parse(args)
+
+	if len(relays) == 0 {
+		return fmt.Errorf("at least one --relay is needed")
+	}
+
+	filter := nostr.Filter{Limit: *limit, Search: *search}
+	for _, id := range ids {
+		if strings.HasPrefix(id, "note1") {
+			parsed, err := nostr.IDFromNote(id)
+			if err != nil {
+				return fmt.Errorf("invalid id '%s': %w", id, err)
+			}
+			filter.IDs = append(filter.IDs, parsed)
+			continue
+		}
+		parsed, err := nostr.IDFromHex(id)
+		if err != nil {
+			return fmt.Errorf("invalid id '%s': %w", id, err)
+		}
+		filter.IDs = append(filter.IDs, parsed)
+	}
+	for _, author := range authors {
+		pk, err := publicKey(author)
+		if err != nil {
+			return fmt.Errorf("invalid author '%s': %w", author, err)
+		}
+		filter.Authors = append(filter.Authors, pk)
+	}
+	for _, kind := range kinds {
+		k, err := strconv.Atoi(kind)
+		if err != nil {
+			return fmt.Errorf("invalid kind '%s'", kind)
+		}
+		filter.Kinds = append(filter.Kinds, k)
+	}
+	for _, tag := range tags {
+		name, value, ok := strings.Cut(tag, "=")
+		if !ok {
+			return fmt.Errorf("invalid tag '%s', should be name=value", tag)
+		}
+		if filter.Tags == nil {
+			filter.Tags = make(nostr.TagMap)
+		}
+		filter.Tags[name] = append(filter.Tags[name], value)
+	}
+	if *since != 0 {
+		t := time.Unix(*since, 0)
+		filter.Since = &t
+	}
+	if *until != 0 {
+		t := time.Unix(*until, 0)
+		filter.Until = &t
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	pool := nostr.NewSimplePool(ctx)
+	defer pool.Close()
+
+	var events chan *nostr.Event
+	if *stream {
+		events = pool.SubMany(ctx, relays, nostr.Filters{filter})
+	} else {
+		events = pool.SubManyEose(ctx, relays, nostr.Filters{filter})
+	}
+	for evt := range events {
+		j, _ := evt.MarshalJSON()
+		fmt.Println(string(j))
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+// Package crawler discovers relays by following the NIP-65 relay lists and the relay hints in
+// contact lists of some users and the people they follow, and checks which ones are reachable,
+// e.g. to choose relays for a new user.
+//
+// It also builds follow graphs from contact lists, see Crawler.FollowGraph, for web-of-trust
+// scoring and recommendations.
+package crawler
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip11"
+)
+
+// Relay is a relay found by the crawler.
+type Relay struct {
+	URL string
+
+	// Users is how many users have this relay in their relay lists or as a hint in contact lists.
+	Users int
+
+	Reachable   bool
+	Latency     time.Duration
+	Information *nip11.RelayInformationDocument // nil if it doesn't have a NIP-11 document
+}
+
+// Score is higher for relays used by more people and that answer faster. It is 0 for relays
+// that can't be reached.
+func (r Relay) Score() float64 {
+	if !r.Reachable {
+		return 0
+	}
+	return float64(r.Users+1) / (1 + r.Latency.Seconds())
+}
+
+// Crawler finds relays starting from some users. Their relay lists and contact lists are
+// fetched from Seeds and the relays found along the way.
+type Crawler struct {
+	Pool  *nostr.SimplePool
+	Seeds []string
+
+	// Depth is how many hops of follows are crawled, 0 means only the starting users.
+	Depth int
+
+	// MaxUsers and MaxRelays stop the crawl from growing too much.
+	MaxUsers  int
+	MaxRelays int
+
+	// ProbeTimeout is how long to wait for each relay to connect and answer a ping.
+	ProbeTimeout time.Duration
+
+	// Concurrency is how many relays are probed, or requests for lists are made, at the same time.
+	Concurrency int
+
+	// OnProgress is called as FollowGraph fetches contact lists, from one goroutine at a time.
+	OnProgress func(Progress)
+}
+
+func New(pool *nostr.SimplePool, seeds []string) *Crawler {
+	return &Crawler{
+		Pool:         pool,
+		Seeds:        seeds,
+		Depth:        1,
+		MaxUsers:     500,
+		MaxRelays:    200,
+		ProbeTimeout: 5 * time.Second,
+		Concurrency:  20,
+	}
+}
+
+// Crawl returns the relays found from the given users, reachable ones first, best first.
+func (c *Crawler) Crawl(ctx context.Context, pubkeys []nostr.PubKey) []Relay {
+	users := make(map[string]int) // relay -> users
+	visited := make(map[nostr.PubKey]struct{})
+	queryRelays := append([]string{}, c.Seeds...)
+
+	current := pubkeys
+	for depth := 0; depth <= c.Depth && len(current) > 0; depth++ {
+		var batch []nostr.PubKey
+		for _, pk := range current {
+			if _, ok := visited[pk]; ok || len(visited) >= c.MaxUsers {
+				continue
+			}
+			visited[pk] = struct{}{}
+			batch = append(batch, pk)
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		var next []nostr.PubKey
+		filter := nostr.Filter{Kinds: []int{nostr.KindContactList, nostr.KindRelayListMetadata}, Authors: batch}
+		events := c.fetch(ctx, queryRelays, filter)
+		for _, evt := range nostr.LatestVersions(events) {
+			found := make(map[string]struct{})
+			for _, tag := range evt.Tags {
+				if len(tag) < 2 {
+					continue
+				}
+				switch {
+				case evt.Kind == nostr.KindRelayListMetadata && tag[0] == "r":
+					found[nostr.NormalizeURL(tag[1])] = struct{}{}
+				case evt.Kind == nostr.KindContactList && tag[0] == "p":
+					if len(tag) >= 3 && nostr.IsValidRelayURL(tag[2]) {
+						found[nostr.NormalizeURL(tag[2])] = struct{}{}
+					}
+					if pk, err := nostr.PubKeyFromHex(tag[1]); err == nil {
+						next = append(next, pk)
+					}
+				}
+			}
+			for url := range found {
+				if _, ok := users[url]; !ok && len(users) >= c.MaxRelays {
+					continue
+				}
+				users[url]++
+			}
+		}
+
+		// the relays of the users we have seen are the best places to look for who they follow
+		queryRelays = append([]string{}, c.Seeds...)
+		for url := range users {
+			queryRelays = append(queryRelays, url)
+		}
+		current = next
+	}
+
+	relays := make([]Relay, 0, len(users))
+	for url, count := range users {
+		relays = append(relays, Relay{URL: url, Users: count})
+	}
+	c.probe(ctx, relays)
+
+	sort.SliceStable(relays, func(i, j int) bool {
+		if relays[i].Score() != relays[j].Score() {
+			return relays[i].Score() > relays[j].Score()
+		}
+		return relays[i].URL < relays[j].URL
+	})
+	return relays
+}
+
+func (c *Crawler) fetch(ctx context.Context, relays []string, filter nostr.Filter) []*nostr.Event {
+	ctx, cancel := context.WithTimeout(ctx, 2*c.ProbeTimeout)
+	defer cancel()
+
+	var events []*nostr.Event
+	for _, part := range filter.Split(100) {
+		for evt := range c.Pool.SubManyEose(ctx, relays, nostr.Filters{part}) {
+			events = append(events, evt)
+		}
+	}
+	return events
+}
+
+// probe connects to each relay, measures its latency and fetches its NIP-11 document.
+func (c *Crawler) probe(ctx context.Context, relays []Relay) {
+	concurrency := c.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	sem := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+	for i := range relays {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(relay *Relay) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			ctx, cancel := context.WithTimeout(ctx, c.ProbeTimeout)
+			defer cancel()
+
+			if info, err := nip11.Fetch(ctx, relay.URL); err == nil {
+				relay.Information = info
+			}
+			relay.Latency, relay.Reachable = ping(ctx, relay.URL)
+		}(&relays[i])
+	}
+	wg.Wait()
+}
+
+// ping connects to url without keeping the connection, giving up when ctx is done.
+func ping(ctx context.Context, url string) (time.Duration, bool) {
+	type result struct {
+		latency time.Duration
+		ok      bool
+	}
+	done := make(chan result, 1)
+	go func() {
+		relay, err := nostr.RelayConnect(ctx, url)
+		if err != nil {
+			done <- result{}
+			return
+		}
+		defer relay.Close()
+		latency, err := relay.Ping(ctx)
+		done <- result{latency, err == nil}
+	}()
+
+	select {
+	case r := <-done:
+		return r.latency, r.ok
+	case <-ctx.Done():
+		return 0, false
+	}
+}
@@ -0,0 +1,149 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// newRelay serves events for any REQ matching them, and answers pings.
+func newRelay(t *testing.T, events func() []*nostr.Event) string {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") == "application/nostr+json" {
+			w.Write([]byte(`{"name":"test relay"}`))
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			var msg []json.RawMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			var label, id string
+			json.Unmarshal(msg[0], &label)
+			if label != "REQ" {
+				continue
+			}
+			json.Unmarshal(msg[1], &id)
+			var filter nostr.Filter
+			json.Unmarshal(msg[2], &filter)
+			for _, evt := range events() {
+				if filter.Matches(evt) {
+					conn.WriteJSON([]any{"EVENT", id, evt})
+				}
+			}
+			conn.WriteJSON([]any{"EOSE", id})
+		}
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func TestCrawl(t *testing.T) {
+	skA, skB := nostr.GeneratePrivateKey(), nostr.GeneratePrivateKey()
+	pkA, _ := nostr.GetPublicKey(skA)
+	pkB, _ := nostr.GetPublicKey(skB)
+
+	var events []*nostr.Event
+	r1 := newRelay(t, func() []*nostr.Event { return events })
+	r2 := newRelay(t, func() []*nostr.Event { return nil })
+	unreachable := "ws://127.0.0.1:1"
+
+	sign := func(sk string, pk string, kind int, tags nostr.Tags) *nostr.Event {
+		evt := &nostr.Event{PubKey: nostr.MustPubKeyFromHex(pk), CreatedAt: time.Unix(1700000000, 0), Kind: kind, Tags: tags}
+		evt.Sign(sk)
+		return evt
+	}
+	events = []*nostr.Event{
+		sign(skA, pkA, nostr.KindRelayListMetadata, nostr.Tags{{"r", r1}, {"r", unreachable, "write"}}),
+		sign(skA, pkA, nostr.KindContactList, nostr.Tags{{"p", pkB, r2}}),
+		sign(skB, pkB, nostr.KindRelayListMetadata, nostr.Tags{{"r", r1}}),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	pool := nostr.NewSimplePool(ctx)
+	defer pool.Close()
+
+	c := New(pool, []string{r1})
+	relays := c.Crawl(ctx, []nostr.PubKey{nostr.MustPubKeyFromHex(pkA)})
+
+	if len(relays) != 3 {
+		t.Fatalf("expected 3 relays, got %v", relays)
+	}
+	if relays[0].URL != r1 || relays[0].Users != 2 || !relays[0].Reachable || relays[0].Information == nil {
+		t.Errorf("wrong first relay %+v", relays[0])
+	}
+	if relays[1].URL != r2 || relays[1].Users != 1 || !relays[1].Reachable {
+		t.Errorf("wrong second relay %+v", relays[1])
+	}
+	if relays[2].URL != unreachable || relays[2].Reachable {
+		t.Errorf("wrong third relay %+v", relays[2])
+	}
+}
+
+func TestFollowGraph(t *testing.T) {
+	keys := make([]string, 5)
+	pks := make([]nostr.PubKey, 5)
+	for i := range keys {
+		keys[i] = nostr.GeneratePrivateKey()
+		pk, _ := nostr.GetPublicKey(keys[i])
+		pks[i] = nostr.MustPubKeyFromHex(pk)
+	}
+	contacts := func(i int, follows ...int) *nostr.Event {
+		evt := &nostr.Event{PubKey: pks[i], CreatedAt: time.Unix(1700000000, 0), Kind: nostr.KindContactList}
+		for _, f := range follows {
+			evt.Tags = append(evt.Tags, nostr.Tag{"p", pks[f].Hex()})
+		}
+		evt.Sign(keys[i])
+		return evt
+	}
+
+	// 0 follows 1 and 2, who both follow 3, and 2 follows 4 too. 3's list is only in its own relay
+	var outbox []*nostr.Event
+	own := newRelay(t, func() []*nostr.Event { return outbox })
+	relayList := &nostr.Event{PubKey: pks[3], CreatedAt: time.Unix(1700000000, 0), Kind: nostr.KindRelayListMetadata, Tags: nostr.Tags{{"r", own}}}
+	relayList.Sign(keys[3])
+	outbox = []*nostr.Event{contacts(3, 0)}
+	events := []*nostr.Event{contacts(0, 1, 2), contacts(1, 3), contacts(2, 3, 4), relayList}
+	seed := newRelay(t, func() []*nostr.Event { return events })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	pool := nostr.NewSimplePool(ctx)
+	defer pool.Close()
+
+	var progress []Progress
+	c := New(pool, []string{seed})
+	c.Depth = 2
+	c.OnProgress = func(p Progress) { progress = append(progress, p) }
+	graph := c.FollowGraph(ctx, pks[0])
+
+	if len(graph.Follows) != 4 || len(graph.Follows[pks[3]]) != 1 {
+		t.Errorf("expected the lists of 0, 1, 2 and 3, got %v", graph.Follows)
+	}
+	if graph.Distance[pks[0]] != 0 || graph.Distance[pks[2]] != 1 || graph.Distance[pks[4]] != 2 {
+		t.Errorf("wrong distances %v", graph.Distance)
+	}
+	if followers := graph.Followers(pks[3]); len(followers) != 2 {
+		t.Errorf("3 should have 2 followers, got %v", followers)
+	}
+	if recs := graph.Recommendations(pks[0], 5); len(recs) != 2 || recs[0] != pks[3] || recs[1] != pks[4] {
+		t.Errorf("wrong recommendations %v", recs)
+	}
+	if len(progress) != 3 || progress[2].Depth != 2 || progress[2].Pending != 0 {
+		t.Errorf("wrong progress %+v", progress)
+	}
+}
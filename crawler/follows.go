@@ -0,0 +1,199 @@
+package crawler
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Graph is who follows whom, as found in the contact lists of the users crawled.
+type Graph struct {
+	// Follows has the contact list of each user whose list was fetched.
+	Follows map[nostr.PubKey][]nostr.PubKey
+
+	// Distance is how many hops of follows each user is from the start, which is at 0.
+	Distance map[nostr.PubKey]int
+}
+
+// Progress is given to Crawler.OnProgress as contact lists are fetched.
+type Progress struct {
+	Depth int // distance of the users being fetched now
+
+	Fetched int // users at this depth whose lists were requested so far
+	Pending int // users at this depth still to be requested
+
+	Users int // users found so far, at any distance
+}
+
+// Followers returns the users in the graph that follow pk.
+func (g *Graph) Followers(pk nostr.PubKey) []nostr.PubKey {
+	var followers []nostr.PubKey
+	for follower, follows := range g.Follows {
+		for _, followed := range follows {
+			if followed == pk {
+				followers = append(followers, follower)
+				break
+			}
+		}
+	}
+	sort.Slice(followers, func(i, j int) bool { return followers[i].Hex() < followers[j].Hex() })
+	return followers
+}
+
+// Recommendations returns up to n users that pk doesn't follow yet, ranked by how many of the
+// users pk follows follow them.
+func (g *Graph) Recommendations(pk nostr.PubKey, n int) []nostr.PubKey {
+	following := make(map[nostr.PubKey]struct{}, len(g.Follows[pk]))
+	for _, followed := range g.Follows[pk] {
+		following[followed] = struct{}{}
+	}
+
+	votes := make(map[nostr.PubKey]int)
+	for _, followed := range g.Follows[pk] {
+		for _, candidate := range g.Follows[followed] {
+			if _, ok := following[candidate]; ok || candidate == pk {
+				continue
+			}
+			votes[candidate]++
+		}
+	}
+
+	candidates := make([]nostr.PubKey, 0, len(votes))
+	for candidate := range votes {
+		candidates = append(candidates, candidate)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if votes[candidates[i]] != votes[candidates[j]] {
+			return votes[candidates[i]] > votes[candidates[j]]
+		}
+		return candidates[i].Hex() < candidates[j].Hex()
+	})
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+	return candidates
+}
+
+// FollowGraph fetches the contact list of start and of the people they follow, up to Depth
+// hops away and MaxUsers users. Lists are looked for in Seeds and in the write relays of the
+// users found, at most MaxRelays of them. Concurrency is how many requests of up to 100 users
+// are made at the same time, and OnProgress, if set, is called after each.
+func (c *Crawler) FollowGraph(ctx context.Context, start nostr.PubKey) *Graph {
+	graph := &Graph{
+		Follows:  make(map[nostr.PubKey][]nostr.PubKey),
+		Distance: map[nostr.PubKey]int{start: 0},
+	}
+	queryRelays := append([]string{}, c.Seeds...)
+	known := make(map[string]struct{})
+	for _, url := range queryRelays {
+		known[nostr.NormalizeURL(url)] = struct{}{}
+	}
+
+	// process reads the lists in events, returning the users found for the next depth and the
+	// relays found
+	process := func(events []*nostr.Event, depth int) (next []nostr.PubKey, relays []string) {
+		for _, evt := range nostr.LatestVersions(events) {
+			switch evt.Kind {
+			case nostr.KindContactList:
+				if _, ok := graph.Follows[evt.PubKey]; ok {
+					continue
+				}
+				follows := make([]nostr.PubKey, 0, len(evt.Tags))
+				for _, tag := range evt.Tags.GetAll([]string{"p", ""}) {
+					pk, err := nostr.PubKeyFromHex(tag.Value())
+					if err != nil {
+						continue
+					}
+					follows = append(follows, pk)
+					if _, ok := graph.Distance[pk]; !ok && len(graph.Distance) < c.MaxUsers {
+						graph.Distance[pk] = depth + 1
+						next = append(next, pk)
+					}
+				}
+				graph.Follows[evt.PubKey] = follows
+			case nostr.KindRelayListMetadata:
+				for _, tag := range evt.Tags.GetAll([]string{"r", ""}) {
+					url := nostr.NormalizeURL(tag.Value())
+					if url == "" || (len(tag) > 2 && tag[2] == "read") || len(known) >= c.MaxRelays {
+						continue
+					}
+					if _, ok := known[url]; !ok {
+						known[url] = struct{}{}
+						relays = append(relays, url)
+					}
+				}
+			}
+		}
+		return next, relays
+	}
+
+	current := []nostr.PubKey{start}
+	for depth := 0; depth <= c.Depth && len(current) > 0; depth++ {
+		events := c.fetchLists(ctx, queryRelays, current, func(fetched int) {
+			if c.OnProgress != nil {
+				c.OnProgress(Progress{
+					Depth:   depth,
+					Fetched: fetched,
+					Pending: len(current) - fetched,
+					Users:   len(graph.Distance),
+				})
+			}
+		})
+		next, relays := process(events, depth)
+		queryRelays = append(queryRelays, relays...)
+
+		// the lists we didn't find may be in the relays we just learned about
+		var missing []nostr.PubKey
+		for _, pk := range current {
+			if _, ok := graph.Follows[pk]; !ok {
+				missing = append(missing, pk)
+			}
+		}
+		if len(relays) > 0 && len(missing) > 0 {
+			more, _ := process(c.fetchLists(ctx, relays, missing, nil), depth)
+			next = append(next, more...)
+		}
+
+		current = next
+	}
+	return graph
+}
+
+// fetchLists gets the contact and relay lists of authors, in parts of 100 authors requested
+// concurrently. done, if not nil, is called after each part with how many authors were
+// requested so far.
+func (c *Crawler) fetchLists(ctx context.Context, relays []string, authors []nostr.PubKey, done func(fetched int)) []*nostr.Event {
+	concurrency := c.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	filter := nostr.Filter{Kinds: []int{nostr.KindContactList, nostr.KindRelayListMetadata}, Authors: authors}
+
+	var mutex sync.Mutex
+	var events []*nostr.Event
+	fetched := 0
+	sem := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+	for _, part := range filter.Split(100) {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(part nostr.Filter) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			found := c.fetch(ctx, relays, part)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			events = append(events, found...)
+			fetched += len(part.Authors)
+			if done != nil {
+				done(fetched)
+			}
+		}(part)
+	}
+	wg.Wait()
+	return events
+}
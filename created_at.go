@@ -0,0 +1,33 @@
+package nostr
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrCreatedAtOutOfRange is wrapped by the errors for events whose created_at is too far from now.
+var ErrCreatedAtOutOfRange = errors.New("created_at is out of the accepted range")
+
+// CreatedAtWindow is how far in the past and in the future the created_at of events can be,
+// as many relays enforce. Zero means there is no limit on that side.
+type CreatedAtWindow struct {
+	Past   time.Duration
+	Future time.Duration
+
+	// ReportOnly makes relays deliver events outside the window after reporting them on Errors,
+	// instead of dropping them, see WithCreatedAtWindow.
+	ReportOnly bool
+}
+
+// Check fails with an error wrapping ErrCreatedAtOutOfRange if evt was created more than
+// w.Past before now or more than w.Future after it.
+func (w CreatedAtWindow) Check(evt *Event, now time.Time) error {
+	if w.Past > 0 && evt.CreatedAt.Before(now.Add(-w.Past)) {
+		return fmt.Errorf("event %s is older than %s: %w", evt.ID, w.Past, ErrCreatedAtOutOfRange)
+	}
+	if w.Future > 0 && evt.CreatedAt.After(now.Add(w.Future)) {
+		return fmt.Errorf("event %s is more than %s in the future: %w", evt.ID, w.Future, ErrCreatedAtOutOfRange)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package nostr
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCreatedAtWindow(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	window := CreatedAtWindow{Past: time.Hour, Future: 15 * time.Minute}
+
+	for _, tc := range []struct {
+		createdAt time.Time
+		ok        bool
+	}{
+		{now, true},
+		{now.Add(-59 * time.Minute), true},
+		{now.Add(-2 * time.Hour), false},
+		{now.Add(10 * time.Minute), true},
+		{now.Add(time.Hour), false},
+	} {
+		err := window.Check(&Event{CreatedAt: tc.createdAt}, now)
+		if (err == nil) != tc.ok || (err != nil && !errors.Is(err, ErrCreatedAtOutOfRange)) {
+			t.Errorf("created_at %s: unexpected error %v", tc.createdAt.Sub(now), err)
+		}
+	}
+
+	if err := (CreatedAtWindow{}).Check(&Event{CreatedAt: time.Unix(0, 0)}, now); err != nil {
+		t.Errorf("empty window has limits: %v", err)
+	}
+}
+
+func TestRelayCreatedAtWindow(t *testing.T) {
+	sk, pk := makeKeyPair(t)
+	old := Event{Kind: 1, PubKey: pk, CreatedAt: time.Now().Add(-48 * time.Hour), Content: "old"}
+	old.Sign(sk)
+	recent := Event{Kind: 1, PubKey: pk, CreatedAt: time.Now().Add(-time.Minute), Content: "recent"}
+	recent.Sign(sk)
+
+	ws := newWebsocketServer((&fakeRelay{events: []Event{old, recent}}).handle)
+	defer ws.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	relay, err := RelayConnect(ctx, ws.URL, WithCreatedAtWindow(CreatedAtWindow{Past: 24 * time.Hour}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer relay.Close()
+
+	events, err := relay.Query(ctx, Filter{Kinds: []int{1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].ID != recent.ID {
+		t.Fatalf("expected only the recent event, got %v", events)
+	}
+	select {
+	case err := <-relay.Errors:
+		if !errors.Is(err, ErrCreatedAtOutOfRange) {
+			t.Fatalf("unexpected error %v", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("old event wasn't reported")
+	}
+}
@@ -0,0 +1,189 @@
+package nostr
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CursorStore remembers, for each subscription filter, the created_at of the newest event
+// received, so subscriptions can be resumed from there. See WithCursor.
+type CursorStore interface {
+	LoadCursor(key string) (time.Time, bool)
+	SaveCursor(key string, cursor time.Time) error
+}
+
+// CursorKey identifies a filter on a relay, ignoring since, until and limit which are
+// expected to change between runs.
+func CursorKey(relayURL string, filter Filter) string {
+	h := sha256.New()
+	h.Write([]byte(NormalizeURL(relayURL)))
+	h.Write([]byte{' '})
+	h.Write([]byte(FilterKey(filter)))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// FilterKey is a hash of filter that is the same for filters asking for the same events, in
+// any order, so it can be used to find things saved for a filter across runs. Like CursorKey,
+// it ignores since, until and limit.
+func FilterKey(filter Filter) string {
+	canonical := Filter{
+		IDs:            sortedCopy(filter.IDs, func(a, b ID) bool { return bytes.Compare(a[:], b[:]) < 0 }),
+		IDPrefixes:     sortedCopy(filter.IDPrefixes, func(a, b string) bool { return a < b }),
+		Kinds:          sortedCopy(filter.Kinds, func(a, b int) bool { return a < b }),
+		Authors:        sortedCopy(filter.Authors, func(a, b PubKey) bool { return bytes.Compare(a[:], b[:]) < 0 }),
+		AuthorPrefixes: sortedCopy(filter.AuthorPrefixes, func(a, b string) bool { return a < b }),
+		Search:         filter.Search,
+	}
+	if filter.Tags != nil {
+		canonical.Tags = make(TagMap, len(filter.Tags))
+		for name, values := range filter.Tags {
+			canonical.Tags[name] = sortedCopy(values, func(a, b string) bool { return a < b })
+		}
+	}
+
+	// MarshalJSON writes tags sorted by name
+	j, _ := json.Marshal(canonical)
+	h := sha256.Sum256(j)
+	return hex.EncodeToString(h[:])
+}
+
+// sortedCopy returns a sorted copy of s, which is nil only if s is, as empty and nil lists
+// mean different things in filters.
+func sortedCopy[T any](s []T, less func(a, b T) bool) []T {
+	if s == nil {
+		return nil
+	}
+	sorted := append(make([]T, 0, len(s)), s...)
+	sort.Slice(sorted, func(i, j int) bool { return less(sorted[i], sorted[j]) })
+	return sorted
+}
+
+var (
+	_ CursorStore = (*MemoryCursorStore)(nil)
+	_ CursorStore = (*FileCursorStore)(nil)
+)
+
+// MemoryCursorStore keeps cursors only while the program runs, which is still
+// useful for resuming subscriptions after reconnections.
+type MemoryCursorStore struct {
+	mutex   sync.Mutex
+	cursors map[string]time.Time
+}
+
+func NewMemoryCursorStore() *MemoryCursorStore {
+	return &MemoryCursorStore{cursors: make(map[string]time.Time)}
+}
+
+func (ms *MemoryCursorStore) LoadCursor(key string) (time.Time, bool) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	cursor, ok := ms.cursors[key]
+	return cursor, ok
+}
+
+func (ms *MemoryCursorStore) SaveCursor(key string, cursor time.Time) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	ms.cursors[key] = cursor
+	return nil
+}
+
+// how long FileCursorStore waits after a cursor changes before writing them all
+const cursorWriteDelay = time.Second
+
+// FileCursorStore keeps cursors in a JSON file, so they survive restarts. Cursors change with
+// every event, so they are written at most once a second: call Flush before exiting to write
+// the latest ones.
+type FileCursorStore struct {
+	path    string
+	mutex   sync.Mutex
+	cursors map[string]int64
+	dirty   bool
+	timer   *time.Timer
+	err     error // of the last write done in the background
+}
+
+// NewFileCursorStore loads the cursors saved at path, which is created if it doesn't exist.
+func NewFileCursorStore(path string) (*FileCursorStore, error) {
+	fs := &FileCursorStore{path: path, cursors: make(map[string]int64)}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fs, nil
+		}
+		return nil, fmt.Errorf("failed to read cursors from %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &fs.cursors); err != nil {
+		return nil, fmt.Errorf("failed to parse cursors from %s: %w", path, err)
+	}
+	return fs, nil
+}
+
+func (fs *FileCursorStore) LoadCursor(key string) (time.Time, bool) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	ts, ok := fs.cursors[key]
+	return time.Unix(ts, 0), ok
+}
+
+// SaveCursor records cursor to be written soon, it returns the error of the previous write if it
+// failed.
+func (fs *FileCursorStore) SaveCursor(key string, cursor time.Time) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	fs.cursors[key] = cursor.Unix()
+	fs.dirty = true
+	if fs.timer == nil {
+		fs.timer = time.AfterFunc(cursorWriteDelay, func() {
+			fs.mutex.Lock()
+			defer fs.mutex.Unlock()
+			fs.timer = nil
+			fs.err = fs.write()
+		})
+	}
+
+	err := fs.err
+	fs.err = nil
+	return err
+}
+
+// Flush writes the cursors saved since the last write right away.
+func (fs *FileCursorStore) Flush() error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	if fs.timer != nil {
+		fs.timer.Stop()
+		fs.timer = nil
+	}
+	return fs.write()
+}
+
+func (fs *FileCursorStore) write() error {
+	if !fs.dirty {
+		return nil
+	}
+	data, err := json.Marshal(fs.cursors)
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first so a crash doesn't leave a corrupted file behind
+	tmp := fs.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to save cursors: %w", err)
+	}
+	if err := os.Rename(tmp, fs.path); err != nil {
+		return fmt.Errorf("failed to save cursors: %w", err)
+	}
+	fs.dirty = false
+	return nil
+}
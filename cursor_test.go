@@ -0,0 +1,124 @@
+package nostr
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileCursorStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cursors.json")
+	store, err := NewFileCursorStore(path)
+	if err != nil {
+		t.Fatalf("failed to create store: %s", err)
+	}
+	key := CursorKey("wss://relay.example.com", Filter{Kinds: []int{1}})
+	if err := store.SaveCursor(key, time.Unix(1672068534, 0)); err != nil {
+		t.Fatalf("failed to save: %s", err)
+	}
+
+	// writes are batched
+	if early, _ := NewFileCursorStore(path); early != nil {
+		if _, ok := early.LoadCursor(key); ok {
+			t.Error("cursor was written right away")
+		}
+	}
+	if err := store.Flush(); err != nil {
+		t.Fatalf("failed to flush: %s", err)
+	}
+
+	reopened, err := NewFileCursorStore(path)
+	if err != nil {
+		t.Fatalf("failed to reopen store: %s", err)
+	}
+	if cursor, ok := reopened.LoadCursor(key); !ok || cursor.Unix() != 1672068534 {
+		t.Errorf("cursor wasn't persisted: %v %v", cursor, ok)
+	}
+
+	since := time.Now()
+	if CursorKey("wss://relay.example.com/", Filter{Kinds: []int{1}, Since: &since, Limit: 10}) != key {
+		t.Error("since and limit shouldn't change the cursor key")
+	}
+	if CursorKey("wss://other.example.com", Filter{Kinds: []int{1}}) == key {
+		t.Error("different relays should have different cursors")
+	}
+}
+
+func TestCursorKeyIsStable(t *testing.T) {
+	filter := Filter{
+		Kinds: []int{1, 7},
+		Tags:  TagMap{"e": {"a", "b"}, "p": {"c"}, "t": {"nostr", "go"}, "d": {"x"}},
+	}
+	key := CursorKey("wss://relay.example.com", filter)
+	for i := 0; i < 50; i++ {
+		if CursorKey("wss://relay.example.com", filter) != key {
+			t.Fatal("cursor key changed between calls")
+		}
+	}
+
+	reordered := Filter{
+		Kinds: []int{7, 1},
+		Tags:  TagMap{"t": {"go", "nostr"}, "d": {"x"}, "p": {"c"}, "e": {"b", "a"}},
+	}
+	if CursorKey("wss://relay.example.com", reordered) != key {
+		t.Error("the order of kinds and tag values shouldn't change the cursor key")
+	}
+	if FilterKey(Filter{Kinds: []int{}}) == FilterKey(Filter{}) {
+		t.Error("empty and missing kinds should have different keys")
+	}
+}
+
+func TestSubscriptionWithCursor(t *testing.T) {
+	priv, pub := makeKeyPair(t)
+	fr := &fakeRelay{}
+	for _, ts := range []int64{1672068534, 1672068600} {
+		evt := Event{Kind: 1, CreatedAt: time.Unix(ts, 0), PubKey: pub}
+		evt.Sign(priv)
+		fr.events = append(fr.events, evt)
+	}
+	ws := newWebsocketServer(fr.handle)
+	defer ws.Close()
+
+	rl := mustRelayConnect(ws.URL)
+	defer rl.Close()
+
+	cursors := NewMemoryCursorStore()
+	filters := Filters{{Kinds: []int{1}}}
+	for run := 0; run < 2; run++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		sub, err := rl.Sub(ctx, filters, WithCursor(cursors))
+		if err != nil {
+			t.Fatalf("subscribe failed: %s", err)
+		}
+		received := 0
+	loop:
+		for {
+			select {
+			case <-sub.Events:
+				received++
+			case <-sub.EndOfStoredEvents:
+				break loop
+			}
+		}
+		cancel()
+
+		fr.mu.Lock()
+		since := fr.lastReq[0].Since
+		fr.mu.Unlock()
+		switch run {
+		case 0:
+			if since != nil || received != 2 {
+				t.Errorf("first run should get everything, got %d events since %v", received, since)
+			}
+		case 1:
+			if since == nil || since.Unix() != 1672068600 {
+				t.Errorf("second run should start from the newest event, got since %v", since)
+			}
+		}
+	}
+
+	if filters[0].Since != nil {
+		t.Error("the caller's filters shouldn't be modified")
+	}
+}
@@ -0,0 +1,102 @@
+package nostr
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// KindDecoder turns an event into the type that represents its kind, like *ProfileMetadata.
+type KindDecoder func(evt *Event) (any, error)
+
+// ErrNoKindDecoder is returned by DecodeEvent for kinds without a decoder.
+var ErrNoKindDecoder = errors.New("no decoder registered")
+
+var (
+	kindDecodersMutex sync.RWMutex
+	kindDecoders      = map[int]KindDecoder{
+		KindSetMetadata:       func(evt *Event) (any, error) { return ParseMetadata(*evt) },
+		KindRelayListMetadata: func(evt *Event) (any, error) { return ParseRelayList(evt) },
+	}
+)
+
+// RegisterKindDecoder sets the decoder used by DecodeEvent for events of kind, replacing the one
+// already registered. A nil decoder removes it. Packages for NIPs register the decoders for
+// their kinds when imported, e.g. nip57 for zap receipts.
+func RegisterKindDecoder(kind int, decoder KindDecoder) {
+	kindDecodersMutex.Lock()
+	defer kindDecodersMutex.Unlock()
+
+	if decoder == nil {
+		delete(kindDecoders, kind)
+	} else {
+		kindDecoders[kind] = decoder
+	}
+}
+
+// DecodeEvent returns the event as the type registered for its kind, by default *ProfileMetadata
+// for kind 0 and *RelayList for kind 10002. Other kinds fail with ErrNoKindDecoder.
+func DecodeEvent(evt *Event) (any, error) {
+	kindDecodersMutex.RLock()
+	decoder, ok := kindDecoders[evt.Kind]
+	kindDecodersMutex.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("can't decode kind %d: %w", evt.Kind, ErrNoKindDecoder)
+	}
+	return decoder(evt)
+}
+
+// WithDecodedEvents makes the subscription call handler with every event it receives whose kind
+// has a decoder, along with the decoded value, before it is sent to sub.Events. Events that fail
+// to decode are reported on the relay's Errors channel. handler shouldn't block.
+func WithDecodedEvents(handler func(evt *Event, value any)) SubscriptionOption {
+	return func(sub *Subscription) {
+		sub.onDecoded = handler
+	}
+}
+
+// decode passes evt to the handler given with WithDecodedEvents, if it can be decoded.
+func (sub *Subscription) decode(evt *Event) {
+	value, err := DecodeEvent(evt)
+	if errors.Is(err, ErrNoKindDecoder) {
+		return
+	}
+	if err != nil {
+		sub.Relay.reportError(ErrorParse, fmt.Errorf("event %s: %w", evt.ID, err))
+		return
+	}
+	sub.onDecoded(evt, value)
+}
+
+// RelayList are the relays someone reads from and writes to, from their NIP-65 kind-10002 event.
+// Relays without a marker are in both.
+type RelayList struct {
+	Read  []string
+	Write []string
+}
+
+func ParseRelayList(evt *Event) (*RelayList, error) {
+	if evt.Kind != KindRelayListMetadata {
+		return nil, fmt.Errorf("event %s is kind %d, not %d", evt.ID, evt.Kind, KindRelayListMetadata)
+	}
+
+	list := &RelayList{}
+	for _, tag := range evt.Tags.GetAll([]string{"r", ""}) {
+		url := NormalizeURL(tag.Value())
+		if url == "" {
+			continue
+		}
+		marker := ""
+		if len(tag) > 2 {
+			marker = tag[2]
+		}
+		if marker != "write" {
+			list.Read = append(list.Read, url)
+		}
+		if marker != "read" {
+			list.Write = append(list.Write, url)
+		}
+	}
+	return list, nil
+}
@@ -0,0 +1,70 @@
+package nostr
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecodeEvent(t *testing.T) {
+	value, err := DecodeEvent(&Event{Kind: KindSetMetadata, Content: `{"name":"bob"}`})
+	if meta, ok := value.(*ProfileMetadata); err != nil || !ok || meta.Name != "bob" {
+		t.Fatalf("unexpected metadata %v, %v", value, err)
+	}
+
+	value, err = DecodeEvent(&Event{Kind: KindRelayListMetadata, Tags: Tags{
+		{"r", "wss://both.example.com"},
+		{"r", "wss://read.example.com", "read"},
+		{"r", "wss://write.example.com", "write"},
+	}})
+	expected := &RelayList{
+		Read:  []string{"wss://both.example.com", "wss://read.example.com"},
+		Write: []string{"wss://both.example.com", "wss://write.example.com"},
+	}
+	if err != nil || !reflect.DeepEqual(value, expected) {
+		t.Fatalf("unexpected relay list %v, %v", value, err)
+	}
+
+	if _, err := DecodeEvent(&Event{Kind: 31337}); !errors.Is(err, ErrNoKindDecoder) {
+		t.Fatalf("expected ErrNoKindDecoder, got %v", err)
+	}
+	RegisterKindDecoder(31337, func(evt *Event) (any, error) { return evt.Content, nil })
+	defer RegisterKindDecoder(31337, nil)
+	if value, err := DecodeEvent(&Event{Kind: 31337, Content: "custom"}); err != nil || value != "custom" {
+		t.Fatalf("unexpected custom value %v, %v", value, err)
+	}
+}
+
+func TestWithDecodedEvents(t *testing.T) {
+	fr := &fakeRelay{}
+	sk, pk := makeKeyPair(t)
+	for _, evt := range []Event{
+		{Kind: KindSetMetadata, PubKey: pk, CreatedAt: time.Now(), Content: `{"name":"alice"}`},
+		{Kind: KindTextNote, PubKey: pk, CreatedAt: time.Now(), Content: "not decoded"},
+	} {
+		evt.Sign(sk)
+		fr.events = append(fr.events, evt)
+	}
+	ws := newWebsocketServer(fr.handle)
+	defer ws.Close()
+	relay := mustRelayConnect(ws.URL)
+	defer relay.Close()
+
+	var decoded []any
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events, sub, err := relay.SubscribeEOSE(ctx, Filters{{Authors: []PubKey{pk}}},
+		WithDecodedEvents(func(evt *Event, value any) { decoded = append(decoded, value) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsub()
+	if len(events) != 2 {
+		t.Fatalf("got %d events, expected 2", len(events))
+	}
+	if len(decoded) != 1 || decoded[0].(*ProfileMetadata).Name != "alice" {
+		t.Fatalf("unexpected decoded events %v", decoded)
+	}
+}
@@ -0,0 +1,339 @@
+// Package dm merges NIP-04 and NIP-17 direct messages into conversations, one per
+// counterparty, so messaging clients don't have to track both protocols themselves.
+package dm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip17"
+	"github.com/nbd-wtf/go-nostr/nip59"
+)
+
+// Protocol tells how a message was sent.
+type Protocol int
+
+const (
+	NIP04 Protocol = 4
+	NIP17 Protocol = 17
+)
+
+// Message is a decrypted direct message, either a kind-4 event or a kind-14 rumor.
+type Message struct {
+	ID        nostr.ID
+	Peer      nostr.PubKey // the other side of the conversation
+	Outgoing  bool         // true if we sent it
+	Content   string
+	CreatedAt time.Time
+	Protocol  Protocol
+	Event     nostr.Event
+}
+
+// Conversations keeps the direct messages of the owner of Signer, grouped by counterparty and
+// ordered by time. Call Start to load past messages and keep listening for new ones, which are
+// also sent to the Receive channel, and Close to stop.
+type Conversations struct {
+	Pool   *nostr.SimplePool
+	Signer nostr.Signer
+	Relays []string // where we look for messages and for the DM relays of our peers
+
+	me       nostr.PubKey
+	mu       sync.Mutex
+	byPeer   map[nostr.PubKey][]Message
+	seen     map[nostr.ID]struct{}
+	incoming chan Message
+	errors   chan error
+	closed   bool
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// errNotAMessage is returned by decrypt for events that are fine but aren't direct messages,
+// like gift wraps with other kinds of rumors.
+var errNotAMessage = errors.New("not a direct message")
+
+func New(ctx context.Context, pool *nostr.SimplePool, signer nostr.Signer, relays []string) (*Conversations, error) {
+	me, err := signer.GetPublicKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get our public key: %w", err)
+	}
+
+	return &Conversations{
+		Pool:     pool,
+		Signer:   signer,
+		Relays:   relays,
+		me:       me,
+		byPeer:   make(map[nostr.PubKey][]Message),
+		seen:     make(map[nostr.ID]struct{}),
+		incoming: make(chan Message, 64),
+		errors:   make(chan error, 16),
+	}, nil
+}
+
+// Receive returns the channel where every new message, sent or received, is delivered after
+// Start is called. It is closed when the ctx given to Start is canceled or Close is called.
+// Messages are dropped from the channel (but still stored) if nobody reads it.
+func (c *Conversations) Receive() <-chan Message {
+	return c.incoming
+}
+
+// Errors returns the channel where the errors of the messages that came after Start is called
+// but couldn't be read, e.g. because they didn't decrypt, are delivered. It is closed along with
+// Receive, and errors are dropped if nobody reads it.
+func (c *Conversations) Errors() <-chan error {
+	return c.errors
+}
+
+// Start subscribes to kind-4 events from and to us and to NIP-17 gift wraps sent to us
+// (on Relays and our kind-10050 DM relays), until ctx is canceled or Close is called. It
+// must only be called once.
+func (c *Conversations) Start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	c.mu.Lock()
+	c.cancel, c.done = cancel, done
+	c.mu.Unlock()
+
+	relays := append(append([]string{}, c.Relays...), nip17.GetDMRelays(ctx, c.Pool, c.me, c.Relays)...)
+	filters := nostr.Filters{
+		{Kinds: []int{nostr.KindEncryptedDirectMessage}, Authors: []nostr.PubKey{c.me}},
+		{Kinds: []int{nostr.KindEncryptedDirectMessage}, Tags: nostr.TagMap{"p": []string{c.me.Hex()}}},
+		{Kinds: []int{nip17.KindGiftWrap}, Tags: nostr.TagMap{"p": []string{c.me.Hex()}}},
+	}
+
+	go func() {
+		defer func() {
+			c.mu.Lock()
+			c.closed = true
+			close(c.incoming)
+			close(c.errors)
+			c.mu.Unlock()
+			close(done)
+		}()
+		for evt := range c.Pool.SubMany(ctx, relays, filters) {
+			msg, err := c.decrypt(ctx, evt)
+			if err == nil {
+				c.add(msg)
+			} else if err != errNotAMessage {
+				select {
+				case c.errors <- fmt.Errorf("message %s: %w", evt.ID, err):
+				default:
+				}
+			}
+		}
+	}()
+}
+
+// Close stops listening for new messages, closing the Receive and Errors channels once it is
+// done. Messages can still be sent afterwards.
+func (c *Conversations) Close() {
+	c.mu.Lock()
+	cancel, done := c.cancel, c.done
+	c.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// Peers returns everybody we have a conversation with, most recent conversations first.
+func (c *Conversations) Peers() []nostr.PubKey {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	peers := make([]nostr.PubKey, 0, len(c.byPeer))
+	for pk := range c.byPeer {
+		peers = append(peers, pk)
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		a, b := c.byPeer[peers[i]], c.byPeer[peers[j]]
+		return a[len(a)-1].CreatedAt.After(b[len(b)-1].CreatedAt)
+	})
+	return peers
+}
+
+// Messages returns the conversation with peer, oldest message first.
+func (c *Conversations) Messages(peer nostr.PubKey) []Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Message(nil), c.byPeer[peer]...)
+}
+
+// Send sends content to peer using NIP-17 if they have a DM relay list and NIP-04 otherwise.
+func (c *Conversations) Send(ctx context.Context, peer nostr.PubKey, content string) error {
+	if dmRelays := nip17.GetDMRelays(ctx, c.Pool, peer, c.Relays); len(dmRelays) > 0 {
+		return c.sendNIP17(ctx, peer, content)
+	}
+	return c.sendNIP04(ctx, peer, content)
+}
+
+func (c *Conversations) sendNIP17(ctx context.Context, peer nostr.PubKey, content string) error {
+	wraps, err := nip17.PrepareMessage(ctx, c.Signer, []nostr.PubKey{peer}, content, nil, nil)
+	if err != nil {
+		return err
+	}
+	if err := nip17.PublishWraps(ctx, c.Pool, c.Relays, wraps); err != nil {
+		return err
+	}
+	// our own copy may take a while to come back, so we show it right away
+	if rumor, err := nip59.GiftUnwrap(ctx, wraps[c.me], c.Signer); err == nil {
+		c.add(c.fromRumor(rumor))
+	}
+	return nil
+}
+
+func (c *Conversations) sendNIP04(ctx context.Context, peer nostr.PubKey, content string) error {
+	_, err := c.sendNIP04To(ctx, peer, content, c.Relays)
+	return err
+}
+
+// sendNIP04To publishes a kind-4 message to relays, returning the ones that accepted it.
+func (c *Conversations) sendNIP04To(ctx context.Context, peer nostr.PubKey, content string, relays []string) ([]string, error) {
+	ciphertext, err := c.Signer.NIP04Encrypt(ctx, content, peer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt: %w", err)
+	}
+	evt := nostr.Event{
+		Kind:      nostr.KindEncryptedDirectMessage,
+		Content:   ciphertext,
+		CreatedAt: nostr.Now(),
+		Tags:      nostr.Tags{{"p", peer.Hex()}},
+	}
+	if err := c.Signer.SignEvent(ctx, &evt); err != nil {
+		return nil, fmt.Errorf("failed to sign: %w", err)
+	}
+
+	accepted := c.publish(ctx, evt, relays)
+	if len(accepted) == 0 {
+		return nil, fmt.Errorf("message wasn't accepted by any relay")
+	}
+
+	c.add(Message{
+		ID:        evt.ID,
+		Peer:      peer,
+		Outgoing:  true,
+		Content:   content,
+		CreatedAt: evt.CreatedAt,
+		Protocol:  NIP04,
+		Event:     evt,
+	})
+	return accepted, nil
+}
+
+// publish sends evt to each of relays, returning the ones that accepted it.
+func (c *Conversations) publish(ctx context.Context, evt nostr.Event, relays []string) []string {
+	var accepted []string
+	for _, url := range relays {
+		relay, err := c.Pool.EnsureRelay(url)
+		if err != nil {
+			continue
+		}
+		if status, _ := relay.Publish(ctx, evt); status == nostr.PublishStatusSucceeded {
+			accepted = append(accepted, url)
+		}
+	}
+	return accepted
+}
+
+// decrypt reads the message in evt, a kind-4 event or a gift wrap.
+func (c *Conversations) decrypt(ctx context.Context, evt *nostr.Event) (Message, error) {
+	switch evt.Kind {
+	case nostr.KindEncryptedDirectMessage:
+		msg := Message{
+			ID:        evt.ID,
+			CreatedAt: evt.CreatedAt,
+			Protocol:  NIP04,
+			Event:     *evt,
+		}
+		if evt.PubKey == c.me {
+			tag := evt.Tags.GetFirst([]string{"p", ""})
+			if tag == nil {
+				return msg, fmt.Errorf("no recipient")
+			}
+			peer, err := nostr.PubKeyFromHex(tag.Value())
+			if err != nil {
+				return msg, fmt.Errorf("invalid recipient: %w", err)
+			}
+			msg.Peer = peer
+			msg.Outgoing = true
+		} else {
+			msg.Peer = evt.PubKey
+		}
+		plaintext, err := c.Signer.NIP04Decrypt(ctx, evt.Content, msg.Peer)
+		if err != nil {
+			return msg, fmt.Errorf("failed to decrypt: %w", err)
+		}
+		msg.Content = plaintext
+		return msg, nil
+
+	case nip17.KindGiftWrap:
+		rumor, err := nip59.GiftUnwrap(ctx, *evt, c.Signer)
+		if err != nil {
+			return Message{}, fmt.Errorf("failed to unwrap: %w", err)
+		}
+		if rumor.Kind != nip17.KindChatMessage {
+			return Message{}, errNotAMessage
+		}
+		msg := c.fromRumor(rumor)
+		if msg.Peer == (nostr.PubKey{}) {
+			return msg, errNotAMessage
+		}
+		return msg, nil
+	}
+	return Message{}, errNotAMessage
+}
+
+// fromRumor finds the peer of a kind-14 message. Group messages, with more than one
+// other participant, are filed under the first of them.
+func (c *Conversations) fromRumor(rumor nostr.Event) Message {
+	msg := Message{
+		ID:        rumor.ID,
+		Peer:      rumor.PubKey,
+		Content:   rumor.Content,
+		CreatedAt: rumor.CreatedAt,
+		Protocol:  NIP17,
+		Event:     rumor,
+	}
+	if rumor.PubKey == c.me {
+		msg.Outgoing = true
+		msg.Peer = nostr.PubKey{}
+		for _, tag := range rumor.Tags.GetAll([]string{"p", ""}) {
+			if pk, err := nostr.PubKeyFromHex(tag.Value()); err == nil && pk != c.me {
+				msg.Peer = pk
+				break
+			}
+		}
+	}
+	return msg
+}
+
+// add stores msg in its conversation, keeping it ordered, and announces it if it is new.
+func (c *Conversations) add(msg Message) {
+	c.mu.Lock()
+	if _, ok := c.seen[msg.ID]; ok {
+		c.mu.Unlock()
+		return
+	}
+	c.seen[msg.ID] = struct{}{}
+
+	conv := c.byPeer[msg.Peer]
+	i := sort.Search(len(conv), func(i int) bool { return conv[i].CreatedAt.After(msg.CreatedAt) })
+	conv = append(conv, Message{})
+	copy(conv[i+1:], conv[i:])
+	conv[i] = msg
+	c.byPeer[msg.Peer] = conv
+
+	if !c.closed {
+		select {
+		case c.incoming <- msg:
+		default:
+		}
+	}
+	c.mu.Unlock()
+}
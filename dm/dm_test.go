@@ -0,0 +1,209 @@
+package dm
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/keyer"
+	"github.com/nbd-wtf/go-nostr/nip17"
+	"github.com/nbd-wtf/go-nostr/server"
+)
+
+func TestConversationsMergeProtocols(t *testing.T) {
+	ctx := context.Background()
+	alice, _ := keyer.NewPlainKeySigner(nostr.GeneratePrivateKey())
+	bob, _ := keyer.NewPlainKeySigner(nostr.GeneratePrivateKey())
+	carol, _ := keyer.NewPlainKeySigner(nostr.GeneratePrivateKey())
+	alicePK, _ := alice.GetPublicKey(ctx)
+	bobPK, _ := bob.GetPublicKey(ctx)
+	carolPK, _ := carol.GetPublicKey(ctx)
+
+	c, err := New(ctx, nil, alice, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	nip04Message := func(from *keyer.KeySigner, to nostr.PubKey, content string, at time.Time) *nostr.Event {
+		ciphertext, _ := from.NIP04Encrypt(ctx, content, to)
+		evt := nostr.Event{
+			Kind:      nostr.KindEncryptedDirectMessage,
+			Content:   ciphertext,
+			CreatedAt: at,
+			Tags:      nostr.Tags{{"p", to.Hex()}},
+		}
+		from.SignEvent(ctx, &evt)
+		return &evt
+	}
+
+	// bob's wrap arrives first but is the newest message
+	wraps, _ := nip17.PrepareMessage(ctx, bob, []nostr.PubKey{alicePK}, "over nip17", nil, func(rumor *nostr.Event) {
+		rumor.CreatedAt = now
+	})
+	gw := wraps[alicePK]
+	for _, evt := range []*nostr.Event{
+		&gw,
+		nip04Message(bob, alicePK, "first", now.Add(-2*time.Minute)),
+		nip04Message(alice, bobPK, "reply", now.Add(-time.Minute)),
+		nip04Message(carol, alicePK, "older conversation", now.Add(-time.Hour)),
+	} {
+		msg, err := c.decrypt(ctx, evt)
+		if err != nil {
+			t.Fatalf("failed to decrypt kind %d: %s", evt.Kind, err)
+		}
+		c.add(msg)
+		c.add(msg) // duplicates are ignored
+	}
+
+	conv := c.Messages(bobPK)
+	if len(conv) != 3 {
+		t.Fatalf("expected 3 messages with bob, got %d", len(conv))
+	}
+	for i, expected := range []struct {
+		content  string
+		outgoing bool
+		protocol Protocol
+	}{
+		{"first", false, NIP04},
+		{"reply", true, NIP04},
+		{"over nip17", false, NIP17},
+	} {
+		if conv[i].Content != expected.content || conv[i].Outgoing != expected.outgoing || conv[i].Protocol != expected.protocol {
+			t.Fatalf("unexpected message %d: %+v", i, conv[i])
+		}
+	}
+
+	if peers := c.Peers(); len(peers) != 2 || peers[0] != bobPK || peers[1] != carolPK {
+		t.Fatalf("unexpected peers: %v", peers)
+	}
+	if len(c.Receive()) != 4 {
+		t.Fatalf("expected 4 messages on the channel, got %d", len(c.Receive()))
+	}
+}
+
+func TestConversationsClose(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ts := httptest.NewServer(server.New(nostr.NewMemoryStore()))
+	defer ts.Close()
+	url := "ws" + strings.TrimPrefix(ts.URL, "http")
+
+	alice, _ := keyer.NewPlainKeySigner(nostr.GeneratePrivateKey())
+	bob, _ := keyer.NewPlainKeySigner(nostr.GeneratePrivateKey())
+	alicePK, _ := alice.GetPublicKey(ctx)
+
+	// a message to alice she can't decrypt
+	evt := nostr.Event{
+		Kind:      nostr.KindEncryptedDirectMessage,
+		Content:   "not encrypted",
+		CreatedAt: nostr.Now(),
+		Tags:      nostr.Tags{{"p", alicePK.Hex()}},
+	}
+	bob.SignEvent(ctx, &evt)
+	relay, err := nostr.RelayConnect(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer relay.Close()
+	if _, err := relay.Publish(ctx, evt); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := New(ctx, nostr.NewSimplePool(ctx), alice, []string{url})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Start(ctx)
+	select {
+	case err := <-c.Errors():
+		if !strings.Contains(err.Error(), evt.ID.Hex()) {
+			t.Fatalf("unexpected error %s", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("no error for the unreadable message")
+	}
+
+	c.Close()
+	if _, ok := <-c.Receive(); ok {
+		t.Fatal("Receive still open after Close")
+	}
+	if _, ok := <-c.Errors(); ok {
+		t.Fatal("Errors still open after Close")
+	}
+	c.Close()
+}
+
+func TestSendToNIP05(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	newRelay := func() string {
+		ts := httptest.NewServer(server.New(nostr.NewMemoryStore()))
+		t.Cleanup(ts.Close)
+		return "ws" + strings.TrimPrefix(ts.URL, "http")
+	}
+	ours, bobsRelay := newRelay(), newRelay()
+
+	alice, _ := keyer.NewPlainKeySigner(nostr.GeneratePrivateKey())
+	bob, _ := keyer.NewPlainKeySigner(nostr.GeneratePrivateKey())
+	bobPK, _ := bob.GetPublicKey(ctx)
+	defer func(original func(context.Context, string) (*nostr.ProfilePointer, error)) { queryNIP05 = original }(queryNIP05)
+	queryNIP05 = func(ctx context.Context, identifier string) (*nostr.ProfilePointer, error) {
+		if identifier != "bob@example.com" {
+			return nil, fmt.Errorf("not found")
+		}
+		return &nostr.ProfilePointer{PublicKey: bobPK, Relays: []string{bobsRelay}}, nil
+	}
+	at := nostr.Now().Add(-time.Minute)
+	publish := func(kind int, content string, tags nostr.Tags) {
+		at = at.Add(time.Second) // so each replaces the previous one
+		evt := nostr.Event{Kind: kind, CreatedAt: at, Content: content, Tags: tags}
+		bob.SignEvent(ctx, &evt)
+		relay, _ := nostr.RelayConnect(ctx, bobsRelay)
+		defer relay.Close()
+		if _, err := relay.Publish(ctx, evt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pool := nostr.NewSimplePool(ctx)
+	c, err := New(ctx, pool, alice, []string{ours})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// bob's profile doesn't claim the identifier yet
+	publish(nostr.KindSetMetadata, `{"name":"bob"}`, nil)
+	if _, err := c.SendTo(ctx, "bob@example.com", "hi"); err == nil {
+		t.Fatal("unverified identifier accepted")
+	}
+
+	publish(nostr.KindSetMetadata, `{"name":"bob","nip05":"Bob@Example.com"}`, nil)
+	publish(nostr.KindRelayListMetadata, "", nostr.Tags{{"r", bobsRelay, "read"}})
+	accepted, err := c.SendTo(ctx, "bob@example.com", "over nip04")
+	if err != nil || len(accepted) != 2 || accepted[0] != bobsRelay {
+		t.Fatalf("unexpected nip04 delivery %v, %v", accepted, err)
+	}
+
+	// with a DM relay list bob gets a gift wrap there instead
+	publish(nip17.KindDMRelayList, "", nostr.Tags{{"relay", bobsRelay}})
+	accepted, err = c.SendTo(ctx, "bob@example.com", "over nip17")
+	if err != nil || len(accepted) != 1 || accepted[0] != bobsRelay {
+		t.Fatalf("unexpected nip17 delivery %v, %v", accepted, err)
+	}
+
+	// both were sent in the same second, so their order isn't known
+	conv := c.Messages(bobPK)
+	if len(conv) != 2 || conv[0].Protocol == conv[1].Protocol || !conv[0].Outgoing || !conv[1].Outgoing {
+		t.Fatalf("unexpected conversation %+v", conv)
+	}
+	if _, err := c.SendTo(ctx, "carol@example.com", "hi"); err == nil {
+		t.Error("unknown identifier accepted")
+	}
+}
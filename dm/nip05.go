@@ -0,0 +1,102 @@
+package dm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip17"
+	"github.com/nbd-wtf/go-nostr/nip59"
+)
+
+// queryNIP05 is replaced in tests.
+var queryNIP05 = nostr.QueryNIP05
+
+// SendTo sends content to the user with the NIP-05 identifier, like "bob@example.com". The
+// identifier is only trusted if their profile claims it back. The message goes over NIP-17 to
+// their DM relays if they have a list, otherwise over NIP-04 to their read relays and ours. It
+// returns the relays that accepted the message, or an error if none did.
+func (c *Conversations) SendTo(ctx context.Context, identifier string, content string) ([]string, error) {
+	peer, hints, err := c.resolve(ctx, identifier)
+	if err != nil {
+		return nil, err
+	}
+	if dmRelays := nip17.GetDMRelays(ctx, c.Pool, peer, appendUnique(append([]string{}, hints...), c.Relays...)); len(dmRelays) > 0 {
+		wraps, err := nip17.PrepareMessage(ctx, c.Signer, []nostr.PubKey{peer}, content, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		accepted := c.publish(ctx, wraps[peer], dmRelays)
+		if len(accepted) == 0 {
+			return nil, fmt.Errorf("none of the DM relays of %s accepted the message", identifier)
+		}
+		// our own copy, failing to keep it doesn't make the message undelivered
+		nip17.PublishWraps(ctx, c.Pool, c.Relays, map[nostr.PubKey]nostr.Event{c.me: wraps[c.me]})
+		if rumor, err := nip59.GiftUnwrap(ctx, wraps[c.me], c.Signer); err == nil {
+			c.add(c.fromRumor(rumor))
+		}
+		return accepted, nil
+	}
+
+	relays := hints
+	if relayList := c.client().FetchLatest(ctx, peer, nostr.KindRelayListMetadata, hints...); relayList != nil {
+		if list, err := nostr.ParseRelayList(relayList); err == nil {
+			relays = appendUnique(list.Read, hints...)
+		}
+	}
+	return c.sendNIP04To(ctx, peer, content, appendUnique(relays, c.Relays...))
+}
+
+// resolve finds the public key behind identifier and checks their profile has it, returning
+// the relays in the nostr.json as hints.
+func (c *Conversations) resolve(ctx context.Context, identifier string) (nostr.PubKey, []string, error) {
+	identifier = normalizeNIP05(identifier)
+	ptr, err := queryNIP05(ctx, identifier)
+	if err != nil {
+		return nostr.PubKey{}, nil, err
+	}
+	pk := ptr.PublicKey
+	var hints []string
+	for _, url := range ptr.Relays {
+		if url = nostr.NormalizeURL(url); url != "" {
+			hints = appendUnique(hints, url)
+		}
+	}
+
+	evt := c.client().FetchLatest(ctx, pk, nostr.KindSetMetadata, hints...)
+	if evt == nil {
+		return pk, nil, fmt.Errorf("no profile found for %s", identifier)
+	}
+	meta, err := nostr.ParseMetadata(*evt)
+	if err != nil {
+		return pk, nil, err
+	}
+	if normalizeNIP05(meta.NIP05) != identifier {
+		return pk, nil, fmt.Errorf("profile of %s doesn't claim %s", pk.Hex(), identifier)
+	}
+	return pk, hints, nil
+}
+
+// client is a nostr.Client on our pool and relays, for its lookups.
+func (c *Conversations) client() *nostr.Client {
+	return &nostr.Client{Pool: c.Pool, Signer: c.Signer, Relays: c.Relays}
+}
+
+// normalizeNIP05 lowercases identifier and writes "_@domain" as just "domain".
+func normalizeNIP05(identifier string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(identifier)), "_@")
+}
+
+func appendUnique(urls []string, more ...string) []string {
+next:
+	for _, url := range more {
+		for _, existing := range urls {
+			if existing == url {
+				continue next
+			}
+		}
+		urls = append(urls, url)
+	}
+	return urls
+}
@@ -3,7 +3,9 @@ package nostr
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/btcsuite/btcd/btcec/v2"
@@ -11,16 +13,17 @@ import (
 )
 
 type Event struct {
-	ID        string
-	PubKey    string
+	ID        ID
+	PubKey    PubKey
 	CreatedAt time.Time
 	Kind      int
 	Tags      Tags
 	Content   string
 	Sig       string
 
-	// anything here will be mashed together with the main event object when serializing
-	extra map[string]any
+	// Extra has the top-level fields that aren't in NIP-01, as they were received, they are
+	// written back when serializing. See SetExtra and GetExtra.
+	Extra map[string]json.RawMessage
 }
 
 const (
@@ -37,14 +40,39 @@ const (
 	KindChannelMessage         int = 42
 	KindChannelHideMessage     int = 43
 	KindChannelMuteUser        int = 44
+	KindReporting              int = 1984
 	KindZapRequest             int = 9734
 	KindZap                    int = 9735
+	KindRelayListMetadata      int = 10002
+	KindClientAuthentication   int = 22242
+	KindNostrConnect           int = 24133
 )
 
-// GetID serializes and returns the event ID as a string
-func (evt *Event) GetID() string {
-	h := sha256.Sum256(evt.Serialize())
-	return hex.EncodeToString(h[:])
+// IDHex returns the ID of the event as hex.
+//
+// Deprecated: use evt.ID.Hex(), this is here for code written when the ID was a string.
+func (evt *Event) IDHex() string { return evt.ID.Hex() }
+
+// PubKeyHex returns the public key of the event as hex.
+//
+// Deprecated: use evt.PubKey.Hex(), this is here for code written when the PubKey was a string.
+func (evt *Event) PubKeyHex() string { return evt.PubKey.Hex() }
+
+// SetPubKeyHex sets the public key of the event from hex.
+//
+// Deprecated: use PubKeyFromHex, this is here for code written when the PubKey was a string.
+func (evt *Event) SetPubKeyHex(pkHex string) error {
+	pk, err := PubKeyFromHex(pkHex)
+	if err != nil {
+		return err
+	}
+	evt.PubKey = pk
+	return nil
+}
+
+// GetID serializes the event and returns its ID
+func (evt *Event) GetID() ID {
+	return sha256.Sum256(evt.Serialize())
 }
 
 // Serialize outputs a byte array that can be hashed/signed to identify/authenticate.
@@ -52,17 +80,17 @@ func (evt *Event) GetID() string {
 func (evt *Event) Serialize() []byte {
 	// the serialization process is just putting everything into a JSON array
 	// so the order is kept. See NIP-01
-	dst := make([]byte, 0)
+	dst := make([]byte, 0, 128+evt.Tags.sizeHint()+len(evt.Content))
 
 	// the header portion is easy to serialize
 	// [0,"pubkey",created_at,kind,[
-	dst = append(dst, []byte(
-		fmt.Sprintf(
-			"[0,\"%s\",%d,%d,",
-			evt.PubKey,
-			evt.CreatedAt.Unix(),
-			evt.Kind,
-		))...)
+	dst = append(dst, `[0,"`...)
+	dst = appendHex(dst, evt.PubKey[:])
+	dst = append(dst, `",`...)
+	dst = strconv.AppendInt(dst, evt.CreatedAt.Unix(), 10)
+	dst = append(dst, ',')
+	dst = strconv.AppendInt(dst, int64(evt.Kind), 10)
+	dst = append(dst, ',')
 
 	// tags
 	dst = evt.Tags.marshalTo(dst)
@@ -79,22 +107,31 @@ func (evt *Event) Serialize() []byte {
 // (which is a hash of the serialized event content).
 // returns an error if the signature itself is invalid.
 func (evt Event) CheckSignature() (bool, error) {
-	// read and check pubkey
-	pk, err := hex.DecodeString(evt.PubKey)
+	// read signature
+	s, err := hex.DecodeString(evt.Sig)
 	if err != nil {
-		return false, fmt.Errorf("event pubkey '%s' is invalid hex: %w", evt.PubKey, err)
+		return false, fmt.Errorf("signature '%s' is invalid hex: %w", evt.Sig, err)
 	}
 
-	pubkey, err := schnorr.ParsePubKey(pk)
-	if err != nil {
-		return false, fmt.Errorf("event has invalid pubkey '%s': %w", evt.PubKey, err)
+	if VerificationBackend == BackendLibsecp256k1 && libsecp256k1Available {
+		if len(s) != 64 {
+			return false, fmt.Errorf("signature should have 64 bytes, has %d", len(s))
+		}
+		var sig [64]byte
+		copy(sig[:], s)
+		ok, err := verifyLibsecp256k1(evt.PubKey, sha256.Sum256(evt.Serialize()), sig)
+		if err != nil {
+			return false, fmt.Errorf("event has invalid pubkey '%s': %w", evt.PubKey, err)
+		}
+		return ok, nil
 	}
 
-	// read signature
-	s, err := hex.DecodeString(evt.Sig)
+	// read and check pubkey
+	pubkey, err := parsePubKey(evt.PubKey)
 	if err != nil {
-		return false, fmt.Errorf("signature '%s' is invalid hex: %w", evt.Sig, err)
+		return false, err
 	}
+
 	sig, err := schnorr.ParseSignature(s)
 	if err != nil {
 		return false, fmt.Errorf("failed to parse signature: %w", err)
@@ -105,6 +142,24 @@ func (evt Event) CheckSignature() (bool, error) {
 	return sig.Verify(hash[:], pubkey), nil
 }
 
+// pubkeyCache holds recently parsed pubkeys, shared by everything that verifies events,
+// since most feeds are dominated by a small set of authors.
+var pubkeyCache = newLRU[PubKey, *btcec.PublicKey](4096)
+
+func parsePubKey(pk PubKey) (*btcec.PublicKey, error) {
+	if pubkey, ok := pubkeyCache.Get(pk); ok {
+		return pubkey, nil
+	}
+
+	pubkey, err := schnorr.ParsePubKey(pk[:])
+	if err != nil {
+		return nil, fmt.Errorf("event has invalid pubkey '%s': %w", pk, err)
+	}
+
+	pubkeyCache.Add(pk, pubkey)
+	return pubkey, nil
+}
+
 // Sign signs an event with a given privateKey
 func (evt *Event) Sign(privateKey string) error {
 	h := sha256.Sum256(evt.Serialize())
@@ -115,12 +170,19 @@ func (evt *Event) Sign(privateKey string) error {
 	}
 	sk, _ := btcec.PrivKeyFromBytes(s)
 
-	sig, err := schnorr.Sign(sk, h[:])
+	var opts []schnorr.SignOption
+	var aux [32]byte
+	if ok, err := readSigningRandomness(&aux); err != nil {
+		return fmt.Errorf("failed to read signing randomness: %w", err)
+	} else if ok {
+		opts = append(opts, schnorr.CustomNonce(aux))
+	}
+	sig, err := schnorr.Sign(sk, h[:], opts...)
 	if err != nil {
 		return err
 	}
 
-	evt.ID = hex.EncodeToString(h[:])
+	evt.ID = h
 	evt.Sig = hex.EncodeToString(sig.Serialize())
 	return nil
 }
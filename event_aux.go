@@ -1,15 +1,22 @@
 package nostr
 
 import (
-	"bytes"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"github.com/valyala/fastjson"
+	"sort"
+	"strconv"
 	"time"
+
+	"github.com/valyala/fastjson"
 )
 
+// parsers are reused, everything we keep from what they parse is copied out
+var fastjsonParsers fastjson.ParserPool
+
 func (evt *Event) UnmarshalJSON(payload []byte) error {
-	var fastjsonParser fastjson.Parser
+	fastjsonParser := fastjsonParsers.Get()
+	defer fastjsonParsers.Put(fastjsonParser)
 	parsed, err := fastjsonParser.ParseBytes(payload)
 	if err != nil {
 		return fmt.Errorf("failed to parse event: %w", err)
@@ -20,25 +27,20 @@ func (evt *Event) UnmarshalJSON(payload []byte) error {
 		return fmt.Errorf("event is not an object")
 	}
 
-	// prepare this to receive any extra property that may serialized along with the event
-	evt.extra = make(map[string]any)
+	evt.Extra = nil
 
 	var visiterr error
 	obj.Visit(func(k []byte, v *fastjson.Value) {
 		key := string(k)
 		switch key {
 		case "id":
-			id, err := v.StringBytes()
-			if err != nil {
+			if err := fastjsonHex32(v, evt.ID[:]); err != nil {
 				visiterr = fmt.Errorf("invalid 'id' field: %w", err)
 			}
-			evt.ID = string(id)
 		case "pubkey":
-			id, err := v.StringBytes()
-			if err != nil {
+			if err := fastjsonHex32(v, evt.PubKey[:]); err != nil {
 				visiterr = fmt.Errorf("invalid 'pubkey' field: %w", err)
 			}
-			evt.PubKey = string(id)
 		case "created_at":
 			val, err := v.Int64()
 			if err != nil {
@@ -69,14 +71,31 @@ func (evt *Event) UnmarshalJSON(payload []byte) error {
 			}
 			evt.Sig = string(id)
 		default:
-			var anyValue any
-			json.Unmarshal(v.MarshalTo([]byte{}), &anyValue)
-			evt.extra[key] = anyValue
+			// any extra property that may be serialized along with the event, kept as it is
+			if evt.Extra == nil {
+				evt.Extra = make(map[string]json.RawMessage)
+			}
+			evt.Extra[key] = v.MarshalTo(nil)
 		}
 	})
 	return visiterr
 }
 
+// unmarshaling helper, an empty string is taken as all zeroes
+func fastjsonHex32(v *fastjson.Value, dst []byte) error {
+	sb, err := v.StringBytes()
+	if err != nil {
+		return err
+	}
+	if len(sb) == 0 {
+		for i := range dst {
+			dst[i] = 0
+		}
+		return nil
+	}
+	return decodeHex32(dst, string(sb))
+}
+
 // unmarshaling helper
 func fastjsonArrayToTags(v *fastjson.Value) (Tags, error) {
 	arr, err := v.Array()
@@ -107,35 +126,55 @@ func fastjsonArrayToTags(v *fastjson.Value) (Tags, error) {
 
 // MarshalJSON() returns the JSON byte encoding of the event, as in NIP-01.
 func (evt Event) MarshalJSON() ([]byte, error) {
-	dst := make([]byte, 0)
-	dst = append(dst, '{')
-	dst = append(dst, []byte(fmt.Sprintf("\"id\":\"%s\",\"pubkey\":\"%s\",\"created_at\":%d,\"kind\":%d,\"tags\":",
-		evt.ID,
-		evt.PubKey,
-		evt.CreatedAt.Unix(),
-		evt.Kind,
-	))...)
+	return evt.marshalJSON(true), nil
+}
+
+// MarshalUnsignedJSON is like MarshalJSON, but leaves the "sig" field out, as unsigned events
+// like NIP-59 rumors must not carry one at all.
+func (evt Event) MarshalUnsignedJSON() ([]byte, error) {
+	return evt.marshalJSON(false), nil
+}
+
+func (evt Event) marshalJSON(withSig bool) []byte {
+	dst := make([]byte, 0, 256+evt.Tags.sizeHint()+len(evt.Content)+len(evt.Sig))
+	dst = append(dst, `{"id":"`...)
+	dst = appendHex(dst, evt.ID[:])
+	dst = append(dst, `","pubkey":"`...)
+	dst = appendHex(dst, evt.PubKey[:])
+	dst = append(dst, `","created_at":`...)
+	dst = strconv.AppendInt(dst, evt.CreatedAt.Unix(), 10)
+	dst = append(dst, `,"kind":`...)
+	dst = strconv.AppendInt(dst, int64(evt.Kind), 10)
+	dst = append(dst, `,"tags":`...)
 	dst = evt.Tags.marshalTo(dst)
-	dst = append(dst, []byte(",\"content\":")...)
+	dst = append(dst, `,"content":`...)
 	dst = escapeString(dst, evt.Content)
-	dst = append(dst, []byte(fmt.Sprintf(",\"sig\":\"%s\"",
-		evt.Sig,
-	))...)
-	// slower marshaling of "any" interface type
-	if evt.extra != nil {
-		buf := bytes.NewBuffer(nil)
-		enc := json.NewEncoder(buf)
-		enc.SetEscapeHTML(false)
-		for k, v := range evt.extra {
-			if e := enc.Encode(v); e == nil {
-				dst = append(dst, ',')
-				dst = escapeString(dst, k)
-				dst = append(dst, ':')
-				dst = append(dst, buf.Bytes()[:buf.Len()-1]...)
-			}
-			buf.Reset()
+	if withSig {
+		dst = append(dst, `,"sig":`...)
+		dst = escapeString(dst, evt.Sig)
+	}
+	// extra fields in a stable order, so the same event always serializes the same way
+	if len(evt.Extra) > 0 {
+		keys := make([]string, 0, len(evt.Extra))
+		for k := range evt.Extra {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			dst = append(dst, ',')
+			dst = escapeString(dst, k)
+			dst = append(dst, ':')
+			dst = append(dst, evt.Extra[k]...)
 		}
 	}
 	dst = append(dst, '}')
-	return dst, nil
+	return dst
+}
+
+// appendHex appends the lowercase hex encoding of src to dst.
+func appendHex(dst []byte, src []byte) []byte {
+	n := len(dst)
+	dst = append(dst, make([]byte, hex.EncodedLen(len(src)))...)
+	hex.Encode(dst[n:], src)
+	return dst
 }
@@ -1,64 +1,59 @@
 package nostr
 
-// SetExtra sets an out-of-the-spec value under the given key into the event object.
+import "encoding/json"
+
+// SetExtra sets an out-of-the-spec value under the given key into the event object. The value
+// is encoded as JSON right away, it is not set if that fails.
 func (evt *Event) SetExtra(key string, value any) {
-	if evt.extra == nil {
-		evt.extra = make(map[string]any)
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	if evt.Extra == nil {
+		evt.Extra = make(map[string]json.RawMessage)
 	}
-	evt.extra[key] = value
+	evt.Extra[key] = raw
 }
 
 // GetExtra tries to get a value under the given key that may be present in the event object
-// but is hidden in the basic type since it is out of the spec.
+// but is hidden in the basic type since it is out of the spec. It is decoded as encoding/json
+// would into an any, so numbers are float64.
 func (evt Event) GetExtra(key string) any {
-	ival, _ := evt.extra[key]
-	return ival
+	var val any
+	evt.getExtra(key, &val)
+	return val
 }
 
 // GetExtraString is like [Event.GetExtra], but only works if the value is a string,
 // otherwise returns the zero-value.
 func (evt Event) GetExtraString(key string) string {
-	ival, ok := evt.extra[key]
-	if !ok {
-		return ""
-	}
-	val, ok := ival.(string)
-	if !ok {
-		return ""
-	}
+	var val string
+	evt.getExtra(key, &val)
 	return val
 }
 
-// GetExtraNumber is like [Event.GetExtra], but only works if the value is a float64,
+// GetExtraNumber is like [Event.GetExtra], but only works if the value is a number,
 // otherwise returns the zero-value.
 func (evt Event) GetExtraNumber(key string) float64 {
-	ival, ok := evt.extra[key]
-	if !ok {
-		return 0
-	}
-
-	switch val := ival.(type) {
-	case float64:
-		return val
-	case int:
-		return float64(val)
-	case int64:
-		return float64(val)
-	}
-
-	return 0
+	var val float64
+	evt.getExtra(key, &val)
+	return val
 }
 
 // GetExtraBoolean is like [Event.GetExtra], but only works if the value is a boolean,
 // otherwise returns the zero-value.
 func (evt Event) GetExtraBoolean(key string) bool {
-	ival, ok := evt.extra[key]
-	if !ok {
-		return false
-	}
-	val, ok := ival.(bool)
+	var val bool
+	evt.getExtra(key, &val)
+	return val
+}
+
+// getExtra decodes the value under key into dst, leaving it untouched if it's missing or of
+// another type.
+func (evt Event) getExtra(key string, dst any) {
+	raw, ok := evt.Extra[key]
 	if !ok {
-		return false
+		return
 	}
-	return val
+	json.Unmarshal(raw, dst)
 }
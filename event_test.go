@@ -2,6 +2,7 @@ package nostr
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
 	"time"
 )
@@ -44,8 +45,8 @@ func TestEventParsingAndVerifying(t *testing.T) {
 func TestEventSerialization(t *testing.T) {
 	events := []Event{
 		{
-			ID:        "92570b321da503eac8014b23447301eb3d0bbdfbace0d11a4e4072e72bb7205d",
-			PubKey:    "e9142f724955c5854de36324dab0434f97b15ec6b33464d56ebe491e3f559d1b",
+			ID:        MustIDFromHex("92570b321da503eac8014b23447301eb3d0bbdfbace0d11a4e4072e72bb7205d"),
+			PubKey:    MustPubKeyFromHex("e9142f724955c5854de36324dab0434f97b15ec6b33464d56ebe491e3f559d1b"),
 			Kind:      4,
 			CreatedAt: time.Unix(1671028682, 0),
 			Tags:      Tags{Tag{"p", "f8340b2bde651576b75af61aa26c80e13c65029f00f7f64004eece679bf7059f"}},
@@ -88,10 +89,22 @@ func TestEventSerialization(t *testing.T) {
 	}
 }
 
+func TestEventSerializationWithoutSignature(t *testing.T) {
+	evt := Event{Kind: 14, CreatedAt: time.Unix(1671028682, 0), Content: "rumor"}
+	signed, _ := evt.MarshalJSON()
+	if !strings.Contains(string(signed), `"sig":""`) {
+		t.Errorf("empty signature left out: %s", signed)
+	}
+	unsigned, _ := evt.MarshalUnsignedJSON()
+	if strings.Contains(string(unsigned), `"sig"`) || !strings.HasSuffix(string(unsigned), `"content":"rumor"}`) {
+		t.Errorf("unexpected unsigned event: %s", unsigned)
+	}
+}
+
 func TestEventSerializationWithExtraFields(t *testing.T) {
 	evt := Event{
-		ID:        "92570b321da503eac8014b23447301eb3d0bbdfbace0d11a4e4072e72bb7205d",
-		PubKey:    "e9142f724955c5854de36324dab0434f97b15ec6b33464d56ebe491e3f559d1b",
+		ID:        MustIDFromHex("92570b321da503eac8014b23447301eb3d0bbdfbace0d11a4e4072e72bb7205d"),
+		PubKey:    MustPubKeyFromHex("e9142f724955c5854de36324dab0434f97b15ec6b33464d56ebe491e3f559d1b"),
 		Kind:      7,
 		CreatedAt: time.Unix(1671028682, 0),
 		Content:   "there is an extra field here",
@@ -124,7 +137,7 @@ func TestEventSerializationWithExtraFields(t *testing.T) {
 		t.Errorf("failed to parse extra string")
 	}
 
-	if float64(evt.GetExtra("elet").(int)) != evt.GetExtraNumber("elet") || evt.GetExtraNumber("elet") != 77 {
+	if evt.GetExtra("elet").(float64) != evt.GetExtraNumber("elet") || evt.GetExtraNumber("elet") != 77 {
 		t.Logf("number: %v == %v", evt.GetExtra("elet"), evt.GetExtraNumber("elet"))
 		t.Errorf("failed to parse extra number")
 	}
@@ -138,9 +151,67 @@ func TestEventSerializationWithExtraFields(t *testing.T) {
 	}
 }
 
+func TestEventPreservesUnknownFields(t *testing.T) {
+	payload := `{"id":"92570b321da503eac8014b23447301eb3d0bbdfbace0d11a4e4072e72bb7205d","pubkey":"e9142f724955c5854de36324dab0434f97b15ec6b33464d56ebe491e3f559d1b","created_at":1671028682,"kind":7,"tags":[],"content":"","sig":"ed08d2dd5b0f7b6a3cdc74643d4adee3158ddede9cc848e8cd97630c097001acc2d052d2d3ec2b7ac4708b2314b797106d1b3c107322e61b5e5cc2116e099b79","big":12345678901234567890,"meta":{"z":1,"a":[true,null]},"seen_on":"wss://x.com"}`
+
+	var evt Event
+	if err := json.Unmarshal([]byte(payload), &evt); err != nil {
+		t.Fatalf("failed to parse event: %v", err)
+	}
+	if len(evt.Extra) != 3 {
+		t.Fatalf("expected 3 extra fields, got %v", evt.Extra)
+	}
+	if evt.GetExtraString("seen_on") != "wss://x.com" {
+		t.Errorf("wrong extra string %q", evt.GetExtraString("seen_on"))
+	}
+	if evt.GetExtraString("big") != "" || evt.GetExtraBoolean("meta") {
+		t.Errorf("accessors should give zero values for other types")
+	}
+
+	b, err := json.Marshal(evt)
+	if err != nil {
+		t.Fatalf("failed to serialize event: %v", err)
+	}
+	if string(b) != payload {
+		t.Errorf("extra fields changed on a round trip:\n%s\n%s", payload, b)
+	}
+}
+
 func mustSignEvent(t *testing.T, privkey string, event *Event) {
 	t.Helper()
 	if err := event.Sign(privkey); err != nil {
 		t.Fatalf("event.Sign: %v", err)
 	}
 }
+
+const benchmarkEvent = `{"id":"9e662bdd7d8abc40b5b15ee1ff5e9320efc87e9274d8d440c58e6eed2dddfbe2","pubkey":"373ebe3d45ec91977296a178d9f19f326c70631d2a1b0bbba5c5ecc2eb53b9e7","created_at":1644844224,"kind":3,"tags":[["p","3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"],["p","75fc5ac2487363293bd27fb0d14fb966477d0f1dbc6361d37806a6a740eda91e"],["p","46d0dfd3a724a302ca9175163bdf788f3606b3fd1bb12d5fe055d1e418cb60ea"]],"content":"{\"wss://nostr-pub.wellorder.net\":{\"read\":true,\"write\":true},\"wss://nostr.bitcoiner.social\":{\"read\":false,\"write\":true}}","sig":"811355d3484d375df47581cb5d66bed05002c2978894098304f20b595e571b7e01b2efd906c5650080ffe49cf1c62b36715698e9d88b9e8be43029a2f3fa66be"}`
+
+func BenchmarkEventUnmarshal(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var evt Event
+		if err := evt.UnmarshalJSON([]byte(benchmarkEvent)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEventMarshal(b *testing.B) {
+	var evt Event
+	evt.UnmarshalJSON([]byte(benchmarkEvent))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		evt.MarshalJSON()
+	}
+}
+
+func BenchmarkEventSerialize(b *testing.B) {
+	var evt Event
+	evt.UnmarshalJSON([]byte(benchmarkEvent))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		evt.Serialize()
+	}
+}
@@ -52,7 +52,10 @@ func main() {
 
 	// create a subscription and submit to relay
 	// results will be returned on the sub.Events channel
-	sub := relay.Subscribe(ctx, filters)
+	sub, err := relay.Sub(ctx, filters)
+	if err != nil {
+		panic(err)
+	}
 
 	// we will append the returned events to this slice
 	evs := make([]nostr.Event, 0)
@@ -93,7 +96,7 @@ func main() {
 		sk = nostr.GeneratePrivateKey()
 	}
 	if pub, e := nostr.GetPublicKey(sk); e == nil {
-		ev.PubKey = pub
+		ev.PubKey, _ = nostr.PubKeyFromHex(pub)
 		if npub, e := nip19.EncodePublicKey(pub); e == nil {
 			fmt.Fprintln(os.Stderr, "using:", npub)
 		}
@@ -123,6 +126,7 @@ func main() {
 			fmt.Println(e)
 			continue
 		}
-		fmt.Println("posting to: ", url, relay.Publish(ctx, ev))
+		status, err := relay.Publish(ctx, ev)
+		fmt.Println("posting to: ", url, status, err)
 	}
 }
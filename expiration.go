@@ -0,0 +1,61 @@
+package nostr
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// ErrExpired is returned by stores asked to save an event whose NIP-40 expiration has passed.
+var ErrExpired = errors.New("event has expired")
+
+// Expiration returns the time set in the NIP-40 "expiration" tag, if any.
+func (evt *Event) Expiration() (time.Time, bool) {
+	tag := evt.Tags.GetFirst([]string{"expiration", ""})
+	if tag == nil {
+		return time.Time{}, false
+	}
+	ts, err := strconv.ParseInt(tag.Value(), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(ts, 0), true
+}
+
+// IsExpired tells if the event has an expiration that is not after now.
+func (evt *Event) IsExpired(now time.Time) bool {
+	expiration, ok := evt.Expiration()
+	return ok && !expiration.After(now)
+}
+
+// ExpiringStore is a Store that can delete the events that expired after being saved.
+type ExpiringStore interface {
+	Store
+
+	// PurgeExpired deletes the events that are expired at now and returns them.
+	PurgeExpired(ctx context.Context, now time.Time) ([]*Event, error)
+}
+
+// PurgeExpiredPeriodically calls store.PurgeExpired every interval until ctx is canceled,
+// passing each removed event to onRemove, which can be nil.
+func PurgeExpiredPeriodically(ctx context.Context, store ExpiringStore, interval time.Duration, onRemove func(*Event)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				removed, err := store.PurgeExpired(ctx, Now())
+				if err != nil || onRemove == nil {
+					continue
+				}
+				for _, evt := range removed {
+					onRemove(evt)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,50 @@
+package nostr
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestExpiration(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	expiresAt := func(d time.Duration) Tags {
+		return Tags{{"expiration", strconv.FormatInt(time.Now().Add(d).Unix(), 10)}}
+	}
+
+	store := NewMemoryStore()
+	if err := store.SaveEvent(ctx, &Event{ID: ID{1}, Kind: 1, Tags: expiresAt(-time.Minute)}); !errors.Is(err, ErrExpired) {
+		t.Fatalf("expected ErrExpired, got %v", err)
+	}
+	store.SaveEvent(ctx, &Event{ID: ID{2}, Kind: 1, Tags: expiresAt(time.Hour)})
+	store.SaveEvent(ctx, &Event{ID: ID{3}, Kind: 1, Tags: expiresAt(2 * time.Second)})
+	store.SaveEvent(ctx, &Event{ID: ID{4}, Kind: 1})
+
+	if results, _ := store.QueryEvents(ctx, Filter{}); len(results) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(results))
+	}
+
+	removed, _ := store.PurgeExpired(ctx, time.Now().Add(time.Minute))
+	if len(removed) != 1 || removed[0].ID != (ID{3}) {
+		t.Fatalf("wrong events purged: %v", removed)
+	}
+	if results, _ := store.QueryEvents(ctx, Filter{}); len(results) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(results))
+	}
+
+	store.SaveEvent(ctx, &Event{ID: ID{5}, Kind: 1, Tags: expiresAt(2 * time.Second)})
+	removals := make(chan *Event, 1)
+	PurgeExpiredPeriodically(ctx, store, 100*time.Millisecond, func(evt *Event) { removals <- evt })
+	select {
+	case evt := <-removals:
+		if evt.ID != (ID{5}) {
+			t.Fatalf("wrong event removed: %v", evt)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expired event wasn't purged")
+	}
+}
@@ -1,7 +1,10 @@
 package nostr
 
 import (
+	"encoding/hex"
 	"encoding/json"
+	"sort"
+	"strings"
 	"time"
 
 	"golang.org/x/exp/slices"
@@ -10,14 +13,20 @@ import (
 type Filters []Filter
 
 type Filter struct {
-	IDs     []string
+	IDs     []ID
 	Kinds   []int
-	Authors []string
+	Authors []PubKey
 	Tags    TagMap
 	Since   *time.Time
 	Until   *time.Time
 	Limit   int
 	Search  string
+
+	// IDPrefixes and AuthorPrefixes are hex prefixes of ids and authors, as filters could have
+	// before NIP-01 required full ones. They are sent in "ids" and "authors" along with IDs and
+	// Authors, and events match if they are in either.
+	IDPrefixes     []string
+	AuthorPrefixes []string
 }
 
 type TagMap map[string][]string
@@ -36,6 +45,42 @@ func (eff Filters) Match(event *Event) bool {
 	return false
 }
 
+// ApplyLimits keeps of events, as a relay would, only the newest Limit matching each filter that
+// has one, along with the ones matching filters without a limit. Events from many relays can be
+// put together with it to get what filters ask for. They are returned newest first, unless no
+// filter has a limit, in which case events is returned as it is.
+func (eff Filters) ApplyLimits(events []*Event) []*Event {
+	limited := false
+	for _, filter := range eff {
+		limited = limited || filter.Limit > 0
+	}
+	if !limited {
+		return events
+	}
+
+	sorted := append([]*Event(nil), events...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].CreatedAt.After(sorted[j].CreatedAt) })
+
+	counts := make([]int, len(eff))
+	kept := sorted[:0]
+	for _, evt := range sorted {
+		keep := false
+		for i, filter := range eff {
+			if !filter.Matches(evt) {
+				continue
+			}
+			if filter.Limit <= 0 || counts[i] < filter.Limit {
+				keep = true
+			}
+			counts[i]++
+		}
+		if keep {
+			kept = append(kept, evt)
+		}
+	}
+	return kept
+}
+
 func (ef Filter) String() string {
 	j, _ := json.Marshal(ef)
 	return string(j)
@@ -46,7 +91,7 @@ func (ef Filter) Matches(event *Event) bool {
 		return false
 	}
 
-	if ef.IDs != nil && !containsPrefixOf(ef.IDs, event.ID) {
+	if !matchesHex32(ef.IDs, ef.IDPrefixes, event.ID) {
 		return false
 	}
 
@@ -54,7 +99,7 @@ func (ef Filter) Matches(event *Event) bool {
 		return false
 	}
 
-	if ef.Authors != nil && !containsPrefixOf(ef.Authors, event.PubKey) {
+	if !matchesHex32(ef.Authors, ef.AuthorPrefixes, event.PubKey) {
 		return false
 	}
 
@@ -75,6 +120,30 @@ func (ef Filter) Matches(event *Event) bool {
 	return true
 }
 
+// matchesHex32 tells if value is one of items or starts with one of prefixes, or if neither
+// restricts it.
+func matchesHex32[T ID | PubKey](items []T, prefixes []string, value T) bool {
+	if items == nil && prefixes == nil {
+		return true
+	}
+	return slices.Contains(items, value) || hasHexPrefix(value[:], prefixes)
+}
+
+// hasHexPrefix tells if the hex of value starts with one of prefixes.
+func hasHexPrefix(value []byte, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return false
+	}
+	valueHex := hex.EncodeToString(value)
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(valueHex, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterEqual tells if a and b ask for the same events, regardless of the order of their items.
 func FilterEqual(a Filter, b Filter) bool {
 	if !similar(a.Kinds, b.Kinds) {
 		return false
@@ -88,6 +157,10 @@ func FilterEqual(a Filter, b Filter) bool {
 		return false
 	}
 
+	if !similar(a.IDPrefixes, b.IDPrefixes) || !similar(a.AuthorPrefixes, b.AuthorPrefixes) {
+		return false
+	}
+
 	if len(a.Tags) != len(b.Tags) {
 		return false
 	}
@@ -102,11 +175,15 @@ func FilterEqual(a Filter, b Filter) bool {
 		}
 	}
 
-	if a.Since != b.Since {
+	if !timeEqual(a.Since, b.Since) {
 		return false
 	}
 
-	if a.Until != b.Until {
+	if !timeEqual(a.Until, b.Until) {
+		return false
+	}
+
+	if a.Limit != b.Limit {
 		return false
 	}
 
@@ -116,3 +193,102 @@ func FilterEqual(a Filter, b Filter) bool {
 
 	return true
 }
+
+func timeEqual(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// Clone returns a copy of ef that shares nothing with it, so it can be modified freely.
+func (ef Filter) Clone() Filter {
+	clone := ef
+	clone.IDs = cloneSlice(ef.IDs)
+	clone.Kinds = cloneSlice(ef.Kinds)
+	clone.Authors = cloneSlice(ef.Authors)
+	clone.IDPrefixes = cloneSlice(ef.IDPrefixes)
+	clone.AuthorPrefixes = cloneSlice(ef.AuthorPrefixes)
+	if ef.Tags != nil {
+		clone.Tags = make(TagMap, len(ef.Tags))
+		for name, values := range ef.Tags {
+			clone.Tags[name] = cloneSlice(values)
+		}
+	}
+	if ef.Since != nil {
+		since := *ef.Since
+		clone.Since = &since
+	}
+	if ef.Until != nil {
+		until := *ef.Until
+		clone.Until = &until
+	}
+	return clone
+}
+
+func cloneSlice[T any](items []T) []T {
+	if items == nil {
+		return nil
+	}
+	return append(make([]T, 0, len(items)), items...)
+}
+
+// Contains tells if every event other asks for is also asked for by ef, so a subscription with
+// ef already gets everything a subscription with other would. It may say false for some
+// filters that are contained, never true for one that isn't. With a limit ef only contains
+// filters that are the same but for a limit no larger than its own.
+func (ef Filter) Contains(other Filter) bool {
+	if ef.Limit > 0 {
+		if other.Limit <= 0 || other.Limit > ef.Limit {
+			return false
+		}
+		a, b := ef, other
+		a.Limit, b.Limit = 0, 0
+		return FilterEqual(a, b)
+	}
+	if ef.IDPrefixes != nil || ef.AuthorPrefixes != nil || other.IDPrefixes != nil || other.AuthorPrefixes != nil {
+		// not worth working out for these old filters
+		return FilterEqual(ef, other)
+	}
+
+	if !containsAll(ef.IDs, other.IDs) || !containsAll(ef.Kinds, other.Kinds) || !containsAll(ef.Authors, other.Authors) {
+		return false
+	}
+	for name, values := range ef.Tags {
+		if values != nil && !containsAll(values, other.Tags[name]) {
+			return false
+		}
+	}
+	if ef.Since != nil && (other.Since == nil || other.Since.Before(*ef.Since)) {
+		return false
+	}
+	if ef.Until != nil && (other.Until == nil || other.Until.After(*ef.Until)) {
+		return false
+	}
+	if ef.Search != "" && ef.Search != other.Search {
+		return false
+	}
+	return true
+}
+
+// IsSubsetOf tells if other asks for every event ef asks for, see Contains.
+func (ef Filter) IsSubsetOf(other Filter) bool {
+	return other.Contains(ef)
+}
+
+// containsAll tells if a filter field with items allows everything one with others does, nil
+// being any value.
+func containsAll[T comparable](items []T, others []T) bool {
+	if items == nil {
+		return true
+	}
+	if others == nil {
+		return false
+	}
+	for _, item := range others {
+		if !slices.Contains(items, item) {
+			return false
+		}
+	}
+	return true
+}
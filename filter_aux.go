@@ -2,6 +2,8 @@ package nostr
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -9,7 +11,8 @@ import (
 )
 
 func (f *Filter) UnmarshalJSON(payload []byte) error {
-	var fastjsonParser fastjson.Parser
+	fastjsonParser := fastjsonParsers.Get()
+	defer fastjsonParsers.Put(fastjsonParser)
 	parsed, err := fastjsonParser.ParseBytes(payload)
 	if err != nil {
 		return fmt.Errorf("failed to parse filter: %w", err)
@@ -27,7 +30,7 @@ func (f *Filter) UnmarshalJSON(payload []byte) error {
 		key := string(k)
 		switch key {
 		case "ids":
-			f.IDs, err = fastjsonArrayToStringList(v)
+			f.IDs, f.IDPrefixes, err = fastjsonArrayToHex32List[ID](v)
 			if err != nil {
 				visiterr = fmt.Errorf("invalid 'ids' field: %w", err)
 			}
@@ -37,7 +40,7 @@ func (f *Filter) UnmarshalJSON(payload []byte) error {
 				visiterr = fmt.Errorf("invalid 'kinds' field: %w", err)
 			}
 		case "authors":
-			f.Authors, err = fastjsonArrayToStringList(v)
+			f.Authors, f.AuthorPrefixes, err = fastjsonArrayToHex32List[PubKey](v)
 			if err != nil {
 				visiterr = fmt.Errorf("invalid 'authors' field: %w", err)
 			}
@@ -84,54 +87,87 @@ func (f *Filter) UnmarshalJSON(payload []byte) error {
 }
 
 func (f Filter) MarshalJSON() ([]byte, error) {
-	var arena fastjson.Arena
+	dst := make([]byte, 0, 64+len(f.IDs)*67+len(f.Authors)*67+len(f.Kinds)*6+len(f.Search))
+	dst = append(dst, '{')
 
-	o := arena.NewObject()
-
-	if f.IDs != nil {
-		o.Set("ids", stringListToFastjsonArray(&arena, f.IDs))
+	if f.IDs != nil || f.IDPrefixes != nil {
+		dst = appendKey(dst, "ids")
+		dst = appendHex32List(dst, f.IDs, f.IDPrefixes)
 	}
 	if f.Kinds != nil {
-		o.Set("kinds", intListToFastjsonArray(&arena, f.Kinds))
+		dst = appendKey(dst, "kinds")
+		dst = append(dst, '[')
+		for i, kind := range f.Kinds {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = strconv.AppendInt(dst, int64(kind), 10)
+		}
+		dst = append(dst, ']')
 	}
-	if f.Authors != nil {
-		o.Set("authors", stringListToFastjsonArray(&arena, f.Authors))
+	if f.Authors != nil || f.AuthorPrefixes != nil {
+		dst = appendKey(dst, "authors")
+		dst = appendHex32List(dst, f.Authors, f.AuthorPrefixes)
 	}
 	if f.Since != nil {
-		o.Set("since", arena.NewNumberInt(int(f.Since.Unix())))
+		dst = appendKey(dst, "since")
+		dst = strconv.AppendInt(dst, f.Since.Unix(), 10)
 	}
 	if f.Until != nil {
-		o.Set("until", arena.NewNumberInt(int(f.Until.Unix())))
-	}
-	if f.Tags != nil {
-		for k, v := range f.Tags {
-			o.Set("#"+k, stringListToFastjsonArray(&arena, v))
+		dst = appendKey(dst, "until")
+		dst = strconv.AppendInt(dst, f.Until.Unix(), 10)
+	}
+	// tags in a stable order, so the same filter always serializes the same way
+	if len(f.Tags) > 0 {
+		names := make([]string, 0, len(f.Tags))
+		for k := range f.Tags {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for _, k := range names {
+			dst = appendKey(dst, "#"+k)
+			dst = Tag(f.Tags[k]).marshalTo(dst)
 		}
 	}
 	if f.Limit != 0 {
-		o.Set("limit", arena.NewNumberInt(f.Limit))
+		dst = appendKey(dst, "limit")
+		dst = strconv.AppendInt(dst, int64(f.Limit), 10)
 	}
 	if f.Search != "" {
-		o.Set("search", arena.NewString(f.Search))
+		dst = appendKey(dst, "search")
+		dst = escapeString(dst, f.Search)
 	}
 
-	return o.MarshalTo(nil), nil
+	dst = append(dst, '}')
+	return dst, nil
 }
 
-func stringListToFastjsonArray(arena *fastjson.Arena, sl []string) *fastjson.Value {
-	arr := arena.NewArray()
-	for i, v := range sl {
-		arr.SetArrayItem(i, arena.NewString(v))
+// appendKey appends an object key to dst, after a comma unless it's the first one.
+func appendKey(dst []byte, key string) []byte {
+	if dst[len(dst)-1] != '{' {
+		dst = append(dst, ',')
 	}
-	return arr
+	dst = escapeString(dst, key)
+	return append(dst, ':')
 }
 
-func intListToFastjsonArray(arena *fastjson.Arena, il []int) *fastjson.Value {
-	arr := arena.NewArray()
-	for i, v := range il {
-		arr.SetArrayItem(i, arena.NewNumberInt(v))
+func appendHex32List[T ID | PubKey](dst []byte, hl []T, prefixes []string) []byte {
+	dst = append(dst, '[')
+	for i, v := range hl {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = append(dst, '"')
+		dst = appendHex(dst, v[:])
+		dst = append(dst, '"')
+	}
+	for i, prefix := range prefixes {
+		if i > 0 || len(hl) > 0 {
+			dst = append(dst, ',')
+		}
+		dst = escapeString(dst, prefix)
 	}
-	return arr
+	return append(dst, ']')
 }
 
 func fastjsonArrayToStringList(v *fastjson.Value) ([]string, error) {
@@ -152,6 +188,53 @@ func fastjsonArrayToStringList(v *fastjson.Value) ([]string, error) {
 	return sl, nil
 }
 
+// fastjsonArrayToHex32List reads an array of ids or pubkeys, putting the ones that are only hex
+// prefixes apart.
+func fastjsonArrayToHex32List[T ID | PubKey](v *fastjson.Value) ([]T, []string, error) {
+	arr, err := v.Array()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hl := make([]T, 0, len(arr))
+	var prefixes []string
+	for _, v := range arr {
+		sb, err := v.StringBytes()
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(sb) < 64 {
+			if !isHexPrefix(sb) {
+				return nil, nil, fmt.Errorf("'%s' is not hex", sb)
+			}
+			prefixes = append(prefixes, string(sb))
+			continue
+		}
+		var item T
+		if err := decodeHex32(item[:], string(sb)); err != nil {
+			return nil, nil, err
+		}
+		hl = append(hl, item)
+	}
+	if len(hl) == 0 && len(prefixes) > 0 {
+		hl = nil
+	}
+
+	return hl, prefixes, nil
+}
+
+func isHexPrefix(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+	for _, c := range b {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
 func fastjsonArrayToIntList(v *fastjson.Value) ([]int, error) {
 	arr, err := v.Array()
 	if err != nil {
@@ -0,0 +1,118 @@
+package nostr
+
+import "time"
+
+// Matcher tells if events match some filters like Filters.Match does, but looks ids, kinds,
+// authors and tag values up in sets built once, so it is much faster for big filters checked
+// against many events. It must be compiled again if the filters change.
+type Matcher struct {
+	filters []compiledFilter
+}
+
+type compiledFilter struct {
+	// nil when the filter doesn't restrict that field
+	ids     map[ID]struct{}
+	kinds   map[int]struct{}
+	authors map[PubKey]struct{}
+	tags    map[string]map[string]struct{}
+	since   *time.Time
+	until   *time.Time
+
+	idPrefixes     []string
+	authorPrefixes []string
+}
+
+// Compile builds a Matcher for the filters as they are now.
+func (eff Filters) Compile() *Matcher {
+	m := &Matcher{filters: make([]compiledFilter, len(eff))}
+	for i, filter := range eff {
+		m.filters[i] = filter.compile()
+	}
+	return m
+}
+
+func (ef Filter) compile() compiledFilter {
+	cf := compiledFilter{since: ef.Since, until: ef.Until, idPrefixes: ef.IDPrefixes, authorPrefixes: ef.AuthorPrefixes}
+	if ef.IDs != nil || ef.IDPrefixes != nil {
+		cf.ids = setOf(ef.IDs)
+	}
+	if ef.Kinds != nil {
+		cf.kinds = setOf(ef.Kinds)
+	}
+	if ef.Authors != nil || ef.AuthorPrefixes != nil {
+		cf.authors = setOf(ef.Authors)
+	}
+	for name, values := range ef.Tags {
+		if values == nil {
+			continue
+		}
+		if cf.tags == nil {
+			cf.tags = make(map[string]map[string]struct{}, len(ef.Tags))
+		}
+		cf.tags[name] = setOf(values)
+	}
+	return cf
+}
+
+func setOf[T comparable](items []T) map[T]struct{} {
+	set := make(map[T]struct{}, len(items))
+	for _, item := range items {
+		set[item] = struct{}{}
+	}
+	return set
+}
+
+// Match tells if event matches any of the filters.
+func (m *Matcher) Match(event *Event) bool {
+	if event == nil {
+		return false
+	}
+	for i := range m.filters {
+		if m.filters[i].matches(event) {
+			return true
+		}
+	}
+	return false
+}
+
+func (cf *compiledFilter) matches(event *Event) bool {
+	if cf.ids != nil {
+		if _, ok := cf.ids[event.ID]; !ok && !hasHexPrefix(event.ID[:], cf.idPrefixes) {
+			return false
+		}
+	}
+	if cf.kinds != nil {
+		if _, ok := cf.kinds[event.Kind]; !ok {
+			return false
+		}
+	}
+	if cf.authors != nil {
+		if _, ok := cf.authors[event.PubKey]; !ok && !hasHexPrefix(event.PubKey[:], cf.authorPrefixes) {
+			return false
+		}
+	}
+	if cf.since != nil && event.CreatedAt.Before(*cf.since) {
+		return false
+	}
+	if cf.until != nil && event.CreatedAt.After(*cf.until) {
+		return false
+	}
+
+	// each tag in the filter must be matched by some tag of the event
+	for name, values := range cf.tags {
+		found := false
+		for _, tag := range event.Tags {
+			if len(tag) < 2 || tag[0] != name {
+				continue
+			}
+			if _, ok := values[tag[1]]; ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+package nostr
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMatcherAgreesWithMatch(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	pubkeys := []PubKey{{1}, {2}, {3}}
+	randomEvent := func() *Event {
+		evt := &Event{
+			ID:        ID{byte(rnd.Intn(4))},
+			PubKey:    pubkeys[rnd.Intn(len(pubkeys))],
+			Kind:      rnd.Intn(4),
+			CreatedAt: time.Unix(int64(rnd.Intn(10)), 0),
+		}
+		for i := rnd.Intn(3); i > 0; i-- {
+			evt.Tags = append(evt.Tags, Tag{[]string{"e", "p", "t"}[rnd.Intn(3)], strconv.Itoa(rnd.Intn(3))})
+		}
+		return evt
+	}
+	since, until := time.Unix(3, 0), time.Unix(7, 0)
+
+	for _, filters := range []Filters{
+		{{}},
+		{{IDs: []ID{{1}, {2}}}},
+		{{IDs: []ID{}}},
+		{{Kinds: []int{1, 3}, Authors: []PubKey{{2}}}},
+		{{Tags: TagMap{"e": {"1"}, "t": {"0", "2"}}}},
+		{{Tags: TagMap{"p": nil}}},
+		{{Since: &since}, {Until: &until, Kinds: []int{0}}},
+		{{Authors: []PubKey{{1}}, Since: &since, Until: &until, Tags: TagMap{"p": {"1"}}}},
+	} {
+		matcher := filters.Compile()
+		for i := 0; i < 500; i++ {
+			evt := randomEvent()
+			if matcher.Match(evt) != filters.Match(evt) {
+				t.Fatalf("%v and %v disagree on %v", filters, matcher, evt)
+			}
+		}
+	}
+}
+
+func BenchmarkMatcher(b *testing.B) {
+	filter := Filter{Kinds: []int{1, 6, 7}}
+	for i := 0; i < 1000; i++ {
+		filter.Authors = append(filter.Authors, PubKey{byte(i), byte(i >> 8)})
+	}
+	filters := Filters{filter}
+	evt := &Event{Kind: 1, PubKey: PubKey{255, 255}}
+
+	b.Run("Filters.Match", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			filters.Match(evt)
+		}
+	})
+	b.Run("Matcher.Match", func(b *testing.B) {
+		matcher := filters.Compile()
+		for i := 0; i < b.N; i++ {
+			matcher.Match(evt)
+		}
+	})
+}
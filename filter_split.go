@@ -0,0 +1,132 @@
+package nostr
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// relays are only asked for their limits when a REQ is bigger than this, smaller ones are
+// accepted everywhere and we don't want to wait for a NIP-11 request before every subscription.
+const (
+	smallReqMaxFilters = 10
+	smallReqMaxItems   = 100
+)
+
+// room left in each REQ for the command, subscription id and separators
+const reqEnvelopeSize = 128
+
+// Split breaks the filter into filters with at most maxItems ids and maxItems authors each,
+// that together match the same events as the original. Limit is kept in every part, so the
+// parts together may return more events than the original filter would.
+func (ef Filter) Split(maxItems int) Filters {
+	if maxItems <= 0 || (len(ef.IDs) <= maxItems && len(ef.Authors) <= maxItems) {
+		return Filters{ef}
+	}
+
+	idChunks := chunk(ef.IDs, maxItems)
+	authorChunks := chunk(ef.Authors, maxItems)
+
+	parts := make(Filters, 0, len(idChunks)*len(authorChunks))
+	for _, ids := range idChunks {
+		for _, authors := range authorChunks {
+			part := ef
+			part.IDs = ids
+			part.Authors = authors
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// chunk returns at least one (possibly nil) chunk so it can be used in products.
+func chunk[T any](items []T, size int) [][]T {
+	if len(items) <= size {
+		return [][]T{items}
+	}
+	chunks := make([][]T, 0, len(items)/size+1)
+	for size < len(items) {
+		chunks = append(chunks, items[0:size:size])
+		items = items[size:]
+	}
+	return append(chunks, items)
+}
+
+// splitFilters turns filters into one or more batches, each sent in its own REQ, that respect
+// Relay.MaxFilterItems and the relay's advertised max_filters and max_message_length, with
+// limits lowered to its max_limit.
+func (r *Relay) splitFilters(ctx context.Context, filters Filters) []Filters {
+	maxItems := r.MaxFilterItems
+	maxFilters := 0
+	maxMessage := 0
+
+	if lim := r.limitation(ctx, isLargeReq(filters)); lim != nil {
+		maxFilters = lim.MaxFilters
+		maxMessage = lim.MaxMessageLength - reqEnvelopeSize
+		filters = clampLimits(filters, lim.MaxLimit)
+	}
+
+	split := make(Filters, 0, len(filters))
+	for _, filter := range filters {
+		for _, part := range filter.Split(maxItems) {
+			split = append(split, splitToSize(part, maxMessage)...)
+		}
+	}
+
+	if maxFilters <= 0 && maxMessage <= 0 {
+		return []Filters{split}
+	}
+
+	batches := make([]Filters, 0, 1)
+	var current Filters
+	currentSize := 0
+	for _, filter := range split {
+		size := filterSize(filter)
+		if len(current) > 0 &&
+			((maxFilters > 0 && len(current) == maxFilters) || (maxMessage > 0 && currentSize+size > maxMessage)) {
+			batches = append(batches, current)
+			current = nil
+			currentSize = 0
+		}
+		current = append(current, filter)
+		currentSize += size + 1
+	}
+	return append(batches, current)
+}
+
+// splitToSize halves the filter until each part serializes to at most maxSize bytes,
+// or can't be split anymore.
+func splitToSize(filter Filter, maxSize int) Filters {
+	if maxSize <= 0 || filterSize(filter) <= maxSize {
+		return Filters{filter}
+	}
+
+	largest := len(filter.IDs)
+	if len(filter.Authors) > largest {
+		largest = len(filter.Authors)
+	}
+	if largest <= 1 {
+		return Filters{filter}
+	}
+
+	var parts Filters
+	for _, part := range filter.Split((largest + 1) / 2) {
+		parts = append(parts, splitToSize(part, maxSize)...)
+	}
+	return parts
+}
+
+func isLargeReq(filters Filters) bool {
+	if len(filters) > smallReqMaxFilters {
+		return true
+	}
+	items := 0
+	for _, filter := range filters {
+		items += len(filter.IDs) + len(filter.Authors)
+	}
+	return items > smallReqMaxItems
+}
+
+func filterSize(filter Filter) int {
+	j, _ := json.Marshal(filter)
+	return len(j)
+}
@@ -9,7 +9,7 @@ import (
 )
 
 func TestFilterUnmarshal(t *testing.T) {
-	raw := `{"ids": ["abc"],"#e":["zzz"],"#something":["nothing","bab"],"since":1644254609,"search":"test"}`
+	raw := `{"ids": ["5a127c9c931f392f6afc7fdb74e8be01c34035314735a6b97d2cf360d13cfb94"],"#e":["zzz"],"#something":["nothing","bab"],"since":1644254609,"search":"test"}`
 	var f Filter
 	err := json.Unmarshal([]byte(raw), &f)
 	if err != nil {
@@ -56,13 +56,13 @@ func TestFilterMatching(t *testing.T) {
 		Tags: TagMap{
 			"p": {"ooo"},
 		},
-		IDs: []string{"prefix"},
+		IDs: []ID{MustIDFromHex("5a127c9c931f392f6afc7fdb74e8be01c34035314735a6b97d2cf360d13cfb94")},
 	}).Matches(&Event{
 		Kind: 4,
 		Tags: Tags{{"p", "ooo", ",x,x,"}, {"m", "yywyw", "xxx"}},
-		ID:   "prefix123",
+		ID:   MustIDFromHex("5a127c9c931f392f6afc7fdb74e8be01c34035314735a6b97d2cf360d13cfb94"),
 	}) {
-		t.Error("failed to match event by kind+tags+id prefix")
+		t.Error("failed to match event by kind+tags+id")
 	}
 }
 
@@ -78,6 +78,36 @@ func TestFilterMatchingLive(t *testing.T) {
 	}
 }
 
+func TestFilterPrefixes(t *testing.T) {
+	raw := `{"ids":["00000000000000000000000000000000000000000000000000000000000000aa","5a127c"],"authors":["1d80e5"]}`
+	var filter Filter
+	if err := json.Unmarshal([]byte(raw), &filter); err != nil {
+		t.Fatal(err)
+	}
+	if len(filter.IDs) != 1 || len(filter.IDPrefixes) != 1 || filter.Authors != nil || len(filter.AuthorPrefixes) != 1 {
+		t.Fatalf("prefixes weren't parsed apart: %+v", filter)
+	}
+	if j, _ := json.Marshal(filter); string(j) != raw {
+		t.Fatalf("prefixes weren't marshaled back: %s", j)
+	}
+
+	event := Event{
+		ID:     MustIDFromHex("5a127c9c931f392f6afc7fdb74e8be01c34035314735a6b97d2cf360d13cfb94"),
+		PubKey: MustPubKeyFromHex("1d80e5588de010d137a67c42b03717595f5f510e73e42cfc48f31bae91844d59"),
+	}
+	if !filter.Matches(&event) || !(Filters{filter}).Compile().Match(&event) {
+		t.Error("failed to match event by prefixes")
+	}
+	event.PubKey = PubKey{}
+	if filter.Matches(&event) || (Filters{filter}).Compile().Match(&event) {
+		t.Error("matched event by the wrong author")
+	}
+
+	if err := json.Unmarshal([]byte(`{"ids":["xyz"]}`), &filter); err == nil {
+		t.Error("invalid prefix should fail")
+	}
+}
+
 func TestFilterEquality(t *testing.T) {
 	if !FilterEqual(
 		Filter{Kinds: []int{4, 5}},
@@ -99,13 +129,13 @@ func TestFilterEquality(t *testing.T) {
 			Kinds: []int{4, 5},
 			Tags:  TagMap{"letter": {"a", "b"}, "fruit": {"banana"}},
 			Since: &tm,
-			IDs:   []string{"aaaa", "bbbb"},
+			IDs:   []ID{{0xaa}, {0xbb}},
 		},
 		Filter{
 			Kinds: []int{5, 4},
 			Tags:  TagMap{"letter": {"a", "b"}, "fruit": {"banana"}},
 			Since: &tm,
-			IDs:   []string{"aaaa", "bbbb"},
+			IDs:   []ID{{0xbb}, {0xaa}},
 		},
 	) {
 		t.Error("kind+2tags+since+ids filters should be equal")
@@ -117,4 +147,157 @@ func TestFilterEquality(t *testing.T) {
 	) {
 		t.Error("kinds filters shouldn't be equal")
 	}
+
+	sameTime := tm.Add(0)
+	if !FilterEqual(Filter{Since: &tm}, Filter{Since: &sameTime}) {
+		t.Error("filters with the same since should be equal")
+	}
+	if FilterEqual(Filter{Kinds: []int{1}, Limit: 10}, Filter{Kinds: []int{1}}) {
+		t.Error("filters with different limits shouldn't be equal")
+	}
+}
+
+func TestFilterContains(t *testing.T) {
+	a, b := PubKey{1}, PubKey{2}
+	t1 := time.Unix(1700000000, 0)
+	t2 := t1.Add(time.Hour)
+
+	for i, c := range []struct {
+		filter, other Filter
+		contains      bool
+	}{
+		{Filter{Kinds: []int{1, 6}}, Filter{Kinds: []int{1}}, true},
+		{Filter{Kinds: []int{1}}, Filter{Kinds: []int{1, 6}}, false},
+		{Filter{Kinds: []int{1}}, Filter{}, false},
+		{Filter{}, Filter{Kinds: []int{1}, Authors: []PubKey{a}}, true},
+		{Filter{Authors: []PubKey{a, b}}, Filter{Kinds: []int{1}, Authors: []PubKey{b}}, true},
+		{Filter{Tags: TagMap{"t": {"x", "y"}}}, Filter{Tags: TagMap{"t": {"y"}, "p": {"z"}}}, true},
+		{Filter{Tags: TagMap{"t": {"x"}}}, Filter{Tags: TagMap{"p": {"z"}}}, false},
+		{Filter{Since: &t1}, Filter{Since: &t2}, true},
+		{Filter{Since: &t2}, Filter{Since: &t1}, false},
+		{Filter{Until: &t2}, Filter{Since: &t1, Until: &t1}, true},
+		{Filter{Until: &t1}, Filter{}, false},
+		{Filter{Kinds: []int{1}}, Filter{Kinds: []int{1}, Limit: 5}, true},
+		{Filter{Kinds: []int{1}, Limit: 10}, Filter{Kinds: []int{1}, Limit: 5}, true},
+		{Filter{Kinds: []int{1}, Limit: 10}, Filter{Kinds: []int{1}, Limit: 20}, false},
+		{Filter{Kinds: []int{1, 6}, Limit: 10}, Filter{Kinds: []int{1}, Limit: 5}, false},
+		{Filter{Search: "nostr"}, Filter{}, false},
+		{Filter{}, Filter{Search: "nostr"}, true},
+	} {
+		if got := c.filter.Contains(c.other); got != c.contains {
+			t.Errorf("case %d: %s contains %s should be %v", i, c.filter, c.other, c.contains)
+		}
+		if got := c.other.IsSubsetOf(c.filter); got != c.contains {
+			t.Errorf("case %d: IsSubsetOf disagrees with Contains", i)
+		}
+	}
+}
+
+func TestFilterClone(t *testing.T) {
+	since := time.Unix(1700000000, 0)
+	filter := Filter{Kinds: []int{1}, Authors: []PubKey{{1}}, Tags: TagMap{"t": {"x"}}, Since: &since, Limit: 3}
+	clone := filter.Clone()
+	if !FilterEqual(filter, clone) {
+		t.Fatalf("clone differs: %s", clone)
+	}
+
+	clone.Kinds[0] = 7
+	clone.Authors[0] = PubKey{2}
+	clone.Tags["t"][0] = "y"
+	*clone.Since = since.Add(time.Hour)
+	if filter.Kinds[0] != 1 || filter.Authors[0] != (PubKey{1}) || filter.Tags["t"][0] != "x" || !filter.Since.Equal(since) {
+		t.Fatalf("original modified through the clone: %s", filter)
+	}
+	if (Filter{}).Clone().Kinds != nil {
+		t.Error("nil fields should stay nil")
+	}
+}
+
+func TestFilterSplit(t *testing.T) {
+	authors := make([]PubKey, 5)
+	for i := range authors {
+		authors[i] = PubKey{byte(i + 1)}
+	}
+	filter := Filter{Kinds: []int{1}, Authors: authors, IDs: []ID{{0xaa}, {0xbb}, {0xcc}}, Limit: 10}
+
+	if parts := filter.Split(5); len(parts) != 1 {
+		t.Errorf("filter within limits shouldn't be split, got %d parts", len(parts))
+	}
+
+	parts := filter.Split(2)
+	if len(parts) != 6 {
+		t.Fatalf("expected 3 author chunks * 2 id chunks = 6 parts, got %d", len(parts))
+	}
+	for _, part := range parts {
+		if len(part.Authors) > 2 || len(part.IDs) > 2 {
+			t.Errorf("part is too big: %v", part)
+		}
+		if part.Limit != 10 || !slices.Equal(part.Kinds, []int{1}) {
+			t.Errorf("other fields should be kept: %v", part)
+		}
+	}
+
+	// every (id, author) combination must be covered exactly once
+	for _, id := range filter.IDs {
+		for _, author := range authors {
+			evt := &Event{ID: id, PubKey: author, Kind: 1}
+			matches := 0
+			for _, part := range parts {
+				if part.Matches(evt) {
+					matches++
+				}
+			}
+			if matches != 1 {
+				t.Errorf("event %s/%s matched %d parts", id, author, matches)
+			}
+		}
+	}
+}
+
+const benchmarkFilter = `{"ids":["5a127c9c931f392f6afc7fdb74e8be01c34035314735a6b97d2cf360d13cfb94"],"kinds":[1,6,7],"authors":["3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d","75fc5ac2487363293bd27fb0d14fb966477d0f1dbc6361d37806a6a740eda91e"],"#e":["zzz"],"since":1644254609,"limit":100}`
+
+func BenchmarkFilterUnmarshal(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var filter Filter
+		if err := filter.UnmarshalJSON([]byte(benchmarkFilter)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFilterMarshal(b *testing.B) {
+	var filter Filter
+	filter.UnmarshalJSON([]byte(benchmarkFilter))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		filter.MarshalJSON()
+	}
+}
+
+func TestFilterApplyLimits(t *testing.T) {
+	var events []*Event
+	for i, kind := range []int{1, 7, 1, 7, 1, 30023} {
+		events = append(events, &Event{Kind: kind, CreatedAt: time.Unix(int64(1700000000+i), 0)})
+	}
+
+	kept := Filters{{Kinds: []int{1}, Limit: 2}, {Kinds: []int{7}}}.ApplyLimits(events)
+	var times []int64
+	for _, evt := range kept {
+		times = append(times, evt.CreatedAt.Unix()-1700000000)
+	}
+	if !slices.Equal(times, []int64{4, 3, 2, 1}) {
+		t.Errorf("kept %v", times)
+	}
+
+	// an event also matching a filter without a limit stays
+	kept = Filters{{Limit: 1}, {Kinds: []int{1}}}.ApplyLimits(events)
+	if len(kept) != 4 || kept[0].Kind != 30023 {
+		t.Errorf("kept %d events, the newest of kind %d", len(kept), kept[0].Kind)
+	}
+
+	if kept := (Filters{{Kinds: []int{1}}}).ApplyLimits(events); len(kept) != len(events) {
+		t.Errorf("events were dropped without limits")
+	}
 }
@@ -0,0 +1,146 @@
+package nostr
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil/bech32"
+)
+
+// ID is the 32-byte sha256 hash that identifies an event.
+type ID [32]byte
+
+// PubKey is a 32-byte x-only schnorr public key.
+type PubKey [32]byte
+
+var (
+	ZeroID     ID
+	ZeroPubKey PubKey
+)
+
+// IDFromHex parses a 64-character hex string into an ID.
+func IDFromHex(idHex string) (ID, error) {
+	var id ID
+	err := decodeHex32(id[:], idHex)
+	return id, err
+}
+
+// MustIDFromHex is like IDFromHex, but panics on invalid input. Only use it with constants.
+func MustIDFromHex(idHex string) ID {
+	id, err := IDFromHex(idHex)
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+// IDsFromHex parses hex strings into IDs, like for Filter.IDs, failing at the first invalid one.
+func IDsFromHex(idHexes ...string) ([]ID, error) {
+	ids := make([]ID, len(idHexes))
+	for i, idHex := range idHexes {
+		if err := decodeHex32(ids[i][:], idHex); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// IDFromNote parses a NIP-19 "note1..." string into an ID.
+func IDFromNote(note string) (ID, error) {
+	var id ID
+	err := decodeBech32(id[:], "note", note)
+	return id, err
+}
+
+func (id ID) Hex() string    { return hex.EncodeToString(id[:]) }
+func (id ID) String() string { return id.Hex() }
+
+// Note returns the NIP-19 "note1..." encoding of the ID.
+func (id ID) Note() string { return encodeBech32("note", id[:]) }
+
+func (id ID) MarshalText() ([]byte, error) { return []byte(id.Hex()), nil }
+func (id *ID) UnmarshalText(text []byte) error {
+	return decodeHex32(id[:], string(text))
+}
+
+// PubKeyFromHex parses a 64-character hex string into a PubKey.
+// It doesn't check if the key is a valid point in the curve.
+func PubKeyFromHex(pkHex string) (PubKey, error) {
+	var pk PubKey
+	err := decodeHex32(pk[:], pkHex)
+	return pk, err
+}
+
+// MustPubKeyFromHex is like PubKeyFromHex, but panics on invalid input. Only use it with constants.
+func MustPubKeyFromHex(pkHex string) PubKey {
+	pk, err := PubKeyFromHex(pkHex)
+	if err != nil {
+		panic(err)
+	}
+	return pk
+}
+
+// PubKeysFromHex parses hex strings into PubKeys, like for Filter.Authors, failing at the first
+// invalid one.
+func PubKeysFromHex(pkHexes ...string) ([]PubKey, error) {
+	pks := make([]PubKey, len(pkHexes))
+	for i, pkHex := range pkHexes {
+		if err := decodeHex32(pks[i][:], pkHex); err != nil {
+			return nil, err
+		}
+	}
+	return pks, nil
+}
+
+// PubKeyFromNpub parses a NIP-19 "npub1..." string into a PubKey.
+func PubKeyFromNpub(npub string) (PubKey, error) {
+	var pk PubKey
+	err := decodeBech32(pk[:], "npub", npub)
+	return pk, err
+}
+
+func (pk PubKey) Hex() string    { return hex.EncodeToString(pk[:]) }
+func (pk PubKey) String() string { return pk.Hex() }
+
+// Npub returns the NIP-19 "npub1..." encoding of the key.
+func (pk PubKey) Npub() string { return encodeBech32("npub", pk[:]) }
+
+func (pk PubKey) MarshalText() ([]byte, error) { return []byte(pk.Hex()), nil }
+func (pk *PubKey) UnmarshalText(text []byte) error {
+	return decodeHex32(pk[:], string(text))
+}
+
+func decodeHex32(dst []byte, src string) error {
+	if len(src) != 64 {
+		return fmt.Errorf("'%s' should have 64 hex characters, has %d", src, len(src))
+	}
+	if _, err := hex.Decode(dst, []byte(src)); err != nil {
+		return fmt.Errorf("'%s' is invalid hex: %w", src, err)
+	}
+	return nil
+}
+
+func encodeBech32(prefix string, data []byte) string {
+	bits5, _ := bech32.ConvertBits(data, 8, 5, true)
+	encoded, _ := bech32.Encode(prefix, bits5)
+	return encoded
+}
+
+func decodeBech32(dst []byte, expectedPrefix string, src string) error {
+	prefix, bits5, err := bech32.DecodeNoLimit(src)
+	if err != nil {
+		return err
+	}
+	if prefix != expectedPrefix {
+		return fmt.Errorf("expected '%s' prefix, got '%s'", expectedPrefix, prefix)
+	}
+	data, err := bech32.ConvertBits(bits5, 5, 8, false)
+	if err != nil {
+		return fmt.Errorf("failed translating data into 8 bits: %w", err)
+	}
+	if len(data) != 32 {
+		return fmt.Errorf("data should have 32 bytes, has %d", len(data))
+	}
+	copy(dst, data)
+	return nil
+}
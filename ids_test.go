@@ -0,0 +1,65 @@
+package nostr
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPubKeyEncodings(t *testing.T) {
+	pk := MustPubKeyFromHex("3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d")
+
+	if pk.Npub() != "npub180cvv07tjdrrgpa0j7j7tmnyl2yr6yr7l8j4s3evf6u64th6gkwsyjh6w6" {
+		t.Errorf("wrong npub: %s", pk.Npub())
+	}
+	if back, err := PubKeyFromNpub(pk.Npub()); err != nil || back != pk {
+		t.Errorf("failed to decode npub: %v", err)
+	}
+	if _, err := PubKeyFromNpub(pk.Npub()[0:60]); err == nil {
+		t.Error("should have failed to decode broken npub")
+	}
+
+	j, _ := json.Marshal(map[PubKey][]ID{pk: {{1, 2, 3}}})
+	if string(j) != `{"3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d":["0102030000000000000000000000000000000000000000000000000000000000"]}` {
+		t.Errorf("wrong json: %s", j)
+	}
+
+	var m map[PubKey][]ID
+	if err := json.Unmarshal(j, &m); err != nil || m[pk][0] != (ID{1, 2, 3}) {
+		t.Errorf("failed to parse json: %v", err)
+	}
+}
+
+func TestIDFromHex(t *testing.T) {
+	if _, err := IDFromHex("abc"); err == nil {
+		t.Error("short id should fail")
+	}
+	if _, err := IDFromHex("zz127c9c931f392f6afc7fdb74e8be01c34035314735a6b97d2cf360d13cfb94"); err == nil {
+		t.Error("invalid hex should fail")
+	}
+	id, err := IDFromHex("5a127c9c931f392f6afc7fdb74e8be01c34035314735a6b97d2cf360d13cfb94")
+	if err != nil || id.Hex() != "5a127c9c931f392f6afc7fdb74e8be01c34035314735a6b97d2cf360d13cfb94" {
+		t.Errorf("failed to roundtrip id: %v", err)
+	}
+	if back, err := IDFromNote(id.Note()); err != nil || back != id {
+		t.Errorf("failed to roundtrip note: %v", err)
+	}
+}
+
+func TestHexShims(t *testing.T) {
+	ids, err := IDsFromHex("5a127c9c931f392f6afc7fdb74e8be01c34035314735a6b97d2cf360d13cfb94")
+	if err != nil || len(ids) != 1 || ids[0].Hex() != "5a127c9c931f392f6afc7fdb74e8be01c34035314735a6b97d2cf360d13cfb94" {
+		t.Errorf("failed to parse ids: %v %v", ids, err)
+	}
+	if _, err := PubKeysFromHex("3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d", "xyz"); err == nil {
+		t.Error("invalid pubkey should fail")
+	}
+
+	var evt Event
+	if err := evt.SetPubKeyHex("3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"); err != nil ||
+		evt.PubKeyHex() != "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d" {
+		t.Errorf("failed to set pubkey: %v", err)
+	}
+	if evt.SetPubKeyHex("abc") == nil {
+		t.Error("short pubkey should fail")
+	}
+}
@@ -0,0 +1,37 @@
+package nostr
+
+// EventInterceptor is called with every event received from relay, once its signature is
+// checked, before it reaches the subscription. It can annotate evt, e.g. with SetExtra, and
+// returns false to drop it. Interceptors run in the goroutine that reads from the relay, so
+// they shouldn't block.
+type EventInterceptor func(relay *Relay, evt *Event) bool
+
+// WithEventInterceptors makes the relay run interceptors, in order, on all the events it
+// receives, e.g. for mute lists, spam scoring or metrics. See also SimplePool.Interceptors.
+func WithEventInterceptors(interceptors ...EventInterceptor) RelayOption {
+	return func(r *Relay) {
+		r.interceptors = append(r.interceptors, interceptors...)
+	}
+}
+
+// RejectAuthors is an EventInterceptor that drops the events by any of pubkeys.
+func RejectAuthors(pubkeys ...PubKey) EventInterceptor {
+	rejected := make(map[PubKey]struct{}, len(pubkeys))
+	for _, pk := range pubkeys {
+		rejected[pk] = struct{}{}
+	}
+	return func(_ *Relay, evt *Event) bool {
+		_, reject := rejected[evt.PubKey]
+		return !reject
+	}
+}
+
+// intercept runs interceptors on evt until one of them drops it.
+func intercept(interceptors []EventInterceptor, relay *Relay, evt *Event) bool {
+	for _, interceptor := range interceptors {
+		if !interceptor(relay, evt) {
+			return false
+		}
+	}
+	return true
+}
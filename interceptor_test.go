@@ -0,0 +1,77 @@
+package nostr
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEventInterceptors(t *testing.T) {
+	fr := &fakeRelay{}
+	sk1, pk1 := makeKeyPair(t)
+	sk2, pk2 := makeKeyPair(t)
+	for _, sk := range []string{sk1, sk2} {
+		pk, _ := GetPublicKey(sk)
+		evt := Event{Kind: 1, PubKey: MustPubKeyFromHex(pk), CreatedAt: time.Now(), Content: "hello"}
+		evt.Sign(sk)
+		fr.events = append(fr.events, evt)
+	}
+	ws := newWebsocketServer(fr.handle)
+	defer ws.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	relay, err := RelayConnect(ctx, ws.URL, WithEventInterceptors(
+		RejectAuthors(pk2),
+		func(relay *Relay, evt *Event) bool {
+			evt.SetExtra("seen_on", relay.URL)
+			return true
+		},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer relay.Close()
+
+	events, err := relay.Query(ctx, Filter{Kinds: []int{1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].PubKey != pk1 {
+		t.Fatalf("expected only the event by %s, got %v", pk1, events)
+	}
+	if events[0].GetExtraString("seen_on") != relay.URL {
+		t.Fatalf("event wasn't annotated: %v", events[0])
+	}
+}
+
+func TestPoolInterceptors(t *testing.T) {
+	sk, pk := makeKeyPair(t)
+	evt := Event{Kind: 1, PubKey: pk, CreatedAt: time.Now(), Content: "everywhere"}
+	evt.Sign(sk)
+
+	var urls []string
+	for i := 0; i < 3; i++ {
+		ws := newWebsocketServer((&fakeRelay{events: []Event{evt}}).handle)
+		defer ws.Close()
+		urls = append(urls, ws.URL)
+	}
+
+	pool := NewSimplePool(context.Background())
+	defer pool.Close()
+	var calls int32
+	pool.Interceptors = []EventInterceptor{func(*Relay, *Event) bool {
+		atomic.AddInt32(&calls, 1)
+		return false
+	}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for range pool.SubManyEose(ctx, urls, Filters{{Kinds: []int{1}}}) {
+		t.Fatal("dropped event was delivered")
+	}
+	if calls != 1 {
+		t.Fatalf("interceptor called %d times, expected 1", calls)
+	}
+}
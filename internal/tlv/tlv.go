@@ -0,0 +1,61 @@
+// Package tlv reads and writes the type-length-value entries of NIP-19 nprofile, nevent and
+// naddr codes, for both the nostr and nip19 packages.
+package tlv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	Default uint8 = 0
+	Relay   uint8 = 1
+	Author  uint8 = 2
+	Kind    uint8 = 3
+)
+
+// Entries are what a code has, Special is the pubkey, id or "d" tag, depending on the code.
+type Entries struct {
+	Special []byte
+	Relays  []string
+	Author  []byte
+	Kind    int
+	HasKind bool
+}
+
+// Decode reads the entries in data. Unknown entries, kinds that aren't 4 bytes and a last
+// byte that can't be an entry are ignored.
+func Decode(data []byte) (Entries, error) {
+	var entries Entries
+	for len(data) >= 2 {
+		typ, length := data[0], int(data[1])
+		if len(data) < 2+length {
+			return entries, fmt.Errorf("entry of type %d is truncated", typ)
+		}
+		value := data[2 : 2+length]
+		data = data[2+length:]
+
+		switch typ {
+		case Default:
+			entries.Special = value
+		case Relay:
+			entries.Relays = append(entries.Relays, string(value))
+		case Author:
+			entries.Author = value
+		case Kind:
+			if length == 4 {
+				entries.Kind = int(binary.BigEndian.Uint32(value))
+				entries.HasKind = true
+			}
+		}
+	}
+	return entries, nil
+}
+
+// Write appends an entry to buf.
+func Write(buf *bytes.Buffer, typ uint8, value []byte) {
+	buf.WriteByte(typ)
+	buf.WriteByte(uint8(len(value)))
+	buf.Write(value)
+}
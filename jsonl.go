@@ -0,0 +1,174 @@
+package nostr
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+type jsonlOptions struct {
+	validate      bool
+	progress      func(count int)
+	progressEvery int
+	onError       func(line int, err error)
+	ctx           context.Context
+}
+
+// JSONLOption customizes ReadEvents and WriteEvents.
+type JSONLOption func(*jsonlOptions)
+
+// WithEventValidation skips events whose id or signature doesn't match. Their content isn't
+// checked, see ValidateKind for that.
+func WithEventValidation() JSONLOption {
+	return func(o *jsonlOptions) {
+		o.validate = true
+	}
+}
+
+// WithEventProgress calls fn with the number of events read or written so far every time
+// another `every` events are done, and once more at the end.
+func WithEventProgress(fn func(count int), every int) JSONLOption {
+	return func(o *jsonlOptions) {
+		o.progress = fn
+		o.progressEvery = every
+	}
+}
+
+// WithLineErrorHandler calls fn for each line that couldn't be parsed or failed validation,
+// with its line number starting at 1, and these lines are skipped. When writing, it is the
+// position of the event in the channel instead.
+func WithLineErrorHandler(fn func(line int, err error)) JSONLOption {
+	return func(o *jsonlOptions) {
+		o.onError = fn
+	}
+}
+
+// WithReadContext makes ReadEvents stop reading and close the channel once ctx is done, so
+// readers can stop early without draining it. WriteEvents ignores it.
+func WithReadContext(ctx context.Context) JSONLOption {
+	return func(o *jsonlOptions) {
+		o.ctx = ctx
+	}
+}
+
+func (o *jsonlOptions) check(evt *Event) error {
+	if !o.validate {
+		return nil
+	}
+	if evt.GetID() != evt.ID {
+		return fmt.Errorf("id doesn't match")
+	}
+	if ok, err := evt.CheckSignature(); !ok {
+		if err == nil {
+			err = fmt.Errorf("invalid signature")
+		}
+		return err
+	}
+	return nil
+}
+
+func (o *jsonlOptions) report(count int, final bool) {
+	if o.progress == nil {
+		return
+	}
+	if final || (o.progressEvery > 0 && count%o.progressEvery == 0) {
+		o.progress(count)
+	}
+}
+
+// ReadEvents parses newline-delimited JSON events, like the ones produced by `strfry export`,
+// sending them to the returned channel, which is closed at the end of r. Blank lines are
+// ignored and lines that can't be parsed are skipped, see WithLineErrorHandler.
+func ReadEvents(r io.Reader, opts ...JSONLOption) <-chan *Event {
+	options := jsonlOptions{ctx: context.Background()}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	ch := make(chan *Event)
+	go func() {
+		defer close(ch)
+
+		reader := bufio.NewReader(r)
+		count := 0
+		for lineNumber := 1; options.ctx.Err() == nil; lineNumber++ {
+			line, err := reader.ReadBytes('\n')
+			if line = bytes.TrimSpace(line); len(line) > 0 {
+				evt := &Event{}
+				perr := evt.UnmarshalJSON(line)
+				if perr == nil {
+					perr = options.check(evt)
+				}
+				if perr != nil {
+					if options.onError != nil {
+						options.onError(lineNumber, perr)
+					}
+				} else {
+					select {
+					case ch <- evt:
+					case <-options.ctx.Done():
+						return
+					}
+					count++
+					options.report(count, false)
+				}
+			}
+
+			if err != nil {
+				if err != io.EOF && options.onError != nil {
+					options.onError(lineNumber, err)
+				}
+				break
+			}
+		}
+		options.report(count, true)
+	}()
+	return ch
+}
+
+// WriteEvents writes the events from ch to w as newline-delimited JSON until ch is closed.
+// With WithEventValidation invalid events are skipped. If writing fails ch is drained and the
+// error is returned.
+func WriteEvents(w io.Writer, ch <-chan *Event, opts ...JSONLOption) error {
+	options := jsonlOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	writer := bufio.NewWriter(w)
+	count := 0
+	position := 0
+	var werr error
+	for evt := range ch {
+		position++
+		if werr != nil {
+			continue
+		}
+		if err := options.check(evt); err != nil {
+			if options.onError != nil {
+				options.onError(position, err)
+			}
+			continue
+		}
+
+		data, err := json.Marshal(evt)
+		if err == nil {
+			data = append(data, '\n')
+			_, err = writer.Write(data)
+		}
+		if err != nil {
+			werr = err
+			continue
+		}
+		count++
+		options.report(count, false)
+	}
+	if werr != nil {
+		return werr
+	}
+	options.report(count, true)
+	return writer.Flush()
+}
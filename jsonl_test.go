@@ -0,0 +1,82 @@
+package nostr
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONL(t *testing.T) {
+	sk := GeneratePrivateKey()
+	pk, _ := GetPublicKey(sk)
+
+	events := make(chan *Event, 4)
+	for i := 0; i < 3; i++ {
+		evt := &Event{PubKey: MustPubKeyFromHex(pk), CreatedAt: time.Unix(int64(1000+i), 0), Kind: 1, Content: "hello\nworld"}
+		evt.Sign(sk)
+		events <- evt
+	}
+	events <- &Event{PubKey: MustPubKeyFromHex(pk), Kind: 1, Content: "unsigned"}
+	close(events)
+
+	var buf bytes.Buffer
+	var failed []int
+	var progress []int
+	err := WriteEvents(&buf, events,
+		WithEventValidation(),
+		WithLineErrorHandler(func(line int, err error) { failed = append(failed, line) }),
+		WithEventProgress(func(count int) { progress = append(progress, count) }, 2),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failed) != 1 || failed[0] != 4 {
+		t.Fatalf("expected the unsigned event to fail, got %v", failed)
+	}
+	if len(progress) != 2 || progress[0] != 2 || progress[1] != 3 {
+		t.Fatalf("wrong progress %v", progress)
+	}
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %d", len(lines))
+	}
+
+	buf.WriteString("\nnot json\n")
+	failed = nil
+	var read []*Event
+	for evt := range ReadEvents(&buf, WithEventValidation(), WithLineErrorHandler(func(line int, err error) { failed = append(failed, line) })) {
+		read = append(read, evt)
+	}
+	if len(read) != 3 || read[2].CreatedAt.Unix() != 1002 || read[0].Content != "hello\nworld" {
+		t.Fatalf("wrong events read: %v", read)
+	}
+	if len(failed) != 1 || failed[0] != 5 {
+		t.Fatalf("expected line 5 to fail, got %v", failed)
+	}
+}
+
+func TestReadEventsContext(t *testing.T) {
+	sk := GeneratePrivateKey()
+	evt := Event{Kind: 1, CreatedAt: time.Unix(1000, 0), Content: "again"}
+	evt.Sign(sk)
+	line, _ := evt.MarshalJSON()
+
+	var buf bytes.Buffer
+	for i := 0; i < 10000; i++ {
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := ReadEvents(&buf, WithReadContext(ctx))
+	<-events
+	cancel()
+	read := 1
+	for range events {
+		read++
+	}
+	if read > 2 {
+		t.Fatalf("read %d events after being canceled", read)
+	}
+}
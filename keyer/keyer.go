@@ -0,0 +1,115 @@
+// Package keyer provides implementations of [nostr.Signer].
+package keyer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip04"
+	"github.com/nbd-wtf/go-nostr/nip44"
+)
+
+var _ nostr.Signer = (*KeySigner)(nil)
+
+// KeySigner is a nostr.Signer backed by a private key held in memory.
+type KeySigner struct {
+	sk string
+	pk nostr.PubKey
+
+	// shared secrets are cached since they are expensive to compute and reused a lot in conversations
+	nip04Secrets map[nostr.PubKey][]byte
+	nip44Keys    map[nostr.PubKey][32]byte
+	mutex        sync.Mutex
+}
+
+// NewPlainKeySigner returns a KeySigner for the given hex private key.
+func NewPlainKeySigner(sk string) (*KeySigner, error) {
+	pkHex, err := nostr.GetPublicKey(sk)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	pk, err := nostr.PubKeyFromHex(pkHex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+
+	return &KeySigner{
+		sk:           sk,
+		pk:           pk,
+		nip04Secrets: make(map[nostr.PubKey][]byte),
+		nip44Keys:    make(map[nostr.PubKey][32]byte),
+	}, nil
+}
+
+func (ks *KeySigner) GetPublicKey(ctx context.Context) (nostr.PubKey, error) {
+	return ks.pk, nil
+}
+
+func (ks *KeySigner) SignEvent(ctx context.Context, evt *nostr.Event) error {
+	evt.PubKey = ks.pk
+	return evt.Sign(ks.sk)
+}
+
+func (ks *KeySigner) NIP04Encrypt(ctx context.Context, plaintext string, recipient nostr.PubKey) (string, error) {
+	secret, err := ks.nip04Secret(recipient)
+	if err != nil {
+		return "", err
+	}
+	return nip04.Encrypt(plaintext, secret)
+}
+
+func (ks *KeySigner) NIP04Decrypt(ctx context.Context, ciphertext string, sender nostr.PubKey) (string, error) {
+	secret, err := ks.nip04Secret(sender)
+	if err != nil {
+		return "", err
+	}
+	return nip04.Decrypt(ciphertext, secret)
+}
+
+func (ks *KeySigner) NIP44Encrypt(ctx context.Context, plaintext string, recipient nostr.PubKey) (string, error) {
+	ck, err := ks.nip44Key(recipient)
+	if err != nil {
+		return "", err
+	}
+	return nip44.Encrypt(plaintext, ck)
+}
+
+func (ks *KeySigner) NIP44Decrypt(ctx context.Context, ciphertext string, sender nostr.PubKey) (string, error) {
+	ck, err := ks.nip44Key(sender)
+	if err != nil {
+		return "", err
+	}
+	return nip44.Decrypt(ciphertext, ck)
+}
+
+func (ks *KeySigner) nip04Secret(pk nostr.PubKey) ([]byte, error) {
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+
+	if secret, ok := ks.nip04Secrets[pk]; ok {
+		return secret, nil
+	}
+	secret, err := nip04.ComputeSharedSecret(pk.Hex(), ks.sk)
+	if err != nil {
+		return nil, err
+	}
+	ks.nip04Secrets[pk] = secret
+	return secret, nil
+}
+
+func (ks *KeySigner) nip44Key(pk nostr.PubKey) ([32]byte, error) {
+	ks.mutex.Lock()
+	defer ks.mutex.Unlock()
+
+	if ck, ok := ks.nip44Keys[pk]; ok {
+		return ck, nil
+	}
+	ck, err := nip44.GenerateConversationKey(pk.Hex(), ks.sk)
+	if err != nil {
+		return ck, err
+	}
+	ks.nip44Keys[pk] = ck
+	return ck, nil
+}
@@ -0,0 +1,49 @@
+package keyer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestKeySignerRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	alice, _ := NewPlainKeySigner(nostr.GeneratePrivateKey())
+	bob, _ := NewPlainKeySigner(nostr.GeneratePrivateKey())
+	alicePK, _ := alice.GetPublicKey(ctx)
+	bobPK, _ := bob.GetPublicKey(ctx)
+
+	evt := nostr.Event{Kind: 1, CreatedAt: time.Now(), Content: "hello"}
+	if err := alice.SignEvent(ctx, &evt); err != nil {
+		t.Fatalf("failed to sign: %s", err)
+	}
+	if evt.PubKey != alicePK {
+		t.Fatalf("pubkey not set: %s", evt.PubKey)
+	}
+	if ok, _ := evt.CheckSignature(); !ok {
+		t.Fatal("signature is invalid")
+	}
+
+	for _, scheme := range []struct {
+		name    string
+		encrypt func(context.Context, string, nostr.PubKey) (string, error)
+		decrypt func(context.Context, string, nostr.PubKey) (string, error)
+	}{
+		{"nip04", alice.NIP04Encrypt, bob.NIP04Decrypt},
+		{"nip44", alice.NIP44Encrypt, bob.NIP44Decrypt},
+	} {
+		ciphertext, err := scheme.encrypt(ctx, "secret message", bobPK)
+		if err != nil {
+			t.Fatalf("%s: failed to encrypt: %s", scheme.name, err)
+		}
+		plaintext, err := scheme.decrypt(ctx, ciphertext, alicePK)
+		if err != nil {
+			t.Fatalf("%s: failed to decrypt: %s", scheme.name, err)
+		}
+		if plaintext != "secret message" {
+			t.Fatalf("%s: got '%s'", scheme.name, plaintext)
+		}
+	}
+}
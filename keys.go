@@ -3,6 +3,7 @@ package nostr
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"fmt"
 	"io"
 	"math/big"
 	"strings"
@@ -12,11 +13,17 @@ import (
 )
 
 func GeneratePrivateKey() string {
+	return GeneratePrivateKeyFrom(rand.Reader)
+}
+
+// GeneratePrivateKeyFrom is like GeneratePrivateKey, reading randomness from r, e.g. a seeded
+// reader so tests get the same keys every time. It returns "" if r fails.
+func GeneratePrivateKeyFrom(r io.Reader) string {
 	params := btcec.S256().Params()
 	one := new(big.Int).SetInt64(1)
 
 	b := make([]byte, params.BitSize/8+8)
-	_, err := io.ReadFull(rand.Reader, b)
+	_, err := io.ReadFull(r, b)
 	if err != nil {
 		return ""
 	}
@@ -26,7 +33,8 @@ func GeneratePrivateKey() string {
 	k.Mod(k, n)
 	k.Add(k, one)
 
-	return hex.EncodeToString(k.Bytes())
+	// pad so keys with leading zeroes still have 64 characters
+	return hex.EncodeToString(k.FillBytes(make([]byte, 32)))
 }
 
 func GetPublicKey(sk string) (string, error) {
@@ -46,3 +54,98 @@ func IsValidPublicKeyHex(pk string) bool {
 	dec, _ := hex.DecodeString(pk)
 	return len(dec) == 32
 }
+
+// NormalizeKey trims spaces and lowercases a hex or bech32 key, also removing the "0x"
+// prefix some people paste hex keys with. It doesn't check if the key is valid.
+func NormalizeKey(input string) string {
+	key := strings.ToLower(strings.TrimSpace(input))
+	return strings.TrimPrefix(key, "0x")
+}
+
+// ParsePublicKey accepts a public key as hex or npub, normalizing it first, and
+// checks it is a valid point on the curve.
+func ParsePublicKey(input string) (PubKey, error) {
+	key := NormalizeKey(input)
+
+	var pk PubKey
+	var err error
+	if strings.HasPrefix(key, "npub1") {
+		pk, err = PubKeyFromNpub(key)
+	} else {
+		pk, err = PubKeyFromHex(key)
+	}
+	if err != nil {
+		return pk, err
+	}
+
+	if _, err := schnorr.ParsePubKey(pk[:]); err != nil {
+		return pk, fmt.Errorf("'%s' is not a valid public key: %w", input, err)
+	}
+	return pk, nil
+}
+
+// ParsePrivateKey accepts a private key as hex or nsec, normalizing it first, checks that
+// it is in the valid range and returns it as hex.
+func ParsePrivateKey(input string) (string, error) {
+	key := NormalizeKey(input)
+
+	var b [32]byte
+	if strings.HasPrefix(key, "nsec1") {
+		if err := decodeBech32(b[:], "nsec", key); err != nil {
+			return "", fmt.Errorf("invalid nsec: %w", err)
+		}
+	} else if err := decodeHex32(b[:], key); err != nil {
+		return "", err
+	}
+
+	var scalar btcec.ModNScalar
+	if overflow := scalar.SetBytes(&b); overflow != 0 || scalar.IsZero() {
+		return "", fmt.Errorf("private key is out of range")
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+// IsValidPublicKey tells if input is a hex or npub public key that can be used.
+func IsValidPublicKey(input string) bool {
+	_, err := ParsePublicKey(input)
+	return err == nil
+}
+
+// IsValidPrivateKey tells if input is a hex or nsec private key that can be used.
+func IsValidPrivateKey(input string) bool {
+	_, err := ParsePrivateKey(input)
+	return err == nil
+}
+
+// PublicKeyToNpub converts a hex public key to npub.
+func PublicKeyToNpub(pkHex string) (string, error) {
+	pk, err := ParsePublicKey(pkHex)
+	if err != nil {
+		return "", err
+	}
+	return pk.Npub(), nil
+}
+
+// NpubToPublicKey converts an npub to a hex public key.
+func NpubToPublicKey(npub string) (string, error) {
+	pk, err := ParsePublicKey(npub)
+	if err != nil {
+		return "", err
+	}
+	return pk.Hex(), nil
+}
+
+// PrivateKeyToNsec converts a hex private key to nsec.
+func PrivateKeyToNsec(skHex string) (string, error) {
+	sk, err := ParsePrivateKey(skHex)
+	if err != nil {
+		return "", err
+	}
+	b, _ := hex.DecodeString(sk)
+	return encodeBech32("nsec", b), nil
+}
+
+// NsecToPrivateKey converts an nsec to a hex private key.
+func NsecToPrivateKey(nsec string) (string, error) {
+	return ParsePrivateKey(nsec)
+}
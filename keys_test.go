@@ -0,0 +1,51 @@
+package nostr
+
+import "testing"
+
+func TestKeyValidation(t *testing.T) {
+	hexpk := "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"
+	npub := "npub180cvv07tjdrrgpa0j7j7tmnyl2yr6yr7l8j4s3evf6u64th6gkwsyjh6w6"
+
+	for _, valid := range []string{hexpk, npub, "0x" + hexpk, "  3BF0C63FCB93463407AF97A5E5EE64FA883D107EF9E558472C4EB9AAAEFA459D\n"} {
+		if !IsValidPublicKey(valid) {
+			t.Errorf("%q should be a valid public key", valid)
+		}
+	}
+	for _, invalid := range []string{"", hexpk[2:], "zz" + hexpk[2:], npub[:len(npub)-1] + "x",
+		// x coordinate that isn't on the curve
+		"0000000000000000000000000000000000000000000000000000000000000005"} {
+		if IsValidPublicKey(invalid) {
+			t.Errorf("%q shouldn't be a valid public key", invalid)
+		}
+	}
+
+	if converted, _ := PublicKeyToNpub(hexpk); converted != npub {
+		t.Errorf("wrong npub %s", converted)
+	}
+	if converted, _ := NpubToPublicKey(npub); converted != hexpk {
+		t.Errorf("wrong hex %s", converted)
+	}
+
+	sk := "0000000000000000000000000000000000000000000000000000000000000001"
+	nsec, err := PrivateKeyToNsec(sk)
+	if err != nil {
+		t.Fatalf("failed to encode nsec: %s", err)
+	}
+	if back, _ := NsecToPrivateKey(nsec); back != sk {
+		t.Errorf("nsec round trip failed: %s", back)
+	}
+	for _, invalid := range []string{
+		"0000000000000000000000000000000000000000000000000000000000000000",
+		"fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141", // curve order
+		"1234",
+	} {
+		if IsValidPrivateKey(invalid) {
+			t.Errorf("%q shouldn't be a valid private key", invalid)
+		}
+	}
+	for i := 0; i < 300; i++ {
+		if sk := GeneratePrivateKey(); !IsValidPrivateKey(sk) || len(sk) != 64 {
+			t.Fatalf("generated invalid private key %s", sk)
+		}
+	}
+}
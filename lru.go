@@ -0,0 +1,62 @@
+package nostr
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lru is a small thread-safe least-recently-used cache.
+type lru[K comparable, V any] struct {
+	mutex    sync.Mutex
+	capacity int
+	items    map[K]*list.Element
+	order    *list.List
+}
+
+type lruEntry[K comparable, V any] struct {
+	key   K
+	value V
+}
+
+func newLRU[K comparable, V any](capacity int) *lru[K, V] {
+	return &lru[K, V]{
+		capacity: capacity,
+		items:    make(map[K]*list.Element, capacity),
+		order:    list.New(),
+	}
+}
+
+func (c *lru[K, V]) Get(key K) (value V, ok bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if el, exists := c.items[key]; exists {
+		c.order.MoveToFront(el)
+		return el.Value.(*lruEntry[K, V]).value, true
+	}
+	return value, false
+}
+
+func (c *lru[K, V]) Add(key K, value V) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if el, exists := c.items[key]; exists {
+		el.Value.(*lruEntry[K, V]).value = value
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&lruEntry[K, V]{key, value})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry[K, V]).key)
+	}
+}
+
+func (c *lru[K, V]) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,21 @@
+package nostr
+
+import "testing"
+
+func TestLRUEviction(t *testing.T) {
+	c := newLRU[string, int](2)
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Get("a") // "b" is now the least recently used
+	c.Add("c", 3)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("b should have been evicted")
+	}
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Error("a should still be cached")
+	}
+	if c.Len() != 2 {
+		t.Errorf("cache has %d items, should have 2", c.Len())
+	}
+}
@@ -10,6 +10,8 @@ type ProfileMetadata struct {
 	About   string `json:"about,omitempty"`
 	Picture string `json:"picture,omitempty"`
 	NIP05   string `json:"nip05,omitempty"`
+	LUD06   string `json:"lud06,omitempty"` // bech32 LNURL
+	LUD16   string `json:"lud16,omitempty"` // lightning address
 }
 
 func ParseMetadata(event Event) (*ProfileMetadata, error) {
@@ -0,0 +1,143 @@
+package nostr
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// how long a fetch shared by concurrent Profile calls can take, it isn't bound to the context of
+// any of them so one giving up doesn't make the others fail
+const profileFetchTimeout = 10 * time.Second
+
+// MetadataResolver answers which profile metadata a user has, keeping it in memory for TTL,
+// so clients can ask for it every time they render a name without flooding relays.
+//
+// Concurrent requests for the same user while it is being fetched share the same fetch.
+// OnChange is called when a newer kind-0 event replaces the one we had, either when the
+// metadata is fetched again after TTL or when one is given to Update.
+type MetadataResolver struct {
+	Client *Client
+	TTL    time.Duration
+
+	OnChange func(pk PubKey, meta *ProfileMetadata)
+
+	mutex    sync.Mutex
+	profiles map[PubKey]*resolvedProfile
+	inflight map[PubKey]*profileCall
+}
+
+type resolvedProfile struct {
+	event     *Event
+	meta      *ProfileMetadata
+	fetchedAt time.Time
+}
+
+type profileCall struct {
+	done chan struct{}
+	meta *ProfileMetadata
+	err  error
+}
+
+func NewMetadataResolver(client *Client, ttl time.Duration) *MetadataResolver {
+	return &MetadataResolver{
+		Client:   client,
+		TTL:      ttl,
+		profiles: make(map[PubKey]*resolvedProfile),
+		inflight: make(map[PubKey]*profileCall),
+	}
+}
+
+// Profile returns the metadata of pk, from memory if it was fetched less than TTL ago,
+// otherwise from the Client's Store the first time and from relays afterwards.
+func (mr *MetadataResolver) Profile(ctx context.Context, pk PubKey) (*ProfileMetadata, error) {
+	mr.mutex.Lock()
+	if profile, ok := mr.profiles[pk]; ok && Now().Sub(profile.fetchedAt) < mr.TTL {
+		mr.mutex.Unlock()
+		return profile.meta, nil
+	}
+	if call, ok := mr.inflight[pk]; ok {
+		mr.mutex.Unlock()
+		select {
+		case <-call.done:
+			return call.meta, call.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	call := &profileCall{done: make(chan struct{})}
+	mr.inflight[pk] = call
+	_, known := mr.profiles[pk]
+	mr.mutex.Unlock()
+
+	go mr.fetch(pk, known, call)
+	select {
+	case <-call.done:
+		return call.meta, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// fetch gets the metadata of pk for everybody waiting on call.
+func (mr *MetadataResolver) fetch(pk PubKey, known bool, call *profileCall) {
+	ctx, cancel := context.WithTimeout(context.Background(), profileFetchTimeout)
+	defer cancel()
+
+	var evt *Event
+	if known {
+		// the Store would give us the same we have
+		evt = mr.Client.fetchLatestFromRelays(ctx, pk, KindSetMetadata, true)
+	} else {
+		evt = mr.Client.fetchLatest(ctx, pk, KindSetMetadata, true)
+	}
+
+	mr.mutex.Lock()
+	delete(mr.inflight, pk)
+	mr.mutex.Unlock()
+
+	if evt != nil {
+		mr.Update(evt)
+	}
+
+	mr.mutex.Lock()
+	if profile, ok := mr.profiles[pk]; ok {
+		profile.fetchedAt = Now()
+		call.meta = profile.meta
+	} else {
+		call.err = fmt.Errorf("no metadata found for %s", pk)
+	}
+	mr.mutex.Unlock()
+
+	close(call.done)
+}
+
+// Update gives the resolver a kind-0 event found elsewhere, e.g. in a subscription. It returns
+// true if it was newer than the one we had, in which case OnChange is called.
+func (mr *MetadataResolver) Update(evt *Event) bool {
+	if evt.Kind != KindSetMetadata {
+		return false
+	}
+	meta, err := ParseMetadata(*evt)
+	if err != nil {
+		return false
+	}
+
+	mr.mutex.Lock()
+	current, known := mr.profiles[evt.PubKey]
+	if known && !evt.IsNewerThan(current.event) {
+		mr.mutex.Unlock()
+		return false
+	}
+	mr.profiles[evt.PubKey] = &resolvedProfile{event: evt, meta: meta, fetchedAt: Now()}
+	mr.mutex.Unlock()
+
+	if mr.Client.Store != nil {
+		mr.Client.Store.SaveEvent(context.Background(), evt)
+	}
+	if known && mr.OnChange != nil {
+		mr.OnChange(evt.PubKey, meta)
+	}
+	return true
+}
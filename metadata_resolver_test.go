@@ -0,0 +1,92 @@
+package nostr
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMetadataResolver(t *testing.T) {
+	signer := newTestSigner(t)
+	older := Event{Kind: KindSetMetadata, CreatedAt: time.Unix(1672068534, 0), Content: `{"name":"old"}`}
+	signer.SignEvent(context.Background(), &older)
+
+	fr := &fakeRelay{events: []Event{older}}
+	ws := newWebsocketServer(fr.handle)
+	defer ws.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client := NewClient(ctx, nil, NewMemoryStore(), []string{ws.URL})
+	defer client.Close()
+
+	resolver := NewMetadataResolver(client, 200*time.Millisecond)
+	changes := make(chan *ProfileMetadata, 1)
+	resolver.OnChange = func(pk PubKey, meta *ProfileMetadata) { changes <- meta }
+
+	// concurrent requests share a single fetch
+	wg := sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			meta, err := resolver.Profile(ctx, signer.pk)
+			if err != nil || meta.Name != "old" {
+				t.Errorf("wrong profile %v: %v", meta, err)
+			}
+		}()
+	}
+	wg.Wait()
+	fr.mu.Lock()
+	reqs := fr.reqs
+	fr.mu.Unlock()
+	if reqs != 2 {
+		t.Fatalf("expected one REQ for the relay list and one for the profile, got %d", reqs)
+	}
+
+	// cached
+	resolver.Profile(ctx, signer.pk)
+	fr.mu.Lock()
+	if fr.reqs != reqs {
+		t.Fatalf("profile was fetched again before the ttl")
+	}
+	newer := Event{Kind: KindSetMetadata, CreatedAt: time.Unix(1672068600, 0), Content: `{"name":"new"}`}
+	signer.SignEvent(ctx, &newer)
+	fr.events = append(fr.events, newer)
+	fr.mu.Unlock()
+
+	time.Sleep(300 * time.Millisecond)
+	meta, err := resolver.Profile(ctx, signer.pk)
+	if err != nil || meta.Name != "new" {
+		t.Fatalf("expected the newer profile, got %v: %v", meta, err)
+	}
+	select {
+	case meta := <-changes:
+		if meta.Name != "new" {
+			t.Fatalf("wrong change %v", meta)
+		}
+	default:
+		t.Fatal("OnChange wasn't called")
+	}
+
+	if resolver.Update(&older) {
+		t.Fatal("older event replaced the newer one")
+	}
+	note := Event{Kind: KindTextNote, CreatedAt: time.Unix(1672068700, 0), Content: `{"name":"note"}`}
+	signer.SignEvent(ctx, &note)
+	if resolver.Update(&note) {
+		t.Fatal("a kind-1 event was taken as metadata")
+	}
+
+	// a caller giving up doesn't make the others fail
+	_, stranger := makeKeyPair(t)
+	canceled, cancelNow := context.WithCancel(ctx)
+	cancelNow()
+	if _, err := resolver.Profile(canceled, stranger); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if _, err := resolver.Profile(ctx, stranger); err == nil || err == context.Canceled {
+		t.Fatalf("expected the shared fetch to find nothing, got %v", err)
+	}
+}
@@ -0,0 +1,233 @@
+// Package metrics counts what relays do and serves it in the Prometheus text format, so
+// services using this library can be scraped without any more code:
+//
+//	collector := metrics.New()
+//	nostr.DefaultTracer = collector // or nostr.WithTracer(collector) for some relays
+//	collector.Watch(relay)          // for the connection and subscription gauges
+//	http.Handle("/metrics", collector)
+//
+// Built with the "prometheus" tag, Collector is also a prometheus.Collector, so it can be
+// registered along with the other metrics of a service:
+//
+//	prometheus.MustRegister(collector)
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+var _ nostr.Tracer = (*Collector)(nil)
+
+// Collector is a nostr.Tracer that counts, for each relay, the events received and published,
+// the publish results, the connections and reconnections. The relays given to Watch also get
+// gauges for their connection state and number of subscriptions.
+type Collector struct {
+	// Next, if set, gets all the spans too, to keep tracing them somewhere else.
+	Next nostr.Tracer
+
+	mutex   sync.Mutex
+	relays  map[string]*relayMetrics
+	watched map[string]*nostr.Relay
+}
+
+type relayMetrics struct {
+	eventsReceived   uint64
+	published        map[string]uint64 // by status
+	connections      uint64
+	connectionErrors uint64
+	reconnections    uint64
+}
+
+func New() *Collector {
+	return &Collector{
+		relays:  make(map[string]*relayMetrics),
+		watched: make(map[string]*nostr.Relay),
+	}
+}
+
+// Watch adds gauges for the connection state and number of open subscriptions of relays.
+func (c *Collector) Watch(relays ...*nostr.Relay) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, relay := range relays {
+		c.watched[relay.URL] = relay
+	}
+}
+
+func (c *Collector) Start(ctx context.Context, name string, attrs ...nostr.Attribute) (context.Context, nostr.Span) {
+	s := &span{collector: c, name: name}
+	if c.Next != nil {
+		ctx, s.next = c.Next.Start(ctx, name, attrs...)
+	}
+	s.SetAttributes(attrs...)
+	return ctx, s
+}
+
+type span struct {
+	collector *Collector
+	next      nostr.Span
+	name      string
+	relay     string
+	status    string
+	failed    bool
+}
+
+func (s *span) SetAttributes(attrs ...nostr.Attribute) {
+	for _, attr := range attrs {
+		switch attr.Key {
+		case "nostr.relay.url":
+			s.relay, _ = attr.Value.(string)
+		case "nostr.publish.status":
+			s.status, _ = attr.Value.(string)
+		}
+	}
+	if s.next != nil {
+		s.next.SetAttributes(attrs...)
+	}
+}
+
+func (s *span) RecordError(err error) {
+	s.failed = true
+	if s.next != nil {
+		s.next.RecordError(err)
+	}
+}
+
+func (s *span) End() {
+	s.collector.record(s)
+	if s.next != nil {
+		s.next.End()
+	}
+}
+
+func (c *Collector) record(s *span) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	m, ok := c.relays[s.relay]
+	if !ok {
+		m = &relayMetrics{published: make(map[string]uint64)}
+		c.relays[s.relay] = m
+	}
+	switch s.name {
+	case "nostr.ReceiveEvent":
+		m.eventsReceived++
+	case "nostr.Publish":
+		m.published[s.status]++
+	case "nostr.Connect":
+		if s.failed {
+			m.connectionErrors++
+		} else {
+			m.connections++
+		}
+	case "nostr.Reconnect":
+		m.reconnections++
+	}
+}
+
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.WriteTo(w)
+}
+
+// family is a metric with its samples, by label values.
+type family struct {
+	name    string
+	typ     string // "counter" or "gauge"
+	help    string
+	labels  []string
+	samples []sample
+}
+
+type sample struct {
+	labelValues []string
+	value       float64
+}
+
+// families returns the current value of all the metrics, in a stable order.
+func (c *Collector) families() []family {
+	counters := []family{
+		{name: "nostr_relay_events_received_total", typ: "counter", help: "Events received from the relay.", labels: []string{"relay"}},
+		{name: "nostr_relay_events_published_total", typ: "counter", help: "Events published to the relay, by result.", labels: []string{"relay", "status"}},
+		{name: "nostr_relay_connections_total", typ: "counter", help: "Successful connections to the relay.", labels: []string{"relay"}},
+		{name: "nostr_relay_connection_errors_total", typ: "counter", help: "Failed connections to the relay.", labels: []string{"relay"}},
+		{name: "nostr_relay_reconnections_total", typ: "counter", help: "Times the connection to the relay dropped and came back.", labels: []string{"relay"}},
+	}
+	gauges := []family{
+		{name: "nostr_relay_connected", typ: "gauge", help: "1 if the connection to the relay is up.", labels: []string{"relay"}},
+		{name: "nostr_relay_subscriptions", typ: "gauge", help: "Subscriptions open in the relay.", labels: []string{"relay"}},
+	}
+
+	c.mutex.Lock()
+	urls := make([]string, 0, len(c.relays))
+	for url := range c.relays {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+	for _, url := range urls {
+		m := c.relays[url]
+		counters[0].samples = append(counters[0].samples, sample{[]string{url}, float64(m.eventsReceived)})
+		statuses := make([]string, 0, len(m.published))
+		for status := range m.published {
+			statuses = append(statuses, status)
+		}
+		sort.Strings(statuses)
+		for _, status := range statuses {
+			counters[1].samples = append(counters[1].samples, sample{[]string{url, status}, float64(m.published[status])})
+		}
+		counters[2].samples = append(counters[2].samples, sample{[]string{url}, float64(m.connections)})
+		counters[3].samples = append(counters[3].samples, sample{[]string{url}, float64(m.connectionErrors)})
+		counters[4].samples = append(counters[4].samples, sample{[]string{url}, float64(m.reconnections)})
+	}
+
+	watched := make([]*nostr.Relay, 0, len(c.watched))
+	for _, relay := range c.watched {
+		watched = append(watched, relay)
+	}
+	c.mutex.Unlock()
+
+	// the relays have their own locks, don't hold ours while asking them
+	sort.Slice(watched, func(i, j int) bool { return watched[i].URL < watched[j].URL })
+	for _, relay := range watched {
+		connected := 0.0
+		if relay.IsConnected() {
+			connected = 1
+		}
+		gauges[0].samples = append(gauges[0].samples, sample{[]string{relay.URL}, connected})
+		gauges[1].samples = append(gauges[1].samples, sample{[]string{relay.URL}, float64(relay.SubscriptionCount())})
+	}
+
+	return append(counters, gauges...)
+}
+
+// WriteTo writes all the metrics to w in the Prometheus text format.
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	b := &strings.Builder{}
+	for _, f := range c.families() {
+		fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.typ)
+		for _, s := range f.samples {
+			labels := make([]string, len(f.labels))
+			for i, label := range f.labels {
+				labels[i] = label + "=" + quote(s.labelValues[i])
+			}
+			fmt.Fprintf(b, "%s{%s} %s\n", f.name, strings.Join(labels, ","), strconv.FormatFloat(s.value, 'f', -1, 64))
+		}
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// quote escapes a label value as the text format requires.
+func quote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value) + `"`
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/server"
+)
+
+func TestCollector(t *testing.T) {
+	ts := httptest.NewServer(server.New(nil))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	collector := New()
+	relay, err := nostr.RelayConnect(ctx, "ws"+strings.TrimPrefix(ts.URL, "http"), nostr.WithTracer(collector))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer relay.Close()
+	collector.Watch(relay)
+
+	sk := nostr.GeneratePrivateKey()
+	pk, _ := nostr.GetPublicKey(sk)
+	evt := nostr.Event{Kind: 1, PubKey: nostr.MustPubKeyFromHex(pk), CreatedAt: time.Now(), Content: "counted"}
+	evt.Sign(sk)
+	if _, err := relay.Publish(ctx, evt); err != nil {
+		t.Fatal(err)
+	}
+	sub, err := relay.Sub(ctx, nostr.Filters{{Kinds: []int{1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-sub.Events
+	<-sub.EndOfStoredEvents
+
+	// the subscription Publish uses to confirm the event is closed in the background
+	for relay.SubscriptionCount() > 1 && ctx.Err() == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	rec := httptest.NewRecorder()
+	collector.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+
+	label := `{relay="` + relay.URL + `"`
+	for _, line := range []string{
+		"# TYPE nostr_relay_events_received_total counter",
+		"nostr_relay_events_received_total" + label + "} 2", // by Publish and by our subscription
+		"nostr_relay_events_published_total" + label + `,status="success"} 1`,
+		"nostr_relay_connections_total" + label + "} 1",
+		"nostr_relay_connected" + label + "} 1",
+		"nostr_relay_subscriptions" + label + "} 1",
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("missing %q in:\n%s", line, body)
+		}
+	}
+}
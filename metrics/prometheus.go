@@ -0,0 +1,32 @@
+//go:build prometheus
+
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var _ prometheus.Collector = (*Collector)(nil)
+
+// Describe sends the descriptions of all the metrics, as prometheus.Collector requires.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, f := range c.families() {
+		ch <- f.desc()
+	}
+}
+
+// Collect sends the current value of all the metrics, as prometheus.Collector requires.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, f := range c.families() {
+		desc := f.desc()
+		valueType := prometheus.CounterValue
+		if f.typ == "gauge" {
+			valueType = prometheus.GaugeValue
+		}
+		for _, s := range f.samples {
+			ch <- prometheus.MustNewConstMetric(desc, valueType, s.value, s.labelValues...)
+		}
+	}
+}
+
+func (f family) desc() *prometheus.Desc {
+	return prometheus.NewDesc(f.name, f.help, f.labels, nil)
+}
@@ -0,0 +1,30 @@
+//go:build prometheus
+
+package metrics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPrometheusCollector(t *testing.T) {
+	collector := New()
+	for _, status := range []string{"success", "success", "failed"} {
+		_, span := collector.Start(context.Background(), "nostr.Publish",
+			nostr.Attribute{Key: "nostr.relay.url", Value: "wss://relay.example.com"},
+			nostr.Attribute{Key: "nostr.publish.status", Value: status})
+		span.End()
+	}
+
+	registry := prometheus.NewPedanticRegistry()
+	if err := registry.Register(collector); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := testutil.GatherAndCount(registry, "nostr_relay_events_published_total"); err != nil || n != 2 {
+		t.Fatalf("expected 2 samples, got %d, %v", n, err)
+	}
+}
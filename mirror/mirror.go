@@ -0,0 +1,163 @@
+// Package mirror copies events matching a filter from some relays to others, which is
+// the core of aggregation and backup bots.
+package mirror
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Mirror subscribes to Filter on Sources and republishes what it gets to Destinations.
+//
+// Events found on more than one source are only published once. When Cursors is set, the
+// created_at of the newest event a destination accepted is saved there, so a restarted Mirror
+// continues from where it stopped. Events are read from the sources only as fast as they can be
+// published, with at most QueueSize of them waiting, so slow destinations don't make
+// memory grow.
+type Mirror struct {
+	Pool         *nostr.SimplePool
+	Sources      []string
+	Destinations []string
+	Filter       nostr.Filter
+	Cursors      nostr.CursorStore // optional
+	QueueSize    int
+
+	// OnPublished is called after each event is published with the destinations that accepted it.
+	OnPublished func(evt *nostr.Event, accepted []string)
+}
+
+func New(pool *nostr.SimplePool, sources []string, destinations []string, filter nostr.Filter) *Mirror {
+	return &Mirror{
+		Pool:         pool,
+		Sources:      sources,
+		Destinations: destinations,
+		Filter:       filter,
+		QueueSize:    100,
+	}
+}
+
+// Run copies the stored events, and then new ones as they come, until ctx is canceled.
+func (m *Mirror) Run(ctx context.Context) error {
+	key := m.cursorKey()
+	filter := m.Filter
+	if m.Cursors != nil {
+		if cursor, ok := m.Cursors.LoadCursor(key); ok && (filter.Since == nil || cursor.After(*filter.Since)) {
+			filter.Since = &cursor
+		}
+	}
+
+	// like in sse, stored events are everything before now and new ones everything after
+	start := nostr.Now()
+	stored := filter
+	if stored.Until == nil || stored.Until.After(start) {
+		stored.Until = &start
+	}
+	// events created in the same second as start may come in both phases
+	boundary := make(map[nostr.ID]struct{})
+	latest, err := m.copy(ctx, m.Pool.SubManyEose(ctx, m.Sources, nostr.Filters{stored}), func(evt *nostr.Event) bool {
+		if evt.CreatedAt.Unix() == start.Unix() {
+			boundary[evt.ID] = struct{}{}
+		}
+		return true
+	}, nil)
+	if err != nil {
+		return err
+	}
+	// we only know everything before start was copied now that all stored events are done
+	if m.Cursors != nil && !latest.IsZero() {
+		m.Cursors.SaveCursor(key, latest)
+	}
+
+	if filter.Until != nil && filter.Until.Before(start) {
+		return nil
+	}
+	live := filter
+	live.Since = &start
+	live.Limit = 0
+	_, err = m.copy(ctx, m.Pool.SubMany(ctx, m.Sources, nostr.Filters{live}), func(evt *nostr.Event) bool {
+		_, seen := boundary[evt.ID]
+		return !seen
+	}, func(evt *nostr.Event) {
+		if m.Cursors != nil {
+			m.Cursors.SaveCursor(key, evt.CreatedAt)
+		}
+	})
+	return err
+}
+
+// copy publishes the events from the channel for which accept returns true, calling published
+// for every one accepted by some destination that is newer than the ones before, and returns
+// the newest created_at of those.
+func (m *Mirror) copy(
+	ctx context.Context,
+	events chan *nostr.Event,
+	accept func(*nostr.Event) bool,
+	published func(*nostr.Event),
+) (time.Time, error) {
+	queue := make(chan *nostr.Event, m.QueueSize)
+	go func() {
+		defer close(queue)
+		for evt := range events {
+			select {
+			case queue <- evt:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var latest time.Time
+	for evt := range queue {
+		if !accept(evt) {
+			continue
+		}
+		accepted := m.publish(ctx, evt)
+		// events nobody took must be tried again next time
+		if len(accepted) > 0 && evt.CreatedAt.After(latest) {
+			latest = evt.CreatedAt
+			if published != nil {
+				published(evt)
+			}
+		}
+		if m.OnPublished != nil {
+			m.OnPublished(evt, accepted)
+		}
+	}
+	return latest, ctx.Err()
+}
+
+func (m *Mirror) publish(ctx context.Context, evt *nostr.Event) []string {
+	var mu sync.Mutex
+	var accepted []string
+	wg := sync.WaitGroup{}
+	for _, url := range m.Destinations {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			relay, err := m.Pool.EnsureRelay(url)
+			if err != nil {
+				return
+			}
+			if status, _ := relay.Publish(ctx, *evt); status == nostr.PublishStatusSucceeded {
+				mu.Lock()
+				accepted = append(accepted, url)
+				mu.Unlock()
+			}
+		}(url)
+	}
+	wg.Wait()
+	return accepted
+}
+
+// cursorKey identifies this mirror, so the same filter copied between other relays has its own cursor.
+func (m *Mirror) cursorKey() string {
+	j, _ := json.Marshal([]any{m.Sources, m.Destinations, nostr.FilterKey(m.Filter)})
+	h := sha256.Sum256(j)
+	return "mirror:" + hex.EncodeToString(h[:])
+}
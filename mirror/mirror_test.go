@@ -0,0 +1,177 @@
+package mirror
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// relay is a minimal relay that answers REQs from its events and stores what it receives.
+type relay struct {
+	mu     sync.Mutex
+	events []*nostr.Event
+	url    string
+	reject bool // refuse every EVENT
+}
+
+func newRelay(t *testing.T, events ...*nostr.Event) *relay {
+	r := &relay{events: events}
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		conn, err := upgrader.Upgrade(w, req, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			var msg []json.RawMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			var label string
+			json.Unmarshal(msg[0], &label)
+			switch label {
+			case "EVENT":
+				var evt nostr.Event
+				json.Unmarshal(msg[1], &evt)
+				r.mu.Lock()
+				if r.reject {
+					r.mu.Unlock()
+					conn.WriteJSON([]any{"OK", evt.ID.Hex(), false, "blocked: no"})
+					continue
+				}
+				r.events = append(r.events, &evt)
+				r.mu.Unlock()
+				conn.WriteJSON([]any{"OK", evt.ID.Hex(), true, ""})
+			case "REQ":
+				var id string
+				json.Unmarshal(msg[1], &id)
+				var filter nostr.Filter
+				json.Unmarshal(msg[2], &filter)
+				r.mu.Lock()
+				for _, evt := range r.events {
+					if filter.Matches(evt) {
+						conn.WriteJSON([]any{"EVENT", id, evt})
+					}
+				}
+				r.mu.Unlock()
+				conn.WriteJSON([]any{"EOSE", id})
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	r.url = "ws" + strings.TrimPrefix(server.URL, "http")
+	return r
+}
+
+func (r *relay) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.events)
+}
+
+func TestMirror(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	pk, _ := nostr.GetPublicKey(sk)
+	var events []*nostr.Event
+	for i := 0; i < 4; i++ {
+		evt := &nostr.Event{PubKey: nostr.MustPubKeyFromHex(pk), CreatedAt: time.Unix(int64(1000+i*10), 0), Kind: 1 + i%2, Content: "hello"}
+		evt.Sign(sk)
+		events = append(events, evt)
+	}
+
+	// both sources have the event at 1020, which should only be copied once
+	source1 := newRelay(t, events[0], events[1], events[2])
+	source2 := newRelay(t, events[2], events[3])
+	destination := newRelay(t)
+	cursors := nostr.NewMemoryCursorStore()
+
+	run := func(expected int) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		pool := nostr.NewSimplePool(ctx)
+		defer pool.Close()
+		m := New(pool, []string{source1.url, source2.url}, []string{destination.url}, nostr.Filter{Kinds: []int{1}})
+		m.Cursors = cursors
+		m.QueueSize = 1
+
+		published := 0
+		m.OnPublished = func(evt *nostr.Event, accepted []string) {
+			if len(accepted) != 1 {
+				t.Errorf("event %s wasn't accepted", evt.ID)
+			}
+			if published++; published == expected {
+				// wait for the cursor to be saved after EOSE
+				time.AfterFunc(100*time.Millisecond, cancel)
+			}
+		}
+		m.Run(ctx)
+		if published != expected {
+			t.Fatalf("expected %d events published, got %d", expected, published)
+		}
+	}
+
+	run(2)
+	if destination.count() != 2 {
+		t.Fatalf("destination has %d events", destination.count())
+	}
+
+	// the next run starts from the cursor, the newest event copied before and the new one
+	source1.mu.Lock()
+	newer := &nostr.Event{PubKey: nostr.MustPubKeyFromHex(pk), CreatedAt: time.Unix(1100, 0), Kind: 1}
+	newer.Sign(sk)
+	source1.events = append(source1.events, newer)
+	source1.mu.Unlock()
+
+	run(2)
+	if destination.count() != 4 {
+		t.Fatalf("destination has %d events", destination.count())
+	}
+}
+
+func TestMirrorCursorOnlyAdvancesWhenAccepted(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	pk, _ := nostr.GetPublicKey(sk)
+	evt := &nostr.Event{PubKey: nostr.MustPubKeyFromHex(pk), CreatedAt: time.Unix(1000, 0), Kind: 1, Content: "hello"}
+	evt.Sign(sk)
+
+	source := newRelay(t, evt)
+	destination := newRelay(t)
+	destination.reject = true
+	cursors := nostr.NewMemoryCursorStore()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	pool := nostr.NewSimplePool(ctx)
+	defer pool.Close()
+	m := New(pool, []string{source.url}, []string{destination.url}, nostr.Filter{Kinds: []int{1}})
+	m.Cursors = cursors
+	m.OnPublished = func(*nostr.Event, []string) { time.AfterFunc(100*time.Millisecond, cancel) }
+	m.Run(ctx)
+
+	if cursor, ok := cursors.LoadCursor(m.cursorKey()); ok {
+		t.Fatalf("cursor moved to %s though the event was rejected", cursor)
+	}
+}
+
+func TestCursorKeyIsStable(t *testing.T) {
+	filter := nostr.Filter{Kinds: []int{1}, Tags: nostr.TagMap{"t": {"nostr"}, "p": {"a", "b"}, "e": {"c"}}}
+	key := New(nil, []string{"wss://a.com"}, []string{"wss://b.com"}, filter).cursorKey()
+	for i := 0; i < 50; i++ {
+		if New(nil, []string{"wss://a.com"}, []string{"wss://b.com"}, filter).cursorKey() != key {
+			t.Fatal("cursor key changed between runs")
+		}
+	}
+	if New(nil, []string{"wss://b.com"}, []string{"wss://a.com"}, filter).cursorKey() == key {
+		t.Error("mirroring the other way should have its own cursor")
+	}
+}
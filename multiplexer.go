@@ -0,0 +1,283 @@
+package nostr
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Multiplexer lets many parts of an application subscribe to the same relay while only using
+// one REQ for all of them, for relays that limit how many subscriptions each client can have.
+//
+// The filters of all the subscriptions are merged and events are dispatched back to the
+// subscriptions whose filters they match. Whenever subscriptions are added the REQ is replaced
+// by a new one, after waiting Delay so many subscriptions made at once cause a single REQ.
+// Subscriptions that already got their stored events only ask for new ones in the new REQ.
+type Multiplexer struct {
+	Relay *Relay
+	Delay time.Duration
+
+	mutex       sync.Mutex
+	subscribers map[*MuxSubscription]struct{}
+	cancel      context.CancelFunc // of the current REQ
+	dispatching chan struct{}      // closed once the events of the current REQ stop being dispatched
+	pending     bool
+}
+
+// MuxSubscription is a subscription made through a Multiplexer. It works like Subscription:
+// Events is closed when Context is canceled.
+type MuxSubscription struct {
+	Filters           Filters
+	Events            chan *Event
+	EndOfStoredEvents chan struct{}
+	Context           context.Context
+
+	mutex    sync.Mutex // held while sending events, so they aren't sent after Events is closed
+	closed   bool
+	live     int32 // set once it got its stored events, read without the mutex as sends can block
+	seen     map[ID]struct{}
+	emitEose sync.Once
+}
+
+func NewMultiplexer(relay *Relay) *Multiplexer {
+	return &Multiplexer{
+		Relay:       relay,
+		Delay:       50 * time.Millisecond,
+		subscribers: make(map[*MuxSubscription]struct{}),
+	}
+}
+
+// Subscribe adds a subscription to the shared REQ. It ends when ctx is canceled.
+func (m *Multiplexer) Subscribe(ctx context.Context, filters Filters) *MuxSubscription {
+	sub := &MuxSubscription{
+		Filters:           filters,
+		Events:            make(chan *Event),
+		EndOfStoredEvents: make(chan struct{}, 1),
+		Context:           ctx,
+		seen:              make(map[ID]struct{}),
+	}
+
+	m.mutex.Lock()
+	m.subscribers[sub] = struct{}{}
+	m.schedule()
+	m.mutex.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		m.remove(sub)
+	}()
+
+	return sub
+}
+
+// remove takes sub out and closes its channel. Its filters stay in the REQ until it is replaced,
+// unless it was the last subscription, in which case the REQ is closed.
+func (m *Multiplexer) remove(sub *MuxSubscription) {
+	m.mutex.Lock()
+	delete(m.subscribers, sub)
+	if len(m.subscribers) == 0 && m.cancel != nil {
+		m.cancel()
+		m.cancel = nil
+	}
+	m.mutex.Unlock()
+
+	// wait for events being sent to finish
+	sub.mutex.Lock()
+	sub.closed = true
+	close(sub.Events)
+	sub.mutex.Unlock()
+}
+
+// schedule replaces the REQ after the delay. Must be called with m.mutex held.
+func (m *Multiplexer) schedule() {
+	if m.pending {
+		return
+	}
+	m.pending = true
+	time.AfterFunc(m.Delay, m.refire)
+}
+
+// refire replaces the current REQ with one covering all the subscriptions.
+func (m *Multiplexer) refire() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.pending = false
+
+	subscribers := make([]*MuxSubscription, 0, len(m.subscribers))
+	var filters Filters
+	now := Now()
+	for sub := range m.subscribers {
+		subscribers = append(subscribers, sub)
+
+		live := atomic.LoadInt32(&sub.live) == 1
+		for _, filter := range sub.Filters {
+			if live {
+				// we already have the stored events, the previous REQ covers everything until now
+				if filter.Since == nil || filter.Since.Before(now) {
+					filter.Since = &now
+				}
+				filter.Limit = 0
+			}
+			filters = append(filters, filter)
+		}
+	}
+	if len(subscribers) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(m.Relay.ConnectionContext)
+	shared, err := m.Relay.Sub(ctx, MergeFilters(filters))
+	if err != nil {
+		cancel()
+		m.Relay.reportError(ErrorWrite, err)
+		return
+	}
+
+	// only close the previous REQ now that the new one is up, so nothing is missed, and only
+	// dispatch the new events once the previous ones are, so they don't race for the subscribers
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.cancel = cancel
+	previous := m.dispatching
+	done := make(chan struct{})
+	m.dispatching = done
+	go func() {
+		defer close(done)
+		if previous != nil {
+			<-previous
+		}
+		dispatch(shared, subscribers)
+	}()
+}
+
+// dispatch sends the events from the shared subscription to the subscribers they match, until
+// it is closed.
+func dispatch(shared *Subscription, subscribers []*MuxSubscription) {
+	eose := shared.EndOfStoredEvents
+	for {
+		select {
+		case <-shared.Context.Done():
+			return
+		case evt, ok := <-shared.Events:
+			if !ok {
+				return
+			}
+			for _, sub := range subscribers {
+				if sub.Filters.Match(evt) {
+					sub.deliver(evt)
+				}
+			}
+		case <-eose:
+			eose = nil
+			for _, sub := range subscribers {
+				sub.dispatchEose()
+			}
+		}
+	}
+}
+
+func (sub *MuxSubscription) deliver(evt *Event) {
+	sub.mutex.Lock()
+	defer sub.mutex.Unlock()
+	if sub.closed {
+		return
+	}
+
+	// events come again when the REQ is replaced
+	if _, ok := sub.seen[evt.ID]; ok {
+		return
+	}
+	sub.seen[evt.ID] = struct{}{}
+
+	select {
+	case sub.Events <- evt:
+	case <-sub.Context.Done():
+	}
+}
+
+func (sub *MuxSubscription) dispatchEose() {
+	sub.emitEose.Do(func() {
+		atomic.StoreInt32(&sub.live, 1)
+		sub.EndOfStoredEvents <- struct{}{}
+	})
+}
+
+// MergeFilters combines filters that only differ in their authors or ids into one, and drops
+// duplicates and filters contained in others, so fewer filters match the same events. Filters
+// with a limit are kept as they are, since merging them would change what they return.
+func MergeFilters(filters Filters) Filters {
+	filters = mergeOn(filters,
+		func(f Filter) bool { return len(f.Authors) > 0 },
+		func(f *Filter) { f.Authors = nil },
+		func(dst *Filter, src Filter) { dst.Authors = appendMissing(dst.Authors, src.Authors) },
+	)
+	filters = mergeOn(filters,
+		func(f Filter) bool { return len(f.IDs) > 0 },
+		func(f *Filter) { f.IDs = nil },
+		func(dst *Filter, src Filter) { dst.IDs = appendMissing(dst.IDs, src.IDs) },
+	)
+	return dropContained(filters)
+}
+
+// dropContained removes the filters that another one in filters already covers.
+func dropContained(filters Filters) Filters {
+	kept := make(Filters, 0, len(filters))
+	for i, filter := range filters {
+		redundant := false
+		for j, other := range filters {
+			// of two equal filters the first one is kept
+			if i != j && other.Contains(filter) && (j < i || !filter.Contains(other)) {
+				redundant = true
+				break
+			}
+		}
+		if !redundant {
+			kept = append(kept, filter)
+		}
+	}
+	return kept
+}
+
+// mergeOn merges the filters that have the field and are the same once it is cleared.
+func mergeOn(filters Filters, has func(Filter) bool, clear func(*Filter), merge func(*Filter, Filter)) Filters {
+	merged := make(Filters, 0, len(filters))
+	index := make(map[string]int)
+	for _, filter := range filters {
+		key := filter.String()
+		if filter.Limit == 0 && has(filter) {
+			rest := filter
+			clear(&rest)
+			key = "merge:" + rest.String()
+		}
+
+		if i, ok := index[key]; ok {
+			if filter.Limit == 0 && has(filter) {
+				merge(&merged[i], filter)
+			}
+			continue
+		}
+		index[key] = len(merged)
+		merged = append(merged, filter)
+	}
+	return merged
+}
+
+func appendMissing[T comparable](dst []T, src []T) []T {
+	// don't modify the array of the original filter
+	result := make([]T, len(dst), len(dst)+len(src))
+	copy(result, dst)
+
+	existing := make(map[T]struct{}, len(dst))
+	for _, item := range dst {
+		existing[item] = struct{}{}
+	}
+	for _, item := range src {
+		if _, ok := existing[item]; !ok {
+			existing[item] = struct{}{}
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,106 @@
+package nostr
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMultiplexer(t *testing.T) {
+	var events []Event
+	var authors []PubKey
+	for i := 0; i < 3; i++ {
+		sk, pk := makeKeyPair(t)
+		evt := Event{Kind: KindSetMetadata, PubKey: pk, CreatedAt: time.Unix(1700000000, 0), Content: "{}"}
+		evt.Sign(sk)
+		events = append(events, evt)
+		authors = append(authors, pk)
+	}
+
+	fr := &fakeRelay{events: events}
+	ws := newWebsocketServer(fr.handle)
+	defer ws.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	relay, err := RelayConnect(ctx, ws.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer relay.Close()
+
+	mux := NewMultiplexer(relay)
+	var subs []*MuxSubscription
+	for _, pk := range authors[0:2] {
+		subs = append(subs, mux.Subscribe(ctx, Filters{{Kinds: []int{KindSetMetadata}, Authors: []PubKey{pk}}}))
+	}
+
+	expectEvent := func(sub *MuxSubscription, id ID) {
+		select {
+		case evt := <-sub.Events:
+			if evt.ID != id {
+				t.Fatalf("got the wrong event %s", evt.ID)
+			}
+		case <-ctx.Done():
+			t.Fatal("didn't get event")
+		}
+	}
+	expectEose := func(sub *MuxSubscription) {
+		select {
+		case <-sub.EndOfStoredEvents:
+		case evt := <-sub.Events:
+			t.Fatalf("got unexpected event %s", evt.ID)
+		case <-ctx.Done():
+			t.Fatal("didn't get eose")
+		}
+	}
+	// events for all the subscriptions come before the eose
+	for i, sub := range subs {
+		expectEvent(sub, events[i].ID)
+	}
+	for _, sub := range subs {
+		expectEose(sub)
+	}
+
+	fr.mu.Lock()
+	if fr.reqs != 1 || len(fr.lastReq) != 1 || len(fr.lastReq[0].Authors) != 2 {
+		t.Fatalf("expected a single REQ with the authors merged, got %d: %v", fr.reqs, fr.lastReq)
+	}
+	fr.mu.Unlock()
+
+	// a new subscription replaces the REQ, the first ones only ask for new events now
+	third := mux.Subscribe(ctx, Filters{{Kinds: []int{KindSetMetadata}, Authors: authors[2:3]}})
+	expectEvent(third, events[2].ID)
+	expectEose(third)
+	fr.mu.Lock()
+	if fr.reqs != 2 || len(fr.lastReq) != 2 {
+		t.Fatalf("expected a second REQ with two filters, got %d: %v", fr.reqs, fr.lastReq)
+	}
+	fr.mu.Unlock()
+
+	select {
+	case evt := <-subs[0].Events:
+		t.Fatalf("old subscription got %s again", evt.ID)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestMergeFilters(t *testing.T) {
+	a, b, c := PubKey{1}, PubKey{2}, PubKey{3}
+	merged := MergeFilters(Filters{
+		{Kinds: []int{0}, Authors: []PubKey{a}},
+		{Kinds: []int{0}, Authors: []PubKey{b, a}},
+		{Kinds: []int{1}, Authors: []PubKey{c}},
+		{Kinds: []int{0}, Authors: []PubKey{c}, Limit: 1},
+		{Kinds: []int{1}, Authors: []PubKey{c}},
+		{IDs: []ID{{1}}},
+		{IDs: []ID{{2}}},
+		{Kinds: []int{1}, Authors: []PubKey{c}, Tags: TagMap{"t": {"x"}}}, // contained in the third
+	})
+	if len(merged) != 4 {
+		t.Fatalf("expected 4 filters, got %v", merged)
+	}
+	if len(merged[0].Authors) != 2 || len(merged[1].Authors) != 1 || merged[2].Limit != 1 || len(merged[3].IDs) != 2 {
+		t.Fatalf("wrong merge: %v", merged)
+	}
+}
@@ -0,0 +1,309 @@
+// Package musig2 allows a group of keyholders to jointly control a single Nostr identity
+// by aggregating their keys and partial signatures as in BIP-327 (MuSig2).
+//
+// The aggregated public key is a normal x-only key and the final signature is a normal
+// BIP-340 signature, so relays and clients can't tell the event was signed by many parties.
+//
+// The signing flow for an event whose PubKey is the output of AggregatePublicKey is:
+//
+//  1. each signer calls GenerateNonces and shares Nonces.Public with the others;
+//  2. anyone combines all public nonces with AggregateNonces;
+//  3. each signer calls PartialSign and shares the resulting partial signature;
+//  4. anyone calls CombineSignatures to set the event ID and Sig.
+package musig2
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	btcmusig2 "github.com/btcsuite/btcd/btcec/v2/schnorr/musig2"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+var (
+	ErrWrongPubKey      = errors.New("musig2: event pubkey is not the aggregate of the given keys")
+	ErrSignerNotInGroup = errors.New("musig2: private key doesn't belong to any of the given keys")
+	ErrNonceReused      = errors.New("musig2: secret nonce is empty, it was probably used already")
+)
+
+// Nonces are generated by each signer before each signing session.
+// Public must be sent to the other signers, Secret must be kept and never reused.
+type Nonces struct {
+	Public string
+	Secret string
+}
+
+// AggregatePublicKey combines the hex x-only public keys of all signers into the
+// hex x-only public key that must be used as the event pubkey.
+// The order of the keys doesn't matter.
+func AggregatePublicKey(pubkeys []string) (string, error) {
+	keys, err := parseKeys(pubkeys)
+	if err != nil {
+		return "", err
+	}
+
+	agg, _, _, err := btcmusig2.AggregateKeys(keys, true)
+	if err != nil {
+		return "", fmt.Errorf("failed to aggregate keys: %w", err)
+	}
+
+	return hex.EncodeToString(schnorr.SerializePubKey(agg.FinalKey)), nil
+}
+
+// GenerateNonces creates a fresh pair of nonces for one signing session.
+func GenerateNonces(privateKey string) (*Nonces, error) {
+	sk, err := parsePrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	nonces, err := btcmusig2.GenNonces(
+		btcmusig2.WithPublicKey(sk.PubKey()),
+		btcmusig2.WithNonceSecretKeyAux(sk),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate nonces: %w", err)
+	}
+
+	return &Nonces{
+		Public: hex.EncodeToString(nonces.PubNonce[:]),
+		Secret: hex.EncodeToString(nonces.SecNonce[:]),
+	}, nil
+}
+
+// AggregateNonces combines the public nonces of all signers into a single hex nonce
+// that must be given to PartialSign and CombineSignatures.
+func AggregateNonces(publicNonces []string) (string, error) {
+	nonces := make([][btcmusig2.PubNonceSize]byte, len(publicNonces))
+	for i, pn := range publicNonces {
+		if err := decodeFixed(nonces[i][:], pn); err != nil {
+			return "", fmt.Errorf("invalid public nonce '%s': %w", pn, err)
+		}
+	}
+
+	agg, err := btcmusig2.AggregateNonces(nonces)
+	if err != nil {
+		return "", fmt.Errorf("failed to aggregate nonces: %w", err)
+	}
+
+	return hex.EncodeToString(agg[:]), nil
+}
+
+// PartialSign produces this signer's hex partial signature for the event.
+// The event must be complete (except for ID and Sig) and have the aggregate key as its PubKey.
+// The secret nonce is wiped from nonces afterwards so it can't be accidentally reused.
+func PartialSign(evt *nostr.Event, privateKey string, pubkeys []string, nonces *Nonces, aggregatedNonce string) (string, error) {
+	sk, err := parsePrivateKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	keys, err := parseKeys(pubkeys)
+	if err != nil {
+		return "", err
+	}
+	if err := checkEventPubKey(evt, keys); err != nil {
+		return "", err
+	}
+
+	var secNonce [btcmusig2.SecNonceSize]byte
+	if nonces == nil || nonces.Secret == "" {
+		return "", ErrNonceReused
+	}
+	if err := decodeFixed(secNonce[:], nonces.Secret); err != nil {
+		return "", fmt.Errorf("invalid secret nonce: %w", err)
+	}
+	var aggNonce [btcmusig2.PubNonceSize]byte
+	if err := decodeFixed(aggNonce[:], aggregatedNonce); err != nil {
+		return "", fmt.Errorf("invalid aggregated nonce '%s': %w", aggregatedNonce, err)
+	}
+
+	sig, err := btcmusig2.Sign(secNonce, sk, aggNonce, keys, sha256.Sum256(evt.Serialize()),
+		btcmusig2.WithSortedKeys())
+	if err != nil {
+		if errors.Is(err, btcmusig2.ErrPubkeyNotIncluded) {
+			return "", ErrSignerNotInGroup
+		}
+		return "", fmt.Errorf("failed to sign: %w", err)
+	}
+	nonces.Secret = ""
+
+	buf := &bytes.Buffer{}
+	sig.Encode(buf)
+	return hex.EncodeToString(buf.Bytes()), nil
+}
+
+// VerifyPartialSignature checks the partial signature produced by the signer identified by
+// pubkey, given the public nonce that same signer had shared.
+func VerifyPartialSignature(evt *nostr.Event, partialSig string, pubkey string, publicNonce string, pubkeys []string, aggregatedNonce string) bool {
+	keys, err := parseKeys(pubkeys)
+	if err != nil {
+		return false
+	}
+	signer, err := parseKeys([]string{pubkey})
+	if err != nil {
+		return false
+	}
+
+	var pubNonce, aggNonce [btcmusig2.PubNonceSize]byte
+	if decodeFixed(pubNonce[:], publicNonce) != nil || decodeFixed(aggNonce[:], aggregatedNonce) != nil {
+		return false
+	}
+
+	sig, err := decodePartialSignature(partialSig)
+	if err != nil {
+		return false
+	}
+
+	return sig.Verify(pubNonce, aggNonce, keys, signer[0], sha256.Sum256(evt.Serialize()),
+		btcmusig2.WithSortedKeys())
+}
+
+// CombineSignatures aggregates the partial signatures of all signers into a final BIP-340
+// signature, setting the event ID and Sig. An error is returned if the result isn't valid.
+func CombineSignatures(evt *nostr.Event, pubkeys []string, aggregatedNonce string, partialSigs []string) error {
+	keys, err := parseKeys(pubkeys)
+	if err != nil {
+		return err
+	}
+	if err := checkEventPubKey(evt, keys); err != nil {
+		return err
+	}
+
+	var aggNonce [btcmusig2.PubNonceSize]byte
+	if err := decodeFixed(aggNonce[:], aggregatedNonce); err != nil {
+		return fmt.Errorf("invalid aggregated nonce '%s': %w", aggregatedNonce, err)
+	}
+
+	sigs := make([]*btcmusig2.PartialSignature, len(partialSigs))
+	for i, ps := range partialSigs {
+		sigs[i], err = decodePartialSignature(ps)
+		if err != nil {
+			return fmt.Errorf("invalid partial signature '%s': %w", ps, err)
+		}
+	}
+
+	h := sha256.Sum256(evt.Serialize())
+	agg, _, _, err := btcmusig2.AggregateKeys(keys, true)
+	if err != nil {
+		return fmt.Errorf("failed to aggregate keys: %w", err)
+	}
+	r, err := finalNonce(aggNonce, agg.FinalKey, h)
+	if err != nil {
+		return err
+	}
+
+	sig := btcmusig2.CombineSigs(r, sigs)
+	evt.ID = h
+	evt.Sig = hex.EncodeToString(sig.Serialize())
+
+	if ok, _ := evt.CheckSignature(); !ok {
+		return fmt.Errorf("musig2: combined signature is invalid")
+	}
+	return nil
+}
+
+// finalNonce computes R = R1 + b*R2 as in the MuSig2 signing algorithm, since the partial
+// signatures we exchange only carry their s value.
+func finalNonce(aggNonce [btcmusig2.PubNonceSize]byte, combinedKey *btcec.PublicKey, msg [32]byte) (*btcec.PublicKey, error) {
+	buf := &bytes.Buffer{}
+	buf.Write(aggNonce[:])
+	buf.Write(schnorr.SerializePubKey(combinedKey))
+	buf.Write(msg[:])
+	blindHash := chainhash.TaggedHash(btcmusig2.NonceBlindTag, buf.Bytes())
+	var b btcec.ModNScalar
+	b.SetByteSlice(blindHash[:])
+
+	r1, err := btcec.ParseJacobian(aggNonce[:btcec.PubKeyBytesLenCompressed])
+	if err != nil {
+		return nil, fmt.Errorf("invalid aggregated nonce: %w", err)
+	}
+	r2, err := btcec.ParseJacobian(aggNonce[btcec.PubKeyBytesLenCompressed:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid aggregated nonce: %w", err)
+	}
+
+	var r btcec.JacobianPoint
+	btcec.ScalarMultNonConst(&b, &r2, &r2)
+	btcec.AddNonConst(&r1, &r2, &r)
+	if r.X.IsZero() && r.Y.IsZero() && r.Z.IsZero() {
+		btcec.Generator().AsJacobian(&r)
+	}
+
+	r.ToAffine()
+	return btcec.NewPublicKey(&r.X, &r.Y), nil
+}
+
+func checkEventPubKey(evt *nostr.Event, keys []*btcec.PublicKey) error {
+	agg, _, _, err := btcmusig2.AggregateKeys(keys, true)
+	if err != nil {
+		return fmt.Errorf("failed to aggregate keys: %w", err)
+	}
+	var aggpk nostr.PubKey
+	copy(aggpk[:], schnorr.SerializePubKey(agg.FinalKey))
+	if evt.PubKey != aggpk {
+		return ErrWrongPubKey
+	}
+	return nil
+}
+
+// parseKeys turns x-only keys into points with even Y, which is what everybody else
+// will see when looking at a Nostr pubkey.
+func parseKeys(pubkeys []string) ([]*btcec.PublicKey, error) {
+	if len(pubkeys) == 0 {
+		return nil, fmt.Errorf("musig2: no public keys given")
+	}
+
+	keys := make([]*btcec.PublicKey, len(pubkeys))
+	for i, pk := range pubkeys {
+		b, err := hex.DecodeString(pk)
+		if err != nil {
+			return nil, fmt.Errorf("pubkey '%s' is invalid hex: %w", pk, err)
+		}
+		keys[i], err = schnorr.ParsePubKey(b)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pubkey '%s': %w", pk, err)
+		}
+	}
+	return keys, nil
+}
+
+// parsePrivateKey negates the key if needed so its public key has an even Y and
+// matches the x-only key the other signers know about.
+func parsePrivateKey(privateKey string) (*btcec.PrivateKey, error) {
+	s, err := hex.DecodeString(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key '%s': %w", privateKey, err)
+	}
+	sk, pk := btcec.PrivKeyFromBytes(s)
+	if pk.SerializeCompressed()[0] == 0x03 {
+		sk.Key.Negate()
+	}
+	return sk, nil
+}
+
+func decodePartialSignature(partialSig string) (*btcmusig2.PartialSignature, error) {
+	var s [32]byte
+	if err := decodeFixed(s[:], partialSig); err != nil {
+		return nil, err
+	}
+	sig := &btcmusig2.PartialSignature{}
+	if err := sig.Decode(bytes.NewReader(s[:])); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+func decodeFixed(dst []byte, h string) error {
+	if len(h) != len(dst)*2 {
+		return fmt.Errorf("expected %d bytes, got %d", len(dst), len(h)/2)
+	}
+	_, err := hex.Decode(dst, []byte(h))
+	return err
+}
@@ -0,0 +1,88 @@
+package musig2
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestMultiPartySigning(t *testing.T) {
+	// run a few times so we get keys with both odd and even Y
+	for round := 0; round < 8; round++ {
+		sks := make([]string, 3)
+		pks := make([]string, 3)
+		for i := range sks {
+			sks[i] = nostr.GeneratePrivateKey()
+			pks[i], _ = nostr.GetPublicKey(sks[i])
+		}
+
+		aggpk, err := AggregatePublicKey(pks)
+		if err != nil {
+			t.Fatalf("failed to aggregate keys: %s", err)
+		}
+		if reversed, _ := AggregatePublicKey([]string{pks[2], pks[1], pks[0]}); reversed != aggpk {
+			t.Fatalf("key order shouldn't matter: %s != %s", reversed, aggpk)
+		}
+
+		evt := nostr.Event{
+			PubKey:    nostr.MustPubKeyFromHex(aggpk),
+			CreatedAt: time.Unix(1672068534, 0),
+			Kind:      1,
+			Content:   "signed by a committee",
+		}
+
+		nonces := make([]*Nonces, len(sks))
+		publicNonces := make([]string, len(sks))
+		for i, sk := range sks {
+			nonces[i], err = GenerateNonces(sk)
+			if err != nil {
+				t.Fatalf("failed to generate nonces: %s", err)
+			}
+			publicNonces[i] = nonces[i].Public
+		}
+
+		aggNonce, err := AggregateNonces(publicNonces)
+		if err != nil {
+			t.Fatalf("failed to aggregate nonces: %s", err)
+		}
+
+		partials := make([]string, len(sks))
+		for i, sk := range sks {
+			partials[i], err = PartialSign(&evt, sk, pks, nonces[i], aggNonce)
+			if err != nil {
+				t.Fatalf("failed to partially sign: %s", err)
+			}
+			if !VerifyPartialSignature(&evt, partials[i], pks[i], publicNonces[i], pks, aggNonce) {
+				t.Fatalf("partial signature %d didn't verify", i)
+			}
+		}
+
+		if _, err := PartialSign(&evt, sks[0], pks, nonces[0], aggNonce); err != ErrNonceReused {
+			t.Fatalf("should have refused to reuse a nonce, got %v", err)
+		}
+
+		if err := CombineSignatures(&evt, pks, aggNonce, partials); err != nil {
+			t.Fatalf("failed to combine signatures: %s", err)
+		}
+		if ok, _ := evt.CheckSignature(); !ok {
+			t.Fatal("final signature is invalid")
+		}
+		if evt.ID != evt.GetID() {
+			t.Fatal("event id wasn't set")
+		}
+	}
+}
+
+func TestPartialSignWrongPubKey(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	pk, _ := nostr.GetPublicKey(sk)
+	other := nostr.GeneratePrivateKey()
+	otherpk, _ := nostr.GetPublicKey(other)
+
+	nonces, _ := GenerateNonces(sk)
+	evt := nostr.Event{PubKey: nostr.MustPubKeyFromHex(pk), Kind: 1, CreatedAt: time.Now()}
+	if _, err := PartialSign(&evt, sk, []string{pk, otherpk}, nonces, nonces.Public); err != ErrWrongPubKey {
+		t.Fatalf("expected ErrWrongPubKey, got %v", err)
+	}
+}
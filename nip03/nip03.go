@@ -0,0 +1,129 @@
+// Package nip03 implements kind-1040 OpenTimestamps attestations, which prove an event existed
+// at some point in time by committing its id to the Bitcoin blockchain.
+// See https://github.com/nostr-protocol/nips/blob/master/03.md for details.
+package nip03
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const KindOpenTimestamps = 1040
+
+// CreateAttestation returns an unsigned attestation for target, with proof being the contents
+// of the .ots file made by stamping the event id with an OpenTimestamps client. relay, if not
+// empty, is where target can be found. The proof must be for the event id and should already
+// have a Bitcoin attestation, see Proof.Pending.
+func CreateAttestation(target *nostr.Event, proof []byte, relay string) (nostr.Event, error) {
+	parsed, err := ParseProof(proof)
+	if err != nil {
+		return nostr.Event{}, err
+	}
+	if !bytes.Equal(parsed.Digest, target.ID[:]) {
+		return nostr.Event{}, fmt.Errorf("proof is for %x, not for event %s", parsed.Digest, target.ID)
+	}
+
+	return nostr.Event{
+		Kind:      KindOpenTimestamps,
+		CreatedAt: nostr.Now(),
+		Tags: nostr.Tags{
+			nostr.EventTag(target.ID, relay, ""),
+			{"k", strconv.Itoa(target.Kind)},
+		},
+		Content: base64.StdEncoding.EncodeToString(proof),
+	}, nil
+}
+
+// Attestation is a parsed kind-1040 event.
+type Attestation struct {
+	EventID   nostr.ID
+	EventKind int    // -1 if the "k" tag is missing
+	Relay     string // where the event can be found, if given
+
+	Proof *Proof
+}
+
+// ParseAttestation decodes the proof in evt and checks it is for the event it points to.
+func ParseAttestation(evt *nostr.Event) (*Attestation, error) {
+	if evt.Kind != KindOpenTimestamps {
+		return nil, fmt.Errorf("expected kind %d, got %d", KindOpenTimestamps, evt.Kind)
+	}
+	tag := evt.Tags.GetFirst([]string{"e", ""})
+	if tag == nil {
+		return nil, fmt.Errorf("attestation has no 'e' tag")
+	}
+	id, err := nostr.IDFromHex(tag.Value())
+	if err != nil {
+		return nil, fmt.Errorf("invalid event id '%s': %w", tag.Value(), err)
+	}
+
+	attestation := &Attestation{EventID: id, EventKind: -1, Relay: tag.Relay()}
+	if k := evt.Tags.GetFirst([]string{"k", ""}); k != nil {
+		if attestation.EventKind, err = strconv.Atoi(k.Value()); err != nil {
+			return nil, fmt.Errorf("invalid kind '%s'", k.Value())
+		}
+	}
+
+	data, err := base64.StdEncoding.DecodeString(evt.Content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 proof: %w", err)
+	}
+	if attestation.Proof, err = ParseProof(data); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(attestation.Proof.Digest, id[:]) {
+		return nil, fmt.Errorf("proof is for %x, not for event %s", attestation.Proof.Digest, id)
+	}
+	return attestation, nil
+}
+
+// Pending tells if the proof has no Bitcoin attestation yet.
+func (p *Proof) Pending() bool {
+	for _, attestation := range p.Attestations {
+		if attestation.Kind == Bitcoin {
+			return false
+		}
+	}
+	return true
+}
+
+// MerkleRootFunc returns the merkle root of the Bitcoin block at height, as it is serialized
+// in the block header, which is reversed from how block explorers show it. It is usually
+// backed by a Bitcoin node or a block explorer API.
+type MerkleRootFunc func(ctx context.Context, height uint64) ([]byte, error)
+
+// Verify checks the Bitcoin attestations of the proof against the blocks given by merkleRoot
+// and returns the lowest height that matches, which is when the digest was timestamped at the
+// latest. Other attestations are ignored.
+func (p *Proof) Verify(ctx context.Context, merkleRoot MerkleRootFunc) (uint64, error) {
+	var height uint64
+	var lastErr error
+	for _, attestation := range p.Attestations {
+		if attestation.Kind != Bitcoin || (height != 0 && attestation.Height >= height) {
+			continue
+		}
+		root, err := merkleRoot(ctx, attestation.Height)
+		if err != nil {
+			lastErr = fmt.Errorf("failed to get block %d: %w", attestation.Height, err)
+			continue
+		}
+		if !bytes.Equal(root, attestation.Commitment) {
+			lastErr = fmt.Errorf("attestation doesn't match the merkle root of block %d", attestation.Height)
+			continue
+		}
+		height = attestation.Height
+	}
+
+	if height == 0 {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("proof has no Bitcoin attestation")
+		}
+		return 0, lastErr
+	}
+	return height, nil
+}
@@ -0,0 +1,114 @@
+package nip03
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func varuint(n uint64) []byte {
+	var b []byte
+	for n >= 0x80 {
+		b = append(b, byte(n)|0x80)
+		n >>= 7
+	}
+	return append(b, byte(n))
+}
+
+func varbytes(data []byte) []byte {
+	return append(varuint(uint64(len(data))), data...)
+}
+
+// makeProof stamps digest with two branches: one pending on a calendar and one that appends
+// a nonce, hashes and is attested in Bitcoin block 800000, whose merkle root is returned.
+func makeProof(digest []byte) (proof []byte, merkleRoot []byte) {
+	nonce := []byte("some nonce")
+	root := sha256.Sum256(append(append([]byte{}, digest...), nonce...))
+
+	var buf bytes.Buffer
+	buf.Write(headerMagic)
+	buf.Write(varuint(1))
+	buf.WriteByte(opSHA256)
+	buf.Write(digest)
+
+	buf.WriteByte(0xff)
+	buf.WriteByte(0x00)
+	buf.Write(pendingTag)
+	buf.Write(varbytes(varbytes([]byte("https://alice.btc.calendar.opentimestamps.org"))))
+
+	buf.WriteByte(opAppend)
+	buf.Write(varbytes(nonce))
+	buf.WriteByte(opSHA256)
+	buf.WriteByte(0x00)
+	buf.Write(bitcoinTag)
+	buf.Write(varbytes(varuint(800000)))
+
+	return buf.Bytes(), root[:]
+}
+
+func TestAttestationRoundTrip(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	target := nostr.Event{Kind: 1, Content: "I was here", CreatedAt: time.Unix(1672068534, 0)}
+	target.Sign(sk)
+
+	data, root := makeProof(target.ID[:])
+	evt, err := CreateAttestation(&target, data, "wss://relay.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attestation, err := ParseAttestation(&evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attestation.EventID != target.ID || attestation.EventKind != 1 || attestation.Relay != "wss://relay.example.com" {
+		t.Fatalf("unexpected attestation %+v", attestation)
+	}
+	proof := attestation.Proof
+	if len(proof.Attestations) != 2 || proof.Pending() ||
+		proof.Attestations[0].Kind != Pending || proof.Attestations[0].URI != "https://alice.btc.calendar.opentimestamps.org" ||
+		proof.Attestations[1].Kind != Bitcoin || proof.Attestations[1].Height != 800000 {
+		t.Fatalf("unexpected proof %+v", proof)
+	}
+
+	blocks := map[uint64][]byte{800000: root}
+	merkleRoot := func(ctx context.Context, height uint64) ([]byte, error) {
+		if root, ok := blocks[height]; ok {
+			return root, nil
+		}
+		return nil, fmt.Errorf("unknown block")
+	}
+	if height, err := proof.Verify(context.Background(), merkleRoot); err != nil || height != 800000 {
+		t.Errorf("verification failed: %d, %v", height, err)
+	}
+	blocks[800000] = make([]byte, 32)
+	if _, err := proof.Verify(context.Background(), merkleRoot); err == nil {
+		t.Errorf("verification should fail against another block")
+	}
+
+	// proofs of other events are refused
+	other := nostr.Event{Kind: 1, Content: "someone else", CreatedAt: time.Unix(1672068534, 0)}
+	other.Sign(sk)
+	if _, err := CreateAttestation(&other, data, ""); err == nil {
+		t.Errorf("attestation created with the proof of another event")
+	}
+}
+
+func TestParseProofInvalid(t *testing.T) {
+	data, _ := makeProof(make([]byte, 32))
+	for name, bad := range map[string][]byte{
+		"truncated": data[:len(data)-3],
+		"trailing":  append(append([]byte{}, data...), 0x00),
+		"magic":     append([]byte{0x01}, data[1:]...),
+	} {
+		if _, err := ParseProof(bad); !errors.Is(err, ErrInvalidProof) {
+			t.Errorf("%s: expected ErrInvalidProof, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package nip03
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/ripemd160"
+	"golang.org/x/crypto/sha3"
+)
+
+// headerMagic starts every OpenTimestamps proof file.
+var headerMagic = []byte("\x00OpenTimestamps\x00\x00Proof\x00\xbf\x89\xe2\xe8\x84\xe8\x92\x94")
+
+var (
+	bitcoinTag  = []byte{0x05, 0x88, 0x96, 0x0d, 0x73, 0xd7, 0x19, 0x01}
+	litecoinTag = []byte{0x06, 0x86, 0x9a, 0x0d, 0x73, 0xd7, 0x1b, 0x45}
+	pendingTag  = []byte{0x83, 0xdf, 0xe3, 0x0d, 0x2e, 0xf9, 0x0c, 0x8e}
+)
+
+const (
+	opSHA1      = 0x02
+	opRIPEMD160 = 0x03
+	opSHA256    = 0x08
+	opKeccak256 = 0x67
+	opAppend    = 0xf0
+	opPrepend   = 0xf1
+	opReverse   = 0xf2
+	opHexlify   = 0xf3
+
+	// limits from the reference implementation, so bad proofs can't make us use much memory
+	maxMessageLength = 4096
+	maxDepth         = 256
+)
+
+var ErrInvalidProof = errors.New("invalid OpenTimestamps proof")
+
+// AttestationKind says where a timestamp is attested.
+type AttestationKind string
+
+const (
+	Bitcoin  AttestationKind = "bitcoin"
+	Litecoin AttestationKind = "litecoin"
+	// Pending attestations only say the calendar at URI will get the timestamp into a block,
+	// the proof must be upgraded with the ots client once that happens.
+	Pending AttestationKind = "pending"
+	Unknown AttestationKind = "unknown"
+)
+
+// TimeAttestation is a leaf of a proof, saying where its commitment was recorded.
+type TimeAttestation struct {
+	Kind   AttestationKind
+	Height uint64 // block height, for Bitcoin and Litecoin
+	URI    string // calendar, for Pending
+
+	// Commitment is what the operations leading to the attestation produce from the digest. For
+	// Bitcoin it must be the merkle root of the block at Height.
+	Commitment []byte
+}
+
+// Proof is a parsed OpenTimestamps proof file.
+type Proof struct {
+	// Digest is the SHA-256 hash that was timestamped, which for NIP-03 is the id of an event.
+	Digest []byte
+
+	Attestations []TimeAttestation
+}
+
+// ParseProof reads a detached OpenTimestamps proof, as written by the ots client in .ots files,
+// running its operations to find the commitment of each attestation.
+func ParseProof(data []byte) (*Proof, error) {
+	r := &reader{data: data}
+	if magic, err := r.read(len(headerMagic)); err != nil || !bytes.Equal(magic, headerMagic) {
+		return nil, fmt.Errorf("%w: not a proof file", ErrInvalidProof)
+	}
+	if version, err := r.varuint(); err != nil || version != 1 {
+		return nil, fmt.Errorf("%w: unsupported version", ErrInvalidProof)
+	}
+	if op, err := r.byte(); err != nil || op != opSHA256 {
+		return nil, fmt.Errorf("%w: only SHA-256 digests are supported", ErrInvalidProof)
+	}
+	digest, err := r.read(sha256.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	proof := &Proof{Digest: digest}
+	if err := r.timestamp(digest, proof, 0); err != nil {
+		return nil, err
+	}
+	if r.pos != len(r.data) {
+		return nil, fmt.Errorf("%w: trailing data", ErrInvalidProof)
+	}
+	return proof, nil
+}
+
+type reader struct {
+	data []byte
+	pos  int
+}
+
+func (r *reader) read(n int) ([]byte, error) {
+	if n < 0 || r.pos+n > len(r.data) {
+		return nil, fmt.Errorf("%w: unexpected end", ErrInvalidProof)
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+func (r *reader) byte() (byte, error) {
+	b, err := r.read(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// varuint is an unsigned LEB128 integer.
+func (r *reader) varuint() (uint64, error) {
+	var value uint64
+	for shift := 0; shift < 64; shift += 7 {
+		b, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		value |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return value, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: varuint too long", ErrInvalidProof)
+}
+
+func (r *reader) varbytes(max int) ([]byte, error) {
+	n, err := r.varuint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(max) {
+		return nil, fmt.Errorf("%w: %d bytes is too long", ErrInvalidProof, n)
+	}
+	return r.read(int(n))
+}
+
+// timestamp reads the tree of operations applied to msg. Branches are prefixed with 0xff,
+// except the last one.
+func (r *reader) timestamp(msg []byte, proof *Proof, depth int) error {
+	if depth > maxDepth {
+		return fmt.Errorf("%w: too deep", ErrInvalidProof)
+	}
+	for {
+		tag, err := r.byte()
+		if err != nil {
+			return err
+		}
+		last := tag != 0xff
+		if !last {
+			if tag, err = r.byte(); err != nil {
+				return err
+			}
+		}
+
+		if tag == 0x00 {
+			if err := r.attestation(msg, proof); err != nil {
+				return err
+			}
+		} else {
+			result, err := r.operation(tag, msg)
+			if err != nil {
+				return err
+			}
+			if err := r.timestamp(result, proof, depth+1); err != nil {
+				return err
+			}
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+func (r *reader) operation(tag byte, msg []byte) ([]byte, error) {
+	switch tag {
+	case opSHA1:
+		h := sha1.Sum(msg)
+		return h[:], nil
+	case opRIPEMD160:
+		h := ripemd160.New()
+		h.Write(msg)
+		return h.Sum(nil), nil
+	case opSHA256:
+		h := sha256.Sum256(msg)
+		return h[:], nil
+	case opKeccak256:
+		h := sha3.NewLegacyKeccak256()
+		h.Write(msg)
+		return h.Sum(nil), nil
+	case opAppend, opPrepend:
+		arg, err := r.varbytes(maxMessageLength)
+		if err != nil {
+			return nil, err
+		}
+		if len(msg)+len(arg) > maxMessageLength {
+			return nil, fmt.Errorf("%w: message too long", ErrInvalidProof)
+		}
+		result := make([]byte, 0, len(msg)+len(arg))
+		if tag == opAppend {
+			return append(append(result, msg...), arg...), nil
+		}
+		return append(append(result, arg...), msg...), nil
+	case opReverse:
+		result := make([]byte, len(msg))
+		for i, b := range msg {
+			result[len(msg)-1-i] = b
+		}
+		return result, nil
+	case opHexlify:
+		const digits = "0123456789abcdef"
+		result := make([]byte, 0, len(msg)*2)
+		for _, b := range msg {
+			result = append(result, digits[b>>4], digits[b&0x0f])
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("%w: unknown operation 0x%02x", ErrInvalidProof, tag)
+}
+
+func (r *reader) attestation(msg []byte, proof *Proof) error {
+	tag, err := r.read(8)
+	if err != nil {
+		return err
+	}
+	payload, err := r.varbytes(8192)
+	if err != nil {
+		return err
+	}
+
+	attestation := TimeAttestation{Kind: Unknown, Commitment: msg}
+	switch {
+	case bytes.Equal(tag, bitcoinTag), bytes.Equal(tag, litecoinTag):
+		attestation.Kind = Bitcoin
+		if bytes.Equal(tag, litecoinTag) {
+			attestation.Kind = Litecoin
+		}
+		pr := &reader{data: payload}
+		if attestation.Height, err = pr.varuint(); err != nil {
+			return err
+		}
+	case bytes.Equal(tag, pendingTag):
+		pr := &reader{data: payload}
+		uri, err := pr.varbytes(1000)
+		if err != nil {
+			return err
+		}
+		attestation.Kind = Pending
+		attestation.URI = string(uri)
+	}
+	proof.Attestations = append(proof.Attestations, attestation)
+	return nil
+}
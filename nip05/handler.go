@@ -0,0 +1,212 @@
+package nip05
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Identity is what a name in a nostr.json resolves to.
+type Identity struct {
+	PubKey nostr.PubKey
+	Relays []string
+}
+
+// Source finds the identity for a name, which is always lowercase.
+// ok is false if the name doesn't exist.
+type Source interface {
+	Lookup(ctx context.Context, name string) (identity Identity, ok bool, err error)
+}
+
+// Lister is implemented by sources that can list all their names, which are then served
+// when nostr.json is requested without a name.
+type Lister interface {
+	List(ctx context.Context) (map[string]Identity, error)
+}
+
+// StaticSource is a fixed set of names.
+type StaticSource map[string]Identity
+
+func (s StaticSource) Lookup(ctx context.Context, name string) (Identity, bool, error) {
+	identity, ok := s[name]
+	return identity, ok, nil
+}
+
+func (s StaticSource) List(ctx context.Context) (map[string]Identity, error) {
+	return s, nil
+}
+
+// SourceFunc turns a function, e.g. one querying a database, into a Source.
+type SourceFunc func(ctx context.Context, name string) (Identity, bool, error)
+
+func (f SourceFunc) Lookup(ctx context.Context, name string) (Identity, bool, error) {
+	return f(ctx, name)
+}
+
+// how long StoreSource uses its index of names before reading the store again, by default
+const defaultStoreSourceMaxAge = time.Minute
+
+// StoreSource finds names in the kind-0 events of a nostr.Store: a name belongs to whoever
+// claims name@domain in their metadata, and their relays are the write relays in their
+// NIP-65 list, if the store has it. The names are indexed, and the index is rebuilt from the
+// store once it is older than MaxAge, a minute if it is zero, so changes take that long to
+// show up.
+type StoreSource struct {
+	Store  nostr.Store
+	Domain string
+	MaxAge time.Duration
+
+	mutex     sync.Mutex
+	index     map[string]Identity
+	indexedAt time.Time
+}
+
+func (s *StoreSource) Lookup(ctx context.Context, name string) (Identity, bool, error) {
+	identities, err := s.identities(ctx)
+	if err != nil {
+		return Identity{}, false, err
+	}
+	identity, ok := identities[name]
+	return identity, ok, nil
+}
+
+func (s *StoreSource) List(ctx context.Context) (map[string]Identity, error) {
+	identities, err := s.identities(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// the index is shared, don't let callers modify it
+	list := make(map[string]Identity, len(identities))
+	for name, identity := range identities {
+		list[name] = identity
+	}
+	return list, nil
+}
+
+// identities returns the index of names, rebuilding it if it is too old. Callers wait for a
+// rebuild in progress instead of reading the store themselves.
+func (s *StoreSource) identities(ctx context.Context) (map[string]Identity, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	maxAge := s.MaxAge
+	if maxAge == 0 {
+		maxAge = defaultStoreSourceMaxAge
+	}
+	if s.index != nil && nostr.Now().Sub(s.indexedAt) < maxAge {
+		return s.index, nil
+	}
+
+	index, err := s.buildIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.index, s.indexedAt = index, nostr.Now()
+	return index, nil
+}
+
+func (s *StoreSource) buildIndex(ctx context.Context) (map[string]Identity, error) {
+	events, err := s.Store.QueryEvents(ctx, nostr.Filter{Kinds: []int{nostr.KindSetMetadata}})
+	if err != nil {
+		return nil, err
+	}
+
+	identities := make(map[string]Identity)
+	for _, evt := range events {
+		meta, err := nostr.ParseMetadata(*evt)
+		if err != nil {
+			continue
+		}
+		name, domain, found := strings.Cut(strings.ToLower(NormalizeIdentifier(meta.NIP05)), "@")
+		if !found {
+			name, domain = "_", name
+		}
+		if domain != strings.ToLower(s.Domain) {
+			continue
+		}
+		// events are newest first, so overwriting leaves the oldest claim when a name is taken twice
+		identities[name] = Identity{PubKey: evt.PubKey, Relays: s.relays(ctx, evt.PubKey)}
+	}
+	return identities, nil
+}
+
+func (s *StoreSource) relays(ctx context.Context, pk nostr.PubKey) []string {
+	events, err := s.Store.QueryEvents(ctx, nostr.Filter{
+		Kinds:   []int{nostr.KindRelayListMetadata},
+		Authors: []nostr.PubKey{pk},
+		Limit:   1,
+	})
+	if err != nil || len(events) == 0 {
+		return nil
+	}
+
+	list, err := nostr.ParseRelayList(events[0])
+	if err != nil {
+		return nil
+	}
+	return list.Write
+}
+
+// Handler serves /.well-known/nostr.json from a Source, answering the "name" query parameter,
+// or with all the names if there is none and the Source is a Lister. Any origin is allowed
+// to read it, as NIP-05 requires for web clients.
+type Handler struct {
+	Source Source
+}
+
+func NewHandler(source Source) *Handler {
+	return &Handler{Source: source}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodOptions:
+		w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	identities := make(map[string]Identity)
+	if name := strings.ToLower(r.URL.Query().Get("name")); name != "" {
+		identity, ok, err := h.Source.Lookup(r.Context(), name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if ok {
+			identities[name] = identity
+		}
+	} else if lister, ok := h.Source.(Lister); ok {
+		all, err := lister.List(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		identities = all
+	}
+
+	response := WellKnownResponse{Names: make(name2KeyMap, len(identities))}
+	for name, identity := range identities {
+		pk := identity.PubKey.Hex()
+		response.Names[name] = pk
+		if len(identity.Relays) > 0 {
+			if response.Relays == nil {
+				response.Relays = make(key2RelaysMap)
+			}
+			response.Relays[pk] = identity.Relays
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
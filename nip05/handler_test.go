@@ -0,0 +1,73 @@
+package nip05
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestHandler(t *testing.T) {
+	bob := nostr.MustPubKeyFromHex("3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d")
+	source := StaticSource{
+		"bob":   {PubKey: bob, Relays: []string{"wss://nos.lol"}},
+		"alice": {PubKey: nostr.PubKey{1}},
+	}
+	handler := NewHandler(source)
+
+	get := func(query string) (*httptest.ResponseRecorder, WellKnownResponse) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/.well-known/nostr.json"+query, nil))
+		var response WellKnownResponse
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		return rec, response
+	}
+
+	rec, response := get("?name=Bob")
+	if rec.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Error("missing CORS header")
+	}
+	if len(response.Names) != 1 || response.Names["bob"] != bob.Hex() || response.Relays[bob.Hex()][0] != "wss://nos.lol" {
+		t.Fatalf("wrong response %s", rec.Body.String())
+	}
+
+	if _, response := get("?name=carol"); len(response.Names) != 0 {
+		t.Fatalf("unknown name returned %v", response.Names)
+	}
+	if _, response := get(""); len(response.Names) != 2 {
+		t.Fatalf("expected all names, got %v", response.Names)
+	}
+}
+
+func TestStoreSource(t *testing.T) {
+	ctx := context.Background()
+	store := nostr.NewMemoryStore()
+	store.SaveEvent(ctx, &nostr.Event{ID: nostr.ID{1}, PubKey: nostr.PubKey{1}, Kind: 0, CreatedAt: time.Unix(1, 0), Content: `{"nip05":"Alice@Example.com"}`})
+	store.SaveEvent(ctx, &nostr.Event{ID: nostr.ID{2}, PubKey: nostr.PubKey{2}, Kind: 0, CreatedAt: time.Unix(2, 0), Content: `{"nip05":"alice@example.com"}`})
+	store.SaveEvent(ctx, &nostr.Event{ID: nostr.ID{3}, PubKey: nostr.PubKey{3}, Kind: 0, CreatedAt: time.Unix(3, 0), Content: `{"nip05":"_@example.com"}`})
+	store.SaveEvent(ctx, &nostr.Event{ID: nostr.ID{4}, PubKey: nostr.PubKey{1}, Kind: 10002, CreatedAt: time.Unix(1, 0),
+		Tags: nostr.Tags{{"r", "wss://read.example.com", "read"}, {"r", "wss://nos.lol"}}})
+
+	source := &StoreSource{Store: store, Domain: "example.com", MaxAge: time.Hour}
+	alice, ok, _ := source.Lookup(ctx, "alice")
+	if !ok || alice.PubKey != (nostr.PubKey{1}) || len(alice.Relays) != 1 || alice.Relays[0] != "wss://nos.lol" {
+		t.Fatalf("wrong identity for alice: %v", alice)
+	}
+	if root, ok, _ := source.Lookup(ctx, "_"); !ok || root.PubKey != (nostr.PubKey{3}) {
+		t.Fatalf("wrong identity for _: %v", root)
+	}
+
+	// the index is used until it is too old
+	store.SaveEvent(ctx, &nostr.Event{ID: nostr.ID{5}, PubKey: nostr.PubKey{5}, Kind: 0, CreatedAt: time.Unix(5, 0), Content: `{"nip05":"bob@example.com"}`})
+	if _, ok, _ := source.Lookup(ctx, "bob"); ok {
+		t.Fatal("the index was rebuilt before MaxAge")
+	}
+	source.indexedAt = time.Now().Add(-2 * time.Hour)
+	if bob, ok, _ := source.Lookup(ctx, "bob"); !ok || bob.PubKey != (nostr.PubKey{5}) {
+		t.Fatalf("the index wasn't rebuilt: %v", bob)
+	}
+}
@@ -0,0 +1,64 @@
+package nip11
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Handler serves Document to requests that accept application/nostr+json and passes every other
+// request, e.g. websocket upgrades, to Next, so both can live on the relay's URL:
+//
+//	http.ListenAndServe(":7447", nip11.NewHandler(info, relayHandler))
+//
+// CORS headers are set on the document and preflight requests are answered, as NIP-11 requires.
+type Handler struct {
+	Document *RelayInformationDocument
+	Next     http.Handler
+}
+
+func NewHandler(document *RelayInformationDocument, next http.Handler) *Handler {
+	return &Handler{Document: document, Next: next}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+		setCORSHeaders(w)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if (r.Method == http.MethodGet || r.Method == http.MethodHead) &&
+		r.Header.Get("Upgrade") == "" && AcceptsDocument(r) {
+		setCORSHeaders(w)
+		w.Header().Set("Content-Type", "application/nostr+json")
+		json.NewEncoder(w).Encode(h.Document)
+		return
+	}
+
+	if h.Next == nil {
+		http.Error(w, "please use a nostr client to connect", http.StatusBadRequest)
+		return
+	}
+	h.Next.ServeHTTP(w, r)
+}
+
+// AcceptsDocument tells if the Accept header of r asks for a NIP-11 document.
+func AcceptsDocument(r *http.Request) bool {
+	for _, header := range r.Header.Values("Accept") {
+		for _, accepted := range strings.Split(header, ",") {
+			mediaType, _, err := mime.ParseMediaType(accepted)
+			if err == nil && mediaType == "application/nostr+json" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func setCORSHeaders(w http.ResponseWriter) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+}
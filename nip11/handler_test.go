@@ -0,0 +1,51 @@
+package nip11
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	document := &RelayInformationDocument{
+		Name:          "test relay",
+		SupportedNIPs: []int{1, 11},
+		Limitation:    &RelayLimitationDocument{MaxLimit: 500},
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("websocket"))
+	})
+	server := httptest.NewServer(NewHandler(document, next))
+	defer server.Close()
+
+	info, err := Fetch(context.Background(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "test relay" || len(info.SupportedNIPs) != 2 || info.Limitation.MaxLimit != 500 {
+		t.Fatalf("wrong document: %v", info)
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "text/html, application/nostr+json;q=0.9")
+	NewHandler(document, next).ServeHTTP(rec, req)
+	if rec.Header().Get("Content-Type") != "application/nostr+json" || rec.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Fatalf("wrong headers: %v", rec.Header())
+	}
+
+	rec = httptest.NewRecorder()
+	NewHandler(document, next).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Body.String() != "websocket" {
+		t.Fatalf("request without the accept header wasn't passed on: %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodOptions, "/", nil)
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	NewHandler(document, next).ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Headers") != "*" {
+		t.Fatalf("wrong preflight response: %d %v", rec.Code, rec.Header())
+	}
+}
@@ -8,4 +8,43 @@ type RelayInformationDocument struct {
 	SupportedNIPs []int  `json:"supported_nips"`
 	Software      string `json:"software"`
 	Version       string `json:"version"`
+
+	Limitation  *RelayLimitationDocument `json:"limitation,omitempty"`
+	PaymentsURL string                   `json:"payments_url,omitempty"`
+	Fees        *RelayFeesDocument       `json:"fees,omitempty"`
+}
+
+// RelayLimitationDocument holds the limits a relay imposes on clients, zero means no limit.
+type RelayLimitationDocument struct {
+	MaxMessageLength int  `json:"max_message_length,omitempty"`
+	MaxSubscriptions int  `json:"max_subscriptions,omitempty"`
+	MaxFilters       int  `json:"max_filters,omitempty"`
+	MaxLimit         int  `json:"max_limit,omitempty"`
+	MaxSubidLength   int  `json:"max_subid_length,omitempty"`
+	MaxEventTags     int  `json:"max_event_tags,omitempty"`
+	MaxContentLength int  `json:"max_content_length,omitempty"`
+	MinPowDifficulty int  `json:"min_pow_difficulty,omitempty"`
+	AuthRequired     bool `json:"auth_required"`
+	PaymentRequired  bool `json:"payment_required"`
+
+	// CreatedAtLowerLimit and CreatedAtUpperLimit are how many seconds in the past and in the
+	// future the created_at of events can be.
+	CreatedAtLowerLimit int64 `json:"created_at_lower_limit,omitempty"`
+	CreatedAtUpperLimit int64 `json:"created_at_upper_limit,omitempty"`
+}
+
+// RelayFeesDocument lists what a paid relay charges.
+type RelayFeesDocument struct {
+	Admission    []Fee `json:"admission,omitempty"`
+	Subscription []Fee `json:"subscription,omitempty"`
+	Publication  []Fee `json:"publication,omitempty"`
+}
+
+// Fee is an amount in Unit (usually "msats"), paid once, every Period seconds for
+// subscriptions, or for each event of Kinds for publication fees.
+type Fee struct {
+	Amount int64  `json:"amount"`
+	Unit   string `json:"unit"`
+	Period int    `json:"period,omitempty"`
+	Kinds  []int  `json:"kinds,omitempty"`
 }
@@ -64,8 +64,8 @@ func Generate(event *nostr.Event, targetDifficulty int, timeout time.Duration) (
 	for {
 		nonce++
 		tag[1] = strconv.FormatUint(nonce, 10)
-		event.CreatedAt = time.Now()
-		if Difficulty(event.GetID()) >= targetDifficulty {
+		event.CreatedAt = nostr.Now()
+		if Difficulty(event.GetID().Hex()) >= targetDifficulty {
 			return event, nil
 		}
 		// benchmarks show one iteration is approx 3000ns on i7-8565U @ 1.8GHz.
@@ -35,7 +35,7 @@ func TestGenerateShort(t *testing.T) {
 	event := &nostr.Event{
 		Kind:    1,
 		Content: "It's just me mining my own business",
-		PubKey:  "a48380f4cfcc1ad5378294fcac36439770f9c878dd880ffa94bb74ea54a6f243",
+		PubKey:  nostr.MustPubKeyFromHex("a48380f4cfcc1ad5378294fcac36439770f9c878dd880ffa94bb74ea54a6f243"),
 	}
 	pow, err := Generate(event, 0, 3*time.Second)
 	if err != nil {
@@ -55,13 +55,13 @@ func TestGenerateLong(t *testing.T) {
 			event := &nostr.Event{
 				Kind:    1,
 				Content: "It's just me mining my own business",
-				PubKey:  "a48380f4cfcc1ad5378294fcac36439770f9c878dd880ffa94bb74ea54a6f243",
+				PubKey:  nostr.MustPubKeyFromHex("a48380f4cfcc1ad5378294fcac36439770f9c878dd880ffa94bb74ea54a6f243"),
 			}
 			pow, err := Generate(event, difficulty, time.Minute)
 			if err != nil {
 				t.Fatal(err)
 			}
-			if err := Check(pow.GetID(), difficulty); err != nil {
+			if err := Check(pow.GetID().Hex(), difficulty); err != nil {
 				t.Error(err)
 			}
 			testNonceTag(t, pow, difficulty)
@@ -91,7 +91,7 @@ func TestGenerateTimeout(t *testing.T) {
 	event := &nostr.Event{
 		Kind:    1,
 		Content: "It's just me mining my own business",
-		PubKey:  "a48380f4cfcc1ad5378294fcac36439770f9c878dd880ffa94bb74ea54a6f243",
+		PubKey:  nostr.MustPubKeyFromHex("a48380f4cfcc1ad5378294fcac36439770f9c878dd880ffa94bb74ea54a6f243"),
 	}
 	done := make(chan error)
 	go func() {
@@ -119,7 +119,7 @@ func BenchmarkGenerateOneIteration(b *testing.B) {
 		event := &nostr.Event{
 			Kind:    1,
 			Content: "It's just me mining my own business",
-			PubKey:  "a48380f4cfcc1ad5378294fcac36439770f9c878dd880ffa94bb74ea54a6f243",
+			PubKey:  nostr.MustPubKeyFromHex("a48380f4cfcc1ad5378294fcac36439770f9c878dd880ffa94bb74ea54a6f243"),
 		}
 		if _, err := Generate(event, 0, time.Minute); err != nil {
 			b.Fatal(err)
@@ -138,7 +138,7 @@ func BenchmarkGenerate(b *testing.B) {
 				event := &nostr.Event{
 					Kind:    1,
 					Content: "It's just me mining my own business",
-					PubKey:  "a48380f4cfcc1ad5378294fcac36439770f9c878dd880ffa94bb74ea54a6f243",
+					PubKey:  nostr.MustPubKeyFromHex("a48380f4cfcc1ad5378294fcac36439770f9c878dd880ffa94bb74ea54a6f243"),
 				}
 				if _, err := Generate(event, difficulty, time.Minute); err != nil {
 					b.Fatal(err)
@@ -0,0 +1,219 @@
+package nip15
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// MessageType identifies the checkout messages, which go in the content of NIP-04 direct
+// messages between customer and merchant.
+type MessageType int
+
+const (
+	TypeOrder          MessageType = 0 // from the customer
+	TypePaymentRequest MessageType = 1 // from the merchant
+	TypeOrderStatus    MessageType = 2 // from the merchant
+)
+
+// Message is one of *Order, *PaymentRequest and *OrderStatus.
+type Message interface {
+	OrderID() string
+	messageType() MessageType
+}
+
+// Order is what a customer sends to place an order. Items must all be from stalls of the
+// merchant it is sent to, and ShippingID one of the zones of the stall.
+type Order struct {
+	ID         string      `json:"id"`
+	Name       string      `json:"name,omitempty"`
+	Address    string      `json:"address,omitempty"`
+	Message    string      `json:"message,omitempty"`
+	Contact    Contact     `json:"contact"`
+	Items      []OrderItem `json:"items"`
+	ShippingID string      `json:"shipping_id"`
+}
+
+type Contact struct {
+	Nostr string `json:"nostr,omitempty"`
+	Phone string `json:"phone,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+type OrderItem struct {
+	ProductID string `json:"product_id"`
+	Quantity  int    `json:"quantity"`
+}
+
+// PaymentRequest is how the merchant tells the customer where to pay for an order.
+type PaymentRequest struct {
+	ID             string          `json:"id"`
+	Message        string          `json:"message,omitempty"`
+	PaymentOptions []PaymentOption `json:"payment_options"`
+}
+
+// PaymentOption is a way to pay, Type being "url", "btc", "ln" or "lnurl".
+type PaymentOption struct {
+	Type string `json:"type"`
+	Link string `json:"link"`
+}
+
+// OrderStatus is how the merchant tells the customer an order was paid or shipped.
+type OrderStatus struct {
+	ID      string `json:"id"`
+	Message string `json:"message"`
+	Paid    bool   `json:"paid"`
+	Shipped bool   `json:"shipped"`
+}
+
+func (o *Order) OrderID() string          { return o.ID }
+func (p *PaymentRequest) OrderID() string { return p.ID }
+func (s *OrderStatus) OrderID() string    { return s.ID }
+
+func (*Order) messageType() MessageType          { return TypeOrder }
+func (*PaymentRequest) messageType() MessageType { return TypePaymentRequest }
+func (*OrderStatus) messageType() MessageType    { return TypeOrderStatus }
+
+// Validate checks the order can be processed.
+func (o *Order) Validate() error {
+	switch {
+	case o.ID == "":
+		return fmt.Errorf("order has no id")
+	case len(o.Items) == 0:
+		return fmt.Errorf("order has no items")
+	case o.ShippingID == "":
+		return fmt.Errorf("order has no shipping zone")
+	}
+	for _, item := range o.Items {
+		if item.ProductID == "" || item.Quantity <= 0 {
+			return fmt.Errorf("invalid item '%s' with quantity %d", item.ProductID, item.Quantity)
+		}
+	}
+	return nil
+}
+
+// Total is the cost of the order with the given products and stall, shipping included. It
+// fails if a product isn't found or isn't from the stall, or the shipping zone doesn't exist.
+func (o *Order) Total(stall Stall, products []Product) (float64, error) {
+	zone := stall.Zone(o.ShippingID)
+	if zone == nil {
+		return 0, fmt.Errorf("stall '%s' has no shipping zone '%s'", stall.ID, o.ShippingID)
+	}
+	byID := make(map[string]Product, len(products))
+	for _, product := range products {
+		byID[product.ID] = product
+	}
+
+	total := 0.0
+	for _, item := range o.Items {
+		product, ok := byID[item.ProductID]
+		if !ok {
+			return 0, fmt.Errorf("product '%s' not found", item.ProductID)
+		}
+		if product.StallID != stall.ID {
+			return 0, fmt.Errorf("product '%s' isn't from stall '%s'", product.ID, stall.ID)
+		}
+		total += float64(item.Quantity) * (product.Price + product.ShippingCost(*zone))
+	}
+	return total, nil
+}
+
+// EncodeMessage returns the JSON of msg with its "type" field, to be sent in a direct message.
+func EncodeMessage(msg Message) (string, error) {
+	if order, ok := msg.(*Order); ok {
+		if err := order.Validate(); err != nil {
+			return "", err
+		}
+	}
+	if msg.OrderID() == "" {
+		return "", fmt.Errorf("message has no order id")
+	}
+
+	j, err := json.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+	// add the type as the last field
+	typ := fmt.Sprintf(`,"type":%d}`, msg.messageType())
+	return string(j[:len(j)-1]) + typ, nil
+}
+
+// ParseMessage reads the content of a checkout direct message.
+func ParseMessage(content string) (Message, error) {
+	var header struct {
+		Type *MessageType `json:"type"`
+	}
+	if err := json.Unmarshal([]byte(content), &header); err != nil {
+		return nil, fmt.Errorf("invalid checkout message: %w", err)
+	}
+	if header.Type == nil {
+		return nil, fmt.Errorf("checkout message has no type")
+	}
+
+	var msg Message
+	switch *header.Type {
+	case TypeOrder:
+		msg = &Order{}
+	case TypePaymentRequest:
+		msg = &PaymentRequest{}
+	case TypeOrderStatus:
+		msg = &OrderStatus{}
+	default:
+		return nil, fmt.Errorf("unknown checkout message type %d", *header.Type)
+	}
+	if err := json.Unmarshal([]byte(content), msg); err != nil {
+		return nil, fmt.Errorf("invalid checkout message: %w", err)
+	}
+	if order, ok := msg.(*Order); ok {
+		if err := order.Validate(); err != nil {
+			return nil, err
+		}
+	} else if msg.OrderID() == "" {
+		return nil, fmt.Errorf("message has no order id")
+	}
+	return msg, nil
+}
+
+// CreateMessage returns the kind-4 direct message carrying msg to recipient, encrypted and
+// signed with signer, ready to be published.
+func CreateMessage(ctx context.Context, signer nostr.Signer, recipient nostr.PubKey, msg Message) (nostr.Event, error) {
+	content, err := EncodeMessage(msg)
+	if err != nil {
+		return nostr.Event{}, err
+	}
+	ciphertext, err := signer.NIP04Encrypt(ctx, content, recipient)
+	if err != nil {
+		return nostr.Event{}, fmt.Errorf("failed to encrypt: %w", err)
+	}
+	pk, err := signer.GetPublicKey(ctx)
+	if err != nil {
+		return nostr.Event{}, fmt.Errorf("failed to get our public key: %w", err)
+	}
+
+	evt := nostr.Event{
+		Kind:      nostr.KindEncryptedDirectMessage,
+		PubKey:    pk,
+		CreatedAt: nostr.Now(),
+		Tags:      nostr.Tags{{"p", recipient.Hex()}},
+		Content:   ciphertext,
+	}
+	if err := signer.SignEvent(ctx, &evt); err != nil {
+		return nostr.Event{}, fmt.Errorf("failed to sign: %w", err)
+	}
+	return evt, nil
+}
+
+// ReadMessage decrypts a kind-4 direct message received by the owner of signer and parses the
+// checkout message in it.
+func ReadMessage(ctx context.Context, signer nostr.Signer, evt *nostr.Event) (Message, error) {
+	if evt.Kind != nostr.KindEncryptedDirectMessage {
+		return nil, fmt.Errorf("expected kind %d, got %d", nostr.KindEncryptedDirectMessage, evt.Kind)
+	}
+	content, err := signer.NIP04Decrypt(ctx, evt.Content, evt.PubKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return ParseMessage(content)
+}
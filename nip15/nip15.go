@@ -0,0 +1,210 @@
+// Package nip15 implements the marketplace of NIP-15: stalls (kind 30017) and the products they
+// sell (kind 30018), and the checkout messages exchanged by customers and merchants over
+// encrypted direct messages.
+// See https://github.com/nostr-protocol/nips/blob/master/15.md for details.
+package nip15
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	KindStall   = 30017
+	KindProduct = 30018
+)
+
+// Stall is a merchant's shop, with the zones it ships to.
+type Stall struct {
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Currency    string         `json:"currency"`
+	Shipping    []ShippingZone `json:"shipping"`
+
+	Merchant nostr.PubKey `json:"-"` // set by ParseStall
+}
+
+// ShippingZone is a way a stall ships products, with its base cost in the stall currency.
+type ShippingZone struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name,omitempty"`
+	Cost    float64  `json:"cost"`
+	Regions []string `json:"regions"`
+}
+
+// Validate checks the stall has everything NIP-15 requires.
+func (stall Stall) Validate() error {
+	switch {
+	case stall.ID == "":
+		return fmt.Errorf("stall has no id")
+	case stall.Name == "":
+		return fmt.Errorf("stall has no name")
+	case stall.Currency == "":
+		return fmt.Errorf("stall has no currency")
+	}
+	zones := make(map[string]struct{}, len(stall.Shipping))
+	for _, zone := range stall.Shipping {
+		if zone.ID == "" {
+			return fmt.Errorf("shipping zone has no id")
+		}
+		if _, ok := zones[zone.ID]; ok {
+			return fmt.Errorf("shipping zone '%s' is repeated", zone.ID)
+		}
+		zones[zone.ID] = struct{}{}
+		if zone.Cost < 0 {
+			return fmt.Errorf("shipping zone '%s' has a negative cost", zone.ID)
+		}
+	}
+	return nil
+}
+
+// Zone returns the shipping zone with the given id, or nil.
+func (stall Stall) Zone(id string) *ShippingZone {
+	for i := range stall.Shipping {
+		if stall.Shipping[i].ID == id {
+			return &stall.Shipping[i]
+		}
+	}
+	return nil
+}
+
+// ToEvent returns the unsigned kind-30017 event of the stall.
+func (stall Stall) ToEvent() (nostr.Event, error) {
+	if err := stall.Validate(); err != nil {
+		return nostr.Event{}, err
+	}
+	if stall.Shipping == nil {
+		stall.Shipping = []ShippingZone{}
+	}
+	content, err := json.Marshal(stall)
+	if err != nil {
+		return nostr.Event{}, err
+	}
+	return nostr.Event{
+		Kind:      KindStall,
+		CreatedAt: nostr.Now(),
+		Tags:      nostr.Tags{{"d", stall.ID}},
+		Content:   string(content),
+	}, nil
+}
+
+// ParseStall reads and validates a kind-30017 event.
+func ParseStall(evt *nostr.Event) (Stall, error) {
+	var stall Stall
+	if evt.Kind != KindStall {
+		return stall, fmt.Errorf("expected kind %d, got %d", KindStall, evt.Kind)
+	}
+	if err := json.Unmarshal([]byte(evt.Content), &stall); err != nil {
+		return stall, fmt.Errorf("invalid stall: %w", err)
+	}
+	stall.Merchant = evt.PubKey
+	if d := evt.Identifier(); d != stall.ID {
+		return stall, fmt.Errorf("'d' tag '%s' doesn't match the stall id '%s'", d, stall.ID)
+	}
+	return stall, stall.Validate()
+}
+
+// Product is something sold in a stall. Prices and costs are in Currency, which should be the
+// currency of the stall.
+type Product struct {
+	ID          string            `json:"id"`
+	StallID     string            `json:"stall_id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Images      []string          `json:"images,omitempty"`
+	Currency    string            `json:"currency"`
+	Price       float64           `json:"price"`
+	Quantity    *int              `json:"quantity"` // nil for unlimited items, like digital goods
+	Specs       [][2]string       `json:"specs,omitempty"`
+	Shipping    []ProductShipping `json:"shipping,omitempty"`
+
+	// Categories are in "t" tags, not in the content.
+	Categories []string     `json:"-"`
+	Merchant   nostr.PubKey `json:"-"` // set by ParseProduct
+}
+
+// ProductShipping is the extra cost of shipping a product with one of the zones of its stall,
+// added to the zone cost.
+type ProductShipping struct {
+	ID   string  `json:"id"`
+	Cost float64 `json:"cost"`
+}
+
+// Validate checks the product has everything NIP-15 requires.
+func (product Product) Validate() error {
+	switch {
+	case product.ID == "":
+		return fmt.Errorf("product has no id")
+	case product.StallID == "":
+		return fmt.Errorf("product has no stall")
+	case product.Name == "":
+		return fmt.Errorf("product has no name")
+	case product.Currency == "":
+		return fmt.Errorf("product has no currency")
+	case product.Price < 0:
+		return fmt.Errorf("product has a negative price")
+	case product.Quantity != nil && *product.Quantity < 0:
+		return fmt.Errorf("product has a negative quantity")
+	}
+	for _, shipping := range product.Shipping {
+		if shipping.ID == "" || shipping.Cost < 0 {
+			return fmt.Errorf("invalid shipping cost for zone '%s'", shipping.ID)
+		}
+	}
+	return nil
+}
+
+// ShippingCost is the cost of shipping one product with zone of its stall: the zone cost plus
+// the extra cost of the product for that zone, if any.
+func (product Product) ShippingCost(zone ShippingZone) float64 {
+	cost := zone.Cost
+	for _, shipping := range product.Shipping {
+		if shipping.ID == zone.ID {
+			cost += shipping.Cost
+		}
+	}
+	return cost
+}
+
+// ToEvent returns the unsigned kind-30018 event of the product.
+func (product Product) ToEvent() (nostr.Event, error) {
+	if err := product.Validate(); err != nil {
+		return nostr.Event{}, err
+	}
+	content, err := json.Marshal(product)
+	if err != nil {
+		return nostr.Event{}, err
+	}
+	evt := nostr.Event{
+		Kind:      KindProduct,
+		CreatedAt: nostr.Now(),
+		Tags:      nostr.Tags{{"d", product.ID}},
+		Content:   string(content),
+	}
+	for _, category := range product.Categories {
+		evt.Tags = append(evt.Tags, nostr.Tag{"t", category})
+	}
+	return evt, nil
+}
+
+// ParseProduct reads and validates a kind-30018 event.
+func ParseProduct(evt *nostr.Event) (Product, error) {
+	var product Product
+	if evt.Kind != KindProduct {
+		return product, fmt.Errorf("expected kind %d, got %d", KindProduct, evt.Kind)
+	}
+	if err := json.Unmarshal([]byte(evt.Content), &product); err != nil {
+		return product, fmt.Errorf("invalid product: %w", err)
+	}
+	product.Merchant = evt.PubKey
+	if d := evt.Identifier(); d != product.ID {
+		return product, fmt.Errorf("'d' tag '%s' doesn't match the product id '%s'", d, product.ID)
+	}
+	for _, tag := range evt.Tags.GetAll([]string{"t", ""}) {
+		product.Categories = append(product.Categories, tag.Value())
+	}
+	return product, product.Validate()
+}
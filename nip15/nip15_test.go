@@ -0,0 +1,117 @@
+package nip15
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/keyer"
+)
+
+func TestStallAndProduct(t *testing.T) {
+	stall := Stall{
+		ID:       "stall1",
+		Name:     "Hats",
+		Currency: "USD",
+		Shipping: []ShippingZone{{ID: "world", Cost: 10, Regions: []string{"*"}}, {ID: "local", Cost: 2}},
+	}
+	evt, err := stall.ToEvent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseStall(&evt)
+	if err != nil || parsed.Name != "Hats" || len(parsed.Shipping) != 2 || parsed.Zone("local").Cost != 2 {
+		t.Fatalf("unexpected stall %+v, %v", parsed, err)
+	}
+
+	quantity := 3
+	product := Product{
+		ID:         "hat1",
+		StallID:    "stall1",
+		Name:       "Red hat",
+		Currency:   "USD",
+		Price:      25,
+		Quantity:   &quantity,
+		Specs:      [][2]string{{"color", "red"}},
+		Shipping:   []ProductShipping{{ID: "world", Cost: 5}},
+		Categories: []string{"hats", "clothing"},
+	}
+	evt, err = product.ToEvent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(evt.Content, `"quantity":3`) || strings.Contains(evt.Content, "hats") {
+		t.Errorf("unexpected content %s", evt.Content)
+	}
+	got, err := ParseProduct(&evt)
+	if err != nil || *got.Quantity != 3 || got.Specs[0][1] != "red" || len(got.Categories) != 2 {
+		t.Fatalf("unexpected product %+v, %v", got, err)
+	}
+
+	// the 'd' tag must be the id in the content
+	evt.Tags[0][1] = "other"
+	if _, err := ParseProduct(&evt); err == nil {
+		t.Errorf("product with mismatched 'd' tag accepted")
+	}
+	if _, err := (Product{ID: "x", StallID: "s", Name: "n", Currency: "USD", Price: -1}).ToEvent(); err == nil {
+		t.Errorf("product with negative price accepted")
+	}
+
+	order := Order{ID: "o1", Items: []OrderItem{{ProductID: "hat1", Quantity: 2}}, ShippingID: "world"}
+	if total, err := order.Total(stall, []Product{product}); err != nil || total != 2*(25+10+5) {
+		t.Errorf("unexpected total %f, %v", total, err)
+	}
+	order.ShippingID = "mars"
+	if _, err := order.Total(stall, []Product{product}); err == nil {
+		t.Errorf("order with unknown shipping zone accepted")
+	}
+}
+
+func TestCheckoutMessages(t *testing.T) {
+	ctx := context.Background()
+	customer, _ := keyer.NewPlainKeySigner(nostr.GeneratePrivateKey())
+	merchant, _ := keyer.NewPlainKeySigner(nostr.GeneratePrivateKey())
+	customerPK, _ := customer.GetPublicKey(ctx)
+	merchantPK, _ := merchant.GetPublicKey(ctx)
+
+	order := &Order{
+		ID:         "o1",
+		Address:    "somewhere",
+		Contact:    Contact{Nostr: customerPK.Hex()},
+		Items:      []OrderItem{{ProductID: "hat1", Quantity: 1}},
+		ShippingID: "world",
+	}
+	evt, err := CreateMessage(ctx, customer, merchantPK, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := evt.CheckSignature(); !ok || evt.Kind != nostr.KindEncryptedDirectMessage {
+		t.Fatalf("invalid message event")
+	}
+	msg, err := ReadMessage(ctx, merchant, &evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := msg.(*Order); !ok || got.Address != "somewhere" || got.Items[0].ProductID != "hat1" {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+
+	content, err := EncodeMessage(&PaymentRequest{ID: "o1", PaymentOptions: []PaymentOption{{Type: "ln", Link: "lnbc1..."}}})
+	if err != nil || !strings.HasSuffix(content, `"type":1}`) {
+		t.Fatalf("unexpected content %s, %v", content, err)
+	}
+	if msg, _ := ParseMessage(content); msg.OrderID() != "o1" || msg.(*PaymentRequest).PaymentOptions[0].Type != "ln" {
+		t.Errorf("unexpected payment request %+v", msg)
+	}
+
+	msg, err = ParseMessage(`{"id":"o1","type":2,"message":"on its way","paid":true,"shipped":true}`)
+	if status, ok := msg.(*OrderStatus); err != nil || !ok || !status.Shipped {
+		t.Errorf("unexpected status %+v, %v", msg, err)
+	}
+	for _, bad := range []string{`{"id":"o1"}`, `{"id":"o1","type":7}`, `{"id":"o1","type":0,"items":[]}`} {
+		if _, err := ParseMessage(bad); err == nil {
+			t.Errorf("%s should be rejected", bad)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+// Package nip17 implements private direct messages: kind-14 chat messages that are sealed and
+// gift wrapped (NIP-59) separately for each participant and sent to their preferred DM relays.
+// See https://github.com/nostr-protocol/nips/blob/master/17.md for details.
+package nip17
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip59"
+)
+
+const (
+	KindChatMessage = 14
+	KindDMRelayList = 10050
+	KindSeal        = nip59.KindSeal
+	KindGiftWrap    = nip59.KindGiftWrap
+)
+
+const (
+	giftWrapLookback = 2 * 24 * time.Hour
+	publishTimeout   = 7 * time.Second
+)
+
+// GetDMRelays returns the relays pk wants to receive direct messages on, from their latest
+// kind-10050 list found on relays, or nil if they don't have one.
+func GetDMRelays(ctx context.Context, pool *nostr.SimplePool, pk nostr.PubKey, relays []string) []string {
+	var latest *nostr.Event
+	for evt := range pool.SubManyEose(ctx, relays, nostr.Filters{{
+		Kinds:   []int{KindDMRelayList},
+		Authors: []nostr.PubKey{pk},
+	}}) {
+		if latest == nil || evt.IsNewerThan(latest) {
+			latest = evt
+		}
+	}
+	if latest == nil {
+		return nil
+	}
+
+	var urls []string
+	for _, tag := range latest.Tags.GetAll([]string{"relay", ""}) {
+		if url := nostr.NormalizeURL(tag.Value()); url != "" {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+// PrepareMessage builds a chat message with content sent by the owner of signer to recipients
+// and returns one gift wrap for each recipient and one for the sender, keyed by who can open it.
+// tags are added to the message, after the "p" tags of the recipients. modify, if given, is
+// called on the message before it is wrapped.
+func PrepareMessage(
+	ctx context.Context,
+	signer nostr.Signer,
+	recipients []nostr.PubKey,
+	content string,
+	tags nostr.Tags,
+	modify func(*nostr.Event),
+) (map[nostr.PubKey]nostr.Event, error) {
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("a message needs at least one recipient")
+	}
+	us, err := signer.GetPublicKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get our public key: %w", err)
+	}
+
+	rumor := nostr.Event{
+		Kind:      KindChatMessage,
+		Content:   content,
+		CreatedAt: nostr.Now(),
+		Tags:      make(nostr.Tags, 0, len(recipients)+len(tags)),
+	}
+	for _, pk := range recipients {
+		rumor.Tags = append(rumor.Tags, nostr.Tag{"p", pk.Hex()})
+	}
+	rumor.Tags = append(rumor.Tags, tags...)
+	if modify != nil {
+		modify(&rumor)
+	}
+
+	wraps := make(map[nostr.PubKey]nostr.Event, len(recipients)+1)
+	for _, pk := range append([]nostr.PubKey{us}, recipients...) {
+		if _, ok := wraps[pk]; ok {
+			continue
+		}
+		gw, err := nip59.GiftWrap(ctx, rumor, signer, pk, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to wrap message for %s: %w", pk, err)
+		}
+		wraps[pk] = gw
+	}
+	return wraps, nil
+}
+
+// SendMessage prepares a message with PrepareMessage and publishes each gift wrap to the DM
+// relays of the participant it is for, as found on relays. Participants without a DM relay list
+// can't receive messages, so an error is returned if any recipient doesn't have one; the
+// message is still delivered to everybody else.
+func SendMessage(
+	ctx context.Context,
+	pool *nostr.SimplePool,
+	signer nostr.Signer,
+	relays []string,
+	recipients []nostr.PubKey,
+	content string,
+	tags nostr.Tags,
+) error {
+	wraps, err := PrepareMessage(ctx, signer, recipients, content, tags, nil)
+	if err != nil {
+		return err
+	}
+	return PublishWraps(ctx, pool, relays, wraps)
+}
+
+// PublishWraps publishes gift wraps returned by PrepareMessage to the DM relays of the
+// participant each one is for, as found on relays.
+func PublishWraps(ctx context.Context, pool *nostr.SimplePool, relays []string, wraps map[nostr.PubKey]nostr.Event) error {
+	var mu sync.Mutex
+	var errs []string
+	wg := sync.WaitGroup{}
+	for pk, gw := range wraps {
+		wg.Add(1)
+		go func(pk nostr.PubKey, gw nostr.Event) {
+			defer wg.Done()
+			err := publishWrap(ctx, pool, pk, relays, gw)
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, err.Error())
+				mu.Unlock()
+			}
+		}(pk, gw)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return fmt.Errorf("message wasn't delivered to everybody: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func publishWrap(ctx context.Context, pool *nostr.SimplePool, pk nostr.PubKey, relays []string, gw nostr.Event) error {
+	dmRelays := GetDMRelays(ctx, pool, pk, relays)
+	if len(dmRelays) == 0 {
+		return fmt.Errorf("%s has no DM relays", pk)
+	}
+
+	for _, url := range dmRelays {
+		relay, err := pool.EnsureRelay(url)
+		if err != nil {
+			continue
+		}
+		publishCtx, cancel := context.WithTimeout(ctx, publishTimeout)
+		status, _ := relay.Publish(publishCtx, gw)
+		cancel()
+		if status == nostr.PublishStatusSucceeded {
+			return nil
+		}
+	}
+	return fmt.Errorf("none of the DM relays of %s accepted the message", pk)
+}
+
+// ListenForMessages subscribes to the gift wraps sent to the owner of signer on relays (usually
+// their own DM relays) and returns the chat messages inside them, until ctx is canceled.
+// Gift wraps have randomized timestamps, so the subscription looks two days before since.
+// Wraps that can't be opened and rumors that aren't chat messages are skipped.
+func ListenForMessages(
+	ctx context.Context,
+	pool *nostr.SimplePool,
+	signer nostr.Signer,
+	relays []string,
+	since time.Time,
+) (chan nostr.Event, error) {
+	us, err := signer.GetPublicKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get our public key: %w", err)
+	}
+
+	lookback := since.Add(-giftWrapLookback)
+	filter := nostr.Filter{
+		Kinds: []int{KindGiftWrap},
+		Tags:  nostr.TagMap{"p": []string{us.Hex()}},
+	}
+	if !since.IsZero() {
+		filter.Since = &lookback
+	}
+
+	messages := make(chan nostr.Event)
+	go func() {
+		defer close(messages)
+		for gw := range pool.SubMany(ctx, relays, nostr.Filters{filter}) {
+			rumor, err := nip59.GiftUnwrap(ctx, *gw, signer)
+			if err != nil || rumor.Kind != KindChatMessage {
+				continue
+			}
+			if !since.IsZero() && rumor.CreatedAt.Before(since) {
+				continue
+			}
+			select {
+			case messages <- rumor:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return messages, nil
+}
@@ -0,0 +1,42 @@
+package nip17
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/keyer"
+	"github.com/nbd-wtf/go-nostr/nip59"
+)
+
+func TestPrepareMessage(t *testing.T) {
+	ctx := context.Background()
+	alice, _ := keyer.NewPlainKeySigner(nostr.GeneratePrivateKey())
+	bob, _ := keyer.NewPlainKeySigner(nostr.GeneratePrivateKey())
+	carol, _ := keyer.NewPlainKeySigner(nostr.GeneratePrivateKey())
+	alicePK, _ := alice.GetPublicKey(ctx)
+	bobPK, _ := bob.GetPublicKey(ctx)
+	carolPK, _ := carol.GetPublicKey(ctx)
+
+	wraps, err := PrepareMessage(ctx, alice, []nostr.PubKey{bobPK, carolPK}, "hi both", nostr.Tags{{"subject", "party"}}, nil)
+	if err != nil {
+		t.Fatalf("failed to prepare: %s", err)
+	}
+	if len(wraps) != 3 {
+		t.Fatalf("expected 3 gift wraps, got %d", len(wraps))
+	}
+
+	for _, participant := range []nostr.Signer{alice, bob, carol} {
+		pk, _ := participant.GetPublicKey(ctx)
+		msg, err := nip59.GiftUnwrap(ctx, wraps[pk], participant)
+		if err != nil {
+			t.Fatalf("%s failed to unwrap: %s", pk, err)
+		}
+		if msg.Kind != KindChatMessage || msg.PubKey != alicePK || msg.Content != "hi both" {
+			t.Fatalf("unexpected message: %v", msg)
+		}
+		if len(msg.Tags.GetAll([]string{"p", ""})) != 2 || msg.Tags.GetFirst([]string{"subject", "party"}) == nil {
+			t.Fatalf("unexpected tags: %v", msg.Tags)
+		}
+	}
+}
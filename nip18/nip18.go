@@ -0,0 +1,174 @@
+// Package nip18 implements reposts, kind 6 for notes and kind 16 for everything else, and
+// quote reposts, notes that mention another event with a "q" tag.
+// See https://github.com/nostr-protocol/nips/blob/master/18.md for details.
+package nip18
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+const (
+	KindRepost        = nostr.KindBoost
+	KindGenericRepost = 16
+)
+
+// ErrNotEmbedded is returned by GetRepostedEvent when the repost doesn't have the event in
+// its content, it has to be fetched with the pointer from GetRepostedPointer then.
+var ErrNotEmbedded = errors.New("reposted event is not embedded")
+
+// CreateRepost returns an unsigned repost of evt, found at relayHint if it isn't empty: kind 6
+// for notes and kind 16 with a "k" tag for other kinds. The event goes in the content as JSON,
+// unless it is protected, as relays won't take it from anyone but its author.
+func CreateRepost(evt *nostr.Event, relayHint string) nostr.Event {
+	repost := nostr.Event{
+		Kind:      KindRepost,
+		CreatedAt: nostr.Now(),
+		Tags: nostr.Tags{
+			nostr.EventTag(evt.ID, relayHint, ""),
+		},
+	}
+	if evt.IsReplaceable() || evt.IsAddressable() {
+		address := nostr.EntityPointer{PublicKey: evt.PubKey, Kind: evt.Kind, Identifier: evt.Identifier()}.Address()
+		repost.Tags = append(repost.Tags, nostr.AddressTag(address, relayHint))
+	}
+	repost.Tags = append(repost.Tags, nostr.Tag{"p", evt.PubKey.Hex()})
+	if evt.Kind != nostr.KindTextNote {
+		repost.Kind = KindGenericRepost
+		repost.Tags = append(repost.Tags, nostr.Tag{"k", strconv.Itoa(evt.Kind)})
+	}
+
+	if !evt.IsProtected() {
+		if j, err := evt.MarshalJSON(); err == nil {
+			repost.Content = string(j)
+		}
+	}
+	return repost
+}
+
+// IsRepost tells if evt is a repost of either kind.
+func IsRepost(evt *nostr.Event) bool {
+	return evt.Kind == KindRepost || evt.Kind == KindGenericRepost
+}
+
+// GetRepostedPointer returns a pointer to the event repost reposts, from its "e" tag, or its
+// "a" tag for addressable events without one.
+func GetRepostedPointer(repost *nostr.Event) (nostr.Pointer, error) {
+	if !IsRepost(repost) {
+		return nil, fmt.Errorf("kind %d event is not a repost", repost.Kind)
+	}
+	tag := repost.Tags.GetFirst([]string{"e", ""})
+	if tag == nil {
+		tag = repost.Tags.GetFirst([]string{"a", ""})
+	}
+	if tag == nil {
+		return nil, fmt.Errorf("repost doesn't reference an event")
+	}
+	ptr, err := nostr.PointerFromTag(*tag)
+	if err != nil {
+		return nil, err
+	}
+	if ep, ok := ptr.(nostr.EventPointer); ok {
+		if p := repost.Tags.GetFirst([]string{"p", ""}); p != nil && ep.Author == nostr.ZeroPubKey {
+			if pk, err := nostr.PubKeyFromHex(p.Value()); err == nil {
+				ep.Author = pk
+			}
+		}
+		return ep, nil
+	}
+	return ptr, nil
+}
+
+// GetRepostedEvent returns the event embedded in the content of repost, checking that it is
+// the one its tags reference, that its id is right and that it is signed. It fails with
+// ErrNotEmbedded if the content is empty.
+func GetRepostedEvent(repost *nostr.Event) (*nostr.Event, error) {
+	ptr, err := GetRepostedPointer(repost)
+	if err != nil {
+		return nil, err
+	}
+	if repost.Content == "" {
+		return nil, ErrNotEmbedded
+	}
+
+	evt := &nostr.Event{}
+	if err := evt.UnmarshalJSON([]byte(repost.Content)); err != nil {
+		return nil, fmt.Errorf("invalid reposted event: %w", err)
+	}
+	switch ptr := ptr.(type) {
+	case nostr.EventPointer:
+		if evt.ID != ptr.ID {
+			return nil, fmt.Errorf("reposted event is %s, but the repost references %s", evt.ID, ptr.ID)
+		}
+	case nostr.EntityPointer:
+		if evt.Kind != ptr.Kind || evt.PubKey != ptr.PublicKey || evt.Identifier() != ptr.Identifier {
+			return nil, fmt.Errorf("reposted event is not %s", ptr.Address())
+		}
+	}
+	if evt.GetID() != evt.ID {
+		return nil, fmt.Errorf("reposted event has the wrong id")
+	}
+	if ok, err := evt.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("failed to check the signature of the reposted event: %w", err)
+	} else if !ok {
+		return nil, fmt.Errorf("reposted event has an invalid signature")
+	}
+	return evt, nil
+}
+
+// QuoteTag returns the "q" tag quoting evt, found at relayHint if it isn't empty. Addressable
+// events are quoted by address, so the quote follows their updates.
+func QuoteTag(evt *nostr.Event, relayHint string) nostr.Tag {
+	if evt.IsAddressable() {
+		address := nostr.EntityPointer{PublicKey: evt.PubKey, Kind: evt.Kind, Identifier: evt.Identifier()}.Address()
+		tag := nostr.AddressTag(address, relayHint)
+		tag[0] = "q"
+		return tag
+	}
+	// the author goes where "e" tags have the marker
+	tag := nostr.EventTag(evt.ID, relayHint, evt.PubKey.Hex())
+	tag[0] = "q"
+	return tag
+}
+
+// CreateQuote returns an unsigned note with content quoting evt: a nostr: URI for it is added
+// at the end of the content, along with its "q" tag and a "p" tag for its author.
+func CreateQuote(content string, evt *nostr.Event, relayHint string) (nostr.Event, error) {
+	var relays []string
+	if relayHint != "" {
+		relays = []string{relayHint}
+	}
+	var ptr nostr.Pointer = nostr.EventPointer{ID: evt.ID, Relays: relays, Author: evt.PubKey, Kind: evt.Kind}
+	if evt.IsAddressable() {
+		ptr = nostr.EntityPointer{PublicKey: evt.PubKey, Kind: evt.Kind, Identifier: evt.Identifier(), Relays: relays}
+	}
+	code, err := nip19.EncodePointer(ptr)
+	if err != nil {
+		return nostr.Event{}, fmt.Errorf("failed to encode the quoted event: %w", err)
+	}
+	if content != "" {
+		content += "\n\n"
+	}
+
+	return nostr.Event{
+		Kind:      nostr.KindTextNote,
+		CreatedAt: nostr.Now(),
+		Content:   content + "nostr:" + code,
+		Tags:      nostr.Tags{QuoteTag(evt, relayHint), {"p", evt.PubKey.Hex()}},
+	}, nil
+}
+
+// GetQuotes returns pointers to the events evt quotes with "q" tags, skipping invalid ones.
+func GetQuotes(evt *nostr.Event) []nostr.Pointer {
+	var quotes []nostr.Pointer
+	for _, tag := range evt.Tags.GetAll([]string{"q", ""}) {
+		if ptr, err := nostr.PointerFromTag(tag); err == nil {
+			quotes = append(quotes, ptr)
+		}
+	}
+	return quotes
+}
@@ -0,0 +1,92 @@
+package nip18
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func signed(t *testing.T, kind int, tags nostr.Tags) *nostr.Event {
+	sk := nostr.GeneratePrivateKey()
+	pk, _ := nostr.GetPublicKey(sk)
+	evt := &nostr.Event{PubKey: nostr.MustPubKeyFromHex(pk), Kind: kind, CreatedAt: nostr.Now(), Content: "hello", Tags: tags}
+	if err := evt.Sign(sk); err != nil {
+		t.Fatal(err)
+	}
+	return evt
+}
+
+func TestRepost(t *testing.T) {
+	note := signed(t, nostr.KindTextNote, nostr.Tags{})
+	repost := CreateRepost(note, "wss://relay.example.com")
+	if repost.Kind != KindRepost || repost.Tags.GetFirst([]string{"e", note.ID.Hex(), "wss://relay.example.com"}) == nil ||
+		repost.Tags.GetFirst([]string{"p", note.PubKey.Hex()}) == nil {
+		t.Fatalf("unexpected repost %v", repost)
+	}
+	got, err := GetRepostedEvent(&repost)
+	if err != nil || got.ID != note.ID {
+		t.Fatalf("reposted event read as %v, %v", got, err)
+	}
+	ptr, err := GetRepostedPointer(&repost)
+	if err != nil || ptr.(nostr.EventPointer).Author != note.PubKey {
+		t.Fatalf("unexpected pointer %v, %v", ptr, err)
+	}
+
+	// tampered events are refused
+	tampered := strings.Replace(repost.Content, `"hello"`, `"bye"`, 1)
+	if _, err := GetRepostedEvent(&nostr.Event{Kind: KindRepost, Tags: repost.Tags, Content: tampered}); err == nil {
+		t.Fatalf("tampered event accepted")
+	}
+	other := signed(t, nostr.KindTextNote, nostr.Tags{})
+	swapped := repost
+	swapped.Content = CreateRepost(other, "").Content
+	if _, err := GetRepostedEvent(&swapped); err == nil {
+		t.Fatalf("event not referenced by the repost accepted")
+	}
+
+	article := signed(t, 30023, nostr.Tags{{"d", "post"}})
+	generic := CreateRepost(article, "")
+	if generic.Kind != KindGenericRepost || generic.Tags.GetFirst([]string{"k", "30023"}) == nil ||
+		generic.Tags.GetFirst([]string{"a", "30023:" + article.PubKey.Hex() + ":post"}) == nil {
+		t.Fatalf("unexpected generic repost %v", generic)
+	}
+	if got, err := GetRepostedEvent(&generic); err != nil || got.ID != article.ID {
+		t.Fatalf("generic repost read as %v, %v", got, err)
+	}
+
+	protected := signed(t, nostr.KindTextNote, nostr.Tags{{"-"}})
+	repost = CreateRepost(protected, "")
+	if _, err := GetRepostedEvent(&repost); !errors.Is(err, ErrNotEmbedded) {
+		t.Fatalf("protected event embedded, %v", err)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	note := signed(t, nostr.KindTextNote, nostr.Tags{})
+	quote, err := CreateQuote("look at this", note, "wss://relay.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(quote.Content, "look at this\n\nnostr:nevent1") {
+		t.Fatalf("unexpected content %s", quote.Content)
+	}
+	quotes := GetQuotes(&quote)
+	if len(quotes) != 1 {
+		t.Fatalf("unexpected quotes %v", quotes)
+	}
+	ep := quotes[0].(nostr.EventPointer)
+	if ep.ID != note.ID || ep.Author != note.PubKey || ep.Relays[0] != "wss://relay.example.com" {
+		t.Fatalf("unexpected quote pointer %+v", ep)
+	}
+
+	article := signed(t, 30023, nostr.Tags{{"d", "post"}})
+	quote, _ = CreateQuote("", article, "")
+	if !strings.HasPrefix(quote.Content, "nostr:naddr1") {
+		t.Fatalf("unexpected content %s", quote.Content)
+	}
+	if quotes := GetQuotes(&quote); len(quotes) != 1 || quotes[0].(nostr.EntityPointer).Identifier != "post" {
+		t.Fatalf("unexpected quotes %v", quotes)
+	}
+}
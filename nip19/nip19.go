@@ -8,6 +8,7 @@ import (
 
 	"github.com/btcsuite/btcd/btcutil/bech32"
 	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/internal/tlv"
 )
 
 func Decode(bech32string string) (prefix string, value any, err error) {
@@ -28,86 +29,43 @@ func Decode(bech32string string) (prefix string, value any, err error) {
 		}
 
 		return prefix, hex.EncodeToString(data[0:32]), nil
-	case "nprofile":
-		var result nostr.ProfilePointer
-		curr := 0
-		for {
-			t, v := readTLVEntry(data[curr:])
-			if v == nil {
-				// end here
-				if result.PublicKey == "" {
-					return prefix, result, fmt.Errorf("no pubkey found for nprofile")
-				}
-
-				return prefix, result, nil
-			}
+	case "nprofile", "nevent", "naddr":
+		entries, err := tlv.Decode(data)
+		if err != nil {
+			return prefix, nil, fmt.Errorf("invalid %s: %w", prefix, err)
+		}
 
-			switch t {
-			case TLVDefault:
-				result.PublicKey = hex.EncodeToString(v)
-			case TLVRelay:
-				result.Relays = append(result.Relays, string(v))
-			default:
-				// ignore
+		switch prefix {
+		case "nprofile":
+			result := nostr.ProfilePointer{Relays: entries.Relays}
+			if len(entries.Special) != 32 {
+				return prefix, result, fmt.Errorf("no pubkey found for nprofile")
 			}
-
-			curr = curr + 2 + len(v)
-		}
-	case "nevent":
-		var result nostr.EventPointer
-		curr := 0
-		for {
-			t, v := readTLVEntry(data[curr:])
-			if v == nil {
-				// end here
-				if result.ID == "" {
-					return prefix, result, fmt.Errorf("no id found for nevent")
-				}
-
-				return prefix, result, nil
+			result.PublicKey = *(*nostr.PubKey)(entries.Special)
+			return prefix, result, nil
+		case "nevent":
+			result := nostr.EventPointer{Relays: entries.Relays, Kind: entries.Kind}
+			if len(entries.Author) == 32 {
+				result.Author = *(*nostr.PubKey)(entries.Author)
 			}
-
-			switch t {
-			case TLVDefault:
-				result.ID = hex.EncodeToString(v)
-			case TLVRelay:
-				result.Relays = append(result.Relays, string(v))
-			case TLVAuthor:
-				result.Author = hex.EncodeToString(v)
-			default:
-				// ignore
+			if len(entries.Special) != 32 {
+				return prefix, result, fmt.Errorf("no id found for nevent")
 			}
-
-			curr = curr + 2 + len(v)
-		}
-	case "naddr":
-		var result nostr.EntityPointer
-		curr := 0
-		for {
-			t, v := readTLVEntry(data[curr:])
-			if v == nil {
-				// end here
-				if result.Kind == 0 || result.Identifier == "" || result.PublicKey == "" {
-					return prefix, result, fmt.Errorf("incomplete naddr")
-				}
-
-				return prefix, result, nil
+			result.ID = *(*nostr.ID)(entries.Special)
+			return prefix, result, nil
+		default:
+			result := nostr.EntityPointer{
+				Identifier: string(entries.Special),
+				Relays:     entries.Relays,
+				Kind:       entries.Kind,
 			}
-
-			switch t {
-			case TLVDefault:
-				result.Identifier = string(v)
-			case TLVRelay:
-				result.Relays = append(result.Relays, string(v))
-			case TLVAuthor:
-				result.PublicKey = hex.EncodeToString(v)
-			case TLVKind:
-				result.Kind = int(binary.BigEndian.Uint32(v))
-			default:
-				// ignore
+			if len(entries.Author) == 32 {
+				result.PublicKey = *(*nostr.PubKey)(entries.Author)
 			}
-
-			curr = curr + 2 + len(v)
+			if result.Kind == 0 || result.Identifier == "" || len(entries.Author) != 32 {
+				return prefix, result, fmt.Errorf("incomplete naddr")
+			}
+			return prefix, result, nil
 		}
 	}
 
@@ -162,10 +120,10 @@ func EncodeProfile(publicKeyHex string, relays []string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("invalid pubkey '%s': %w", publicKeyHex, err)
 	}
-	writeTLVEntry(buf, TLVDefault, pubkey)
+	tlv.Write(buf, TLVDefault, pubkey)
 
 	for _, url := range relays {
-		writeTLVEntry(buf, TLVRelay, []byte(url))
+		tlv.Write(buf, TLVRelay, []byte(url))
 	}
 
 	bits5, err := bech32.ConvertBits(buf.Bytes(), 8, 5, true)
@@ -177,19 +135,47 @@ func EncodeProfile(publicKeyHex string, relays []string) (string, error) {
 }
 
 func EncodeEvent(eventIdHex string, relays []string, author string) (string, error) {
+	return encodeEvent(eventIdHex, relays, author, 0)
+}
+
+// EncodePointer encodes a ProfilePointer as nprofile, an EventPointer as nevent, with its kind
+// if known, and an EntityPointer as naddr.
+func EncodePointer(ptr nostr.Pointer) (string, error) {
+	switch p := ptr.(type) {
+	case nostr.ProfilePointer:
+		return EncodeProfile(p.PublicKey.Hex(), p.Relays)
+	case nostr.EventPointer:
+		author := ""
+		if p.Author != nostr.ZeroPubKey {
+			author = p.Author.Hex()
+		}
+		return encodeEvent(p.ID.Hex(), p.Relays, author, p.Kind)
+	case nostr.EntityPointer:
+		return EncodeEntity(p.PublicKey.Hex(), p.Kind, p.Identifier, p.Relays)
+	}
+	return "", fmt.Errorf("unsupported pointer %T", ptr)
+}
+
+func encodeEvent(eventIdHex string, relays []string, author string, kind int) (string, error) {
 	buf := &bytes.Buffer{}
 	id, err := hex.DecodeString(eventIdHex)
 	if err != nil || len(id) != 32 {
 		return "", fmt.Errorf("invalid id '%s': %w", eventIdHex, err)
 	}
-	writeTLVEntry(buf, TLVDefault, id)
+	tlv.Write(buf, TLVDefault, id)
 
 	for _, url := range relays {
-		writeTLVEntry(buf, TLVRelay, []byte(url))
+		tlv.Write(buf, TLVRelay, []byte(url))
 	}
 
 	if pubkey, _ := hex.DecodeString(author); len(pubkey) == 32 {
-		writeTLVEntry(buf, TLVAuthor, pubkey)
+		tlv.Write(buf, TLVAuthor, pubkey)
+	}
+
+	if kind != 0 {
+		kindBytes := make([]byte, 4)
+		binary.BigEndian.PutUint32(kindBytes, uint32(kind))
+		tlv.Write(buf, TLVKind, kindBytes)
 	}
 
 	bits5, err := bech32.ConvertBits(buf.Bytes(), 8, 5, true)
@@ -203,21 +189,21 @@ func EncodeEvent(eventIdHex string, relays []string, author string) (string, err
 func EncodeEntity(publicKey string, kind int, identifier string, relays []string) (string, error) {
 	buf := &bytes.Buffer{}
 
-	writeTLVEntry(buf, TLVDefault, []byte(identifier))
+	tlv.Write(buf, TLVDefault, []byte(identifier))
 
 	for _, url := range relays {
-		writeTLVEntry(buf, TLVRelay, []byte(url))
+		tlv.Write(buf, TLVRelay, []byte(url))
 	}
 
 	pubkey, err := hex.DecodeString(publicKey)
 	if err != nil {
 		return "", fmt.Errorf("invalid pubkey '%s': %w", pubkey, err)
 	}
-	writeTLVEntry(buf, TLVAuthor, pubkey)
+	tlv.Write(buf, TLVAuthor, pubkey)
 
 	kindBytes := make([]byte, 4)
 	binary.BigEndian.PutUint32(kindBytes, uint32(kind))
-	writeTLVEntry(buf, TLVKind, kindBytes)
+	tlv.Write(buf, TLVKind, kindBytes)
 
 	bits5, err := bech32.ConvertBits(buf.Bytes(), 8, 5, true)
 	if err != nil {
@@ -1,8 +1,10 @@
 package nip19
 
 import (
+	"reflect"
 	"testing"
 
+	"github.com/btcsuite/btcd/btcutil/bech32"
 	"github.com/nbd-wtf/go-nostr"
 )
 
@@ -59,7 +61,7 @@ func TestDecodeNprofile(t *testing.T) {
 		t.Error("value returned of wrong type")
 	}
 
-	if pp.PublicKey != "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d" {
+	if pp.PublicKey.Hex() != "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d" {
 		t.Error("decoded invalid public key")
 	}
 
@@ -84,7 +86,7 @@ func TestDecodeOtherNprofile(t *testing.T) {
 		t.Error("value returned of wrong type")
 	}
 
-	if pp.PublicKey != "e8b487c079b0f67c695ae6c4c2552a47f38adfa2533cc5926bd2c102942fdcb7" {
+	if pp.PublicKey.Hex() != "e8b487c079b0f67c695ae6c4c2552a47f38adfa2533cc5926bd2c102942fdcb7" {
 		t.Error("decoded invalid public key")
 	}
 
@@ -133,7 +135,7 @@ func TestEncodeDecodeNaddr(t *testing.T) {
 		t.Error("returned invalid prefix")
 	}
 	ep := data.(nostr.EntityPointer)
-	if ep.PublicKey != "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d" {
+	if ep.PublicKey.Hex() != "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d" {
 		t.Error("returned wrong pubkey")
 	}
 	if ep.Kind != 30023 {
@@ -156,7 +158,7 @@ func TestDecodeNaddrWithoutRelays(t *testing.T) {
 		t.Error("returned invalid prefix")
 	}
 	ep := data.(nostr.EntityPointer)
-	if ep.PublicKey != "7fa56f5d6962ab1e3cd424e758c3002b8665f7b0d8dcee9fe9e288d7751ac194" {
+	if ep.PublicKey.Hex() != "7fa56f5d6962ab1e3cd424e758c3002b8665f7b0d8dcee9fe9e288d7751ac194" {
 		t.Error("returned wrong pubkey")
 	}
 	if ep.Kind != 30023 {
@@ -194,11 +196,11 @@ func TestEncodeDecodeNEventTestEncodeDecodeNEvent(t *testing.T) {
 		t.Errorf("'%s' should be an nevent, not %v", nevent, res)
 	}
 
-	if ep.Author != "7fa56f5d6962ab1e3cd424e758c3002b8665f7b0d8dcee9fe9e288d7751abb88" {
+	if ep.Author.Hex() != "7fa56f5d6962ab1e3cd424e758c3002b8665f7b0d8dcee9fe9e288d7751abb88" {
 		t.Error("wrong author")
 	}
 
-	if ep.ID != "45326f5d6962ab1e3cd424e758c3002b8665f7b0d8dcee9fe9e288d7751ac194" {
+	if ep.ID.Hex() != "45326f5d6962ab1e3cd424e758c3002b8665f7b0d8dcee9fe9e288d7751ac194" {
 		t.Error("wrong id")
 	}
 
@@ -206,3 +208,52 @@ func TestEncodeDecodeNEventTestEncodeDecodeNEvent(t *testing.T) {
 		t.Error("wrong relay")
 	}
 }
+
+func TestEncodePointer(t *testing.T) {
+	pointers := []nostr.Pointer{
+		nostr.ProfilePointer{
+			PublicKey: nostr.MustPubKeyFromHex("3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"),
+			Relays:    []string{"wss://r.x.com"},
+		},
+		nostr.EventPointer{
+			ID:     nostr.MustIDFromHex("45326f5d6962ab1e3cd424e758c3002b8665f7b0d8dcee9fe9e288d7751ac194"),
+			Relays: []string{"wss://banana.com"},
+			Author: nostr.MustPubKeyFromHex("7fa56f5d6962ab1e3cd424e758c3002b8665f7b0d8dcee9fe9e288d7751abb88"),
+			Kind:   30023,
+		},
+		nostr.EntityPointer{
+			PublicKey:  nostr.MustPubKeyFromHex("3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"),
+			Kind:       30023,
+			Identifier: "banana",
+			Relays:     []string{"wss://relay.nostr.example.mydomain.example.com"},
+		},
+	}
+	for _, ptr := range pointers {
+		code, err := EncodePointer(ptr)
+		if err != nil {
+			t.Fatalf("failed to encode %+v: %s", ptr, err)
+		}
+		_, decoded, err := Decode(code)
+		if err != nil {
+			t.Fatalf("failed to decode %s: %s", code, err)
+		}
+		if !reflect.DeepEqual(decoded, ptr) {
+			t.Errorf("pointer changed on a round trip: %+v != %+v", decoded, ptr)
+		}
+		if parsed, err := nostr.ParsePointer("nostr:" + code); err != nil || !reflect.DeepEqual(parsed, ptr) {
+			t.Errorf("nostr.ParsePointer disagrees: %+v, %v", parsed, err)
+		}
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	// a relay entry that says it is longer than what follows
+	bits5, _ := bech32.ConvertBits([]byte{0, 32, 1, 2, 3}, 8, 5, true)
+	code, _ := bech32.Encode("nprofile", bits5)
+	if _, _, err := Decode(code); err == nil {
+		t.Fatal("truncated nprofile was decoded")
+	}
+	if _, err := nostr.ParsePointer(code); err == nil {
+		t.Fatal("truncated nprofile was parsed")
+	}
+}
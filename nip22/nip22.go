@@ -0,0 +1,223 @@
+// Package nip22 implements kind-1111 comments, which reply to anything other than kind-1 notes:
+// articles, files, other comments or things outside of Nostr like web pages. Comments tag the
+// root of the discussion with uppercase "E", "A", "I", "K" and "P" tags and the item they
+// reply to directly with the lowercase ones.
+// See https://github.com/nostr-protocol/nips/blob/master/22.md for details.
+package nip22
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip73"
+)
+
+const KindComment = 1111
+
+// Target is what a comment is about, its root, or what it replies to, its parent. It is an
+// event, an addressable or replaceable event, or both when the version commented on matters,
+// or an external id.
+type Target struct {
+	ID       string            // hex id of the event, "E" and "e" tags
+	Address  string            // "<kind>:<pubkey>:<d tag>" of the event, "A" and "a" tags
+	External *nip73.ExternalID // "I" and "i" tags
+	Kind     string            // kind number of the event, or kind of the external id
+	Author   string            // hex public key of the author of the event, optional
+	Relay    string            // where the event can be found, optional
+}
+
+// EventTarget is evt, found at relay if it isn't empty. Replaceable and addressable events are
+// targeted by their address as well as by their id.
+func EventTarget(evt *nostr.Event, relay string) Target {
+	target := Target{
+		ID:     evt.ID.Hex(),
+		Kind:   strconv.Itoa(evt.Kind),
+		Author: evt.PubKey.Hex(),
+		Relay:  relay,
+	}
+	if evt.IsReplaceable() || evt.IsAddressable() {
+		target.Address = nostr.EntityPointer{
+			PublicKey:  evt.PubKey,
+			Kind:       evt.Kind,
+			Identifier: evt.Identifier(),
+		}.Address()
+	}
+	return target
+}
+
+// ExternalTarget is something outside of Nostr, see package nip73.
+func ExternalTarget(id nip73.ExternalID) Target {
+	return Target{External: &id, Kind: string(id.Kind)}
+}
+
+func (t Target) valid() error {
+	if t.ID == "" && t.Address == "" && t.External == nil {
+		return fmt.Errorf("target has no event, address or external id")
+	}
+	if t.Kind == "" {
+		return fmt.Errorf("target has no kind")
+	}
+	if _, err := nostr.IDFromHex(t.ID); t.ID != "" && err != nil {
+		return fmt.Errorf("invalid id '%s': %w", t.ID, err)
+	}
+	if _, err := nostr.PubKeyFromHex(t.Author); t.Author != "" && err != nil {
+		return fmt.Errorf("invalid author '%s': %w", t.Author, err)
+	}
+	return nil
+}
+
+// Tags returns the tags for t, uppercase when it is the root of the discussion and lowercase
+// when it is the parent. Invalid ids and authors are left out, see Comment.
+func (t Target) Tags(root bool) nostr.Tags {
+	named := func(tag nostr.Tag) nostr.Tag {
+		if root {
+			tag[0] = strings.ToUpper(tag[0])
+		}
+		return tag
+	}
+
+	var tags nostr.Tags
+	if t.Address != "" {
+		tags = append(tags, named(nostr.AddressTag(t.Address, t.Relay)))
+	}
+	if id, err := nostr.IDFromHex(t.ID); err == nil {
+		// the author goes where "e" tags usually have the marker
+		tags = append(tags, named(nostr.EventTag(id, t.Relay, t.Author)))
+	}
+	if t.External != nil {
+		tag := nostr.Tag{"i", t.External.Value}
+		if t.External.Hint != "" {
+			tag = append(tag, t.External.Hint)
+		}
+		tags = append(tags, named(tag))
+	}
+	tags = append(tags, named(nostr.Tag{"k", t.Kind}))
+	if pk, err := nostr.PubKeyFromHex(t.Author); err == nil {
+		tags = append(tags, named(nostr.PubKeyTag(pk, t.Relay)))
+	}
+	return tags
+}
+
+// item returns the i-th item of tag, or "" if it is too short.
+func item(tag *nostr.Tag, i int) string {
+	if len(*tag) > i {
+		return (*tag)[i]
+	}
+	return ""
+}
+
+// Comment returns an unsigned comment on root replying to parent, which is root itself for
+// top-level comments.
+func Comment(content string, root, parent Target) (nostr.Event, error) {
+	if err := root.valid(); err != nil {
+		return nostr.Event{}, fmt.Errorf("invalid root: %w", err)
+	}
+	if err := parent.valid(); err != nil {
+		return nostr.Event{}, fmt.Errorf("invalid parent: %w", err)
+	}
+
+	return nostr.Event{
+		Kind:      KindComment,
+		CreatedAt: nostr.Now(),
+		Content:   content,
+		Tags:      append(root.Tags(true), parent.Tags(false)...),
+	}, nil
+}
+
+// Reply returns an unsigned comment replying to parent, found at relay if it isn't empty. If
+// parent is a comment the reply has the same root, otherwise parent is the root.
+func Reply(content string, parent *nostr.Event, relay string) (nostr.Event, error) {
+	target := EventTarget(parent, relay)
+	if parent.Kind != KindComment {
+		return Comment(content, target, target)
+	}
+	root, ok := Root(parent)
+	if !ok {
+		return nostr.Event{}, fmt.Errorf("comment %s has no root", parent.ID.Hex())
+	}
+	return Comment(content, root, target)
+}
+
+// Root returns what the comment evt is about, from its uppercase tags.
+func Root(evt *nostr.Event) (Target, bool) {
+	return fromTags(evt.Tags, true)
+}
+
+// Parent returns what the comment evt replies to, from its lowercase tags.
+func Parent(evt *nostr.Event) (Target, bool) {
+	return fromTags(evt.Tags, false)
+}
+
+func fromTags(tags nostr.Tags, root bool) (Target, bool) {
+	e, a, i, k, p := "e", "a", "i", "k", "p"
+	if root {
+		e, a, i, k, p = "E", "A", "I", "K", "P"
+	}
+
+	var t Target
+	if tag := tags.GetFirst([]string{a, ""}); tag != nil {
+		if _, err := nostr.ParseAddress(tag.Value()); err == nil {
+			t.Address = tag.Value()
+			t.Relay = item(tag, 2)
+		}
+	}
+	if tag := tags.GetFirst([]string{e, ""}); tag != nil {
+		if _, err := nostr.IDFromHex(tag.Value()); err == nil {
+			t.ID = tag.Value()
+			if relay := item(tag, 2); relay != "" {
+				t.Relay = relay
+			}
+			if author := item(tag, 3); nostr.IsValidPublicKeyHex(author) {
+				t.Author = author
+			}
+		}
+	}
+	if tag := tags.GetFirst([]string{i, ""}); tag != nil {
+		if id, err := nip73.Parse(tag.Value()); err == nil {
+			id.Hint = item(tag, 2)
+			t.External = &id
+		}
+	}
+	if t.ID == "" && t.Address == "" && t.External == nil {
+		return Target{}, false
+	}
+
+	if tag := tags.GetFirst([]string{k, ""}); tag != nil {
+		t.Kind = tag.Value()
+	}
+	if tag := tags.GetFirst([]string{p, ""}); tag != nil && nostr.IsValidPublicKeyHex(tag.Value()) {
+		t.Author = tag.Value()
+	}
+	return t, true
+}
+
+// Filter matches the comments on root, at any depth.
+func Filter(root Target) nostr.Filter {
+	return filter(root, true)
+}
+
+// RepliesFilter matches the comments replying directly to parent.
+func RepliesFilter(parent Target) nostr.Filter {
+	return filter(parent, false)
+}
+
+func filter(t Target, root bool) nostr.Filter {
+	tags := make(nostr.TagMap)
+	key := func(n string) string {
+		if root {
+			return strings.ToUpper(n)
+		}
+		return n
+	}
+	switch {
+	case t.Address != "":
+		tags[key("a")] = []string{t.Address}
+	case t.ID != "":
+		tags[key("e")] = []string{t.ID}
+	case t.External != nil:
+		tags[key("i")] = []string{t.External.Value}
+	}
+	return nostr.Filter{Kinds: []int{KindComment}, Tags: tags}
+}
@@ -0,0 +1,78 @@
+package nip22
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip73"
+)
+
+func TestCommentOnArticle(t *testing.T) {
+	article := &nostr.Event{ID: nostr.ID{1}, PubKey: nostr.PubKey{2}, Kind: 30023, Tags: nostr.Tags{{"d", "post"}}}
+	root := EventTarget(article, "wss://relay.example.com")
+	if root.Address != "30023:"+article.PubKey.Hex()+":post" || root.Kind != "30023" {
+		t.Fatalf("unexpected target %+v", root)
+	}
+
+	comment, err := Reply("great post", article, "wss://relay.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if comment.Kind != KindComment || len(comment.Tags.GetAll([]string{"A", ""})) != 1 ||
+		len(comment.Tags.GetAll([]string{"e", ""})) != 1 || comment.Tags.GetFirst([]string{"K", "30023"}) == nil {
+		t.Fatalf("unexpected tags %v", comment.Tags)
+	}
+	if got, ok := Root(&comment); !ok || !reflect.DeepEqual(got, root) {
+		t.Fatalf("root read as %+v, expected %+v", got, root)
+	}
+	if got, ok := Parent(&comment); !ok || !reflect.DeepEqual(got, root) {
+		t.Fatalf("parent read as %+v, expected %+v", got, root)
+	}
+
+	// replying to the comment keeps the root
+	comment.ID = nostr.ID{3}
+	comment.PubKey = nostr.PubKey{4}
+	reply, err := Reply("thanks", &comment, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := Root(&reply); !reflect.DeepEqual(got, root) {
+		t.Fatalf("reply root is %+v", got)
+	}
+	parent, _ := Parent(&reply)
+	if parent.ID != comment.ID.Hex() || parent.Kind != "1111" || parent.Author != comment.PubKey.Hex() || parent.Address != "" {
+		t.Fatalf("reply parent is %+v", parent)
+	}
+
+	filter := Filter(root)
+	if !filter.Matches(&comment) || !filter.Matches(&reply) {
+		t.Fatalf("filter %v doesn't match the comments", filter)
+	}
+	if filter := RepliesFilter(parent); filter.Matches(&comment) || !filter.Matches(&reply) {
+		t.Fatalf("replies filter %v matches wrong comments", filter)
+	}
+}
+
+func TestCommentOnExternal(t *testing.T) {
+	page, _ := nip73.URL("https://example.com/post")
+	root := ExternalTarget(page)
+	comment, err := Comment("nice", root, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := nostr.Tags{{"I", "https://example.com/post"}, {"K", "web"}, {"i", "https://example.com/post"}, {"k", "web"}}
+	if !reflect.DeepEqual(comment.Tags, expected) {
+		t.Fatalf("unexpected tags %v", comment.Tags)
+	}
+	if got, ok := Parent(&comment); !ok || got.External == nil || got.External.Value != page.Value {
+		t.Fatalf("parent read as %+v", got)
+	}
+
+	if _, err := Comment("nowhere", Target{Kind: "1"}, root); err == nil {
+		t.Fatalf("comment without a root was built")
+	}
+	if _, ok := Root(&nostr.Event{Kind: KindComment}); ok {
+		t.Fatalf("found a root in an event without tags")
+	}
+}
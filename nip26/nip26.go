@@ -143,7 +143,7 @@ jump2:
 	}
 
 	// compute the digest
-	h := sha256.Sum256([]byte(fmt.Sprintf("nostr:delegation:%s:%s", ev.PubKey, d.tag[2])))
+	h := sha256.Sum256([]byte(fmt.Sprintf("nostr:delegation:%s:%s", ev.PubKey.Hex(), d.tag[2])))
 
 	sig, err := schnorr.ParseSignature(d.token[:])
 	if err != nil {
@@ -181,7 +181,7 @@ jump:
 	if pk, e := nostr.GetPublicKey(delegatee_sk); e != nil {
 		return fmt.Errorf("invalid delegatee secret key.")
 	} else {
-		ev.PubKey = pk
+		ev.PubKey, _ = nostr.PubKeyFromHex(pk)
 	}
 	ev.Tags = append(ev.Tags, d.Tag())
 	return ev.Sign(delegatee_sk)
@@ -0,0 +1,120 @@
+package nip27
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+// "@name" at the start or after a space, so emails like a@name.com aren't taken as placeholders
+var placeholderRegex = regexp.MustCompile(`(^|\s)@[\p{L}\p{N}_]+`)
+
+type composeOptions struct {
+	mentionMarkers bool
+}
+
+// ComposeOption customizes Compose.
+type ComposeOption func(*composeOptions)
+
+// WithMentionMarkers makes Compose tag referenced events with ["e", id, relay, "mention"] and
+// addresses with "a" tags, as older clients expect, instead of "q" tags.
+func WithMentionMarkers() ComposeOption {
+	return func(o *composeOptions) {
+		o.mentionMarkers = true
+	}
+}
+
+// Compose turns text written by a user into event content and the tags that go with it.
+// "@name" placeholders are replaced with the nostr: URI of names[name], if there, and raw
+// npub, nprofile, note, nevent and naddr codes get the "nostr:" prefix they should have.
+// Every profile referenced gets a "p" tag and every event a "q" tag, with relay hints when
+// known, and hashtags get "t" tags.
+func Compose(text string, names map[string]nostr.PubKey, opts ...ComposeOption) (string, nostr.Tags) {
+	var options composeOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	text = placeholderRegex.ReplaceAllStringFunc(text, func(match string) string {
+		at := strings.IndexByte(match, '@')
+		space, name := match[:at], match[at+1:]
+		pk, ok := names[name]
+		if !ok {
+			if pk, ok = names[strings.ToLower(name)]; !ok {
+				return match
+			}
+		}
+		npub, _ := nip19.EncodePublicKey(pk.Hex())
+		return space + "nostr:" + npub
+	})
+
+	var content strings.Builder
+	tags := make(nostr.Tags, 0)
+	seen := make(map[[2]string]bool)
+	add := func(tag nostr.Tag) {
+		// Tags.AppendUnique would match "#nostr" with "#nostrich" since it compares prefixes
+		key := [2]string{tag[0], tag[1]}
+		if !seen[key] {
+			seen[key] = true
+			tags = append(tags, tag)
+		}
+	}
+	for _, block := range Parse(text) {
+		switch block.Type {
+		case BlockReference:
+			code := strings.TrimPrefix(block.Text, "nostr:")
+			content.WriteString("nostr:" + code)
+			for _, tag := range referenceTags(block.Reference, options) {
+				add(tag)
+			}
+		case BlockHashtag:
+			content.WriteString(block.Text)
+			add(nostr.Tag{"t", block.Hashtag})
+		default:
+			content.WriteString(block.Text)
+		}
+	}
+
+	return content.String(), tags
+}
+
+func referenceTags(ref *Reference, options composeOptions) nostr.Tags {
+	relay := func(relays []string) string {
+		if len(relays) > 0 {
+			return relays[0]
+		}
+		return ""
+	}
+
+	switch {
+	case ref.Profile != nil:
+		return nostr.Tags{nostr.PubKeyTag(ref.Profile.PublicKey, relay(ref.Profile.Relays))}
+	case ref.Event != nil:
+		var tags nostr.Tags
+		if options.mentionMarkers {
+			tags = append(tags, nostr.EventTag(ref.Event.ID, relay(ref.Event.Relays), "mention"))
+		} else {
+			// the author goes where "e" tags have the marker
+			author := ""
+			if ref.Event.Author != nostr.ZeroPubKey {
+				author = ref.Event.Author.Hex()
+			}
+			tag := nostr.EventTag(ref.Event.ID, relay(ref.Event.Relays), author)
+			tag[0] = "q"
+			tags = append(tags, tag)
+		}
+		if ref.Event.Author != nostr.ZeroPubKey {
+			tags = append(tags, nostr.PubKeyTag(ref.Event.Author, ""))
+		}
+		return tags
+	case ref.Entity != nil:
+		tag := nostr.AddressTag(ref.Entity.Address(), relay(ref.Entity.Relays))
+		if !options.mentionMarkers {
+			tag[0] = "q"
+		}
+		return nostr.Tags{tag, nostr.PubKeyTag(ref.Entity.PublicKey, "")}
+	}
+	return nil
+}
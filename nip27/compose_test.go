@@ -0,0 +1,56 @@
+package nip27
+
+import (
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+func TestCompose(t *testing.T) {
+	alice := nostr.MustPubKeyFromHex("3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d")
+	bob := "82341f882b6eabcd2ba7f1ef90aad961cf074af15b9ef44a09f9d2a8fbfbe6a2"
+	aliceNpub, _ := nip19.EncodePublicKey(alice.Hex())
+	bobNprofile, _ := nip19.EncodeProfile(bob, []string{"wss://nos.lol"})
+	nevent, _ := nip19.EncodeEvent("f39e9b451a73d62abc5016cffdd294b1a904e2f34536a208874fe5e22bbd47cf", []string{"wss://relay.example.com"}, bob)
+
+	content, tags := Compose(
+		"hey @Alice and "+bobNprofile+", look at this: "+nevent+" #nostrich #nostr #nostr @nobody bob@alice.com",
+		map[string]nostr.PubKey{"alice": alice},
+	)
+
+	expected := "hey nostr:" + aliceNpub + " and nostr:" + bobNprofile + ", look at this: nostr:" + nevent + " #nostrich #nostr #nostr @nobody bob@alice.com"
+	if content != expected {
+		t.Fatalf("unexpected content:\n%s\n%s", content, expected)
+	}
+
+	expectedTags := nostr.Tags{
+		{"p", alice.Hex()},
+		{"p", bob, "wss://nos.lol"},
+		{"q", "f39e9b451a73d62abc5016cffdd294b1a904e2f34536a208874fe5e22bbd47cf", "wss://relay.example.com", bob},
+		{"t", "nostrich"},
+		{"t", "nostr"},
+	}
+	if len(tags) != len(expectedTags) {
+		t.Fatalf("unexpected tags: %v", tags)
+	}
+	for i, tag := range expectedTags {
+		if len(tags[i]) != len(tag) {
+			t.Fatalf("unexpected tag %v, expected %v", tags[i], tag)
+		}
+		for j := range tag {
+			if tags[i][j] != tag[j] {
+				t.Fatalf("unexpected tag %v, expected %v", tags[i], tag)
+			}
+		}
+	}
+
+	_, tags = Compose("nostr:"+nevent, nil, WithMentionMarkers())
+	if tags[0][0] != "e" || tags[0][3] != "mention" {
+		t.Fatalf("unexpected tags: %v", tags)
+	}
+
+	if content, _ := Compose("@alice\n@alice", map[string]nostr.PubKey{"alice": alice}); content != "nostr:"+aliceNpub+"\nnostr:"+aliceNpub {
+		t.Fatalf("unexpected content %s", content)
+	}
+}
@@ -0,0 +1,191 @@
+// Package nip27 splits event content into blocks of text, nostr references, hashtags and
+// links, so clients can render notes without their own regular expressions.
+// See https://github.com/nostr-protocol/nips/blob/master/27.md for details.
+package nip27
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+type BlockType int
+
+const (
+	BlockText BlockType = iota
+	BlockReference
+	BlockHashtag
+	BlockURL
+	BlockImage
+	BlockVideo
+)
+
+func (t BlockType) String() string {
+	switch t {
+	case BlockText:
+		return "text"
+	case BlockReference:
+		return "reference"
+	case BlockHashtag:
+		return "hashtag"
+	case BlockURL:
+		return "url"
+	case BlockImage:
+		return "image"
+	case BlockVideo:
+		return "video"
+	}
+	return "unknown"
+}
+
+// Block is a piece of content. Start and End are byte offsets, so content[Start:End] == Text.
+type Block struct {
+	Type  BlockType
+	Text  string
+	Start int
+	End   int
+
+	// set for BlockReference
+	Reference *Reference
+	// set for BlockHashtag, without the "#" and lowercased
+	Hashtag string
+}
+
+// Reference is a decoded NIP-19 code. Exactly one of the pointers is set.
+type Reference struct {
+	Prefix  string // "npub", "nprofile", "note", "nevent" or "naddr"
+	Profile *nostr.ProfilePointer
+	Event   *nostr.EventPointer
+	Entity  *nostr.EntityPointer
+}
+
+var (
+	tokenRegex = regexp.MustCompile(
+		`(?:nostr:)?(?:npub|nprofile|note|nevent|naddr)1[qpzry9x8gf2tvdw0s3jn54khce6mua7l]+` +
+			`|https?://[^\s<>"]+` +
+			`|#[\p{L}\p{N}_]+`)
+
+	imageExtensions = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true, ".avif": true, ".svg": true}
+	videoExtensions = map[string]bool{".mp4": true, ".webm": true, ".mov": true, ".m3u8": true}
+)
+
+// Parse splits content into blocks. Adjacent text is always merged into a single block, and
+// things that look like references or links but can't be decoded are left as text.
+// Both "nostr:npub1..." and bare "npub1..." references are recognized.
+func Parse(content string) []Block {
+	var blocks []Block
+	textStart := 0
+	flushText := func(end int) {
+		if end > textStart {
+			blocks = append(blocks, Block{Type: BlockText, Text: content[textStart:end], Start: textStart, End: end})
+		}
+	}
+
+	for _, loc := range tokenRegex.FindAllStringIndex(content, -1) {
+		start, end := loc[0], loc[1]
+		if start < textStart {
+			continue
+		}
+		// tokens must start at a word boundary, "abc#def" is not a hashtag
+		if start > 0 {
+			if r, _ := utf8.DecodeLastRuneInString(content[:start]); unicode.IsLetter(r) || unicode.IsDigit(r) {
+				continue
+			}
+		}
+
+		token := content[start:end]
+		var block Block
+		switch {
+		case token[0] == '#':
+			block = Block{Type: BlockHashtag, Hashtag: strings.ToLower(token[1:])}
+		case strings.HasPrefix(token, "http"):
+			token = trimURL(token)
+			end = start + len(token)
+			u, err := url.Parse(token)
+			if err != nil || u.Host == "" {
+				continue
+			}
+			block = Block{Type: BlockURL}
+			ext := strings.ToLower(path.Ext(u.Path))
+			if imageExtensions[ext] {
+				block.Type = BlockImage
+			} else if videoExtensions[ext] {
+				block.Type = BlockVideo
+			}
+		default:
+			ref, ok := decodeReference(strings.TrimPrefix(token, "nostr:"))
+			if !ok {
+				continue
+			}
+			block = Block{Type: BlockReference, Reference: ref}
+		}
+
+		flushText(start)
+		block.Text = content[start:end]
+		block.Start = start
+		block.End = end
+		blocks = append(blocks, block)
+		textStart = end
+	}
+	flushText(len(content))
+
+	return blocks
+}
+
+// trimURL removes punctuation that is probably part of the sentence, not the link.
+func trimURL(u string) string {
+	for len(u) > 0 {
+		last := u[len(u)-1]
+		if strings.IndexByte(".,;:!?'\"", last) != -1 ||
+			(last == ')' && strings.Count(u, "(") < strings.Count(u, ")")) {
+			u = u[:len(u)-1]
+			continue
+		}
+		break
+	}
+	return u
+}
+
+func decodeReference(code string) (*Reference, bool) {
+	prefix, value, err := nip19.Decode(code)
+	if err != nil {
+		return nil, false
+	}
+
+	ref := &Reference{Prefix: prefix}
+	switch v := value.(type) {
+	case nostr.ProfilePointer:
+		ref.Profile = &v
+	case nostr.EventPointer:
+		ref.Event = &v
+	case nostr.EntityPointer:
+		ref.Entity = &v
+	case string:
+		switch prefix {
+		case "npub":
+			pk, err := nostr.PubKeyFromHex(v)
+			if err != nil {
+				return nil, false
+			}
+			ref.Profile = &nostr.ProfilePointer{PublicKey: pk}
+		case "note":
+			id, err := nostr.IDFromHex(v)
+			if err != nil {
+				return nil, false
+			}
+			ref.Event = &nostr.EventPointer{ID: id}
+		default:
+			// nsec, never render those
+			return nil, false
+		}
+	default:
+		return nil, false
+	}
+	return ref, true
+}
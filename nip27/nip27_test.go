@@ -0,0 +1,60 @@
+package nip27
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+func TestParse(t *testing.T) {
+	npub, _ := nip19.EncodePublicKey("3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d")
+	nevent, _ := nip19.EncodeEvent("f39e9b451a73d62abc5016cffdd294b1a904e2f34536a208874fe5e22bbd47cf", []string{"wss://nos.lol"}, "")
+	content := "gm nostr:" + npub + "! see " + nevent + " and https://example.com/cat.JPG, #Nostr#not (https://en.wikipedia.org/wiki/Go_(language)). abc#nope"
+
+	blocks := Parse(content)
+
+	var rebuilt strings.Builder
+	for _, b := range blocks {
+		if content[b.Start:b.End] != b.Text {
+			t.Fatalf("wrong offsets for %v", b)
+		}
+		rebuilt.WriteString(b.Text)
+	}
+	if rebuilt.String() != content {
+		t.Fatalf("blocks don't add up to the content:\n%s", rebuilt.String())
+	}
+
+	var types []string
+	for _, b := range blocks {
+		if b.Type != BlockText {
+			types = append(types, b.Type.String()+":"+b.Text)
+		}
+	}
+	expected := []string{
+		"reference:nostr:" + npub,
+		"reference:" + nevent,
+		"image:https://example.com/cat.JPG",
+		"hashtag:#Nostr",
+		"url:https://en.wikipedia.org/wiki/Go_(language)",
+	}
+	if strings.Join(types, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("unexpected blocks:\n%s", strings.Join(types, "\n"))
+	}
+
+	if blocks[1].Reference.Profile == nil || blocks[3].Reference.Event.Relays[0] != "wss://nos.lol" {
+		t.Fatalf("references weren't decoded: %v %v", blocks[1].Reference, blocks[3].Reference)
+	}
+	for _, b := range blocks {
+		if b.Type == BlockHashtag && b.Hashtag != "nostr" {
+			t.Fatalf("unexpected hashtag %q", b.Hashtag)
+		}
+	}
+}
+
+func TestParseInvalidReference(t *testing.T) {
+	blocks := Parse("nostr:npub1qqqqqqqq is broken")
+	if len(blocks) != 1 || blocks[0].Type != BlockText {
+		t.Fatalf("invalid reference should be text: %v", blocks)
+	}
+}
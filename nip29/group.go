@@ -0,0 +1,135 @@
+package nip29
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Group is the state of a group as published by its relay.
+type Group struct {
+	Address GroupAddress
+
+	Name    string
+	Picture string
+	About   string
+	Private bool // only members can read
+	Closed  bool // join requests are ignored, only invites work
+
+	// Members has everybody in the group, with the roles of those that are admins.
+	Members map[nostr.PubKey][]string
+
+	LastMetadataUpdate time.Time
+	LastAdminsUpdate   time.Time
+	LastMembersUpdate  time.Time
+}
+
+func NewGroup(gad GroupAddress) Group {
+	return Group{
+		Address: gad,
+		Name:    gad.ID,
+		Members: make(map[nostr.PubKey][]string),
+	}
+}
+
+// Admins returns the members that have at least one role.
+func (group Group) Admins() []nostr.PubKey {
+	var admins []nostr.PubKey
+	for pk, roles := range group.Members {
+		if len(roles) > 0 {
+			admins = append(admins, pk)
+		}
+	}
+	return admins
+}
+
+// MergeInMetadataEvent updates the group from a kind-39000 event, unless it is older than
+// what we have. The event must have been validated with ValidateRelaySigned.
+func (group *Group) MergeInMetadataEvent(evt *nostr.Event) error {
+	if err := group.checkStateEvent(evt, KindSimpleGroupMetadata); err != nil {
+		return err
+	}
+	if evt.CreatedAt.Before(group.LastMetadataUpdate) {
+		return nil
+	}
+	group.LastMetadataUpdate = evt.CreatedAt
+
+	group.Name = group.Address.ID
+	if tag := evt.Tags.GetFirst([]string{"name", ""}); tag != nil {
+		group.Name = tag.Value()
+	}
+	group.Picture = ""
+	if tag := evt.Tags.GetFirst([]string{"picture", ""}); tag != nil {
+		group.Picture = tag.Value()
+	}
+	group.About = ""
+	if tag := evt.Tags.GetFirst([]string{"about", ""}); tag != nil {
+		group.About = tag.Value()
+	}
+	group.Private = evt.Tags.GetFirst([]string{"private"}) != nil
+	group.Closed = evt.Tags.GetFirst([]string{"closed"}) != nil
+	return nil
+}
+
+// MergeInAdminsEvent updates the admins and their roles from a kind-39001 event.
+func (group *Group) MergeInAdminsEvent(evt *nostr.Event) error {
+	if err := group.checkStateEvent(evt, KindSimpleGroupAdmins); err != nil {
+		return err
+	}
+	if evt.CreatedAt.Before(group.LastAdminsUpdate) {
+		return nil
+	}
+	group.LastAdminsUpdate = evt.CreatedAt
+
+	for pk := range group.Members {
+		group.Members[pk] = nil
+	}
+	for _, tag := range evt.Tags.GetAll([]string{"p", ""}) {
+		pk, err := nostr.PubKeyFromHex(tag.Value())
+		if err != nil {
+			continue
+		}
+		group.Members[pk] = append([]string{}, tag[2:]...)
+	}
+	return nil
+}
+
+// MergeInMembersEvent replaces the member list with the one in a kind-39002 event, keeping
+// the roles of admins.
+func (group *Group) MergeInMembersEvent(evt *nostr.Event) error {
+	if err := group.checkStateEvent(evt, KindSimpleGroupMembers); err != nil {
+		return err
+	}
+	if evt.CreatedAt.Before(group.LastMembersUpdate) {
+		return nil
+	}
+	group.LastMembersUpdate = evt.CreatedAt
+
+	members := make(map[nostr.PubKey][]string)
+	for _, tag := range evt.Tags.GetAll([]string{"p", ""}) {
+		if pk, err := nostr.PubKeyFromHex(tag.Value()); err == nil {
+			members[pk] = nil
+		}
+	}
+	for pk, roles := range group.Members {
+		if len(roles) > 0 {
+			members[pk] = roles
+		}
+	}
+	group.Members = members
+	return nil
+}
+
+func (group *Group) checkStateEvent(evt *nostr.Event, kind int) error {
+	if evt.Kind != kind {
+		return fmt.Errorf("expected kind %d, got %d", kind, evt.Kind)
+	}
+	if id := GetGroupID(evt); id != group.Address.ID {
+		return fmt.Errorf("event is for group '%s', not '%s'", id, group.Address.ID)
+	}
+	if group.Members == nil {
+		group.Members = make(map[nostr.PubKey][]string)
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package nip29
+
+import (
+	"fmt"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Action is a moderation event sent by a group admin, parsed with ParseModerationAction.
+type Action interface {
+	// Apply changes the group as the relay would after accepting the action.
+	Apply(group *Group)
+	// Kind is the kind of the event that performs the action.
+	Kind() int
+}
+
+type PutUser struct {
+	Targets map[nostr.PubKey][]string // with their roles
+}
+
+type RemoveUser struct {
+	Targets []nostr.PubKey
+}
+
+type EditMetadata struct {
+	Name    *string
+	Picture *string
+	About   *string
+	Private *bool
+	Closed  *bool
+}
+
+type DeleteEvent struct {
+	Targets []nostr.ID
+}
+
+type CreateGroup struct{}
+
+type DeleteGroup struct{}
+
+type CreateInvite struct {
+	Code string
+}
+
+func (a PutUser) Kind() int      { return KindSimpleGroupPutUser }
+func (a RemoveUser) Kind() int   { return KindSimpleGroupRemoveUser }
+func (a EditMetadata) Kind() int { return KindSimpleGroupEditMetadata }
+func (a DeleteEvent) Kind() int  { return KindSimpleGroupDeleteEvent }
+func (a CreateGroup) Kind() int  { return KindSimpleGroupCreateGroup }
+func (a DeleteGroup) Kind() int  { return KindSimpleGroupDeleteGroup }
+func (a CreateInvite) Kind() int { return KindSimpleGroupCreateInvite }
+
+func (a PutUser) Apply(group *Group) {
+	if group.Members == nil {
+		group.Members = make(map[nostr.PubKey][]string)
+	}
+	for pk, roles := range a.Targets {
+		if len(roles) > 0 || group.Members[pk] == nil {
+			group.Members[pk] = roles
+		}
+	}
+}
+
+func (a RemoveUser) Apply(group *Group) {
+	for _, pk := range a.Targets {
+		delete(group.Members, pk)
+	}
+}
+
+func (a EditMetadata) Apply(group *Group) {
+	if a.Name != nil {
+		group.Name = *a.Name
+	}
+	if a.Picture != nil {
+		group.Picture = *a.Picture
+	}
+	if a.About != nil {
+		group.About = *a.About
+	}
+	if a.Private != nil {
+		group.Private = *a.Private
+	}
+	if a.Closed != nil {
+		group.Closed = *a.Closed
+	}
+}
+
+// deleted events and invite codes aren't part of the group state
+func (a DeleteEvent) Apply(group *Group)  {}
+func (a CreateInvite) Apply(group *Group) {}
+func (a CreateGroup) Apply(group *Group)  {}
+
+func (a DeleteGroup) Apply(group *Group) {
+	*group = NewGroup(group.Address)
+}
+
+// ParseModerationAction parses one of the moderation events, kinds 9000 to 9009.
+// It doesn't check if the author is allowed to perform it, that is up to the relay.
+func ParseModerationAction(evt *nostr.Event) (Action, error) {
+	switch evt.Kind {
+	case KindSimpleGroupPutUser:
+		targets := make(map[nostr.PubKey][]string)
+		for _, tag := range evt.Tags.GetAll([]string{"p", ""}) {
+			pk, err := nostr.PubKeyFromHex(tag.Value())
+			if err != nil {
+				return nil, fmt.Errorf("invalid public key '%s'", tag.Value())
+			}
+			targets[pk] = append([]string{}, tag[2:]...)
+		}
+		if len(targets) == 0 {
+			return nil, fmt.Errorf("missing 'p' tags")
+		}
+		return PutUser{Targets: targets}, nil
+
+	case KindSimpleGroupRemoveUser:
+		var targets []nostr.PubKey
+		for _, tag := range evt.Tags.GetAll([]string{"p", ""}) {
+			pk, err := nostr.PubKeyFromHex(tag.Value())
+			if err != nil {
+				return nil, fmt.Errorf("invalid public key '%s'", tag.Value())
+			}
+			targets = append(targets, pk)
+		}
+		if len(targets) == 0 {
+			return nil, fmt.Errorf("missing 'p' tags")
+		}
+		return RemoveUser{Targets: targets}, nil
+
+	case KindSimpleGroupEditMetadata:
+		var a EditMetadata
+		for _, field := range []struct {
+			tag string
+			dst **string
+		}{{"name", &a.Name}, {"picture", &a.Picture}, {"about", &a.About}} {
+			if tag := evt.Tags.GetFirst([]string{field.tag, ""}); tag != nil {
+				v := tag.Value()
+				*field.dst = &v
+			}
+		}
+		yes, no := true, false
+		if evt.Tags.GetFirst([]string{"private"}) != nil {
+			a.Private = &yes
+		} else if evt.Tags.GetFirst([]string{"public"}) != nil {
+			a.Private = &no
+		}
+		if evt.Tags.GetFirst([]string{"closed"}) != nil {
+			a.Closed = &yes
+		} else if evt.Tags.GetFirst([]string{"open"}) != nil {
+			a.Closed = &no
+		}
+		return a, nil
+
+	case KindSimpleGroupDeleteEvent:
+		var targets []nostr.ID
+		for _, tag := range evt.Tags.GetAll([]string{"e", ""}) {
+			id, err := nostr.IDFromHex(tag.Value())
+			if err != nil {
+				return nil, fmt.Errorf("invalid event id '%s'", tag.Value())
+			}
+			targets = append(targets, id)
+		}
+		if len(targets) == 0 {
+			return nil, fmt.Errorf("missing 'e' tags")
+		}
+		return DeleteEvent{Targets: targets}, nil
+
+	case KindSimpleGroupCreateGroup:
+		return CreateGroup{}, nil
+
+	case KindSimpleGroupDeleteGroup:
+		return DeleteGroup{}, nil
+
+	case KindSimpleGroupCreateInvite:
+		tag := evt.Tags.GetFirst([]string{"code", ""})
+		if tag == nil {
+			return nil, fmt.Errorf("missing 'code' tag")
+		}
+		return CreateInvite{Code: tag.Value()}, nil
+	}
+
+	return nil, fmt.Errorf("kind %d is not a moderation event", evt.Kind)
+}
+
+// ModerationEvent returns an unsigned event performing action on the group, to be signed by an admin.
+// reason is optional.
+func ModerationEvent(groupID string, action Action, reason string) nostr.Event {
+	evt := groupEvent(action.Kind(), groupID)
+	switch a := action.(type) {
+	case PutUser:
+		for pk, roles := range a.Targets {
+			evt.Tags = append(evt.Tags, append(nostr.Tag{"p", pk.Hex()}, roles...))
+		}
+	case RemoveUser:
+		for _, pk := range a.Targets {
+			evt.Tags = append(evt.Tags, nostr.Tag{"p", pk.Hex()})
+		}
+	case EditMetadata:
+		for _, field := range []struct {
+			tag   string
+			value *string
+		}{{"name", a.Name}, {"picture", a.Picture}, {"about", a.About}} {
+			if field.value != nil {
+				evt.Tags = append(evt.Tags, nostr.Tag{field.tag, *field.value})
+			}
+		}
+		if a.Private != nil && *a.Private {
+			evt.Tags = append(evt.Tags, nostr.Tag{"private"})
+		} else if a.Private != nil {
+			evt.Tags = append(evt.Tags, nostr.Tag{"public"})
+		}
+		if a.Closed != nil && *a.Closed {
+			evt.Tags = append(evt.Tags, nostr.Tag{"closed"})
+		} else if a.Closed != nil {
+			evt.Tags = append(evt.Tags, nostr.Tag{"open"})
+		}
+	case DeleteEvent:
+		for _, id := range a.Targets {
+			evt.Tags = append(evt.Tags, nostr.Tag{"e", id.Hex()})
+		}
+	case CreateInvite:
+		evt.Tags = append(evt.Tags, nostr.Tag{"code", a.Code})
+	}
+	evt.Content = reason
+	return evt
+}
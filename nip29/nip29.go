@@ -0,0 +1,108 @@
+// Package nip29 implements relay-based groups: the group state published by the relay,
+// the moderation events sent by admins and the join and leave requests sent by users.
+// See https://github.com/nostr-protocol/nips/blob/master/29.md for details.
+package nip29
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	KindSimpleGroupPutUser      = 9000
+	KindSimpleGroupRemoveUser   = 9001
+	KindSimpleGroupEditMetadata = 9002
+	KindSimpleGroupDeleteEvent  = 9005
+	KindSimpleGroupCreateGroup  = 9007
+	KindSimpleGroupDeleteGroup  = 9008
+	KindSimpleGroupCreateInvite = 9009
+	KindSimpleGroupJoinRequest  = 9021
+	KindSimpleGroupLeaveRequest = 9022
+
+	// these are signed by the relay itself
+	KindSimpleGroupMetadata = 39000
+	KindSimpleGroupAdmins   = 39001
+	KindSimpleGroupMembers  = 39002
+)
+
+// GroupAddress identifies a group as "<relay host>'<group id>".
+type GroupAddress struct {
+	Relay string
+	ID    string
+}
+
+func (gad GroupAddress) String() string {
+	return strings.TrimPrefix(strings.TrimPrefix(gad.Relay, "wss://"), "ws://") + "'" + gad.ID
+}
+
+func (gad GroupAddress) IsValid() bool {
+	return gad.Relay != "" && gad.ID != ""
+}
+
+// ParseGroupAddress parses "<relay host>'<group id>", with or without the scheme in the relay.
+func ParseGroupAddress(raw string) (GroupAddress, error) {
+	spl := strings.Split(raw, "'")
+	if len(spl) != 2 || spl[0] == "" || spl[1] == "" {
+		return GroupAddress{}, fmt.Errorf("invalid group address '%s'", raw)
+	}
+	relay := spl[0]
+	if !strings.HasPrefix(relay, "ws://") && !strings.HasPrefix(relay, "wss://") {
+		relay = "wss://" + relay
+	}
+	return GroupAddress{Relay: nostr.NormalizeURL(relay), ID: spl[1]}, nil
+}
+
+// GetGroupID returns the id of the group an event belongs to, from its "h" tag, or from its
+// "d" tag for the events signed by the relay. It is "" for events that don't belong to a group.
+func GetGroupID(evt *nostr.Event) string {
+	tagName := "h"
+	if evt.Kind >= KindSimpleGroupMetadata && evt.Kind <= KindSimpleGroupMembers {
+		tagName = "d"
+	}
+	if tag := evt.Tags.GetFirst([]string{tagName, ""}); tag != nil {
+		return tag.Value()
+	}
+	return ""
+}
+
+// ValidateRelaySigned checks that evt, one of the group state events, was signed by the relay,
+// whose public key is in its NIP-11 document.
+func ValidateRelaySigned(evt *nostr.Event, relayPubKey nostr.PubKey) error {
+	if evt.PubKey != relayPubKey {
+		return fmt.Errorf("event was signed by %s, not by the relay %s", evt.PubKey, relayPubKey)
+	}
+	if evt.GetID() != evt.ID {
+		return fmt.Errorf("event id doesn't match its contents")
+	}
+	if ok, err := evt.CheckSignature(); !ok {
+		return fmt.Errorf("invalid signature: %v", err)
+	}
+	return nil
+}
+
+// JoinRequest returns an unsigned event asking to join the group. reason and inviteCode are optional.
+func JoinRequest(groupID string, reason string, inviteCode string) nostr.Event {
+	evt := groupEvent(KindSimpleGroupJoinRequest, groupID)
+	evt.Content = reason
+	if inviteCode != "" {
+		evt.Tags = append(evt.Tags, nostr.Tag{"code", inviteCode})
+	}
+	return evt
+}
+
+// LeaveRequest returns an unsigned event asking to be removed from the group.
+func LeaveRequest(groupID string, reason string) nostr.Event {
+	evt := groupEvent(KindSimpleGroupLeaveRequest, groupID)
+	evt.Content = reason
+	return evt
+}
+
+func groupEvent(kind int, groupID string) nostr.Event {
+	return nostr.Event{
+		Kind:      kind,
+		CreatedAt: nostr.Now(),
+		Tags:      nostr.Tags{{"h", groupID}},
+	}
+}
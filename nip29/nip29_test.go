@@ -0,0 +1,95 @@
+package nip29
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestGroupAddress(t *testing.T) {
+	gad, err := ParseGroupAddress("groups.nostr.com'abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gad.Relay != "wss://groups.nostr.com" || gad.ID != "abcdef" || gad.String() != "groups.nostr.com'abcdef" {
+		t.Fatalf("unexpected address: %v", gad)
+	}
+	if _, err := ParseGroupAddress("groups.nostr.com"); err == nil {
+		t.Fatal("should fail without a group id")
+	}
+}
+
+func TestGroupState(t *testing.T) {
+	relaySK := nostr.GeneratePrivateKey()
+	relayPK := nostr.MustPubKeyFromHex(must(nostr.GetPublicKey(relaySK)))
+	admin := nostr.MustPubKeyFromHex(must(nostr.GetPublicKey(nostr.GeneratePrivateKey())))
+	member := nostr.MustPubKeyFromHex(must(nostr.GetPublicKey(nostr.GeneratePrivateKey())))
+
+	group := NewGroup(GroupAddress{Relay: "wss://groups.nostr.com", ID: "abcdef"})
+	for _, evt := range []nostr.Event{
+		{Kind: KindSimpleGroupMetadata, Tags: nostr.Tags{{"d", "abcdef"}, {"name", "pizza lovers"}, {"closed"}}},
+		{Kind: KindSimpleGroupAdmins, Tags: nostr.Tags{{"d", "abcdef"}, {"p", admin.Hex(), "ceo"}}},
+		{Kind: KindSimpleGroupMembers, Tags: nostr.Tags{{"d", "abcdef"}, {"p", member.Hex()}}},
+	} {
+		evt.CreatedAt = time.Now()
+		evt.PubKey = relayPK
+		evt.Sign(relaySK)
+		if err := ValidateRelaySigned(&evt, relayPK); err != nil {
+			t.Fatalf("kind %d: %s", evt.Kind, err)
+		}
+		switch evt.Kind {
+		case KindSimpleGroupMetadata:
+			if err := group.MergeInMetadataEvent(&evt); err != nil {
+				t.Fatal(err)
+			}
+		case KindSimpleGroupAdmins:
+			if err := group.MergeInAdminsEvent(&evt); err != nil {
+				t.Fatal(err)
+			}
+		case KindSimpleGroupMembers:
+			if err := group.MergeInMembersEvent(&evt); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if group.Name != "pizza lovers" || !group.Closed || group.Private {
+		t.Fatalf("unexpected metadata: %+v", group)
+	}
+	if len(group.Members) != 2 || len(group.Members[admin]) != 1 || len(group.Admins()) != 1 {
+		t.Fatalf("unexpected members: %v", group.Members)
+	}
+
+	forged := nostr.Event{Kind: KindSimpleGroupMetadata, CreatedAt: time.Now(), Tags: nostr.Tags{{"d", "abcdef"}}, PubKey: admin}
+	if err := ValidateRelaySigned(&forged, relayPK); err == nil {
+		t.Fatal("event not signed by the relay was accepted")
+	}
+
+	// moderation round trip
+	name := "pineapple lovers"
+	for _, action := range []Action{
+		EditMetadata{Name: &name},
+		RemoveUser{Targets: []nostr.PubKey{member}},
+	} {
+		evt := ModerationEvent("abcdef", action, "")
+		if GetGroupID(&evt) != "abcdef" {
+			t.Fatalf("missing h tag: %v", evt.Tags)
+		}
+		parsed, err := ParseModerationAction(&evt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed.Apply(&group)
+	}
+	if group.Name != name || len(group.Members) != 1 {
+		t.Fatalf("actions weren't applied: %+v", group)
+	}
+}
+
+func must(s string, err error) string {
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
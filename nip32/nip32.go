@@ -0,0 +1,148 @@
+// Package nip32 implements labeling: kind-1985 events that attach labels, grouped in
+// namespaces, to events, public keys, relays or topics.
+// See https://github.com/nostr-protocol/nips/blob/master/32.md for details.
+package nip32
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const KindLabel = 1985
+
+// DefaultNamespace applies to "l" tags without a mark.
+const DefaultNamespace = "ugc"
+
+type Label struct {
+	Namespace string
+	Value     string
+}
+
+// TargetType tells what a label is attached to, it is the name of the tag that holds the target.
+type TargetType string
+
+const (
+	TargetEvent   TargetType = "e"
+	TargetPubKey  TargetType = "p"
+	TargetAddress TargetType = "a"
+	TargetRelay   TargetType = "r"
+	TargetTopic   TargetType = "t"
+)
+
+type Target struct {
+	Type  TargetType
+	Value string // event id, hex public key, address, relay URL or topic
+	Relay string // optional hint for events, addresses and public keys
+}
+
+// CreateLabelEvent returns an unsigned kind-1985 event applying labels, all in namespace, to
+// targets. An empty namespace means DefaultNamespace.
+func CreateLabelEvent(namespace string, labels []string, targets ...Target) (nostr.Event, error) {
+	if len(labels) == 0 {
+		return nostr.Event{}, fmt.Errorf("no labels given")
+	}
+	if len(targets) == 0 {
+		return nostr.Event{}, fmt.Errorf("no targets given")
+	}
+
+	evt := nostr.Event{
+		Kind:      KindLabel,
+		CreatedAt: nostr.Now(),
+		Tags:      make(nostr.Tags, 0, len(labels)+len(targets)+1),
+	}
+	if namespace == "" {
+		namespace = DefaultNamespace
+	} else {
+		evt.Tags = append(evt.Tags, nostr.Tag{"L", namespace})
+	}
+	for _, label := range labels {
+		evt.Tags = append(evt.Tags, nostr.Tag{"l", label, namespace})
+	}
+	for _, target := range targets {
+		tag := nostr.Tag{string(target.Type), target.Value}
+		if target.Relay != "" {
+			tag = append(tag, target.Relay)
+		}
+		evt.Tags = append(evt.Tags, tag)
+	}
+	return evt, nil
+}
+
+// ParseLabels returns the labels in evt, which can be a kind-1985 event or any event labeling
+// itself. Labels in namespaces that weren't declared with an "L" tag are ignored, except
+// for DefaultNamespace.
+func ParseLabels(evt *nostr.Event) []Label {
+	namespaces := map[string]bool{DefaultNamespace: true}
+	for _, tag := range evt.Tags.GetAll([]string{"L", ""}) {
+		namespaces[tag.Value()] = true
+	}
+
+	var labels []Label
+	for _, tag := range evt.Tags.GetAll([]string{"l", ""}) {
+		namespace := DefaultNamespace
+		if len(tag) > 2 && tag[2] != "" {
+			namespace = tag[2]
+		}
+		if namespaces[namespace] {
+			labels = append(labels, Label{Namespace: namespace, Value: tag.Value()})
+		}
+	}
+	return labels
+}
+
+// ParseTargets returns what a kind-1985 event labels.
+func ParseTargets(evt *nostr.Event) []Target {
+	var targets []Target
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch t := TargetType(tag[0]); t {
+		case TargetEvent, TargetPubKey, TargetAddress, TargetRelay, TargetTopic:
+			target := Target{Type: t, Value: tag[1]}
+			if len(tag) > 2 {
+				target.Relay = tag[2]
+			}
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+// Filter returns a filter for the label events attached to target, optionally only in the
+// given namespaces.
+func Filter(target Target, namespaces ...string) nostr.Filter {
+	filter := nostr.Filter{
+		Kinds: []int{KindLabel},
+		Tags:  nostr.TagMap{string(target.Type): []string{target.Value}},
+	}
+	if len(namespaces) > 0 {
+		filter.Tags["L"] = namespaces
+	}
+	return filter
+}
+
+// QueryLabels collects the labels applied to target by the label events found on relays, with
+// how many times each was applied. If authors are given only their labels are counted, which
+// is usually what we want since anybody can label anything.
+func QueryLabels(ctx context.Context, pool *nostr.SimplePool, relays []string, target Target, authors []nostr.PubKey, namespaces ...string) map[Label]int {
+	filter := Filter(target, namespaces...)
+	filter.Authors = authors
+
+	wanted := make(map[string]bool, len(namespaces))
+	for _, ns := range namespaces {
+		wanted[ns] = true
+	}
+
+	counts := make(map[Label]int)
+	for evt := range pool.SubManyEose(ctx, relays, nostr.Filters{filter}) {
+		for _, label := range ParseLabels(evt) {
+			if len(wanted) == 0 || wanted[label.Namespace] {
+				counts[label]++
+			}
+		}
+	}
+	return counts
+}
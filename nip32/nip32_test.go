@@ -0,0 +1,39 @@
+package nip32
+
+import (
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestLabelRoundTrip(t *testing.T) {
+	pk := "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"
+	evt, err := CreateLabelEvent("ISO-639-1", []string{"en", "pt"},
+		Target{Type: TargetPubKey, Value: pk},
+		Target{Type: TargetTopic, Value: "bitcoin"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	labels := ParseLabels(&evt)
+	if len(labels) != 2 || labels[0] != (Label{"ISO-639-1", "en"}) || labels[1] != (Label{"ISO-639-1", "pt"}) {
+		t.Fatalf("unexpected labels: %v", labels)
+	}
+	targets := ParseTargets(&evt)
+	if len(targets) != 2 || targets[0].Type != TargetPubKey || targets[0].Value != pk || targets[1].Value != "bitcoin" {
+		t.Fatalf("unexpected targets: %v", targets)
+	}
+
+	// labels in undeclared namespaces are ignored
+	evt.Tags = append(evt.Tags, nostr.Tag{"l", "spam", "com.example.undeclared"}, nostr.Tag{"l", "nsfw"})
+	labels = ParseLabels(&evt)
+	if len(labels) != 3 || labels[2] != (Label{DefaultNamespace, "nsfw"}) {
+		t.Fatalf("unexpected labels: %v", labels)
+	}
+
+	filter := Filter(targets[0], "ISO-639-1")
+	if !filter.Matches(&evt) {
+		t.Fatal("filter should match the label event")
+	}
+}
@@ -0,0 +1,51 @@
+// Package nip34 implements git collaboration over nostr: repository announcements, their
+// state, patches and issues.
+// See https://github.com/nostr-protocol/nips/blob/master/34.md for details.
+package nip34
+
+import (
+	"fmt"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	KindRepositoryAnnouncement = 30617
+	KindRepositoryState        = 30618
+	KindPatch                  = 1617
+	KindIssue                  = 1621
+)
+
+// RepositoryAddress is the "a" tag value identifying a repository: "30617:<owner>:<id>".
+func RepositoryAddress(owner nostr.PubKey, id string) string {
+	return fmt.Sprintf("%d:%s:%s", KindRepositoryAnnouncement, owner.Hex(), id)
+}
+
+// ParseRepositoryAddress is the inverse of RepositoryAddress.
+func ParseRepositoryAddress(address string) (nostr.EntityPointer, error) {
+	ptr, err := nostr.ParseAddress(address)
+	if err != nil {
+		return nostr.EntityPointer{}, err
+	}
+	if ptr.Kind != KindRepositoryAnnouncement {
+		return nostr.EntityPointer{}, fmt.Errorf("'%s' is not a repository address", address)
+	}
+	return ptr, nil
+}
+
+// tagValues returns the values of all tags named name, since lists like "clone" or "relays"
+// can be given in a single tag or spread across many.
+func tagValues(tags nostr.Tags, name string) []string {
+	var values []string
+	for _, tag := range tags.GetAll([]string{name, ""}) {
+		values = append(values, tag[1:]...)
+	}
+	return values
+}
+
+func tagValue(tags nostr.Tags, name string) string {
+	if tag := tags.GetFirst([]string{name, ""}); tag != nil {
+		return tag.Value()
+	}
+	return ""
+}
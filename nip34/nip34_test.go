@@ -0,0 +1,65 @@
+package nip34
+
+import (
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestRepositoryRoundTrip(t *testing.T) {
+	owner := nostr.MustPubKeyFromHex("3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d")
+	repo := Repository{
+		ID:                     "go-nostr",
+		Owner:                  owner,
+		Name:                   "go-nostr",
+		Clone:                  []string{"https://github.com/nbd-wtf/go-nostr.git"},
+		Relays:                 []string{"wss://relay.example.com", "wss://nos.lol"},
+		EarliestUniqueCommitID: "ab906d1",
+		Maintainers:            []nostr.PubKey{owner},
+	}
+	evt := repo.ToEvent()
+	parsed, err := ParseRepository(&evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.ID != repo.ID || len(parsed.Relays) != 2 || parsed.EarliestUniqueCommitID != "ab906d1" || len(parsed.Maintainers) != 1 {
+		t.Fatalf("unexpected repository: %+v", parsed)
+	}
+
+	ptr, err := ParseRepositoryAddress(repo.Address())
+	if err != nil || ptr.Identifier != "go-nostr" || ptr.PublicKey != owner {
+		t.Fatalf("unexpected address %v: %s", ptr, err)
+	}
+
+	state := RepositoryState{ID: "go-nostr", Branches: map[string]string{"master": "b82097c"}, HEAD: "master"}
+	evt = state.ToEvent()
+	parsedState, err := ParseRepositoryState(&evt)
+	if err != nil || parsedState.HEAD != "master" || parsedState.Branches["master"] != "b82097c" {
+		t.Fatalf("unexpected state %+v: %s", parsedState, err)
+	}
+}
+
+func TestPatchAndIssue(t *testing.T) {
+	address := "30617:3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d:go-nostr"
+	patch := Patch{
+		Repository: address,
+		Content:    "From b82097c Mon Sep 17 00:00:00 2001\nFrom: someone\nSubject: [PATCH 1/2] fix the relay\n\nbody\n",
+		Commit:     "b82097c",
+		Root:       true,
+	}
+	evt := patch.ToEvent()
+	if evt.Tags.GetFirst([]string{"p", "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"}) == nil {
+		t.Fatal("repository owner wasn't tagged")
+	}
+	parsed, err := ParsePatch(&evt)
+	if err != nil || !parsed.Root || parsed.Commit != "b82097c" || parsed.Subject() != "fix the relay" {
+		t.Fatalf("unexpected patch %+v: %s", parsed, err)
+	}
+
+	issue := Issue{Repository: address, Subject: "it crashes", Content: "when I do this", Labels: []string{"bug"}}
+	evt = issue.ToEvent()
+	parsedIssue, err := ParseIssue(&evt)
+	if err != nil || parsedIssue.Subject != "it crashes" || len(parsedIssue.Labels) != 1 {
+		t.Fatalf("unexpected issue %+v: %s", parsedIssue, err)
+	}
+}
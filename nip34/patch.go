@@ -0,0 +1,134 @@
+package nip34
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Patch is a kind-1617 event with the output of git format-patch.
+type Patch struct {
+	Repository string // address of the repository, see RepositoryAddress
+	Content    string
+
+	Commit       string // optional, the id of the commit this patch creates
+	ParentCommit string // optional
+	Root         bool   // the first patch in a series
+	RootRevision bool   // the first patch in a revision of a series
+
+	Event nostr.Event
+}
+
+func ParsePatch(evt *nostr.Event) (Patch, error) {
+	if evt.Kind != KindPatch {
+		return Patch{}, fmt.Errorf("expected kind %d, got %d", KindPatch, evt.Kind)
+	}
+	patch := Patch{
+		Repository:   tagValue(evt.Tags, "a"),
+		Content:      evt.Content,
+		Commit:       tagValue(evt.Tags, "commit"),
+		ParentCommit: tagValue(evt.Tags, "parent-commit"),
+		Root:         evt.Tags.GetFirst([]string{"t", "root"}) != nil,
+		RootRevision: evt.Tags.GetFirst([]string{"t", "root-revision"}) != nil,
+		Event:        *evt,
+	}
+	if patch.Repository == "" {
+		return patch, fmt.Errorf("missing 'a' tag")
+	}
+	return patch, nil
+}
+
+// Subject returns the "Subject:" header of the patch, without the "[PATCH]" prefix.
+func (patch Patch) Subject() string {
+	for _, line := range strings.Split(patch.Content, "\n") {
+		if line == "" {
+			// end of the headers
+			break
+		}
+		if subject := strings.TrimPrefix(line, "Subject: "); subject != line {
+			if strings.HasPrefix(subject, "[") {
+				if end := strings.Index(subject, "] "); end != -1 {
+					subject = subject[end+2:]
+				}
+			}
+			return subject
+		}
+	}
+	return ""
+}
+
+// ToEvent returns the unsigned patch event, tagging the repository owner.
+func (patch Patch) ToEvent() nostr.Event {
+	evt := nostr.Event{
+		Kind:      KindPatch,
+		CreatedAt: nostr.Now(),
+		Content:   patch.Content,
+		Tags:      nostr.Tags{{"a", patch.Repository}},
+	}
+	if ptr, err := ParseRepositoryAddress(patch.Repository); err == nil {
+		evt.Tags = append(evt.Tags, nostr.PubKeyTag(ptr.PublicKey, ""))
+	}
+	if patch.Commit != "" {
+		evt.Tags = append(evt.Tags, nostr.Tag{"commit", patch.Commit}, nostr.Tag{"r", patch.Commit})
+	}
+	if patch.ParentCommit != "" {
+		evt.Tags = append(evt.Tags, nostr.Tag{"parent-commit", patch.ParentCommit})
+	}
+	if patch.Root {
+		evt.Tags = append(evt.Tags, nostr.Tag{"t", "root"})
+	}
+	if patch.RootRevision {
+		evt.Tags = append(evt.Tags, nostr.Tag{"t", "root-revision"})
+	}
+	return evt
+}
+
+// Issue is a kind-1621 bug report or feature request for a repository.
+type Issue struct {
+	Repository string // address of the repository, see RepositoryAddress
+	Subject    string
+	Content    string // markdown
+	Labels     []string
+
+	Event nostr.Event
+}
+
+func ParseIssue(evt *nostr.Event) (Issue, error) {
+	if evt.Kind != KindIssue {
+		return Issue{}, fmt.Errorf("expected kind %d, got %d", KindIssue, evt.Kind)
+	}
+	issue := Issue{
+		Repository: tagValue(evt.Tags, "a"),
+		Subject:    tagValue(evt.Tags, "subject"),
+		Content:    evt.Content,
+		Event:      *evt,
+	}
+	if issue.Repository == "" {
+		return issue, fmt.Errorf("missing 'a' tag")
+	}
+	for _, tag := range evt.Tags.GetAll([]string{"t", ""}) {
+		issue.Labels = append(issue.Labels, tag.Value())
+	}
+	return issue, nil
+}
+
+// ToEvent returns the unsigned issue event, tagging the repository owner.
+func (issue Issue) ToEvent() nostr.Event {
+	evt := nostr.Event{
+		Kind:      KindIssue,
+		CreatedAt: nostr.Now(),
+		Content:   issue.Content,
+		Tags:      nostr.Tags{{"a", issue.Repository}},
+	}
+	if ptr, err := ParseRepositoryAddress(issue.Repository); err == nil {
+		evt.Tags = append(evt.Tags, nostr.PubKeyTag(ptr.PublicKey, ""))
+	}
+	if issue.Subject != "" {
+		evt.Tags = append(evt.Tags, nostr.Tag{"subject", issue.Subject})
+	}
+	for _, label := range issue.Labels {
+		evt.Tags = append(evt.Tags, nostr.Tag{"t", label})
+	}
+	return evt
+}
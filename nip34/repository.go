@@ -0,0 +1,148 @@
+package nip34
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Repository is a kind-30617 announcement of a git repository.
+type Repository struct {
+	ID          string
+	Owner       nostr.PubKey
+	Name        string
+	Description string
+	Web         []string // URLs to browse the repository
+	Clone       []string // URLs to git clone from
+	Relays      []string // where patches and issues should be sent
+
+	// EarliestUniqueCommitID is the root commit, used to find forks of the same repository.
+	EarliestUniqueCommitID string
+	Maintainers            []nostr.PubKey
+}
+
+func ParseRepository(evt *nostr.Event) (Repository, error) {
+	if evt.Kind != KindRepositoryAnnouncement {
+		return Repository{}, fmt.Errorf("expected kind %d, got %d", KindRepositoryAnnouncement, evt.Kind)
+	}
+	repo := Repository{
+		ID:          tagValue(evt.Tags, "d"),
+		Owner:       evt.PubKey,
+		Name:        tagValue(evt.Tags, "name"),
+		Description: tagValue(evt.Tags, "description"),
+		Web:         tagValues(evt.Tags, "web"),
+		Clone:       tagValues(evt.Tags, "clone"),
+		Relays:      tagValues(evt.Tags, "relays"),
+	}
+	if repo.ID == "" {
+		return repo, fmt.Errorf("missing 'd' tag")
+	}
+	for _, tag := range evt.Tags.GetAll([]string{"r", ""}) {
+		if len(tag) > 2 && tag[2] == "euc" {
+			repo.EarliestUniqueCommitID = tag[1]
+		}
+	}
+	for _, v := range tagValues(evt.Tags, "maintainers") {
+		if pk, err := nostr.PubKeyFromHex(v); err == nil {
+			repo.Maintainers = append(repo.Maintainers, pk)
+		}
+	}
+	return repo, nil
+}
+
+// Address is the value used in "a" tags to refer to this repository.
+func (repo Repository) Address() string {
+	return RepositoryAddress(repo.Owner, repo.ID)
+}
+
+// ToEvent returns the unsigned announcement event.
+func (repo Repository) ToEvent() nostr.Event {
+	evt := nostr.Event{
+		Kind:      KindRepositoryAnnouncement,
+		PubKey:    repo.Owner,
+		CreatedAt: nostr.Now(),
+		Tags:      nostr.Tags{{"d", repo.ID}},
+	}
+	if repo.Name != "" {
+		evt.Tags = append(evt.Tags, nostr.Tag{"name", repo.Name})
+	}
+	if repo.Description != "" {
+		evt.Tags = append(evt.Tags, nostr.Tag{"description", repo.Description})
+	}
+	for _, list := range []struct {
+		name   string
+		values []string
+	}{{"web", repo.Web}, {"clone", repo.Clone}, {"relays", repo.Relays}} {
+		if len(list.values) > 0 {
+			evt.Tags = append(evt.Tags, append(nostr.Tag{list.name}, list.values...))
+		}
+	}
+	if repo.EarliestUniqueCommitID != "" {
+		evt.Tags = append(evt.Tags, nostr.Tag{"r", repo.EarliestUniqueCommitID, "euc"})
+	}
+	if len(repo.Maintainers) > 0 {
+		tag := nostr.Tag{"maintainers"}
+		for _, pk := range repo.Maintainers {
+			tag = append(tag, pk.Hex())
+		}
+		evt.Tags = append(evt.Tags, tag)
+	}
+	return evt
+}
+
+// RepositoryState is a kind-30618 event with the branches and tags of a repository, as its
+// owner sees them.
+type RepositoryState struct {
+	ID       string            // of the repository
+	Branches map[string]string // name to commit id
+	Tags     map[string]string // name to commit id
+	HEAD     string            // the default branch name
+}
+
+func ParseRepositoryState(evt *nostr.Event) (RepositoryState, error) {
+	if evt.Kind != KindRepositoryState {
+		return RepositoryState{}, fmt.Errorf("expected kind %d, got %d", KindRepositoryState, evt.Kind)
+	}
+	state := RepositoryState{
+		ID:       tagValue(evt.Tags, "d"),
+		Branches: make(map[string]string),
+		Tags:     make(map[string]string),
+	}
+	if state.ID == "" {
+		return state, fmt.Errorf("missing 'd' tag")
+	}
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch {
+		case tag[0] == "HEAD":
+			state.HEAD = strings.TrimPrefix(tag[1], "ref: refs/heads/")
+		case strings.HasPrefix(tag[0], "refs/heads/"):
+			state.Branches[strings.TrimPrefix(tag[0], "refs/heads/")] = tag[1]
+		case strings.HasPrefix(tag[0], "refs/tags/"):
+			state.Tags[strings.TrimPrefix(tag[0], "refs/tags/")] = tag[1]
+		}
+	}
+	return state, nil
+}
+
+// ToEvent returns the unsigned state event.
+func (state RepositoryState) ToEvent() nostr.Event {
+	evt := nostr.Event{
+		Kind:      KindRepositoryState,
+		CreatedAt: nostr.Now(),
+		Tags:      nostr.Tags{{"d", state.ID}},
+	}
+	for name, commit := range state.Branches {
+		evt.Tags = append(evt.Tags, nostr.Tag{"refs/heads/" + name, commit})
+	}
+	for name, commit := range state.Tags {
+		evt.Tags = append(evt.Tags, nostr.Tag{"refs/tags/" + name, commit})
+	}
+	if state.HEAD != "" {
+		evt.Tags = append(evt.Tags, nostr.Tag{"HEAD", "ref: refs/heads/" + state.HEAD})
+	}
+	return evt
+}
@@ -0,0 +1,114 @@
+// Package nip39 implements external identities in profiles: "i" tags in kind-0 events
+// claiming accounts on other platforms, and verification of the proofs left there.
+// See https://github.com/nostr-protocol/nips/blob/master/39.md for details.
+package nip39
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Identity is a claim like "github:semisol" with the id of the proof on that platform.
+type Identity struct {
+	Platform string
+	Identity string
+	Proof    string
+}
+
+// ParseIdentities returns the identities claimed in the "i" tags of evt.
+func ParseIdentities(evt *nostr.Event) []Identity {
+	var identities []Identity
+	for _, tag := range evt.Tags.GetAll([]string{"i", ""}) {
+		platform, identity, ok := strings.Cut(tag.Value(), ":")
+		if !ok || platform == "" || identity == "" {
+			continue
+		}
+		id := Identity{Platform: platform, Identity: identity}
+		if len(tag) > 2 {
+			id.Proof = tag[2]
+		}
+		identities = append(identities, id)
+	}
+	return identities
+}
+
+// Tag returns the "i" tag for this identity, to be added to a kind-0 event.
+func (id Identity) Tag() nostr.Tag {
+	return nostr.Tag{"i", id.Platform + ":" + id.Identity, id.Proof}
+}
+
+// ProofURL is where the proof can be seen, or "" for unknown platforms.
+func (id Identity) ProofURL() string {
+	switch id.Platform {
+	case "github":
+		return fmt.Sprintf("https://gist.github.com/%s/%s", id.Identity, id.Proof)
+	case "twitter":
+		return fmt.Sprintf("https://twitter.com/%s/status/%s", id.Identity, id.Proof)
+	case "mastodon":
+		return fmt.Sprintf("https://%s/%s", id.Identity, id.Proof)
+	case "telegram":
+		return fmt.Sprintf("https://t.me/%s", id.Proof)
+	}
+	return ""
+}
+
+// ErrUnknownPlatform is returned by Verify for platforms we can't check.
+var ErrUnknownPlatform = fmt.Errorf("unknown platform")
+
+// HTTPClient is used to fetch proofs.
+var HTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// Verify fetches the proof of id and checks that it mentions pk, as npub. The proof text for
+// each platform is described in the NIP, but all of them must contain the npub.
+func Verify(ctx context.Context, id Identity, pk nostr.PubKey) error {
+	if id.Proof == "" {
+		return fmt.Errorf("no proof given")
+	}
+
+	var proofURL string
+	switch id.Platform {
+	case "github":
+		// the raw gist, so we don't depend on the page layout
+		proofURL = fmt.Sprintf("https://gist.githubusercontent.com/%s/%s/raw", id.Identity, id.Proof)
+	case "twitter":
+		// tweets are rendered with javascript, but the oembed endpoint has their text
+		proofURL = "https://publish.twitter.com/oembed?url=" + url.QueryEscape(id.ProofURL())
+	case "mastodon", "telegram":
+		proofURL = id.ProofURL()
+	default:
+		return ErrUnknownPlatform
+	}
+
+	return checkProof(ctx, proofURL, pk)
+}
+
+func checkProof(ctx context.Context, proofURL string, pk nostr.PubKey) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, proofURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch proof: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch proof: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read proof: %w", err)
+	}
+	if !strings.Contains(string(body), pk.Npub()) {
+		return fmt.Errorf("proof at %s doesn't mention %s", proofURL, pk.Npub())
+	}
+	return nil
+}
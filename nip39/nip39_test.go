@@ -0,0 +1,49 @@
+package nip39
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestParseIdentities(t *testing.T) {
+	evt := nostr.Event{Kind: 0, Tags: nostr.Tags{
+		Identity{"github", "semisol", "9721ce4ee4fceb91c9711ca2a6c9a5ab"}.Tag(),
+		{"i", "telegram:1087295469", "nostrdirectory/770"},
+		{"i", "invalid"},
+	}}
+
+	identities := ParseIdentities(&evt)
+	if len(identities) != 2 {
+		t.Fatalf("expected 2 identities, got %v", identities)
+	}
+	if identities[0].ProofURL() != "https://gist.github.com/semisol/9721ce4ee4fceb91c9711ca2a6c9a5ab" {
+		t.Fatalf("unexpected proof url %s", identities[0].ProofURL())
+	}
+	if identities[1].ProofURL() != "https://t.me/nostrdirectory/770" {
+		t.Fatalf("unexpected proof url %s", identities[1].ProofURL())
+	}
+}
+
+func TestCheckProof(t *testing.T) {
+	pk := nostr.MustPubKeyFromHex("3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "Verifying that I control the following Nostr public key: %s", pk.Npub())
+	}))
+	defer server.Close()
+
+	if err := checkProof(context.Background(), server.URL, pk); err != nil {
+		t.Fatalf("valid proof rejected: %s", err)
+	}
+	other := nostr.MustPubKeyFromHex("82341f882b6eabcd2ba7f1ef90aad961cf074af15b9ef44a09f9d2a8fbfbe6a2")
+	if err := checkProof(context.Background(), server.URL, other); err == nil {
+		t.Fatal("proof for another key accepted")
+	}
+	if err := Verify(context.Background(), Identity{"myspace", "me", "1"}, pk); err != ErrUnknownPlatform {
+		t.Fatalf("expected ErrUnknownPlatform, got %v", err)
+	}
+}
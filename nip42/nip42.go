@@ -10,10 +10,10 @@ import (
 
 // CreateUnsignedAuthEvent creates an event which should be sent via an "AUTH" command.
 // If the authentication succeeds, the user will be authenticated as pubkey.
-func CreateUnsignedAuthEvent(challenge, pubkey, relayURL string) nostr.Event {
+func CreateUnsignedAuthEvent(challenge string, pubkey nostr.PubKey, relayURL string) nostr.Event {
 	return nostr.Event{
 		PubKey:    pubkey,
-		CreatedAt: time.Now(),
+		CreatedAt: nostr.Now(),
 		Kind:      22242,
 		Tags: nostr.Tags{
 			nostr.Tag{"relay", relayURL},
@@ -34,40 +34,40 @@ func parseUrl(input string) (*url.URL, error) {
 
 // ValidateAuthEvent checks whether event is a valid NIP-42 event for given challenge and relayURL.
 // The result of the validation is encoded in the ok bool.
-func ValidateAuthEvent(event *nostr.Event, challenge string, relayURL string) (pubkey string, ok bool) {
+func ValidateAuthEvent(event *nostr.Event, challenge string, relayURL string) (pubkey nostr.PubKey, ok bool) {
 	if event.Kind != 22242 {
-		return "", false
+		return nostr.ZeroPubKey, false
 	}
 
 	if event.Tags.GetFirst([]string{"challenge", challenge}) == nil {
-		return "", false
+		return nostr.ZeroPubKey, false
 	}
 
 	expected, err := parseUrl(relayURL)
 	if err != nil {
-		return "", false
+		return nostr.ZeroPubKey, false
 	}
 
 	found, err := parseUrl(event.Tags.GetFirst([]string{"relay", ""}).Value())
 	if err != nil {
-		return "", false
+		return nostr.ZeroPubKey, false
 	}
 
 	if expected.Scheme != found.Scheme ||
 		expected.Host != found.Host ||
 		expected.Path != found.Path {
-		return "", false
+		return nostr.ZeroPubKey, false
 	}
 
-	now := time.Now()
+	now := nostr.Now()
 	if event.CreatedAt.After(now.Add(10*time.Minute)) || event.CreatedAt.Before(now.Add(-10*time.Minute)) {
-		return "", false
+		return nostr.ZeroPubKey, false
 	}
 
 	// save for last, as it is most expensive operation
 	// no need to check returned error, since ok == true implies err == nil.
 	if ok, _ := event.CheckSignature(); !ok {
-		return "", false
+		return nostr.ZeroPubKey, false
 	}
 
 	return event.PubKey, true
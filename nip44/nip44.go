@@ -0,0 +1,246 @@
+// Package nip44 implements version 2 of the NIP-44 encryption scheme.
+// See https://github.com/nostr-protocol/nips/blob/master/44.md for details.
+package nip44
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	version          byte = 2
+	minPlaintextSize      = 1
+	maxPlaintextSize      = 65535
+)
+
+var (
+	ErrUnsupportedVersion = errors.New("nip44: unknown encryption version")
+	ErrInvalidMAC         = errors.New("nip44: invalid MAC")
+	ErrInvalidPadding     = errors.New("nip44: invalid padding")
+)
+
+var (
+	randomnessMutex sync.Mutex
+	randomness      io.Reader = rand.Reader
+)
+
+// SetRandomness makes Encrypt get its nonces from r instead of crypto/rand, or from crypto/rand
+// again if r is nil. It is only meant for tests, with a seeded reader to get the same payloads
+// every run; reads are serialized, so it can be set while others are encrypting.
+func SetRandomness(r io.Reader) {
+	randomnessMutex.Lock()
+	defer randomnessMutex.Unlock()
+	if r == nil {
+		r = rand.Reader
+	}
+	randomness = r
+}
+
+func readRandom(b []byte) error {
+	randomnessMutex.Lock()
+	defer randomnessMutex.Unlock()
+	_, err := io.ReadFull(randomness, b)
+	return err
+}
+
+type encryptOptions struct {
+	nonce []byte
+}
+
+// EncryptOption customizes Encrypt.
+type EncryptOption func(*encryptOptions)
+
+// WithCustomNonce makes Encrypt use the given 32-byte nonce instead of a random one.
+// It must only be used for testing, reusing nonces is catastrophic.
+func WithCustomNonce(nonce []byte) EncryptOption {
+	return func(o *encryptOptions) {
+		o.nonce = nonce
+	}
+}
+
+// GenerateConversationKey returns the key shared by the owners of pub and sk, which
+// is the same in both directions. The keys should be hex encoded.
+func GenerateConversationKey(pub string, sk string) ([32]byte, error) {
+	var ck [32]byte
+
+	skb, err := hex.DecodeString(sk)
+	if err != nil || len(skb) != 32 {
+		return ck, fmt.Errorf("invalid private key '%s'", sk)
+	}
+	privKey, _ := btcec.PrivKeyFromBytes(skb)
+
+	// adding 02 to signal that this is a compressed public key (33 bytes)
+	pkb, err := hex.DecodeString("02" + pub)
+	if err != nil {
+		return ck, fmt.Errorf("invalid public key '%s': %w", pub, err)
+	}
+	pubKey, err := btcec.ParsePubKey(pkb)
+	if err != nil {
+		return ck, fmt.Errorf("invalid public key '%s': %w", pub, err)
+	}
+
+	// unhashed x coordinate of the shared point
+	shared := btcec.GenerateSharedSecret(privKey, pubKey)
+	copy(ck[:], hkdf.Extract(sha256.New, shared, []byte("nip44-v2")))
+	return ck, nil
+}
+
+// Encrypt encrypts plaintext with the conversation key obtained from GenerateConversationKey.
+// Returns the base64 payload.
+func Encrypt(plaintext string, conversationKey [32]byte, opts ...EncryptOption) (string, error) {
+	options := encryptOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	nonce := options.nonce
+	if nonce == nil {
+		nonce = make([]byte, 32)
+		if err := readRandom(nonce); err != nil {
+			return "", fmt.Errorf("failed to generate nonce: %w", err)
+		}
+	} else if len(nonce) != 32 {
+		return "", fmt.Errorf("nonce must have 32 bytes, has %d", len(nonce))
+	}
+
+	chachaKey, chachaNonce, hmacKey, err := messageKeys(conversationKey, nonce)
+	if err != nil {
+		return "", err
+	}
+
+	padded, err := pad(plaintext)
+	if err != nil {
+		return "", err
+	}
+
+	ciphertext, err := chacha(chachaKey, chachaNonce, padded)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmacAAD(hmacKey, ciphertext, nonce)
+
+	payload := make([]byte, 0, 1+32+len(ciphertext)+32)
+	payload = append(payload, version)
+	payload = append(payload, nonce...)
+	payload = append(payload, ciphertext...)
+	payload = append(payload, mac...)
+	return base64.StdEncoding.EncodeToString(payload), nil
+}
+
+// Decrypt decrypts a base64 payload produced by Encrypt using the conversation key.
+func Decrypt(payload string, conversationKey [32]byte) (string, error) {
+	plen := len(payload)
+	if plen == 0 || payload[0] == '#' {
+		return "", ErrUnsupportedVersion
+	}
+	if plen < 132 || plen > 87472 {
+		return "", fmt.Errorf("nip44: invalid payload length %d", plen)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", fmt.Errorf("nip44: invalid base64: %w", err)
+	}
+	dlen := len(data)
+	if dlen < 99 || dlen > 65603 {
+		return "", fmt.Errorf("nip44: invalid data length %d", dlen)
+	}
+	if data[0] != version {
+		return "", ErrUnsupportedVersion
+	}
+
+	nonce := data[1:33]
+	ciphertext := data[33 : dlen-32]
+	mac := data[dlen-32:]
+
+	chachaKey, chachaNonce, hmacKey, err := messageKeys(conversationKey, nonce)
+	if err != nil {
+		return "", err
+	}
+
+	if !hmac.Equal(hmacAAD(hmacKey, ciphertext, nonce), mac) {
+		return "", ErrInvalidMAC
+	}
+
+	padded, err := chacha(chachaKey, chachaNonce, ciphertext)
+	if err != nil {
+		return "", err
+	}
+
+	return unpad(padded)
+}
+
+func messageKeys(conversationKey [32]byte, nonce []byte) (chachaKey, chachaNonce, hmacKey []byte, err error) {
+	keys := make([]byte, 76)
+	if _, err := io.ReadFull(hkdf.Expand(sha256.New, conversationKey[:], nonce), keys); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to derive message keys: %w", err)
+	}
+	return keys[0:32], keys[32:44], keys[44:76], nil
+}
+
+func chacha(key, nonce, input []byte) ([]byte, error) {
+	cipher, err := chacha20.NewUnauthenticatedCipher(key, nonce)
+	if err != nil {
+		return nil, err
+	}
+	output := make([]byte, len(input))
+	cipher.XORKeyStream(output, input)
+	return output, nil
+}
+
+func hmacAAD(key, message, aad []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(aad)
+	h.Write(message)
+	return h.Sum(nil)
+}
+
+func calcPaddedLen(unpaddedLen int) int {
+	if unpaddedLen <= 32 {
+		return 32
+	}
+	nextPower := 1 << (int(math.Floor(math.Log2(float64(unpaddedLen-1)))) + 1)
+	chunk := 32
+	if nextPower > 256 {
+		chunk = nextPower / 8
+	}
+	return chunk * ((unpaddedLen-1)/chunk + 1)
+}
+
+func pad(plaintext string) ([]byte, error) {
+	size := len(plaintext)
+	if size < minPlaintextSize || size > maxPlaintextSize {
+		return nil, fmt.Errorf("nip44: plaintext must have between %d and %d bytes, has %d",
+			minPlaintextSize, maxPlaintextSize, size)
+	}
+
+	padded := make([]byte, 2+calcPaddedLen(size))
+	binary.BigEndian.PutUint16(padded, uint16(size))
+	copy(padded[2:], plaintext)
+	return padded, nil
+}
+
+func unpad(padded []byte) (string, error) {
+	if len(padded) < 2 {
+		return "", ErrInvalidPadding
+	}
+	size := int(binary.BigEndian.Uint16(padded[0:2]))
+	if size < minPlaintextSize || len(padded) != 2+calcPaddedLen(size) {
+		return "", ErrInvalidPadding
+	}
+	return string(padded[2 : 2+size]), nil
+}
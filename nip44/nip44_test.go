@@ -0,0 +1,84 @@
+package nip44
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestConversationKey(t *testing.T) {
+	sk1 := "0000000000000000000000000000000000000000000000000000000000000001"
+	pub2 := "c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5" // sk = 2
+
+	ck, err := GenerateConversationKey(pub2, sk1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(ck[:]) != "c41c775356fd92eadc63ff5a0dc1da211b268cbea22316767095b2871ea1412d" {
+		t.Errorf("wrong conversation key: %x", ck)
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	sk1 := nostr.GeneratePrivateKey()
+	pub1, _ := nostr.GetPublicKey(sk1)
+	sk2 := nostr.GeneratePrivateKey()
+	pub2, _ := nostr.GetPublicKey(sk2)
+
+	ck1, _ := GenerateConversationKey(pub2, sk1)
+	ck2, _ := GenerateConversationKey(pub1, sk2)
+	if ck1 != ck2 {
+		t.Fatal("conversation keys should be symmetric")
+	}
+
+	for _, plaintext := range []string{"a", "hello, world", strings.Repeat("x", 33), strings.Repeat("ü", 3000)} {
+		payload, err := Encrypt(plaintext, ck1)
+		if err != nil {
+			t.Fatalf("failed to encrypt: %s", err)
+		}
+		decrypted, err := Decrypt(payload, ck2)
+		if err != nil {
+			t.Fatalf("failed to decrypt: %s", err)
+		}
+		if decrypted != plaintext {
+			t.Errorf("decrypted '%s' differs from original", decrypted)
+		}
+
+		tampered := []byte(payload)
+		tampered[50] ^= 1
+		if _, err := Decrypt(string(tampered), ck2); err == nil {
+			t.Error("should have failed to decrypt tampered payload")
+		}
+	}
+
+	if _, err := Encrypt("", ck1); err == nil {
+		t.Error("should refuse to encrypt empty plaintext")
+	}
+}
+
+func TestPaddedLength(t *testing.T) {
+	for unpadded, padded := range map[int]int{
+		1: 32, 32: 32, 33: 64, 37: 64, 64: 64, 65: 96, 100: 128, 256: 256, 257: 320, 1025: 1280, 65535: 65536,
+	} {
+		if calcPaddedLen(unpadded) != padded {
+			t.Errorf("padded length of %d should be %d, got %d", unpadded, padded, calcPaddedLen(unpadded))
+		}
+	}
+}
+
+func TestKnownPayload(t *testing.T) {
+	ck, _ := hex.DecodeString("c41c775356fd92eadc63ff5a0dc1da211b268cbea22316767095b2871ea1412d")
+	nonce, _ := hex.DecodeString("0000000000000000000000000000000000000000000000000000000000000001")
+	var key [32]byte
+	copy(key[:], ck)
+
+	payload, err := Encrypt("a", key, WithCustomNonce(nonce))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload != "AgAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAABee0G5VSK0/9YypIObAtDKfYEAjD35uVkHyB0F4DwrcNaCXlCWZKaArsGrY6M9wnuTMxWfp1RTN9Xga8no+kF5Vsb" {
+		t.Errorf("unexpected payload: %s", payload)
+	}
+}
@@ -0,0 +1,351 @@
+// Package nip46 implements the client side of NIP-46 (Nostr Connect), which lets an
+// application use keys held by a remote signer ("bunker").
+// See https://github.com/nostr-protocol/nips/blob/master/46.md for details.
+package nip46
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip04"
+	"github.com/nbd-wtf/go-nostr/nip44"
+)
+
+var _ nostr.Signer = (*BunkerClient)(nil)
+
+type Request struct {
+	ID     string   `json:"id"`
+	Method string   `json:"method"`
+	Params []string `json:"params"`
+}
+
+type Response struct {
+	ID     string `json:"id"`
+	Result string `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BunkerClient talks to a remote signer through relays and implements nostr.Signer.
+type BunkerClient struct {
+	clientSecretKey string
+	clientPubKey    nostr.PubKey
+	target          nostr.PubKey
+	relays          []*nostr.Relay
+	conversationKey [32]byte
+	onAuth          func(string)
+
+	serial    uint64
+	idPrefix  string
+	listeners map[string]chan Response
+	mutex     sync.Mutex
+
+	// the user pubkey is different from the bunker pubkey, we cache it after the first call,
+	// guarded by mutex
+	userPubKey *nostr.PubKey
+}
+
+// ParseBunkerURL reads a "bunker://<remote-signer-pubkey>?relay=wss://...&secret=..." URL.
+func ParseBunkerURL(bunkerURL string) (target nostr.PubKey, relays []string, secret string, err error) {
+	parsed, err := url.Parse(bunkerURL)
+	if err != nil {
+		return target, nil, "", fmt.Errorf("invalid bunker url: %w", err)
+	}
+	if parsed.Scheme != "bunker" {
+		return target, nil, "", fmt.Errorf("invalid scheme '%s', should be 'bunker'", parsed.Scheme)
+	}
+
+	target, err = nostr.PubKeyFromHex(parsed.Host)
+	if err != nil {
+		return target, nil, "", fmt.Errorf("invalid remote signer pubkey: %w", err)
+	}
+
+	query := parsed.Query()
+	for _, r := range query["relay"] {
+		if nr := nostr.NormalizeURL(r); nr != "" {
+			relays = append(relays, nr)
+		}
+	}
+	if len(relays) == 0 {
+		return target, nil, "", fmt.Errorf("bunker url has no relays")
+	}
+
+	return target, relays, query.Get("secret"), nil
+}
+
+// ConnectBunker parses a bunker:// URL, connects to its relays and performs the "connect" handshake.
+// onAuth is called with a URL whenever the remote signer asks the user to authorize something there.
+func ConnectBunker(ctx context.Context, clientSecretKey string, bunkerURL string, onAuth func(string)) (*BunkerClient, error) {
+	target, relays, secret, err := ParseBunkerURL(bunkerURL)
+	if err != nil {
+		return nil, err
+	}
+
+	bunker, err := NewBunker(ctx, clientSecretKey, target, relays, onAuth)
+	if err != nil {
+		return nil, err
+	}
+
+	params := []string{target.Hex()}
+	if secret != "" {
+		params = append(params, secret)
+	}
+	if _, err := bunker.RPC(ctx, "connect", params); err != nil {
+		bunker.Close()
+		return nil, fmt.Errorf("connect failed: %w", err)
+	}
+
+	return bunker, nil
+}
+
+// NewBunker connects to the given relays and starts listening for responses from the
+// remote signer identified by target, but doesn't send any request.
+func NewBunker(ctx context.Context, clientSecretKey string, target nostr.PubKey, relayURLs []string, onAuth func(string)) (*BunkerClient, error) {
+	clientPubKeyHex, err := nostr.GetPublicKey(clientSecretKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid client secret key: %w", err)
+	}
+	clientPubKey, _ := nostr.PubKeyFromHex(clientPubKeyHex)
+
+	ck, err := nip44.GenerateConversationKey(target.Hex(), clientSecretKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute conversation key: %w", err)
+	}
+
+	bunker := &BunkerClient{
+		clientSecretKey: clientSecretKey,
+		clientPubKey:    clientPubKey,
+		target:          target,
+		conversationKey: ck,
+		onAuth:          onAuth,
+		idPrefix:        "gn-" + strconv.FormatInt(time.Now().Unix(), 36),
+		listeners:       make(map[string]chan Response),
+	}
+
+	now := nostr.Now()
+	filters := nostr.Filters{{
+		Kinds:   []int{nostr.KindNostrConnect},
+		Authors: []nostr.PubKey{target},
+		Tags:    nostr.TagMap{"p": {clientPubKey.Hex()}},
+		Since:   &now,
+	}}
+
+	for _, u := range relayURLs {
+		relay, err := nostr.RelayConnect(ctx, u)
+		if err != nil {
+			continue
+		}
+		sub, err := relay.Sub(context.Background(), filters)
+		if err != nil {
+			relay.Close()
+			continue
+		}
+		bunker.relays = append(bunker.relays, relay)
+
+		go func() {
+			for evt := range sub.Events {
+				bunker.handleResponse(evt)
+			}
+		}()
+	}
+	if len(bunker.relays) == 0 {
+		return nil, fmt.Errorf("failed to connect to any of the bunker relays")
+	}
+
+	return bunker, nil
+}
+
+func (bunker *BunkerClient) handleResponse(evt *nostr.Event) {
+	var plain string
+	var err error
+	if strings.Contains(evt.Content, "?iv=") {
+		// some signers still answer with NIP-04
+		var secret []byte
+		secret, err = nip04.ComputeSharedSecret(bunker.target.Hex(), bunker.clientSecretKey)
+		if err == nil {
+			plain, err = nip04.Decrypt(evt.Content, secret)
+		}
+	} else {
+		plain, err = nip44.Decrypt(evt.Content, bunker.conversationKey)
+	}
+	if err != nil {
+		return
+	}
+
+	var resp Response
+	if err := json.Unmarshal([]byte(plain), &resp); err != nil {
+		return
+	}
+
+	bunker.mutex.Lock()
+	ch, ok := bunker.listeners[resp.ID]
+	bunker.mutex.Unlock()
+	if !ok {
+		return
+	}
+
+	select {
+	case ch <- resp:
+	default:
+	}
+}
+
+// RPC sends a request to the remote signer and waits for its result.
+func (bunker *BunkerClient) RPC(ctx context.Context, method string, params []string) (string, error) {
+	id := bunker.idPrefix + "-" + strconv.FormatUint(atomic.AddUint64(&bunker.serial, 1), 10)
+	req, err := json.Marshal(Request{ID: id, Method: method, Params: params})
+	if err != nil {
+		return "", err
+	}
+
+	content, err := nip44.Encrypt(string(req), bunker.conversationKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt request: %w", err)
+	}
+
+	evt := nostr.Event{
+		PubKey:    bunker.clientPubKey,
+		CreatedAt: nostr.Now(),
+		Kind:      nostr.KindNostrConnect,
+		Tags:      nostr.Tags{{"p", bunker.target.Hex()}},
+		Content:   content,
+	}
+	if err := evt.Sign(bunker.clientSecretKey); err != nil {
+		return "", fmt.Errorf("failed to sign request: %w", err)
+	}
+
+	respCh := make(chan Response, 4)
+	bunker.mutex.Lock()
+	bunker.listeners[id] = respCh
+	bunker.mutex.Unlock()
+	defer func() {
+		bunker.mutex.Lock()
+		delete(bunker.listeners, id)
+		bunker.mutex.Unlock()
+	}()
+
+	sent := false
+	for _, relay := range bunker.relays {
+		if _, err := relay.Publish(ctx, evt); err == nil {
+			sent = true
+		}
+	}
+	if !sent {
+		return "", fmt.Errorf("failed to send request to any relay")
+	}
+
+	for {
+		select {
+		case resp := <-respCh:
+			if resp.Result == "auth_url" {
+				// the user must go to this url and authorize, then we'll get the real response
+				if bunker.onAuth != nil {
+					bunker.onAuth(resp.Error)
+				}
+				continue
+			}
+			if resp.Error != "" {
+				return "", fmt.Errorf("remote signer error: %s", resp.Error)
+			}
+			return resp.Result, nil
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// Ping checks if the remote signer is responding.
+func (bunker *BunkerClient) Ping(ctx context.Context) error {
+	_, err := bunker.RPC(ctx, "ping", []string{})
+	return err
+}
+
+func (bunker *BunkerClient) GetPublicKey(ctx context.Context) (nostr.PubKey, error) {
+	bunker.mutex.Lock()
+	cached := bunker.userPubKey
+	bunker.mutex.Unlock()
+	if cached != nil {
+		return *cached, nil
+	}
+
+	resp, err := bunker.RPC(ctx, "get_public_key", []string{})
+	if err != nil {
+		return nostr.ZeroPubKey, err
+	}
+	pk, err := nostr.PubKeyFromHex(resp)
+	if err != nil {
+		return pk, fmt.Errorf("remote signer returned invalid pubkey: %w", err)
+	}
+
+	bunker.mutex.Lock()
+	bunker.userPubKey = &pk
+	bunker.mutex.Unlock()
+	return pk, nil
+}
+
+func (bunker *BunkerClient) SignEvent(ctx context.Context, evt *nostr.Event) error {
+	unsigned, err := json.Marshal(map[string]any{
+		"kind":       evt.Kind,
+		"content":    evt.Content,
+		"tags":       evt.Tags,
+		"created_at": evt.CreatedAt.Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := bunker.RPC(ctx, "sign_event", []string{string(unsigned)})
+	if err != nil {
+		return err
+	}
+
+	var signed nostr.Event
+	if err := json.Unmarshal([]byte(resp), &signed); err != nil {
+		return fmt.Errorf("remote signer returned invalid event: %w", err)
+	}
+
+	// make sure what was signed is the event we asked for, not just any valid event
+	result := *evt
+	result.PubKey = signed.PubKey
+	result.ID = result.GetID()
+	result.Sig = signed.Sig
+	if result.ID != signed.ID {
+		return fmt.Errorf("remote signer signed a different event")
+	}
+	if ok, _ := result.CheckSignature(); !ok {
+		return fmt.Errorf("remote signer returned an invalid signature")
+	}
+
+	*evt = result
+	return nil
+}
+
+func (bunker *BunkerClient) NIP04Encrypt(ctx context.Context, plaintext string, recipient nostr.PubKey) (string, error) {
+	return bunker.RPC(ctx, "nip04_encrypt", []string{recipient.Hex(), plaintext})
+}
+
+func (bunker *BunkerClient) NIP04Decrypt(ctx context.Context, ciphertext string, sender nostr.PubKey) (string, error) {
+	return bunker.RPC(ctx, "nip04_decrypt", []string{sender.Hex(), ciphertext})
+}
+
+func (bunker *BunkerClient) NIP44Encrypt(ctx context.Context, plaintext string, recipient nostr.PubKey) (string, error) {
+	return bunker.RPC(ctx, "nip44_encrypt", []string{recipient.Hex(), plaintext})
+}
+
+func (bunker *BunkerClient) NIP44Decrypt(ctx context.Context, ciphertext string, sender nostr.PubKey) (string, error) {
+	return bunker.RPC(ctx, "nip44_decrypt", []string{sender.Hex(), ciphertext})
+}
+
+// Close disconnects from all the bunker relays.
+func (bunker *BunkerClient) Close() {
+	for _, relay := range bunker.relays {
+		relay.Close()
+	}
+}
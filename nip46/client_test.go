@@ -0,0 +1,29 @@
+package nip46
+
+import "testing"
+
+func TestParseBunkerURL(t *testing.T) {
+	target, relays, secret, err := ParseBunkerURL("bunker://3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d?relay=wss://relay.example.com&relay=wss://other.example.com/&secret=abcd")
+	if err != nil {
+		t.Fatalf("failed to parse: %s", err)
+	}
+	if target.Hex() != "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d" {
+		t.Errorf("wrong target %s", target)
+	}
+	if len(relays) != 2 || relays[0] != "wss://relay.example.com" || relays[1] != "wss://other.example.com" {
+		t.Errorf("wrong relays %v", relays)
+	}
+	if secret != "abcd" {
+		t.Errorf("wrong secret %s", secret)
+	}
+
+	for _, invalid := range []string{
+		"nostrconnect://3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d?relay=wss://relay.example.com",
+		"bunker://3bf0c63f?relay=wss://relay.example.com",
+		"bunker://3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d",
+	} {
+		if _, _, _, err := ParseBunkerURL(invalid); err == nil {
+			t.Errorf("should have failed to parse %s", invalid)
+		}
+	}
+}
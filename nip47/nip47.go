@@ -0,0 +1,206 @@
+// Package nip47 implements Nostr Wallet Connect: apps send encrypted kind-23194 requests to a
+// wallet service, which pays and creates invoices for them and answers with kind-23195
+// responses. This package has the messages and a Service for Go wallet backends to expose
+// their wallet.
+// See https://github.com/nostr-protocol/nips/blob/master/47.md for details.
+package nip47
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	KindInfo     = 13194
+	KindRequest  = 23194
+	KindResponse = 23195
+)
+
+// Methods of the protocol.
+const (
+	MethodPayInvoice       = "pay_invoice"
+	MethodPayKeysend       = "pay_keysend"
+	MethodMakeInvoice      = "make_invoice"
+	MethodLookupInvoice    = "lookup_invoice"
+	MethodListTransactions = "list_transactions"
+	MethodGetBalance       = "get_balance"
+	MethodGetInfo          = "get_info"
+)
+
+// Error codes a wallet service answers with.
+const (
+	CodeRateLimited           = "RATE_LIMITED"
+	CodeNotImplemented        = "NOT_IMPLEMENTED"
+	CodeInsufficientBalance   = "INSUFFICIENT_BALANCE"
+	CodeQuotaExceeded         = "QUOTA_EXCEEDED"
+	CodeRestricted            = "RESTRICTED"
+	CodeUnauthorized          = "UNAUTHORIZED"
+	CodeInternal              = "INTERNAL"
+	CodeOther                 = "OTHER"
+	CodePaymentFailed         = "PAYMENT_FAILED"
+	CodeNotFound              = "NOT_FOUND"
+	CodeUnsupportedEncryption = "UNSUPPORTED_ENCRYPTION"
+)
+
+// Error is the error of a response. Handlers return it to answer with a specific code, other
+// errors are sent as INTERNAL.
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string { return e.Code + ": " + e.Message }
+
+// ErrNotImplemented is returned by NotImplemented for every method.
+var ErrNotImplemented = &Error{Code: CodeNotImplemented, Message: "method not implemented"}
+
+// Request is the decrypted content of a kind-23194 event.
+type Request struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// Response is the content of a kind-23195 event, with either Error or Result set.
+type Response struct {
+	ResultType string          `json:"result_type"`
+	Error      *Error          `json:"error"`
+	Result     json.RawMessage `json:"result"`
+}
+
+type PayInvoiceParams struct {
+	Invoice string `json:"invoice"`
+	Amount  int64  `json:"amount,omitempty"` // msats, for invoices without an amount
+}
+
+type PayResult struct {
+	Preimage string `json:"preimage"`
+	FeesPaid int64  `json:"fees_paid,omitempty"` // msats
+}
+
+type TLVRecord struct {
+	Type  uint64 `json:"type"`
+	Value string `json:"value"` // hex
+}
+
+type PayKeysendParams struct {
+	Amount     int64       `json:"amount"` // msats
+	PubKey     string      `json:"pubkey"`
+	Preimage   string      `json:"preimage,omitempty"`
+	TLVRecords []TLVRecord `json:"tlv_records,omitempty"`
+}
+
+type MakeInvoiceParams struct {
+	Amount          int64  `json:"amount"` // msats
+	Description     string `json:"description,omitempty"`
+	DescriptionHash string `json:"description_hash,omitempty"`
+	Expiry          int64  `json:"expiry,omitempty"` // seconds
+}
+
+// LookupInvoiceParams has one of PaymentHash and Invoice.
+type LookupInvoiceParams struct {
+	PaymentHash string `json:"payment_hash,omitempty"`
+	Invoice     string `json:"invoice,omitempty"`
+}
+
+type ListTransactionsParams struct {
+	From   int64  `json:"from,omitempty"`
+	Until  int64  `json:"until,omitempty"`
+	Limit  int    `json:"limit,omitempty"`
+	Offset int    `json:"offset,omitempty"`
+	Unpaid bool   `json:"unpaid,omitempty"`
+	Type   string `json:"type,omitempty"` // "incoming" or "outgoing", both if empty
+}
+
+// Transaction is an invoice or payment, as returned by make_invoice, lookup_invoice and
+// list_transactions. Amounts are in msats and times in unix seconds.
+type Transaction struct {
+	Type            string         `json:"type"` // "incoming" or "outgoing"
+	Invoice         string         `json:"invoice,omitempty"`
+	Description     string         `json:"description,omitempty"`
+	DescriptionHash string         `json:"description_hash,omitempty"`
+	Preimage        string         `json:"preimage,omitempty"`
+	PaymentHash     string         `json:"payment_hash"`
+	Amount          int64          `json:"amount"`
+	FeesPaid        int64          `json:"fees_paid"`
+	CreatedAt       int64          `json:"created_at"`
+	ExpiresAt       int64          `json:"expires_at,omitempty"`
+	SettledAt       int64          `json:"settled_at,omitempty"`
+	Metadata        map[string]any `json:"metadata,omitempty"`
+}
+
+type ListTransactionsResult struct {
+	Transactions []Transaction `json:"transactions"`
+}
+
+type GetBalanceResult struct {
+	Balance int64 `json:"balance"` // msats
+}
+
+type GetInfoResult struct {
+	Alias       string   `json:"alias,omitempty"`
+	Color       string   `json:"color,omitempty"`
+	PubKey      string   `json:"pubkey,omitempty"` // of the lightning node
+	Network     string   `json:"network,omitempty"`
+	BlockHeight uint32   `json:"block_height,omitempty"`
+	BlockHash   string   `json:"block_hash,omitempty"`
+	Methods     []string `json:"methods"`
+}
+
+// ConnectionURI is what a wallet service gives an app so it can connect, as a
+// "nostr+walletconnect://" URI. Secret is the hex private key the app signs its requests with.
+type ConnectionURI struct {
+	WalletPubKey nostr.PubKey
+	Relays       []string
+	Secret       string
+	LUD16        string // optional lightning address of the wallet
+}
+
+func (c ConnectionURI) String() string {
+	query := url.Values{}
+	for _, relay := range c.Relays {
+		query.Add("relay", relay)
+	}
+	query.Set("secret", c.Secret)
+	if c.LUD16 != "" {
+		query.Set("lud16", c.LUD16)
+	}
+	return "nostr+walletconnect://" + c.WalletPubKey.Hex() + "?" + query.Encode()
+}
+
+// ClientPubKey is the public key the app will sign its requests with.
+func (c ConnectionURI) ClientPubKey() (nostr.PubKey, error) {
+	pk, err := nostr.GetPublicKey(c.Secret)
+	if err != nil {
+		return nostr.PubKey{}, err
+	}
+	return nostr.PubKeyFromHex(pk)
+}
+
+// ParseConnectionURI reads a "nostr+walletconnect://" URI.
+func ParseConnectionURI(uri string) (ConnectionURI, error) {
+	var c ConnectionURI
+	if !strings.HasPrefix(uri, "nostr+walletconnect:") {
+		return c, fmt.Errorf("not a wallet connect URI")
+	}
+	rest := strings.TrimPrefix(strings.TrimPrefix(uri, "nostr+walletconnect:"), "//")
+	pk, query, _ := strings.Cut(rest, "?")
+	var err error
+	if c.WalletPubKey, err = nostr.PubKeyFromHex(pk); err != nil {
+		return c, fmt.Errorf("invalid wallet pubkey: %w", err)
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return c, err
+	}
+	c.Relays = values["relay"]
+	c.Secret = values.Get("secret")
+	c.LUD16 = values.Get("lud16")
+	if len(c.Relays) == 0 || len(c.Secret) != 64 {
+		return c, fmt.Errorf("wallet connect URI needs a relay and a secret")
+	}
+	return c, nil
+}
@@ -0,0 +1,143 @@
+package nip47
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/keyer"
+	"github.com/nbd-wtf/go-nostr/server"
+)
+
+func TestConnectionURI(t *testing.T) {
+	secret := nostr.GeneratePrivateKey()
+	walletHex, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+	wallet := nostr.MustPubKeyFromHex(walletHex)
+	uri := ConnectionURI{WalletPubKey: wallet, Relays: []string{"wss://a.com", "wss://b.com"}, Secret: secret, LUD16: "me@a.com"}
+
+	parsed, err := ParseConnectionURI(uri.String())
+	if err != nil || parsed.WalletPubKey != wallet || len(parsed.Relays) != 2 || parsed.Secret != secret || parsed.LUD16 != "me@a.com" {
+		t.Fatalf("unexpected %+v, %v", parsed, err)
+	}
+	for _, bad := range []string{"nostr:" + wallet.Hex(), "nostr+walletconnect://" + wallet.Hex() + "?secret=" + secret} {
+		if _, err := ParseConnectionURI(bad); err == nil {
+			t.Errorf("%s should be rejected", bad)
+		}
+	}
+}
+
+type testWallet struct {
+	NotImplemented
+}
+
+func (testWallet) GetBalance(context.Context, nostr.PubKey) (GetBalanceResult, error) {
+	return GetBalanceResult{Balance: 21000}, nil
+}
+
+func (testWallet) PayInvoice(ctx context.Context, client nostr.PubKey, params PayInvoiceParams) (PayResult, error) {
+	if params.Invoice == "lnbc-too-much" {
+		return PayResult{}, &Error{Code: CodeInsufficientBalance, Message: "not enough sats"}
+	}
+	return PayResult{Preimage: "00ff"}, nil
+}
+
+func TestService(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ts := httptest.NewServer(server.New(nil))
+	defer ts.Close()
+	url := "ws" + strings.TrimPrefix(ts.URL, "http")
+
+	walletSigner, _ := keyer.NewPlainKeySigner(nostr.GeneratePrivateKey())
+	service, err := NewService(ctx, nostr.NewSimplePool(ctx), walletSigner, []string{url}, testWallet{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := nostr.GeneratePrivateKey()
+	client, _ := keyer.NewPlainKeySigner(secret)
+	clientPK, _ := client.GetPublicKey(ctx)
+	service.Authorize = AllowClients(clientPK)
+	go service.Run(ctx)
+
+	conn, err := nostr.RelayConnect(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	responses, err := conn.Sub(ctx, nostr.Filters{{Kinds: []int{KindResponse}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	call := func(signer nostr.Signer, nip44 bool, method string, params any) Response {
+		pk, _ := signer.GetPublicKey(ctx)
+		j, _ := json.Marshal(params)
+		content, _ := json.Marshal(Request{Method: method, Params: j})
+		wallet := service.Connection(secret).WalletPubKey
+		evt := nostr.Event{Kind: KindRequest, PubKey: pk, CreatedAt: nostr.Now(), Tags: nostr.Tags{{"p", wallet.Hex()}}}
+		if nip44 {
+			evt.Tags = append(evt.Tags, nostr.Tag{"encryption", "nip44_v2"})
+			evt.Content, _ = signer.NIP44Encrypt(ctx, string(content), wallet)
+		} else {
+			evt.Content, _ = signer.NIP04Encrypt(ctx, string(content), wallet)
+		}
+		signer.SignEvent(ctx, &evt)
+
+		// the service may not be listening yet, so the request is sent until it is answered
+		ticker := time.NewTicker(200 * time.Millisecond)
+		defer ticker.Stop()
+		conn.Publish(ctx, evt)
+		for {
+			select {
+			case res := <-responses.Events:
+				if res.Tags.GetFirst([]string{"e", evt.ID.Hex()}) == nil {
+					continue
+				}
+				var plaintext string
+				if nip44 {
+					plaintext, err = signer.NIP44Decrypt(ctx, res.Content, res.PubKey)
+				} else {
+					plaintext, err = signer.NIP04Decrypt(ctx, res.Content, res.PubKey)
+				}
+				var response Response
+				if err != nil || json.Unmarshal([]byte(plaintext), &response) != nil {
+					t.Fatalf("unreadable response %s, %v", plaintext, err)
+				}
+				return response
+			case <-ticker.C:
+				conn.Publish(ctx, evt)
+			case <-ctx.Done():
+				t.Fatalf("no response to %s", method)
+			}
+		}
+	}
+
+	res := call(client, true, MethodGetBalance, nil)
+	var balance GetBalanceResult
+	if res.Error != nil || res.ResultType != MethodGetBalance || json.Unmarshal(res.Result, &balance) != nil || balance.Balance != 21000 {
+		t.Fatalf("unexpected get_balance response %+v", res)
+	}
+
+	res = call(client, false, MethodPayInvoice, PayInvoiceParams{Invoice: "lnbc1"})
+	var paid PayResult
+	if res.Error != nil || json.Unmarshal(res.Result, &paid) != nil || paid.Preimage != "00ff" {
+		t.Fatalf("unexpected pay_invoice response %+v", res)
+	}
+
+	if res = call(client, true, MethodPayInvoice, PayInvoiceParams{Invoice: "lnbc-too-much"}); res.Error == nil || res.Error.Code != CodeInsufficientBalance {
+		t.Errorf("expected INSUFFICIENT_BALANCE, got %+v", res)
+	}
+	if res = call(client, true, MethodMakeInvoice, MakeInvoiceParams{Amount: 1000}); res.Error == nil || res.Error.Code != CodeNotImplemented {
+		t.Errorf("expected NOT_IMPLEMENTED, got %+v", res)
+	}
+
+	stranger, _ := keyer.NewPlainKeySigner(nostr.GeneratePrivateKey())
+	if res = call(stranger, true, MethodGetBalance, nil); res.Error == nil || res.Error.Code != CodeUnauthorized {
+		t.Errorf("expected UNAUTHORIZED, got %+v", res)
+	}
+}
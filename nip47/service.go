@@ -0,0 +1,343 @@
+package nip47
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Handler does what apps ask a Service. Methods can return an *Error to answer with a specific
+// code, e.g. INSUFFICIENT_BALANCE. Embed NotImplemented to only implement some of them.
+type Handler interface {
+	PayInvoice(ctx context.Context, client nostr.PubKey, params PayInvoiceParams) (PayResult, error)
+	PayKeysend(ctx context.Context, client nostr.PubKey, params PayKeysendParams) (PayResult, error)
+	MakeInvoice(ctx context.Context, client nostr.PubKey, params MakeInvoiceParams) (Transaction, error)
+	LookupInvoice(ctx context.Context, client nostr.PubKey, params LookupInvoiceParams) (Transaction, error)
+	ListTransactions(ctx context.Context, client nostr.PubKey, params ListTransactionsParams) (ListTransactionsResult, error)
+	GetBalance(ctx context.Context, client nostr.PubKey) (GetBalanceResult, error)
+	GetInfo(ctx context.Context, client nostr.PubKey) (GetInfoResult, error)
+}
+
+// NotImplemented answers every method with NOT_IMPLEMENTED.
+type NotImplemented struct{}
+
+func (NotImplemented) PayInvoice(context.Context, nostr.PubKey, PayInvoiceParams) (PayResult, error) {
+	return PayResult{}, ErrNotImplemented
+}
+
+func (NotImplemented) PayKeysend(context.Context, nostr.PubKey, PayKeysendParams) (PayResult, error) {
+	return PayResult{}, ErrNotImplemented
+}
+
+func (NotImplemented) MakeInvoice(context.Context, nostr.PubKey, MakeInvoiceParams) (Transaction, error) {
+	return Transaction{}, ErrNotImplemented
+}
+
+func (NotImplemented) LookupInvoice(context.Context, nostr.PubKey, LookupInvoiceParams) (Transaction, error) {
+	return Transaction{}, ErrNotImplemented
+}
+
+func (NotImplemented) ListTransactions(context.Context, nostr.PubKey, ListTransactionsParams) (ListTransactionsResult, error) {
+	return ListTransactionsResult{}, ErrNotImplemented
+}
+
+func (NotImplemented) GetBalance(context.Context, nostr.PubKey) (GetBalanceResult, error) {
+	return GetBalanceResult{}, ErrNotImplemented
+}
+
+func (NotImplemented) GetInfo(context.Context, nostr.PubKey) (GetInfoResult, error) {
+	return GetInfoResult{}, ErrNotImplemented
+}
+
+// Service is the wallet side of NWC: it listens for requests to the owner of Signer on Relays,
+// has Handler do them and publishes the responses, encrypted with NIP-44 or NIP-04 like each
+// request was.
+type Service struct {
+	Pool    *nostr.SimplePool
+	Signer  nostr.Signer
+	Relays  []string
+	Handler Handler
+
+	// Methods are the ones announced in the info event, only these are given to Handler.
+	Methods []string
+
+	// Authorize decides if client can call method, returning an *Error with the code to answer
+	// otherwise. Requests from clients it doesn't know should get UNAUTHORIZED. If it is nil
+	// every request is refused, see AllowClients.
+	Authorize func(ctx context.Context, client nostr.PubKey, method string) error
+
+	// OnError, if set, is called with requests that couldn't be read or answered.
+	OnError func(evt *nostr.Event, err error)
+
+	me nostr.PubKey
+}
+
+// NewService creates a Service announcing all the methods, which only does something once
+// Authorize is set.
+func NewService(ctx context.Context, pool *nostr.SimplePool, signer nostr.Signer, relays []string, handler Handler) (*Service, error) {
+	me, err := signer.GetPublicKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get our public key: %w", err)
+	}
+	return &Service{
+		Pool:    pool,
+		Signer:  signer,
+		Relays:  relays,
+		Handler: handler,
+		Methods: []string{
+			MethodPayInvoice, MethodPayKeysend, MethodMakeInvoice, MethodLookupInvoice,
+			MethodListTransactions, MethodGetBalance, MethodGetInfo,
+		},
+		me: me,
+	}, nil
+}
+
+// AllowClients returns a Service.Authorize function that lets the given clients call any method.
+func AllowClients(clients ...nostr.PubKey) func(context.Context, nostr.PubKey, string) error {
+	allowed := make(map[nostr.PubKey]struct{}, len(clients))
+	for _, pk := range clients {
+		allowed[pk] = struct{}{}
+	}
+	return func(ctx context.Context, client nostr.PubKey, method string) error {
+		if _, ok := allowed[client]; !ok {
+			return &Error{Code: CodeUnauthorized, Message: "no wallet connected for this public key"}
+		}
+		return nil
+	}
+}
+
+// Connection returns the URI for an app to connect with secret, the hex private key its
+// requests will be signed with.
+func (s *Service) Connection(secret string) ConnectionURI {
+	return ConnectionURI{WalletPubKey: s.me, Relays: s.Relays, Secret: secret}
+}
+
+// InfoEvent returns the signed kind-13194 event announcing Methods and the encryptions we support.
+func (s *Service) InfoEvent(ctx context.Context) (nostr.Event, error) {
+	evt := nostr.Event{
+		Kind:      KindInfo,
+		PubKey:    s.me,
+		CreatedAt: nostr.Now(),
+		Tags:      nostr.Tags{{"encryption", "nip44_v2 nip04"}},
+		Content:   strings.Join(s.Methods, " "),
+	}
+	if err := s.Signer.SignEvent(ctx, &evt); err != nil {
+		return evt, fmt.Errorf("failed to sign info event: %w", err)
+	}
+	return evt, nil
+}
+
+// Run publishes the info event and answers requests until ctx is canceled. Requests sent
+// before it started are ignored, since apps don't wait that long for an answer.
+func (s *Service) Run(ctx context.Context) error {
+	info, err := s.InfoEvent(ctx)
+	if err != nil {
+		return err
+	}
+	if err := s.publish(ctx, info); err != nil {
+		return fmt.Errorf("failed to publish info event: %w", err)
+	}
+
+	since := nostr.Now().Truncate(time.Second) // events are in seconds
+	requests := s.Pool.SubMany(ctx, s.Relays, nostr.Filters{{
+		Kinds: []int{KindRequest},
+		Tags:  nostr.TagMap{"p": []string{s.me.Hex()}},
+		Since: &since,
+	}})
+
+	wg := sync.WaitGroup{}
+	for evt := range requests {
+		wg.Add(1)
+		go func(evt *nostr.Event) {
+			defer wg.Done()
+			if err := s.handle(ctx, evt); err != nil && s.OnError != nil {
+				s.OnError(evt, err)
+			}
+		}(evt)
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// handle answers one request, ignoring the ones that aren't for us or have expired.
+func (s *Service) handle(ctx context.Context, evt *nostr.Event) error {
+	if evt.Kind != KindRequest || evt.Tags.GetFirst([]string{"p", s.me.Hex()}) == nil {
+		return nil
+	}
+	if expiration, ok := evt.Expiration(); ok && !expiration.After(nostr.Now()) {
+		return nil
+	}
+
+	nip44 := false
+	if tag := evt.Tags.GetFirst([]string{"encryption", ""}); tag != nil {
+		switch tag.Value() {
+		case "nip44_v2":
+			nip44 = true
+		case "nip04":
+		default:
+			// we don't know how to encrypt the answer, so it goes in NIP-04 as the spec says
+			return s.respond(ctx, evt, false, "", nil, &Error{Code: CodeUnsupportedEncryption, Message: "use nip44_v2 or nip04"})
+		}
+	}
+
+	var plaintext string
+	var err error
+	if nip44 {
+		plaintext, err = s.Signer.NIP44Decrypt(ctx, evt.Content, evt.PubKey)
+	} else {
+		plaintext, err = s.Signer.NIP04Decrypt(ctx, evt.Content, evt.PubKey)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to decrypt request: %w", err)
+	}
+	var req Request
+	if err := json.Unmarshal([]byte(plaintext), &req); err != nil {
+		return s.respond(ctx, evt, nip44, "", nil, &Error{Code: CodeOther, Message: "invalid request"})
+	}
+
+	result, err := s.dispatch(ctx, evt.PubKey, req)
+	return s.respond(ctx, evt, nip44, req.Method, result, err)
+}
+
+func (s *Service) dispatch(ctx context.Context, client nostr.PubKey, req Request) (any, error) {
+	supported := false
+	for _, method := range s.Methods {
+		supported = supported || method == req.Method
+	}
+	if !supported {
+		return nil, &Error{Code: CodeNotImplemented, Message: fmt.Sprintf("method '%s' not supported", req.Method)}
+	}
+	if s.Authorize == nil {
+		return nil, &Error{Code: CodeUnauthorized, Message: "wallet doesn't take requests"}
+	}
+	if err := s.Authorize(ctx, client, req.Method); err != nil {
+		return nil, err
+	}
+
+	params := req.Params
+	if len(params) == 0 || string(params) == "null" {
+		params = json.RawMessage("{}")
+	}
+	decode := func(v any) error {
+		if err := json.Unmarshal(params, v); err != nil {
+			return &Error{Code: CodeOther, Message: "invalid params: " + err.Error()}
+		}
+		return nil
+	}
+
+	switch req.Method {
+	case MethodPayInvoice:
+		var p PayInvoiceParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		return s.Handler.PayInvoice(ctx, client, p)
+	case MethodPayKeysend:
+		var p PayKeysendParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		return s.Handler.PayKeysend(ctx, client, p)
+	case MethodMakeInvoice:
+		var p MakeInvoiceParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		return s.Handler.MakeInvoice(ctx, client, p)
+	case MethodLookupInvoice:
+		var p LookupInvoiceParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		return s.Handler.LookupInvoice(ctx, client, p)
+	case MethodListTransactions:
+		var p ListTransactionsParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		return s.Handler.ListTransactions(ctx, client, p)
+	case MethodGetBalance:
+		return s.Handler.GetBalance(ctx, client)
+	case MethodGetInfo:
+		info, err := s.Handler.GetInfo(ctx, client)
+		if err == nil && info.Methods == nil {
+			info.Methods = s.Methods
+		}
+		return info, err
+	}
+	return nil, &Error{Code: CodeNotImplemented, Message: fmt.Sprintf("unknown method '%s'", req.Method)}
+}
+
+// respond publishes the response to req, with result or, if err isn't nil, the error.
+func (s *Service) respond(ctx context.Context, req *nostr.Event, nip44 bool, method string, result any, err error) error {
+	res := Response{ResultType: method}
+	if err != nil {
+		var nwcErr *Error
+		if !errors.As(err, &nwcErr) {
+			nwcErr = &Error{Code: CodeInternal, Message: err.Error()}
+		}
+		res.Error = nwcErr
+	} else {
+		j, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		res.Result = j
+	}
+	content, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+
+	evt := nostr.Event{
+		Kind:      KindResponse,
+		PubKey:    s.me,
+		CreatedAt: nostr.Now(),
+		Tags:      nostr.Tags{{"p", req.PubKey.Hex()}, {"e", req.ID.Hex()}},
+	}
+	if nip44 {
+		evt.Tags = append(evt.Tags, nostr.Tag{"encryption", "nip44_v2"})
+		evt.Content, err = s.Signer.NIP44Encrypt(ctx, string(content), req.PubKey)
+	} else {
+		evt.Content, err = s.Signer.NIP04Encrypt(ctx, string(content), req.PubKey)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encrypt response: %w", err)
+	}
+	if err := s.Signer.SignEvent(ctx, &evt); err != nil {
+		return fmt.Errorf("failed to sign response: %w", err)
+	}
+	return s.publish(ctx, evt)
+}
+
+func (s *Service) publish(ctx context.Context, evt nostr.Event) error {
+	var mu sync.Mutex
+	accepted := false
+	wg := sync.WaitGroup{}
+	for _, url := range s.Relays {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			relay, err := s.Pool.EnsureRelay(url)
+			if err != nil {
+				return
+			}
+			if status, _ := relay.Publish(ctx, evt); status == nostr.PublishStatusSucceeded {
+				mu.Lock()
+				accepted = true
+				mu.Unlock()
+			}
+		}(url)
+	}
+	wg.Wait()
+	if !accepted {
+		return fmt.Errorf("event wasn't accepted by any relay")
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+// Package nip48 implements proxy tags, which mark events mirrored from other protocols with
+// the identifier of the original object.
+// See https://github.com/nostr-protocol/nips/blob/master/48.md for details.
+package nip48
+
+import (
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	ProtocolActivityPub = "activitypub" // the id is the URL of the object
+	ProtocolATProto     = "atproto"     // the id is an at:// URI
+	ProtocolRSS         = "rss"         // the id is the URL of the item, with a fragment if needed
+	ProtocolWeb         = "web"         // the id is the URL of the page
+)
+
+// Proxy is where an event came from.
+type Proxy struct {
+	ID       string
+	Protocol string
+}
+
+// SetProxy adds a "proxy" tag to evt, replacing any existing one. It must be called before signing.
+func SetProxy(evt *nostr.Event, proxy Proxy) {
+	evt.Tags = append(evt.Tags.FilterOut([]string{"proxy"}), nostr.Tag{"proxy", proxy.ID, proxy.Protocol})
+}
+
+// GetProxy returns the origin of evt, if it was mirrored from another protocol.
+func GetProxy(evt *nostr.Event) (Proxy, bool) {
+	tag := evt.Tags.GetFirst([]string{"proxy", ""})
+	if tag == nil || len(*tag) < 3 {
+		return Proxy{}, false
+	}
+	return Proxy{ID: (*tag)[1], Protocol: (*tag)[2]}, true
+}
+
+// Filter returns a filter for the events mirroring the object with the given id, so bridges can
+// check if somebody already mirrored it.
+func Filter(id string) nostr.Filter {
+	return nostr.Filter{Tags: nostr.TagMap{"proxy": []string{id}}}
+}
+
+// Dedupe removes events mirroring an object that an earlier event in the list already mirrors,
+// keeping the order. Different bridges may mirror the same object, and the same bridge may
+// do it more than once.
+func Dedupe(events []*nostr.Event) []*nostr.Event {
+	seen := make(map[Proxy]struct{})
+	result := make([]*nostr.Event, 0, len(events))
+	for _, evt := range events {
+		if proxy, ok := GetProxy(evt); ok {
+			if _, dup := seen[proxy]; dup {
+				continue
+			}
+			seen[proxy] = struct{}{}
+		}
+		result = append(result, evt)
+	}
+	return result
+}
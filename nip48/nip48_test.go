@@ -0,0 +1,31 @@
+package nip48
+
+import (
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestProxy(t *testing.T) {
+	origin := Proxy{ID: "https://gleasonator.com/objects/8f6fac53-4f66-4c6e-ac7d-92e5e78c3e79", Protocol: ProtocolActivityPub}
+
+	a := &nostr.Event{Kind: 1, Content: "hello"}
+	SetProxy(a, Proxy{ID: "old", Protocol: ProtocolWeb})
+	SetProxy(a, origin)
+	if len(a.Tags) != 1 {
+		t.Fatalf("proxy tag wasn't replaced: %v", a.Tags)
+	}
+	if proxy, ok := GetProxy(a); !ok || proxy != origin {
+		t.Fatalf("unexpected proxy %v", proxy)
+	}
+	if !Filter(origin.ID).Matches(a) {
+		t.Fatal("filter doesn't match")
+	}
+
+	b := &nostr.Event{Kind: 1, Content: "hello from another bridge"}
+	SetProxy(b, origin)
+	native := &nostr.Event{Kind: 1, Content: "native"}
+	if events := Dedupe([]*nostr.Event{a, native, b}); len(events) != 2 || events[0] != a || events[1] != native {
+		t.Fatalf("unexpected dedupe result: %v", events)
+	}
+}
@@ -0,0 +1,124 @@
+// Package nip56 implements kind-1984 reports, used to flag users and events as spam,
+// illegal and so on.
+// See https://github.com/nostr-protocol/nips/blob/master/56.md for details.
+package nip56
+
+import (
+	"fmt"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const KindReporting = nostr.KindReporting
+
+func init() {
+	nostr.RegisterKindValidator(KindReporting, Validate)
+}
+
+type ReportType string
+
+const (
+	ReportNudity        ReportType = "nudity"
+	ReportMalware       ReportType = "malware"
+	ReportProfanity     ReportType = "profanity"
+	ReportIllegal       ReportType = "illegal"
+	ReportSpam          ReportType = "spam"
+	ReportImpersonation ReportType = "impersonation"
+	ReportOther         ReportType = "other"
+)
+
+func (rt ReportType) IsValid() bool {
+	switch rt {
+	case ReportNudity, ReportMalware, ReportProfanity, ReportIllegal,
+		ReportSpam, ReportImpersonation, ReportOther:
+		return true
+	}
+	return false
+}
+
+// Target is what is being reported: a user, or one of their events when Event is set.
+type Target struct {
+	PubKey nostr.PubKey
+	Event  *nostr.ID
+}
+
+// CreateReport returns an unsigned report of target. reason is optional extra information.
+func CreateReport(target Target, reportType ReportType, reason string) (nostr.Event, error) {
+	if !reportType.IsValid() {
+		return nostr.Event{}, fmt.Errorf("invalid report type '%s'", reportType)
+	}
+
+	evt := nostr.Event{
+		Kind:      KindReporting,
+		CreatedAt: nostr.Now(),
+		Content:   reason,
+	}
+	if target.Event != nil {
+		evt.Tags = nostr.Tags{
+			{"e", target.Event.Hex(), string(reportType)},
+			{"p", target.PubKey.Hex()},
+		}
+	} else {
+		evt.Tags = nostr.Tags{{"p", target.PubKey.Hex(), string(reportType)}}
+	}
+	return evt, nil
+}
+
+// Report is a parsed kind-1984 event.
+type Report struct {
+	Reporter nostr.PubKey
+	Type     ReportType
+	Reason   string
+
+	PubKeys []nostr.PubKey
+	Events  []nostr.ID
+}
+
+// ParseReport extracts the reported users and events from evt. Unknown report types are
+// returned as ReportOther.
+func ParseReport(evt *nostr.Event) (Report, error) {
+	if evt.Kind != KindReporting {
+		return Report{}, fmt.Errorf("expected kind %d, got %d", KindReporting, evt.Kind)
+	}
+
+	report := Report{Reporter: evt.PubKey, Reason: evt.Content}
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 || (tag[0] != "e" && tag[0] != "p") {
+			continue
+		}
+		if len(tag) > 2 && report.Type == "" {
+			report.Type = ReportType(tag[2])
+		}
+		if tag[0] == "e" {
+			if id, err := nostr.IDFromHex(tag[1]); err == nil {
+				report.Events = append(report.Events, id)
+			}
+		} else {
+			if pk, err := nostr.PubKeyFromHex(tag[1]); err == nil {
+				report.PubKeys = append(report.PubKeys, pk)
+			}
+		}
+	}
+
+	if len(report.PubKeys) == 0 && len(report.Events) == 0 {
+		return report, fmt.Errorf("report has no targets")
+	}
+	if !report.Type.IsValid() {
+		report.Type = ReportOther
+	}
+	return report, nil
+}
+
+// Validate checks that evt reports at least one valid pubkey, it is registered as the
+// nostr.ValidateKind validator for reports when this package is imported. Unknown report types
+// are fine, as ParseReport takes them as ReportOther.
+func Validate(evt *nostr.Event) error {
+	p := evt.Tags.GetFirst([]string{"p", ""})
+	if p == nil {
+		return fmt.Errorf("must have a 'p' tag")
+	}
+	if !nostr.IsValidPublicKeyHex(p.Value()) {
+		return fmt.Errorf("invalid pubkey in %v", *p)
+	}
+	return nil
+}
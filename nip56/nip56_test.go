@@ -0,0 +1,52 @@
+package nip56
+
+import (
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestReportRoundTrip(t *testing.T) {
+	pk := nostr.MustPubKeyFromHex("3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d")
+	id := nostr.MustIDFromHex("0000000000000000000000000000000000000000000000000000000000000001")
+
+	evt, err := CreateReport(Target{PubKey: pk, Event: &id}, ReportSpam, "buy my coin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := ParseReport(&evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Type != ReportSpam || report.Reason != "buy my coin" ||
+		len(report.Events) != 1 || report.Events[0] != id ||
+		len(report.PubKeys) != 1 || report.PubKeys[0] != pk {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	evt, _ = CreateReport(Target{PubKey: pk}, ReportImpersonation, "")
+	if report, _ := ParseReport(&evt); report.Type != ReportImpersonation || len(report.Events) != 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	if _, err := CreateReport(Target{PubKey: pk}, "annoying", ""); err == nil {
+		t.Fatal("invalid report type accepted")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	pk := "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"
+	for i, test := range []struct {
+		evt   nostr.Event
+		valid bool
+	}{
+		{nostr.Event{Kind: 1984, Tags: nostr.Tags{{"p", pk, "spam"}}}, true},
+		{nostr.Event{Kind: 1984, Tags: nostr.Tags{{"p", pk, "ugly"}}}, true},
+		{nostr.Event{Kind: 1984, Tags: nostr.Tags{{"p", "fiatjaf", "spam"}}}, false},
+		{nostr.Event{Kind: 1984, Tags: nostr.Tags{{"e", pk, "spam"}}}, false},
+	} {
+		if err := nostr.ValidateKind(&test.evt); (err == nil) != test.valid {
+			t.Errorf("%d: expected valid=%v, got %v", i, test.valid, err)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Package nip57 implements helpers for lightning zaps: kind-9734 zap requests and the
+// kind-9735 receipts that lightning providers publish once they are paid.
+// See https://github.com/nostr-protocol/nips/blob/master/57.md for details.
+package nip57
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func init() {
+	nostr.RegisterKindDecoder(nostr.KindZap, func(evt *nostr.Event) (any, error) { return ParseZapReceipt(evt) })
+}
+
+// ZapReceipt is a kind-9735 zap receipt, as returned by nostr.DecodeEvent once this package is
+// imported.
+type ZapReceipt struct {
+	Recipient nostr.PubKey
+	Sender    *nostr.PubKey // from the "P" tag, if the provider added it
+	Event     string        // id of the event that was zapped, if any
+	Amount    int64         // in millisatoshis
+	Request   nostr.Event
+}
+
+// ParseZapReceipt reads the recipient, amount and zap request of a zap receipt. It doesn't check
+// the receipt was published by the recipient's lightning provider.
+func ParseZapReceipt(receipt *nostr.Event) (*ZapReceipt, error) {
+	req, err := GetZapRequest(receipt)
+	if err != nil {
+		return nil, err
+	}
+	amount, err := GetAmountFromZap(receipt)
+	if err != nil {
+		return nil, err
+	}
+
+	zap := &ZapReceipt{Amount: amount, Request: req}
+	p := receipt.Tags.GetFirst([]string{"p", ""})
+	if p == nil {
+		return nil, fmt.Errorf("zap receipt has no 'p' tag")
+	}
+	if zap.Recipient, err = nostr.PubKeyFromHex(p.Value()); err != nil {
+		return nil, fmt.Errorf("invalid recipient: %w", err)
+	}
+	if sender := receipt.Tags.GetFirst([]string{"P", ""}); sender != nil {
+		if pk, err := nostr.PubKeyFromHex(sender.Value()); err == nil {
+			zap.Sender = &pk
+		}
+	}
+	if e := receipt.Tags.GetFirst([]string{"e", ""}); e != nil {
+		zap.Event = e.Value()
+	}
+	return zap, nil
+}
+
+// GetZapRequest returns the zap request embedded in the "description" tag of a zap receipt.
+func GetZapRequest(receipt *nostr.Event) (nostr.Event, error) {
+	var req nostr.Event
+	if receipt.Kind != nostr.KindZap {
+		return req, fmt.Errorf("expected kind %d, got %d", nostr.KindZap, receipt.Kind)
+	}
+	tag := receipt.Tags.GetFirst([]string{"description", ""})
+	if tag == nil {
+		return req, fmt.Errorf("zap receipt has no 'description' tag")
+	}
+	if err := req.UnmarshalJSON([]byte(tag.Value())); err != nil {
+		return req, fmt.Errorf("invalid zap request: %w", err)
+	}
+	if req.Kind != nostr.KindZapRequest {
+		return req, fmt.Errorf("description is kind %d, not a zap request", req.Kind)
+	}
+	return req, nil
+}
+
+// GetAmountFromZap returns how much was paid, in millisatoshis, according to the invoice in the
+// "bolt11" tag of a zap receipt.
+func GetAmountFromZap(receipt *nostr.Event) (int64, error) {
+	tag := receipt.Tags.GetFirst([]string{"bolt11", ""})
+	if tag == nil {
+		return 0, fmt.Errorf("zap receipt has no 'bolt11' tag")
+	}
+	return InvoiceAmount(tag.Value())
+}
+
+// InvoiceAmount reads the amount, in millisatoshis, from the human-readable part of a bolt11
+// invoice, like "lnbc2500u1...". It doesn't check the invoice signature.
+func InvoiceAmount(invoice string) (int64, error) {
+	invoice = strings.ToLower(invoice)
+	sep := strings.LastIndexByte(invoice, '1')
+	if !strings.HasPrefix(invoice, "ln") || sep == -1 {
+		return 0, fmt.Errorf("invalid invoice")
+	}
+	hrp := invoice[2:sep]
+
+	// skip the network, "bc", "tb", "bcrt" and so on, up to the first digit
+	start := strings.IndexAny(hrp, "0123456789")
+	if start == -1 {
+		return 0, fmt.Errorf("invoice has no amount")
+	}
+	amount := hrp[start:]
+
+	// amounts are in bitcoin, with an optional multiplier
+	msatsPerUnit := int64(100_000_000_000)
+	divisor := int64(1)
+	switch amount[len(amount)-1] {
+	case 'm':
+		divisor = 1_000
+	case 'u':
+		divisor = 1_000_000
+	case 'n':
+		divisor = 1_000_000_000
+	case 'p':
+		divisor = 1_000_000_000_000
+	}
+	if divisor != 1 {
+		amount = amount[:len(amount)-1]
+	}
+
+	n, err := strconv.ParseInt(amount, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid invoice amount '%s'", amount)
+	}
+	if divisor == 1_000_000_000_000 {
+		// picobitcoin is a tenth of a millisatoshi
+		return n / 10, nil
+	}
+	return n * (msatsPerUnit / divisor), nil
+}
@@ -0,0 +1,191 @@
+package nip57
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/bech32"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/keyer"
+)
+
+func TestInvoiceAmount(t *testing.T) {
+	for invoice, expected := range map[string]int64{
+		"lnbc2500u1pvjluezpp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypq": 250_000_000,
+		"lnbc20m1pvjluezhp58yjmdan79s6qkdhdqhqz3sx0s2jz2q":                         2_000_000_000,
+		"lntb10n1pvjluez": 1_000,
+		"lnbcrt5000p1abc": 500,
+		"LNBC1U1XYZ":      100_000,
+	} {
+		amount, err := InvoiceAmount(invoice)
+		if err != nil {
+			t.Fatalf("%s: %s", invoice, err)
+		}
+		if amount != expected {
+			t.Fatalf("%s: expected %d msats, got %d", invoice, expected, amount)
+		}
+	}
+
+	for _, invoice := range []string{"lnbc1pvjluez", "nope", "lnbcxu1abc"} {
+		if _, err := InvoiceAmount(invoice); err == nil {
+			t.Fatalf("%s: should have failed", invoice)
+		}
+	}
+}
+
+func TestParseZapReceipt(t *testing.T) {
+	sk := nostr.GeneratePrivateKey()
+	pk, _ := nostr.GetPublicKey(sk)
+	request := nostr.Event{Kind: nostr.KindZapRequest, PubKey: nostr.MustPubKeyFromHex(pk), Tags: nostr.Tags{{"p", pk}}}
+	request.Sign(sk)
+	description, _ := request.MarshalJSON()
+
+	receipt := &nostr.Event{Kind: nostr.KindZap, Tags: nostr.Tags{
+		{"p", pk},
+		{"P", pk},
+		{"e", "abcd"},
+		{"bolt11", "lnbc2500u1pvjluezpp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypq"},
+		{"description", string(description)},
+	}}
+	value, err := nostr.DecodeEvent(receipt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zap, ok := value.(*ZapReceipt)
+	if !ok {
+		t.Fatalf("decoded to %T", value)
+	}
+	if zap.Amount != 250_000_000 || zap.Recipient.Hex() != pk || zap.Sender == nil || zap.Event != "abcd" || zap.Request.ID != request.ID {
+		t.Fatalf("unexpected zap receipt %+v", zap)
+	}
+}
+
+func TestValidateZapReceipt(t *testing.T) {
+	providerSK := nostr.GeneratePrivateKey()
+	providerPK, _ := nostr.GetPublicKey(providerSK)
+	fetches := 0
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		if r.URL.Path != "/.well-known/lnurlp/bob" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"callback":"https://x/cb","allowsNostr":true,"nostrPubkey":"%s"}`, providerPK)
+	}))
+	defer ts.Close()
+	defer func(client *http.Client) { HTTPClient = client }(HTTPClient)
+	HTTPClient = ts.Client()
+	lnurl := "bob@" + strings.TrimPrefix(ts.URL, "https://")
+
+	senderSK := nostr.GeneratePrivateKey()
+	senderPK, _ := nostr.GetPublicKey(senderSK)
+	bobPK, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+	receipt := func(signer, amount string) *nostr.Event {
+		request := nostr.Event{Kind: nostr.KindZapRequest, PubKey: nostr.MustPubKeyFromHex(senderPK), CreatedAt: nostr.Now(),
+			Tags: nostr.Tags{{"p", bobPK}, {"amount", amount}}}
+		request.Sign(senderSK)
+		description, _ := request.MarshalJSON()
+		pk, _ := nostr.GetPublicKey(signer)
+		evt := &nostr.Event{Kind: nostr.KindZap, PubKey: nostr.MustPubKeyFromHex(pk), CreatedAt: nostr.Now(), Tags: nostr.Tags{
+			{"p", bobPK},
+			{"bolt11", "lnbc10u1pvjluez"},
+			{"description", string(description)},
+		}}
+		evt.Sign(signer)
+		return evt
+	}
+
+	ctx := context.Background()
+	v := &Validator{}
+	if res := v.Validate(ctx, receipt(providerSK, "1000000"), lnurl); !res.Valid() || res.Receipt.Amount != 1_000_000 {
+		t.Fatalf("expected valid, got %s: %s", res.Verdict, res.Reason)
+	}
+	if res := v.Validate(ctx, receipt(nostr.GeneratePrivateKey(), "1000000"), lnurl); res.Verdict != WrongProvider {
+		t.Errorf("expected wrong provider, got %s", res.Verdict)
+	}
+	if res := v.Validate(ctx, receipt(providerSK, "5000"), lnurl); res.Verdict != AmountMismatch {
+		t.Errorf("expected amount mismatch, got %s", res.Verdict)
+	}
+	tampered := receipt(providerSK, "1000000")
+	tampered.Tags[0][1] = senderPK
+	if res := v.Validate(ctx, tampered, lnurl); res.Verdict != InvalidReceipt {
+		t.Errorf("expected invalid receipt, got %s", res.Verdict)
+	}
+	if fetches != 1 {
+		t.Errorf("provider fetched %d times", fetches)
+	}
+	if res := v.Validate(ctx, receipt(providerSK, "1000000"), "alice@"+strings.TrimPrefix(ts.URL, "https://")); res.Verdict != UnknownProvider {
+		t.Errorf("expected unknown provider, got %s", res.Verdict)
+	}
+
+	bits5, _ := bech32.ConvertBits([]byte("https://example.com/lnurlp/bob"), 8, 5, true)
+	lud06, _ := bech32.Encode("lnurl", bits5)
+	if endpoint, err := LNURLPayEndpoint(strings.ToUpper(lud06)); err != nil || endpoint != "https://example.com/lnurlp/bob" {
+		t.Errorf("unexpected endpoint %s, %v", endpoint, err)
+	}
+}
+
+func TestZapInvoice(t *testing.T) {
+	var host string
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/lnurlp/bob":
+			fmt.Fprintf(w, `{"callback":"https://%s/cb?k=v","minSendable":1000,"maxSendable":100000000,"allowsNostr":true,"nostrPubkey":"%s"}`,
+				host, strings.Repeat("ab", 32))
+		case "/cb":
+			var req nostr.Event
+			if err := req.UnmarshalJSON([]byte(r.URL.Query().Get("nostr"))); err != nil || r.URL.Query().Get("k") != "v" {
+				fmt.Fprint(w, `{"status":"ERROR","reason":"bad request"}`)
+				return
+			}
+			if ok, _ := req.CheckSignature(); !ok || r.URL.Query().Get("lnurl") == "" {
+				fmt.Fprint(w, `{"status":"ERROR","reason":"bad zap request"}`)
+				return
+			}
+			// always for 1000 sats, so other amounts get the wrong invoice
+			fmt.Fprint(w, `{"pr":"lnbc10u1pvjluez"}`)
+		}
+	}))
+	defer ts.Close()
+	host = strings.TrimPrefix(ts.URL, "https://")
+	defer func(client *http.Client) { HTTPClient = client }(HTTPClient)
+	HTTPClient = ts.Client()
+
+	ctx := context.Background()
+	signer, _ := keyer.NewPlainKeySigner(nostr.GeneratePrivateKey())
+	bob := nostr.MustPubKeyFromHex(strings.Repeat("cd", 32))
+	article := &nostr.Event{Kind: 30023, PubKey: bob, Tags: nostr.Tags{{"d", "post"}}}
+	zap := Zap{
+		Recipient: bob,
+		LNURL:     ProfileLNURL(&nostr.ProfileMetadata{LUD16: "bob@" + host}),
+		Amount:    1_000_000,
+		Event:     article,
+		Comment:   "great post",
+		Relays:    []string{"wss://relay.example.com"},
+	}
+
+	req, err := CreateZapRequest(ctx, signer, zap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := req.Tags.GetFirst([]string{"a", ""}); a == nil || a.Value() != "30023:"+bob.Hex()+":post" || req.Content != "great post" {
+		t.Fatalf("unexpected zap request %v", req)
+	}
+
+	invoice, err := Invoice(ctx, signer, zap)
+	if err != nil || invoice != "lnbc10u1pvjluez" {
+		t.Fatalf("unexpected invoice %s, %v", invoice, err)
+	}
+	zap.Amount = 2_000_000
+	if _, err := Invoice(ctx, signer, zap); err == nil {
+		t.Errorf("invoice for the wrong amount accepted")
+	}
+	zap.Amount = 1
+	if _, err := Invoice(ctx, signer, zap); err == nil {
+		t.Errorf("amount below minSendable accepted")
+	}
+}
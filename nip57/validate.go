@@ -0,0 +1,200 @@
+package nip57
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil/bech32"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// HTTPClient is used to fetch LNURL pay endpoints.
+var HTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// Verdict says if a zap receipt can be trusted, or why not.
+type Verdict int
+
+const (
+	Valid           Verdict = iota
+	InvalidReceipt          // bad signature or missing tags
+	UnknownProvider         // the recipient's LNURL endpoint couldn't be fetched or doesn't support zaps
+	WrongProvider           // signed by someone other than the recipient's lightning provider
+	InvalidRequest          // the zap request in the description is invalid or doesn't match the receipt
+	AmountMismatch          // the invoice isn't for the amount the zap request asked for
+)
+
+func (v Verdict) String() string {
+	switch v {
+	case Valid:
+		return "valid"
+	case InvalidReceipt:
+		return "invalid receipt"
+	case UnknownProvider:
+		return "unknown provider"
+	case WrongProvider:
+		return "wrong provider"
+	case InvalidRequest:
+		return "invalid request"
+	case AmountMismatch:
+		return "amount mismatch"
+	}
+	return fmt.Sprintf("verdict %d", int(v))
+}
+
+// Validation is the result of validating a zap receipt. Receipt is set whenever it could be
+// parsed, so it can be shown as unverified if the caller wants.
+type Validation struct {
+	Verdict Verdict
+	Reason  string
+	Receipt *ZapReceipt
+}
+
+func (v Validation) Valid() bool { return v.Verdict == Valid }
+
+// PayParams is the part of an LNURL pay endpoint response that matters for zaps.
+type PayParams struct {
+	Callback    string `json:"callback"`
+	MinSendable int64  `json:"minSendable"` // msats
+	MaxSendable int64  `json:"maxSendable"` // msats
+	AllowsNostr bool   `json:"allowsNostr"`
+	NostrPubkey string `json:"nostrPubkey"`
+}
+
+// LNURLPayEndpoint returns the URL of the LNURL pay endpoint of lnurl, which is either a
+// lightning address like "me@example.com" (lud16) or a bech32 "lnurl1..." (lud06).
+func LNURLPayEndpoint(lnurl string) (string, error) {
+	lnurl = strings.TrimPrefix(strings.TrimSpace(lnurl), "lightning:")
+	if name, domain, ok := strings.Cut(lnurl, "@"); ok {
+		if name == "" || domain == "" || strings.ContainsAny(domain, "/?#") {
+			return "", fmt.Errorf("invalid lightning address '%s'", lnurl)
+		}
+		scheme := "https"
+		if strings.HasSuffix(domain, ".onion") {
+			scheme = "http"
+		}
+		return scheme + "://" + domain + "/.well-known/lnurlp/" + name, nil
+	}
+
+	prefix, bits5, err := bech32.DecodeNoLimit(strings.ToLower(lnurl))
+	if err != nil || prefix != "lnurl" {
+		return "", fmt.Errorf("invalid lnurl '%s'", lnurl)
+	}
+	data, err := bech32.ConvertBits(bits5, 5, 8, false)
+	if err != nil {
+		return "", fmt.Errorf("invalid lnurl '%s': %w", lnurl, err)
+	}
+	return string(data), nil
+}
+
+// FetchPayParams gets the LNURL pay parameters for lnurl, see LNURLPayEndpoint.
+func FetchPayParams(ctx context.Context, lnurl string) (PayParams, error) {
+	var params PayParams
+	endpoint, err := LNURLPayEndpoint(lnurl)
+	if err != nil {
+		return params, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return params, err
+	}
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		return params, fmt.Errorf("failed to fetch %s: %w", endpoint, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return params, fmt.Errorf("failed to fetch %s: %s", endpoint, resp.Status)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&params); err != nil {
+		return params, fmt.Errorf("invalid response from %s: %w", endpoint, err)
+	}
+	return params, nil
+}
+
+// Validator checks zap receipts, remembering the provider key of each LNURL so it is fetched
+// only once. The zero value is ready to use.
+type Validator struct {
+	mu        sync.Mutex
+	providers map[string]nostr.PubKey
+}
+
+// Validate checks that receipt was signed by the provider behind lnurl, the lud16 or lud06 of
+// the recipient's profile, and that it carries a valid zap request to the same recipient with
+// the amount the invoice is for. Receipts that aren't valid shouldn't be displayed as zaps.
+func (v *Validator) Validate(ctx context.Context, receipt *nostr.Event, lnurl string) Validation {
+	if ok, err := receipt.CheckSignature(); !ok {
+		return Validation{Verdict: InvalidReceipt, Reason: fmt.Sprintf("invalid signature: %v", err)}
+	}
+	zap, err := ParseZapReceipt(receipt)
+	if err != nil {
+		return Validation{Verdict: InvalidReceipt, Reason: err.Error()}
+	}
+	result := Validation{Receipt: zap}
+	fail := func(verdict Verdict, reason string, args ...any) Validation {
+		result.Verdict = verdict
+		result.Reason = fmt.Sprintf(reason, args...)
+		return result
+	}
+
+	provider, err := v.provider(ctx, lnurl)
+	if err != nil {
+		return fail(UnknownProvider, "%s", err)
+	}
+	if receipt.PubKey != provider {
+		return fail(WrongProvider, "signed by %s instead of %s", receipt.PubKey.Hex(), provider.Hex())
+	}
+
+	req := zap.Request
+	if ok, _ := req.CheckSignature(); !ok {
+		return fail(InvalidRequest, "zap request has an invalid signature")
+	}
+	ps := req.Tags.GetAll([]string{"p", ""})
+	if len(ps) != 1 || ps[0].Value() != zap.Recipient.Hex() {
+		return fail(InvalidRequest, "zap request isn't for %s", zap.Recipient.Hex())
+	}
+	if e := req.Tags.GetFirst([]string{"e", ""}); e != nil && e.Value() != zap.Event {
+		return fail(InvalidRequest, "zap request is for event %s, receipt for '%s'", e.Value(), zap.Event)
+	}
+	if zap.Sender != nil && *zap.Sender != req.PubKey {
+		return fail(InvalidRequest, "receipt sender doesn't match the zap request author")
+	}
+	if amount := req.Tags.GetFirst([]string{"amount", ""}); amount != nil && amount.Value() != fmt.Sprint(zap.Amount) {
+		return fail(AmountMismatch, "zap request asked for %s msats, invoice is for %d", amount.Value(), zap.Amount)
+	}
+	return result
+}
+
+// provider returns the key the provider behind lnurl signs zap receipts with.
+func (v *Validator) provider(ctx context.Context, lnurl string) (nostr.PubKey, error) {
+	v.mu.Lock()
+	pk, ok := v.providers[lnurl]
+	v.mu.Unlock()
+	if ok {
+		return pk, nil
+	}
+
+	params, err := FetchPayParams(ctx, lnurl)
+	if err != nil {
+		return pk, err
+	}
+	if !params.AllowsNostr {
+		return pk, fmt.Errorf("%s doesn't support zaps", lnurl)
+	}
+	if pk, err = nostr.PubKeyFromHex(params.NostrPubkey); err != nil {
+		return pk, fmt.Errorf("invalid nostrPubkey from %s: %w", lnurl, err)
+	}
+
+	v.mu.Lock()
+	if v.providers == nil {
+		v.providers = make(map[string]nostr.PubKey)
+	}
+	v.providers[lnurl] = pk
+	v.mu.Unlock()
+	return pk, nil
+}
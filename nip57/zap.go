@@ -0,0 +1,160 @@
+package nip57
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/btcsuite/btcd/btcutil/bech32"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Zap describes a zap to be sent.
+type Zap struct {
+	Recipient nostr.PubKey
+	LNURL     string       // lud16 or lud06 of the recipient, see ProfileLNURL
+	Amount    int64        // in millisatoshis
+	Event     *nostr.Event // the event being zapped, if any
+	Comment   string
+	Relays    []string // where the provider should publish the receipt
+}
+
+// ProfileLNURL returns the LNURL zaps to the owner of profile should be sent to, preferring
+// the lightning address.
+func ProfileLNURL(profile *nostr.ProfileMetadata) string {
+	if profile.LUD16 != "" {
+		return profile.LUD16
+	}
+	return profile.LUD06
+}
+
+// CreateZapRequest returns the kind-9734 zap request for zap, signed with signer.
+func CreateZapRequest(ctx context.Context, signer nostr.Signer, zap Zap) (nostr.Event, error) {
+	pk, err := signer.GetPublicKey(ctx)
+	if err != nil {
+		return nostr.Event{}, fmt.Errorf("failed to get our public key: %w", err)
+	}
+	if len(zap.Relays) == 0 {
+		return nostr.Event{}, fmt.Errorf("zap request needs relays for the receipt")
+	}
+
+	relays := nostr.Tag{"relays"}
+	relays = append(relays, zap.Relays...)
+	tags := nostr.Tags{relays, {"amount", strconv.FormatInt(zap.Amount, 10)}, {"p", zap.Recipient.Hex()}}
+	if lnurl, err := encodeLNURL(zap.LNURL); err == nil {
+		tags = append(tags, nostr.Tag{"lnurl", lnurl})
+	}
+	if zap.Event != nil {
+		tags = append(tags, nostr.Tag{"e", zap.Event.ID.Hex()})
+		if key, ok := zap.Event.ReplaceableKey(); ok && nostr.IsAddressableKind(key.Kind) {
+			tags = append(tags, nostr.Tag{"a", fmt.Sprintf("%d:%s:%s", key.Kind, key.PubKey.Hex(), key.D)})
+		}
+		tags = append(tags, nostr.Tag{"k", strconv.Itoa(zap.Event.Kind)})
+	}
+
+	req := nostr.Event{
+		Kind:      nostr.KindZapRequest,
+		PubKey:    pk,
+		CreatedAt: nostr.Now(),
+		Tags:      tags,
+		Content:   zap.Comment,
+	}
+	if err := signer.SignEvent(ctx, &req); err != nil {
+		return nostr.Event{}, fmt.Errorf("failed to sign zap request: %w", err)
+	}
+	return req, nil
+}
+
+// RequestInvoice asks the LNURL pay endpoint of lnurl for an invoice paying the zap request
+// req, and checks it is for the amount the request asks for.
+func RequestInvoice(ctx context.Context, lnurl string, req nostr.Event) (string, error) {
+	params, err := FetchPayParams(ctx, lnurl)
+	if err != nil {
+		return "", err
+	}
+	if !params.AllowsNostr || params.NostrPubkey == "" {
+		return "", fmt.Errorf("%s doesn't support zaps", lnurl)
+	}
+	tag := req.Tags.GetFirst([]string{"amount", ""})
+	if tag == nil {
+		return "", fmt.Errorf("zap request has no 'amount' tag")
+	}
+	amount, err := strconv.ParseInt(tag.Value(), 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid zap request amount '%s'", tag.Value())
+	}
+	if amount < params.MinSendable || (params.MaxSendable > 0 && amount > params.MaxSendable) {
+		return "", fmt.Errorf("%d msats is outside of the %d-%d range accepted by %s", amount, params.MinSendable, params.MaxSendable, lnurl)
+	}
+
+	callback, err := url.Parse(params.Callback)
+	if err != nil || callback.Host == "" {
+		return "", fmt.Errorf("invalid callback '%s'", params.Callback)
+	}
+	j, err := req.MarshalJSON()
+	if err != nil {
+		return "", err
+	}
+	query := callback.Query()
+	query.Set("amount", tag.Value())
+	query.Set("nostr", string(j))
+	if lnurl := req.Tags.GetFirst([]string{"lnurl", ""}); lnurl != nil {
+		query.Set("lnurl", lnurl.Value())
+	}
+	callback.RawQuery = query.Encode()
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, callback.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := HTTPClient.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to call %s: %w", callback.Host, err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		PR     string `json:"pr"`
+		Status string `json:"status"`
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&result); err != nil {
+		return "", fmt.Errorf("invalid response from %s: %w", callback.Host, err)
+	}
+	if result.Status == "ERROR" {
+		return "", fmt.Errorf("%s refused the zap: %s", callback.Host, result.Reason)
+	}
+	if invoiceAmount, err := InvoiceAmount(result.PR); err != nil {
+		return "", fmt.Errorf("invalid invoice from %s: %w", callback.Host, err)
+	} else if invoiceAmount != amount {
+		return "", fmt.Errorf("invoice from %s is for %d msats instead of %d", callback.Host, invoiceAmount, amount)
+	}
+	return result.PR, nil
+}
+
+// Invoice creates the zap request for zap and gets the invoice to pay it from the recipient's
+// provider. Once it is paid the provider publishes the receipt to zap.Relays.
+func Invoice(ctx context.Context, signer nostr.Signer, zap Zap) (string, error) {
+	req, err := CreateZapRequest(ctx, signer, zap)
+	if err != nil {
+		return "", err
+	}
+	return RequestInvoice(ctx, zap.LNURL, req)
+}
+
+// encodeLNURL returns lnurl as bech32, the form it takes in "lnurl" tags.
+func encodeLNURL(lnurl string) (string, error) {
+	endpoint, err := LNURLPayEndpoint(lnurl)
+	if err != nil {
+		return "", err
+	}
+	bits5, err := bech32.ConvertBits([]byte(endpoint), 8, 5, true)
+	if err != nil {
+		return "", err
+	}
+	return bech32.Encode("lnurl", bits5)
+}
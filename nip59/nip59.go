@@ -0,0 +1,161 @@
+// Package nip59 implements gift wrapping, which hides the content, author and recipients of
+// an event from everybody but the recipient.
+// See https://github.com/nostr-protocol/nips/blob/master/59.md for details.
+package nip59
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip44"
+)
+
+const (
+	KindSeal     = 13
+	KindGiftWrap = 1059
+)
+
+var (
+	randomnessMutex sync.Mutex
+	randomness      io.Reader = rand.Reader
+)
+
+// SetRandomness makes the one-time keys of gift wraps and the tweaks of their timestamps come
+// from r instead of crypto/rand, or from crypto/rand again if r is nil. It is only meant for
+// tests, with a seeded reader, along with nip44.SetRandomness for the encryption nonces, to get
+// the same gift wraps every run.
+func SetRandomness(r io.Reader) {
+	randomnessMutex.Lock()
+	defer randomnessMutex.Unlock()
+	if r == nil {
+		r = rand.Reader
+	}
+	randomness = r
+}
+
+// lockedRandomness reads from the reader given to SetRandomness, one read at a time.
+type lockedRandomness struct{}
+
+func (lockedRandomness) Read(b []byte) (int, error) {
+	randomnessMutex.Lock()
+	defer randomnessMutex.Unlock()
+	return randomness.Read(b)
+}
+
+// timestamps are randomized up to this far in the past so they can't be used to correlate events
+const maxTimestampTweak = 2 * 24 * time.Hour
+
+// GiftWrap seals rumor with signer, as its author, and wraps it with a random one-time key so it
+// can only be opened by recipient. The rumor is the unsigned event that will be delivered; its
+// PubKey and ID are set here. modify, if given, can change the gift wrap (e.g. add tags)
+// before it is signed.
+func GiftWrap(
+	ctx context.Context,
+	rumor nostr.Event,
+	signer nostr.Signer,
+	recipient nostr.PubKey,
+	modify func(*nostr.Event),
+) (nostr.Event, error) {
+	author, err := signer.GetPublicKey(ctx)
+	if err != nil {
+		return nostr.Event{}, fmt.Errorf("failed to get author public key: %w", err)
+	}
+	rumor.PubKey = author
+	rumor.ID = rumor.GetID()
+	rumor.Sig = ""
+
+	rumorJSON, _ := rumor.MarshalUnsignedJSON()
+	sealed, err := signer.NIP44Encrypt(ctx, string(rumorJSON), recipient)
+	if err != nil {
+		return nostr.Event{}, fmt.Errorf("failed to encrypt rumor: %w", err)
+	}
+	seal := nostr.Event{
+		Kind:      KindSeal,
+		Content:   sealed,
+		CreatedAt: randomNow(),
+		Tags:      nostr.Tags{},
+	}
+	if err := signer.SignEvent(ctx, &seal); err != nil {
+		return nostr.Event{}, fmt.Errorf("failed to sign seal: %w", err)
+	}
+
+	ephemeral := nostr.GeneratePrivateKeyFrom(lockedRandomness{})
+	ephemeralPub, _ := nostr.GetPublicKey(ephemeral)
+	ck, err := nip44.GenerateConversationKey(recipient.Hex(), ephemeral)
+	if err != nil {
+		return nostr.Event{}, err
+	}
+	sealJSON, _ := seal.MarshalJSON()
+	wrapped, err := nip44.Encrypt(string(sealJSON), ck)
+	if err != nil {
+		return nostr.Event{}, fmt.Errorf("failed to encrypt seal: %w", err)
+	}
+
+	gw := nostr.Event{
+		PubKey:    nostr.MustPubKeyFromHex(ephemeralPub),
+		Kind:      KindGiftWrap,
+		Content:   wrapped,
+		CreatedAt: randomNow(),
+		Tags:      nostr.Tags{{"p", recipient.Hex()}},
+	}
+	if modify != nil {
+		modify(&gw)
+	}
+	if err := gw.Sign(ephemeral); err != nil {
+		return nostr.Event{}, fmt.Errorf("failed to sign gift wrap: %w", err)
+	}
+	return gw, nil
+}
+
+// GiftUnwrap opens a gift wrap sent to the owner of signer and returns the rumor inside.
+// The rumor author is checked to be the same that signed the seal.
+func GiftUnwrap(ctx context.Context, gw nostr.Event, signer nostr.Signer) (nostr.Event, error) {
+	if gw.Kind != KindGiftWrap {
+		return nostr.Event{}, fmt.Errorf("event is kind %d, not a gift wrap", gw.Kind)
+	}
+
+	sealJSON, err := signer.NIP44Decrypt(ctx, gw.Content, gw.PubKey)
+	if err != nil {
+		return nostr.Event{}, fmt.Errorf("failed to decrypt gift wrap: %w", err)
+	}
+	var seal nostr.Event
+	if err := seal.UnmarshalJSON([]byte(sealJSON)); err != nil {
+		return nostr.Event{}, fmt.Errorf("invalid seal: %w", err)
+	}
+	if seal.Kind != KindSeal {
+		return nostr.Event{}, fmt.Errorf("sealed event is kind %d, not a seal", seal.Kind)
+	}
+	if ok, _ := seal.CheckSignature(); !ok || seal.GetID() != seal.ID {
+		return nostr.Event{}, fmt.Errorf("seal has an invalid signature")
+	}
+
+	rumorJSON, err := signer.NIP44Decrypt(ctx, seal.Content, seal.PubKey)
+	if err != nil {
+		return nostr.Event{}, fmt.Errorf("failed to decrypt seal: %w", err)
+	}
+	var rumor nostr.Event
+	if err := rumor.UnmarshalJSON([]byte(rumorJSON)); err != nil {
+		return nostr.Event{}, fmt.Errorf("invalid rumor: %w", err)
+	}
+	if rumor.PubKey != seal.PubKey {
+		return nostr.Event{}, fmt.Errorf("rumor author %s is not the seal author %s", rumor.PubKey, seal.PubKey)
+	}
+	if rumor.GetID() != rumor.ID {
+		return nostr.Event{}, fmt.Errorf("rumor has a wrong id")
+	}
+
+	return rumor, nil
+}
+
+func randomNow() time.Time {
+	var b [8]byte
+	io.ReadFull(lockedRandomness{}, b[:])
+	tweak := time.Duration(binary.BigEndian.Uint64(b[:]) % uint64(maxTimestampTweak))
+	return nostr.Now().Add(-tweak).Truncate(time.Second)
+}
@@ -0,0 +1,72 @@
+package nip59
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/keyer"
+	"github.com/nbd-wtf/go-nostr/nip44"
+)
+
+func TestGiftWrapRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	alice, _ := keyer.NewPlainKeySigner(nostr.GeneratePrivateKey())
+	bob, _ := keyer.NewPlainKeySigner(nostr.GeneratePrivateKey())
+	carol, _ := keyer.NewPlainKeySigner(nostr.GeneratePrivateKey())
+	alicePK, _ := alice.GetPublicKey(ctx)
+	bobPK, _ := bob.GetPublicKey(ctx)
+
+	rumor := nostr.Event{Kind: 1, CreatedAt: time.Now().Truncate(time.Second), Content: "are you going to the party tonight?"}
+	gw, err := GiftWrap(ctx, rumor, alice, bobPK, nil)
+	if err != nil {
+		t.Fatalf("failed to wrap: %s", err)
+	}
+	if gw.Kind != KindGiftWrap || gw.PubKey == alicePK || gw.Tags.GetFirst([]string{"p", bobPK.Hex()}) == nil {
+		t.Fatalf("unexpected gift wrap: %v", gw)
+	}
+	if ok, _ := gw.CheckSignature(); !ok {
+		t.Fatal("gift wrap signature is invalid")
+	}
+
+	opened, err := GiftUnwrap(ctx, gw, bob)
+	if err != nil {
+		t.Fatalf("failed to unwrap: %s", err)
+	}
+	if opened.PubKey != alicePK || opened.Content != rumor.Content || opened.Sig != "" || opened.ID != opened.GetID() {
+		t.Fatalf("unexpected rumor: %v", opened)
+	}
+
+	if _, err := GiftUnwrap(ctx, gw, carol); err == nil {
+		t.Fatal("somebody else could unwrap the gift")
+	}
+}
+
+func TestGiftWrapRandomness(t *testing.T) {
+	ctx := context.Background()
+	alice, _ := keyer.NewPlainKeySigner(nostr.GeneratePrivateKey())
+	bob, _ := keyer.NewPlainKeySigner(nostr.GeneratePrivateKey())
+	bobPK, _ := bob.GetPublicKey(ctx)
+
+	defer SetRandomness(nil)
+	defer nip44.SetRandomness(nil)
+	wrap := func() nostr.Event {
+		SetRandomness(rand.New(rand.NewSource(7)))
+		nip44.SetRandomness(rand.New(rand.NewSource(8)))
+		gw, err := GiftWrap(ctx, nostr.Event{Kind: 1, Content: "hi"}, alice, bobPK, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return gw
+	}
+
+	first, second := wrap(), wrap()
+	if first.PubKey != second.PubKey {
+		t.Fatal("one-time keys from the same seed are different")
+	}
+	if opened, err := GiftUnwrap(ctx, second, bob); err != nil || opened.Content != "hi" {
+		t.Fatalf("failed to unwrap: %v", err)
+	}
+}
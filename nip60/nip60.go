@@ -0,0 +1,371 @@
+// Package nip60 implements Cashu wallets kept on relays: a kind-17375 wallet event with the
+// wallet's key and mints, kind-7375 token events with the unspent proofs and kind-7376 events
+// with the spending history, all with their content encrypted to the owner with NIP-44.
+// See https://github.com/nostr-protocol/nips/blob/master/60.md for details.
+package nip60
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip61"
+)
+
+const (
+	KindWallet  = 17375
+	KindToken   = 7375
+	KindHistory = 7376
+)
+
+// encrypt encrypts v as JSON to the owner of signer.
+func encrypt(ctx context.Context, signer nostr.Signer, v any) (string, nostr.PubKey, error) {
+	pk, err := signer.GetPublicKey(ctx)
+	if err != nil {
+		return "", pk, fmt.Errorf("failed to get public key: %w", err)
+	}
+	j, err := json.Marshal(v)
+	if err != nil {
+		return "", pk, err
+	}
+	content, err := signer.NIP44Encrypt(ctx, string(j), pk)
+	if err != nil {
+		return "", pk, fmt.Errorf("failed to encrypt: %w", err)
+	}
+	return content, pk, nil
+}
+
+// decrypt decrypts the content of evt, which its author encrypted to themselves, into v.
+func decrypt(ctx context.Context, signer nostr.Signer, evt *nostr.Event, v any) error {
+	plaintext, err := signer.NIP44Decrypt(ctx, evt.Content, evt.PubKey)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt: %w", err)
+	}
+	if err := json.Unmarshal([]byte(plaintext), v); err != nil {
+		return fmt.Errorf("invalid content: %w", err)
+	}
+	return nil
+}
+
+// Wallet is the key that receives nutzaps, see package nip61, and the mints the wallet uses.
+type Wallet struct {
+	PrivKey string // hex, only for P2PK-locked proofs, not a Nostr key
+	Mints   []string
+}
+
+// ToEvent returns the unsigned wallet event, encrypted by signer to its owner.
+func (w Wallet) ToEvent(ctx context.Context, signer nostr.Signer) (nostr.Event, error) {
+	items := make([][]string, 0, len(w.Mints)+1)
+	if w.PrivKey != "" {
+		items = append(items, []string{"privkey", w.PrivKey})
+	}
+	for _, mint := range w.Mints {
+		items = append(items, []string{"mint", mint})
+	}
+	content, pk, err := encrypt(ctx, signer, items)
+	if err != nil {
+		return nostr.Event{}, err
+	}
+	return nostr.Event{
+		Kind:      KindWallet,
+		PubKey:    pk,
+		CreatedAt: nostr.Now(),
+		Content:   content,
+		Tags:      nostr.Tags{},
+	}, nil
+}
+
+// ParseWallet decrypts a wallet event with the signer of its owner.
+func ParseWallet(ctx context.Context, signer nostr.Signer, evt *nostr.Event) (Wallet, error) {
+	if evt.Kind != KindWallet {
+		return Wallet{}, fmt.Errorf("expected kind %d, got %d", KindWallet, evt.Kind)
+	}
+	var items [][]string
+	if err := decrypt(ctx, signer, evt, &items); err != nil {
+		return Wallet{}, err
+	}
+
+	var w Wallet
+	for _, item := range items {
+		if len(item) < 2 {
+			continue
+		}
+		switch item[0] {
+		case "privkey":
+			w.PrivKey = item[1]
+		case "mint":
+			w.Mints = append(w.Mints, item[1])
+		}
+	}
+	if len(w.Mints) == 0 {
+		return w, fmt.Errorf("wallet has no mints")
+	}
+	return w, nil
+}
+
+// Token is a set of unspent proofs from one mint. Tokens aren't changed: when some of their
+// proofs are spent a new token with the change replaces them, listing them in Deleted.
+type Token struct {
+	ID        nostr.ID     // set by ParseToken
+	PubKey    nostr.PubKey // set by ParseToken
+	CreatedAt time.Time    // set by ParseToken
+	Mint      string
+	Unit      string // "sat" if empty
+	Proofs    []nip61.Proof
+	Deleted   []nostr.ID // tokens this one replaces
+}
+
+type tokenContent struct {
+	Mint   string        `json:"mint"`
+	Unit   string        `json:"unit,omitempty"`
+	Proofs []nip61.Proof `json:"proofs"`
+	Del    []nostr.ID    `json:"del,omitempty"`
+}
+
+// ToEvent returns the unsigned token event, encrypted by signer to its owner.
+func (t Token) ToEvent(ctx context.Context, signer nostr.Signer) (nostr.Event, error) {
+	if t.Mint == "" {
+		return nostr.Event{}, fmt.Errorf("token has no mint")
+	}
+	content, pk, err := encrypt(ctx, signer, tokenContent{Mint: t.Mint, Unit: t.Unit, Proofs: t.Proofs, Del: t.Deleted})
+	if err != nil {
+		return nostr.Event{}, err
+	}
+	return nostr.Event{
+		Kind:      KindToken,
+		PubKey:    pk,
+		CreatedAt: nostr.Now(),
+		Content:   content,
+		Tags:      nostr.Tags{},
+	}, nil
+}
+
+// ParseToken decrypts a token event with the signer of its owner.
+func ParseToken(ctx context.Context, signer nostr.Signer, evt *nostr.Event) (Token, error) {
+	if evt.Kind != KindToken {
+		return Token{}, fmt.Errorf("expected kind %d, got %d", KindToken, evt.Kind)
+	}
+	var c tokenContent
+	if err := decrypt(ctx, signer, evt, &c); err != nil {
+		return Token{}, err
+	}
+	if c.Mint == "" {
+		return Token{}, fmt.Errorf("token has no mint")
+	}
+	if c.Unit == "" {
+		c.Unit = "sat"
+	}
+	return Token{
+		ID:        evt.ID,
+		PubKey:    evt.PubKey,
+		CreatedAt: evt.CreatedAt,
+		Mint:      c.Mint,
+		Unit:      c.Unit,
+		Proofs:    c.Proofs,
+		Deleted:   c.Del,
+	}, nil
+}
+
+// Amount is the sum of the token's proofs, in Unit.
+func (t Token) Amount() uint64 {
+	var total uint64
+	for _, proof := range t.Proofs {
+		total += proof.Amount
+	}
+	return total
+}
+
+// Unspent returns the tokens that weren't replaced by other tokens or deleted by their owner
+// with one of the kind-5 deletions, once each, as they are usually fetched from many relays.
+func Unspent(tokens []Token, deletions []*nostr.Event) []Token {
+	gone := make(map[nostr.ID]nostr.PubKey)
+	for _, t := range tokens {
+		for _, id := range t.Deleted {
+			gone[id] = t.PubKey
+		}
+	}
+	for _, deletion := range deletions {
+		if deletion.Kind != nostr.KindDeletion {
+			continue
+		}
+		for _, tag := range deletion.Tags.GetAll([]string{"e", ""}) {
+			if id, err := nostr.IDFromHex(tag.Value()); err == nil {
+				gone[id] = deletion.PubKey
+			}
+		}
+	}
+
+	unspent := make([]Token, 0, len(tokens))
+	seen := make(map[nostr.ID]bool, len(tokens))
+	for _, t := range tokens {
+		if seen[t.ID] {
+			continue
+		}
+		seen[t.ID] = true
+		// only the owner can delete their tokens
+		if by, ok := gone[t.ID]; ok && by == t.PubKey {
+			continue
+		}
+		unspent = append(unspent, t)
+	}
+	return unspent
+}
+
+// Balance adds up the unspent tokens, see Unspent, by unit.
+func Balance(tokens []Token, deletions []*nostr.Event) map[string]uint64 {
+	balance := make(map[string]uint64)
+	for _, t := range Unspent(tokens, deletions) {
+		unit := t.Unit
+		if unit == "" {
+			unit = "sat"
+		}
+		balance[unit] += t.Amount()
+	}
+	return balance
+}
+
+// DeleteTokens returns the unsigned kind-5 event deleting the given token events, once their
+// proofs are spent.
+func DeleteTokens(ids ...nostr.ID) nostr.Event {
+	evt := nostr.Event{
+		Kind:      nostr.KindDeletion,
+		CreatedAt: nostr.Now(),
+		Tags:      make(nostr.Tags, 0, len(ids)+1),
+	}
+	for _, id := range ids {
+		evt.Tags = append(evt.Tags, nostr.Tag{"e", id.Hex()})
+	}
+	evt.Tags = append(evt.Tags, nostr.Tag{"k", strconv.Itoa(KindToken)})
+	return evt
+}
+
+type Direction string
+
+const (
+	In  Direction = "in"
+	Out Direction = "out"
+)
+
+// Markers of the token events a history entry refers to.
+const (
+	Created   = "created"
+	Destroyed = "destroyed"
+	Redeemed  = "redeemed" // nutzaps redeemed, kept in public tags so others can see it
+)
+
+// Ref is an event a history entry refers to.
+type Ref struct {
+	ID     nostr.ID
+	Relay  string
+	Marker string
+}
+
+// History is an entry of the spending history of a wallet.
+type History struct {
+	ID        nostr.ID  // set by ParseHistory
+	CreatedAt time.Time // set by ParseHistory
+	Direction Direction
+	Amount    uint64
+	Unit      string // "sat" if empty
+	Refs      []Ref
+}
+
+func (r Ref) tag() nostr.Tag {
+	return nostr.Tag{"e", r.ID.Hex(), r.Relay, r.Marker}
+}
+
+// ToEvent returns the unsigned history event, encrypted by signer to its owner except for the
+// references to redeemed nutzaps.
+func (h History) ToEvent(ctx context.Context, signer nostr.Signer) (nostr.Event, error) {
+	if h.Direction != In && h.Direction != Out {
+		return nostr.Event{}, fmt.Errorf("invalid direction '%s'", h.Direction)
+	}
+	items := [][]string{
+		{"direction", string(h.Direction)},
+		{"amount", strconv.FormatUint(h.Amount, 10)},
+	}
+	if h.Unit != "" && h.Unit != "sat" {
+		items = append(items, []string{"unit", h.Unit})
+	}
+	public := nostr.Tags{}
+	for _, ref := range h.Refs {
+		if ref.Marker == Redeemed {
+			public = append(public, ref.tag())
+		} else {
+			items = append(items, ref.tag())
+		}
+	}
+
+	content, pk, err := encrypt(ctx, signer, items)
+	if err != nil {
+		return nostr.Event{}, err
+	}
+	return nostr.Event{
+		Kind:      KindHistory,
+		PubKey:    pk,
+		CreatedAt: nostr.Now(),
+		Content:   content,
+		Tags:      public,
+	}, nil
+}
+
+// ParseHistory decrypts a history event with the signer of its owner.
+func ParseHistory(ctx context.Context, signer nostr.Signer, evt *nostr.Event) (History, error) {
+	if evt.Kind != KindHistory {
+		return History{}, fmt.Errorf("expected kind %d, got %d", KindHistory, evt.Kind)
+	}
+	var items [][]string
+	if err := decrypt(ctx, signer, evt, &items); err != nil {
+		return History{}, err
+	}
+
+	h := History{ID: evt.ID, CreatedAt: evt.CreatedAt, Unit: "sat"}
+	for _, tag := range evt.Tags {
+		items = append(items, tag)
+	}
+	for _, item := range items {
+		if len(item) < 2 {
+			continue
+		}
+		switch item[0] {
+		case "direction":
+			h.Direction = Direction(item[1])
+		case "amount":
+			amount, err := strconv.ParseUint(item[1], 10, 64)
+			if err != nil {
+				return h, fmt.Errorf("invalid amount '%s'", item[1])
+			}
+			h.Amount = amount
+		case "unit":
+			h.Unit = item[1]
+		case "e":
+			id, err := nostr.IDFromHex(item[1])
+			if err != nil {
+				continue
+			}
+			ref := Ref{ID: id}
+			if len(item) > 2 {
+				ref.Relay = item[2]
+			}
+			if len(item) > 3 {
+				ref.Marker = item[3]
+			}
+			h.Refs = append(h.Refs, ref)
+		}
+	}
+	if h.Direction != In && h.Direction != Out {
+		return h, fmt.Errorf("invalid direction '%s'", h.Direction)
+	}
+	return h, nil
+}
+
+// Filters returns the filters for the wallet, tokens, history and token deletions of owner.
+func Filters(owner nostr.PubKey) nostr.Filters {
+	return nostr.Filters{
+		{Kinds: []int{KindWallet, KindToken, KindHistory}, Authors: []nostr.PubKey{owner}},
+		{Kinds: []int{nostr.KindDeletion}, Authors: []nostr.PubKey{owner}, Tags: nostr.TagMap{"k": []string{strconv.Itoa(KindToken)}}},
+	}
+}
@@ -0,0 +1,123 @@
+package nip60
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/keyer"
+	"github.com/nbd-wtf/go-nostr/nip61"
+)
+
+func TestWallet(t *testing.T) {
+	ctx := context.Background()
+	signer, err := keyer.NewPlainKeySigner(nostr.GeneratePrivateKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wallet := Wallet{PrivKey: "e9e9a4bd6ed2e1b4d9c1f9e4aa1c1b0a2a24e31c7b8f5e3d6a9f0c8b7e6d5c4b", Mints: []string{"https://mint.example.com"}}
+	evt, err := wallet.ToEvent(ctx, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := signer.SignEvent(ctx, &evt); err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseWallet(ctx, signer, &evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.PrivKey != wallet.PrivKey || len(parsed.Mints) != 1 || parsed.Mints[0] != wallet.Mints[0] {
+		t.Fatalf("unexpected wallet %+v", parsed)
+	}
+
+	// a token is spent: the change goes in a new one replacing it
+	var tokens []Token
+	for _, token := range []Token{
+		{Mint: "https://mint.example.com", Proofs: []nip61.Proof{{Amount: 1, Secret: "a"}, {Amount: 8, Secret: "b"}}},
+		{Mint: "https://mint.example.com", Proofs: []nip61.Proof{{Amount: 2, Secret: "c"}}},
+		{Mint: "https://mint.example.com", Unit: "usd", Proofs: []nip61.Proof{{Amount: 4, Secret: "d"}}},
+	} {
+		if len(tokens) == 1 {
+			token.Deleted = []nostr.ID{tokens[0].ID}
+		}
+		evt, err := token.ToEvent(ctx, signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := signer.SignEvent(ctx, &evt); err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := ParseToken(ctx, signer, &evt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens = append(tokens, parsed)
+	}
+	if tokens[0].Amount() != 9 || tokens[0].Unit != "sat" || tokens[1].Deleted[0] != tokens[0].ID {
+		t.Fatalf("unexpected tokens %+v", tokens)
+	}
+
+	// the same tokens from another relay
+	tokens = append(tokens, tokens...)
+	balance := Balance(tokens, nil)
+	if balance["sat"] != 2 || balance["usd"] != 4 {
+		t.Fatalf("unexpected balance %v", balance)
+	}
+
+	deletion := DeleteTokens(tokens[2].ID)
+	if err := signer.SignEvent(ctx, &deletion); err != nil {
+		t.Fatal(err)
+	}
+	forged := DeleteTokens(tokens[1].ID)
+	forged.PubKey = nostr.PubKey{1}
+	balance = Balance(tokens, []*nostr.Event{&deletion, &forged})
+	if balance["sat"] != 2 || balance["usd"] != 0 {
+		t.Fatalf("unexpected balance after deletion %v", balance)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	ctx := context.Background()
+	signer, err := keyer.NewPlainKeySigner(nostr.GeneratePrivateKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history := History{
+		Direction: In,
+		Amount:    21,
+		Refs: []Ref{
+			{ID: nostr.ID{1}, Relay: "wss://relay.example.com", Marker: Created},
+			{ID: nostr.ID{2}, Marker: Redeemed},
+		},
+	}
+	evt, err := history.ToEvent(ctx, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evt.Tags) != 1 || evt.Tags[0][1] != (nostr.ID{2}).Hex() || evt.Tags[0][3] != Redeemed {
+		t.Fatalf("only the redeemed nutzap should be public: %v", evt.Tags)
+	}
+	if err := signer.SignEvent(ctx, &evt); err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseHistory(ctx, signer, &evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Direction != In || parsed.Amount != 21 || parsed.Unit != "sat" || len(parsed.Refs) != 2 ||
+		parsed.Refs[0].Relay != "wss://relay.example.com" || parsed.Refs[0].Marker != Created {
+		t.Fatalf("unexpected history %+v", parsed)
+	}
+
+	other, _ := keyer.NewPlainKeySigner(nostr.GeneratePrivateKey())
+	if _, err := ParseHistory(ctx, other, &evt); err == nil {
+		t.Fatal("others shouldn't be able to read the history")
+	}
+	if _, err := (History{Direction: "sideways"}).ToEvent(ctx, signer); err == nil {
+		t.Fatal("invalid direction should fail")
+	}
+}
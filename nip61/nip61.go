@@ -0,0 +1,313 @@
+// Package nip61 implements nutzaps: Cashu tokens sent as kind-9321 events, locked to a key the
+// recipient announced in their kind-10019 event along with the mints they trust, so they can be
+// tipped without a Lightning wallet.
+// See https://github.com/nostr-protocol/nips/blob/master/61.md for details.
+package nip61
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	KindNutzapInfo = 10019
+	KindNutzap     = 9321
+)
+
+// Mint is a Cashu mint a user accepts nutzaps from, in the given units ("sat" if none).
+type Mint struct {
+	URL   string
+	Units []string
+}
+
+// Info is what a user publishes to receive nutzaps.
+type Info struct {
+	Relays []string // where nutzaps to them should be published
+	Mints  []Mint
+	PubKey string // P2PK key, hex, nutzaps must be locked to; not their Nostr key
+}
+
+func ParseInfo(evt *nostr.Event) (Info, error) {
+	if evt.Kind != KindNutzapInfo {
+		return Info{}, fmt.Errorf("expected kind %d, got %d", KindNutzapInfo, evt.Kind)
+	}
+
+	var info Info
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "relay":
+			info.Relays = append(info.Relays, tag[1])
+		case "mint":
+			info.Mints = append(info.Mints, Mint{URL: tag[1], Units: tag[2:]})
+		case "pubkey":
+			info.PubKey = tag[1]
+		}
+	}
+
+	if len(info.Mints) == 0 {
+		return info, fmt.Errorf("missing 'mint' tag")
+	}
+	if info.PubKey == "" {
+		return info, fmt.Errorf("missing 'pubkey' tag")
+	}
+	return info, nil
+}
+
+// ToEvent returns the unsigned nutzap info event.
+func (info Info) ToEvent() nostr.Event {
+	evt := nostr.Event{
+		Kind:      KindNutzapInfo,
+		CreatedAt: nostr.Now(),
+		Tags:      make(nostr.Tags, 0, len(info.Relays)+len(info.Mints)+1),
+	}
+	for _, relay := range info.Relays {
+		evt.Tags = append(evt.Tags, nostr.Tag{"relay", relay})
+	}
+	for _, mint := range info.Mints {
+		evt.Tags = append(evt.Tags, append(nostr.Tag{"mint", mint.URL}, mint.Units...))
+	}
+	evt.Tags = append(evt.Tags, nostr.Tag{"pubkey", info.PubKey})
+	return evt
+}
+
+// AcceptsMint tells if nutzaps from the mint at url, in unit, are accepted.
+func (info Info) AcceptsMint(url, unit string) bool {
+	for _, mint := range info.Mints {
+		if !sameMint(mint.URL, url) {
+			continue
+		}
+		if len(mint.Units) == 0 {
+			return unit == "sat"
+		}
+		for _, u := range mint.Units {
+			if u == unit {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func sameMint(a, b string) bool {
+	return strings.TrimRight(a, "/") == strings.TrimRight(b, "/")
+}
+
+// Proof is a Cashu proof, as in NUT-00, the unit of value of a token.
+type Proof struct {
+	Amount  uint64          `json:"amount"`
+	ID      string          `json:"id"` // of the mint's keyset
+	Secret  string          `json:"secret"`
+	C       string          `json:"C"`
+	Witness string          `json:"witness,omitempty"`
+	DLEQ    json.RawMessage `json:"dleq,omitempty"`
+}
+
+// P2PKSecret is the secret of a proof locked to a public key, as in NUT-11.
+type P2PKSecret struct {
+	Nonce string     `json:"nonce"`
+	Data  string     `json:"data"` // the public key, compressed, hex
+	Tags  [][]string `json:"tags,omitempty"`
+}
+
+// NewP2PKSecret returns a random secret locking a proof to pubkey, to be used when minting the
+// proofs of a nutzap. pubkey is the key from the recipient's Info, "02" is added to x-only keys.
+func NewP2PKSecret(pubkey string) (string, error) {
+	var nonce [32]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return "", err
+	}
+	j, err := json.Marshal([]any{"P2PK", P2PKSecret{Nonce: hex.EncodeToString(nonce[:]), Data: compressedKey(pubkey)}})
+	if err != nil {
+		return "", err
+	}
+	return string(j), nil
+}
+
+// P2PK reads the secret of a proof locked to a public key.
+func (p Proof) P2PK() (P2PKSecret, error) {
+	var secret []json.RawMessage
+	if err := json.Unmarshal([]byte(p.Secret), &secret); err != nil || len(secret) != 2 {
+		return P2PKSecret{}, fmt.Errorf("proof is not locked to a key")
+	}
+	var kind string
+	if err := json.Unmarshal(secret[0], &kind); err != nil || kind != "P2PK" {
+		return P2PKSecret{}, fmt.Errorf("proof is not locked to a key")
+	}
+	var s P2PKSecret
+	if err := json.Unmarshal(secret[1], &s); err != nil || s.Data == "" {
+		return P2PKSecret{}, fmt.Errorf("invalid P2PK secret: %s", p.Secret)
+	}
+	return s, nil
+}
+
+// LockedTo tells if the proof can only be spent by the owner of pubkey, given as in Info.
+func (p Proof) LockedTo(pubkey string) bool {
+	s, err := p.P2PK()
+	return err == nil && strings.EqualFold(s.Data, compressedKey(pubkey))
+}
+
+// compressedKey adds the "02" prefix to x-only keys, as NIP-61 says senders must.
+func compressedKey(pubkey string) string {
+	if len(pubkey) == 64 {
+		return "02" + pubkey
+	}
+	return pubkey
+}
+
+// Nutzap is a payment of Cashu proofs to Recipient, optionally for an event.
+type Nutzap struct {
+	ID        nostr.ID     // set by ParseNutzap
+	Sender    nostr.PubKey // set by ParseNutzap
+	CreatedAt time.Time    // set by ParseNutzap
+	Comment   string
+	Proofs    []Proof
+	Mint      string
+	Unit      string // "sat" if empty
+	Recipient nostr.PubKey
+
+	// the event being zapped, if any
+	Event      string // hex id
+	EventRelay string
+	EventKind  int
+}
+
+func ParseNutzap(evt *nostr.Event) (Nutzap, error) {
+	if evt.Kind != KindNutzap {
+		return Nutzap{}, fmt.Errorf("expected kind %d, got %d", KindNutzap, evt.Kind)
+	}
+
+	zap := Nutzap{ID: evt.ID, Sender: evt.PubKey, CreatedAt: evt.CreatedAt, Comment: evt.Content, Unit: "sat"}
+	var hasRecipient bool
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "proof":
+			var proof Proof
+			if err := json.Unmarshal([]byte(tag[1]), &proof); err != nil {
+				return zap, fmt.Errorf("invalid proof: %w", err)
+			}
+			zap.Proofs = append(zap.Proofs, proof)
+		case "u":
+			zap.Mint = tag[1]
+		case "unit":
+			zap.Unit = tag[1]
+		case "p":
+			pk, err := nostr.PubKeyFromHex(tag[1])
+			if err != nil {
+				return zap, fmt.Errorf("invalid recipient '%s': %w", tag[1], err)
+			}
+			zap.Recipient = pk
+			hasRecipient = true
+		case "e":
+			zap.Event = tag[1]
+			if len(tag) > 2 {
+				zap.EventRelay = tag[2]
+			}
+		case "k":
+			zap.EventKind, _ = strconv.Atoi(tag[1])
+		}
+	}
+
+	switch {
+	case len(zap.Proofs) == 0:
+		return zap, fmt.Errorf("missing 'proof' tag")
+	case zap.Mint == "":
+		return zap, fmt.Errorf("missing 'u' tag")
+	case !hasRecipient:
+		return zap, fmt.Errorf("missing 'p' tag")
+	}
+	return zap, nil
+}
+
+// ToEvent returns the unsigned nutzap event.
+func (zap Nutzap) ToEvent() (nostr.Event, error) {
+	if len(zap.Proofs) == 0 {
+		return nostr.Event{}, fmt.Errorf("nutzap has no proofs")
+	}
+	if zap.Mint == "" {
+		return nostr.Event{}, fmt.Errorf("nutzap has no mint")
+	}
+
+	evt := nostr.Event{
+		Kind:      KindNutzap,
+		CreatedAt: nostr.Now(),
+		Content:   zap.Comment,
+		Tags:      make(nostr.Tags, 0, len(zap.Proofs)+5),
+	}
+	for _, proof := range zap.Proofs {
+		j, err := json.Marshal(proof)
+		if err != nil {
+			return nostr.Event{}, fmt.Errorf("failed to encode proof: %w", err)
+		}
+		evt.Tags = append(evt.Tags, nostr.Tag{"proof", string(j)})
+	}
+	evt.Tags = append(evt.Tags, nostr.Tag{"u", zap.Mint})
+	if zap.Unit != "" && zap.Unit != "sat" {
+		evt.Tags = append(evt.Tags, nostr.Tag{"unit", zap.Unit})
+	}
+	if zap.Event != "" {
+		e := nostr.Tag{"e", zap.Event}
+		if zap.EventRelay != "" {
+			e = append(e, zap.EventRelay)
+		}
+		evt.Tags = append(evt.Tags, e)
+		if zap.EventKind != 0 {
+			evt.Tags = append(evt.Tags, nostr.Tag{"k", strconv.Itoa(zap.EventKind)})
+		}
+	}
+	evt.Tags = append(evt.Tags, nostr.Tag{"p", zap.Recipient.Hex()})
+	return evt, nil
+}
+
+// Amount is the sum of the proofs, in Unit.
+func (zap Nutzap) Amount() uint64 {
+	var total uint64
+	for _, proof := range zap.Proofs {
+		total += proof.Amount
+	}
+	return total
+}
+
+// Validate checks that the nutzap can be redeemed by the owner of info: it comes from one of
+// their mints, in a unit they accept, and every proof is locked to their key. Whether the proofs
+// were already spent can only be asked to the mint.
+func (zap Nutzap) Validate(info Info) error {
+	unit := zap.Unit
+	if unit == "" {
+		unit = "sat"
+	}
+	if !info.AcceptsMint(zap.Mint, unit) {
+		return fmt.Errorf("mint %s in '%s' is not accepted", zap.Mint, unit)
+	}
+	for i, proof := range zap.Proofs {
+		if !proof.LockedTo(info.PubKey) {
+			return fmt.Errorf("proof %d is not locked to %s", i, info.PubKey)
+		}
+	}
+	return nil
+}
+
+// Filter returns a filter for the nutzaps to recipient from the given mints, or any mint if
+// none are given, to be used on the relays of their Info.
+func Filter(recipient nostr.PubKey, mints ...string) nostr.Filter {
+	filter := nostr.Filter{
+		Kinds: []int{KindNutzap},
+		Tags:  nostr.TagMap{"p": []string{recipient.Hex()}},
+	}
+	if len(mints) > 0 {
+		filter.Tags["u"] = mints
+	}
+	return filter
+}
@@ -0,0 +1,79 @@
+package nip61
+
+import (
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const p2pkKey = "e9e9a4bd6ed2e1b4d9c1f9e4aa1c1b0a2a24e31c7b8f5e3d6a9f0c8b7e6d5c4b"
+
+func TestNutzap(t *testing.T) {
+	info := Info{
+		Relays: []string{"wss://relay.example.com"},
+		Mints:  []Mint{{URL: "https://mint.example.com", Units: []string{"sat", "usd"}}, {URL: "https://other.example.com"}},
+		PubKey: p2pkKey,
+	}
+	evt := info.ToEvent()
+	parsed, err := ParseInfo(&evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed.Mints) != 2 || parsed.PubKey != p2pkKey || !parsed.AcceptsMint("https://mint.example.com/", "usd") ||
+		parsed.AcceptsMint("https://other.example.com", "usd") || !parsed.AcceptsMint("https://other.example.com", "sat") {
+		t.Fatalf("unexpected info %+v", parsed)
+	}
+
+	secret, err := NewP2PKSecret(p2pkKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zap := Nutzap{
+		Comment: "thanks",
+		Proofs: []Proof{
+			{Amount: 1, ID: "000a93d6f8a1d2c4", Secret: secret, C: "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"},
+			{Amount: 4, ID: "000a93d6f8a1d2c4", Secret: secret, C: "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"},
+		},
+		Mint:      "https://mint.example.com",
+		Recipient: nostr.PubKey{1},
+		Event:     nostr.ID{2}.Hex(),
+		EventKind: 1,
+	}
+	evt, err = zap.ToEvent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Filter(nostr.PubKey{1}, "https://mint.example.com").Matches(&evt) {
+		t.Fatalf("filter doesn't match the nutzap")
+	}
+	received, err := ParseNutzap(&evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received.Amount() != 5 || received.Unit != "sat" || received.Comment != "thanks" || received.EventKind != 1 ||
+		received.Event != zap.Event || received.Recipient != zap.Recipient {
+		t.Fatalf("unexpected nutzap %+v", received)
+	}
+	if s, err := received.Proofs[0].P2PK(); err != nil || s.Data != "02"+p2pkKey || len(s.Nonce) != 64 {
+		t.Fatalf("unexpected secret %+v, %v", s, err)
+	}
+	if err := received.Validate(parsed); err != nil {
+		t.Fatal(err)
+	}
+
+	// proofs locked to someone else, or not locked
+	received.Proofs[1].Secret = "d341ee4871f1f889041e63cf0d3823c713eea6aff01e80f1719f08f9e5be98f6"
+	if err := received.Validate(parsed); err == nil {
+		t.Fatalf("unlocked proof accepted")
+	}
+	other, _ := NewP2PKSecret("03" + p2pkKey)
+	received.Proofs[1].Secret = other
+	if err := received.Validate(parsed); err == nil {
+		t.Fatalf("proof locked to another key accepted")
+	}
+	received.Proofs = received.Proofs[:1]
+	received.Mint = "https://unknown.example.com"
+	if err := received.Validate(parsed); err == nil {
+		t.Fatalf("unknown mint accepted")
+	}
+}
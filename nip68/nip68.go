@@ -0,0 +1,150 @@
+// Package nip68 implements kind-20 picture events, the posts of picture-first feeds, with one
+// "imeta" tag per image.
+// See https://github.com/nostr-protocol/nips/blob/master/68.md for details.
+package nip68
+
+import (
+	"fmt"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip92"
+)
+
+const KindPicture = 20
+
+// MediaTypes are the image formats picture events can have.
+var MediaTypes = []string{"image/apng", "image/avif", "image/gif", "image/jpeg", "image/png", "image/webp"}
+
+type Picture struct {
+	Title       string
+	Description string
+
+	// Images are the pictures of the post, shown in order. Each should have Alt, and Width,
+	// Height and Blurhash so clients can lay it out before it loads.
+	Images []nip92.IMeta
+
+	ContentWarning string
+	Hashtags       []string
+	Location       string // a place name
+	Geohash        string
+	People         []nostr.PubKey // tagged in the pictures
+}
+
+// ParsePicture reads a kind-20 event, skipping the "imeta" tags it can't read.
+func ParsePicture(evt *nostr.Event) (Picture, error) {
+	if evt.Kind != KindPicture {
+		return Picture{}, fmt.Errorf("expected kind %d, got %d", KindPicture, evt.Kind)
+	}
+
+	picture := Picture{
+		Description: evt.Content,
+		Images:      nip92.ParseAll(evt.Tags),
+	}
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "title":
+			picture.Title = tag[1]
+		case "content-warning":
+			picture.ContentWarning = tag[1]
+		case "t":
+			picture.Hashtags = append(picture.Hashtags, tag[1])
+		case "location":
+			picture.Location = tag[1]
+		case "g":
+			picture.Geohash = tag[1]
+		case "p":
+			if pk, err := nostr.PubKeyFromHex(tag[1]); err == nil {
+				picture.People = append(picture.People, pk)
+			}
+		}
+	}
+	return picture, picture.Validate()
+}
+
+// Validate checks that the picture has images, all with URLs and, when they say it, one of
+// the MediaTypes.
+func (picture Picture) Validate() error {
+	if len(picture.Images) == 0 {
+		return fmt.Errorf("picture has no images")
+	}
+	for _, image := range picture.Images {
+		if image.URL == "" {
+			return fmt.Errorf("image without url")
+		}
+		if image.MimeType != "" && !isMediaType(image.MimeType) {
+			return fmt.Errorf("'%s' is not a picture media type", image.MimeType)
+		}
+		if (image.Width > 0) != (image.Height > 0) {
+			return fmt.Errorf("image %s has only one of its dimensions", image.URL)
+		}
+	}
+	return nil
+}
+
+func isMediaType(mimeType string) bool {
+	for _, t := range MediaTypes {
+		if t == mimeType {
+			return true
+		}
+	}
+	return false
+}
+
+// ToEvent returns the unsigned picture event. Besides the "imeta" tags, the media types and
+// hashes of the images get "m" and "x" tags so they can be queried.
+func (picture Picture) ToEvent() (nostr.Event, error) {
+	if err := picture.Validate(); err != nil {
+		return nostr.Event{}, err
+	}
+
+	evt := nostr.Event{
+		Kind:      KindPicture,
+		CreatedAt: nostr.Now(),
+		Content:   picture.Description,
+		Tags:      nostr.Tags{},
+	}
+	if picture.Title != "" {
+		evt.Tags = append(evt.Tags, nostr.Tag{"title", picture.Title})
+	}
+	for _, image := range picture.Images {
+		evt.Tags = append(evt.Tags, image.Tag())
+	}
+	if picture.ContentWarning != "" {
+		evt.Tags = append(evt.Tags, nostr.Tag{"content-warning", picture.ContentWarning})
+	}
+	for _, pk := range picture.People {
+		evt.Tags = append(evt.Tags, nostr.Tag{"p", pk.Hex()})
+	}
+	seen := make(map[[2]string]bool)
+	for _, image := range picture.Images {
+		for _, tag := range []nostr.Tag{{"m", image.MimeType}, {"x", image.SHA256}} {
+			if key := [2]string{tag[0], tag[1]}; tag[1] != "" && !seen[key] {
+				seen[key] = true
+				evt.Tags = append(evt.Tags, tag)
+			}
+		}
+	}
+	for _, t := range picture.Hashtags {
+		evt.Tags = append(evt.Tags, nostr.Tag{"t", t})
+	}
+	if picture.Location != "" {
+		evt.Tags = append(evt.Tags, nostr.Tag{"location", picture.Location})
+	}
+	if picture.Geohash != "" {
+		evt.Tags = append(evt.Tags, nostr.Tag{"g", picture.Geohash})
+	}
+	return evt, nil
+}
+
+// Filter matches the picture events with images of the given media types, or all of them if
+// none are given.
+func Filter(mediaTypes ...string) nostr.Filter {
+	filter := nostr.Filter{Kinds: []int{KindPicture}}
+	if len(mediaTypes) > 0 {
+		filter.Tags = nostr.TagMap{"m": mediaTypes}
+	}
+	return filter
+}
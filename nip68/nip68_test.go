@@ -0,0 +1,56 @@
+package nip68
+
+import (
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip92"
+)
+
+func TestPictureRoundTrip(t *testing.T) {
+	picture := Picture{
+		Title:       "sunset",
+		Description: "from the beach",
+		Images: []nip92.IMeta{
+			{URL: "https://example.com/a.jpg", MimeType: "image/jpeg", Width: 3024, Height: 4032, Blurhash: "eVF$^OI:${M{o#*0-nNFxakD-?xVM}WEWB%iNKxvR-oetmo#R-aen$", Alt: "the sun setting", SHA256: "aa"},
+			{URL: "https://example.com/b.webp", MimeType: "image/webp", Alt: "waves"},
+		},
+		Hashtags: []string{"sunset"},
+		Location: "Praia",
+		People:   []nostr.PubKey{{1}},
+	}
+
+	evt, err := picture.ToEvent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if evt.Kind != KindPicture || evt.Tags.GetFirst([]string{"m", "image/webp"}) == nil || evt.Tags.GetFirst([]string{"x", "aa"}) == nil {
+		t.Fatalf("unexpected event %v", evt)
+	}
+	if !Filter("image/jpeg").Matches(&evt) || Filter("image/gif").Matches(&evt) {
+		t.Fatalf("media type filter doesn't work")
+	}
+
+	parsed, err := ParsePicture(&evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Title != "sunset" || parsed.Description != "from the beach" || len(parsed.Images) != 2 ||
+		parsed.Images[0].Width != 3024 || parsed.Images[0].Blurhash != picture.Images[0].Blurhash ||
+		parsed.Images[1].Alt != "waves" || parsed.Location != "Praia" || len(parsed.People) != 1 {
+		t.Fatalf("unexpected picture: %+v", parsed)
+	}
+
+	for _, invalid := range []Picture{
+		{Description: "no images"},
+		{Images: []nip92.IMeta{{URL: "https://example.com/v.mp4", MimeType: "video/mp4"}}},
+		{Images: []nip92.IMeta{{URL: "https://example.com/a.png", Width: 100}}},
+	} {
+		if _, err := invalid.ToEvent(); err == nil {
+			t.Errorf("invalid picture %+v accepted", invalid)
+		}
+	}
+	if _, err := ParsePicture(&nostr.Event{Kind: KindPicture, Tags: nostr.Tags{{"imeta", "m image/png"}}}); err == nil {
+		t.Fatalf("picture without valid images accepted")
+	}
+}
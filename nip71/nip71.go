@@ -0,0 +1,141 @@
+// Package nip71 implements video events: kind 21 for regular videos and kind 22 for short,
+// vertical ones, each with one "imeta" tag per variant of the file.
+// See https://github.com/nostr-protocol/nips/blob/master/71.md for details.
+package nip71
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip92"
+)
+
+const (
+	KindVideo      = 21
+	KindShortVideo = 22
+)
+
+type Video struct {
+	Short       bool // kind 22
+	Title       string
+	Description string
+	PublishedAt time.Time
+	Alt         string
+
+	// Variants are the same video in different resolutions or formats, each with its own
+	// thumbnails in Image and length in Duration.
+	Variants []nip92.IMeta
+
+	ContentWarning string
+	Hashtags       []string
+	Participants   []nostr.PubKey
+}
+
+// ParseVideo reads a kind-21 or kind-22 event.
+func ParseVideo(evt *nostr.Event) (Video, error) {
+	if evt.Kind != KindVideo && evt.Kind != KindShortVideo {
+		return Video{}, fmt.Errorf("kind %d is not a video", evt.Kind)
+	}
+
+	video := Video{
+		Short:       evt.Kind == KindShortVideo,
+		Description: evt.Content,
+		Variants:    nip92.ParseAll(evt.Tags),
+	}
+	if len(video.Variants) == 0 {
+		return video, fmt.Errorf("video has no valid imeta tags")
+	}
+
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "title":
+			video.Title = tag[1]
+		case "alt":
+			video.Alt = tag[1]
+		case "published_at":
+			if ts, err := strconv.ParseInt(tag[1], 10, 64); err == nil {
+				video.PublishedAt = time.Unix(ts, 0)
+			}
+		case "content-warning":
+			video.ContentWarning = tag[1]
+		case "t":
+			video.Hashtags = append(video.Hashtags, tag[1])
+		case "p":
+			if pk, err := nostr.PubKeyFromHex(tag[1]); err == nil {
+				video.Participants = append(video.Participants, pk)
+			}
+		case "duration":
+			// older events have a single duration for all the variants
+			if d, err := strconv.ParseFloat(tag[1], 64); err == nil {
+				for i := range video.Variants {
+					if video.Variants[i].Duration == 0 {
+						video.Variants[i].Duration = d
+					}
+				}
+			}
+		}
+	}
+	return video, nil
+}
+
+// Duration is the length of the video, from the first variant that has it.
+func (video Video) Duration() time.Duration {
+	for _, v := range video.Variants {
+		if v.Duration > 0 {
+			return time.Duration(v.Duration * float64(time.Second))
+		}
+	}
+	return 0
+}
+
+// Thumbnails returns the preview images of all the variants, without repetitions.
+func (video Video) Thumbnails() []string {
+	var thumbs []string
+	seen := make(map[string]struct{})
+	for _, v := range video.Variants {
+		for _, image := range v.Image {
+			if _, ok := seen[image]; !ok {
+				seen[image] = struct{}{}
+				thumbs = append(thumbs, image)
+			}
+		}
+	}
+	return thumbs
+}
+
+// ToEvent returns the unsigned video event.
+func (video Video) ToEvent() nostr.Event {
+	evt := nostr.Event{
+		Kind:      KindVideo,
+		CreatedAt: nostr.Now(),
+		Content:   video.Description,
+		Tags:      nostr.Tags{{"title", video.Title}},
+	}
+	if video.Short {
+		evt.Kind = KindShortVideo
+	}
+	if !video.PublishedAt.IsZero() {
+		evt.Tags = append(evt.Tags, nostr.Tag{"published_at", strconv.FormatInt(video.PublishedAt.Unix(), 10)})
+	}
+	if video.Alt != "" {
+		evt.Tags = append(evt.Tags, nostr.Tag{"alt", video.Alt})
+	}
+	for _, v := range video.Variants {
+		evt.Tags = append(evt.Tags, v.Tag())
+	}
+	if video.ContentWarning != "" {
+		evt.Tags = append(evt.Tags, nostr.Tag{"content-warning", video.ContentWarning})
+	}
+	for _, t := range video.Hashtags {
+		evt.Tags = append(evt.Tags, nostr.Tag{"t", t})
+	}
+	for _, pk := range video.Participants {
+		evt.Tags = append(evt.Tags, nostr.Tag{"p", pk.Hex()})
+	}
+	return evt
+}
@@ -0,0 +1,49 @@
+package nip71
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip92"
+)
+
+func TestVideoRoundTrip(t *testing.T) {
+	video := Video{
+		Short:       true,
+		Title:       "cat",
+		Description: "my cat doing things",
+		PublishedAt: time.Unix(1700000000, 0),
+		Variants: []nip92.IMeta{
+			{URL: "https://example.com/1080.mp4", MimeType: "video/mp4", Width: 1080, Height: 1920, Duration: 29.5, Image: []string{"https://example.com/thumb.jpg"}},
+			{URL: "https://example.com/720.mp4", MimeType: "video/mp4", Width: 720, Height: 1280, Image: []string{"https://example.com/thumb.jpg"}},
+		},
+		Hashtags: []string{"cats"},
+	}
+
+	evt := video.ToEvent()
+	if evt.Kind != KindShortVideo {
+		t.Fatalf("expected kind %d, got %d", KindShortVideo, evt.Kind)
+	}
+	parsed, err := ParseVideo(&evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Title != "cat" || !parsed.PublishedAt.Equal(video.PublishedAt) || len(parsed.Variants) != 2 || len(parsed.Hashtags) != 1 {
+		t.Fatalf("unexpected video: %+v", parsed)
+	}
+	if parsed.Duration() != 29500*time.Millisecond {
+		t.Fatalf("unexpected duration %s", parsed.Duration())
+	}
+	if thumbs := parsed.Thumbnails(); len(thumbs) != 1 {
+		t.Fatalf("unexpected thumbnails %v", thumbs)
+	}
+
+	legacy := nostr.Event{Kind: KindVideo, Tags: nostr.Tags{
+		{"imeta", "url https://example.com/a.mp4"},
+		{"duration", "60"},
+	}}
+	if parsed, _ := ParseVideo(&legacy); parsed.Duration() != time.Minute {
+		t.Fatalf("legacy duration tag ignored: %s", parsed.Duration())
+	}
+}
@@ -0,0 +1,235 @@
+// Package nip73 implements external content ids: "i" tags pointing events at things outside
+// of Nostr, like web pages, books, movies, podcasts and hashtags, with "k" tags saying what
+// kind of thing they are, so clients can show and query the discussion about them.
+// See https://github.com/nostr-protocol/nips/blob/master/73.md for details.
+package nip73
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Kind is what an id points to, it goes in the "k" tag.
+type Kind string
+
+const (
+	Web              Kind = "web"
+	ISBN             Kind = "isbn"
+	Geohash          Kind = "geo"
+	Country          Kind = "iso3166"
+	ISAN             Kind = "isan" // movies
+	DOI              Kind = "doi"  // papers
+	Hashtag          Kind = "#"
+	PodcastFeed      Kind = "podcast:guid"
+	PodcastEpisode   Kind = "podcast:item:guid"
+	PodcastPublisher Kind = "podcast:publisher:guid"
+	BitcoinTx        Kind = "bitcoin:tx"
+	BitcoinAddress   Kind = "bitcoin:address"
+	EthereumTx       Kind = "ethereum:tx"
+	EthereumAddress  Kind = "ethereum:address"
+)
+
+// prefixes of the kinds whose ids are the kind followed by ":", longest first so podcast
+// episodes aren't taken for feeds
+var prefixed = []Kind{PodcastEpisode, PodcastPublisher, PodcastFeed, BitcoinTx, BitcoinAddress, ISBN, Geohash, Country, ISAN, DOI}
+
+// ExternalID is the value of an "i" tag.
+type ExternalID struct {
+	Kind  Kind
+	Value string // the whole tag value, e.g. "isbn:9780765382030"
+	Hint  string // optional URL where the thing can be found
+}
+
+// URL points to a web page. The URL is normalized and its fragment removed.
+func URL(u string) (ExternalID, error) {
+	parsed, err := url.Parse(strings.TrimSpace(u))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return ExternalID{}, fmt.Errorf("invalid web URL '%s'", u)
+	}
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	parsed.Host = strings.ToLower(parsed.Host)
+	parsed.Fragment = ""
+	parsed.RawFragment = ""
+	return ExternalID{Kind: Web, Value: parsed.String()}, nil
+}
+
+// Book is identified by its ISBN, hyphens are removed.
+func Book(isbn string) (ExternalID, error) {
+	isbn = strings.ReplaceAll(strings.ReplaceAll(strings.TrimSpace(isbn), "-", ""), " ", "")
+	if len(isbn) != 10 && len(isbn) != 13 {
+		return ExternalID{}, fmt.Errorf("invalid ISBN '%s'", isbn)
+	}
+	for i, c := range isbn {
+		if (c < '0' || c > '9') && !(i == 9 && len(isbn) == 10 && (c == 'X' || c == 'x')) {
+			return ExternalID{}, fmt.Errorf("invalid ISBN '%s'", isbn)
+		}
+	}
+	return ExternalID{Kind: ISBN, Value: string(ISBN) + ":" + strings.ToUpper(isbn)}, nil
+}
+
+// Movie is identified by its ISAN, without the version part.
+func Movie(isan string) ExternalID {
+	return ExternalID{Kind: ISAN, Value: string(ISAN) + ":" + strings.ToUpper(strings.TrimSpace(isan))}
+}
+
+// Paper is identified by its DOI, which is lowercased.
+func Paper(doi string) ExternalID {
+	return ExternalID{Kind: DOI, Value: string(DOI) + ":" + strings.ToLower(strings.TrimSpace(doi))}
+}
+
+// Location is a geohash, which is lowercased.
+func Location(geohash string) ExternalID {
+	return ExternalID{Kind: Geohash, Value: string(Geohash) + ":" + strings.ToLower(strings.TrimSpace(geohash))}
+}
+
+// Region is an ISO 3166 country or subdivision code, like "ES" or "ES-M", uppercased.
+func Region(code string) ExternalID {
+	return ExternalID{Kind: Country, Value: string(Country) + ":" + strings.ToUpper(strings.TrimSpace(code))}
+}
+
+// Topic is a hashtag, with or without "#". It is lowercased.
+func Topic(hashtag string) ExternalID {
+	return ExternalID{Kind: Hashtag, Value: "#" + strings.ToLower(strings.TrimPrefix(strings.TrimSpace(hashtag), "#"))}
+}
+
+// Podcast is identified by the podcast:guid of its feed.
+func Podcast(guid string) ExternalID {
+	return ExternalID{Kind: PodcastFeed, Value: string(PodcastFeed) + ":" + strings.TrimSpace(guid)}
+}
+
+// Episode is identified by the guid of the item in the podcast feed.
+func Episode(guid string) ExternalID {
+	return ExternalID{Kind: PodcastEpisode, Value: string(PodcastEpisode) + ":" + strings.TrimSpace(guid)}
+}
+
+// Publisher is identified by the guid of a podcast publisher feed.
+func Publisher(guid string) ExternalID {
+	return ExternalID{Kind: PodcastPublisher, Value: string(PodcastPublisher) + ":" + strings.TrimSpace(guid)}
+}
+
+// Transaction is a Bitcoin transaction, hash in lowercase hex.
+func Transaction(hash string) ExternalID {
+	return ExternalID{Kind: BitcoinTx, Value: string(BitcoinTx) + ":" + strings.ToLower(strings.TrimSpace(hash))}
+}
+
+// Address is a Bitcoin address, kept as it is since some encodings are case-sensitive.
+func Address(address string) ExternalID {
+	return ExternalID{Kind: BitcoinAddress, Value: string(BitcoinAddress) + ":" + strings.TrimSpace(address)}
+}
+
+// EthereumTransaction is a transaction on the EVM chain with the given id, 1 for mainnet.
+func EthereumTransaction(chainID int, hash string) ExternalID {
+	return ExternalID{Kind: EthereumTx, Value: fmt.Sprintf("ethereum:%d:tx:%s", chainID, strings.ToLower(strings.TrimSpace(hash)))}
+}
+
+// EthereumAccount is an address on the EVM chain with the given id, 1 for mainnet.
+func EthereumAccount(chainID int, address string) ExternalID {
+	return ExternalID{Kind: EthereumAddress, Value: fmt.Sprintf("ethereum:%d:address:%s", chainID, strings.ToLower(strings.TrimSpace(address)))}
+}
+
+// WithHint returns id with a URL where the thing can be found.
+func (id ExternalID) WithHint(hint string) ExternalID {
+	id.Hint = hint
+	return id
+}
+
+// Parse reads the value of an "i" tag, telling its kind from its prefix.
+func Parse(value string) (ExternalID, error) {
+	switch {
+	case value == "":
+		return ExternalID{}, fmt.Errorf("empty external id")
+	case strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://"):
+		return ExternalID{Kind: Web, Value: value}, nil
+	case strings.HasPrefix(value, "#") && len(value) > 1:
+		return ExternalID{Kind: Hashtag, Value: value}, nil
+	case strings.HasPrefix(value, "ethereum:"):
+		// ethereum:<chain id>:<tx|address>:<hash>
+		parts := strings.SplitN(value, ":", 4)
+		if len(parts) == 4 && parts[1] != "" && parts[3] != "" {
+			switch parts[2] {
+			case "tx":
+				return ExternalID{Kind: EthereumTx, Value: value}, nil
+			case "address":
+				return ExternalID{Kind: EthereumAddress, Value: value}, nil
+			}
+		}
+	default:
+		for _, kind := range prefixed {
+			if strings.HasPrefix(value, string(kind)+":") && len(value) > len(kind)+1 {
+				return ExternalID{Kind: kind, Value: value}, nil
+			}
+		}
+	}
+	return ExternalID{}, fmt.Errorf("unknown external id '%s'", value)
+}
+
+// Tags returns the "i" and "k" tags for id.
+func (id ExternalID) Tags() nostr.Tags {
+	i := nostr.Tag{"i", id.Value}
+	if id.Hint != "" {
+		i = append(i, id.Hint)
+	}
+	return nostr.Tags{i, {"k", string(id.Kind)}}
+}
+
+// Add tags evt with the given ids, skipping the tags it already has.
+func Add(evt *nostr.Event, ids ...ExternalID) {
+	for _, id := range ids {
+		for _, tag := range id.Tags() {
+			if !hasTag(evt.Tags, tag[0], tag[1]) {
+				evt.Tags = append(evt.Tags, tag)
+			}
+		}
+	}
+}
+
+// hasTag is like Tags.GetFirst but doesn't take values that start with value for it.
+func hasTag(tags nostr.Tags, name, value string) bool {
+	for _, tag := range tags {
+		if len(tag) >= 2 && tag[0] == name && tag[1] == value {
+			return true
+		}
+	}
+	return false
+}
+
+// FromEvent returns the external ids in the "i" tags of evt, skipping the ones it can't parse.
+func FromEvent(evt *nostr.Event) []ExternalID {
+	var ids []ExternalID
+	for _, tag := range evt.Tags.GetAll([]string{"i", ""}) {
+		id, err := Parse(tag.Value())
+		if err != nil {
+			continue
+		}
+		if len(tag) > 2 {
+			id.Hint = tag[2]
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Filter matches the events about any of ids, of the given event kinds if any are given.
+func Filter(ids []ExternalID, kinds ...int) nostr.Filter {
+	values := make([]string, len(ids))
+	for i, id := range ids {
+		values[i] = id.Value
+	}
+	filter := nostr.Filter{Tags: nostr.TagMap{"i": values}}
+	if len(kinds) > 0 {
+		filter.Kinds = kinds
+	}
+	return filter
+}
+
+// KindFilter matches the events about anything of the given kinds, e.g. all books.
+func KindFilter(kinds ...Kind) nostr.Filter {
+	values := make([]string, len(kinds))
+	for i, kind := range kinds {
+		values[i] = string(kind)
+	}
+	return nostr.Filter{Tags: nostr.TagMap{"k": values}}
+}
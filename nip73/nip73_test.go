@@ -0,0 +1,70 @@
+package nip73
+
+import (
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestExternalIDs(t *testing.T) {
+	book, err := Book("978-0-7653-8203-0")
+	if err != nil || book.Value != "isbn:9780765382030" {
+		t.Fatalf("unexpected book %+v, %v", book, err)
+	}
+	if _, err := Book("12345"); err == nil {
+		t.Errorf("invalid ISBN accepted")
+	}
+	page, err := URL("HTTPS://Example.com/post?id=1#comments")
+	if err != nil || page.Value != "https://example.com/post?id=1" {
+		t.Fatalf("unexpected page %+v, %v", page, err)
+	}
+
+	for _, id := range []ExternalID{
+		book, page,
+		Movie("0000-0000-401A-0000-7"),
+		Paper("10.1000/182"),
+		Location("EZS42E44YX96"),
+		Region("es"),
+		Topic("#Nostr"),
+		Podcast("c90e609a-df1e-596a-bd5e-57bcc8aad6cc"),
+		Episode("d98d189b-dc7b-45b1-8720-d4b98690f31f"),
+		Publisher("18bcbf10-6701-4ffb-b255-bc057390d738"),
+		Transaction("A1075DB55D416D3CA199F55B6084E2115B9345E16C5CF302FC80E9D5FBF5D48D"),
+		Address("bc1qxy2kgdygjrsqtzq2n0yrf2493p83kkfjhx0wlh"),
+		EthereumTransaction(1, "0x98F7812BE496F97F80E2E98D66358D1FC733CF34176A8356D171EA7FBBE97CCD"),
+		EthereumAccount(100, "0xd8dA6BF26964aF9D7eEd9e03E53415D37aA96045"),
+	} {
+		parsed, err := Parse(id.Value)
+		if err != nil || parsed.Kind != id.Kind {
+			t.Errorf("%s parsed as %+v, %v", id.Value, parsed, err)
+		}
+	}
+	if Topic("#Nostr").Value != "#nostr" || Region("es").Value != "iso3166:ES" {
+		t.Errorf("ids should be normalized")
+	}
+	if _, err := Parse("something:else"); err == nil {
+		t.Errorf("unknown id parsed")
+	}
+}
+
+func TestEventTags(t *testing.T) {
+	episode := Episode("d98d189b").WithHint("https://fountain.fm/episode/123")
+	evt := nostr.Event{Kind: 1111}
+	Add(&evt, episode, Topic("podcasting"), Topic("podcasting"))
+
+	if len(evt.Tags) != 4 {
+		t.Fatalf("unexpected tags %v", evt.Tags)
+	}
+	ids := FromEvent(&evt)
+	if len(ids) != 2 || ids[0] != episode || ids[1].Kind != Hashtag {
+		t.Fatalf("unexpected ids %+v", ids)
+	}
+
+	filter := Filter([]ExternalID{episode}, 1111)
+	if !filter.Matches(&evt) || filter.Matches(&nostr.Event{Kind: 1111}) {
+		t.Errorf("filter doesn't match the event about the episode")
+	}
+	if !KindFilter(PodcastEpisode).Matches(&evt) || KindFilter(ISBN).Matches(&evt) {
+		t.Errorf("kind filter doesn't match")
+	}
+}
@@ -0,0 +1,144 @@
+// Package nip75 implements zap goals, kind-9041 fundraising targets that are funded by zaps
+// tagging them.
+// See https://github.com/nostr-protocol/nips/blob/master/75.md for details.
+package nip75
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip57"
+)
+
+const KindZapGoal = 9041
+
+type Goal struct {
+	ID          nostr.ID // set by ParseGoal
+	Description string
+	Amount      int64    // target, in millisatoshis
+	Relays      []string // where zaps to the goal are published and counted from
+	ClosedAt    time.Time
+	Image       string
+	Summary     string
+	URL         string // optional link to what the goal is about
+}
+
+func ParseGoal(evt *nostr.Event) (Goal, error) {
+	if evt.Kind != KindZapGoal {
+		return Goal{}, fmt.Errorf("expected kind %d, got %d", KindZapGoal, evt.Kind)
+	}
+
+	goal := Goal{ID: evt.ID, Description: evt.Content}
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "amount":
+			amount, err := strconv.ParseInt(tag[1], 10, 64)
+			if err != nil || amount <= 0 {
+				return goal, fmt.Errorf("invalid amount '%s'", tag[1])
+			}
+			goal.Amount = amount
+		case "relays":
+			goal.Relays = append(goal.Relays, tag[1:]...)
+		case "closed_at":
+			if ts, err := strconv.ParseInt(tag[1], 10, 64); err == nil {
+				goal.ClosedAt = time.Unix(ts, 0)
+			}
+		case "image":
+			goal.Image = tag[1]
+		case "summary":
+			goal.Summary = tag[1]
+		case "r":
+			goal.URL = tag[1]
+		}
+	}
+
+	if goal.Amount == 0 {
+		return goal, fmt.Errorf("missing 'amount' tag")
+	}
+	if len(goal.Relays) == 0 {
+		return goal, fmt.Errorf("missing 'relays' tag")
+	}
+	return goal, nil
+}
+
+// ToEvent returns the unsigned goal event.
+func (goal Goal) ToEvent() nostr.Event {
+	evt := nostr.Event{
+		Kind:      KindZapGoal,
+		CreatedAt: nostr.Now(),
+		Content:   goal.Description,
+		Tags: nostr.Tags{
+			{"amount", strconv.FormatInt(goal.Amount, 10)},
+			append(nostr.Tag{"relays"}, goal.Relays...),
+		},
+	}
+	if !goal.ClosedAt.IsZero() {
+		evt.Tags = append(evt.Tags, nostr.Tag{"closed_at", strconv.FormatInt(goal.ClosedAt.Unix(), 10)})
+	}
+	for _, field := range []struct{ name, value string }{
+		{"image", goal.Image},
+		{"summary", goal.Summary},
+		{"r", goal.URL},
+	} {
+		if field.value != "" {
+			evt.Tags = append(evt.Tags, nostr.Tag{field.name, field.value})
+		}
+	}
+	return evt
+}
+
+// Progress is how much a goal has raised.
+type Progress struct {
+	Raised  int64 // millisatoshis
+	Zaps    int
+	Percent float64 // can be over 100
+}
+
+// ComputeProgress adds up the zap receipts for goal. Receipts that don't tag the goal, that
+// can't be read or, if the goal has a closing date, that came after it, are ignored, as are
+// repeated receipts.
+func ComputeProgress(goal Goal, receipts []*nostr.Event) Progress {
+	var progress Progress
+	seen := make(map[nostr.ID]struct{}, len(receipts))
+	for _, receipt := range receipts {
+		if _, ok := seen[receipt.ID]; ok {
+			continue
+		}
+		seen[receipt.ID] = struct{}{}
+
+		if receipt.Kind != nostr.KindZap || receipt.Tags.GetFirst([]string{"e", goal.ID.Hex()}) == nil {
+			continue
+		}
+		if !goal.ClosedAt.IsZero() && receipt.CreatedAt.After(goal.ClosedAt) {
+			continue
+		}
+		amount, err := nip57.GetAmountFromZap(receipt)
+		if err != nil {
+			continue
+		}
+		progress.Raised += amount
+		progress.Zaps++
+	}
+
+	if goal.Amount > 0 {
+		progress.Percent = float64(progress.Raised) * 100 / float64(goal.Amount)
+	}
+	return progress
+}
+
+// Filter returns a filter for the zap receipts of goal, to be used on its relays.
+func Filter(goal Goal) nostr.Filter {
+	filter := nostr.Filter{
+		Kinds: []int{nostr.KindZap},
+		Tags:  nostr.TagMap{"e": []string{goal.ID.Hex()}},
+	}
+	if !goal.ClosedAt.IsZero() {
+		filter.Until = &goal.ClosedAt
+	}
+	return filter
+}
@@ -0,0 +1,46 @@
+package nip75
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestGoalProgress(t *testing.T) {
+	goal := Goal{
+		Description: "Nostrasia travel expenses",
+		Amount:      210_000_000,
+		Relays:      []string{"wss://alicerelay.example.com", "wss://bobrelay.example.com"},
+		ClosedAt:    time.Unix(1700000000, 0),
+	}
+	evt := goal.ToEvent()
+	evt.ID = evt.GetID()
+	parsed, err := ParseGoal(&evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Amount != goal.Amount || len(parsed.Relays) != 2 || !parsed.ClosedAt.Equal(goal.ClosedAt) {
+		t.Fatalf("unexpected goal: %+v", parsed)
+	}
+
+	receipt := func(id byte, bolt11 string, at int64, goalID nostr.ID) *nostr.Event {
+		return &nostr.Event{
+			ID:        nostr.ID{id},
+			Kind:      nostr.KindZap,
+			CreatedAt: time.Unix(at, 0),
+			Tags:      nostr.Tags{{"e", goalID.Hex()}, {"bolt11", bolt11}},
+		}
+	}
+	first := receipt(1, "lnbc1m1abc", 1690000000, parsed.ID) // 100k sats
+	progress := ComputeProgress(parsed, []*nostr.Event{
+		first,
+		first,
+		receipt(2, "lnbc50u1abc", 1690000001, parsed.ID),
+		receipt(3, "lnbc1m1abc", 1800000000, parsed.ID),  // too late
+		receipt(4, "lnbc1m1abc", 1690000000, nostr.ID{}), // another goal
+	})
+	if progress.Zaps != 2 || progress.Raised != 105_000_000 || progress.Percent != 50 {
+		t.Fatalf("unexpected progress: %+v", progress)
+	}
+}
@@ -0,0 +1,137 @@
+// Package nip84 implements kind-9802 highlights: excerpts of notes, articles or web pages
+// that a user found worth sharing.
+// See https://github.com/nostr-protocol/nips/blob/master/84.md for details.
+package nip84
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const KindHighlight = 9802
+
+// Source is where the highlighted text came from. Exactly one of Event, Address or URL is set.
+type Source struct {
+	Event   *nostr.ID
+	Address string // "<kind>:<pubkey>:<d tag>", for articles and other addressable events
+	URL     string
+	Relay   string // optional hint for Event and Address
+}
+
+// Attribution credits the authors (or editors and so on) of the source.
+type Attribution struct {
+	PubKey nostr.PubKey
+	Relay  string
+	Role   string // optional, like "author" or "editor"
+}
+
+type Highlight struct {
+	Text    string // the highlighted excerpt
+	Context string // optional, the paragraph the excerpt is part of
+	Comment string // optional, makes it a quote highlight
+	Source  Source
+
+	Attributions []Attribution
+}
+
+// ToEvent returns the unsigned highlight event.
+func (h Highlight) ToEvent() (nostr.Event, error) {
+	evt := nostr.Event{
+		Kind:      KindHighlight,
+		CreatedAt: nostr.Now(),
+		Content:   h.Text,
+	}
+
+	switch {
+	case h.Source.Event != nil:
+		evt.Tags = append(evt.Tags, nostr.EventTag(*h.Source.Event, h.Source.Relay, ""))
+	case h.Source.Address != "":
+		evt.Tags = append(evt.Tags, nostr.AddressTag(h.Source.Address, h.Source.Relay))
+	case h.Source.URL != "":
+		evt.Tags = append(evt.Tags, nostr.Tag{"r", h.Source.URL, "source"})
+	default:
+		return evt, fmt.Errorf("highlight has no source")
+	}
+
+	if h.Context != "" {
+		evt.Tags = append(evt.Tags, nostr.Tag{"context", h.Context})
+	}
+	if h.Comment != "" {
+		evt.Tags = append(evt.Tags, nostr.Tag{"comment", h.Comment})
+	}
+	for _, a := range h.Attributions {
+		tag := nostr.Tag{"p", a.PubKey.Hex(), a.Relay}
+		if a.Role != "" {
+			tag = append(tag, a.Role)
+		}
+		evt.Tags = append(evt.Tags, tag)
+	}
+	return evt, nil
+}
+
+// ParseHighlight reads a kind-9802 event. "r" tags marked as "mention" are links from the
+// comment, not the source, and are ignored.
+func ParseHighlight(evt *nostr.Event) (Highlight, error) {
+	if evt.Kind != KindHighlight {
+		return Highlight{}, fmt.Errorf("expected kind %d, got %d", KindHighlight, evt.Kind)
+	}
+
+	h := Highlight{Text: evt.Content}
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "e":
+			if id, err := nostr.IDFromHex(tag[1]); err == nil && h.Source.Event == nil {
+				h.Source.Event = &id
+				h.Source.Relay = tag.Relay()
+			}
+		case "a":
+			if h.Source.Address == "" {
+				h.Source.Address = tag[1]
+				if h.Source.Event == nil {
+					h.Source.Relay = tag.Relay()
+				}
+			}
+		case "r":
+			if (len(tag) < 3 || tag[2] != "mention") && h.Source.URL == "" {
+				h.Source.URL = tag[1]
+			}
+		case "context":
+			h.Context = tag[1]
+		case "comment":
+			h.Comment = tag[1]
+		case "p":
+			pk, err := nostr.PubKeyFromHex(tag[1])
+			if err != nil {
+				continue
+			}
+			a := Attribution{PubKey: pk, Relay: tag.Relay()}
+			if len(tag) > 3 {
+				a.Role = tag[3]
+			}
+			h.Attributions = append(h.Attributions, a)
+		}
+	}
+
+	if h.Source.Event == nil && h.Source.Address == "" && h.Source.URL == "" {
+		return h, fmt.Errorf("highlight has no source")
+	}
+	return h, nil
+}
+
+// Range returns where the highlighted text is in Context, as byte offsets, so it can be shown
+// marked within it. ok is false if there is no context or the text isn't in it.
+func (h Highlight) Range() (start int, end int, ok bool) {
+	if h.Context == "" || h.Text == "" {
+		return 0, 0, false
+	}
+	start = strings.Index(h.Context, h.Text)
+	if start == -1 {
+		return 0, 0, false
+	}
+	return start, start + len(h.Text), true
+}
@@ -0,0 +1,50 @@
+package nip84
+
+import (
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestHighlightRoundTrip(t *testing.T) {
+	author := nostr.MustPubKeyFromHex("3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d")
+	h := Highlight{
+		Text:    "the quick brown fox",
+		Context: "Everybody knows that the quick brown fox jumps over the lazy dog.",
+		Comment: "classic",
+		Source:  Source{Address: "30023:" + author.Hex() + ":foxes", Relay: "wss://relay.example.com"},
+		Attributions: []Attribution{
+			{PubKey: author, Role: "author"},
+		},
+	}
+
+	evt, err := h.ToEvent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseHighlight(&evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Source.Address != h.Source.Address || parsed.Source.Relay != h.Source.Relay ||
+		parsed.Comment != "classic" || len(parsed.Attributions) != 1 || parsed.Attributions[0].Role != "author" {
+		t.Fatalf("unexpected highlight: %+v", parsed)
+	}
+
+	start, end, ok := parsed.Range()
+	if !ok || parsed.Context[start:end] != h.Text {
+		t.Fatalf("unexpected range %d-%d", start, end)
+	}
+
+	evt = nostr.Event{Kind: KindHighlight, Content: "x", Tags: nostr.Tags{
+		{"r", "https://example.com/mentioned", "mention"},
+		{"r", "https://example.com/article"},
+	}}
+	if parsed, _ := ParseHighlight(&evt); parsed.Source.URL != "https://example.com/article" {
+		t.Fatalf("unexpected source: %+v", parsed.Source)
+	}
+
+	if _, err := (Highlight{Text: "orphan"}).ToEvent(); err == nil {
+		t.Fatal("highlight without source accepted")
+	}
+}
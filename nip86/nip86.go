@@ -0,0 +1,231 @@
+// Package nip86 is a client for the relay management API, JSON-RPC over HTTP on the relay's
+// URL, with requests authenticated as in NIP-98, so relay admins can ban and allow users and
+// events and change the relay's settings from code:
+//
+//	admin := nip86.NewClient("wss://relay.example.com", signer)
+//	err := admin.BanPubKey(ctx, spammer, "spam")
+//
+// See https://github.com/nostr-protocol/nips/blob/master/86.md for details.
+package nip86
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip98"
+)
+
+// ContentType is the content type of management requests.
+const ContentType = "application/nostr+json+rpc"
+
+// Client calls the management API of one relay.
+type Client struct {
+	URL        string // http:// or https://
+	Signer     nostr.Signer
+	HTTPClient *http.Client
+}
+
+// NewClient returns a client for the relay at relayURL, which can be given as ws:// or wss://,
+// signing the requests with signer, which must be an admin of the relay.
+func NewClient(relayURL string, signer nostr.Signer) *Client {
+	u := nostr.NormalizeURL(relayURL)
+	if u == "" {
+		u = relayURL
+	}
+	u = strings.Replace(u, "ws://", "http://", 1)
+	u = strings.Replace(u, "wss://", "https://", 1)
+	return &Client{
+		URL:        u,
+		Signer:     signer,
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Error is an error returned by the relay for a call.
+type Error struct {
+	Method  string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s failed: %s", e.Method, e.Message)
+}
+
+type request struct {
+	Method string `json:"method"`
+	Params []any  `json:"params"`
+}
+
+type response struct {
+	Result json.RawMessage `json:"result"`
+	Error  string          `json:"error"`
+}
+
+// Call calls method with params, decoding its result into result if it isn't nil. It is for
+// the methods that don't have their own function, like ones specific to some relay software.
+func (c *Client) Call(ctx context.Context, method string, params []any, result any) error {
+	if params == nil {
+		params = []any{}
+	}
+	body, err := json.Marshal(request{Method: method, Params: params})
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	auth, err := nip98.Header(ctx, c.Signer, http.MethodPost, c.URL, body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ContentType)
+	req.Header.Set("Authorization", auth)
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var r response
+	if err := json.Unmarshal(data, &r); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("relay returned %s", resp.Status)
+		}
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	if r.Error != "" {
+		return &Error{Method: method, Message: r.Error}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("relay returned %s", resp.Status)
+	}
+	if result != nil {
+		if err := json.Unmarshal(r.Result, result); err != nil {
+			return fmt.Errorf("invalid result of %s: %w", method, err)
+		}
+	}
+	return nil
+}
+
+// PubKeyReason is a user the relay banned or allowed, and why.
+type PubKeyReason struct {
+	PubKey nostr.PubKey `json:"pubkey"`
+	Reason string       `json:"reason,omitempty"`
+}
+
+// IDReason is an event the relay banned or that needs moderation, and why.
+type IDReason struct {
+	ID     nostr.ID `json:"id"`
+	Reason string   `json:"reason,omitempty"`
+}
+
+// IPReason is an address the relay blocked, and why.
+type IPReason struct {
+	IP     string `json:"ip"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// SupportedMethods returns the methods the relay implements.
+func (c *Client) SupportedMethods(ctx context.Context) ([]string, error) {
+	var methods []string
+	err := c.Call(ctx, "supportedmethods", nil, &methods)
+	return methods, err
+}
+
+func (c *Client) BanPubKey(ctx context.Context, pk nostr.PubKey, reason string) error {
+	return c.Call(ctx, "banpubkey", []any{pk.Hex(), reason}, nil)
+}
+
+func (c *Client) ListBannedPubKeys(ctx context.Context) ([]PubKeyReason, error) {
+	var list []PubKeyReason
+	err := c.Call(ctx, "listbannedpubkeys", nil, &list)
+	return list, err
+}
+
+func (c *Client) AllowPubKey(ctx context.Context, pk nostr.PubKey, reason string) error {
+	return c.Call(ctx, "allowpubkey", []any{pk.Hex(), reason}, nil)
+}
+
+func (c *Client) ListAllowedPubKeys(ctx context.Context) ([]PubKeyReason, error) {
+	var list []PubKeyReason
+	err := c.Call(ctx, "listallowedpubkeys", nil, &list)
+	return list, err
+}
+
+func (c *Client) ListEventsNeedingModeration(ctx context.Context) ([]IDReason, error) {
+	var list []IDReason
+	err := c.Call(ctx, "listeventsneedingmoderation", nil, &list)
+	return list, err
+}
+
+func (c *Client) AllowEvent(ctx context.Context, id nostr.ID, reason string) error {
+	return c.Call(ctx, "allowevent", []any{id.Hex(), reason}, nil)
+}
+
+func (c *Client) BanEvent(ctx context.Context, id nostr.ID, reason string) error {
+	return c.Call(ctx, "banevent", []any{id.Hex(), reason}, nil)
+}
+
+func (c *Client) ListBannedEvents(ctx context.Context) ([]IDReason, error) {
+	var list []IDReason
+	err := c.Call(ctx, "listbannedevents", nil, &list)
+	return list, err
+}
+
+func (c *Client) ChangeRelayName(ctx context.Context, name string) error {
+	return c.Call(ctx, "changerelayname", []any{name}, nil)
+}
+
+func (c *Client) ChangeRelayDescription(ctx context.Context, description string) error {
+	return c.Call(ctx, "changerelaydescription", []any{description}, nil)
+}
+
+func (c *Client) ChangeRelayIcon(ctx context.Context, url string) error {
+	return c.Call(ctx, "changerelayicon", []any{url}, nil)
+}
+
+func (c *Client) AllowKind(ctx context.Context, kind int) error {
+	return c.Call(ctx, "allowkind", []any{kind}, nil)
+}
+
+func (c *Client) DisallowKind(ctx context.Context, kind int) error {
+	return c.Call(ctx, "disallowkind", []any{kind}, nil)
+}
+
+func (c *Client) ListAllowedKinds(ctx context.Context) ([]int, error) {
+	var kinds []int
+	err := c.Call(ctx, "listallowedkinds", nil, &kinds)
+	return kinds, err
+}
+
+func (c *Client) BlockIP(ctx context.Context, ip string, reason string) error {
+	return c.Call(ctx, "blockip", []any{ip, reason}, nil)
+}
+
+func (c *Client) UnblockIP(ctx context.Context, ip string) error {
+	return c.Call(ctx, "unblockip", []any{ip}, nil)
+}
+
+func (c *Client) ListBlockedIPs(ctx context.Context) ([]IPReason, error) {
+	var list []IPReason
+	err := c.Call(ctx, "listblockedips", nil, &list)
+	return list, err
+}
@@ -0,0 +1,92 @@
+package nip86
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/keyer"
+	"github.com/nbd-wtf/go-nostr/nip98"
+)
+
+func TestClient(t *testing.T) {
+	signer, err := keyer.NewPlainKeySigner(nostr.GeneratePrivateKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, _ := signer.GetPublicKey(context.Background())
+
+	banned := []PubKeyReason{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("Content-Type") != ContentType {
+			http.Error(w, "wrong content type", http.StatusBadRequest)
+			return
+		}
+		pk, err := nip98.Validate(r.Header.Get("Authorization"), r.Method, "http://"+r.Host, body)
+		if err != nil || pk != admin {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]any{"error": "unauthorized"})
+			return
+		}
+
+		var req struct {
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		json.Unmarshal(body, &req)
+		var result any
+		switch req.Method {
+		case "supportedmethods":
+			result = []string{"supportedmethods", "banpubkey", "listbannedpubkeys"}
+		case "banpubkey":
+			var entry PubKeyReason
+			json.Unmarshal(req.Params[0], &entry.PubKey)
+			json.Unmarshal(req.Params[1], &entry.Reason)
+			banned = append(banned, entry)
+			result = true
+		case "listbannedpubkeys":
+			result = banned
+		default:
+			json.NewEncoder(w).Encode(map[string]any{"error": "unsupported method"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"result": result})
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	client := NewClient("ws"+strings.TrimPrefix(ts.URL, "http"), signer)
+	if client.URL != ts.URL {
+		t.Fatalf("unexpected URL %s", client.URL)
+	}
+
+	methods, err := client.SupportedMethods(ctx)
+	if err != nil || len(methods) != 3 {
+		t.Fatalf("unexpected methods %v, %v", methods, err)
+	}
+	if err := client.BanPubKey(ctx, nostr.PubKey{1}, "spam"); err != nil {
+		t.Fatal(err)
+	}
+	list, err := client.ListBannedPubKeys(ctx)
+	if err != nil || len(list) != 1 || list[0].PubKey != (nostr.PubKey{1}) || list[0].Reason != "spam" {
+		t.Fatalf("unexpected banned list %v, %v", list, err)
+	}
+
+	var rpcErr *Error
+	if err := client.BlockIP(ctx, "10.0.0.1", ""); !errors.As(err, &rpcErr) || rpcErr.Message != "unsupported method" {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	other, _ := keyer.NewPlainKeySigner(nostr.GeneratePrivateKey())
+	client.Signer = other
+	if err := client.BanPubKey(ctx, nostr.PubKey{2}, ""); !errors.As(err, &rpcErr) || rpcErr.Message != "unauthorized" {
+		t.Fatalf("unexpected error for non-admin %v", err)
+	}
+}
@@ -0,0 +1,228 @@
+// Package nip88 implements polls, kind-1068 questions with options, and the kind-1018
+// responses that vote on them.
+// See https://github.com/nostr-protocol/nips/blob/master/88.md for details.
+package nip88
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	KindPoll     = 1068
+	KindResponse = 1018
+)
+
+type PollType string
+
+const (
+	SingleChoice   PollType = "singlechoice"
+	MultipleChoice PollType = "multiplechoice"
+)
+
+type Option struct {
+	ID    string
+	Label string
+}
+
+type Poll struct {
+	ID       nostr.ID // set by ParsePoll
+	Question string
+	Options  []Option
+	Type     PollType // SingleChoice if empty
+	Relays   []string // where responses should be published and counted from
+	EndsAt   time.Time
+}
+
+func ParsePoll(evt *nostr.Event) (Poll, error) {
+	if evt.Kind != KindPoll {
+		return Poll{}, fmt.Errorf("expected kind %d, got %d", KindPoll, evt.Kind)
+	}
+
+	poll := Poll{ID: evt.ID, Question: evt.Content, Type: SingleChoice}
+	seen := make(map[string]bool)
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "option":
+			if len(tag) < 3 || tag[1] == "" {
+				return poll, fmt.Errorf("invalid option %v", tag)
+			}
+			if seen[tag[1]] {
+				return poll, fmt.Errorf("repeated option '%s'", tag[1])
+			}
+			seen[tag[1]] = true
+			poll.Options = append(poll.Options, Option{ID: tag[1], Label: tag[2]})
+		case "relay":
+			poll.Relays = append(poll.Relays, tag[1])
+		case "polltype":
+			switch PollType(tag[1]) {
+			case SingleChoice, MultipleChoice:
+				poll.Type = PollType(tag[1])
+			default:
+				return poll, fmt.Errorf("invalid poll type '%s'", tag[1])
+			}
+		case "endsAt":
+			if ts, err := strconv.ParseInt(tag[1], 10, 64); err == nil {
+				poll.EndsAt = time.Unix(ts, 0)
+			}
+		}
+	}
+
+	if len(poll.Options) == 0 {
+		return poll, fmt.Errorf("poll has no options")
+	}
+	return poll, nil
+}
+
+// ToEvent returns the unsigned poll event.
+func (poll Poll) ToEvent() nostr.Event {
+	pollType := poll.Type
+	if pollType == "" {
+		pollType = SingleChoice
+	}
+
+	evt := nostr.Event{
+		Kind:      KindPoll,
+		CreatedAt: nostr.Now(),
+		Content:   poll.Question,
+		Tags:      make(nostr.Tags, 0, len(poll.Options)+len(poll.Relays)+2),
+	}
+	for _, option := range poll.Options {
+		evt.Tags = append(evt.Tags, nostr.Tag{"option", option.ID, option.Label})
+	}
+	for _, relay := range poll.Relays {
+		evt.Tags = append(evt.Tags, nostr.Tag{"relay", relay})
+	}
+	evt.Tags = append(evt.Tags, nostr.Tag{"polltype", string(pollType)})
+	if !poll.EndsAt.IsZero() {
+		evt.Tags = append(evt.Tags, nostr.Tag{"endsAt", strconv.FormatInt(poll.EndsAt.Unix(), 10)})
+	}
+	return evt
+}
+
+// Option returns the option with the given id, or nil.
+func (poll Poll) Option(id string) *Option {
+	for i, option := range poll.Options {
+		if option.ID == id {
+			return &poll.Options[i]
+		}
+	}
+	return nil
+}
+
+// Respond returns the unsigned response voting for the options with the given ids, only one
+// for single choice polls.
+func (poll Poll) Respond(optionIDs ...string) (nostr.Event, error) {
+	if len(optionIDs) == 0 {
+		return nostr.Event{}, fmt.Errorf("no options chosen")
+	}
+	if len(optionIDs) > 1 && poll.Type != MultipleChoice {
+		return nostr.Event{}, fmt.Errorf("only one option can be chosen in a single choice poll")
+	}
+
+	evt := nostr.Event{
+		Kind:      KindResponse,
+		CreatedAt: nostr.Now(),
+		Tags:      nostr.Tags{{"e", poll.ID.Hex()}},
+	}
+	for _, id := range optionIDs {
+		if poll.Option(id) == nil {
+			return nostr.Event{}, fmt.Errorf("poll has no option '%s'", id)
+		}
+		evt.Tags = append(evt.Tags, nostr.Tag{"response", id})
+	}
+	return evt, nil
+}
+
+type Response struct {
+	Poll      nostr.ID
+	PubKey    nostr.PubKey
+	CreatedAt time.Time
+	Options   []string // ids of the options chosen
+}
+
+func ParseResponse(evt *nostr.Event) (Response, error) {
+	if evt.Kind != KindResponse {
+		return Response{}, fmt.Errorf("expected kind %d, got %d", KindResponse, evt.Kind)
+	}
+	e := evt.Tags.GetFirst([]string{"e", ""})
+	if e == nil {
+		return Response{}, fmt.Errorf("missing 'e' tag")
+	}
+	poll, err := nostr.IDFromHex(e.Value())
+	if err != nil {
+		return Response{}, fmt.Errorf("invalid poll id '%s': %w", e.Value(), err)
+	}
+
+	response := Response{Poll: poll, PubKey: evt.PubKey, CreatedAt: evt.CreatedAt}
+	for _, tag := range evt.Tags.GetAll([]string{"response", ""}) {
+		response.Options = append(response.Options, tag.Value())
+	}
+	return response, nil
+}
+
+// Results are the votes a poll got.
+type Results struct {
+	Votes  map[string]int // by option id
+	Voters int
+}
+
+// Tally counts the responses to poll, one vote per public key: only the latest response of
+// each counts, and only its first option in single choice polls. Responses to other polls,
+// that can't be read or, if the poll has an end, that came after it are ignored, as are
+// options the poll doesn't have.
+func Tally(poll Poll, responses []*nostr.Event) Results {
+	latest := make(map[nostr.PubKey]*nostr.Event)
+	for _, evt := range responses {
+		if evt.Kind != KindResponse || evt.Tags.GetFirst([]string{"e", poll.ID.Hex()}) == nil {
+			continue
+		}
+		if !poll.EndsAt.IsZero() && evt.CreatedAt.After(poll.EndsAt) {
+			continue
+		}
+		if current, ok := latest[evt.PubKey]; !ok || evt.IsNewerThan(current) {
+			latest[evt.PubKey] = evt
+		}
+	}
+
+	results := Results{Votes: make(map[string]int, len(poll.Options))}
+	for _, evt := range latest {
+		response, err := ParseResponse(evt)
+		if err != nil {
+			continue
+		}
+		counted := make(map[string]bool, len(response.Options))
+		for _, id := range response.Options {
+			if counted[id] || poll.Option(id) == nil {
+				continue
+			}
+			counted[id] = true
+			results.Votes[id]++
+			if poll.Type != MultipleChoice {
+				break
+			}
+		}
+		if len(counted) > 0 {
+			results.Voters++
+		}
+	}
+	return results
+}
+
+// Filter returns a filter for the responses to poll, to be used on its relays.
+func Filter(poll Poll) nostr.Filter {
+	filter := nostr.Filter{
+		Kinds: []int{KindResponse},
+		Tags:  nostr.TagMap{"e": []string{poll.ID.Hex()}},
+	}
+	if !poll.EndsAt.IsZero() {
+		filter.Until = &poll.EndsAt
+	}
+	return filter
+}
@@ -0,0 +1,69 @@
+package nip88
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestPollTally(t *testing.T) {
+	poll := Poll{
+		Question: "best relay software?",
+		Options:  []Option{{"a", "strfry"}, {"b", "khatru"}, {"c", "nostr-rs-relay"}},
+		Relays:   []string{"wss://relay.example.com"},
+		EndsAt:   time.Unix(1700000000, 0),
+	}
+	evt := poll.ToEvent()
+	evt.ID = evt.GetID()
+	parsed, err := ParsePoll(&evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Type != SingleChoice || len(parsed.Options) != 3 || parsed.Option("b").Label != "khatru" ||
+		!parsed.EndsAt.Equal(poll.EndsAt) || parsed.Relays[0] != "wss://relay.example.com" {
+		t.Fatalf("unexpected poll: %+v", parsed)
+	}
+	if _, err := parsed.Respond("a", "b"); err == nil {
+		t.Fatalf("two options chosen in a single choice poll")
+	}
+	if _, err := parsed.Respond("z"); err == nil {
+		t.Fatalf("unknown option chosen")
+	}
+
+	response := func(voter byte, at int64, options ...string) *nostr.Event {
+		evt, err := parsed.Respond(options[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, option := range options[1:] {
+			evt.Tags = append(evt.Tags, nostr.Tag{"response", option})
+		}
+		evt.PubKey = nostr.PubKey{voter}
+		evt.CreatedAt = time.Unix(at, 0)
+		evt.ID = evt.GetID()
+		return &evt
+	}
+	responses := []*nostr.Event{
+		response(1, 1690000000, "a"),
+		response(1, 1690000010, "b"), // changed their mind
+		response(2, 1690000000, "b", "c"),
+		response(3, 1690000000, "c"),
+		response(4, 1800000000, "a"), // too late
+	}
+	results := Tally(parsed, responses)
+	if results.Voters != 3 || results.Votes["a"] != 0 || results.Votes["b"] != 2 || results.Votes["c"] != 1 {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+
+	parsed.Type = MultipleChoice
+	results = Tally(parsed, responses)
+	if results.Voters != 3 || results.Votes["b"] != 2 || results.Votes["c"] != 2 {
+		t.Fatalf("unexpected multiple choice results: %+v", results)
+	}
+
+	r, err := ParseResponse(responses[2])
+	if err != nil || r.Poll != parsed.ID || len(r.Options) != 2 {
+		t.Fatalf("unexpected response %+v, %v", r, err)
+	}
+}
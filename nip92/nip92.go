@@ -0,0 +1,127 @@
+// Package nip92 implements "imeta" tags, which describe media files linked from an event:
+// their type, hash, dimensions, thumbnails and so on.
+// See https://github.com/nostr-protocol/nips/blob/master/92.md for details.
+package nip92
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// IMeta is the metadata of one media file. Only URL is required.
+type IMeta struct {
+	URL      string
+	MimeType string // "m"
+	SHA256   string // "x"
+	Width    int    // from "dim", as <width>x<height>
+	Height   int
+	Size     int64 // bytes
+	Blurhash string
+	Alt      string
+
+	Image    []string // preview images or thumbnails
+	Fallback []string // other URLs for the same file
+
+	Duration float64 // seconds, for audio and video
+	Bitrate  int     // bits per second, for audio and video
+}
+
+// Parse reads an "imeta" tag, where each item is "<key> <value>".
+// Unknown keys are ignored.
+func Parse(tag nostr.Tag) (IMeta, error) {
+	if len(tag) < 2 || tag[0] != "imeta" {
+		return IMeta{}, fmt.Errorf("not an imeta tag")
+	}
+
+	var m IMeta
+	for _, item := range tag[1:] {
+		key, value, ok := strings.Cut(item, " ")
+		if !ok {
+			continue
+		}
+		var err error
+		switch key {
+		case "url":
+			m.URL = value
+		case "m":
+			m.MimeType = value
+		case "x":
+			m.SHA256 = value
+		case "dim":
+			w, h, _ := strings.Cut(value, "x")
+			if m.Width, err = strconv.Atoi(w); err == nil {
+				m.Height, err = strconv.Atoi(h)
+			}
+		case "size":
+			m.Size, err = strconv.ParseInt(value, 10, 64)
+		case "blurhash":
+			m.Blurhash = value
+		case "alt":
+			m.Alt = value
+		case "image", "thumb":
+			m.Image = append(m.Image, value)
+		case "fallback":
+			m.Fallback = append(m.Fallback, value)
+		case "duration":
+			m.Duration, err = strconv.ParseFloat(value, 64)
+		case "bitrate":
+			m.Bitrate, err = strconv.Atoi(value)
+		}
+		if err != nil {
+			return m, fmt.Errorf("invalid '%s' in imeta: %s", key, value)
+		}
+	}
+
+	if m.URL == "" {
+		return m, fmt.Errorf("imeta without url")
+	}
+	return m, nil
+}
+
+// ParseAll returns the valid "imeta" tags in tags.
+func ParseAll(tags nostr.Tags) []IMeta {
+	var result []IMeta
+	for _, tag := range tags.GetAll([]string{"imeta", ""}) {
+		if m, err := Parse(tag); err == nil {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// Tag returns the "imeta" tag, with only the fields that are set.
+func (m IMeta) Tag() nostr.Tag {
+	tag := nostr.Tag{"imeta", "url " + m.URL}
+	add := func(key, value string) {
+		if value != "" {
+			tag = append(tag, key+" "+value)
+		}
+	}
+
+	add("m", m.MimeType)
+	add("x", m.SHA256)
+	if m.Width > 0 && m.Height > 0 {
+		add("dim", fmt.Sprintf("%dx%d", m.Width, m.Height))
+	}
+	if m.Size > 0 {
+		add("size", strconv.FormatInt(m.Size, 10))
+	}
+	add("blurhash", m.Blurhash)
+	add("alt", m.Alt)
+	for _, image := range m.Image {
+		add("image", image)
+	}
+	for _, fallback := range m.Fallback {
+		add("fallback", fallback)
+	}
+	if m.Duration > 0 {
+		add("duration", strconv.FormatFloat(m.Duration, 'f', -1, 64))
+	}
+	if m.Bitrate > 0 {
+		add("bitrate", strconv.Itoa(m.Bitrate))
+	}
+	return tag
+}
@@ -0,0 +1,45 @@
+package nip92
+
+import (
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestIMetaRoundTrip(t *testing.T) {
+	tag := nostr.Tag{
+		"imeta",
+		"url https://nostr.build/i/my-image.jpg",
+		"m image/jpeg",
+		"blurhash eVF$^OI:${M{o#*0-nNFxakD-?xVM}WEWB%iNKxvR-oetmo#R-aen$",
+		"dim 3024x4032",
+		"alt A scenic photo overlooking the coast of Costa Rica",
+		"x 39c9a6b39c8f4f5b6c1f14b2e2d0c9a0c4a5f3c2e9b4c7a1b3c4d5e6f7a8b9c0",
+		"fallback https://nostrcheck.me/alt1.jpg",
+		"fallback https://void.cat/alt1.jpg",
+		"unknown whatever",
+	}
+
+	m, err := Parse(tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Width != 3024 || m.Height != 4032 || m.MimeType != "image/jpeg" || len(m.Fallback) != 2 {
+		t.Fatalf("unexpected imeta: %+v", m)
+	}
+
+	again, err := Parse(m.Tag())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.URL != m.URL || again.Blurhash != m.Blurhash || again.Alt != m.Alt || len(again.Fallback) != 2 {
+		t.Fatalf("round trip changed imeta: %+v", again)
+	}
+
+	if _, err := Parse(nostr.Tag{"imeta", "m image/jpeg"}); err == nil {
+		t.Fatal("imeta without url accepted")
+	}
+	if _, err := Parse(nostr.Tag{"imeta", "url https://x.com/a.jpg", "dim big"}); err == nil {
+		t.Fatal("invalid dim accepted")
+	}
+}
@@ -0,0 +1,86 @@
+// Package nip98 implements HTTP auth: requests carry a signed kind-27235 event in their
+// Authorization header, saying which URL and method they are for, so HTTP services can know
+// which Nostr identity made them.
+// See https://github.com/nostr-protocol/nips/blob/master/98.md for details.
+package nip98
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const KindHTTPAuth = 27235
+
+// MaxAge is how far the created_at of an auth event may be from the time it is validated.
+var MaxAge = time.Minute
+
+// Header returns the Authorization header value for a request to url with method, signed by
+// signer. payload is the body of the request, nil if it has none.
+func Header(ctx context.Context, signer nostr.Signer, method, url string, payload []byte) (string, error) {
+	evt := nostr.Event{
+		Kind:      KindHTTPAuth,
+		CreatedAt: nostr.Now(),
+		Tags:      nostr.Tags{{"u", url}, {"method", strings.ToUpper(method)}},
+	}
+	if len(payload) > 0 {
+		hash := sha256.Sum256(payload)
+		evt.Tags = append(evt.Tags, nostr.Tag{"payload", hex.EncodeToString(hash[:])})
+	}
+	if err := signer.SignEvent(ctx, &evt); err != nil {
+		return "", fmt.Errorf("failed to sign auth event: %w", err)
+	}
+
+	j, err := evt.MarshalJSON()
+	if err != nil {
+		return "", err
+	}
+	return "Nostr " + base64.StdEncoding.EncodeToString(j), nil
+}
+
+// Validate checks the Authorization header of a request to url with method and body payload,
+// returning who signed it. Requests with a body must have its hash in the auth event.
+func Validate(header, method, url string, payload []byte) (nostr.PubKey, error) {
+	if !strings.HasPrefix(header, "Nostr ") {
+		return nostr.PubKey{}, fmt.Errorf("authorization is not of the Nostr scheme")
+	}
+	j, err := base64.StdEncoding.DecodeString(strings.TrimSpace(header[len("Nostr "):]))
+	if err != nil {
+		return nostr.PubKey{}, fmt.Errorf("invalid base64 in authorization: %w", err)
+	}
+	var evt nostr.Event
+	if err := evt.UnmarshalJSON(j); err != nil {
+		return nostr.PubKey{}, fmt.Errorf("invalid auth event: %w", err)
+	}
+
+	if evt.Kind != KindHTTPAuth {
+		return nostr.PubKey{}, fmt.Errorf("auth event is of kind %d", evt.Kind)
+	}
+	if age := nostr.Now().Sub(evt.CreatedAt); age > MaxAge || age < -MaxAge {
+		return nostr.PubKey{}, fmt.Errorf("auth event is too old or in the future")
+	}
+	if u := evt.Tags.GetFirst([]string{"u", ""}); u == nil || u.Value() != url {
+		return nostr.PubKey{}, fmt.Errorf("auth event is not for %s", url)
+	}
+	if m := evt.Tags.GetFirst([]string{"method", ""}); m == nil || !strings.EqualFold(m.Value(), method) {
+		return nostr.PubKey{}, fmt.Errorf("auth event is not for %s", method)
+	}
+	if p := evt.Tags.GetFirst([]string{"payload", ""}); p != nil {
+		hash := sha256.Sum256(payload)
+		if p.Value() != hex.EncodeToString(hash[:]) {
+			return nostr.PubKey{}, fmt.Errorf("auth event is for another payload")
+		}
+	} else if len(payload) > 0 {
+		return nostr.PubKey{}, fmt.Errorf("auth event has no payload hash")
+	}
+	if ok, err := evt.CheckSignature(); err != nil || !ok {
+		return nostr.PubKey{}, fmt.Errorf("auth event has an invalid signature")
+	}
+	return evt.PubKey, nil
+}
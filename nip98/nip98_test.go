@@ -0,0 +1,54 @@
+package nip98
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/keyer"
+)
+
+func TestHeader(t *testing.T) {
+	signer, err := keyer.NewPlainKeySigner(nostr.GeneratePrivateKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, _ := signer.GetPublicKey(context.Background())
+
+	url := "https://api.example.com/upload"
+	payload := []byte(`{"name":"cat.jpg"}`)
+	header, err := Header(context.Background(), signer, "post", url, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Validate(header, "POST", url, payload); err != nil || got != pk {
+		t.Fatalf("validated as %s, %v", got, err)
+	}
+
+	noPayload, err := Header(context.Background(), signer, "post", url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Validate(noPayload, "POST", url, nil); err != nil {
+		t.Fatalf("request without a body rejected: %s", err)
+	}
+
+	for name, check := range map[string]func() error{
+		"url":     func() error { _, err := Validate(header, "POST", "https://api.example.com/other", payload); return err },
+		"method":  func() error { _, err := Validate(header, "PUT", url, payload); return err },
+		"payload": func() error { _, err := Validate(header, "POST", url, []byte("{}")); return err },
+		"scheme":  func() error { _, err := Validate("Bearer abc", "POST", url, payload); return err },
+		"no hash": func() error { _, err := Validate(noPayload, "POST", url, payload); return err },
+	} {
+		if check() == nil {
+			t.Errorf("wrong %s accepted", name)
+		}
+	}
+
+	defer nostr.SetClock(nil)
+	nostr.SetClock(nostr.SkewedClock(2 * time.Minute))
+	if _, err := Validate(header, "POST", url, payload); err == nil {
+		t.Fatalf("old auth event accepted")
+	}
+}
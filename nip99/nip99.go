@@ -0,0 +1,158 @@
+// Package nip99 implements classified listings, kind 30402, and their drafts, kind 30403.
+// See https://github.com/nostr-protocol/nips/blob/master/99.md for details.
+package nip99
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	KindClassifiedListing      = 30402
+	KindDraftClassifiedListing = 30403
+)
+
+type Status string
+
+const (
+	StatusActive Status = "active"
+	StatusSold   Status = "sold"
+)
+
+// Price is given as a string so amounts don't lose precision. Frequency is optional, for
+// recurring payments like rent, and is "hour", "day", "week", "month" or "year".
+type Price struct {
+	Amount    string
+	Currency  string // ISO 4217, or a crypto ticker like "btc" or "sat"
+	Frequency string
+}
+
+type Image struct {
+	URL        string
+	Dimensions string // optional, like "256x256"
+}
+
+// Listing is a classified listing. Content is markdown with the full description.
+type Listing struct {
+	ID          string // the "d" tag
+	Draft       bool   // kind 30403
+	Title       string
+	Summary     string
+	Content     string
+	PublishedAt time.Time
+	Location    string
+	Geohash     string
+	Price       *Price
+	Status      Status
+	Images      []Image
+	Hashtags    []string
+}
+
+// ParseListing reads a kind-30402 or kind-30403 event.
+func ParseListing(evt *nostr.Event) (Listing, error) {
+	if evt.Kind != KindClassifiedListing && evt.Kind != KindDraftClassifiedListing {
+		return Listing{}, fmt.Errorf("kind %d is not a classified listing", evt.Kind)
+	}
+
+	listing := Listing{
+		Draft:   evt.Kind == KindDraftClassifiedListing,
+		Content: evt.Content,
+		Status:  StatusActive,
+	}
+	for _, tag := range evt.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "d":
+			listing.ID = tag[1]
+		case "title":
+			listing.Title = tag[1]
+		case "summary":
+			listing.Summary = tag[1]
+		case "published_at":
+			if ts, err := strconv.ParseInt(tag[1], 10, 64); err == nil {
+				listing.PublishedAt = time.Unix(ts, 0)
+			}
+		case "location":
+			listing.Location = tag[1]
+		case "g":
+			listing.Geohash = tag[1]
+		case "status":
+			listing.Status = Status(tag[1])
+		case "t":
+			listing.Hashtags = append(listing.Hashtags, tag[1])
+		case "image":
+			image := Image{URL: tag[1]}
+			if len(tag) > 2 {
+				image.Dimensions = tag[2]
+			}
+			listing.Images = append(listing.Images, image)
+		case "price":
+			if len(tag) < 3 {
+				return listing, fmt.Errorf("price without currency")
+			}
+			if _, err := strconv.ParseFloat(tag[1], 64); err != nil {
+				return listing, fmt.Errorf("invalid price '%s'", tag[1])
+			}
+			listing.Price = &Price{Amount: tag[1], Currency: tag[2]}
+			if len(tag) > 3 {
+				listing.Price.Frequency = tag[3]
+			}
+		}
+	}
+
+	if listing.ID == "" {
+		return listing, fmt.Errorf("missing 'd' tag")
+	}
+	return listing, nil
+}
+
+// ToEvent returns the unsigned listing event, a draft if Draft is set.
+func (listing Listing) ToEvent() nostr.Event {
+	evt := nostr.Event{
+		Kind:      KindClassifiedListing,
+		CreatedAt: nostr.Now(),
+		Content:   listing.Content,
+		Tags:      nostr.Tags{{"d", listing.ID}},
+	}
+	if listing.Draft {
+		evt.Kind = KindDraftClassifiedListing
+	}
+
+	for _, field := range []struct{ name, value string }{
+		{"title", listing.Title},
+		{"summary", listing.Summary},
+		{"location", listing.Location},
+		{"g", listing.Geohash},
+		{"status", string(listing.Status)},
+	} {
+		if field.value != "" {
+			evt.Tags = append(evt.Tags, nostr.Tag{field.name, field.value})
+		}
+	}
+	if !listing.PublishedAt.IsZero() {
+		evt.Tags = append(evt.Tags, nostr.Tag{"published_at", strconv.FormatInt(listing.PublishedAt.Unix(), 10)})
+	}
+	if listing.Price != nil {
+		tag := nostr.Tag{"price", listing.Price.Amount, listing.Price.Currency}
+		if listing.Price.Frequency != "" {
+			tag = append(tag, listing.Price.Frequency)
+		}
+		evt.Tags = append(evt.Tags, tag)
+	}
+	for _, image := range listing.Images {
+		tag := nostr.Tag{"image", image.URL}
+		if image.Dimensions != "" {
+			tag = append(tag, image.Dimensions)
+		}
+		evt.Tags = append(evt.Tags, tag)
+	}
+	for _, t := range listing.Hashtags {
+		evt.Tags = append(evt.Tags, nostr.Tag{"t", t})
+	}
+	return evt
+}
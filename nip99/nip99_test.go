@@ -0,0 +1,44 @@
+package nip99
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestListingRoundTrip(t *testing.T) {
+	listing := Listing{
+		ID:          "lorem-ipsum",
+		Title:       "Lorem Ipsum",
+		Summary:     "More lorem ipsum that is a little more than the title",
+		Content:     "Lorem [ipsum][nostr:nevent1...] dolor sit amet",
+		PublishedAt: time.Unix(1296962229, 0),
+		Location:    "NYC",
+		Price:       &Price{Amount: "100", Currency: "USD", Frequency: "month"},
+		Images:      []Image{{URL: "https://url.to.img", Dimensions: "256x256"}},
+		Hashtags:    []string{"electronics"},
+	}
+
+	evt := listing.ToEvent()
+	parsed, err := ParseListing(&evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Draft || parsed.Title != listing.Title || parsed.Status != StatusActive ||
+		*parsed.Price != *listing.Price || len(parsed.Images) != 1 || parsed.Images[0] != listing.Images[0] ||
+		!parsed.PublishedAt.Equal(listing.PublishedAt) {
+		t.Fatalf("unexpected listing: %+v", parsed)
+	}
+
+	listing.Draft = true
+	evt = listing.ToEvent()
+	if parsed, _ := ParseListing(&evt); evt.Kind != KindDraftClassifiedListing || !parsed.Draft {
+		t.Fatal("draft wasn't kept")
+	}
+
+	evt = nostr.Event{Kind: KindClassifiedListing, Tags: nostr.Tags{{"d", "x"}, {"price", "cheap", "USD"}}}
+	if _, err := ParseListing(&evt); err == nil {
+		t.Fatal("invalid price accepted")
+	}
+}
@@ -1,34 +1,100 @@
 package nostr
 
 import (
+	"net"
 	"net/url"
 	"strings"
+
+	"golang.org/x/net/idna"
 )
 
 // NormalizeURL normalizes the url and replaces http://, https:// schemes by ws://, wss://.
+// Bare domains get wss://, or ws:// for localhost and private addresses. Hosts are lowercased
+// and internationalized ones converted to punycode, default ports, fragments and trailing
+// slashes are removed. It returns "" for invalid URLs and other schemes.
 func NormalizeURL(u string) string {
+	u = strings.TrimSpace(u)
 	if u == "" {
 		return ""
 	}
 
-	u = strings.TrimSpace(u)
-	u = strings.ToLower(u)
-
-	if !strings.HasPrefix(u, "http") && !strings.HasPrefix(u, "ws") {
-		u = "wss://" + u
+	if !strings.Contains(u, "://") {
+		scheme := "wss://"
+		if p, err := url.Parse("ws://" + u); err == nil && isLocalHost(p.Hostname()) {
+			scheme = "ws://"
+		}
+		u = scheme + u
 	}
 	p, err := url.Parse(u)
-	if err != nil {
+	if err != nil || p.Host == "" {
 		return ""
 	}
 
-	if p.Scheme == "http" {
+	switch strings.ToLower(p.Scheme) {
+	case "ws", "http":
 		p.Scheme = "ws"
-	} else if p.Scheme == "https" {
+	case "wss", "https":
 		p.Scheme = "wss"
+	default:
+		return ""
+	}
+
+	host, port := p.Hostname(), p.Port()
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "" {
+		return ""
+	}
+	if ascii, ok := toASCII(host); ok {
+		host = ascii
+	} else {
+		return ""
+	}
+	if (p.Scheme == "ws" && port == "80") || (p.Scheme == "wss" && port == "443") {
+		port = ""
 	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	p.Host = host
 
+	p.User = nil
+	p.Fragment = ""
+	p.RawFragment = ""
 	p.Path = strings.TrimRight(p.Path, "/")
+	p.RawPath = ""
 
 	return p.String()
 }
+
+// SameRelay tells if a and b are the same relay written differently.
+func SameRelay(a, b string) bool {
+	na := NormalizeURL(a)
+	return na != "" && na == NormalizeURL(b)
+}
+
+// isLocalHost tells if host is this machine or in a private network, where relays usually
+// don't have TLS.
+func isLocalHost(host string) bool {
+	host = strings.ToLower(host)
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") || strings.HasSuffix(host, ".local") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && (ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast())
+}
+
+// toASCII converts host to the form used in DNS lookups, with the labels that aren't ASCII in
+// punycode, it fails for names that can't be a domain. IP addresses are left as they are.
+func toASCII(host string) (string, bool) {
+	if net.ParseIP(host) != nil {
+		return host, true
+	}
+	ascii, err := idna.Lookup.ToASCII(host)
+	if err != nil {
+		return "", false
+	}
+	return ascii, true
+}
@@ -1,6 +1,9 @@
 package nostr
 
-import "fmt"
+import (
+	"fmt"
+	"testing"
+)
 
 func ExampleNormalizeURL() {
 	fmt.Println(NormalizeURL(""))
@@ -15,6 +18,14 @@ func ExampleNormalizeURL() {
 	fmt.Println(NormalizeURL("x.com/"))
 	fmt.Println(NormalizeURL("x.com////"))
 	fmt.Println(NormalizeURL("x.com/?x=23"))
+	fmt.Println(NormalizeURL("  WSS://Relay.X.com:443/Path/ "))
+	fmt.Println(NormalizeURL("ws://x.com:80#top"))
+	fmt.Println(NormalizeURL("wss://x.com:7777"))
+	fmt.Println(NormalizeURL("localhost:7777"))
+	fmt.Println(NormalizeURL("192.168.0.10:4869/"))
+	fmt.Println(NormalizeURL("wss.x.com"))
+	fmt.Println(NormalizeURL("münchen.de"))
+	fmt.Println(NormalizeURL("ftp://x.com"))
 
 	// Output:
 	//
@@ -29,4 +40,35 @@ func ExampleNormalizeURL() {
 	// wss://x.com
 	// wss://x.com
 	// wss://x.com?x=23
+	// wss://relay.x.com/Path
+	// ws://x.com
+	// wss://x.com:7777
+	// ws://localhost:7777
+	// ws://192.168.0.10:4869
+	// wss://wss.x.com
+	// wss://xn--mnchen-3ya.de
+	//
+}
+
+func TestSameRelay(t *testing.T) {
+	for _, pair := range [][2]string{
+		{"wss://relay.x.com", "relay.x.com/"},
+		{"wss://relay.x.com", "https://RELAY.x.com:443"},
+		{"ws://localhost:7777", "localhost:7777"},
+		{"wss://bücher.example", "wss://xn--bcher-kva.example"},
+	} {
+		if !SameRelay(pair[0], pair[1]) {
+			t.Errorf("%s and %s should be the same relay", pair[0], pair[1])
+		}
+	}
+	for _, pair := range [][2]string{
+		{"wss://relay.x.com", "ws://relay.x.com"},
+		{"wss://relay.x.com", "wss://relay.x.com:4443"},
+		{"wss://x.com/a", "wss://x.com/b"},
+		{"", ""},
+	} {
+		if SameRelay(pair[0], pair[1]) {
+			t.Errorf("%s and %s shouldn't be the same relay", pair[0], pair[1])
+		}
+	}
 }
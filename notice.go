@@ -0,0 +1,92 @@
+package nostr
+
+import (
+	"errors"
+	"strings"
+)
+
+// Machine-readable prefixes relays start "NOTICE", "OK" and "CLOSED" messages with, as in NIP-01.
+const (
+	NoticeDuplicate    = "duplicate"
+	NoticePow          = "pow"
+	NoticeBlocked      = "blocked"
+	NoticeRateLimited  = "rate-limited"
+	NoticeInvalid      = "invalid"
+	NoticeRestricted   = "restricted"
+	NoticeMute         = "mute"
+	NoticeError        = "error"
+	NoticeAuthRequired = "auth-required"
+
+	NoticePaymentRequired = "payment-required"
+)
+
+var noticePrefixes = []string{
+	NoticeDuplicate, NoticePow, NoticeBlocked, NoticeRateLimited, NoticeInvalid,
+	NoticeRestricted, NoticeMute, NoticeError, NoticeAuthRequired, NoticePaymentRequired,
+}
+
+// how many notices are kept in the Notices channel of a relay while nobody reads it, newer ones
+// are dropped once it is full
+const noticesBuffer = 32
+
+// Notice is a message from a relay split into its machine-readable prefix, like
+// NoticeRateLimited, and the part meant for humans. Prefix is empty if it has none we know.
+type Notice struct {
+	Prefix  string
+	Message string
+}
+
+// ParseNotice splits a "NOTICE", or the reason of an "OK" or "CLOSED", into a Notice. Prefixes
+// are matched regardless of case, as some relays send "ERROR: ...".
+func ParseNotice(notice string) Notice {
+	if prefix, message, ok := strings.Cut(notice, ":"); ok {
+		prefix = strings.ToLower(strings.TrimSpace(prefix))
+		for _, known := range noticePrefixes {
+			if prefix == known {
+				return Notice{Prefix: known, Message: strings.TrimSpace(message)}
+			}
+		}
+	}
+	return Notice{Message: strings.TrimSpace(notice)}
+}
+
+func (n Notice) String() string {
+	if n.Prefix == "" {
+		return n.Message
+	}
+	return n.Prefix + ": " + n.Message
+}
+
+// RejectionError is returned when a relay answers an "EVENT" or an "AUTH" with a failed "OK",
+// Reason is the message it came with.
+type RejectionError struct {
+	Reason string
+}
+
+func (e *RejectionError) Error() string { return "msg: " + e.Reason }
+
+// Notice parses the reason the relay gave.
+func (e *RejectionError) Notice() Notice { return ParseNotice(e.Reason) }
+
+// rejection returns the parsed reason if err, or an error it wraps, is a RejectionError.
+func rejection(err error) (Notice, bool) {
+	var rejected *RejectionError
+	if !errors.As(err, &rejected) {
+		return Notice{}, false
+	}
+	return rejected.Notice(), true
+}
+
+// rejectedWith tells if err is a RejectionError whose reason has one of the given prefixes.
+func rejectedWith(err error, prefixes ...string) bool {
+	notice, ok := rejection(err)
+	if !ok {
+		return false
+	}
+	for _, prefix := range prefixes {
+		if notice.Prefix == prefix {
+			return true
+		}
+	}
+	return false
+}
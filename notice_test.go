@@ -0,0 +1,89 @@
+package nostr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestParseNotice(t *testing.T) {
+	for notice, expected := range map[string]Notice{
+		"rate-limited: slow down":        {NoticeRateLimited, "slow down"},
+		"ERROR: bad message":             {NoticeError, "bad message"},
+		"auth-required:":                 {NoticeAuthRequired, ""},
+		"welcome to the relay":           {"", "welcome to the relay"},
+		"note: this isn't a prefix":      {"", "note: this isn't a prefix"},
+		"  blocked: you are banned  ":    {NoticeBlocked, "you are banned"},
+		"invalid: id is wrong: it isn't": {NoticeInvalid, "id is wrong: it isn't"},
+	} {
+		if got := ParseNotice(notice); got != expected {
+			t.Errorf("%q parsed as %+v", notice, got)
+		}
+	}
+	if s := (Notice{NoticePow, "difficulty 20"}).String(); s != "pow: difficulty 20" {
+		t.Errorf("got %q", s)
+	}
+}
+
+func TestRejectionError(t *testing.T) {
+	err := fmt.Errorf("wss://relay.example.com: %w", &RejectionError{Reason: "Rate-Limited: slow down"})
+	if !rejectedWith(err, NoticeError, NoticeRateLimited) || rejectedWith(err, NoticeAuthRequired) {
+		t.Errorf("wrong prefix for %v", err)
+	}
+	if rejectedWith(fmt.Errorf("rate-limited: not from a relay"), NoticeRateLimited) {
+		t.Error("plain error taken as a rejection")
+	}
+}
+
+func TestNoticesDontBlock(t *testing.T) {
+	ws := newWebsocketServer(func(conn *websocket.Conn) {
+		for i := 0; i < noticesBuffer*2; i++ {
+			websocket.JSON.Send(conn, []any{"NOTICE", fmt.Sprint(i)})
+		}
+		for {
+			var raw []json.RawMessage
+			if err := websocket.JSON.Receive(conn, &raw); err != nil {
+				return
+			}
+			var id string
+			json.Unmarshal(raw[1], &id)
+			websocket.JSON.Send(conn, []any{"EOSE", id})
+		}
+	})
+	defer ws.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	relay, err := RelayConnect(ctx, ws.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer relay.Close()
+
+	// nobody reads the notices, but the relay goes on
+	sub, err := relay.Sub(ctx, Filters{{Kinds: []int{1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-sub.EndOfStoredEvents:
+	case <-ctx.Done():
+		t.Fatal("no EOSE")
+	}
+
+	// the first ones are kept, in order
+	for i := 0; i < noticesBuffer; i++ {
+		if notice := <-relay.Notices; notice != fmt.Sprint(i) {
+			t.Fatalf("got notice %q instead of %d", notice, i)
+		}
+	}
+	select {
+	case notice := <-relay.Notices:
+		t.Fatalf("notice %q wasn't dropped", notice)
+	default:
+	}
+}
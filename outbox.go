@@ -0,0 +1,445 @@
+package nostr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// OutboxEntry is an event waiting in an Outbox to be taken by some relays.
+type OutboxEntry struct {
+	Seq      uint64    `json:"seq"` // entries are sent to each relay in this order
+	Event    Event     `json:"event"`
+	QueuedAt time.Time `json:"queued_at"`
+
+	Pending  []string          `json:"pending"` // relays still to take it
+	Accepted []string          `json:"accepted,omitempty"`
+	Rejected map[string]string `json:"rejected,omitempty"` // relay to the reason it gave
+}
+
+// OutboxStore keeps the entries of an Outbox, see MemoryOutboxStore and FileOutboxStore.
+type OutboxStore interface {
+	// LoadEntries returns all the entries saved, in any order.
+	LoadEntries() ([]OutboxEntry, error)
+	// SaveEntry adds an entry or replaces the one with the same Seq.
+	SaveEntry(entry OutboxEntry) error
+	DeleteEntry(seq uint64) error
+}
+
+var (
+	_ OutboxStore = (*MemoryOutboxStore)(nil)
+	_ OutboxStore = (*FileOutboxStore)(nil)
+)
+
+// Outbox queues events for relays that can't be reached now and keeps trying to send them,
+// even across restarts if its store is persistent. Each relay gets the events in the order
+// they were queued: an event is only sent to a relay after the ones queued before it were
+// taken or rejected there. Events rejected by a relay aren't sent to it again.
+type Outbox struct {
+	Pool  *SimplePool
+	Store OutboxStore
+
+	// Backoff is how long to wait before trying a relay again after failing to reach it,
+	// counting the failures in a row from 0. DefaultBackoff is used if it is nil.
+	Backoff Backoff
+
+	// OnDone, if set, is called with each entry once no relay is pending for it anymore.
+	OnDone func(entry OutboxEntry)
+
+	mutex   sync.Mutex
+	entries []OutboxEntry // ordered by Seq
+	nextSeq uint64
+	relays  map[string]*outboxRelay
+	wake    chan struct{}
+}
+
+type outboxRelay struct {
+	failures    int
+	nextAttempt time.Time
+	flushing    bool
+}
+
+// NewOutbox loads the entries saved in store, which can be nil to keep them only in memory.
+func NewOutbox(pool *SimplePool, store OutboxStore) (*Outbox, error) {
+	if store == nil {
+		store = NewMemoryOutboxStore()
+	}
+	entries, err := store.LoadEntries()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load outbox: %w", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Seq < entries[j].Seq })
+
+	o := &Outbox{
+		Pool:    pool,
+		Store:   store,
+		entries: entries,
+		nextSeq: 1,
+		relays:  make(map[string]*outboxRelay),
+		wake:    make(chan struct{}, 1),
+	}
+	if len(entries) > 0 {
+		o.nextSeq = entries[len(entries)-1].Seq + 1
+	}
+	return o, nil
+}
+
+// Enqueue saves evt to be sent to the given relays the next time they can be reached.
+func (o *Outbox) Enqueue(evt Event, relays []string) error {
+	pending := uniqueURLs(relays)
+	if len(pending) == 0 {
+		return fmt.Errorf("no relays to send the event to")
+	}
+
+	o.mutex.Lock()
+	entry := OutboxEntry{Seq: o.nextSeq, Event: evt, QueuedAt: Now(), Pending: pending}
+	if err := o.Store.SaveEntry(entry); err != nil {
+		o.mutex.Unlock()
+		return fmt.Errorf("failed to save to outbox: %w", err)
+	}
+	o.nextSeq++
+	o.entries = append(o.entries, entry)
+	o.mutex.Unlock()
+
+	o.Wake()
+	return nil
+}
+
+// Pending returns the entries still in the queue, oldest first.
+func (o *Outbox) Pending() []OutboxEntry {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return append([]OutboxEntry{}, o.entries...)
+}
+
+// Wake makes Run try all the relays again right away, for when we know the network is back.
+func (o *Outbox) Wake() {
+	o.mutex.Lock()
+	for _, state := range o.relays {
+		state.nextAttempt = time.Time{}
+	}
+	o.mutex.Unlock()
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends the queued events until ctx is canceled, waiting before trying again the relays
+// that can't be reached as given by Backoff.
+func (o *Outbox) Run(ctx context.Context) {
+	for {
+		wait := o.Flush(ctx)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-o.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// Flush tries once to send the queued events to the relays that aren't waiting after a failure,
+// in parallel for each relay, and returns how long until the next relay can be tried, or an
+// hour if there is nothing to send.
+func (o *Outbox) Flush(ctx context.Context) time.Duration {
+	o.mutex.Lock()
+	now := Now()
+	var due []string
+	for _, entry := range o.entries {
+		for _, url := range entry.Pending {
+			state, ok := o.relays[url]
+			if !ok {
+				state = &outboxRelay{}
+				o.relays[url] = state
+			}
+			if !state.flushing && !now.Before(state.nextAttempt) {
+				state.flushing = true
+				due = append(due, url)
+			}
+		}
+	}
+	o.mutex.Unlock()
+
+	wg := sync.WaitGroup{}
+	for _, url := range due {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			o.flushRelay(ctx, url)
+		}(url)
+	}
+	wg.Wait()
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	wait := time.Hour
+	for _, entry := range o.entries {
+		for _, url := range entry.Pending {
+			state, ok := o.relays[url]
+			if !ok {
+				// queued while we were flushing, it was never tried
+				return 0
+			}
+			if until := time.Until(state.nextAttempt); until < wait {
+				wait = until
+			}
+		}
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return wait
+}
+
+// flushRelay sends the entries pending for url in order, stopping at the first one the relay
+// doesn't answer.
+func (o *Outbox) flushRelay(ctx context.Context, url string) {
+	var failure error
+	defer func() {
+		o.mutex.Lock()
+		defer o.mutex.Unlock()
+		state := o.relays[url]
+		state.flushing = false
+		if failure == nil {
+			state.failures = 0
+			return
+		}
+		backoff := o.Backoff
+		if backoff == nil {
+			backoff = DefaultBackoff
+		}
+		state.nextAttempt = Now().Add(backoff(state.failures))
+		state.failures++
+	}()
+
+	relay, err := o.Pool.ensureRelayCtx(ctx, url)
+	if err != nil {
+		failure = err
+		return
+	}
+
+	for _, seq := range o.pendingFor(url) {
+		evt, ok := o.event(seq)
+		if !ok {
+			continue
+		}
+		status, err := relay.Publish(ctx, evt)
+		if status == PublishStatusSucceeded {
+			o.settle(seq, url, nil)
+			continue
+		}
+		if err == nil {
+			err = fmt.Errorf("no answer")
+		}
+		if shouldRetry(status, err) {
+			failure = err
+			return
+		}
+
+		// the relay answered or we didn't even send it, see what the relay said
+		var rejected *RejectionError
+		if !errors.As(err, &rejected) {
+			o.settle(seq, url, err)
+		} else if rejected.Notice().Prefix == NoticeDuplicate {
+			o.settle(seq, url, nil)
+		} else {
+			o.settle(seq, url, errors.New(rejected.Reason))
+		}
+	}
+}
+
+// shouldRetry tells if an event that couldn't be published with status and err may be taken
+// later, because the relay couldn't be reached or asked us to try again, as opposed to
+// rejecting it.
+func shouldRetry(status Status, err error) bool {
+	if status == PublishStatusSent || errors.Is(err, ErrRelayClosed) {
+		return true
+	}
+	return rejectedWith(err, NoticeAuthRequired, NoticeRateLimited, NoticeError)
+}
+
+func (o *Outbox) pendingFor(url string) []uint64 {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	var seqs []uint64
+	for _, entry := range o.entries {
+		for _, pending := range entry.Pending {
+			if pending == url {
+				seqs = append(seqs, entry.Seq)
+				break
+			}
+		}
+	}
+	return seqs
+}
+
+func (o *Outbox) event(seq uint64) (Event, bool) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	for _, entry := range o.entries {
+		if entry.Seq == seq {
+			return entry.Event, true
+		}
+	}
+	return Event{}, false
+}
+
+// settle records that url took the entry, or rejected it if err isn't nil, removing the entry
+// once no relay is pending.
+func (o *Outbox) settle(seq uint64, url string, rejection error) {
+	o.mutex.Lock()
+	var done *OutboxEntry
+	for i := range o.entries {
+		entry := &o.entries[i]
+		if entry.Seq != seq {
+			continue
+		}
+
+		pending := make([]string, 0, len(entry.Pending))
+		for _, p := range entry.Pending {
+			if p != url {
+				pending = append(pending, p)
+			}
+		}
+		entry.Pending = pending
+		if rejection == nil {
+			entry.Accepted = append(entry.Accepted, url)
+		} else {
+			if entry.Rejected == nil {
+				entry.Rejected = make(map[string]string)
+			}
+			entry.Rejected[url] = rejection.Error()
+		}
+
+		if len(entry.Pending) > 0 {
+			o.Store.SaveEntry(*entry)
+			break
+		}
+		finished := *entry
+		done = &finished
+		o.entries = append(o.entries[:i], o.entries[i+1:]...)
+		o.Store.DeleteEntry(seq)
+		break
+	}
+	o.mutex.Unlock()
+
+	if done != nil && o.OnDone != nil {
+		o.OnDone(*done)
+	}
+}
+
+// MemoryOutboxStore keeps the entries only while the program runs.
+type MemoryOutboxStore struct {
+	mutex   sync.Mutex
+	entries map[uint64]OutboxEntry
+}
+
+func NewMemoryOutboxStore() *MemoryOutboxStore {
+	return &MemoryOutboxStore{entries: make(map[uint64]OutboxEntry)}
+}
+
+func (ms *MemoryOutboxStore) LoadEntries() ([]OutboxEntry, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	entries := make([]OutboxEntry, 0, len(ms.entries))
+	for _, entry := range ms.entries {
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (ms *MemoryOutboxStore) SaveEntry(entry OutboxEntry) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	ms.entries[entry.Seq] = entry
+	return nil
+}
+
+func (ms *MemoryOutboxStore) DeleteEntry(seq uint64) error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	delete(ms.entries, seq)
+	return nil
+}
+
+// FileOutboxStore keeps the entries in a JSON file, so they survive restarts. The whole file is
+// written on every change, which is fine for the small queues of clients.
+type FileOutboxStore struct {
+	path    string
+	mutex   sync.Mutex
+	entries map[uint64]OutboxEntry
+}
+
+// NewFileOutboxStore loads the entries saved at path, which is created if it doesn't exist.
+func NewFileOutboxStore(path string) (*FileOutboxStore, error) {
+	fs := &FileOutboxStore{path: path, entries: make(map[uint64]OutboxEntry)}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fs, nil
+		}
+		return nil, fmt.Errorf("failed to read outbox from %s: %w", path, err)
+	}
+	var entries []OutboxEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse outbox from %s: %w", path, err)
+	}
+	for _, entry := range entries {
+		fs.entries[entry.Seq] = entry
+	}
+	return fs, nil
+}
+
+func (fs *FileOutboxStore) LoadEntries() ([]OutboxEntry, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	return fs.list(), nil
+}
+
+func (fs *FileOutboxStore) SaveEntry(entry OutboxEntry) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	fs.entries[entry.Seq] = entry
+	return fs.write()
+}
+
+func (fs *FileOutboxStore) DeleteEntry(seq uint64) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	delete(fs.entries, seq)
+	return fs.write()
+}
+
+func (fs *FileOutboxStore) list() []OutboxEntry {
+	entries := make([]OutboxEntry, 0, len(fs.entries))
+	for _, entry := range fs.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Seq < entries[j].Seq })
+	return entries
+}
+
+func (fs *FileOutboxStore) write() error {
+	data, err := json.Marshal(fs.list())
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first so a crash doesn't leave a corrupted file behind
+	tmp := fs.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to save outbox: %w", err)
+	}
+	return os.Rename(tmp, fs.path)
+}
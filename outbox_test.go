@@ -0,0 +1,143 @@
+package nostr
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestOutboxSurvivesRestart(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// a relay that is down for now, at an address we can bring it up on later
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	url := "ws://" + addr
+
+	priv, pub := makeKeyPair(t)
+	var events []Event
+	for i := 0; i < 3; i++ {
+		evt := Event{Kind: 1, Content: "queued", CreatedAt: time.Unix(1672068534+int64(i), 0), PubKey: pub}
+		evt.Sign(priv)
+		events = append(events, evt)
+	}
+
+	path := filepath.Join(t.TempDir(), "outbox.json")
+	store, err := NewFileOutboxStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outbox, err := NewOutbox(NewSimplePool(ctx), store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, evt := range events {
+		if err := outbox.Enqueue(evt, []string{url}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if wait := outbox.Flush(ctx); wait <= 0 {
+		t.Errorf("should wait before trying the relay again, got %s", wait)
+	}
+	if pending := outbox.Pending(); len(pending) != 3 {
+		t.Fatalf("expected 3 pending entries, got %d", len(pending))
+	}
+
+	// restart with the relay up
+	fr := &fakeRelay{}
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("can't listen on %s again: %s", addr, err)
+	}
+	ws := httptest.NewUnstartedServer(&websocket.Server{Handshake: anyOriginHandshake, Handler: fr.handle})
+	ws.Listener.Close()
+	ws.Listener = ln
+	ws.Start()
+	defer ws.Close()
+
+	store, err = NewFileOutboxStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outbox, err = NewOutbox(NewSimplePool(ctx), store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var done []OutboxEntry
+	outbox.OnDone = func(entry OutboxEntry) { done = append(done, entry) }
+	outbox.Flush(ctx)
+
+	if len(outbox.Pending()) != 0 || len(done) != 3 {
+		t.Fatalf("expected all entries to be sent, %d pending and %d done", len(outbox.Pending()), len(done))
+	}
+	for i, entry := range done {
+		if entry.Event.ID != events[i].ID || len(entry.Accepted) != 1 || entry.Accepted[0] != NormalizeURL(url) {
+			t.Errorf("unexpected entry %d: %+v", i, entry)
+		}
+	}
+	fr.mu.Lock()
+	if len(fr.events) != 3 {
+		t.Errorf("relay got %d events", len(fr.events))
+	}
+	for i, evt := range fr.events {
+		if evt.ID != events[i].ID {
+			t.Errorf("event %d arrived out of order", i)
+		}
+	}
+	fr.mu.Unlock()
+
+	if entries, _ := store.LoadEntries(); len(entries) != 0 {
+		t.Errorf("sent entries should be removed from the store, got %d", len(entries))
+	}
+}
+
+func TestClientQueuesOnlyForUnreachableRelays(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rejecting := newWebsocketServer((&fakeRelay{reject: "blocked: no thanks"}).handle)
+	defer rejecting.Close()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	down := "ws://" + ln.Addr().String()
+	ln.Close()
+
+	client := NewClient(ctx, newTestSigner(t), nil, []string{rejecting.URL})
+	defer client.Close()
+	client.MaxPowDifficulty = 0
+	client.Outbox, err = NewOutbox(client.Pool, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evt := Event{Kind: 1, Content: "hello", CreatedAt: time.Now()}
+	if err := client.Publish(ctx, &evt); err == nil || !strings.Contains(err.Error(), "blocked: no thanks") {
+		t.Fatalf("expected the rejection, got %v", err)
+	}
+	if pending := client.Outbox.Pending(); len(pending) != 0 {
+		t.Fatalf("rejected event was queued: %+v", pending)
+	}
+
+	client.Relays = append(client.Relays, down)
+	evt = Event{Kind: 1, Content: "hello again", CreatedAt: time.Now()}
+	if err := client.Publish(ctx, &evt); err != nil {
+		t.Fatalf("event should have been queued, got %v", err)
+	}
+	pending := client.Outbox.Pending()
+	if len(pending) != 1 || len(pending[0].Pending) != 1 || pending[0].Pending[0] != NormalizeURL(down) {
+		t.Fatalf("expected the event queued only for the unreachable relay, got %+v", pending)
+	}
+}
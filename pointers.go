@@ -1,19 +1,204 @@
 package nostr
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil/bech32"
+	"github.com/nbd-wtf/go-nostr/internal/tlv"
+)
+
+// Pointer refers to a profile, an event or an addressable event, with hints of relays where
+// it can be found, as encoded in nprofile, nevent and naddr codes (NIP-19) and nostr: URIs
+// (NIP-21). It is a ProfilePointer, an EventPointer or an EntityPointer, see Client.Fetch.
+type Pointer interface {
+	// AsFilter returns a filter that matches what the pointer refers to.
+	AsFilter() (Filter, error)
+	// Hints are the relays where what the pointer refers to may be found.
+	Hints() []string
+}
+
+// ProfilePointer refers to the profile of PublicKey.
 type ProfilePointer struct {
-	PublicKey string
+	PublicKey PubKey
 	Relays    []string
 }
 
+// EventPointer refers to the event with ID. Author and Kind are optional, zero when unknown.
 type EventPointer struct {
-	ID     string
+	ID     ID
 	Relays []string
-	Author string
+	Author PubKey
+	Kind   int
 }
 
+// EntityPointer refers to the latest version of an addressable event, by its kind, author and
+// "d" tag.
 type EntityPointer struct {
-	PublicKey  string
+	PublicKey  PubKey
 	Kind       int
 	Identifier string
 	Relays     []string
 }
+
+// AsFilter matches the profile metadata event of the pointer's public key.
+func (ep ProfilePointer) AsFilter() (Filter, error) {
+	return Filter{Kinds: []int{KindSetMetadata}, Authors: []PubKey{ep.PublicKey}, Limit: 1}, nil
+}
+
+func (ep ProfilePointer) Hints() []string { return ep.Relays }
+
+// AsFilter matches the event by id, and by author and kind if they are known.
+func (ep EventPointer) AsFilter() (Filter, error) {
+	filter := Filter{IDs: []ID{ep.ID}}
+	if ep.Author != ZeroPubKey {
+		filter.Authors = []PubKey{ep.Author}
+	}
+	if ep.Kind != 0 {
+		filter.Kinds = []int{ep.Kind}
+	}
+	return filter, nil
+}
+
+func (ep EventPointer) Hints() []string { return ep.Relays }
+
+// AsFilter matches every version of the addressable event, the newest is the one that counts.
+func (ep EntityPointer) AsFilter() (Filter, error) {
+	return Filter{
+		Kinds:   []int{ep.Kind},
+		Authors: []PubKey{ep.PublicKey},
+		Tags:    TagMap{"d": []string{ep.Identifier}},
+		Limit:   1,
+	}, nil
+}
+
+func (ep EntityPointer) Hints() []string { return ep.Relays }
+
+// Address is the "<kind>:<pubkey>:<d tag>" form used in "a" tags.
+func (ep EntityPointer) Address() string {
+	return fmt.Sprintf("%d:%s:%s", ep.Kind, ep.PublicKey.Hex(), ep.Identifier)
+}
+
+// ParseAddress reads an address in the "<kind>:<pubkey>:<d tag>" form, as in "a" tags.
+func ParseAddress(address string) (EntityPointer, error) {
+	spl := strings.SplitN(address, ":", 3)
+	if len(spl) != 3 {
+		return EntityPointer{}, fmt.Errorf("'%s' is not an address", address)
+	}
+	kind, err := strconv.Atoi(spl[0])
+	if err != nil || kind < 0 {
+		return EntityPointer{}, fmt.Errorf("invalid kind in address '%s'", address)
+	}
+	pk, err := PubKeyFromHex(spl[1])
+	if err != nil {
+		return EntityPointer{}, fmt.Errorf("invalid pubkey in address '%s': %w", address, err)
+	}
+	return EntityPointer{PublicKey: pk, Kind: kind, Identifier: spl[2]}, nil
+}
+
+// PointerFromTag makes a pointer to what an "e", "a" or "q" tag references, with the tag's
+// relay hint and, for "e" and "q" tags that have it, the author.
+func PointerFromTag(tag Tag) (Pointer, error) {
+	if len(tag) < 2 {
+		return nil, fmt.Errorf("tag %v doesn't reference anything", tag)
+	}
+
+	var hints []string
+	if relay := tag.Relay(); relay != "" {
+		hints = []string{relay}
+	}
+
+	switch {
+	case tag[0] == "a" || (tag[0] == "q" && strings.Contains(tag[1], ":")):
+		ptr, err := ParseAddress(tag[1])
+		if err != nil {
+			return nil, err
+		}
+		ptr.Relays = hints
+		return ptr, nil
+	case tag[0] == "e" || tag[0] == "q":
+		id, err := IDFromHex(tag[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid id '%s': %w", tag[1], err)
+		}
+		ptr := EventPointer{ID: id, Relays: hints}
+		// NIP-10 puts the author after the marker, NIP-18 right after the relay
+		author := 3
+		if tag[0] == "e" {
+			author = 4
+		}
+		if len(tag) > author {
+			if pk, err := PubKeyFromHex(tag[author]); err == nil {
+				ptr.Author = pk
+			}
+		}
+		return ptr, nil
+	}
+	return nil, fmt.Errorf("can't make a pointer from a '%s' tag", tag[0])
+}
+
+// ParsePointer reads a hex event id or a NIP-19 code: npub, nprofile, note, nevent or naddr.
+// See the nip19 package for encoding them.
+func ParsePointer(code string) (Pointer, error) {
+	code = strings.TrimPrefix(code, "nostr:")
+	if id, err := IDFromHex(code); err == nil {
+		return EventPointer{ID: id}, nil
+	}
+
+	prefix, bits5, err := bech32.DecodeNoLimit(code)
+	if err != nil {
+		return nil, fmt.Errorf("'%s' is neither an id nor a NIP-19 code: %w", code, err)
+	}
+	data, err := bech32.ConvertBits(bits5, 5, 8, false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid NIP-19 code '%s': %w", code, err)
+	}
+
+	switch prefix {
+	case "npub", "note":
+		if len(data) != 32 {
+			return nil, fmt.Errorf("invalid %s, %d bytes", prefix, len(data))
+		}
+		if prefix == "npub" {
+			return ProfilePointer{PublicKey: *(*PubKey)(data)}, nil
+		}
+		return EventPointer{ID: *(*ID)(data)}, nil
+	case "nprofile", "nevent", "naddr":
+		entries, err := tlv.Decode(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", prefix, err)
+		}
+
+		switch prefix {
+		case "nprofile":
+			if len(entries.Special) != 32 {
+				return nil, fmt.Errorf("invalid nprofile, %d bytes pubkey", len(entries.Special))
+			}
+			return ProfilePointer{PublicKey: *(*PubKey)(entries.Special), Relays: entries.Relays}, nil
+		case "nevent":
+			if len(entries.Special) != 32 {
+				return nil, fmt.Errorf("invalid nevent, %d bytes id", len(entries.Special))
+			}
+			ptr := EventPointer{ID: *(*ID)(entries.Special), Relays: entries.Relays}
+			if len(entries.Author) == 32 {
+				ptr.Author = *(*PubKey)(entries.Author)
+			}
+			if entries.Kind > 0 {
+				ptr.Kind = entries.Kind
+			}
+			return ptr, nil
+		default:
+			if len(entries.Author) != 32 || !entries.HasKind {
+				return nil, fmt.Errorf("incomplete naddr")
+			}
+			return EntityPointer{
+				PublicKey:  *(*PubKey)(entries.Author),
+				Kind:       entries.Kind,
+				Identifier: string(entries.Special),
+				Relays:     entries.Relays,
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported NIP-19 code '%s'", prefix)
+}
@@ -0,0 +1,37 @@
+package nostr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPointerFromTag(t *testing.T) {
+	id := MustIDFromHex("45326f5d6962ab1e3cd424e758c3002b8665f7b0d8dcee9fe9e288d7751ac194")
+	pk := MustPubKeyFromHex("3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d")
+
+	for _, test := range []struct {
+		tag      Tag
+		expected Pointer
+	}{
+		{Tag{"e", id.Hex()}, EventPointer{ID: id}},
+		{Tag{"e", id.Hex(), "wss://x.com", "reply", pk.Hex()}, EventPointer{ID: id, Relays: []string{"wss://x.com"}, Author: pk}},
+		{Tag{"q", id.Hex(), "wss://x.com", pk.Hex()}, EventPointer{ID: id, Relays: []string{"wss://x.com"}, Author: pk}},
+		{Tag{"a", "30023:" + pk.Hex() + ":a:b", "wss://y.com"}, EntityPointer{PublicKey: pk, Kind: 30023, Identifier: "a:b", Relays: []string{"wss://y.com"}}},
+		{Tag{"q", "30023:" + pk.Hex() + ":"}, EntityPointer{PublicKey: pk, Kind: 30023}},
+	} {
+		ptr, err := PointerFromTag(test.tag)
+		if err != nil {
+			t.Errorf("failed to make pointer from %v: %s", test.tag, err)
+			continue
+		}
+		if !reflect.DeepEqual(ptr, test.expected) {
+			t.Errorf("wrong pointer from %v: %+v", test.tag, ptr)
+		}
+	}
+
+	for _, tag := range []Tag{{"e"}, {"e", "xyz"}, {"a", "1:" + pk.Hex()}, {"a", "x:" + pk.Hex() + ":"}, {"p", pk.Hex()}} {
+		if _, err := PointerFromTag(tag); err == nil {
+			t.Errorf("should fail to make a pointer from %v", tag)
+		}
+	}
+}
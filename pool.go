@@ -0,0 +1,302 @@
+package nostr
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	s "github.com/SaveTheRbtz/generic-sync-map-go"
+)
+
+// SimplePool keeps connections to many relays so they can be reused across subscriptions,
+// and merges (and dedupes) the events that come from all of them.
+type SimplePool struct {
+	Relays  s.MapOf[string, *Relay]
+	Context context.Context
+
+	// Policy, if set, keeps the pool from connecting to relays it doesn't allow, so events are
+	// never requested from or published to them.
+	Policy *RelayPolicy
+
+	// RelayOptions are used for every new relay connection.
+	RelayOptions []RelayOption
+
+	// Interceptors run on the events of the subscriptions made through the pool, like the ones
+	// given to WithEventInterceptors, but only once for events sent by many relays.
+	Interceptors []EventInterceptor
+
+	// MaxConcurrentDials limits how many relays are connected to at the same time, and
+	// MaxConnections how many are kept connected. Once there are MaxConnections, the relay used
+	// least recently that has no subscriptions is closed to make room for a new one. Both must be
+	// set before the pool is used, 0 means no limit.
+	MaxConcurrentDials int
+	MaxConnections     int
+
+	// SeenCapacity is about how many of the latest event ids each subscription remembers to
+	// skip events that were already delivered by another relay, DefaultSeenCapacity if 0.
+	// Memory use is fixed, about 3.6 bytes per id, but one in a thousand new events may be
+	// mistaken for a duplicate and skipped.
+	SeenCapacity int
+
+	// CoalesceQueries makes queries that end on "EOSE", like SubManyEose and QuerySingle, share
+	// the REQs other queries made at about the same time have open on the same relay if these
+	// ask for everything they want, e.g. when rendering a feed asks for the same profiles many
+	// times. Queries given SubscriptionOptions never share REQs.
+	CoalesceQueries bool
+
+	// MemberBackoff is how long the subscriptions made with Subscribe wait before connecting
+	// again to a member relay that couldn't be reached or that ended their REQ, DefaultBackoff
+	// if nil.
+	MemberBackoff Backoff
+
+	connecting s.MapOf[string, *sync.Mutex]
+	health     relayHealthTracker
+	budget     connectionBudget
+	members    poolMembership
+	queries    sharedQueries
+	cancel     context.CancelFunc
+}
+
+func NewSimplePool(ctx context.Context) *SimplePool {
+	ctx, cancel := context.WithCancel(ctx)
+
+	return &SimplePool{
+		Context: ctx,
+		cancel:  cancel,
+	}
+}
+
+// EnsureRelay returns a connection to the relay at url, reusing an existing one if possible.
+func (pool *SimplePool) EnsureRelay(url string) (*Relay, error) {
+	nm := NormalizeURL(url)
+	if nm == "" {
+		return nil, fmt.Errorf("invalid relay URL '%s'", url)
+	}
+	if !pool.Policy.Allowed(nm) {
+		return nil, fmt.Errorf("%s: %w", nm, ErrRelayDenied)
+	}
+	if pool.health.quarantined(nm) {
+		return nil, fmt.Errorf("%s: %w", nm, ErrRelayQuarantined)
+	}
+
+	// only one connection attempt per relay at a time, but different relays can connect concurrently
+	mu, _ := pool.connecting.LoadOrStore(nm, &sync.Mutex{})
+	mu.Lock()
+	defer mu.Unlock()
+
+	if relay, ok := pool.Relays.Load(nm); ok && relay.ConnectionContext.Err() == nil {
+		pool.touch(nm)
+		return relay, nil
+	}
+
+	ctx, cancel := context.WithTimeout(pool.Context, 15*time.Second)
+	defer cancel()
+	release, err := pool.reserveConnection(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("can't connect to %s: %w", nm, err)
+	}
+	defer release()
+	relay, err := RelayConnect(ctx, nm, pool.RelayOptions...)
+	if err != nil {
+		pool.health.failure(nm)
+		return nil, fmt.Errorf("failed to connect to %s: %w", nm, err)
+	}
+	pool.health.success(nm)
+
+	pool.Relays.Store(nm, relay)
+	pool.touch(nm)
+	return relay, nil
+}
+
+// ensureRelayCtx is like EnsureRelay but stops waiting when ctx is canceled, leaving the
+// connection to finish in the background so it can be reused later.
+func (pool *SimplePool) ensureRelayCtx(ctx context.Context, url string) (*Relay, error) {
+	type result struct {
+		relay *Relay
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		relay, err := pool.EnsureRelay(url)
+		done <- result{relay, err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.relay, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// SubMany opens a subscription with the given filters on all the given relays and returns
+// a channel with the events from all of them, without duplicates.
+// The channel is closed once all subscriptions end, which happens when ctx is canceled.
+func (pool *SimplePool) SubMany(ctx context.Context, urls []string, filters Filters, opts ...SubscriptionOption) chan *Event {
+	return subMany(pool, ctx, urls, filters, false, 0, opts, bareEvent)
+}
+
+// SubManyEose is like SubMany, but each subscription ends when its relay sends "EOSE",
+// so the channel is closed once all stored events were received.
+func (pool *SimplePool) SubManyEose(ctx context.Context, urls []string, filters Filters, opts ...SubscriptionOption) chan *Event {
+	return subMany(pool, ctx, urls, filters, true, 0, opts, bareEvent)
+}
+
+// SubManyEoseFastest is like SubManyEose, but only waits for the first k relays to send "EOSE".
+// All the relays are queried at the same time, the healthiest first, and once k of them are
+// done the others are canceled, so slow relays don't hold interactive queries back.
+func (pool *SimplePool) SubManyEoseFastest(ctx context.Context, urls []string, filters Filters, k int, opts ...SubscriptionOption) chan *Event {
+	return subMany(pool, ctx, urls, filters, true, k, opts, bareEvent)
+}
+
+// RelayEvent is an event delivered by a pool along with the relay it came from and when it was
+// received. Events sent by many relays are only delivered once, from the first of them.
+type RelayEvent struct {
+	*Event
+	Relay      *Relay
+	ReceivedAt time.Time
+}
+
+// SubManyAnnotated is like SubMany, but tells which relay sent each event and when.
+func (pool *SimplePool) SubManyAnnotated(ctx context.Context, urls []string, filters Filters, opts ...SubscriptionOption) chan RelayEvent {
+	return subMany(pool, ctx, urls, filters, false, 0, opts, annotatedEvent)
+}
+
+// SubManyEoseAnnotated is like SubManyEose, but tells which relay sent each event and when.
+func (pool *SimplePool) SubManyEoseAnnotated(ctx context.Context, urls []string, filters Filters, opts ...SubscriptionOption) chan RelayEvent {
+	return subMany(pool, ctx, urls, filters, true, 0, opts, annotatedEvent)
+}
+
+func bareEvent(evt *Event, _ *Relay, _ time.Time) *Event { return evt }
+
+func annotatedEvent(evt *Event, relay *Relay, receivedAt time.Time) RelayEvent {
+	return RelayEvent{Event: evt, Relay: relay, ReceivedAt: receivedAt}
+}
+
+// subMany subscribes to all urls. If eose is set each subscription ends on "EOSE", and if
+// enough is more than zero all of them end once that many relays sent "EOSE". Events are
+// delivered as wrap returns them.
+func subMany[T any](pool *SimplePool, ctx context.Context, urls []string, filters Filters, eose bool, enough int, opts []SubscriptionOption,
+	wrap func(*Event, *Relay, time.Time) T,
+) chan T {
+	ctx, cancel := context.WithCancel(ctx)
+	var eoseCount int32
+
+	events := make(chan T)
+	seenAlready := newSeenFilter(pool.SeenCapacity)
+	wg := sync.WaitGroup{}
+
+	for _, url := range pool.RankRelays(urls) {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+
+			relay, err := pool.ensureRelayCtx(ctx, url)
+			if err != nil {
+				return
+			}
+
+			deliver := func(evt *Event, receivedAt time.Time) bool {
+				if seenAlready.check(evt.ID) || !intercept(pool.Interceptors, relay, evt) {
+					return true
+				}
+				select {
+				case events <- wrap(evt, relay, receivedAt):
+					return true
+				case <-ctx.Done():
+					return false
+				}
+			}
+
+			if eose && pool.CoalesceQueries && len(opts) == 0 {
+				for re := range pool.queryShared(ctx, relay, filters) {
+					if !deliver(re.Event, re.ReceivedAt) {
+						return
+					}
+				}
+				if enough > 0 && int(atomic.AddInt32(&eoseCount, 1)) >= enough {
+					cancel()
+				}
+				return
+			}
+
+			subCtx, subCancel := context.WithCancel(ctx)
+			defer subCancel()
+			start := time.Now()
+			sub, err := relay.Sub(subCtx, filters, opts...)
+			if err != nil {
+				pool.health.failure(url)
+				return
+			}
+
+			// we always wait for "EOSE" to measure how long the relay takes, set to nil afterwards
+			eoseSignal := sub.EndOfStoredEvents
+
+			for {
+				select {
+				case evt, more := <-sub.Events:
+					if !more {
+						return
+					}
+					if !deliver(evt, time.Now()) {
+						return
+					}
+				case <-eoseSignal:
+					pool.health.eose(url, time.Since(start))
+					if enough > 0 && int(atomic.AddInt32(&eoseCount, 1)) >= enough {
+						// we have all we need, stop waiting for the stragglers
+						cancel()
+					}
+					if eose {
+						return
+					}
+					eoseSignal = nil
+				}
+			}
+		}(url)
+	}
+
+	go func() {
+		wg.Wait()
+		cancel()
+		close(events)
+	}()
+
+	return events
+}
+
+// QuerySingle returns the first event matching filter that any of the relays sends, or nil.
+func (pool *SimplePool) QuerySingle(ctx context.Context, urls []string, filter Filter) *Event {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for evt := range pool.SubManyEose(ctx, urls, Filters{filter}) {
+		return evt
+	}
+	return nil
+}
+
+// Close disconnects from all relays and cancels all the subscriptions made through the pool.
+func (pool *SimplePool) Close() {
+	pool.cancel()
+	pool.Relays.Range(func(_ string, relay *Relay) bool {
+		relay.Close()
+		return true
+	})
+}
+
+func uniqueURLs(urls []string) []string {
+	unique := make([]string, 0, len(urls))
+	seen := make(map[string]struct{}, len(urls))
+	for _, url := range urls {
+		nm := NormalizeURL(url)
+		if _, ok := seen[nm]; ok || nm == "" {
+			continue
+		}
+		seen[nm] = struct{}{}
+		unique = append(unique, nm)
+	}
+	return unique
+}
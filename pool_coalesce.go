@@ -0,0 +1,165 @@
+package nostr
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sharedQueries are the REQs that end on "EOSE" being shared by concurrent queries, by relay,
+// see SimplePool.CoalesceQueries.
+type sharedQueries struct {
+	mutex   sync.Mutex
+	byRelay map[string][]*sharedQuery
+}
+
+// sharedQuery is a REQ to one relay whose events go to every query it covers. Events are kept
+// until "EOSE" so queries joining late get them too.
+type sharedQuery struct {
+	filters Filters
+	cancel  context.CancelFunc
+
+	// guarded by the sharedQueries mutex
+	events  []RelayEvent
+	updated chan struct{} // closed and replaced whenever events or done change
+	done    bool
+	waiters int
+}
+
+// covers tells if the query gets every event filters ask for. Filters with a limit are only
+// covered by the same filter, as the relay would pick other events for a different limit.
+func (q *sharedQuery) covers(filters Filters) bool {
+next:
+	for _, f := range filters {
+		for _, g := range q.filters {
+			if g.Contains(f) && g.Limit == f.Limit {
+				continue next
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// queryShared streams the events matching filters relay has up to "EOSE", joining a REQ another
+// query has open on it if it covers filters, or opening one others can join. The channel is
+// closed after "EOSE" or when ctx is done.
+func (pool *SimplePool) queryShared(ctx context.Context, relay *Relay, filters Filters) chan RelayEvent {
+	sq := &pool.queries
+	sq.mutex.Lock()
+	var query *sharedQuery
+	for _, q := range sq.byRelay[relay.URL] {
+		if !q.done && q.waiters > 0 && q.covers(filters) {
+			query = q
+			break
+		}
+	}
+	if query == nil {
+		query = pool.startSharedQuery(relay, filters)
+	}
+	query.waiters++
+	sq.mutex.Unlock()
+
+	events := make(chan RelayEvent)
+	go func() {
+		defer close(events)
+		defer func() {
+			sq.mutex.Lock()
+			query.waiters--
+			if query.waiters == 0 {
+				// nobody else wants it
+				query.cancel()
+			}
+			sq.mutex.Unlock()
+		}()
+
+		for i := 0; ; {
+			sq.mutex.Lock()
+			pending := query.events[i:]
+			done, updated := query.done, query.updated
+			sq.mutex.Unlock()
+
+			for _, re := range pending {
+				i++
+				if !filters.Match(re.Event) {
+					continue
+				}
+				select {
+				case events <- re:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if len(pending) > 0 {
+				continue
+			}
+			if done {
+				return
+			}
+			select {
+			case <-updated:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events
+}
+
+// startSharedQuery opens the REQ of a new shared query. Must be called with the sharedQueries
+// mutex held.
+func (pool *SimplePool) startSharedQuery(relay *Relay, filters Filters) *sharedQuery {
+	sq := &pool.queries
+	ctx, cancel := context.WithCancel(pool.Context)
+	query := &sharedQuery{filters: filters, cancel: cancel, updated: make(chan struct{})}
+	if sq.byRelay == nil {
+		sq.byRelay = make(map[string][]*sharedQuery)
+	}
+	sq.byRelay[relay.URL] = append(sq.byRelay[relay.URL], query)
+
+	update := func(f func()) {
+		sq.mutex.Lock()
+		f()
+		close(query.updated)
+		query.updated = make(chan struct{})
+		sq.mutex.Unlock()
+	}
+
+	go func() {
+		defer cancel()
+		defer update(func() {
+			query.done = true
+			queries := sq.byRelay[relay.URL]
+			for i, q := range queries {
+				if q == query {
+					sq.byRelay[relay.URL] = append(queries[:i:i], queries[i+1:]...)
+					break
+				}
+			}
+			if len(sq.byRelay[relay.URL]) == 0 {
+				delete(sq.byRelay, relay.URL)
+			}
+		})
+
+		start := time.Now()
+		sub, err := relay.Sub(ctx, filters)
+		if err != nil {
+			pool.health.failure(relay.URL)
+			return
+		}
+		for {
+			select {
+			case evt, more := <-sub.Events:
+				if !more {
+					return
+				}
+				re := RelayEvent{Event: evt, Relay: relay, ReceivedAt: time.Now()}
+				update(func() { query.events = append(query.events, re) })
+			case <-sub.EndOfStoredEvents:
+				pool.health.eose(relay.URL, time.Since(start))
+				return
+			}
+		}
+	}()
+	return query
+}
@@ -0,0 +1,82 @@
+package nostr
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCoalesceQueries(t *testing.T) {
+	sk, pk := makeKeyPair(t)
+	fr := &fakeRelay{eoseDelay: 300 * time.Millisecond}
+	for i, kind := range []int{1, 1, 7} {
+		evt := Event{Kind: kind, PubKey: pk, CreatedAt: time.Now(), Content: fmt.Sprint(i)}
+		evt.Sign(sk)
+		fr.events = append(fr.events, evt)
+	}
+	ws := newWebsocketServer(fr.handle)
+	defer ws.Close()
+
+	pool := NewSimplePool(context.Background())
+	pool.CoalesceQueries = true
+	defer pool.Close()
+	if _, err := pool.EnsureRelay(ws.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := func(filters Filters) int {
+		n := 0
+		for range pool.SubManyEose(ctx, []string{ws.URL}, filters) {
+			n++
+		}
+		return n
+	}
+
+	var wg sync.WaitGroup
+	results := make([]int, 5)
+	for i := range results {
+		filters := Filters{{Kinds: []int{1, 7}}}
+		if i == 4 {
+			// covered by the others
+			filters = Filters{{Kinds: []int{1}}}
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = query(filters)
+		}(i)
+		if i == 0 {
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+	wg.Wait()
+
+	for i, n := range results[:4] {
+		if n != 3 {
+			t.Errorf("query %d got %d events", i, n)
+		}
+	}
+	if results[4] != 2 {
+		t.Errorf("covered query got %d events", results[4])
+	}
+	fr.mu.Lock()
+	if fr.reqs != 1 {
+		t.Errorf("relay got %d REQs", fr.reqs)
+	}
+	fr.mu.Unlock()
+
+	// once done the next query asks again
+	if n := query(Filters{{Kinds: []int{1, 7}}}); n != 3 {
+		t.Errorf("got %d events", n)
+	}
+	fr.mu.Lock()
+	if fr.reqs != 2 {
+		t.Errorf("relay got %d REQs", fr.reqs)
+	}
+	fr.mu.Unlock()
+}
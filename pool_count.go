@@ -0,0 +1,92 @@
+package nostr
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultCountFallbackLimit is how many events CountMany fetches at most from each relay that
+// doesn't support NIP-45, to count them.
+var DefaultCountFallbackLimit = 500
+
+// RelayCount is the count of one relay in a CountResult.
+type RelayCount struct {
+	Count int64
+	// Counted is set when the relay answered a "COUNT", otherwise the events were fetched.
+	Counted bool
+	// LowerBound is set when there may be more events than Count, because fetching them
+	// stopped at the limit.
+	LowerBound bool
+	Err        error
+}
+
+// CountResult is what CountMany returns.
+type CountResult struct {
+	Relays map[string]RelayCount
+
+	// Estimate is how many distinct events match in all relays together, as far as we can tell:
+	// the highest count a relay gave, or how many distinct events were fetched from the relays
+	// without NIP-45, whichever is higher. It is a lower bound since relays that answer
+	// "COUNT" don't say which events they counted.
+	Estimate int64
+}
+
+// CountMany counts the events matching filter in each relay at the same time, with "COUNT"
+// on relays that support NIP-45 and fetching up to DefaultCountFallbackLimit events on the
+// others.
+func (pool *SimplePool) CountMany(ctx context.Context, urls []string, filter Filter) CountResult {
+	result := CountResult{Relays: make(map[string]RelayCount)}
+	seen := make(map[ID]struct{})
+
+	var mutex sync.Mutex
+	wg := sync.WaitGroup{}
+	for _, url := range uniqueURLs(urls) {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+
+			var count RelayCount
+			var ids []ID
+			relay, err := pool.ensureRelayCtx(ctx, url)
+			switch {
+			case err != nil:
+				count.Err = err
+			case relay.Supports(ctx, 45):
+				count.Count, count.Err = relay.Count(ctx, Filters{filter})
+				count.Counted = true
+			default:
+				limit := DefaultCountFallbackLimit
+				if filter.Limit > 0 && filter.Limit < limit {
+					limit = filter.Limit
+				}
+				bounded := filter
+				bounded.Limit = limit
+				events, err := relay.Query(ctx, bounded)
+				for _, evt := range events {
+					ids = append(ids, evt.ID)
+				}
+				count.Count, count.Err = int64(len(events)), err
+				count.LowerBound = len(events) >= limit
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			result.Relays[url] = count
+			if count.Err != nil {
+				return
+			}
+			for _, id := range ids {
+				seen[id] = struct{}{}
+			}
+			if count.Counted && count.Count > result.Estimate {
+				result.Estimate = count.Count
+			}
+		}(url)
+	}
+	wg.Wait()
+
+	if distinct := int64(len(seen)); distinct > result.Estimate {
+		result.Estimate = distinct
+	}
+	return result
+}
@@ -0,0 +1,81 @@
+package nostr
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestPoolCountMany(t *testing.T) {
+	// a relay with NIP-45 that counts 2 events
+	counting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") == "application/nostr+json" {
+			w.Write([]byte(`{"supported_nips":[1,11,45]}`))
+			return
+		}
+		(&websocket.Server{
+			Handshake: anyOriginHandshake,
+			Handler: func(conn *websocket.Conn) {
+				for {
+					var raw []json.RawMessage
+					if err := websocket.JSON.Receive(conn, &raw); err != nil {
+						return
+					}
+					var typ, subid string
+					json.Unmarshal(raw[0], &typ)
+					json.Unmarshal(raw[1], &subid)
+					if typ == "COUNT" {
+						websocket.JSON.Send(conn, []any{"COUNT", subid, map[string]any{"count": 2}})
+					}
+				}
+			},
+		}).ServeHTTP(w, r)
+	}))
+	defer counting.Close()
+
+	// two relays without it that have 3 different events between them
+	priv, pub := makeKeyPair(t)
+	events := make([]Event, 3)
+	for i := range events {
+		events[i] = Event{Kind: 1, Content: "hello", CreatedAt: time.Unix(1672068534+int64(i), 0), PubKey: pub}
+		events[i].Sign(priv)
+	}
+	first := &fakeRelay{events: events[:2]}
+	second := &fakeRelay{events: events[1:]}
+	firstServer := newWebsocketServer(first.handle)
+	defer firstServer.Close()
+	secondServer := newWebsocketServer(second.handle)
+	defer secondServer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	pool := NewSimplePool(ctx)
+	defer pool.Close()
+
+	urls := []string{counting.URL, firstServer.URL, secondServer.URL, "ws://127.0.0.1:1"}
+	result := pool.CountMany(ctx, urls, Filter{Kinds: []int{1}})
+
+	if c := result.Relays[NormalizeURL(counting.URL)]; c.Count != 2 || !c.Counted || c.Err != nil {
+		t.Errorf("wrong count from the NIP-45 relay: %+v", c)
+	}
+	if c := result.Relays[NormalizeURL(firstServer.URL)]; c.Count != 2 || c.Counted || c.LowerBound || c.Err != nil {
+		t.Errorf("wrong count from the first relay: %+v", c)
+	}
+	if c := result.Relays[NormalizeURL("ws://127.0.0.1:1")]; c.Err == nil {
+		t.Errorf("unreachable relay should have an error: %+v", c)
+	}
+	if result.Estimate != 3 {
+		t.Errorf("estimate should be the 3 distinct events, got %d", result.Estimate)
+	}
+
+	// with a small limit the fetched counts are only lower bounds
+	result = pool.CountMany(ctx, urls, Filter{Kinds: []int{1}, Limit: 1})
+	if c := result.Relays[NormalizeURL(secondServer.URL)]; c.Count != 1 || !c.LowerBound {
+		t.Errorf("wrong bounded count from the second relay: %+v", c)
+	}
+}
@@ -0,0 +1,127 @@
+package nostr
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrRelayQuarantined is returned when trying to use a relay that failed too many times in a
+// row and is being left alone for a while.
+var ErrRelayQuarantined = errors.New("relay is quarantined after repeated failures")
+
+const (
+	// consecutive failures before a relay is quarantined
+	quarantineThreshold = 3
+	minQuarantine       = time.Minute
+	maxQuarantine       = time.Hour
+	// weight of new EOSE latency samples in the moving average
+	latencySmoothing = 0.3
+)
+
+// RelayHealth is what a SimplePool knows about how well a relay has been working.
+type RelayHealth struct {
+	Successes           int // connections made and subscriptions that reached EOSE
+	Errors              int // connection, subscription and publish failures
+	ConsecutiveFailures int
+	EoseLatency         time.Duration // moving average of the time to EOSE
+	QuarantinedUntil    time.Time
+}
+
+// Score goes from 0, for relays that are quarantined or always fail, to 1, for relays that
+// always work and answer instantly. Relays we know nothing about get 0.5.
+func (h RelayHealth) Score() float64 {
+	if Now().Before(h.QuarantinedUntil) {
+		return 0
+	}
+	successRate := float64(h.Successes+1) / float64(h.Successes+h.Errors+2)
+	return successRate / (1 + h.EoseLatency.Seconds())
+}
+
+type relayHealthTracker struct {
+	mu     sync.Mutex
+	relays map[string]*RelayHealth
+}
+
+func (t *relayHealthTracker) update(url string, fn func(h *RelayHealth)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.relays == nil {
+		t.relays = make(map[string]*RelayHealth)
+	}
+	h, ok := t.relays[url]
+	if !ok {
+		h = &RelayHealth{}
+		t.relays[url] = h
+	}
+	fn(h)
+}
+
+func (t *relayHealthTracker) get(url string) RelayHealth {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if h, ok := t.relays[url]; ok {
+		return *h
+	}
+	return RelayHealth{}
+}
+
+func (t *relayHealthTracker) success(url string) {
+	t.update(url, func(h *RelayHealth) {
+		h.Successes++
+		h.ConsecutiveFailures = 0
+	})
+}
+
+func (t *relayHealthTracker) failure(url string) {
+	t.update(url, func(h *RelayHealth) {
+		h.Errors++
+		h.ConsecutiveFailures++
+		if h.ConsecutiveFailures >= quarantineThreshold {
+			// a minute for the first time, doubling each time it fails again after that
+			d := minQuarantine << (h.ConsecutiveFailures - quarantineThreshold)
+			if d > maxQuarantine || d <= 0 {
+				d = maxQuarantine
+			}
+			h.QuarantinedUntil = Now().Add(d)
+		}
+	})
+}
+
+func (t *relayHealthTracker) eose(url string, latency time.Duration) {
+	t.update(url, func(h *RelayHealth) {
+		h.Successes++
+		h.ConsecutiveFailures = 0
+		if h.EoseLatency == 0 {
+			h.EoseLatency = latency
+		} else {
+			h.EoseLatency = time.Duration(latencySmoothing*float64(latency) + (1-latencySmoothing)*float64(h.EoseLatency))
+		}
+	})
+}
+
+func (t *relayHealthTracker) quarantined(url string) bool {
+	return Now().Before(t.get(url).QuarantinedUntil)
+}
+
+// Health returns what the pool knows about the relay at url.
+func (pool *SimplePool) Health(url string) RelayHealth {
+	return pool.health.get(NormalizeURL(url))
+}
+
+// RankRelays returns urls, filtered like FilterRelays and without quarantined relays, sorted
+// from the healthiest to the least healthy, see RelayHealth.Score.
+func (pool *SimplePool) RankRelays(urls []string) []string {
+	ranked := make([]string, 0, len(urls))
+	scores := make(map[string]float64, len(urls))
+	for _, url := range pool.FilterRelays(urls) {
+		if pool.health.quarantined(url) {
+			continue
+		}
+		ranked = append(ranked, url)
+		scores[url] = pool.health.get(url).Score()
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return scores[ranked[i]] > scores[ranked[j]] })
+	return ranked
+}
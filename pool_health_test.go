@@ -0,0 +1,39 @@
+package nostr
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRelayHealth(t *testing.T) {
+	pool := NewSimplePool(context.Background())
+	defer pool.Close()
+
+	fast, slow, flaky := "wss://fast.example.com", "wss://slow.example.com", "wss://flaky.example.com"
+	pool.health.eose(fast, 50*time.Millisecond)
+	pool.health.eose(slow, 3*time.Second)
+	for i := 0; i < quarantineThreshold; i++ {
+		pool.health.failure(flaky)
+	}
+
+	if h := pool.Health(flaky); h.ConsecutiveFailures != 3 || h.QuarantinedUntil.Before(time.Now()) || h.Score() != 0 {
+		t.Fatalf("flaky relay wasn't quarantined: %+v", h)
+	}
+	if _, err := pool.EnsureRelay(flaky); !errors.Is(err, ErrRelayQuarantined) {
+		t.Fatalf("expected ErrRelayQuarantined, got %v", err)
+	}
+
+	ranked := pool.RankRelays([]string{slow, flaky, "wss://unknown.example.com", fast})
+	if len(ranked) != 3 || ranked[0] != fast || ranked[1] != "wss://unknown.example.com" || ranked[2] != slow {
+		t.Fatalf("unexpected ranking: %v", ranked)
+	}
+
+	// recovering resets the failure streak
+	pool.health.success(flaky)
+	pool.health.failure(flaky)
+	if h := pool.Health(flaky); h.ConsecutiveFailures != 1 {
+		t.Fatalf("unexpected health: %+v", h)
+	}
+}
@@ -0,0 +1,109 @@
+package nostr
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrConnectionLimit is returned when the pool has MaxConnections relays connected, all of them
+// in use, with open subscriptions or calls in progress, and can't connect to another one.
+var ErrConnectionLimit = errors.New("too many relay connections")
+
+// connectionBudget keeps SimplePool within MaxConcurrentDials and MaxConnections.
+type connectionBudget struct {
+	mutex    sync.Mutex
+	dials    chan struct{}
+	pending  int                  // dials in progress, which count as connections
+	lastUsed map[string]time.Time // when each relay was last returned by EnsureRelay
+}
+
+// operation records that a call waiting for the relay is in progress, until the function it
+// returns is called, so the pool doesn't close it meanwhile.
+func (r *Relay) operation() (done func()) {
+	atomic.AddInt32(&r.operations, 1)
+	return func() { atomic.AddInt32(&r.operations, -1) }
+}
+
+// busy tells if the relay has open subscriptions or calls in progress.
+func (r *Relay) busy() bool {
+	return atomic.LoadInt32(&r.operations) > 0 || r.SubscriptionCount() > 0
+}
+
+// touch records that the relay at url was just used.
+func (pool *SimplePool) touch(url string) {
+	pool.budget.mutex.Lock()
+	defer pool.budget.mutex.Unlock()
+	if pool.budget.lastUsed == nil {
+		pool.budget.lastUsed = make(map[string]time.Time)
+	}
+	pool.budget.lastUsed[url] = time.Now()
+}
+
+// reserveConnection makes room for a new connection, closing the idle relay used least recently,
+// if MaxConnections was reached, and waits for a dial slot if MaxConcurrentDials are in progress.
+// release must be called once the dial is done.
+func (pool *SimplePool) reserveConnection(ctx context.Context) (release func(), err error) {
+	budget := &pool.budget
+	budget.mutex.Lock()
+
+	if pool.MaxConnections > 0 {
+		open := budget.pending
+		var idle []*Relay
+		pool.Relays.Range(func(url string, relay *Relay) bool {
+			if relay.ConnectionContext.Err() != nil {
+				pool.Relays.Delete(url)
+				delete(budget.lastUsed, url)
+				return true
+			}
+			open++
+			if !relay.busy() {
+				idle = append(idle, relay)
+			}
+			return true
+		})
+		if open >= pool.MaxConnections {
+			if len(idle) == 0 {
+				budget.mutex.Unlock()
+				return nil, ErrConnectionLimit
+			}
+			oldest := idle[0]
+			for _, relay := range idle[1:] {
+				if budget.lastUsed[relay.URL].Before(budget.lastUsed[oldest.URL]) {
+					oldest = relay
+				}
+			}
+			pool.Relays.Delete(oldest.URL)
+			delete(budget.lastUsed, oldest.URL)
+			oldest.Close()
+		}
+	}
+	budget.pending++
+
+	if pool.MaxConcurrentDials > 0 && budget.dials == nil {
+		budget.dials = make(chan struct{}, pool.MaxConcurrentDials)
+	}
+	dials := budget.dials
+	budget.mutex.Unlock()
+
+	done := func() {
+		budget.mutex.Lock()
+		budget.pending--
+		budget.mutex.Unlock()
+	}
+	if dials == nil {
+		return done, nil
+	}
+	select {
+	case dials <- struct{}{}:
+		return func() {
+			<-dials
+			done()
+		}, nil
+	case <-ctx.Done():
+		done()
+		return nil, ctx.Err()
+	}
+}
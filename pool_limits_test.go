@@ -0,0 +1,89 @@
+package nostr
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPoolMaxConnections(t *testing.T) {
+	var urls []string
+	for i := 0; i < 4; i++ {
+		ws := newWebsocketServer((&fakeRelay{}).handle)
+		defer ws.Close()
+		urls = append(urls, NormalizeURL(ws.URL))
+	}
+
+	pool := NewSimplePool(context.Background())
+	pool.MaxConnections = 2
+	pool.MaxConcurrentDials = 1
+	defer pool.Close()
+
+	ensure := func(url string) *Relay {
+		t.Helper()
+		relay, err := pool.EnsureRelay(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return relay
+	}
+	first := ensure(urls[0])
+	second := ensure(urls[1])
+	ensure(urls[0]) // now the second is the least recently used
+
+	third := ensure(urls[2])
+	if second.ConnectionContext.Err() == nil {
+		t.Fatal("least recently used relay wasn't closed")
+	}
+	if _, ok := pool.Relays.Load(urls[1]); ok {
+		t.Fatal("closed relay is still in the pool")
+	}
+
+	// relays with subscriptions are never closed
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for _, relay := range []*Relay{first, third} {
+		if _, err := relay.Sub(ctx, Filters{{Kinds: []int{1}}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := pool.EnsureRelay(urls[3]); !errors.Is(err, ErrConnectionLimit) {
+		t.Fatalf("expected ErrConnectionLimit, got %v", err)
+	}
+	if first.ConnectionContext.Err() != nil || third.ConnectionContext.Err() != nil {
+		t.Fatal("busy relay was closed")
+	}
+}
+
+func TestPoolMaxConnectionsKeepsRelaysInUse(t *testing.T) {
+	var urls []string
+	for i := 0; i < 2; i++ {
+		ws := newWebsocketServer((&fakeRelay{}).handle)
+		defer ws.Close()
+		urls = append(urls, NormalizeURL(ws.URL))
+	}
+
+	pool := NewSimplePool(context.Background())
+	pool.MaxConnections = 1
+	defer pool.Close()
+
+	first, err := pool.EnsureRelay(urls[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// as if it was waiting for an OK
+	done := first.operation()
+	if _, err := pool.EnsureRelay(urls[1]); !errors.Is(err, ErrConnectionLimit) {
+		t.Fatalf("expected ErrConnectionLimit, got %v", err)
+	}
+	done()
+
+	if _, err := pool.EnsureRelay(urls[1]); err != nil {
+		t.Fatal(err)
+	}
+	if first.ConnectionContext.Err() == nil {
+		t.Fatal("idle relay wasn't closed")
+	}
+}
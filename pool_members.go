@@ -0,0 +1,206 @@
+package nostr
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// poolMembership is the set of relays of a pool and the subscriptions that follow it, see
+// SimplePool.AddRelay and SimplePool.Subscribe.
+type poolMembership struct {
+	mutex         sync.Mutex
+	urls          []string
+	subscriptions map[*memberSubscription]struct{}
+}
+
+// memberSubscription is a subscription made with SimplePool.Subscribe, with one REQ on each
+// relay of the pool.
+type memberSubscription struct {
+	pool    *SimplePool
+	ctx     context.Context
+	filters Filters
+	opts    []SubscriptionOption
+	events  chan *Event
+	seen    *seenFilter
+	wg      sync.WaitGroup
+
+	// guarded by the membership mutex
+	relays map[string]*memberREQ
+	closed bool
+}
+
+// memberREQ is the REQ of a memberSubscription on one relay.
+type memberREQ struct {
+	cancel context.CancelFunc
+}
+
+// AddRelay connects to the relay at url and makes it a member of the pool: the subscriptions
+// made with Subscribe start receiving its events too. It does nothing if the relay is already
+// a member. If the first connection fails the error is returned, but the relay stays a member
+// and the subscriptions keep trying to connect to it until it is removed.
+func (pool *SimplePool) AddRelay(url string) error {
+	nm := NormalizeURL(url)
+	if nm == "" {
+		return fmt.Errorf("invalid relay URL '%s'", url)
+	}
+	if !pool.Policy.Allowed(nm) {
+		return fmt.Errorf("%s: %w", nm, ErrRelayDenied)
+	}
+
+	m := &pool.members
+	m.mutex.Lock()
+	for _, member := range m.urls {
+		if member == nm {
+			m.mutex.Unlock()
+			return nil
+		}
+	}
+	m.urls = append(m.urls, nm)
+	for sub := range m.subscriptions {
+		sub.start(nm)
+	}
+	m.mutex.Unlock()
+
+	_, err := pool.EnsureRelay(nm)
+	return err
+}
+
+// RemoveRelay takes the relay at url out of the pool's members, closing the REQs the
+// subscriptions made with Subscribe had on it. The connection is kept, as it may be used by
+// other subscriptions.
+func (pool *SimplePool) RemoveRelay(url string) {
+	nm := NormalizeURL(url)
+
+	m := &pool.members
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for i, member := range m.urls {
+		if member == nm {
+			m.urls = append(m.urls[:i:i], m.urls[i+1:]...)
+			break
+		}
+	}
+	for sub := range m.subscriptions {
+		if req, ok := sub.relays[nm]; ok {
+			req.cancel()
+			delete(sub.relays, nm)
+		}
+	}
+}
+
+// Members returns the relays added with AddRelay and not removed.
+func (pool *SimplePool) Members() []string {
+	pool.members.mutex.Lock()
+	defer pool.members.mutex.Unlock()
+	return append([]string(nil), pool.members.urls...)
+}
+
+// Subscribe is like SubMany on the pool's members, but follows them as they change: relays
+// added later get the same REQ and the REQs on removed relays are closed, while the returned
+// channel keeps going. It is closed once ctx is canceled.
+func (pool *SimplePool) Subscribe(ctx context.Context, filters Filters, opts ...SubscriptionOption) chan *Event {
+	sub := &memberSubscription{
+		pool:    pool,
+		ctx:     ctx,
+		filters: filters,
+		opts:    opts,
+		events:  make(chan *Event),
+		seen:    newSeenFilter(pool.SeenCapacity),
+		relays:  make(map[string]*memberREQ),
+	}
+
+	m := &pool.members
+	m.mutex.Lock()
+	if m.subscriptions == nil {
+		m.subscriptions = make(map[*memberSubscription]struct{})
+	}
+	m.subscriptions[sub] = struct{}{}
+	for _, url := range m.urls {
+		sub.start(url)
+	}
+	m.mutex.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		m.mutex.Lock()
+		delete(m.subscriptions, sub)
+		sub.closed = true
+		m.mutex.Unlock()
+
+		// no relays are started once closed, so the wait group only goes down
+		sub.wg.Wait()
+		close(sub.events)
+	}()
+
+	return sub.events
+}
+
+// start sends the REQ to the relay at url, and again after a while whenever it can't be sent
+// or ends, until the relay is removed. Must be called with the membership mutex held.
+func (sub *memberSubscription) start(url string) {
+	if sub.closed {
+		return
+	}
+	if _, ok := sub.relays[url]; ok {
+		return
+	}
+	ctx, cancel := context.WithCancel(sub.ctx)
+	req := &memberREQ{cancel: cancel}
+	sub.relays[url] = req
+
+	sub.wg.Add(1)
+	go func() {
+		defer sub.wg.Done()
+		defer func() {
+			cancel()
+			// so the relay can be started again if it is added back
+			sub.pool.members.mutex.Lock()
+			if sub.relays[url] == req {
+				delete(sub.relays, url)
+			}
+			sub.pool.members.mutex.Unlock()
+		}()
+
+		backoff := sub.pool.MemberBackoff
+		if backoff == nil {
+			backoff = DefaultBackoff
+		}
+		for attempt := 0; ; attempt++ {
+			if sub.follow(ctx, url) {
+				attempt = 0
+			}
+			select {
+			case <-time.After(backoff(attempt)):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// follow sends the REQ to the relay at url and forwards its events until it ends, it returns
+// false if the REQ couldn't be sent.
+func (sub *memberSubscription) follow(ctx context.Context, url string) bool {
+	relay, err := sub.pool.ensureRelayCtx(ctx, url)
+	if err != nil {
+		return false
+	}
+	s, err := relay.Sub(ctx, sub.filters, sub.opts...)
+	if err != nil {
+		sub.pool.health.failure(url)
+		return false
+	}
+	for evt := range s.Events {
+		if sub.seen.check(evt.ID) || !intercept(sub.pool.Interceptors, relay, evt) {
+			continue
+		}
+		select {
+		case sub.events <- evt:
+		case <-ctx.Done():
+			return true
+		}
+	}
+	return true
+}
@@ -0,0 +1,127 @@
+package nostr
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestPoolMembers(t *testing.T) {
+	sk, pk := makeKeyPair(t)
+	var relays []*fakeRelay
+	var urls []string
+	for i := 0; i < 2; i++ {
+		evt := Event{Kind: 1, PubKey: pk, CreatedAt: time.Unix(int64(1700000000+i), 0), Content: "stored"}
+		evt.Sign(sk)
+		fr := &fakeRelay{events: []Event{evt}}
+		ws := newWebsocketServer(fr.handle)
+		defer ws.Close()
+		relays = append(relays, fr)
+		urls = append(urls, NormalizeURL(ws.URL))
+	}
+
+	pool := NewSimplePool(context.Background())
+	defer pool.Close()
+	if err := pool.AddRelay(urls[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events := pool.Subscribe(ctx, Filters{{Kinds: []int{1}}})
+	next := func() *Event {
+		select {
+		case evt := <-events:
+			return evt
+		case <-ctx.Done():
+			t.Fatal("no event")
+			return nil
+		}
+	}
+	if evt := next(); evt.ID != relays[0].events[0].ID {
+		t.Fatalf("unexpected event %s", evt.Content)
+	}
+
+	// the new relay gets the REQ and its stored event comes through the same channel
+	if err := pool.AddRelay(urls[1]); err != nil {
+		t.Fatal(err)
+	}
+	if evt := next(); evt.ID != relays[1].events[0].ID {
+		t.Fatalf("unexpected event %s", evt.Content)
+	}
+	if members := pool.Members(); len(members) != 2 {
+		t.Fatalf("unexpected members %v", members)
+	}
+
+	pool.RemoveRelay(urls[0])
+	first, _ := pool.Relays.Load(urls[0])
+	for first.SubscriptionCount() != 0 {
+		if ctx.Err() != nil {
+			t.Fatal("subscription on the removed relay wasn't closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	second, _ := pool.Relays.Load(urls[1])
+	if second.SubscriptionCount() != 1 || len(pool.Members()) != 1 {
+		t.Fatal("other relay affected by the removal")
+	}
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestPoolMemberRetry(t *testing.T) {
+	sk, pk := makeKeyPair(t)
+	evt := Event{Kind: 1, PubKey: pk, CreatedAt: time.Unix(1700000000, 0), Content: "late"}
+	evt.Sign(sk)
+	fr := &fakeRelay{events: []Event{evt}}
+
+	// take a free port and leave nothing listening on it for now
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	pool := NewSimplePool(context.Background())
+	pool.MemberBackoff = func(int) time.Duration { return 200 * time.Millisecond }
+	defer pool.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	events := pool.Subscribe(ctx, Filters{{Kinds: []int{1}}})
+
+	if err := pool.AddRelay("ws://" + addr); err == nil {
+		t.Fatal("connected to a relay that isn't there")
+	}
+	if members := pool.Members(); len(members) != 1 {
+		t.Fatalf("unreachable relay not kept as a member: %v", members)
+	}
+
+	ws := httptest.NewUnstartedServer(&websocket.Server{Handshake: anyOriginHandshake, Handler: fr.handle})
+	ws.Listener.Close()
+	if ws.Listener, err = net.Listen("tcp", addr); err != nil {
+		t.Fatal(err)
+	}
+	ws.Start()
+	defer ws.Close()
+
+	select {
+	case got := <-events:
+		if got.ID != evt.ID {
+			t.Fatalf("unexpected event %s", got.Content)
+		}
+	case <-ctx.Done():
+		t.Fatal("relay wasn't retried")
+	}
+
+	cancel()
+	for range events {
+	}
+}
@@ -0,0 +1,87 @@
+package nostr
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// ErrRelayDenied is returned when trying to use a relay that the pool's RelayPolicy doesn't allow.
+var ErrRelayDenied = errors.New("relay denied by policy")
+
+// RelayPolicy decides which relays a SimplePool can talk to.
+//
+// Entries in Allow and Deny with a scheme, like "wss://relay.example.com", match that relay
+// URL exactly, after normalization. Entries without one, like "example.com", are domains and
+// match all the relays in them and in their subdomains.
+type RelayPolicy struct {
+	// Allow, if not empty, has the only relays that can be used.
+	Allow []string
+	// Deny has relays that are never used, even if in Allow.
+	Deny []string
+	// Check, if set, is called for relays that passed Allow and Deny and can still reject them.
+	Check func(url string) bool
+}
+
+// Allowed tells if the relay at url can be used.
+func (p *RelayPolicy) Allowed(url string) bool {
+	if p == nil {
+		return true
+	}
+	nm := NormalizeURL(url)
+	if nm == "" {
+		return false
+	}
+	host := relayHost(nm)
+
+	for _, entry := range p.Deny {
+		if policyMatches(entry, nm, host) {
+			return false
+		}
+	}
+	if len(p.Allow) > 0 {
+		allowed := false
+		for _, entry := range p.Allow {
+			if policyMatches(entry, nm, host) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	if p.Check != nil {
+		return p.Check(nm)
+	}
+	return true
+}
+
+func policyMatches(entry string, nm string, host string) bool {
+	if strings.Contains(entry, "://") {
+		return NormalizeURL(entry) == nm
+	}
+	domain := strings.ToLower(strings.Trim(strings.TrimSpace(entry), "."))
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+func relayHost(nm string) string {
+	u, err := url.Parse(nm)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// FilterRelays normalizes urls and removes duplicates and the relays the pool's Policy doesn't
+// allow. It should be used on relay hints found in events, nevent codes, NIP-65 lists and so on
+// before connecting to them.
+func (pool *SimplePool) FilterRelays(urls []string) []string {
+	filtered := make([]string, 0, len(urls))
+	for _, url := range uniqueURLs(urls) {
+		if pool.Policy.Allowed(url) {
+			filtered = append(filtered, url)
+		}
+	}
+	return filtered
+}
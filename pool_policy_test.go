@@ -0,0 +1,45 @@
+package nostr
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRelayPolicy(t *testing.T) {
+	policy := &RelayPolicy{
+		Allow: []string{"example.com", "wss://nos.lol"},
+		Deny:  []string{"spam.example.com", "wss://relay.example.com/bad"},
+		Check: func(url string) bool { return !strings.Contains(url, "paid") },
+	}
+
+	for url, expected := range map[string]bool{
+		"wss://relay.example.com":     true,
+		"relay.example.com/":          true,
+		"wss://example.com":           true,
+		"wss://nos.lol":               true,
+		"wss://nos.lol/other":         false,
+		"wss://a.spam.example.com":    false,
+		"wss://relay.example.com/bad": false,
+		"wss://paid.example.com":      false,
+		"wss://notexample.com":        false,
+		"wss://relay.damus.io":        false,
+	} {
+		if policy.Allowed(url) != expected {
+			t.Errorf("%s: expected allowed=%v", url, expected)
+		}
+	}
+
+	pool := NewSimplePool(context.Background())
+	defer pool.Close()
+	pool.Policy = policy
+
+	filtered := pool.FilterRelays([]string{"wss://relay.damus.io", "nos.lol", "wss://nos.lol/", "wss://example.com"})
+	if len(filtered) != 2 || filtered[0] != "wss://nos.lol" || filtered[1] != "wss://example.com" {
+		t.Fatalf("unexpected relays: %v", filtered)
+	}
+	if _, err := pool.EnsureRelay("wss://relay.damus.io"); !errors.Is(err, ErrRelayDenied) {
+		t.Fatalf("expected ErrRelayDenied, got %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package nostr
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+)
+
+// DefaultSeenCapacity is used by pools without SimplePool.SeenCapacity.
+const DefaultSeenCapacity = 20_000
+
+// seenFilter remembers recent event ids in a pair of bloom filters, with about 0.1% false
+// positives, in a fixed amount of memory: once the current filter has capacity ids it becomes
+// the previous one, and the ids only in the filter before are forgotten.
+type seenFilter struct {
+	mutex    sync.Mutex
+	capacity int
+	bits     uint64 // in each filter
+	current  []uint64
+	previous []uint64
+	count    int
+}
+
+// bloom hashes per id, and bits per id, for 0.1% false positives
+const (
+	seenHashes    = 10
+	seenBitsPerID = 14.4
+)
+
+func newSeenFilter(capacity int) *seenFilter {
+	if capacity <= 0 {
+		capacity = DefaultSeenCapacity
+	}
+	bits := uint64(math.Ceil(float64(capacity)*seenBitsPerID/64)) * 64
+	return &seenFilter{
+		capacity: capacity,
+		bits:     bits,
+		current:  make([]uint64, bits/64),
+		previous: make([]uint64, bits/64),
+	}
+}
+
+// check tells if id was seen recently, and remembers it.
+func (s *seenFilter) check(id ID) bool {
+	// ids are hashes already, so they can be used as the hashes for the filter
+	h1 := binary.LittleEndian.Uint64(id[0:8])
+	h2 := binary.LittleEndian.Uint64(id[8:16]) | 1
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	inCurrent, inPrevious := true, true
+	for i := uint64(0); i < seenHashes; i++ {
+		bit := (h1 + i*h2) % s.bits
+		if s.current[bit/64]&(1<<(bit%64)) == 0 {
+			inCurrent = false
+		}
+		if s.previous[bit/64]&(1<<(bit%64)) == 0 {
+			inPrevious = false
+		}
+	}
+	if inCurrent {
+		return true
+	}
+
+	if s.count >= s.capacity {
+		s.current, s.previous = s.previous, s.current
+		for i := range s.current {
+			s.current[i] = 0
+		}
+		s.count = 0
+	}
+	for i := uint64(0); i < seenHashes; i++ {
+		bit := (h1 + i*h2) % s.bits
+		s.current[bit/64] |= 1 << (bit % 64)
+	}
+	s.count++
+	return inPrevious
+}
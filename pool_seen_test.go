@@ -0,0 +1,49 @@
+package nostr
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"testing"
+)
+
+func TestSeenFilter(t *testing.T) {
+	id := func(n int) ID {
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], uint64(n))
+		return sha256.Sum256(b[:])
+	}
+
+	seen := newSeenFilter(1000)
+	for n := 0; n < 1000; n++ {
+		seen.check(id(n))
+	}
+	for n := 0; n < 1000; n++ {
+		if !seen.check(id(n)) {
+			t.Fatalf("id %d was forgotten", n)
+		}
+	}
+
+	falsePositives := 0
+	for n := 1000; n < 2000; n++ {
+		if seen.check(id(n)) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 10 {
+		t.Fatalf("%d false positives out of 1000", falsePositives)
+	}
+
+	// ids are forgotten once two filters' worth of newer ones were seen
+	for n := 2000; n < 4000; n++ {
+		seen.check(id(n))
+	}
+	forgotten := 0
+	for n := 0; n < 100; n++ {
+		if !seen.check(id(n)) {
+			forgotten++
+		}
+	}
+	if forgotten < 90 {
+		t.Fatalf("only %d of 100 old ids were forgotten", forgotten)
+	}
+}
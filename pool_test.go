@@ -0,0 +1,68 @@
+package nostr
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSubManyEoseFastest(t *testing.T) {
+	evt := Event{Kind: 1, CreatedAt: time.Unix(1700000000, 0), Content: "hello"}
+	sk, pk := makeKeyPair(t)
+	evt.PubKey = pk
+	evt.Sign(sk)
+
+	var urls []string
+	for _, delay := range []time.Duration{0, 0, 10 * time.Second} {
+		fr := &fakeRelay{events: []Event{evt}, eoseDelay: delay}
+		ws := newWebsocketServer(fr.handle)
+		defer ws.Close()
+		urls = append(urls, ws.URL)
+	}
+
+	pool := NewSimplePool(context.Background())
+	defer pool.Close()
+
+	start := time.Now()
+	var received []*Event
+	for evt := range pool.SubManyEoseFastest(context.Background(), urls, Filters{{Kinds: []int{1}}}, 2) {
+		received = append(received, evt)
+	}
+	if elapsed := time.Since(start); elapsed > 8*time.Second {
+		t.Fatalf("waited for the slow relay: %s", elapsed)
+	}
+	if len(received) != 1 || received[0].ID != evt.ID {
+		t.Fatalf("unexpected events: %v", received)
+	}
+}
+
+func TestSubManyAnnotated(t *testing.T) {
+	sk, pk := makeKeyPair(t)
+	shared := Event{Kind: 1, PubKey: pk, CreatedAt: time.Unix(1700000000, 0), Content: "everywhere"}
+	shared.Sign(sk)
+	only := Event{Kind: 1, PubKey: pk, CreatedAt: time.Unix(1700000001, 0), Content: "only on the second"}
+	only.Sign(sk)
+
+	first := newWebsocketServer((&fakeRelay{events: []Event{shared}}).handle)
+	defer first.Close()
+	second := newWebsocketServer((&fakeRelay{events: []Event{shared, only}}).handle)
+	defer second.Close()
+
+	pool := NewSimplePool(context.Background())
+	defer pool.Close()
+
+	start := time.Now()
+	sources := make(map[ID]string)
+	for re := range pool.SubManyEoseAnnotated(context.Background(), []string{first.URL, second.URL}, Filters{{Kinds: []int{1}}}) {
+		if re.Relay == nil || re.ReceivedAt.Before(start) || re.ReceivedAt.After(time.Now()) {
+			t.Fatalf("bad annotation %+v", re)
+		}
+		if _, ok := sources[re.ID]; ok {
+			t.Fatalf("%s delivered twice", re.ID)
+		}
+		sources[re.ID] = re.Relay.URL
+	}
+	if len(sources) != 2 || sources[only.ID] != NormalizeURL(second.URL) {
+		t.Fatalf("unexpected sources %v", sources)
+	}
+}
@@ -0,0 +1,175 @@
+package nostr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Profile is what LookupProfile finds about a user.
+type Profile struct {
+	PubKey PubKey
+	// NIP05 is the identifier the profile was looked up with, if any, already verified.
+	NIP05 string
+
+	Metadata *ProfileMetadata
+	Event    *Event // the kind-0 event Metadata comes from
+
+	// from their NIP-65 list, see Client.FetchRelayList
+	ReadRelays  []string
+	WriteRelays []string
+}
+
+// how long NIP-05 identifiers are remembered after being resolved
+const nip05TTL = time.Hour
+
+type nip05Entry struct {
+	pointer    ProfilePointer
+	resolvedAt time.Time
+}
+
+// nip05Cache remembers the NIP-05 identifiers a Client resolved, it is ready to use when zero.
+type nip05Cache struct {
+	once    sync.Once
+	entries *lru[string, nip05Entry]
+}
+
+func (nc *nip05Cache) get(identifier string) (ProfilePointer, bool) {
+	nc.once.Do(nc.init)
+	entry, ok := nc.entries.Get(identifier)
+	if !ok || Now().Sub(entry.resolvedAt) >= nip05TTL {
+		return ProfilePointer{}, false
+	}
+	return entry.pointer, true
+}
+
+func (nc *nip05Cache) add(identifier string, pp ProfilePointer) {
+	nc.once.Do(nc.init)
+	nc.entries.Add(identifier, nip05Entry{pp, Now()})
+}
+
+func (nc *nip05Cache) init() { nc.entries = newLRU[string, nip05Entry](1024) }
+
+// nip05HTTPClient doesn't follow redirects, as NIP-05 requires.
+var nip05HTTPClient = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// QueryNIP05 resolves a NIP-05 identifier, "name@domain" or just "domain" for "_@domain", into
+// the public key and relays published at the domain's /.well-known/nostr.json.
+func QueryNIP05(ctx context.Context, identifier string) (*ProfilePointer, error) {
+	name, domain, found := strings.Cut(identifier, "@")
+	if !found {
+		name, domain = "_", identifier
+	}
+	if name == "" || !strings.Contains(domain, ".") || strings.ContainsAny(domain, "/@?#") {
+		return nil, fmt.Errorf("invalid NIP-05 identifier '%s'", identifier)
+	}
+
+	u := "https://" + domain + "/.well-known/nostr.json?name=" + url.QueryEscape(name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := nip05HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query %s: %w", domain, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s answered %d", domain, res.StatusCode)
+	}
+
+	var result struct {
+		Names  map[string]string   `json:"names"`
+		Relays map[string][]string `json:"relays"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid nostr.json at %s: %w", domain, err)
+	}
+	pubkey, ok := result.Names[name]
+	if !ok {
+		return nil, fmt.Errorf("'%s' not found at %s", name, domain)
+	}
+	pk, err := PubKeyFromHex(pubkey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pubkey for '%s': %w", identifier, err)
+	}
+
+	return &ProfilePointer{PublicKey: pk, Relays: result.Relays[pubkey]}, nil
+}
+
+// FetchProfile returns the latest metadata published by pk, from the Store if possible.
+func (c *Client) FetchProfile(ctx context.Context, pk PubKey) (*ProfileMetadata, error) {
+	evt := c.fetchLatest(ctx, pk, KindSetMetadata, true)
+	if evt == nil {
+		return nil, fmt.Errorf("no metadata found for %s", pk)
+	}
+	return ParseMetadata(*evt)
+}
+
+// LookupProfile finds the metadata and relay list of a user given as a hex public key, an npub,
+// an nprofile or a NIP-05 identifier. Both are taken from the Store if possible, otherwise
+// from our relays and the relays hinted in the input, and the metadata also from the user's
+// write relays. What is found is saved to the Store. It fails if there is no metadata.
+func (c *Client) LookupProfile(ctx context.Context, input string) (*Profile, error) {
+	ptr, nip05, err := c.resolveProfile(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	pk := ptr.PublicKey
+
+	var hints []string
+	for _, url := range ptr.Relays {
+		if url = NormalizeURL(url); url != "" && c.Pool.Policy.Allowed(url) {
+			hints = append(hints, url)
+		}
+	}
+
+	profile := &Profile{PubKey: pk, NIP05: nip05}
+	if relayList := c.fetchLatest(ctx, pk, KindRelayListMetadata, false, hints...); relayList != nil {
+		profile.ReadRelays, profile.WriteRelays = c.relayList(relayList)
+	}
+
+	profile.Event = c.fetchLatest(ctx, pk, KindSetMetadata, false, append(hints, profile.WriteRelays...)...)
+	if profile.Event == nil {
+		return profile, fmt.Errorf("no metadata found for %s", pk)
+	}
+	if profile.Metadata, err = ParseMetadata(*profile.Event); err != nil {
+		return profile, err
+	}
+	return profile, nil
+}
+
+// resolveProfile turns the input of LookupProfile into a pointer, also returning the NIP-05
+// identifier if it was one.
+func (c *Client) resolveProfile(ctx context.Context, input string) (ProfilePointer, string, error) {
+	input = strings.TrimSpace(input)
+	if pk, err := PubKeyFromHex(input); err == nil {
+		return ProfilePointer{PublicKey: pk}, "", nil
+	}
+	if ptr, err := ParsePointer(input); err == nil {
+		if pp, ok := ptr.(ProfilePointer); ok {
+			return pp, "", nil
+		}
+		return ProfilePointer{}, "", fmt.Errorf("'%s' is not a profile", input)
+	}
+
+	identifier := strings.ToLower(input)
+	if pp, ok := c.nip05.get(identifier); ok {
+		return pp, identifier, nil
+	}
+	pp, err := QueryNIP05(ctx, identifier)
+	if err != nil {
+		return ProfilePointer{}, "", err
+	}
+	c.nip05.add(identifier, *pp)
+	return *pp, identifier, nil
+}
@@ -0,0 +1,69 @@
+package nostr
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetchProfileNIP05(t *testing.T) {
+	fr := &fakeRelay{}
+	ws := newWebsocketServer(fr.handle)
+	defer ws.Close()
+
+	signer := newTestSigner(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	meta := Event{Kind: KindSetMetadata, CreatedAt: time.Unix(1672068534, 0), Content: `{"name":"bob"}`}
+	signer.SignEvent(ctx, &meta)
+	fr.events = append(fr.events, meta)
+
+	var queries int32
+	wellKnown := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&queries, 1)
+		if r.URL.Path != "/.well-known/nostr.json" || r.URL.Query().Get("name") != "bob" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintf(w, `{"names":{"bob":"%s"},"relays":{"%s":["%s"]}}`, signer.pk.Hex(), signer.pk.Hex(), ws.URL)
+	}))
+	defer wellKnown.Close()
+
+	// every domain is served by our test server
+	defer func(original *http.Client) { nip05HTTPClient = original }(nip05HTTPClient)
+	nip05HTTPClient = &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return net.Dial(network, wellKnown.Listener.Addr().String())
+		},
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}}
+
+	// the client knows no relays, the profile is found through the relays in nostr.json
+	client := NewClient(context.Background(), nil, nil, nil)
+	defer client.Close()
+	for i := 0; i < 2; i++ {
+		profile, err := client.LookupProfile(ctx, "Bob@example.com")
+		if err != nil {
+			t.Fatalf("failed to fetch profile: %s", err)
+		}
+		if profile.PubKey != signer.pk || profile.NIP05 != "bob@example.com" || profile.Metadata.Name != "bob" {
+			t.Errorf("wrong profile %+v", profile)
+		}
+	}
+	if n := atomic.LoadInt32(&queries); n != 1 {
+		t.Errorf("identifier should have been resolved once, got %d queries", n)
+	}
+
+	if _, err := client.LookupProfile(ctx, "alice@example.com"); err == nil {
+		t.Errorf("alice doesn't exist")
+	}
+	if _, err := client.LookupProfile(ctx, "note1xyz"); err == nil {
+		t.Errorf("not a profile")
+	}
+}
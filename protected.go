@@ -0,0 +1,60 @@
+package nostr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrProtectedEvent is returned when publishing a NIP-70 protected event to a relay that we
+// aren't authenticated to as the event's author, since the relay must reject it.
+var ErrProtectedEvent = errors.New("protected event needs authentication as its author")
+
+// IsProtected tells if the event has the NIP-70 "-" tag, meaning only its author can publish it
+// to relays, after authenticating.
+func (evt *Event) IsProtected() bool {
+	for _, tag := range evt.Tags {
+		if len(tag) == 1 && tag[0] == "-" {
+			return true
+		}
+	}
+	return false
+}
+
+// Protect adds the NIP-70 "-" tag to the event, if it isn't there. It must be called before
+// signing.
+func (evt *Event) Protect() {
+	if !evt.IsProtected() {
+		evt.Tags = append(evt.Tags, Tag{"-"})
+	}
+}
+
+// ProtectEvents is a PublishHook that marks every event as protected, see Event.Protect.
+func ProtectEvents(ctx context.Context, evt *Event) error {
+	evt.Protect()
+	return nil
+}
+
+// AuthenticatedAs tells if the relay accepted our NIP-42 authentication as pk in the current
+// connection.
+func (r *Relay) AuthenticatedAs(pk PubKey) bool {
+	_, ok := r.authenticated.Load(pk)
+	return ok
+}
+
+// guardProtected makes sure we are authenticated as the author before publishing a protected
+// event, answering the relay's challenge first if we know how to.
+func (r *Relay) guardProtected(ctx context.Context, event Event) error {
+	if !event.IsProtected() || r.AuthenticatedAs(event.PubKey) {
+		return nil
+	}
+	if r.authHandler != nil && r.Challenge() != "" {
+		if err := r.Authenticate(ctx, r.authHandler); err != nil {
+			return fmt.Errorf("%w: %v", ErrProtectedEvent, err)
+		}
+	}
+	if !r.AuthenticatedAs(event.PubKey) {
+		return ErrProtectedEvent
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package nostr
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPublishProtected(t *testing.T) {
+	priv, pub := makeKeyPair(t)
+	evt := Event{Kind: 1, Content: "only from me", CreatedAt: time.Unix(1672068534, 0), PubKey: pub}
+	ProtectEvents(context.Background(), &evt)
+	evt.Protect()
+	if !evt.IsProtected() || len(evt.Tags) != 1 {
+		t.Fatalf("event should have one '-' tag, got %v", evt.Tags)
+	}
+	evt.Sign(priv)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// a relay that doesn't do NIP-42 won't take it
+	plain := newWebsocketServer((&fakeRelay{}).handle)
+	defer plain.Close()
+	relay := mustRelayConnect(plain.URL)
+	defer relay.Close()
+	if _, err := relay.Publish(ctx, evt); !errors.Is(err, ErrProtectedEvent) {
+		t.Errorf("expected ErrProtectedEvent, got %v", err)
+	}
+
+	// one that does takes it once we authenticate
+	authing := newWebsocketServer((&fakeRelay{challenge: "chachacha"}).handle)
+	defer authing.Close()
+	relay, err := RelayConnect(ctx, authing.URL, WithAuthHandler(func(ctx context.Context, evt *Event) error {
+		evt.PubKey = pub
+		return evt.Sign(priv)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer relay.Close()
+	for relay.Challenge() == "" && ctx.Err() == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if status, err := relay.Publish(ctx, evt); err != nil || status != PublishStatusSucceeded {
+		t.Errorf("failed to publish after authenticating: %s, %v", status, err)
+	}
+	if !relay.AuthenticatedAs(pub) {
+		t.Errorf("should be authenticated")
+	}
+}
@@ -0,0 +1,110 @@
+package nostr
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// PublishHook is called with events before they are published. It can change them, adding
+// tags for example, or return an error to refuse publishing them. Events that are already
+// signed can't be changed, so on a Relay, where they always are, hooks can only refuse them.
+type PublishHook func(ctx context.Context, evt *Event) error
+
+// WithPublishHooks makes Publish run hooks, in order, on every event. Relay.Publish only takes
+// signed events, so hooks that change them, like AddClientTag, make Connect fail: give those to
+// Client.PublishHooks instead, which runs them before signing.
+func WithPublishHooks(hooks ...PublishHook) RelayOption {
+	return func(r *Relay) {
+		r.publishHooks = append(r.publishHooks, hooks...)
+	}
+}
+
+// AddClientTag is a PublishHook that tags events with the client that made them, as in NIP-89,
+// unless they have a "client" tag already. address is the "31990:<pubkey>:<d>" address of the
+// client's handler information event, it can be empty.
+func AddClientTag(name string, address string) PublishHook {
+	tag := Tag{"client", name}
+	if address != "" {
+		tag = append(tag, address)
+	}
+	return func(_ context.Context, evt *Event) error {
+		if evt.Tags.GetFirst([]string{"client"}) == nil {
+			evt.Tags = append(evt.Tags, tag)
+		}
+		return nil
+	}
+}
+
+// StripClientTag is a PublishHook that removes the NIP-89 "client" tags from events, so they
+// don't tell which client was used.
+func StripClientTag(_ context.Context, evt *Event) error {
+	tags := make(Tags, 0, len(evt.Tags))
+	for _, tag := range evt.Tags {
+		if len(tag) == 0 || tag[0] != "client" {
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) != len(evt.Tags) {
+		evt.Tags = tags
+	}
+	return nil
+}
+
+// AddExpiration is a PublishHook that makes events expire, as in NIP-40, ttl after they are
+// created, unless they have an expiration already.
+func AddExpiration(ttl time.Duration) PublishHook {
+	return func(_ context.Context, evt *Event) error {
+		if _, ok := evt.Expiration(); !ok {
+			evt.Tags = append(evt.Tags, Tag{"expiration", strconv.FormatInt(evt.CreatedAt.Add(ttl).Unix(), 10)})
+		}
+		return nil
+	}
+}
+
+// MaxContentLength is a PublishHook that refuses events with more than max bytes of content.
+func MaxContentLength(max int) PublishHook {
+	return func(_ context.Context, evt *Event) error {
+		if len(evt.Content) > max {
+			return fmt.Errorf("content has %d bytes, more than %d", len(evt.Content), max)
+		}
+		return nil
+	}
+}
+
+// checkRelayPublishHooks tells if hooks only refuse events, by running them on a sample one,
+// as the ones changing events would make every Relay.Publish fail.
+func checkRelayPublishHooks(ctx context.Context, hooks []PublishHook) error {
+	for i, hook := range hooks {
+		sample := Event{Kind: KindTextNote, CreatedAt: Now(), Tags: Tags{}, Content: "sample"}
+		sample.ID = sample.GetID()
+		hook(ctx, &sample)
+		if sample.GetID() != sample.ID {
+			return fmt.Errorf("publish hook %d changes events, which are signed already on a Relay, use Client.PublishHooks instead", i)
+		}
+	}
+	return nil
+}
+
+// runPublishHooks runs hooks on evt. When signed is set they run on a copy, so evt is left as
+// it was, and it fails if they change it.
+func runPublishHooks(ctx context.Context, hooks []PublishHook, evt *Event, signed bool) error {
+	if signed {
+		copied := *evt
+		copied.Tags = make(Tags, len(evt.Tags))
+		for i, tag := range evt.Tags {
+			copied.Tags[i] = append(Tag{}, tag...)
+		}
+		evt = &copied
+	}
+	for _, hook := range hooks {
+		if err := hook(ctx, evt); err != nil {
+			return fmt.Errorf("event refused: %w", err)
+		}
+	}
+	if signed && evt.GetID() != evt.ID {
+		return fmt.Errorf("publish hooks can't change event %s, it is signed already", evt.ID)
+	}
+	return nil
+}
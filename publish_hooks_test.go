@@ -0,0 +1,94 @@
+package nostr
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientPublishHooks(t *testing.T) {
+	fr := &fakeRelay{}
+	ws := newWebsocketServer(fr.handle)
+	defer ws.Close()
+
+	client := NewClient(context.Background(), newTestSigner(t), nil, []string{ws.URL})
+	defer client.Close()
+	client.PublishHooks = []PublishHook{AddClientTag("test", "31990:"+strings.Repeat("a", 64)+":test"), AddExpiration(time.Hour), MaxContentLength(10)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	evt := Event{Kind: 1, CreatedAt: time.Unix(1700000000, 0), Content: "short"}
+	if err := client.Publish(ctx, &evt); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := evt.CheckSignature(); !ok {
+		t.Fatal("event wasn't signed after the hooks ran")
+	}
+	if tag := evt.Tags.GetFirst([]string{"client", "test"}); tag == nil || len(*tag) != 3 {
+		t.Errorf("no client tag in %v", evt.Tags)
+	}
+	if expiration, ok := evt.Expiration(); !ok || expiration.Unix() != 1700003600 {
+		t.Errorf("wrong expiration in %v", evt.Tags)
+	}
+
+	long := Event{Kind: 1, CreatedAt: time.Now(), Content: "too long to be published"}
+	if err := client.Publish(ctx, &long); err == nil || !strings.Contains(err.Error(), "more than 10") {
+		t.Fatalf("expected the event to be refused, got %v", err)
+	}
+
+	// signed events can't be changed, they are refused and left as they were
+	sk, pk := makeKeyPair(t)
+	signed := Event{Kind: 1, PubKey: pk, CreatedAt: time.Now(), Content: "signed"}
+	signed.Sign(sk)
+	if err := client.Publish(ctx, &signed); err == nil {
+		t.Fatal("a signed event the hooks would change was published")
+	}
+	if len(signed.Tags) != 0 {
+		t.Fatalf("the hooks changed the signed event: %v", signed.Tags)
+	}
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	if len(fr.events) != 1 {
+		t.Fatalf("relay got %d events, expected 1", len(fr.events))
+	}
+}
+
+func TestRelayPublishHooksCantChangeSignedEvents(t *testing.T) {
+	ws := newWebsocketServer((&fakeRelay{}).handle)
+	defer ws.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := RelayConnect(ctx, ws.URL, WithPublishHooks(AddClientTag("test", ""))); err == nil {
+		t.Fatal("a hook changing events should be rejected on a relay")
+	}
+
+	relay, err := RelayConnect(ctx, ws.URL, WithPublishHooks(MaxContentLength(10)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer relay.Close()
+
+	sk, pk := makeKeyPair(t)
+	long := Event{Kind: 1, PubKey: pk, CreatedAt: time.Now(), Content: "too long to be published"}
+	long.Sign(sk)
+	if status, err := relay.Publish(ctx, long); status != PublishStatusFailed || err == nil {
+		t.Fatalf("refused event was published: %s, %v", status, err)
+	}
+
+	evt := Event{Kind: 1, PubKey: pk, CreatedAt: time.Now(), Content: "signed"}
+	evt.Sign(sk)
+	if status, err := relay.Publish(ctx, evt); status != PublishStatusSucceeded {
+		t.Fatalf("publish failed: %s, %v", status, err)
+	}
+}
+
+func TestStripClientTag(t *testing.T) {
+	evt := Event{Tags: Tags{{"client", "leaky"}, {"t", "nostr"}}}
+	StripClientTag(context.Background(), &evt)
+	if len(evt.Tags) != 1 || evt.Tags[0][0] != "t" {
+		t.Fatalf("unexpected tags %v", evt.Tags)
+	}
+}
@@ -0,0 +1,193 @@
+package nostr
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// how many events are taken from the store at a time by Rebroadcast
+var rebroadcastPageSize = 500
+
+type rebroadcastOptions struct {
+	pool     *SimplePool
+	rate     float64
+	progress func(stats RebroadcastStats)
+}
+
+// RebroadcastOption customizes Rebroadcast.
+type RebroadcastOption func(*rebroadcastOptions)
+
+// WithRebroadcastPool makes Rebroadcast use the relays of pool instead of connecting to them itself.
+func WithRebroadcastPool(pool *SimplePool) RebroadcastOption {
+	return func(o *rebroadcastOptions) {
+		o.pool = pool
+	}
+}
+
+// WithRebroadcastRate makes Rebroadcast publish at most perSecond events per second, so the
+// relays don't rate limit us. By default it goes as fast as the relays answer.
+func WithRebroadcastRate(perSecond float64) RebroadcastOption {
+	return func(o *rebroadcastOptions) {
+		o.rate = perSecond
+	}
+}
+
+// WithRebroadcastProgress makes Rebroadcast call fn with the stats so far after each event.
+func WithRebroadcastProgress(fn func(stats RebroadcastStats)) RebroadcastOption {
+	return func(o *rebroadcastOptions) {
+		o.progress = fn
+	}
+}
+
+// RebroadcastStats tells how a Rebroadcast went.
+type RebroadcastStats struct {
+	// Events is how many events were taken from the store.
+	Events int
+
+	// Accepted and Rejected are how many events each relay took and refused. Events a relay
+	// didn't answer for are in neither.
+	Accepted map[string]int
+	Rejected map[string]int
+}
+
+func (stats RebroadcastStats) clone() RebroadcastStats {
+	clone := RebroadcastStats{
+		Events:   stats.Events,
+		Accepted: make(map[string]int, len(stats.Accepted)),
+		Rejected: make(map[string]int, len(stats.Rejected)),
+	}
+	for url, n := range stats.Accepted {
+		clone.Accepted[url] = n
+	}
+	for url, n := range stats.Rejected {
+		clone.Rejected[url] = n
+	}
+	return clone
+}
+
+// Rebroadcast publishes the events in store matching filter to relays, newest first, e.g. to
+// move a profile to new relays or to back it up. Events are read from the store a few at a
+// time, so it works with big stores, and filter.Limit, if set, caps how many are published.
+// It stops when ctx is canceled, returning ctx.Err() along with what was done so far.
+func Rebroadcast(ctx context.Context, store Store, filter Filter, relays []string, opts ...RebroadcastOption) (RebroadcastStats, error) {
+	options := rebroadcastOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.pool == nil {
+		options.pool = NewSimplePool(ctx)
+		defer options.pool.Close()
+	}
+
+	relays = uniqueURLs(relays)
+	stats := RebroadcastStats{
+		Accepted: make(map[string]int, len(relays)),
+		Rejected: make(map[string]int, len(relays)),
+	}
+
+	var tick <-chan time.Time
+	if options.rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / options.rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	err := storedEvents(ctx, store, filter, func(evt *Event) error {
+		if tick != nil && stats.Events > 0 {
+			select {
+			case <-tick:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		stats.Events++
+
+		var mu sync.Mutex
+		wg := sync.WaitGroup{}
+		for _, url := range relays {
+			wg.Add(1)
+			go func(url string) {
+				defer wg.Done()
+				relay, err := options.pool.ensureRelayCtx(ctx, url)
+				if err != nil {
+					return
+				}
+				status, _ := relay.Publish(ctx, *evt)
+				mu.Lock()
+				defer mu.Unlock()
+				switch status {
+				case PublishStatusSucceeded:
+					stats.Accepted[url]++
+				case PublishStatusFailed:
+					stats.Rejected[url]++
+				}
+			}(url)
+		}
+		wg.Wait()
+
+		if options.progress != nil {
+			options.progress(stats.clone())
+		}
+		return ctx.Err()
+	})
+	return stats, err
+}
+
+// storedEvents calls fn with each event in store matching filter, newest first, querying
+// them in pages, until fn returns an error.
+func storedEvents(ctx context.Context, store Store, filter Filter, fn func(evt *Event) error) error {
+	remaining := filter.Limit
+	page := filter
+	// ids already seen in the second of the oldest event of the last page, which comes again
+	// in the next one since until is inclusive
+	var boundary map[ID]struct{}
+	for {
+		page.Limit = rebroadcastPageSize
+		if remaining > 0 && remaining < page.Limit {
+			page.Limit = remaining
+		}
+		events, err := store.QueryEvents(ctx, page)
+		if err != nil {
+			return err
+		}
+
+		fresh := 0
+		for _, evt := range events {
+			if _, ok := boundary[evt.ID]; ok {
+				continue
+			}
+			fresh++
+			if err := fn(evt); err != nil {
+				return err
+			}
+			if remaining > 0 {
+				if remaining--; remaining == 0 {
+					return nil
+				}
+			}
+		}
+		if len(events) < page.Limit {
+			return nil
+		}
+
+		oldest := events[len(events)-1].CreatedAt
+		nextBoundary := make(map[ID]struct{})
+		for _, evt := range events {
+			if evt.CreatedAt.Equal(oldest) {
+				nextBoundary[evt.ID] = struct{}{}
+			}
+		}
+		if fresh == 0 {
+			// a whole page in the same second, there's no way to get the rest of it
+			oldest = oldest.Add(-time.Second)
+			nextBoundary = nil
+		} else if prev := page.Until; prev != nil && prev.Equal(oldest) {
+			for id := range boundary {
+				nextBoundary[id] = struct{}{}
+			}
+		}
+		boundary = nextBoundary
+		page.Until = &oldest
+	}
+}
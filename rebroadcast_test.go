@@ -0,0 +1,60 @@
+package nostr
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRebroadcast(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	// more than a page, with many events in the same second
+	defer func(size int) { rebroadcastPageSize = size }(rebroadcastPageSize)
+	rebroadcastPageSize = 20
+	priv, pub := makeKeyPair(t)
+	store := NewMemoryStore()
+	for i := 0; i < rebroadcastPageSize*3+5; i++ {
+		evt := &Event{Kind: 1, Content: fmt.Sprintf("note %d", i), CreatedAt: time.Unix(1672068534+int64(i/7), 0), PubKey: pub}
+		evt.Sign(priv)
+		store.SaveEvent(ctx, evt)
+	}
+	other := &Event{Kind: 7, Content: "+", CreatedAt: time.Unix(1672068534, 0), PubKey: pub}
+	other.Sign(priv)
+	store.SaveEvent(ctx, other)
+
+	fr := &fakeRelay{}
+	ws := newWebsocketServer(fr.handle)
+	defer ws.Close()
+
+	calls := 0
+	stats, err := Rebroadcast(ctx, store, Filter{Kinds: []int{1}}, []string{ws.URL},
+		WithRebroadcastProgress(func(RebroadcastStats) { calls++ }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := NormalizeURL(ws.URL)
+	if stats.Events != rebroadcastPageSize*3+5 || stats.Accepted[url] != stats.Events || calls != stats.Events {
+		t.Errorf("unexpected stats %d events, %v accepted, %d progress calls", stats.Events, stats.Accepted, calls)
+	}
+
+	fr.mu.Lock()
+	seen := make(map[ID]struct{})
+	for _, evt := range fr.events {
+		if evt.Kind != 1 {
+			t.Errorf("event of kind %d shouldn't be published", evt.Kind)
+		}
+		seen[evt.ID] = struct{}{}
+	}
+	if len(seen) != len(fr.events) || len(seen) != stats.Events {
+		t.Errorf("relay got %d events, %d distinct", len(fr.events), len(seen))
+	}
+	fr.mu.Unlock()
+
+	stats, err = Rebroadcast(ctx, store, Filter{Kinds: []int{1}, Limit: 10}, []string{ws.URL})
+	if err != nil || stats.Events != 10 {
+		t.Errorf("limit not respected: %d events, %v", stats.Events, err)
+	}
+}
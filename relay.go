@@ -2,18 +2,25 @@ package nostr
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/recws-org/recws"
 	s "github.com/SaveTheRbtz/generic-sync-map-go"
 	"github.com/gorilla/websocket"
 )
 
+// ErrRelayClosed is returned by the methods of a Relay after it is closed.
+var ErrRelayClosed = errors.New("relay connection closed")
+
 type Status int
 
 const (
@@ -22,8 +29,6 @@ const (
 	PublishStatusSucceeded Status = 1
 )
 
-var subscriptionIdCounter = 0
-
 func (s Status) String() string {
 	switch s {
 	case PublishStatusSent:
@@ -41,26 +46,86 @@ type Relay struct {
 	URL           string
 	RequestHeader http.Header // e.g. for origin header
 
-	Connection    *recws.RecConn
+	// Deprecated: the connection is managed by the Relay, which sends and reads everything
+	// through it. It is kept for code that wrote to it directly, and is no longer a
+	// *recws.RecConn, but has the same WriteMessage, IsConnected and Close methods.
+	Connection *ReconnectingConn
+
+	conn          *ReconnectingConn
 	subscriptions s.MapOf[string, *Subscription]
 
 	Challenges        chan string // NIP-42 Challenges
-	Notices           chan string
-	Errors 			chan error
+	Notices           chan string // only the latest few are kept while nobody reads it, see WithNoticeHandler
+	Errors 			chan error // of *RelayError, only the latest few are kept while nobody reads it, see WithErrorHandler
 	ConnectionContext context.Context // will be canceled when the connection closes
+	closeConnection   context.CancelFunc
+
+	// subscription ids are this random prefix followed by a sequential number
+	subscriptionPrefix     string
+	subscriptionPrefixOnce sync.Once
+	subscriptionCounter    int64
 
-	okCallbacks s.MapOf[string, func(bool, string)]
+	okCallbacks s.MapOf[ID, func(bool, string)]
+	pongWaiters s.MapOf[string, chan struct{}]
+	info        relayInformation
+	challenge   atomic.Value
+
+	// who we authenticated as in the current connection, see AuthenticatedAs
+	authenticated s.MapOf[PubKey, struct{}]
+	authMutex     sync.Mutex
+	answered      map[PubKey]string // the last challenge answered successfully by each key
+
+	closeMutex sync.RWMutex // held to send to the channels above, so Close can close them
+	closed     bool
 
 	// custom things that aren't often used
 	//
 	AssumeValid bool // this will skip verifying signatures for events received from this relay
+
+	// filters with more ids or authors than this are split into smaller ones, 0 means no limit.
+	// limits advertised by the relay through NIP-11 are respected regardless of this.
+	MaxFilterItems int
+
+	// SignatureChecker, if set, is used instead of the signature cache (see WithSignatureCache)
+	// to decide if received events are valid, see AllOf. It isn't called if
+	// AssumeValid is set.
+	SignatureChecker SignatureChecker
+
+	// OnSend and OnReceive, if set, are called with every text frame exactly as it is sent to or
+	// received from the relay, to debug the protocol. They must not keep or modify raw.
+	OnSend    func(raw []byte)
+	OnReceive func(raw []byte)
+
+	noticeHandler   func(notice string)
+	authHandler     func(ctx context.Context, evt *Event) error
+	paymentHandler  PaymentHandler
+	tlsConfig       *tls.Config
+	createdAtWindow *CreatedAtWindow
+	strict          bool
+	tracer          Tracer
+	backoff         Backoff
+	maxReconnects   int
+	maxMessageSize  int64 // negative means no limit
+	maxEventSize    int
+	closeOversized  bool
+	errorHandler    func(err *RelayError)
+	stateHandler    func(state ConnectionState)
+	statusWatchers  statusWatchers
+	verifier        *SignatureVerifier
+	signatureCache  *SignatureCache
+	deliveries      chan delivery // see Relay.deliver
+	outgoing        *outgoingBuffer
+	interceptors    []EventInterceptor
+	publishHooks    []PublishHook
+	defaultLimits   bool
+	operations      int32 // Publish, Auth, Count and Ping calls in progress, see Relay.busy
 }
 
-// RelayConnect returns a relay object connected to url.
+// RelayConnect returns a relay object connected to url, configured with opts.
 // Once successfully connected, cancelling ctx has no effect.
 // To close the connection, call r.Close().
-func RelayConnect(ctx context.Context, url string) (*Relay, error) {
-	r := &Relay{URL: NormalizeURL(url)}
+func RelayConnect(ctx context.Context, url string, opts ...RelayOption) (*Relay, error) {
+	r := NewRelay(url, opts...)
 	err := r.Connect(ctx)
 	return r, err
 }
@@ -69,11 +134,75 @@ func (r *Relay) String() string {
 	return r.URL
 }
 
+// send writes message to the relay as JSON, or queues it while disconnected, see
+// WithOutgoingBuffer.
+func (r *Relay) send(message []interface{}) error {
+	if r.isClosed() {
+		return ErrRelayClosed
+	}
+	raw, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	if command, _ := message[0].(string); r.outgoing != nil && (command == "EVENT" || command == "REQ" || command == "CLOSE") {
+		frame := outgoingFrame{raw: raw, command: command}
+		if command != "EVENT" {
+			frame.subID, _ = message[1].(string)
+		}
+		return r.outgoing.send(frame, r.writeFrame)
+	}
+	return r.writeFrame(raw)
+}
+
+// writeFrame writes raw to the relay, passing it to OnSend first.
+func (r *Relay) writeFrame(raw []byte) error {
+	if r.OnSend != nil {
+		r.OnSend(raw)
+	}
+	return r.conn.WriteMessage(websocket.TextMessage, raw)
+}
+
+// emit sends v to ch, one of the channels of r, unless r is closed or gets closed meanwhile.
+func emit[T any](r *Relay, ch chan T, v T) {
+	r.closeMutex.RLock()
+	defer r.closeMutex.RUnlock()
+	if r.closed {
+		return
+	}
+	select {
+	case ch <- v:
+	case <-r.ConnectionContext.Done():
+	}
+}
+
+// offer sends v to ch, one of the channels of r, if it has room, without waiting.
+func offer[T any](r *Relay, ch chan T, v T) {
+	r.closeMutex.RLock()
+	defer r.closeMutex.RUnlock()
+	if r.closed {
+		return
+	}
+	select {
+	case ch <- v:
+	default:
+	}
+}
+
 // Connect tries to establish a websocket connection to r.URL.
 // If the context expires before the connection is complete, an error is returned.
 // Once successfully connected, context expiration has no effect: call r.Close
-// to close the connection.
-func (r *Relay) Connect(ctx context.Context) error {
+// to close the connection. opts, if given, are applied before connecting.
+func (r *Relay) Connect(ctx context.Context, opts ...RelayOption) (err error) {
+	for _, opt := range opts {
+		opt(r)
+	}
+	if err := checkRelayPublishHooks(ctx, r.publishHooks); err != nil {
+		return err
+	}
+
+	ctx, span := r.startSpan(ctx, "nostr.Connect")
+	defer func() { endSpan(span, err) }()
+
 	connectionContext, cancel := context.WithCancel(context.Background())
 	r.ConnectionContext = connectionContext
 
@@ -89,54 +218,163 @@ func (r *Relay) Connect(ctx context.Context) error {
 		defer cancel()
 	}
 
-	ws := recws.RecConn{
-		KeepAliveTimeout: 10 * time.Second,
-		RecIntvlMin: 5 * time.Second,
+	backoff := r.backoff
+	if backoff == nil {
+		backoff = DefaultBackoff
+	}
+	if r.maxMessageSize == 0 {
+		r.maxMessageSize = DefaultMaxMessageSize
 	}
-	ws.Dial(r.URL, r.RequestHeader)
-	
+	var connections int32
+	ws := &ReconnectingConn{
+		ctx:            connectionContext,
+		url:            r.URL,
+		header:         r.RequestHeader,
+		dialer:         &websocket.Dialer{Proxy: http.ProxyFromEnvironment, TLSClientConfig: r.tlsConfig},
+		backoff:        backoff,
+		maxAttempts:    r.maxReconnects,
+		maxMessageSize: r.maxMessageSize,
+		onState:        r.setStatus,
+		onConnect: func(socket *websocket.Conn) {
+			socket.SetPongHandler(r.handlePong)
+
+			if atomic.AddInt32(&connections, 1) == 1 {
+				return
+			}
+			_, span := r.startSpan(connectionContext, "nostr.Reconnect")
+			span.End()
+
+			// a new connection must be authenticated again
+			r.authenticated.Range(func(pk PubKey, _ struct{}) bool {
+				r.authenticated.Delete(pk)
+				return true
+			})
+			r.authMutex.Lock()
+			r.answered = nil
+			r.authMutex.Unlock()
+
+			if r.outgoing != nil {
+				// if we were authenticated the relay will want it again before taking our events
+				waitAuth := r.authHandler != nil && r.Challenge() != ""
+				r.challenge.Store("")
+				r.outgoing.resetAuth()
+				go r.flushOutgoing(waitAuth)
+			}
+
+			// resume the subscriptions that can be resumed
+			// (subscriptions split in many REQs show up more than once)
+			resumable := make(map[*Subscription]struct{})
+			r.subscriptions.Range(func(_ string, sub *Subscription) bool {
+				if sub.cursorStore != nil {
+					resumable[sub] = struct{}{}
+				}
+				return true
+			})
+			for sub := range resumable {
+				go sub.resume()
+			}
+		},
+	}
+
 	r.Challenges = make(chan string)
-	r.Notices = make(chan string)
-	r.Errors = make(chan error) 
+	r.Notices = make(chan string, noticesBuffer)
+	r.Errors = make(chan error, errorsBuffer)
 
-	r.Connection = &ws
+	r.conn = ws
+	r.Connection = ws
+	r.closeConnection = cancel
+
+	if err := ws.dial(ctx, ConnectionConnecting); err != nil {
+		cancel()
+		ws.Close()
+		return fmt.Errorf("error opening websocket to '%s': %w", r.URL, err)
+	}
 
 	// ping every 29 seconds
-	ticker := time.NewTicker(29 * time.Second)
-	defer ticker.Stop()
 	go func() {
+		ticker := time.NewTicker(29 * time.Second)
+		defer ticker.Stop()
 		for {
 			select {
 			case <-ticker.C:
+				if !ws.IsConnected() {
+					// reconnecting
+					continue
+				}
 				err := ws.WriteMessage(websocket.PingMessage, nil)
 				if err != nil {
-					log.Printf("error writing ping: %v; closing websocket", err)
-					return
+					log.Printf("error writing ping: %v; reconnecting websocket", err)
+					ws.drop()
 				}
+			case <-connectionContext.Done():
+				return
 			}
 		}
 	}()
 
+	if r.verifier == nil {
+		r.verifier = DefaultSignatureVerifier
+	}
+	if r.signatureCache == nil {
+		r.signatureCache = DefaultSignatureCache
+	}
+	r.deliveries = make(chan delivery, 64)
+	go r.runDeliveries()
+
 	// handling received messages
 	go func() {
+		defer cancel()
+
 		for {
 			typ, message, err := ws.ReadMessage()
+			if errors.Is(err, ErrMessageTooLarge) {
+				r.reportError(ErrorMessageTooLarge, err)
+				if r.closeOversized {
+					r.Close()
+					return
+				}
+				continue
+			}
 			if err != nil {
-				go func() {
-					r.Errors <- err
-				}()
+				if connectionContext.Err() != nil {
+					return
+				}
+				r.reportError(ErrorRead, err)
+
+				// dial again until it works, unless we are closed
+				err := ws.reconnect(func(err error) { r.reportError(ErrorReconnect, err) })
+				if errors.Is(err, ErrReconnectExhausted) {
+					r.reportError(ErrorReconnectExhausted, err)
+					r.Close()
+				}
+				if err != nil {
+					return
+				}
 				continue
 			}
-				
+
 			if typ == websocket.PingMessage {
 				ws.WriteMessage(websocket.PongMessage, nil)
 				continue
 			}
 
-			if typ != websocket.TextMessage || len(message) == 0 || message[0] != '[' {
+			if typ != websocket.TextMessage {
+				continue
+			}
+			if r.OnReceive != nil {
+				r.OnReceive(message)
+			}
+			if len(message) == 0 || message[0] != '[' {
 				continue
 			}
 
+			if r.strict {
+				if _, _, err := ParseMessageStrict(message); err != nil {
+					r.reportError(ErrorParse, err)
+					continue
+				}
+			}
+
 			var jsonMessage []json.RawMessage
 			err = json.Unmarshal(message, &jsonMessage)
 			if err != nil {
@@ -154,20 +392,31 @@ func (r *Relay) Connect(ctx context.Context) error {
 			case "NOTICE":
 				var content string
 				json.Unmarshal(jsonMessage[1], &content)
-				go func() {
-					r.Notices <- content
-				}()
+				if r.noticeHandler != nil {
+					r.noticeHandler(content)
+				} else {
+					offer(r, r.Notices, content)
+				}
 			case "AUTH":
 				var challenge string
 				json.Unmarshal(jsonMessage[1], &challenge)
-				go func() {
-					r.Challenges <- challenge
-				}()
+				r.challenge.Store(challenge)
+				if r.authHandler != nil {
+					go r.handleChallenge()
+				} else {
+					go emit(r, r.Challenges, challenge)
+				}
 			case "EVENT":
 				if len(jsonMessage) < 3 {
 					continue
 				}
 
+				if r.maxEventSize > 0 && len(jsonMessage[2]) > r.maxEventSize {
+					r.reportError(ErrorMessageTooLarge, fmt.Errorf("%w: event of %d bytes, the limit is %d",
+						ErrMessageTooLarge, len(jsonMessage[2]), r.maxEventSize))
+					continue
+				}
+
 				var subId string
 				json.Unmarshal(jsonMessage[1], &subId)
 				if subscription, ok := r.subscriptions.Load(subId); !ok {
@@ -177,33 +426,49 @@ func (r *Relay) Connect(ctx context.Context) error {
 					func() {
 						// decode event
 						var event Event
-						json.Unmarshal(jsonMessage[2], &event)
+						subscription.statistics.update(func(s *SubscriptionStats) {
+							s.Received++
+							s.LastEvent = time.Now()
+						})
+						if r.strict {
+							if err := event.UnmarshalStrict(jsonMessage[2]); err != nil {
+								r.reportError(ErrorParse, fmt.Errorf("invalid event: %w", err))
+								return
+							}
+						} else {
+							event.UnmarshalJSON(jsonMessage[2])
+						}
+
+						_, span := r.startSpan(connectionContext, "nostr.ReceiveEvent",
+							Attribute{"nostr.subscription.id", subId}, Attribute{"nostr.event.kind", event.Kind})
+						defer span.End()
 
 						// check if the event matches the desired filter, ignore otherwise
 						if !subscription.Filters.Match(&event) {
+							subscription.statistics.update(func(s *SubscriptionStats) { s.DroppedByFilter++ })
 							log.Printf("filter does not match: %v ~ %v\n", subscription.Filters[0], event)
 							return
 						}
 
 						subscription.mutex.Lock()
-						defer subscription.mutex.Unlock()
-						if subscription.stopped {
+						stopped := subscription.stopped
+						subscription.mutex.Unlock()
+						if stopped {
 							return
 						}
 
 						// check signature, ignore invalid, except from trusted (AssumeValid) relays
+						var verified <-chan bool
 						if !r.AssumeValid {
-							if ok, err := event.CheckSignature(); !ok {
-								errmsg := ""
-								if err != nil {
-									errmsg = err.Error()
-								}
-								log.Printf("bad signature: %s\n", errmsg)
-								return
+							check := r.SignatureChecker
+							if check == nil {
+								check = r.signatureCache.Check
 							}
+							verified = r.verifier.verify(&event, check)
 						}
-
-						subscription.Events <- &event
+						r.deliver(delivery{verified, func(valid bool) {
+							r.deliverEvent(subscription, &event, valid)
+						}})
 					}()
 				}
 			case "EOSE":
@@ -213,16 +478,44 @@ func (r *Relay) Connect(ctx context.Context) error {
 				var subId string
 				json.Unmarshal(jsonMessage[1], &subId)
 				if subscription, ok := r.subscriptions.Load(subId); ok {
-					subscription.emitEose.Do(func() {
-						subscription.EndOfStoredEvents <- struct{}{}
-					})
+					// after the events that came before it
+					r.deliver(delivery{run: func(bool) { subscription.dispatchEose() }})
+				}
+			case "CLOSED":
+				if len(jsonMessage) < 2 {
+					continue
+				}
+				var subId, reason string
+				json.Unmarshal(jsonMessage[1], &subId)
+				if len(jsonMessage) > 2 {
+					json.Unmarshal(jsonMessage[2], &reason)
+				}
+				if subscription, ok := r.subscriptions.Load(subId); ok {
+					batch := subscription.batchIndex(subId)
+					r.deliver(delivery{run: func(bool) { go r.handleClosed(subscription, batch, reason) }})
+				}
+			case "COUNT":
+				if len(jsonMessage) < 3 {
+					continue
+				}
+				var subId string
+				json.Unmarshal(jsonMessage[1], &subId)
+				var result struct {
+					Count int64 `json:"count"`
+				}
+				json.Unmarshal(jsonMessage[2], &result)
+				if subscription, ok := r.subscriptions.Load(subId); ok && subscription.countResult != nil {
+					select {
+					case subscription.countResult <- result.Count:
+					default:
+					}
 				}
 			case "OK":
 				if len(jsonMessage) < 3 {
 					continue
 				}
 				var (
-					eventId string
+					eventId ID
 					ok      bool
 					msg     string
 				)
@@ -238,16 +531,77 @@ func (r *Relay) Connect(ctx context.Context) error {
 				}
 			}
 		}
-
-		cancel()
 	}()
 
 	return nil
 }
 
+// Challenge returns the last NIP-42 challenge sent by the relay, or an empty string.
+func (r *Relay) Challenge() string {
+	challenge, _ := r.challenge.Load().(string)
+	return challenge
+}
+
 // Publish sends an "EVENT" command to the relay r as in NIP-01.
 // Status can be: success, failed, or sent (no response from relay before ctx times out).
-func (r *Relay) Publish(ctx context.Context, event Event) (Status, error) {
+// Events the relay would refuse because of the limits in its NIP-11 document fail right away
+// with an error wrapping ErrRelayLimit. Events refused because the relay wasn't paid fail with
+// a *PaymentRequiredError, see WithPaymentHandler.
+func (r *Relay) Publish(ctx context.Context, event Event) (status Status, err error) {
+	defer r.operation()()
+	ctx, span := r.startSpan(ctx, "nostr.Publish",
+		Attribute{"nostr.event.id", event.ID.Hex()}, Attribute{"nostr.event.kind", event.Kind})
+	defer func() {
+		span.SetAttributes(Attribute{"nostr.publish.status", status.String()})
+		endSpan(span, err)
+	}()
+
+	if r.isClosed() {
+		return PublishStatusFailed, ErrRelayClosed
+	}
+	if err := runPublishHooks(ctx, r.publishHooks, &event, true); err != nil {
+		return PublishStatusFailed, err
+	}
+	if err := r.checkEvent(ctx, event); err != nil {
+		return PublishStatusFailed, err
+	}
+	if err := r.guardProtected(ctx, event); err != nil {
+		return PublishStatusFailed, fmt.Errorf("%s: %w", r.URL, err)
+	}
+
+	status, err = r.publish(ctx, event)
+	if status == PublishStatusFailed && err != nil && rejectedWith(err, NoticeAuthRequired) && r.authHandler != nil {
+		// authenticate and try again, once
+		if authErr := r.Authenticate(ctx, r.authHandler); authErr != nil {
+			return status, fmt.Errorf("%w (and %v)", err, authErr)
+		}
+		if status, err = r.publish(ctx, event); err != nil {
+			err = fmt.Errorf("after authenticating: %w", err)
+		}
+	}
+	if status != PublishStatusFailed || err == nil {
+		return status, err
+	}
+
+	payment := r.paymentError(ctx, err)
+	if payment == nil {
+		return status, err
+	}
+	if r.paymentHandler == nil || r.paymentHandler(ctx, payment) != nil {
+		return status, payment
+	}
+
+	// paid, try again
+	status, err = r.publish(ctx, event)
+	if status == PublishStatusFailed && err != nil {
+		if payment := r.paymentError(ctx, err); payment != nil {
+			return status, payment
+		}
+	}
+	return status, err
+}
+
+func (r *Relay) publish(ctx context.Context, event Event) (Status, error) {
 	status := PublishStatusSent
 	var err error
 
@@ -274,7 +628,7 @@ func (r *Relay) Publish(ctx context.Context, event Event) (Status, error) {
 			status = PublishStatusSucceeded
 		} else {
 			status = PublishStatusFailed
-			err = fmt.Errorf("msg: %s", msg)
+			err = &RejectionError{Reason: msg}
 		}
 		cancel()
 	}
@@ -282,17 +636,26 @@ func (r *Relay) Publish(ctx context.Context, event Event) (Status, error) {
 	defer r.okCallbacks.Delete(event.ID)
 
 	// publish event
-	if err := r.Connection.WriteJSON([]interface{}{"EVENT", event}); err != nil {
+	if err := r.send([]interface{}{"EVENT", event}); err != nil {
 		return status, err
 	}
 
-	sub := r.Subscribe(ctx, Filters{Filter{IDs: []string{event.ID}}})
+	sub, subErr := r.Sub(ctx, Filters{Filter{IDs: []ID{event.ID}}})
+	if subErr != nil {
+		return status, subErr
+	}
+	// the okCallback may still be running when the context is done
+	result := func() (Status, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return status, err
+	}
 	for {
 		select {
 		case receivedEvent := <-sub.Events:
 			if receivedEvent == nil {
 				// channel is closed
-				return status, err
+				return result()
 			}
 
 			if receivedEvent.ID == event.ID {
@@ -300,7 +663,7 @@ func (r *Relay) Publish(ctx context.Context, event Event) (Status, error) {
 				mu.Lock()
 				status = PublishStatusSucceeded
 				mu.Unlock()
-				return status, err
+				return result()
 			}
 		case <-ctx.Done():
 			// return status as it was
@@ -308,10 +671,10 @@ func (r *Relay) Publish(ctx context.Context, event Event) (Status, error) {
 			// e.g. if this happens because of the timeout then status will probably be "failed"
 			//      but if it happens because okCallback was called then it might be "succeeded"
 			// do not return if okCallback is in process
-			return status, err
+			return result()
 		case <-r.ConnectionContext.Done():
 			// same as above, but when the relay loses connectivity entirely
-			return status, err
+			return result()
 		}
 	}
 }
@@ -319,6 +682,7 @@ func (r *Relay) Publish(ctx context.Context, event Event) (Status, error) {
 // Auth sends an "AUTH" command client -> relay as in NIP-42.
 // Status can be: success, failed, or sent (no response from relay before ctx times out).
 func (r *Relay) Auth(ctx context.Context, event Event) (Status, error) {
+	defer r.operation()()
 	status := PublishStatusFailed
 	var err error
 
@@ -344,7 +708,7 @@ func (r *Relay) Auth(ctx context.Context, event Event) (Status, error) {
 			status = PublishStatusSucceeded
 		} else {
 			status = PublishStatusFailed
-			err = fmt.Errorf("msg: %s", msg)
+			err = &RejectionError{Reason: msg}
 		}
 		mu.Unlock()
 		cancel()
@@ -353,7 +717,7 @@ func (r *Relay) Auth(ctx context.Context, event Event) (Status, error) {
 	defer r.okCallbacks.Delete(event.ID)
 
 	// send AUTH
-	if err := r.Connection.WriteJSON([]interface{}{"AUTH", event}); err != nil {
+	if err := r.send([]interface{}{"AUTH", event}); err != nil {
 		// status will be "failed"
 		return status, err
 	}
@@ -368,30 +732,92 @@ func (r *Relay) Auth(ctx context.Context, event Event) (Status, error) {
 	<-ctx.Done()
 	mu.Lock()
 	defer mu.Unlock()
+	if status == PublishStatusSucceeded {
+		r.authenticated.Store(event.PubKey, struct{}{})
+	}
 	return status, err
 }
 
-// Subscribe sends a "REQ" command to the relay r as in NIP-01.
+// Subscribe sends a "REQ" command to the relay r as in NIP-01, see Sub, which also returns the
+// error if it fails. Here the subscription returned is already closed then, with the error as
+// its ClosedReason.
+func (r *Relay) Subscribe(ctx context.Context, filters Filters, opts ...SubscriptionOption) *Subscription {
+	sub, err := r.Sub(ctx, filters, opts...)
+	if err != nil {
+		sub = r.PrepareSubscription(ctx)
+		sub.Filters = filters
+		sub.cancel()
+		sub.stopped = true
+		close(sub.Events)
+		sub.ClosedReason <- err.Error()
+	}
+	return sub
+}
+
+// Sub sends a "REQ" command to the relay r as in NIP-01.
 // Events are returned through the channel sub.Events.
 // The subscription is closed when context ctx is cancelled ("CLOSE" in NIP-01).
-func (r *Relay) Subscribe(ctx context.Context, filters Filters) *Subscription {
-	if r.Connection == nil {
-		panic(fmt.Errorf("must call .Connect() first before calling .Subscribe()"))
+// An error wrapping ErrUnsupported is returned if the filters use features the relay doesn't advertise.
+func (r *Relay) Sub(ctx context.Context, filters Filters, opts ...SubscriptionOption) (sub *Subscription, err error) {
+	ctx, span := r.startSpan(ctx, "nostr.Subscribe", Attribute{"nostr.subscription.filters", len(filters)})
+	defer func() {
+		if sub != nil {
+			span.SetAttributes(Attribute{"nostr.subscription.id", sub.GetID()})
+		}
+		endSpan(span, err)
+	}()
+
+	if r.conn == nil {
+		return nil, fmt.Errorf("must call .Connect() first before calling .Sub()")
+	}
+	if r.isClosed() {
+		return nil, ErrRelayClosed
+	}
+	if err := r.checkFilters(ctx, filters); err != nil {
+		return nil, err
 	}
 
-	sub := r.PrepareSubscription(ctx)
+	sub = r.PrepareSubscription(ctx)
 	sub.Filters = filters
-	sub.Fire()
+	for _, opt := range opts {
+		opt(sub)
+	}
+	if sub.id != "" {
+		if _, taken := r.subscriptions.Load(sub.id); taken {
+			sub.cancel()
+			return nil, fmt.Errorf("there is already a subscription with id %s at %s", sub.id, r.URL)
+		}
+	}
+	sub.applyCursors()
+	sub.batches = r.splitFilters(ctx, sub.Filters)
+	if err := r.fitSubscriptionID(sub); err != nil {
+		return nil, err
+	}
+	if err := sub.Fire(); err != nil {
+		return nil, fmt.Errorf("couldn't subscribe to %v at %s: %w", filters, r.URL, err)
+	}
 
-	return sub
+	return sub, nil
 }
 
+// QuerySync is like Query, returning no events if it fails.
 func (r *Relay) QuerySync(ctx context.Context, filter Filter) []*Event {
-	sub := r.Subscribe(ctx, Filters{filter})
+	events, _ := r.Query(ctx, filter)
+	return events
+}
+
+// Query returns the stored events matching filter, once the relay sends "EOSE" or after
+// at most 7 seconds if ctx has no deadline. If filter has a Limit it returns as soon as that
+// many events arrive, and never more than that.
+func (r *Relay) Query(ctx context.Context, filter Filter) ([]*Event, error) {
+	sub, err := r.Sub(ctx, Filters{filter})
+	if err != nil {
+		return nil, err
+	}
 	defer sub.Unsub()
 
 	if _, ok := ctx.Deadline(); !ok {
-		// if no timeout is set, force it to 3 seconds
+		// if no timeout is set, force it to 7 seconds
 		var cancel context.CancelFunc
 		ctx, cancel = context.WithTimeout(ctx, 7*time.Second)
 		defer cancel()
@@ -403,20 +829,111 @@ func (r *Relay) QuerySync(ctx context.Context, filter Filter) []*Event {
 		case evt := <-sub.Events:
 			if evt == nil {
 				// channel is closed
-				return events
+				return events, nil
 			}
 			events = append(events, evt)
+			if filter.Limit > 0 && len(events) >= filter.Limit {
+				// relays send the newest first, so these are the ones asked for
+				return events, nil
+			}
 		case <-sub.EndOfStoredEvents:
-			return events
+			return events, nil
 		case <-ctx.Done():
-			return events
+			return events, nil
 		}
 	}
 }
 
+// SubscribeEOSE subscribes like Sub and waits for the stored events, until "EOSE" or for
+// at most 7 seconds if ctx has no deadline. They are returned together with the subscription,
+// which goes on delivering new events on sub.Events until ctx is canceled.
+func (r *Relay) SubscribeEOSE(ctx context.Context, filters Filters, opts ...SubscriptionOption) ([]*Event, *Subscription, error) {
+	sub, err := r.Sub(ctx, filters, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	wait := ctx
+	if _, ok := ctx.Deadline(); !ok {
+		// if no timeout is set, force it to 7 seconds, for the stored events only
+		var cancel context.CancelFunc
+		wait, cancel = context.WithTimeout(ctx, 7*time.Second)
+		defer cancel()
+	}
+
+	var events []*Event
+	for {
+		select {
+		case evt := <-sub.Events:
+			if evt == nil {
+				// channel is closed
+				return events, sub, nil
+			}
+			events = append(events, evt)
+		case <-sub.EndOfStoredEvents:
+			return events, sub, nil
+		case <-wait.Done():
+			return events, sub, nil
+		}
+	}
+}
+
+// Count sends a "COUNT" command to the relay as in NIP-45 and returns how many events match filters.
+// An error wrapping ErrUnsupported is returned if the relay doesn't advertise NIP-45.
+func (r *Relay) Count(ctx context.Context, filters Filters) (int64, error) {
+	defer r.operation()()
+	if r.conn == nil {
+		return 0, fmt.Errorf("must call .Connect() first before calling .Count()")
+	}
+	if r.isClosed() {
+		return 0, ErrRelayClosed
+	}
+	if err := r.requireNIP(ctx, 45, "COUNT"); err != nil {
+		return 0, err
+	}
+	if err := r.checkFilters(ctx, filters); err != nil {
+		return 0, err
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		// if no timeout is set, force it to 7 seconds
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, 7*time.Second)
+		defer cancel()
+	}
+
+	sub := r.PrepareSubscription(ctx)
+	sub.Filters = filters
+	sub.countResult = make(chan int64, 1)
+	r.subscriptions.Store(sub.GetID(), sub)
+	defer r.subscriptions.Delete(sub.GetID())
+	defer sub.cancel()
+
+	message := []interface{}{"COUNT", sub.GetID()}
+	for _, filter := range filters {
+		message = append(message, filter)
+	}
+	if err := r.send(message); err != nil {
+		return 0, err
+	}
+
+	select {
+	case count := <-sub.countResult:
+		return count, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-r.ConnectionContext.Done():
+		return 0, fmt.Errorf("connection to %s closed before COUNT result", r.URL)
+	}
+}
+
 func (r *Relay) PrepareSubscription(ctx context.Context) *Subscription {
-	current := subscriptionIdCounter
-	subscriptionIdCounter++
+	r.subscriptionPrefixOnce.Do(func() {
+		prefix := make([]byte, 3)
+		rand.Read(prefix)
+		r.subscriptionPrefix = hex.EncodeToString(prefix)
+	})
+	current := atomic.AddInt64(&r.subscriptionCounter, 1)
 
 	ctx, cancel := context.WithCancel(ctx)
 
@@ -424,13 +941,66 @@ func (r *Relay) PrepareSubscription(ctx context.Context) *Subscription {
 		Relay:             r,
 		Context:           ctx,
 		cancel:            cancel,
-		conn:              r.Connection,
-		counter:           current,
+		prefix:            r.subscriptionPrefix,
+		counter:           int(current),
 		Events:            make(chan *Event),
 		EndOfStoredEvents: make(chan struct{}, 1),
+		ClosedReason:      make(chan string, 1),
 	}
 }
 
+// IsConnected tells if the websocket connection is up, it is down while reconnecting.
+func (r *Relay) IsConnected() bool {
+	return r.conn != nil && r.conn.IsConnected()
+}
+
+// SubscriptionCount returns how many subscriptions are open in this relay.
+func (r *Relay) SubscriptionCount() int {
+	subs := make(map[*Subscription]struct{})
+	r.subscriptions.Range(func(_ string, sub *Subscription) bool {
+		subs[sub] = struct{}{}
+		return true
+	})
+	return len(subs)
+}
+
+// Close closes the connection for good: ConnectionContext is canceled, the Challenges, Notices
+// and Errors channels are closed, and so are the Events channels of all subscriptions. Methods
+// called afterwards return ErrRelayClosed. It can be called more than once.
 func (r *Relay) Close() {
-	r.Connection.Close()
+	if r.closeConnection != nil {
+		r.closeConnection()
+	}
+	if r.conn != nil {
+		r.conn.Close()
+	}
+
+	r.subscriptions.Range(func(id string, sub *Subscription) bool {
+		r.subscriptions.Delete(id)
+		sub.cancel() // first, so nothing is left blocked sending to sub.Events
+		sub.mutex.Lock()
+		if !sub.stopped && sub.Events != nil {
+			close(sub.Events)
+		}
+		sub.stopped = true
+		sub.mutex.Unlock()
+		return true
+	})
+
+	// senders wait on ConnectionContext, which is done, so they release this soon
+	r.closeMutex.Lock()
+	defer r.closeMutex.Unlock()
+	if r.closed || r.Errors == nil {
+		r.closed = true
+		return
+	}
+	r.closed = true
+	close(r.Challenges)
+	close(r.Notices)
+	close(r.Errors)
+}
+
+// isClosed tells if Close was called or the connection ended for good.
+func (r *Relay) isClosed() bool {
+	return r.ConnectionContext != nil && r.ConnectionContext.Err() != nil
 }
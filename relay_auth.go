@@ -0,0 +1,86 @@
+package nostr
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Authenticate answers the last NIP-42 challenge sent by the relay with an event signed by sign,
+// which works like Signer.SignEvent. It does nothing if that challenge was already answered.
+func (r *Relay) Authenticate(ctx context.Context, sign func(ctx context.Context, evt *Event) error) error {
+	return r.authenticate(ctx, nil, sign)
+}
+
+// AuthenticateAs answers the last NIP-42 challenge sent by the relay as the owner of signer. It
+// does nothing if they already answered that challenge, but does answer it again if it was
+// answered with another key, for clients acting as many accounts on the same connection.
+func (r *Relay) AuthenticateAs(ctx context.Context, signer Signer) error {
+	pk, err := signer.GetPublicKey(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get public key: %w", err)
+	}
+	return r.authenticate(ctx, &pk, signer.SignEvent)
+}
+
+// authenticate answers the last challenge with sign, unless it was answered already by pk, or
+// by anyone if pk is nil.
+func (r *Relay) authenticate(ctx context.Context, pk *PubKey, sign func(ctx context.Context, evt *Event) error) error {
+	r.authMutex.Lock()
+	defer r.authMutex.Unlock()
+
+	challenge := r.Challenge()
+	if challenge == "" {
+		return fmt.Errorf("%s didn't send an auth challenge", r.URL)
+	}
+	for answeredBy, answered := range r.answered {
+		if answered == challenge && (pk == nil || *pk == answeredBy) {
+			return nil
+		}
+	}
+
+	evt := newAuthEvent(r.URL, challenge)
+	if err := sign(ctx, &evt); err != nil {
+		return fmt.Errorf("failed to sign auth event: %w", err)
+	}
+	status, err := r.Auth(ctx, evt)
+	if status == PublishStatusFailed {
+		return fmt.Errorf("auth failed: %w", err)
+	}
+	if r.answered == nil {
+		r.answered = make(map[PubKey]string)
+	}
+	r.answered[evt.PubKey] = challenge
+	if r.outgoing != nil && status == PublishStatusSucceeded {
+		// otherwise the buffered messages wait for reauthTimeout, in case the relay is slow
+		r.outgoing.authenticated()
+	}
+	return nil
+}
+
+// handleClosed ends a subscription the relay closed with reason. If it was because we need to
+// authenticate and we have an auth handler, we authenticate and send the REQ again, once.
+func (r *Relay) handleClosed(sub *Subscription, batch int, reason string) {
+	sub.mutex.Lock()
+	retry := ParseNotice(reason).Prefix == NoticeAuthRequired && r.authHandler != nil && !sub.authRetried && !sub.stopped
+	sub.authRetried = sub.authRetried || retry
+	sub.mutex.Unlock()
+
+	if retry {
+		ctx, cancel := context.WithTimeout(sub.Context, 10*time.Second)
+		err := r.Authenticate(ctx, r.authHandler)
+		cancel()
+		if err == nil {
+			if err = sub.fireBatch(batch); err == nil {
+				return
+			}
+		}
+		reason = fmt.Sprintf("%s (and %s)", reason, err)
+	}
+
+	select {
+	case sub.ClosedReason <- reason:
+	default:
+	}
+	sub.cancel()
+}
@@ -0,0 +1,76 @@
+package nostr
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuthRequiredRetry(t *testing.T) {
+	priv, pub := makeKeyPair(t)
+	evt := Event{Kind: 1, Content: "hello", CreatedAt: time.Unix(1672068534, 0), PubKey: pub}
+	evt.Sign(priv)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	fr := &fakeRelay{requireAuth: true}
+	ws := newWebsocketServer(fr.handle)
+	defer ws.Close()
+
+	// without an auth handler the refusals come back to us
+	relay := mustRelayConnect(ws.URL)
+	defer relay.Close()
+	if _, err := relay.Publish(ctx, evt); err == nil || !strings.Contains(err.Error(), "auth-required:") {
+		t.Errorf("expected auth-required, got %v", err)
+	}
+	sub, err := relay.Sub(ctx, Filters{{Kinds: []int{1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case reason := <-sub.ClosedReason:
+		if ParseNotice(reason).Prefix != NoticeAuthRequired {
+			t.Errorf("unexpected reason %q", reason)
+		}
+	case <-ctx.Done():
+		t.Fatal("subscription wasn't closed")
+	}
+	for range sub.Events {
+	}
+
+	// with one we authenticate and try again
+	authed, err := RelayConnect(ctx, ws.URL, WithAuthHandler(func(ctx context.Context, evt *Event) error {
+		evt.PubKey = pub
+		return evt.Sign(priv)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer authed.Close()
+	sub, err = authed.Sub(ctx, Filters{{Kinds: []int{1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-sub.EndOfStoredEvents:
+	case reason := <-sub.ClosedReason:
+		t.Fatalf("subscription closed: %s", reason)
+	case <-ctx.Done():
+		t.Fatal("no EOSE")
+	}
+	sub.Unsub()
+
+	if status, err := authed.Publish(ctx, evt); err != nil || status != PublishStatusSucceeded {
+		t.Errorf("failed to publish after authenticating: %s, %v", status, err)
+	}
+	if !authed.AuthenticatedAs(pub) {
+		t.Errorf("should be authenticated")
+	}
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	if len(fr.events) != 1 {
+		t.Errorf("expected the event to be stored once, got %d", len(fr.events))
+	}
+}
@@ -0,0 +1,187 @@
+package nostr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrOutgoingBufferFull is returned when sending to a disconnected relay whose outgoing buffer
+// has no more room, see WithOutgoingBuffer.
+var ErrOutgoingBufferFull = errors.New("outgoing buffer is full")
+
+// how long to wait for a relay we had authenticated to before to ask for it again after
+// reconnecting, before sending the buffered messages anyway
+const reauthTimeout = 5 * time.Second
+
+// WithOutgoingBuffer makes the relay keep up to size "EVENT", "REQ" and "CLOSE" messages sent
+// while the connection is down and send them, in order, once it is back. If we had authenticated
+// to the relay, they are sent after answering its new NIP-42 challenge, see WithAuthHandler.
+// Publish returns PublishStatusSent for events that stay in the buffer past its context.
+func WithOutgoingBuffer(size int) RelayOption {
+	return func(r *Relay) {
+		r.outgoing = &outgoingBuffer{size: size, authed: make(chan struct{}, 1)}
+	}
+}
+
+type outgoingBuffer struct {
+	mutex    sync.Mutex
+	size     int
+	frames   []outgoingFrame
+	flushing bool // a queued frame is being written
+	// closed once the queued frames are all written, nil while there are none
+	drained chan struct{}
+
+	authed chan struct{} // signaled after the relay accepted our answer to a challenge
+}
+
+type outgoingFrame struct {
+	raw     []byte
+	command string // "EVENT", "REQ" or "CLOSE"
+	subID   string // for "REQ" and "CLOSE"
+}
+
+// send writes frame with write, or queues it if we aren't connected or still have queued
+// frames, so they are all sent in order. A "CLOSE" for a subscription whose "REQ" is still
+// queued takes both out of the queue instead. The lock isn't held while writing.
+func (b *outgoingBuffer) send(frame outgoingFrame, write func([]byte) error) error {
+	b.mutex.Lock()
+	if frame.command == "CLOSE" && b.dropREQ(frame.subID) {
+		b.mutex.Unlock()
+		return nil
+	}
+	if len(b.frames) == 0 && !b.flushing {
+		b.mutex.Unlock()
+		if err := write(frame.raw); err != ErrNotConnected {
+			return err
+		}
+		b.mutex.Lock()
+	}
+	defer b.mutex.Unlock()
+
+	if len(b.frames) >= b.size {
+		return ErrOutgoingBufferFull
+	}
+	if b.drained == nil {
+		b.drained = make(chan struct{})
+	}
+	b.frames = append(b.frames, frame)
+	return nil
+}
+
+// dropREQ removes the queued "REQ" of subID, unless it is being written, it tells if there was
+// one. Must be called with the lock held.
+func (b *outgoingBuffer) dropREQ(subID string) bool {
+	for i, frame := range b.frames {
+		if i == 0 && b.flushing {
+			continue
+		}
+		if frame.command == "REQ" && frame.subID == subID {
+			b.frames = append(b.frames[:i:i], b.frames[i+1:]...)
+			if len(b.frames) == 0 && b.drained != nil {
+				close(b.drained)
+				b.drained = nil
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// flush writes the queued frames, the ones that can't be written stay for the next time.
+func (b *outgoingBuffer) flush(write func([]byte) error) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.flushing {
+		return nil
+	}
+
+	for len(b.frames) > 0 {
+		frame := b.frames[0]
+		b.flushing = true
+		b.mutex.Unlock()
+		err := write(frame.raw)
+		b.mutex.Lock()
+		b.flushing = false
+		if err != nil {
+			return err
+		}
+		// a "CLOSE" may have taken other frames out meanwhile, but not this one
+		b.frames = b.frames[1:]
+	}
+	b.frames = nil
+	if b.drained != nil {
+		close(b.drained)
+		b.drained = nil
+	}
+	return nil
+}
+
+// pending returns a channel closed once the frames queued now are written, or nil if there
+// are none.
+func (b *outgoingBuffer) pending() <-chan struct{} {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.drained == nil {
+		return nil
+	}
+	return b.drained
+}
+
+func (b *outgoingBuffer) authenticated() {
+	select {
+	case b.authed <- struct{}{}:
+	default:
+	}
+}
+
+// resetAuth forgets authentications from previous connections.
+func (b *outgoingBuffer) resetAuth() {
+	select {
+	case <-b.authed:
+	default:
+	}
+}
+
+// flushOutgoing sends what was buffered while disconnected, first waiting for the relay to
+// authenticate us again if waitAuth is set.
+func (r *Relay) flushOutgoing(waitAuth bool) {
+	if waitAuth {
+		select {
+		case <-r.outgoing.authed:
+		case <-time.After(reauthTimeout):
+		case <-r.ConnectionContext.Done():
+			return
+		}
+	}
+	if err := r.outgoing.flush(r.writeFrame); err != nil && err != ErrNotConnected {
+		r.reportError(ErrorWrite, fmt.Errorf("failed to send buffered messages: %w", err))
+	}
+}
+
+// Flush waits until the frames sent so far, including the ones kept by WithOutgoingBuffer
+// while disconnected, are written to the socket, so batch publishers can make sure everything
+// went out before calling Close. It returns ctx.Err() if ctx is done first.
+func (r *Relay) Flush(ctx context.Context) error {
+	if r.isClosed() {
+		return ErrRelayClosed
+	}
+	if r.conn == nil {
+		return fmt.Errorf("must call .Connect() first before calling .Flush()")
+	}
+
+	if r.outgoing != nil {
+		if drained := r.outgoing.pending(); drained != nil {
+			select {
+			case <-drained:
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-r.ConnectionContext.Done():
+				return ErrRelayClosed
+			}
+		}
+	}
+	return r.conn.waitWrites(ctx)
+}
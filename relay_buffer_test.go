@@ -0,0 +1,207 @@
+package nostr
+
+import (
+	"context"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestOutgoingBuffer(t *testing.T) {
+	var connected bool
+	var written []string
+	write := func(raw []byte) error {
+		if !connected {
+			return ErrNotConnected
+		}
+		written = append(written, string(raw))
+		return nil
+	}
+
+	b := &outgoingBuffer{size: 2}
+	if err := b.send(outgoingFrame{raw: []byte("a")}, write); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.send(outgoingFrame{raw: []byte("b")}, write); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.send(outgoingFrame{raw: []byte("c")}, write); err != ErrOutgoingBufferFull {
+		t.Fatalf("expected ErrOutgoingBufferFull, got %v", err)
+	}
+
+	// nothing skips the queue
+	connected = true
+	if err := b.send(outgoingFrame{raw: []byte("c")}, write); err != ErrOutgoingBufferFull {
+		t.Fatalf("expected ErrOutgoingBufferFull, got %v", err)
+	}
+	if err := b.flush(write); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.send(outgoingFrame{raw: []byte("c")}, write); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(written, []string{"a", "b", "c"}) {
+		t.Fatalf("written %v", written)
+	}
+
+	// subscriptions closed while their REQ is queued aren't sent at all
+	connected = false
+	written = nil
+	b.send(outgoingFrame{raw: []byte("req"), command: "REQ", subID: "x"}, write)
+	b.send(outgoingFrame{raw: []byte("event"), command: "EVENT"}, write)
+	if err := b.send(outgoingFrame{raw: []byte("close"), command: "CLOSE", subID: "x"}, write); err != nil {
+		t.Fatal(err)
+	}
+	connected = true
+	if err := b.flush(write); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(written, []string{"event"}) {
+		t.Fatalf("written %v", written)
+	}
+}
+
+func TestPublishWhileReconnecting(t *testing.T) {
+	fr := &fakeRelay{}
+	drop := make(chan struct{})
+	var connections int32
+	ws := newWebsocketServer(func(conn *websocket.Conn) {
+		if atomic.AddInt32(&connections, 1) == 1 {
+			<-drop
+			return
+		}
+		fr.handle(conn)
+	})
+	defer ws.Close()
+
+	disconnected := make(chan struct{}, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	relay, err := RelayConnect(ctx, ws.URL, WithOutgoingBuffer(10),
+		WithReconnectBackoff(func(int) time.Duration { return 200 * time.Millisecond }),
+		WithConnectionStateHandler(func(state ConnectionState) {
+			if state == ConnectionDisconnected {
+				select {
+				case disconnected <- struct{}{}:
+				default:
+				}
+			}
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer relay.Close()
+	go func() {
+		for range relay.Errors {
+		}
+	}()
+
+	close(drop)
+	select {
+	case <-disconnected:
+	case <-ctx.Done():
+		t.Fatal("connection didn't drop")
+	}
+
+	sk, pk := makeKeyPair(t)
+	evt := Event{Kind: 1, PubKey: pk, CreatedAt: time.Now(), Content: "buffered"}
+	evt.Sign(sk)
+	status, err := relay.Publish(ctx, evt)
+	if err != nil || status != PublishStatusSucceeded {
+		t.Fatalf("publish failed: %s, %v", status, err)
+	}
+
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	if len(fr.events) != 1 || fr.events[0].ID != evt.ID {
+		t.Fatalf("relay got %v", fr.events)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	fr := &fakeRelay{}
+	drop := make(chan struct{})
+	var connections int32
+	ws := newWebsocketServer(func(conn *websocket.Conn) {
+		if atomic.AddInt32(&connections, 1) == 1 {
+			<-drop
+			return
+		}
+		fr.handle(conn)
+	})
+	defer ws.Close()
+
+	disconnected := make(chan struct{}, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	relay, err := RelayConnect(ctx, ws.URL, WithOutgoingBuffer(10),
+		WithReconnectBackoff(func(int) time.Duration { return 500 * time.Millisecond }),
+		WithConnectionStateHandler(func(state ConnectionState) {
+			if state == ConnectionDisconnected {
+				select {
+				case disconnected <- struct{}{}:
+				default:
+				}
+			}
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer relay.Close()
+	go func() {
+		for range relay.Errors {
+		}
+	}()
+
+	if err := relay.Flush(ctx); err != nil {
+		t.Fatalf("flushing nothing failed: %v", err)
+	}
+
+	close(drop)
+	select {
+	case <-disconnected:
+	case <-ctx.Done():
+		t.Fatal("connection didn't drop")
+	}
+
+	sk, pk := makeKeyPair(t)
+	evt := Event{Kind: 1, PubKey: pk, CreatedAt: time.Now(), Content: "buffered"}
+	evt.Sign(sk)
+	if err := relay.send([]interface{}{"EVENT", evt}); err != nil {
+		t.Fatal(err)
+	}
+
+	short, cancelShort := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancelShort()
+	if err := relay.Flush(short); err != context.DeadlineExceeded {
+		t.Fatalf("expected the flush to time out while disconnected, got %v", err)
+	}
+
+	if err := relay.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if relay.outgoing.pending() != nil {
+		t.Fatal("frames still queued after flushing")
+	}
+	for {
+		fr.mu.Lock()
+		n := len(fr.events)
+		fr.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatal("relay didn't get the flushed event")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	relay.Close()
+	if err := relay.Flush(ctx); err != ErrRelayClosed {
+		t.Fatalf("expected ErrRelayClosed, got %v", err)
+	}
+}
@@ -0,0 +1,294 @@
+package nostr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ErrNotConnected is returned when writing to a relay while its connection is down.
+var ErrNotConnected = errors.New("not connected")
+
+// ErrMessageTooLarge is wrapped by the errors for messages from a relay larger than allowed, see
+// WithMaxMessageSize.
+var ErrMessageTooLarge = errors.New("message too large")
+
+// DefaultMaxMessageSize is the largest message read from relays that weren't given another
+// limit with WithMaxMessageSize.
+const DefaultMaxMessageSize = 16 << 20
+
+// ConnectionState is the state of the websocket connection to a relay, see
+// WithConnectionStateHandler.
+type ConnectionState int
+
+const (
+	ConnectionDisconnected ConnectionState = iota // not connected yet, or dropped and waiting to dial again
+	ConnectionConnecting                          // dialing for the first time
+	ConnectionConnected                           // up
+	ConnectionClosed                              // closed with Close, for good
+	ConnectionReconnecting                        // dialing again after the connection dropped
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case ConnectionConnecting:
+		return "connecting"
+	case ConnectionReconnecting:
+		return "reconnecting"
+	case ConnectionConnected:
+		return "connected"
+	case ConnectionDisconnected:
+		return "disconnected"
+	case ConnectionClosed:
+		return "closed"
+	}
+
+	return "unknown"
+}
+
+// Backoff returns how long to wait before the given reconnection attempt, counting from 0.
+type Backoff func(attempt int) time.Duration
+
+// DefaultBackoff is used by relays that weren't given one with WithReconnectBackoff.
+var DefaultBackoff = ExponentialBackoff(5*time.Second, 30*time.Second)
+
+// ExponentialBackoff waits around min before the first attempt, doubling every time up to max.
+// Waits are randomized a little so many clients don't come back at the same instant.
+func ExponentialBackoff(min, max time.Duration) Backoff {
+	return func(attempt int) time.Duration {
+		wait := min
+		for i := 0; i < attempt && wait < max; i++ {
+			wait *= 2
+		}
+		if wait > max {
+			wait = max
+		}
+		if jitter := int64(wait / 5); jitter > 0 {
+			wait += time.Duration(rand.Int63n(jitter)) - time.Duration(jitter/2)
+		}
+		return wait
+	}
+}
+
+// ReconnectingConn is a websocket connection that can be dialed again after dropping, until
+// its context is canceled. Only one goroutine may read from it, writes are serialized. The
+// relay reads from it, so others should only write, see Relay.Connection.
+type ReconnectingConn struct {
+	ctx     context.Context // closes the connection for good when canceled
+	url     string
+	header  http.Header
+	dialer  *websocket.Dialer
+	backoff Backoff
+	// maxAttempts is how many failed attempts reconnect makes before giving up, 0 means no limit.
+	maxAttempts int
+	// maxMessageSize is the largest message ReadMessage returns, 0 means no limit.
+	maxMessageSize int64
+
+	// onConnect is called with every new socket once it is up, before it is read from.
+	onConnect func(socket *websocket.Conn)
+	// onState is called with every state change.
+	onState func(state ConnectionState)
+
+	mutex  sync.Mutex // guards socket and state
+	socket *websocket.Conn
+	state  ConnectionState
+
+	writeMutex sync.Mutex
+}
+
+func (c *ReconnectingConn) setState(state ConnectionState) {
+	c.mutex.Lock()
+	if c.state == state || c.state == ConnectionClosed {
+		c.mutex.Unlock()
+		return
+	}
+	c.state = state
+	c.mutex.Unlock()
+
+	if c.onState != nil {
+		c.onState(state)
+	}
+}
+
+// dial opens a new socket, giving up when ctx is done. state is ConnectionConnecting the first
+// time and ConnectionReconnecting afterwards.
+func (c *ReconnectingConn) dial(ctx context.Context, state ConnectionState) error {
+	c.setState(state)
+	socket, _, err := c.dialer.DialContext(ctx, c.url, c.header)
+	if err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		c.setState(ConnectionDisconnected)
+		return err
+	}
+
+	c.mutex.Lock()
+	if c.ctx.Err() != nil {
+		// closed while dialing
+		c.mutex.Unlock()
+		socket.Close()
+		return c.ctx.Err()
+	}
+	c.socket = socket
+	c.mutex.Unlock()
+
+	c.setState(ConnectionConnected)
+	if c.onConnect != nil {
+		c.onConnect(socket)
+	}
+	return nil
+}
+
+// reconnect dials again, waiting as told by backoff before each attempt, until it succeeds, the
+// connection is closed or maxAttempts fail. Errors of the failed attempts are given to onError.
+func (c *ReconnectingConn) reconnect(onError func(error)) error {
+	c.setState(ConnectionDisconnected)
+	for attempt := 0; ; attempt++ {
+		select {
+		case <-c.ctx.Done():
+			return c.ctx.Err()
+		case <-time.After(c.backoff(attempt)):
+		}
+
+		ctx, cancel := context.WithTimeout(c.ctx, 7*time.Second)
+		err := c.dial(ctx, ConnectionReconnecting)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if c.ctx.Err() != nil {
+			return c.ctx.Err()
+		}
+		onError(err)
+		if c.maxAttempts > 0 && attempt+1 >= c.maxAttempts {
+			return fmt.Errorf("%w after %d attempts, the last failed with: %v", ErrReconnectExhausted, attempt+1, err)
+		}
+	}
+}
+
+func (c *ReconnectingConn) currentState() ConnectionState {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.state
+}
+
+// current returns the socket in use, or nil while not connected.
+func (c *ReconnectingConn) current() *websocket.Conn {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.state != ConnectionConnected {
+		return nil
+	}
+	return c.socket
+}
+
+// ReadMessage reads from the current socket. An error means it dropped: call reconnect.
+func (c *ReconnectingConn) ReadMessage() (int, []byte, error) {
+	c.mutex.Lock()
+	socket := c.socket
+	c.mutex.Unlock()
+	if socket == nil {
+		return 0, nil, ErrNotConnected
+	}
+
+	typ, message, err := c.read(socket)
+	if err != nil && !errors.Is(err, ErrMessageTooLarge) {
+		c.mutex.Lock()
+		if c.socket == socket {
+			c.socket = nil
+		}
+		c.mutex.Unlock()
+		socket.Close()
+	}
+	return typ, message, err
+}
+
+// read reads the next message from socket. Messages larger than maxMessageSize are skipped
+// without keeping them in memory, failing with ErrMessageTooLarge.
+func (c *ReconnectingConn) read(socket *websocket.Conn) (int, []byte, error) {
+	if c.maxMessageSize <= 0 {
+		return socket.ReadMessage()
+	}
+
+	typ, r, err := socket.NextReader()
+	if err != nil {
+		return typ, nil, err
+	}
+	message, err := io.ReadAll(io.LimitReader(r, c.maxMessageSize+1))
+	if err != nil {
+		return typ, nil, err
+	}
+	if int64(len(message)) > c.maxMessageSize {
+		skipped, err := io.Copy(io.Discard, r)
+		if err != nil {
+			return typ, nil, err
+		}
+		return typ, nil, fmt.Errorf("%w: %d bytes, the limit is %d", ErrMessageTooLarge, int64(len(message))+skipped, c.maxMessageSize)
+	}
+	return typ, message, nil
+}
+
+func (c *ReconnectingConn) WriteMessage(messageType int, data []byte) error {
+	socket := c.current()
+	if socket == nil {
+		return ErrNotConnected
+	}
+
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	return socket.WriteMessage(messageType, data)
+}
+
+// waitWrites waits for the write in progress, if any, to be done.
+func (c *ReconnectingConn) waitWrites(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		c.writeMutex.Lock()
+		c.writeMutex.Unlock()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *ReconnectingConn) IsConnected() bool {
+	return c.current() != nil
+}
+
+// drop closes the current socket so the reader sees an error and reconnects.
+func (c *ReconnectingConn) drop() {
+	c.mutex.Lock()
+	socket := c.socket
+	c.mutex.Unlock()
+	if socket != nil {
+		socket.Close()
+	}
+}
+
+// Close closes the current socket and stops reconnecting, the context must be canceled too.
+func (c *ReconnectingConn) Close() error {
+	c.mutex.Lock()
+	socket := c.socket
+	c.socket = nil
+	c.mutex.Unlock()
+	c.setState(ConnectionClosed)
+
+	if socket == nil {
+		return nil
+	}
+	socket.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	return socket.Close()
+}
@@ -0,0 +1,199 @@
+package nostr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestRelayReconnects(t *testing.T) {
+	var connections int32
+	ws := newWebsocketServer(func(conn *websocket.Conn) {
+		if atomic.AddInt32(&connections, 1) == 1 {
+			return // drop the first connection right away
+		}
+		io.ReadAll(conn)
+	})
+	defer ws.Close()
+
+	var mu sync.Mutex
+	var states []ConnectionState
+	connected := make(chan struct{}, 2)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	relay, err := RelayConnect(ctx, ws.URL,
+		WithReconnectBackoff(func(int) time.Duration { return 10 * time.Millisecond }),
+		WithConnectionStateHandler(func(state ConnectionState) {
+			mu.Lock()
+			states = append(states, state)
+			mu.Unlock()
+			if state == ConnectionConnected {
+				connected <- struct{}{}
+			}
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for range relay.Errors {
+		}
+	}()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-connected:
+		case <-ctx.Done():
+			t.Fatal("didn't reconnect")
+		}
+	}
+	if !relay.IsConnected() {
+		t.Error("relay isn't connected after reconnecting")
+	}
+
+	relay.Close()
+	select {
+	case <-relay.ConnectionContext.Done():
+	case <-ctx.Done():
+		t.Fatal("connection context wasn't canceled")
+	}
+	if relay.IsConnected() {
+		t.Error("relay is connected after Close")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []ConnectionState{
+		ConnectionConnecting, ConnectionConnected,
+		ConnectionDisconnected, ConnectionReconnecting, ConnectionConnected,
+		ConnectionClosed,
+	}
+	if !reflect.DeepEqual(states, expected) {
+		t.Errorf("states are %v, expected %v", states, expected)
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(time.Second, 10*time.Second)
+	for attempt, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		wait := backoff(attempt)
+		if wait < expected*9/10 || wait > expected*11/10 {
+			t.Errorf("attempt %d waits %s, expected around %s", attempt, wait, expected)
+		}
+	}
+}
+
+func TestRelayStatus(t *testing.T) {
+	var connections int32
+	ws := newWebsocketServer(func(conn *websocket.Conn) {
+		if atomic.AddInt32(&connections, 1) == 1 {
+			time.Sleep(100 * time.Millisecond) // drop the first connection once we watch it
+			return
+		}
+		io.ReadAll(conn)
+	})
+	defer ws.Close()
+
+	relay := NewRelay(ws.URL, WithReconnectBackoff(func(int) time.Duration { return 50 * time.Millisecond }))
+	if status := relay.Status(); status != ConnectionDisconnected {
+		t.Fatalf("status before connecting is %s", status)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := relay.Connect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for range relay.Errors {
+		}
+	}()
+
+	watch := relay.WatchStatus(ctx)
+	reconnected := false
+	for state := range watch {
+		if state == ConnectionReconnecting {
+			reconnected = true
+		}
+		if reconnected && state == ConnectionConnected {
+			break
+		}
+	}
+	if !reconnected || relay.Status() != ConnectionConnected {
+		t.Fatalf("didn't see the relay reconnecting, status is %s", relay.Status())
+	}
+
+	relay.Close()
+	var last ConnectionState
+	for state := range watch {
+		last = state
+	}
+	if last != ConnectionClosed || relay.Status() != ConnectionClosed {
+		t.Fatalf("last state was %s, status is %s", last, relay.Status())
+	}
+
+	// watching a closed relay only tells it is closed
+	if states := relay.WatchStatus(ctx); <-states != ConnectionClosed {
+		t.Fatal("closed relay isn't reported as closed")
+	}
+}
+
+func TestMaxMessageSize(t *testing.T) {
+	sk, pk := makeKeyPair(t)
+	evt := Event{Kind: 1, PubKey: pk, CreatedAt: time.Now(), Content: "small"}
+	evt.Sign(sk)
+	ws := newWebsocketServer(func(conn *websocket.Conn) {
+		var raw []json.RawMessage
+		if err := websocket.JSON.Receive(conn, &raw); err != nil {
+			return
+		}
+		var id string
+		json.Unmarshal(raw[1], &id)
+		websocket.JSON.Send(conn, []any{"NOTICE", strings.Repeat("x", 2000)})
+		websocket.JSON.Send(conn, []any{"EVENT", id, evt})
+		websocket.JSON.Send(conn, []any{"EOSE", id})
+		io.ReadAll(conn)
+	})
+	defer ws.Close()
+
+	for _, disconnect := range []bool{false, true} {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		tooLarge := make(chan *RelayError, 1)
+		relay, err := RelayConnect(ctx, ws.URL, WithMaxMessageSize(1000, disconnect),
+			WithErrorHandler(func(err *RelayError) {
+				if err.Kind == ErrorMessageTooLarge {
+					tooLarge <- err
+				}
+			}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer relay.Close()
+
+		events, _ := relay.Query(ctx, Filter{Kinds: []int{1}})
+		select {
+		case err := <-tooLarge:
+			if !errors.Is(err, ErrMessageTooLarge) {
+				t.Fatalf("unexpected error %v", err)
+			}
+		case <-ctx.Done():
+			t.Fatal("large message wasn't reported")
+		}
+		if disconnect {
+			if relay.Status() != ConnectionClosed {
+				t.Fatalf("relay is %s after a large message", relay.Status())
+			}
+		} else if len(events) != 1 || events[0].ID != evt.ID {
+			t.Fatalf("messages after the large one were lost, got %v", events)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package nostr
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrReconnectExhausted is wrapped by the error reported when a relay gives up connecting again,
+// see WithMaxReconnectAttempts.
+var ErrReconnectExhausted = errors.New("gave up reconnecting")
+
+// how many errors are kept in the Errors channel of a relay while nobody reads it, newer ones
+// are dropped once it is full
+const errorsBuffer = 32
+
+// RelayErrorKind tells what went wrong in a RelayError.
+type RelayErrorKind int
+
+const (
+	ErrorRead               RelayErrorKind = iota // the connection dropped while reading from it
+	ErrorParse                                    // a message or event from the relay was malformed
+	ErrorWrite                                    // a message couldn't be sent to the relay
+	ErrorReconnect                                // an attempt to connect again failed
+	ErrorReconnectExhausted                       // we gave up connecting again, the relay is closed
+	ErrorAuth                                     // answering a NIP-42 challenge failed
+	ErrorEvent                                    // an event from the relay was rejected, e.g. by WithCreatedAtWindow
+	ErrorMessageTooLarge                          // a message or event from the relay was skipped for its size
+)
+
+func (k RelayErrorKind) String() string {
+	switch k {
+	case ErrorRead:
+		return "read"
+	case ErrorParse:
+		return "parse"
+	case ErrorWrite:
+		return "write"
+	case ErrorReconnect:
+		return "reconnect"
+	case ErrorReconnectExhausted:
+		return "reconnect exhausted"
+	case ErrorAuth:
+		return "auth"
+	case ErrorEvent:
+		return "event"
+	case ErrorMessageTooLarge:
+		return "message too large"
+	}
+
+	return "unknown"
+}
+
+// RelayError is what goes wrong with a relay outside of the calls made to it, like the
+// connection dropping, sent to the handler given to WithErrorHandler or to the Errors channel.
+type RelayError struct {
+	Kind RelayErrorKind
+	URL  string
+	Time time.Time
+	Err  error
+}
+
+func (e *RelayError) Error() string { return fmt.Sprintf("%s: %s", e.URL, e.Err) }
+func (e *RelayError) Unwrap() error { return e.Err }
+
+// WithErrorHandler makes the relay call handler with every *RelayError, instead of sending them
+// to the Errors channel, which drops them once full. It is called synchronously, so it
+// shouldn't block.
+func WithErrorHandler(handler func(err *RelayError)) RelayOption {
+	return func(r *Relay) {
+		r.errorHandler = handler
+	}
+}
+
+// WithMaxReconnectAttempts makes the relay give up after failing to connect again n times in a
+// row once the connection drops, closing it and reporting an ErrorReconnectExhausted. By default
+// it tries until closed.
+func WithMaxReconnectAttempts(n int) RelayOption {
+	return func(r *Relay) {
+		r.maxReconnects = n
+	}
+}
+
+// reportError gives err, as a *RelayError of kind, to the error handler or the Errors channel,
+// without blocking the caller.
+func (r *Relay) reportError(kind RelayErrorKind, err error) {
+	relayErr := &RelayError{Kind: kind, URL: r.URL, Time: time.Now(), Err: err}
+	if r.errorHandler != nil {
+		r.errorHandler(relayErr)
+		return
+	}
+	offer(r, r.Errors, error(relayErr))
+}
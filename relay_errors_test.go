@@ -0,0 +1,82 @@
+package nostr
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestErrorHandlerAndReconnectExhausted(t *testing.T) {
+	var connections int32
+	upgrade := &websocket.Server{Handshake: anyOriginHandshake, Handler: func(conn *websocket.Conn) {}}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&connections, 1) > 1 {
+			// the relay went away for good
+			http.Error(w, "gone", http.StatusServiceUnavailable)
+			return
+		}
+		upgrade.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	var mu sync.Mutex
+	var reported []*RelayError
+	exhausted := make(chan struct{})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	relay, err := RelayConnect(ctx, "ws"+ts.URL[len("http"):],
+		WithReconnectBackoff(func(int) time.Duration { return 10 * time.Millisecond }),
+		WithMaxReconnectAttempts(2),
+		WithErrorHandler(func(err *RelayError) {
+			mu.Lock()
+			reported = append(reported, err)
+			mu.Unlock()
+			if err.Kind == ErrorReconnectExhausted {
+				close(exhausted)
+			}
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer relay.Close()
+
+	select {
+	case <-exhausted:
+	case <-ctx.Done():
+		t.Fatal("relay didn't give up reconnecting")
+	}
+	select {
+	case <-relay.ConnectionContext.Done():
+	case <-ctx.Done():
+		t.Fatal("relay wasn't closed after giving up")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var kinds []RelayErrorKind
+	for _, err := range reported {
+		kinds = append(kinds, err.Kind)
+		if err.URL != relay.URL || err.Time.IsZero() {
+			t.Errorf("error without context: %+v", err)
+		}
+	}
+	expected := []RelayErrorKind{ErrorRead, ErrorReconnect, ErrorReconnect, ErrorReconnectExhausted}
+	if len(kinds) != len(expected) {
+		t.Fatalf("reported %v", kinds)
+	}
+	for i := range kinds {
+		if kinds[i] != expected[i] {
+			t.Fatalf("reported %v", kinds)
+		}
+	}
+	if !errors.Is(reported[3], ErrReconnectExhausted) {
+		t.Errorf("unexpected error %v", reported[3])
+	}
+}
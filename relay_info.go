@@ -0,0 +1,103 @@
+package nostr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr/nip11"
+)
+
+// ErrUnsupported is returned (wrapped) when an operation needs a NIP the relay doesn't advertise.
+var ErrUnsupported = errors.New("unsupported by relay")
+
+// how long to wait before trying to fetch the NIP-11 document again after a failure
+var informationRetryInterval = 5 * time.Minute
+
+type relayInformation struct {
+	mutex     sync.Mutex
+	document  *nip11.RelayInformationDocument
+	err       error
+	fetchedAt time.Time
+	fetching  chan struct{} // closed when the fetch in progress, if any, is done
+}
+
+// Information returns the NIP-11 document of the relay, which is fetched on the first call
+// and cached afterwards. Failures are cached for a while too, so relays without a NIP-11
+// document aren't asked again on every call.
+func (r *Relay) Information(ctx context.Context) (*nip11.RelayInformationDocument, error) {
+	r.info.mutex.Lock()
+	for r.info.fetching != nil {
+		// somebody else is fetching it already
+		fetching := r.info.fetching
+		r.info.mutex.Unlock()
+		select {
+		case <-fetching:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		r.info.mutex.Lock()
+	}
+	if r.info.document != nil || (r.info.err != nil && Now().Sub(r.info.fetchedAt) < informationRetryInterval) {
+		defer r.info.mutex.Unlock()
+		return r.info.document, r.info.err
+	}
+	fetching := make(chan struct{})
+	r.info.fetching = fetching
+	r.info.mutex.Unlock()
+
+	document, err := nip11.Fetch(ctx, r.URL)
+
+	r.info.mutex.Lock()
+	defer r.info.mutex.Unlock()
+	r.info.fetching = nil
+	close(fetching)
+	// only remember failures that are about the relay, not about our ctx
+	if err == nil || ctx.Err() == nil {
+		r.info.document, r.info.err = document, err
+		r.info.fetchedAt = Now()
+	}
+	return document, err
+}
+
+// Supports tells if the relay advertises support for the given NIP in its NIP-11 document.
+// If the document can't be fetched the relay is assumed to support nothing beyond NIP-01.
+func (r *Relay) Supports(ctx context.Context, nip int) bool {
+	if nip == 1 {
+		return true
+	}
+
+	info, err := r.Information(ctx)
+	return err == nil && advertises(info, nip)
+}
+
+func advertises(info *nip11.RelayInformationDocument, nip int) bool {
+	for _, supported := range info.SupportedNIPs {
+		if supported == nip {
+			return true
+		}
+	}
+	return false
+}
+
+// requireNIP fails if the relay's NIP-11 document doesn't list nip. Relays whose document
+// can't be fetched may support it anyway, so they get the benefit of the doubt.
+func (r *Relay) requireNIP(ctx context.Context, nip int, feature string) error {
+	info, err := r.Information(ctx)
+	if err != nil || advertises(info, nip) {
+		return nil
+	}
+	return fmt.Errorf("%s (NIP-%02d) is %w %s", feature, nip, ErrUnsupported, r.URL)
+}
+
+// checkFilters makes sure the relay will understand the filters before we send them.
+func (r *Relay) checkFilters(ctx context.Context, filters Filters) error {
+	for _, filter := range filters {
+		if filter.Search != "" {
+			return r.requireNIP(ctx, 50, "search")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package nostr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nbd-wtf/go-nostr/nip11"
+)
+
+// ErrRelayLimit is returned (wrapped) when something would go over a limit the relay advertises
+// in its NIP-11 document, and would be dropped or rejected if it was sent.
+var ErrRelayLimit = errors.New("over the relay limits")
+
+// limitation returns the limits the relay advertises. The NIP-11 document is only fetched if
+// fetch is true, otherwise it is only used if we already have it.
+func (r *Relay) limitation(ctx context.Context, fetch bool) *nip11.RelayLimitationDocument {
+	var info *nip11.RelayInformationDocument
+	if fetch {
+		info, _ = r.Information(ctx)
+	} else {
+		r.info.mutex.Lock()
+		info = r.info.document
+		r.info.mutex.Unlock()
+	}
+	if info == nil {
+		return nil
+	}
+	return info.Limitation
+}
+
+// Limits checked before publishing to relays that don't advertise their own in NIP-11, when
+// enabled with WithDefaultLimits. They are the defaults of popular relay implementations, which
+// drop bigger events without an answer.
+var (
+	DefaultMaxMessageLength  = 128 * 1024
+	DefaultMaxEventTags      = 2000
+	DefaultMaxTagValueLength = 1024 // for the values of single-letter tags, that relays index
+)
+
+// WithDefaultLimits makes Publish also refuse events over DefaultMaxMessageLength,
+// DefaultMaxEventTags or DefaultMaxTagValueLength when the relay doesn't advertise its own
+// limits, instead of only checking the ones it advertises.
+func WithDefaultLimits() RelayOption {
+	return func(r *Relay) {
+		r.defaultLimits = true
+	}
+}
+
+// checkEvent fails if the relay would refuse evt because of its size, number of tags or proof of
+// work, or because it has empty tags.
+func (r *Relay) checkEvent(ctx context.Context, evt Event) error {
+	for _, tag := range evt.Tags {
+		if len(tag) == 0 {
+			return fmt.Errorf("event has an empty tag: %w", ErrRelayLimit)
+		}
+		if r.defaultLimits && len(tag[0]) == 1 && len(tag) > 1 && DefaultMaxTagValueLength > 0 &&
+			len(tag[1]) > DefaultMaxTagValueLength {
+			return fmt.Errorf("'%s' tag value is %d bytes, more than %d: %w",
+				tag[0], len(tag[1]), DefaultMaxTagValueLength, ErrRelayLimit)
+		}
+	}
+
+	lim := r.limitation(ctx, true)
+	if lim == nil {
+		lim = &nip11.RelayLimitationDocument{}
+	}
+
+	maxMessage := lim.MaxMessageLength
+	if maxMessage <= 0 && r.defaultLimits {
+		maxMessage = DefaultMaxMessageLength
+	}
+	if maxMessage > 0 {
+		j, _ := json.Marshal([]any{"EVENT", evt})
+		if len(j) > maxMessage {
+			return fmt.Errorf("event is %d bytes, %s accepts %d: %w", len(j), r.URL, maxMessage, ErrRelayLimit)
+		}
+	}
+	if length := utf8.RuneCountInString(evt.Content); lim.MaxContentLength > 0 && length > lim.MaxContentLength {
+		return fmt.Errorf("content is %d characters, %s accepts %d: %w",
+			length, r.URL, lim.MaxContentLength, ErrRelayLimit)
+	}
+	maxTags := lim.MaxEventTags
+	if maxTags <= 0 && r.defaultLimits {
+		maxTags = DefaultMaxEventTags
+	}
+	if maxTags > 0 && len(evt.Tags) > maxTags {
+		return fmt.Errorf("event has %d tags, %s accepts %d: %w", len(evt.Tags), r.URL, maxTags, ErrRelayLimit)
+	}
+	window := CreatedAtWindow{
+		Past:   time.Duration(lim.CreatedAtLowerLimit) * time.Second,
+		Future: time.Duration(lim.CreatedAtUpperLimit) * time.Second,
+	}
+	if window.Check(&evt, Now()) != nil {
+		return fmt.Errorf("created_at %d is outside of the range %s accepts: %w",
+			evt.CreatedAt.Unix(), r.URL, ErrRelayLimit)
+	}
+	if lim.MinPowDifficulty > 0 {
+		if difficulty := powDifficulty(evt.ID); difficulty < lim.MinPowDifficulty {
+			return fmt.Errorf("event has proof of work %d, %s requires %d: %w",
+				difficulty, r.URL, lim.MinPowDifficulty, ErrRelayLimit)
+		}
+	}
+	return nil
+}
+
+// fitSubscriptionID leaves the label out of the subscription id if the ids of its REQs would be
+// longer than the relay's max_subid_length, and fails if they still are.
+func (r *Relay) fitSubscriptionID(sub *Subscription) error {
+	lim := r.limitation(sub.Context, false)
+	if lim == nil || lim.MaxSubidLength <= 0 {
+		return nil
+	}
+
+	last := len(sub.getBatches()) - 1
+	if len(sub.batchID(last)) > lim.MaxSubidLength {
+		sub.shortID = true
+		if len(sub.batchID(last)) > lim.MaxSubidLength {
+			return fmt.Errorf("subscription id %s is longer than the %d characters %s accepts: %w",
+				sub.batchID(last), lim.MaxSubidLength, r.URL, ErrRelayLimit)
+		}
+	}
+	return nil
+}
+
+// clampLimits lowers the limit of filters asking for more events than the relay returns,
+// so we know we may get less than we asked for.
+func clampLimits(filters Filters, maxLimit int) Filters {
+	if maxLimit <= 0 {
+		return filters
+	}
+	var clamped Filters
+	for i, filter := range filters {
+		if filter.Limit > maxLimit {
+			if clamped == nil {
+				// don't modify the filters the caller gave us
+				clamped = make(Filters, len(filters))
+				copy(clamped, filters)
+			}
+			clamped[i].Limit = maxLimit
+		}
+	}
+	if clamped == nil {
+		return filters
+	}
+	return clamped
+}
+
+// powDifficulty is the number of leading zero bits of id, as in NIP-13.
+func powDifficulty(id ID) int {
+	zeros := 0
+	for _, b := range id {
+		zeros += bits.LeadingZeros8(b)
+		if b != 0 {
+			break
+		}
+	}
+	return zeros
+}
+
+// MinePow adds a NIP-13 "nonce" tag to the event and changes it, along with CreatedAt, until
+// the id has at least difficulty leading zero bits. PubKey must already be set, as it is part
+// of the id, and the event must be signed afterwards. It gives up with ctx.Err() when ctx is done.
+func (evt *Event) MinePow(ctx context.Context, difficulty int) error {
+	tag := Tag{"nonce", "", strconv.Itoa(difficulty)}
+	evt.Tags = append(evt.Tags.FilterOut([]string{"nonce"}), tag)
+
+	for nonce := uint64(1); ; nonce++ {
+		if nonce%1000 == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		tag[1] = strconv.FormatUint(nonce, 10)
+		evt.CreatedAt = Now()
+		if id := evt.GetID(); powDifficulty(id) >= difficulty {
+			evt.ID = id
+			return nil
+		}
+	}
+}
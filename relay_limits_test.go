@@ -0,0 +1,153 @@
+package nostr
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr/nip11"
+	"golang.org/x/net/websocket"
+)
+
+func TestRelayLimits(t *testing.T) {
+	fr := &fakeRelay{}
+	var fetches int32
+	wsHandler := &websocket.Server{Handshake: anyOriginHandshake, Handler: fr.handle}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") == "application/nostr+json" {
+			atomic.AddInt32(&fetches, 1)
+			w.Write([]byte(`{"name":"test","limitation":{"max_limit":10,"max_content_length":20,"min_pow_difficulty":8}}`))
+			return
+		}
+		wsHandler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	signer := newTestSigner(t)
+	client := NewClient(ctx, signer, nil, []string{server.URL})
+	defer client.Close()
+	relay, err := client.Pool.EnsureRelay(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	long := Event{Kind: 1, CreatedAt: time.Now(), Content: strings.Repeat("a", 21)}
+	signer.SignEvent(ctx, &long)
+	if _, err := relay.Publish(ctx, long); !errors.Is(err, ErrRelayLimit) {
+		t.Fatalf("expected ErrRelayLimit for long content, got %v", err)
+	}
+
+	// the limit is in characters, not bytes
+	accented := Event{Kind: 1, CreatedAt: time.Now(), Content: strings.Repeat("é", 20)}
+	signer.SignEvent(ctx, &accented)
+	if err := relay.checkEvent(ctx, accented); err != nil && strings.Contains(err.Error(), "content") {
+		t.Fatalf("content within the limit was refused: %v", err)
+	}
+
+	evt := Event{Kind: 1, CreatedAt: time.Now(), Content: "hello"}
+	signer.SignEvent(ctx, &evt)
+	if powDifficulty(evt.ID) < 8 {
+		if _, err := relay.Publish(ctx, evt); !errors.Is(err, ErrRelayLimit) {
+			t.Fatalf("expected ErrRelayLimit without proof of work, got %v", err)
+		}
+	}
+
+	// the client mines before signing
+	mined := Event{Kind: 1, CreatedAt: time.Now(), Content: "hello"}
+	if err := client.Publish(ctx, &mined); err != nil {
+		t.Fatalf("publish failed: %s", err)
+	}
+	if powDifficulty(mined.ID) < 8 || mined.Tags.GetFirst([]string{"nonce"}) == nil {
+		t.Fatalf("event wasn't mined: %s", mined.ID)
+	}
+	if ok, _ := mined.CheckSignature(); !ok {
+		t.Fatal("mined event has an invalid signature")
+	}
+
+	// limits above max_limit are lowered
+	if _, err := relay.Query(ctx, Filter{Kinds: []int{1}, Limit: 500}); err != nil {
+		t.Fatal(err)
+	}
+	fr.mu.Lock()
+	if len(fr.lastReq) != 1 || fr.lastReq[0].Limit != 10 {
+		t.Errorf("limit wasn't clamped: %v", fr.lastReq)
+	}
+	fr.mu.Unlock()
+
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Errorf("NIP-11 document fetched %d times, expected once", n)
+	}
+}
+
+func TestFitSubscriptionID(t *testing.T) {
+	relay := NewRelay("wss://relay.example.com")
+	relay.info.document = &nip11.RelayInformationDocument{Limitation: &nip11.RelayLimitationDocument{MaxSubidLength: 8}}
+
+	sub := &Subscription{Relay: relay, Context: context.Background(), label: "profile-loader", counter: 123}
+	if err := relay.fitSubscriptionID(sub); err != nil || sub.GetID() != "123" {
+		t.Fatalf("expected a short id, got %s, %v", sub.GetID(), err)
+	}
+
+	sub = &Subscription{Relay: relay, Context: context.Background(), label: "a", counter: 123}
+	if err := relay.fitSubscriptionID(sub); err != nil || sub.GetID() != "a:123" {
+		t.Fatalf("id shouldn't have changed, got %s, %v", sub.GetID(), err)
+	}
+}
+
+func TestDefaultEventLimits(t *testing.T) {
+	fr := &fakeRelay{}
+	ws := newWebsocketServer(fr.handle)
+	defer ws.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sk, pk := makeKeyPair(t)
+
+	// without WithDefaultLimits only the advertised limits are checked
+	relay := mustRelayConnect(ws.URL)
+	long := Event{Kind: 1, PubKey: pk, CreatedAt: time.Now(), Tags: Tags{{"t", strings.Repeat("a", DefaultMaxTagValueLength+1)}}}
+	long.Sign(sk)
+	if status, err := relay.Publish(ctx, long); status != PublishStatusSucceeded {
+		t.Fatalf("publish failed: %s, %v", status, err)
+	}
+	relay.Close()
+	fr.mu.Lock()
+	fr.events = nil
+	fr.mu.Unlock()
+
+	relay, err := RelayConnect(ctx, ws.URL, WithDefaultLimits())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer relay.Close()
+
+	manyTags := make(Tags, DefaultMaxEventTags+1)
+	for i := range manyTags {
+		manyTags[i] = Tag{"t", "many"}
+	}
+	for _, evt := range []Event{
+		{Kind: 1, PubKey: pk, CreatedAt: time.Now(), Content: strings.Repeat("a", DefaultMaxMessageLength)},
+		{Kind: 1, PubKey: pk, CreatedAt: time.Now(), Tags: Tags{{}}},
+		{Kind: 1, PubKey: pk, CreatedAt: time.Now(), Tags: Tags{{"t", strings.Repeat("a", DefaultMaxTagValueLength+1)}}},
+		{Kind: 1, PubKey: pk, CreatedAt: time.Now(), Tags: manyTags},
+	} {
+		evt.Sign(sk)
+		if _, err := relay.Publish(ctx, evt); !errors.Is(err, ErrRelayLimit) {
+			t.Errorf("expected ErrRelayLimit, got %v", err)
+		}
+	}
+
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	if len(fr.events) != 0 {
+		t.Fatalf("relay got %d events", len(fr.events))
+	}
+}
@@ -0,0 +1,155 @@
+package nostr
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"time"
+)
+
+// RelayOption configures a Relay before it connects, see RelayConnect and NewRelay.
+type RelayOption func(*Relay)
+
+// WithRequestHeader sets the headers of the websocket handshake, e.g. for the Origin header.
+func WithRequestHeader(header http.Header) RelayOption {
+	return func(r *Relay) {
+		r.RequestHeader = header
+	}
+}
+
+// WithNoticeHandler makes the relay call handler with every "NOTICE" it receives, in order,
+// instead of sending them to the Notices channel, which drops them once full. handler is called
+// from the goroutine reading from the relay, so it must not block. See ParseNotice.
+func WithNoticeHandler(handler func(notice string)) RelayOption {
+	return func(r *Relay) {
+		r.noticeHandler = handler
+	}
+}
+
+// WithAuthHandler makes the relay answer NIP-42 challenges on its own: handler gets an
+// unsigned kind-22242 event to sign, like Signer.SignEvent does, which is then sent with Auth.
+// Challenges are still sent to the Challenges channel if there is no handler.
+func WithAuthHandler(handler func(ctx context.Context, evt *Event) error) RelayOption {
+	return func(r *Relay) {
+		r.authHandler = handler
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used to connect to wss:// relays.
+func WithTLSConfig(config *tls.Config) RelayOption {
+	return func(r *Relay) {
+		r.tlsConfig = config
+	}
+}
+
+// WithSignatureChecker replaces the signature verification of received events, see
+// Relay.SignatureChecker.
+func WithSignatureChecker(checker SignatureChecker) RelayOption {
+	return func(r *Relay) {
+		r.SignatureChecker = checker
+	}
+}
+
+// WithStrictParsing makes the relay drop, and report on Errors, messages and events that
+// don't parse with ParseMessageStrict and Event.UnmarshalStrict, instead of reading what it can
+// from them.
+func WithStrictParsing() RelayOption {
+	return func(r *Relay) {
+		r.strict = true
+	}
+}
+
+// WithMaxMessageSize makes the relay skip messages larger than size bytes, instead of the ones
+// larger than DefaultMaxMessageSize, without keeping them in memory. They are reported as
+// ErrorMessageTooLarge, and if disconnect is set the relay is closed too, as one sending them is
+// probably broken or malicious. A negative size means no limit.
+func WithMaxMessageSize(size int64, disconnect bool) RelayOption {
+	return func(r *Relay) {
+		r.maxMessageSize = size
+		r.closeOversized = disconnect
+	}
+}
+
+// WithMaxEventSize makes the relay drop events larger than size bytes as JSON, reporting them as
+// ErrorMessageTooLarge.
+func WithMaxEventSize(size int) RelayOption {
+	return func(r *Relay) {
+		r.maxEventSize = size
+	}
+}
+
+// WithSignatureVerifier makes the relay verify signatures with verifier instead of
+// DefaultSignatureVerifier, e.g. to give relays of a pool more or fewer workers. Relay.SignatureChecker
+// is still what decides if each event is valid.
+func WithSignatureVerifier(verifier *SignatureVerifier) RelayOption {
+	return func(r *Relay) {
+		r.verifier = verifier
+	}
+}
+
+// WithSignatureCache makes the relay remember the signatures it verified in cache instead of
+// DefaultSignatureCache, e.g. to keep the relays of one pool apart from the rest, or not at all
+// with NewSignatureCache(0). It isn't used if Relay.SignatureChecker is set.
+func WithSignatureCache(cache *SignatureCache) RelayOption {
+	return func(r *Relay) {
+		r.signatureCache = cache
+	}
+}
+
+// WithCreatedAtWindow makes the relay check the created_at of received events against window:
+// the ones outside of it are reported on Errors and dropped, or delivered anyway if
+// window.ReportOnly is set.
+func WithCreatedAtWindow(window CreatedAtWindow) RelayOption {
+	return func(r *Relay) {
+		r.createdAtWindow = &window
+	}
+}
+
+// WithReconnectBackoff sets how long to wait before each attempt to connect again after the
+// connection drops, instead of DefaultBackoff.
+func WithReconnectBackoff(backoff Backoff) RelayOption {
+	return func(r *Relay) {
+		r.backoff = backoff
+	}
+}
+
+// WithConnectionStateHandler makes the relay call handler whenever its connection goes up, down,
+// starts dialing again or is closed. It is called synchronously, so it shouldn't block. See also
+// Relay.Status and Relay.WatchStatus.
+func WithConnectionStateHandler(handler func(state ConnectionState)) RelayOption {
+	return func(r *Relay) {
+		r.stateHandler = handler
+	}
+}
+
+// NewRelay returns a Relay for url, configured with opts, that still has to Connect.
+func NewRelay(url string, opts ...RelayOption) *Relay {
+	r := &Relay{URL: NormalizeURL(url)}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// newAuthEvent returns the unsigned NIP-42 event answering challenge from the relay at url.
+func newAuthEvent(url string, challenge string) Event {
+	return Event{
+		CreatedAt: Now(),
+		Kind:      KindClientAuthentication,
+		Tags: Tags{
+			Tag{"relay", url},
+			Tag{"challenge", challenge},
+		},
+	}
+}
+
+// handleChallenge answers the last challenge using the auth handler, failures are reported as
+// ErrorAuth.
+func (r *Relay) handleChallenge() {
+	ctx, cancel := context.WithTimeout(r.ConnectionContext, 10*time.Second)
+	defer cancel()
+
+	if err := r.Authenticate(ctx, r.authHandler); err != nil {
+		r.reportError(ErrorAuth, err)
+	}
+}
@@ -0,0 +1,69 @@
+package nostr
+
+import (
+	"context"
+	"errors"
+
+	"github.com/nbd-wtf/go-nostr/nip11"
+)
+
+// ErrPaymentRequired is wrapped by PaymentRequiredError, so it can be checked with errors.Is.
+var ErrPaymentRequired = errors.New("payment required")
+
+// PaymentRequiredError is returned by Publish when the relay refuses an event until it is paid.
+type PaymentRequiredError struct {
+	Relay   string
+	Message string // as sent by the relay in the "OK"
+
+	// from the relay's NIP-11 document, if it has them
+	PaymentsURL string
+	Fees        *nip11.RelayFeesDocument
+}
+
+func (e *PaymentRequiredError) Error() string {
+	msg := e.Relay + " requires payment: " + e.Message
+	if e.PaymentsURL != "" {
+		msg += " (pay at " + e.PaymentsURL + ")"
+	}
+	return msg
+}
+
+func (e *PaymentRequiredError) Unwrap() error { return ErrPaymentRequired }
+
+// PaymentHandler is called when a relay asks to be paid, e.g. to pay its invoice with NWC.
+// If it returns nil the event is published again.
+type PaymentHandler func(ctx context.Context, payment *PaymentRequiredError) error
+
+// WithPaymentHandler makes Publish call handler when the relay refuses an event because it
+// wasn't paid, and try again once if handler succeeds.
+func WithPaymentHandler(handler PaymentHandler) RelayOption {
+	return func(r *Relay) {
+		r.paymentHandler = handler
+	}
+}
+
+// RequiresPayment tells if the relay says in its NIP-11 document that it must be paid for.
+func (r *Relay) RequiresPayment(ctx context.Context) bool {
+	lim := r.limitation(ctx, true)
+	return lim != nil && lim.PaymentRequired
+}
+
+// paymentError returns a PaymentRequiredError if err, from a rejected event, says the relay wants
+// to be paid: its message has the "payment-required:" prefix, or it is "restricted:" on a paid relay.
+func (r *Relay) paymentError(ctx context.Context, err error) *PaymentRequiredError {
+	var rejected *RejectionError
+	if !errors.As(err, &rejected) {
+		return nil
+	}
+	if prefix := rejected.Notice().Prefix; prefix != NoticePaymentRequired &&
+		!(prefix == NoticeRestricted && r.RequiresPayment(ctx)) {
+		return nil
+	}
+
+	payment := &PaymentRequiredError{Relay: r.URL, Message: rejected.Reason}
+	if info, err := r.Information(ctx); err == nil {
+		payment.PaymentsURL = info.PaymentsURL
+		payment.Fees = info.Fees
+	}
+	return payment
+}
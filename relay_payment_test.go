@@ -0,0 +1,86 @@
+package nostr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestPaymentRequired(t *testing.T) {
+	var mu sync.Mutex
+	paid := false
+	wsHandler := &websocket.Server{
+		Handshake: anyOriginHandshake,
+		Handler: func(conn *websocket.Conn) {
+			for {
+				var raw []json.RawMessage
+				if err := websocket.JSON.Receive(conn, &raw); err != nil {
+					return
+				}
+				var typ string
+				json.Unmarshal(raw[0], &typ)
+				if typ != "EVENT" {
+					continue
+				}
+				var evt Event
+				json.Unmarshal(raw[1], &evt)
+				mu.Lock()
+				ok := paid
+				mu.Unlock()
+				if ok {
+					websocket.JSON.Send(conn, []any{"OK", evt.ID, true, ""})
+				} else {
+					websocket.JSON.Send(conn, []any{"OK", evt.ID, false, "restricted: pay first"})
+				}
+			}
+		},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") == "application/nostr+json" {
+			w.Write([]byte(`{"name":"paid","limitation":{"payment_required":true},"payments_url":"https://relay.example.com/pay",` +
+				`"fees":{"admission":[{"amount":21000,"unit":"msats"}]}}`))
+			return
+		}
+		wsHandler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	sk, pk := makeKeyPair(t)
+	evt := Event{Kind: 1, PubKey: pk, CreatedAt: time.Now(), Content: "hello"}
+	evt.Sign(sk)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	relay := mustRelayConnect(server.URL)
+	defer relay.Close()
+	_, err := relay.Publish(ctx, evt)
+	var payment *PaymentRequiredError
+	if !errors.As(err, &payment) || !errors.Is(err, ErrPaymentRequired) {
+		t.Fatalf("expected a PaymentRequiredError, got %v", err)
+	}
+	if payment.PaymentsURL != "https://relay.example.com/pay" || payment.Fees.Admission[0].Amount != 21000 {
+		t.Fatalf("payment info missing: %+v", payment)
+	}
+
+	relay, err = RelayConnect(ctx, server.URL, WithPaymentHandler(func(ctx context.Context, p *PaymentRequiredError) error {
+		mu.Lock()
+		paid = true
+		mu.Unlock()
+		return nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer relay.Close()
+	if status, err := relay.Publish(ctx, evt); status != PublishStatusSucceeded {
+		t.Fatalf("publish after paying failed: %v", err)
+	}
+}
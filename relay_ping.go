@@ -0,0 +1,98 @@
+package nostr
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// some relays sit behind proxies that don't answer websocket pings, so we don't wait for the
+// pong more than this before trying a REQ instead
+const pongTimeout = 3 * time.Second
+
+// Ping measures the round trip time to the relay. It sends a websocket ping and waits for the
+// pong, or, if it doesn't come, sends a REQ that can't match anything and waits for the "EOSE".
+// An error means the relay is not reachable right now.
+func (r *Relay) Ping(ctx context.Context) (time.Duration, error) {
+	defer r.operation()()
+	if r.conn == nil {
+		return 0, fmt.Errorf("not connected to %s", r.URL)
+	}
+	if r.isClosed() {
+		return 0, ErrRelayClosed
+	}
+	if !r.conn.IsConnected() {
+		return 0, fmt.Errorf("%s is reconnecting", r.URL)
+	}
+
+	rtt, err := r.pingPong(ctx)
+	if err == nil || ctx.Err() != nil {
+		return rtt, err
+	}
+	return r.pingREQ(ctx)
+}
+
+func (r *Relay) pingPong(ctx context.Context) (time.Duration, error) {
+	payload := make([]byte, 8)
+	rand.Read(payload)
+	key := hex.EncodeToString(payload)
+
+	pong := make(chan struct{}, 1)
+	r.pongWaiters.Store(key, pong)
+	defer r.pongWaiters.Delete(key)
+
+	start := time.Now()
+	if err := r.conn.WriteMessage(websocket.PingMessage, []byte(key)); err != nil {
+		return 0, err
+	}
+
+	select {
+	case <-pong:
+		return time.Since(start), nil
+	case <-time.After(pongTimeout):
+		return 0, errors.New("no pong received")
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+func (r *Relay) pingREQ(ctx context.Context) (time.Duration, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	start := time.Now()
+	// an id made of zeroes won't match anything, so the relay should just send "EOSE"
+	sub, err := r.Sub(ctx, Filters{{IDs: []ID{{}}, Limit: 1}})
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		select {
+		case <-sub.EndOfStoredEvents:
+			return time.Since(start), nil
+		case _, more := <-sub.Events:
+			if !more {
+				return 0, fmt.Errorf("subscription closed before EOSE")
+			}
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+}
+
+// handlePong is the pong handler set on every new websocket connection.
+func (r *Relay) handlePong(payload string) error {
+	if pong, ok := r.pongWaiters.Load(payload); ok {
+		select {
+		case pong <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package nostr
+
+import (
+	"context"
+	"sync"
+)
+
+// statusWatchers are the channels returned by Relay.WatchStatus.
+type statusWatchers struct {
+	mutex    sync.Mutex
+	watchers map[chan ConnectionState]struct{}
+}
+
+// Status is the state of the connection to the relay right now, ConnectionDisconnected if
+// Connect wasn't called yet.
+func (r *Relay) Status() ConnectionState {
+	if r.conn == nil {
+		return ConnectionDisconnected
+	}
+	if r.isClosed() {
+		return ConnectionClosed
+	}
+	return r.conn.currentState()
+}
+
+// WatchStatus returns a channel that gets the state of the connection now and every time it
+// changes, e.g. to show whether the relay is reachable. Readers that fall behind only get the
+// latest state. It is closed when ctx is done or after ConnectionClosed.
+func (r *Relay) WatchStatus(ctx context.Context) <-chan ConnectionState {
+	ch := make(chan ConnectionState, 1)
+
+	w := &r.statusWatchers
+	w.mutex.Lock()
+	state := r.Status()
+	ch <- state
+	if state == ConnectionClosed {
+		w.mutex.Unlock()
+		close(ch)
+		return ch
+	}
+	if w.watchers == nil {
+		w.watchers = make(map[chan ConnectionState]struct{})
+	}
+	w.watchers[ch] = struct{}{}
+	w.mutex.Unlock()
+
+	go func() {
+		closed := false
+		select {
+		case <-ctx.Done():
+		case <-r.closedStatus():
+			closed = true
+		}
+		w.mutex.Lock()
+		defer w.mutex.Unlock()
+		if _, ok := w.watchers[ch]; ok {
+			if closed {
+				replaceStatus(ch, ConnectionClosed)
+			}
+			delete(w.watchers, ch)
+			close(ch)
+		}
+	}()
+	return ch
+}
+
+// closedStatus is done once the relay is closed, or never if it never connected.
+func (r *Relay) closedStatus() <-chan struct{} {
+	if r.ConnectionContext == nil {
+		return nil
+	}
+	return r.ConnectionContext.Done()
+}
+
+// setStatus is called by the connection on every state change.
+func (r *Relay) setStatus(state ConnectionState) {
+	if r.stateHandler != nil {
+		r.stateHandler(state)
+	}
+
+	w := &r.statusWatchers
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	for ch := range w.watchers {
+		replaceStatus(ch, state)
+		if state == ConnectionClosed {
+			delete(w.watchers, ch)
+			close(ch)
+		}
+	}
+}
+
+// replaceStatus puts state in ch in place of the one waiting there, if any, as only the latest
+// matters to readers that are behind. Must be called with the watchers mutex held.
+func replaceStatus(ch chan ConnectionState, state ConnectionState) {
+	select {
+	case <-ch:
+	default:
+	}
+	ch <- state
+}
@@ -8,7 +8,9 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -167,14 +169,14 @@ var anyOriginHandshake = func(conf *websocket.Config, r *http.Request) error {
 	return nil
 }
 
-func makeKeyPair(t *testing.T) (priv, pub string) {
+func makeKeyPair(t *testing.T) (priv string, pub PubKey) {
 	t.Helper()
 	privkey := GeneratePrivateKey()
 	pubkey, err := GetPublicKey(privkey)
 	if err != nil {
 		t.Fatalf("GetPublicKey(%q): %v", privkey, err)
 	}
-	return privkey, pubkey
+	return privkey, MustPubKeyFromHex(pubkey)
 }
 
 func mustRelayConnect(url string) *Relay {
@@ -228,3 +230,615 @@ func parseSubscriptionMessage(t *testing.T, raw []json.RawMessage) (subid string
 	}
 	return id, ff
 }
+
+func TestRelayCapabilities(t *testing.T) {
+	wsHandler := &websocket.Server{
+		Handshake: anyOriginHandshake,
+		Handler: func(conn *websocket.Conn) {
+			for {
+				var raw []json.RawMessage
+				if err := websocket.JSON.Receive(conn, &raw); err != nil {
+					return
+				}
+				var typ, subid string
+				json.Unmarshal(raw[0], &typ)
+				json.Unmarshal(raw[1], &subid)
+				if typ == "COUNT" {
+					websocket.JSON.Send(conn, []any{"COUNT", subid, map[string]any{"count": 42}})
+				}
+			}
+		},
+	}
+	var mu sync.Mutex
+	var fetches int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") == "application/nostr+json" {
+			mu.Lock()
+			fetches++
+			mu.Unlock()
+			w.Write([]byte(`{"name":"test","supported_nips":[1,11,45]}`))
+			return
+		}
+		wsHandler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	rl := mustRelayConnect(server.URL)
+	defer rl.Close()
+
+	if !rl.Supports(context.Background(), 45) || rl.Supports(context.Background(), 50) {
+		t.Errorf("wrong supported nips")
+	}
+	mu.Lock()
+	if fetches != 1 {
+		t.Errorf("information document was fetched %d times, should be cached", fetches)
+	}
+	mu.Unlock()
+
+	if _, err := rl.Sub(context.Background(), Filters{{Search: "nostr"}}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("search subscription should have failed with ErrUnsupported, got %v", err)
+	}
+	sub := rl.Subscribe(context.Background(), Filters{{Search: "nostr"}})
+	if _, open := <-sub.Events; open || !strings.Contains(<-sub.ClosedReason, "unsupported") {
+		t.Errorf("failed subscription should be closed with the error")
+	}
+
+	count, err := rl.Count(context.Background(), Filters{{Kinds: []int{1}}})
+	if err != nil {
+		t.Fatalf("count failed: %s", err)
+	}
+	if count != 42 {
+		t.Errorf("count is %d, not 42", count)
+	}
+}
+
+func TestSubscribeSplitsFilters(t *testing.T) {
+	priv, pub := makeKeyPair(t)
+	evt := Event{Kind: 1, Content: "hello", CreatedAt: time.Unix(1672068534, 0), PubKey: pub}
+	evt.Sign(priv)
+
+	var mu sync.Mutex
+	var reqs [][]Filter
+	wsHandler := &websocket.Server{
+		Handshake: anyOriginHandshake,
+		Handler: func(conn *websocket.Conn) {
+			for {
+				var raw []json.RawMessage
+				if err := websocket.JSON.Receive(conn, &raw); err != nil {
+					return
+				}
+				var typ string
+				json.Unmarshal(raw[0], &typ)
+				if typ != "REQ" {
+					continue
+				}
+				subid, filters := parseSubscriptionMessage(t, raw)
+				mu.Lock()
+				reqs = append(reqs, filters)
+				mu.Unlock()
+				// every REQ returns the same event, the client must dedupe
+				websocket.JSON.Send(conn, []any{"EVENT", subid, evt})
+				websocket.JSON.Send(conn, []any{"EOSE", subid})
+			}
+		},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") == "application/nostr+json" {
+			w.Write([]byte(`{"supported_nips":[1,11],"limitation":{"max_filters":1}}`))
+			return
+		}
+		wsHandler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	rl := mustRelayConnect(server.URL)
+	defer rl.Close()
+	rl.MaxFilterItems = 50
+
+	authors := make([]PubKey, 149)
+	for i := range authors {
+		authors[i] = PubKey{byte(i), byte(i)}
+	}
+	authors = append(authors, pub)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	events, err := rl.Query(ctx, Filter{Kinds: []int{1}, Authors: authors})
+	if err != nil {
+		t.Fatalf("query failed: %s", err)
+	}
+	if len(events) != 1 || events[0].ID != evt.ID {
+		t.Errorf("expected the event once, got %v", events)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reqs) != 3 {
+		t.Fatalf("expected 3 REQs, got %d", len(reqs))
+	}
+	total := 0
+	for _, filters := range reqs {
+		if len(filters) != 1 || len(filters[0].Authors) > 50 {
+			t.Errorf("REQ over the limits: %v", filters)
+		}
+		total += len(filters[0].Authors)
+	}
+	if total != len(authors) {
+		t.Errorf("REQs had %d authors in total, expected %d", total, len(authors))
+	}
+}
+
+func TestResumeRebatches(t *testing.T) {
+	wsHandler := &websocket.Server{
+		Handshake: anyOriginHandshake,
+		Handler: func(conn *websocket.Conn) {
+			for {
+				var raw []json.RawMessage
+				if err := websocket.JSON.Receive(conn, &raw); err != nil {
+					return
+				}
+			}
+		},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") == "application/nostr+json" {
+			w.Write([]byte(`{"supported_nips":[1,11],"limitation":{"max_filters":1}}`))
+			return
+		}
+		wsHandler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	rl := mustRelayConnect(server.URL)
+	defer rl.Close()
+	rl.MaxFilterItems = 50
+
+	authors := make([]PubKey, 150)
+	for i := range authors {
+		authors[i] = PubKey{byte(i), byte(i)}
+	}
+	sub, err := rl.Sub(context.Background(), Filters{{Kinds: []int{1}, Authors: authors}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsub()
+	if len(sub.batches) != 3 || sub.seen == nil {
+		t.Fatalf("expected 3 batches, got %d", len(sub.batches))
+	}
+
+	// after reconnecting the filters fit in a single REQ
+	sub.Filters = Filters{{Kinds: []int{1}, Authors: authors[:10]}}
+	sub.resume()
+	if len(sub.batches) != 1 || atomic.LoadInt32(&sub.eosePending) != 1 || sub.seen != nil {
+		t.Fatalf("batch state not reset: %d batches, %d pending", len(sub.batches), sub.eosePending)
+	}
+	for i := 1; i < 3; i++ {
+		if _, ok := rl.subscriptions.Load(sub.batchID(i)); ok {
+			t.Errorf("batch %d still registered", i)
+		}
+	}
+}
+
+func TestPing(t *testing.T) {
+	// x/net/websocket answers pings on its own, and our fake relay answers the REQ fallback
+	ws := newWebsocketServer((&fakeRelay{}).handle)
+	defer ws.Close()
+
+	rl := mustRelayConnect(ws.URL)
+	defer rl.Close()
+
+	for name, ping := range map[string]func(context.Context) (time.Duration, error){
+		"pong": rl.pingPong,
+		"req":  rl.pingREQ,
+	} {
+		rtt, err := ping(context.Background())
+		if err != nil {
+			t.Fatalf("%s: ping failed: %s", name, err)
+		}
+		if rtt <= 0 || rtt > pongTimeout {
+			t.Fatalf("%s: unexpected rtt %s", name, rtt)
+		}
+	}
+}
+
+func TestRelayOptions(t *testing.T) {
+	authed := make(chan Event, 1)
+	ws := newWebsocketServer(func(conn *websocket.Conn) {
+		websocket.JSON.Send(conn, []any{"NOTICE", "welcome"})
+		websocket.JSON.Send(conn, []any{"AUTH", "chachachallenge"})
+		for {
+			var raw []json.RawMessage
+			if err := websocket.JSON.Receive(conn, &raw); err != nil {
+				return
+			}
+			var typ string
+			json.Unmarshal(raw[0], &typ)
+			if typ == "AUTH" {
+				var evt Event
+				json.Unmarshal(raw[1], &evt)
+				authed <- evt
+				websocket.JSON.Send(conn, []any{"OK", evt.ID, true, ""})
+			}
+		}
+	})
+	defer ws.Close()
+
+	sk, pk := makeKeyPair(t)
+	notices := make(chan string, 1)
+	rl, err := RelayConnect(context.Background(), ws.URL,
+		WithNoticeHandler(func(notice string) { notices <- notice }),
+		WithAuthHandler(func(ctx context.Context, evt *Event) error {
+			evt.PubKey = pk
+			return evt.Sign(sk)
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rl.Close()
+
+	select {
+	case notice := <-notices:
+		if notice != "welcome" {
+			t.Fatalf("unexpected notice %q", notice)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("notice handler wasn't called")
+	}
+
+	select {
+	case evt := <-authed:
+		if evt.Kind != KindClientAuthentication || evt.PubKey != pk ||
+			evt.Tags.GetFirst([]string{"challenge", "chachachallenge"}) == nil {
+			t.Fatalf("unexpected auth event %v", evt)
+		}
+		if ok, _ := evt.CheckSignature(); !ok {
+			t.Fatal("auth event isn't signed")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("relay wasn't authenticated")
+	}
+}
+
+func TestRelayFrameHooks(t *testing.T) {
+	ws := newWebsocketServer((&fakeRelay{}).handle)
+	defer ws.Close()
+
+	var mu sync.Mutex
+	var sent, received []string
+	relay := NewRelay(ws.URL)
+	relay.OnSend = func(raw []byte) {
+		mu.Lock()
+		sent = append(sent, string(raw))
+		mu.Unlock()
+	}
+	relay.OnReceive = func(raw []byte) {
+		mu.Lock()
+		received = append(received, string(raw))
+		mu.Unlock()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := relay.Connect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer relay.Close()
+
+	sk, pk := makeKeyPair(t)
+	evt := Event{Kind: 1, PubKey: pk, CreatedAt: time.Now(), Content: "traced"}
+	evt.Sign(sk)
+	if _, err := relay.Publish(ctx, evt); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sent) == 0 || !strings.HasPrefix(sent[0], `["EVENT",{"id":"`+evt.ID.Hex()) {
+		t.Fatalf("unexpected sent frames %v", sent)
+	}
+	if len(received) == 0 || !strings.HasPrefix(received[0], `["OK","`+evt.ID.Hex()) {
+		t.Fatalf("unexpected received frames %v", received)
+	}
+}
+
+func TestSubscriptionIDs(t *testing.T) {
+	ws := newWebsocketServer((&fakeRelay{}).handle)
+	defer ws.Close()
+	relay := mustRelayConnect(ws.URL)
+	defer relay.Close()
+
+	// ids are unique even when subscriptions are prepared concurrently
+	var mu sync.Mutex
+	ids := make(map[string]struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id := relay.PrepareSubscription(context.Background()).GetID()
+			mu.Lock()
+			ids[id] = struct{}{}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if len(ids) != 50 {
+		t.Fatalf("got %d distinct ids out of 50", len(ids))
+	}
+
+	// and each relay has its own prefix
+	other := NewRelay(ws.URL)
+	other.PrepareSubscription(context.Background())
+	if relay.subscriptionPrefix == other.subscriptionPrefix {
+		t.Fatalf("two relays got the same prefix %s", relay.subscriptionPrefix)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sub, err := relay.Sub(ctx, Filters{{Kinds: []int{1}}}, WithSubscriptionID("mine"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.GetID() != "mine" {
+		t.Fatalf("subscription id is %s, expected mine", sub.GetID())
+	}
+	if _, err := relay.Sub(ctx, Filters{{Kinds: []int{1}}}, WithSubscriptionID("mine")); err == nil {
+		t.Fatal("subscribed twice with the same id")
+	}
+}
+
+func TestRelayClose(t *testing.T) {
+	ws := newWebsocketServer((&fakeRelay{}).handle)
+	defer ws.Close()
+	relay := mustRelayConnect(ws.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sub, err := relay.Sub(ctx, Filters{{Kinds: []int{1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	relay.reportError(ErrorRead, errors.New("nobody is reading this"))
+
+	relay.Close()
+	relay.Close()
+
+	if relay.ConnectionContext.Err() == nil {
+		t.Error("connection context wasn't canceled")
+	}
+	for range sub.Events {
+	}
+	for range relay.Errors {
+	}
+	for range relay.Notices {
+	}
+	for range relay.Challenges {
+	}
+	if relay.SubscriptionCount() != 0 {
+		t.Errorf("%d subscriptions left", relay.SubscriptionCount())
+	}
+
+	if _, err := relay.Sub(ctx, Filters{{Kinds: []int{1}}}); err != ErrRelayClosed {
+		t.Errorf("Subscribe returned %v", err)
+	}
+	if _, err := relay.Publish(ctx, Event{}); err != ErrRelayClosed {
+		t.Errorf("Publish returned %v", err)
+	}
+	if _, err := relay.Count(ctx, Filters{{Kinds: []int{1}}}); err != ErrRelayClosed {
+		t.Errorf("Count returned %v", err)
+	}
+	if _, err := relay.Ping(ctx); err != ErrRelayClosed {
+		t.Errorf("Ping returned %v", err)
+	}
+}
+
+func TestUnsub(t *testing.T) {
+	fr := &fakeRelay{}
+	ws := newWebsocketServer(fr.handle)
+	defer ws.Close()
+	relay := mustRelayConnect(ws.URL)
+	defer relay.Close()
+
+	var mu sync.Mutex
+	var sent []string
+	relay.OnSend = func(raw []byte) {
+		mu.Lock()
+		sent = append(sent, string(raw))
+		mu.Unlock()
+	}
+
+	sk, pk := makeKeyPair(t)
+	evt := Event{Kind: 1, PubKey: pk, CreatedAt: time.Now(), Content: "unread"}
+	evt.Sign(sk)
+	fr.mu.Lock()
+	fr.events = append(fr.events, evt)
+	fr.mu.Unlock()
+
+	// nobody reads the event the relay sends, that must not block Unsub
+	sub, err := relay.Sub(context.Background(), Filters{{Kinds: []int{1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sub.Unsub()
+		}()
+	}
+	wg.Wait()
+
+	for range sub.Events {
+	}
+	if relay.SubscriptionCount() != 0 {
+		t.Errorf("%d subscriptions left", relay.SubscriptionCount())
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	closes := 0
+	for _, raw := range sent {
+		if raw == `["CLOSE","`+sub.GetID()+`"]` {
+			closes++
+		}
+	}
+	if closes != 1 {
+		t.Errorf("sent %d CLOSE, expected 1: %v", closes, sent)
+	}
+}
+
+func TestSubscribeEOSE(t *testing.T) {
+	fr := &fakeRelay{}
+	sk, pk := makeKeyPair(t)
+	for i := 0; i < 3; i++ {
+		evt := Event{Kind: 1, PubKey: pk, CreatedAt: time.Unix(int64(1700000000+i), 0), Content: "stored"}
+		evt.Sign(sk)
+		fr.events = append(fr.events, evt)
+	}
+	ws := newWebsocketServer(fr.handle)
+	defer ws.Close()
+	relay := mustRelayConnect(ws.URL)
+	defer relay.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, sub, err := relay.SubscribeEOSE(ctx, Filters{{Kinds: []int{1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("got %d stored events, expected 3", len(events))
+	}
+	if sub.Context.Err() != nil || relay.SubscriptionCount() != 1 {
+		t.Fatal("subscription isn't live anymore")
+	}
+
+	cancel()
+	for range sub.Events {
+	}
+}
+
+func TestCloseOnEOSE(t *testing.T) {
+	fr := &fakeRelay{}
+	sk, pk := makeKeyPair(t)
+	for i := 0; i < 2; i++ {
+		evt := Event{Kind: 1, PubKey: pk, CreatedAt: time.Unix(int64(1700000000+i), 0), Content: "stored"}
+		evt.Sign(sk)
+		fr.events = append(fr.events, evt)
+	}
+	ws := newWebsocketServer(fr.handle)
+	defer ws.Close()
+	relay := mustRelayConnect(ws.URL)
+	defer relay.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sub, err := relay.Sub(ctx, Filters{{Kinds: []int{1}}}, WithCloseOnEOSE())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	received := 0
+	for range sub.Events {
+		received++
+	}
+	if received != 2 {
+		t.Fatalf("got %d stored events, expected 2", received)
+	}
+	select {
+	case <-sub.EndOfStoredEvents:
+	default:
+		t.Fatal("Events closed without EOSE")
+	}
+	if ctx.Err() != nil || relay.SubscriptionCount() != 0 {
+		t.Fatal("subscription wasn't closed on EOSE")
+	}
+}
+
+func TestQuerySyncLimit(t *testing.T) {
+	fr := &fakeRelay{eoseDelay: 3 * time.Second}
+	sk, pk := makeKeyPair(t)
+	for i := 0; i < 5; i++ {
+		evt := Event{Kind: 1, PubKey: pk, CreatedAt: time.Unix(int64(1700000000+i), 0), Content: "stored"}
+		evt.Sign(sk)
+		fr.events = append(fr.events, evt)
+	}
+	ws := newWebsocketServer(fr.handle)
+	defer ws.Close()
+	relay := mustRelayConnect(ws.URL)
+	defer relay.Close()
+
+	// the relay ignores the limit and takes long to send "EOSE"
+	start := time.Now()
+	events, err := relay.Query(context.Background(), Filter{Kinds: []int{1}, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events, expected 2", len(events))
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("waited %s for events already received", elapsed)
+	}
+}
+
+func TestSubscriptionNext(t *testing.T) {
+	fr := &fakeRelay{}
+	sk, pk := makeKeyPair(t)
+	for i := 0; i < 2; i++ {
+		evt := Event{Kind: 1, PubKey: pk, CreatedAt: time.Unix(int64(1700000000+i), 0), Content: "stored"}
+		evt.Sign(sk)
+		fr.events = append(fr.events, evt)
+	}
+	ws := newWebsocketServer(fr.handle)
+	defer ws.Close()
+	relay := mustRelayConnect(ws.URL)
+	defer relay.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sub, err := relay.Sub(ctx, Filters{{Kinds: []int{1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		evt, err := sub.Next(ctx)
+		if err != nil || evt.ID != fr.events[i].ID {
+			t.Fatalf("event %d: %v, %v", i, evt, err)
+		}
+	}
+
+	short, cancelShort := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancelShort()
+	if _, err := sub.Next(short); err != context.DeadlineExceeded {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+
+	sub.Unsub()
+	if _, err := sub.Next(ctx); err != ErrSubscriptionClosed {
+		t.Fatalf("expected ErrSubscriptionClosed, got %v", err)
+	}
+
+	// closed by the relay
+	ws2 := newWebsocketServer(func(conn *websocket.Conn) {
+		var raw []json.RawMessage
+		websocket.JSON.Receive(conn, &raw)
+		var id string
+		json.Unmarshal(raw[1], &id)
+		websocket.JSON.Send(conn, []any{"CLOSED", id, "restricted: members only"})
+		io.ReadAll(conn)
+	})
+	defer ws2.Close()
+	relay2 := mustRelayConnect(ws2.URL)
+	defer relay2.Close()
+	sub, err = relay2.Sub(ctx, Filters{{Kinds: []int{1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sub.Next(ctx); !errors.Is(err, ErrSubscriptionClosed) || !strings.Contains(err.Error(), "members only") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package nostr
+
+import "bytes"
+
+// IsReplaceableKind tells if only the latest event of this kind by each author should be kept (NIP-01).
+func IsReplaceableKind(kind int) bool {
+	return kind == KindSetMetadata || kind == KindContactList || (kind >= 10000 && kind < 20000)
+}
+
+// IsAddressableKind tells if only the latest event of this kind by each author for each "d" tag
+// should be kept (NIP-01).
+func IsAddressableKind(kind int) bool {
+	return kind >= 30000 && kind < 40000
+}
+
+// IsEphemeralKind tells if events of this kind are not expected to be stored by relays (NIP-01).
+func IsEphemeralKind(kind int) bool {
+	return kind >= 20000 && kind < 30000
+}
+
+// IsReplaceable tells if evt is of a replaceable kind, see IsReplaceableKind.
+func (evt *Event) IsReplaceable() bool { return IsReplaceableKind(evt.Kind) }
+
+// IsAddressable tells if evt is of an addressable kind, see IsAddressableKind.
+func (evt *Event) IsAddressable() bool { return IsAddressableKind(evt.Kind) }
+
+// IsEphemeral tells if evt is of an ephemeral kind, see IsEphemeralKind.
+func (evt *Event) IsEphemeral() bool { return IsEphemeralKind(evt.Kind) }
+
+// Identifier returns the value of the first "d" tag, which together with the kind and author
+// identifies an addressable event. It is empty if there is no "d" tag.
+func (evt *Event) Identifier() string {
+	if d := evt.Tags.GetFirst([]string{"d", ""}); d != nil {
+		return d.Value()
+	}
+	return ""
+}
+
+// ReplaceableKey identifies the slot occupied by a replaceable or addressable event, in which
+// newer versions replace older ones. D is always empty for replaceable events.
+type ReplaceableKey struct {
+	Kind   int
+	PubKey PubKey
+	D      string
+}
+
+// ReplaceableKey returns the slot this event occupies, or false if it isn't replaceable or addressable.
+func (evt *Event) ReplaceableKey() (ReplaceableKey, bool) {
+	switch {
+	case evt.IsReplaceable():
+		return ReplaceableKey{Kind: evt.Kind, PubKey: evt.PubKey}, true
+	case evt.IsAddressable():
+		return ReplaceableKey{Kind: evt.Kind, PubKey: evt.PubKey, D: evt.Identifier()}, true
+	}
+	return ReplaceableKey{}, false
+}
+
+// IsNewerThan tells if evt replaces other: it is newer, or created at the same time with the
+// lowest id, as NIP-01 says, so every client picks the same version.
+func (evt *Event) IsNewerThan(other *Event) bool {
+	if !evt.CreatedAt.Equal(other.CreatedAt) {
+		return evt.CreatedAt.After(other.CreatedAt)
+	}
+	return bytes.Compare(evt.ID[:], other.ID[:]) < 0
+}
+
+// LatestVersions drops the versions of replaceable and addressable events that were replaced by
+// other events in the list, keeping the order of the rest. Other events are all kept.
+func LatestVersions(events []*Event) []*Event {
+	latest := make(map[ReplaceableKey]*Event)
+	for _, evt := range events {
+		if key, ok := evt.ReplaceableKey(); ok {
+			if current, ok := latest[key]; !ok || evt.IsNewerThan(current) {
+				latest[key] = evt
+			}
+		}
+	}
+
+	results := make([]*Event, 0, len(events))
+	for _, evt := range events {
+		if key, ok := evt.ReplaceableKey(); ok && latest[key] != evt {
+			continue
+		}
+		results = append(results, evt)
+	}
+	return results
+}
@@ -0,0 +1,71 @@
+package nostr
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLatestVersions(t *testing.T) {
+	alice := PubKey{1}
+	bob := PubKey{2}
+	at := func(ts int64) time.Time { return time.Unix(ts, 0) }
+
+	events := []*Event{
+		{ID: ID{1}, PubKey: alice, Kind: 0, CreatedAt: at(100)},
+		{ID: ID{2}, PubKey: alice, Kind: 0, CreatedAt: at(200)},
+		{ID: ID{3}, PubKey: bob, Kind: 0, CreatedAt: at(50)},
+		{ID: ID{5}, PubKey: alice, Kind: 30023, CreatedAt: at(300), Tags: Tags{{"d", "post"}}},
+		{ID: ID{4}, PubKey: alice, Kind: 30023, CreatedAt: at(300), Tags: Tags{{"d", "post"}}},
+		{ID: ID{6}, PubKey: alice, Kind: 30023, CreatedAt: at(100), Tags: Tags{{"d", "other"}}},
+		{ID: ID{7}, PubKey: alice, Kind: 1, CreatedAt: at(10)},
+		{ID: ID{8}, PubKey: alice, Kind: 1, CreatedAt: at(20)},
+	}
+
+	var ids []byte
+	for _, evt := range LatestVersions(events) {
+		ids = append(ids, evt.ID[0])
+	}
+	if string(ids) != string([]byte{2, 3, 4, 6, 7, 8}) {
+		t.Fatalf("wrong events kept: %v", ids)
+	}
+
+	// the store keeps the same ones, whatever the order they arrive in
+	for _, order := range [][]int{{0, 1, 2, 3, 4, 5, 6, 7}, {7, 6, 5, 4, 3, 2, 1, 0}} {
+		store := NewMemoryStore()
+		for _, i := range order {
+			store.SaveEvent(context.Background(), events[i])
+		}
+		results, _ := store.QueryEvents(context.Background(), Filter{Authors: []PubKey{alice}, Kinds: []int{0, 30023}})
+		if len(results) != 3 || results[0].ID != (ID{4}) || results[1].ID != (ID{2}) || results[2].ID != (ID{6}) {
+			t.Fatalf("wrong events stored: %v", results)
+		}
+	}
+}
+
+func TestKindSemantics(t *testing.T) {
+	for _, c := range []struct {
+		kind                                int
+		replaceable, addressable, ephemeral bool
+	}{
+		{KindSetMetadata, true, false, false},
+		{KindTextNote, false, false, false},
+		{KindContactList, true, false, false},
+		{10002, true, false, false},
+		{20001, false, false, true},
+		{30023, false, true, false},
+	} {
+		evt := &Event{Kind: c.kind}
+		if evt.IsReplaceable() != c.replaceable || evt.IsAddressable() != c.addressable || evt.IsEphemeral() != c.ephemeral {
+			t.Errorf("wrong semantics for kind %d", c.kind)
+		}
+	}
+
+	evt := &Event{Kind: 30023, Tags: Tags{{"t", "x"}, {"d", "post"}, {"d", "other"}}}
+	if evt.Identifier() != "post" {
+		t.Errorf("expected the first 'd' tag, got '%s'", evt.Identifier())
+	}
+	if (&Event{Kind: 30023, Tags: Tags{{"d"}}}).Identifier() != "" || (&Event{Kind: 30023}).Identifier() != "" {
+		t.Error("missing or empty 'd' tags should give an empty identifier")
+	}
+}
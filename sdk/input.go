@@ -1,8 +1,6 @@
 package sdk
 
 import (
-	"encoding/hex"
-
 	"github.com/nbd-wtf/go-nostr"
 	"github.com/nbd-wtf/go-nostr/nip05"
 	"github.com/nbd-wtf/go-nostr/nip19"
@@ -11,18 +9,17 @@ import (
 // InputToProfile turns any npub/nprofile/hex/nip05 input into a ProfilePointer (or nil)
 func InputToProfile(input string) *nostr.ProfilePointer {
 	// handle if it is a hex string
-	if len(input) == 64 {
-		if _, err := hex.DecodeString(input); err == nil {
-			return &nostr.ProfilePointer{PublicKey: input}
-		}
+	if pk, err := nostr.PubKeyFromHex(input); err == nil {
+		return &nostr.ProfilePointer{PublicKey: pk}
 	}
 
 	// handle nip19 codes, if that's the case
 	prefix, data, _ := nip19.Decode(input)
 	switch prefix {
 	case "npub":
-		input = data.(string)
-		return &nostr.ProfilePointer{PublicKey: input}
+		if pk, err := nostr.PubKeyFromHex(data.(string)); err == nil {
+			return &nostr.ProfilePointer{PublicKey: pk}
+		}
 	case "nprofile":
 		pp := data.(nostr.ProfilePointer)
 		return &pp
@@ -40,18 +37,17 @@ func InputToProfile(input string) *nostr.ProfilePointer {
 // InputToEventPointer turns any note/nevent/hex input into a EventPointer (or nil)
 func InputToEventPointer(input string) *nostr.EventPointer {
 	// handle if it is a hex string
-	if len(input) == 64 {
-		if _, err := hex.DecodeString(input); err == nil {
-			return &nostr.EventPointer{ID: input}
-		}
+	if id, err := nostr.IDFromHex(input); err == nil {
+		return &nostr.EventPointer{ID: id}
 	}
 
 	// handle nip19 codes, if that's the case
 	prefix, data, _ := nip19.Decode(input)
 	switch prefix {
 	case "note":
-		input = data.(string)
-		return &nostr.EventPointer{ID: input}
+		if id, err := nostr.IDFromHex(data.(string)); err == nil {
+			return &nostr.EventPointer{ID: id}
+		}
 	case "nevent":
 		ep := data.(nostr.EventPointer)
 		return &ep
@@ -0,0 +1,202 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip13"
+	"golang.org/x/exp/slices"
+)
+
+// EventPolicy decides if an event published by a client is accepted. A non-nil error rejects
+// it and its text is sent back in the OK message, so it should start with one of the NIP-01
+// prefixes, like "blocked: ". Errors starting with "auth-required: " also make the relay send
+// a NIP-42 challenge.
+type EventPolicy func(ctx context.Context, evt *nostr.Event) error
+
+// FilterPolicy decides if a filter in a REQ or COUNT is accepted, like EventPolicy. The
+// subscription is closed with the error's text if any of its filters is rejected.
+type FilterPolicy func(ctx context.Context, filter nostr.Filter) error
+
+// AuthedPubKey returns the pubkey the connection of ctx has authenticated as with NIP-42.
+func AuthedPubKey(ctx context.Context) (nostr.PubKey, bool) {
+	c := clientFromContext(ctx)
+	if c == nil {
+		return nostr.ZeroPubKey, false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.authed == nil {
+		return nostr.ZeroPubKey, false
+	}
+	return *c.authed, true
+}
+
+// AllowKinds rejects events of any other kind.
+func AllowKinds(kinds ...int) EventPolicy {
+	return func(ctx context.Context, evt *nostr.Event) error {
+		if !slices.Contains(kinds, evt.Kind) {
+			return fmt.Errorf("blocked: kind %d is not accepted", evt.Kind)
+		}
+		return nil
+	}
+}
+
+// RejectKinds rejects events of the given kinds.
+func RejectKinds(kinds ...int) EventPolicy {
+	return func(ctx context.Context, evt *nostr.Event) error {
+		if slices.Contains(kinds, evt.Kind) {
+			return fmt.Errorf("blocked: kind %d is not accepted", evt.Kind)
+		}
+		return nil
+	}
+}
+
+// AllowPubKeys rejects events from any other author.
+func AllowPubKeys(pubkeys ...nostr.PubKey) EventPolicy {
+	return func(ctx context.Context, evt *nostr.Event) error {
+		if !slices.Contains(pubkeys, evt.PubKey) {
+			return fmt.Errorf("blocked: %s is not allowed to write here", evt.PubKey)
+		}
+		return nil
+	}
+}
+
+// RejectPubKeys rejects events from the given authors.
+func RejectPubKeys(pubkeys ...nostr.PubKey) EventPolicy {
+	return func(ctx context.Context, evt *nostr.Event) error {
+		if slices.Contains(pubkeys, evt.PubKey) {
+			return fmt.Errorf("blocked: %s is not allowed to write here", evt.PubKey)
+		}
+		return nil
+	}
+}
+
+// RequirePow rejects events whose id has less than difficulty leading zero bits (NIP-13).
+func RequirePow(difficulty int) EventPolicy {
+	return func(ctx context.Context, evt *nostr.Event) error {
+		if got := nip13.Difficulty(evt.ID.Hex()); got < difficulty {
+			return fmt.Errorf("pow: difficulty %d is less than %d", got, difficulty)
+		}
+		return nil
+	}
+}
+
+// MaxEventSize rejects events longer than size bytes once encoded.
+func MaxEventSize(size int) EventPolicy {
+	return func(ctx context.Context, evt *nostr.Event) error {
+		j, _ := json.Marshal(evt)
+		if len(j) > size {
+			return fmt.Errorf("invalid: event is %d bytes, the maximum is %d", len(j), size)
+		}
+		return nil
+	}
+}
+
+// RequireCreatedAtWithin rejects events whose created_at is outside of window.
+func RequireCreatedAtWithin(window nostr.CreatedAtWindow) EventPolicy {
+	return func(ctx context.Context, evt *nostr.Event) error {
+		if window.Check(evt, nostr.Now()) != nil {
+			return fmt.Errorf("invalid: created_at is too far off from the current time")
+		}
+		return nil
+	}
+}
+
+// RequireAuth rejects events from connections that haven't authenticated with NIP-42.
+func RequireAuth() EventPolicy {
+	return func(ctx context.Context, evt *nostr.Event) error {
+		if _, ok := AuthedPubKey(ctx); !ok {
+			return fmt.Errorf("auth-required: authenticate to publish")
+		}
+		return nil
+	}
+}
+
+// RequireAuthToRead rejects filters from connections that haven't authenticated with NIP-42.
+func RequireAuthToRead() FilterPolicy {
+	return func(ctx context.Context, filter nostr.Filter) error {
+		if _, ok := AuthedPubKey(ctx); !ok {
+			return fmt.Errorf("auth-required: authenticate to read")
+		}
+		return nil
+	}
+}
+
+// RejectBroadFilters rejects filters that would go through too many events: the ones that
+// don't ask for specific ids, authors or tags and either have no kinds or no limit.
+func RejectBroadFilters() FilterPolicy {
+	return func(ctx context.Context, filter nostr.Filter) error {
+		if len(filter.IDs) > 0 || len(filter.Authors) > 0 || len(filter.Tags) > 0 {
+			return nil
+		}
+		if len(filter.Kinds) == 0 || filter.Limit <= 0 {
+			return fmt.Errorf("blocked: filter is too broad, use authors, ids, tags or kinds with a limit")
+		}
+		return nil
+	}
+}
+
+// EventRateLimit rejects events from a connection that publishes more than perSecond events
+// on average, allowing bursts of up to burst events.
+func EventRateLimit(perSecond float64, burst int) EventPolicy {
+	limiter := rateLimiter{key: new(int), perSecond: perSecond, burst: burst}
+	return func(ctx context.Context, evt *nostr.Event) error {
+		if !limiter.allow(ctx) {
+			return fmt.Errorf("rate-limited: slow down")
+		}
+		return nil
+	}
+}
+
+// FilterRateLimit is like EventRateLimit, counting the filters of REQs and COUNTs.
+func FilterRateLimit(perSecond float64, burst int) FilterPolicy {
+	limiter := rateLimiter{key: new(int), perSecond: perSecond, burst: burst}
+	return func(ctx context.Context, filter nostr.Filter) error {
+		if !limiter.allow(ctx) {
+			return fmt.Errorf("rate-limited: slow down")
+		}
+		return nil
+	}
+}
+
+// rateLimiter is a token bucket for each connection, kept in its state under key.
+type rateLimiter struct {
+	key       *int
+	perSecond float64
+	burst     int
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func (l rateLimiter) allow(ctx context.Context) bool {
+	c := clientFromContext(ctx)
+	if c == nil {
+		return true
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	b, ok := c.state[l.key].(*bucket)
+	if !ok {
+		b = &bucket{tokens: float64(l.burst), last: now}
+		c.state[l.key] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * l.perSecond
+	if b.tokens > float64(l.burst) {
+		b.tokens = float64(l.burst)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
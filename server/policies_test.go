@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestPolicies(t *testing.T) {
+	ctx := context.Background()
+	evt := &nostr.Event{ID: nostr.ID{0, 0x0f}, PubKey: nostr.PubKey{1}, Kind: 1}
+
+	if err := AllowKinds(0, 3)(ctx, evt); err == nil || !strings.HasPrefix(err.Error(), "blocked:") {
+		t.Errorf("kind 1 allowed: %v", err)
+	}
+	if err := RejectPubKeys(nostr.PubKey{2})(ctx, evt); err != nil {
+		t.Errorf("pubkey rejected: %v", err)
+	}
+	if err := RequirePow(12)(ctx, evt); err != nil {
+		t.Errorf("difficulty 12 rejected: %v", err)
+	}
+	if err := RequirePow(13)(ctx, evt); err == nil || !strings.HasPrefix(err.Error(), "pow:") {
+		t.Errorf("difficulty 13 accepted: %v", err)
+	}
+
+	window := RequireCreatedAtWithin(nostr.CreatedAtWindow{Past: time.Hour, Future: time.Minute})
+	if err := window(ctx, &nostr.Event{CreatedAt: time.Now().Add(time.Hour)}); err == nil || !strings.HasPrefix(err.Error(), "invalid:") {
+		t.Errorf("event from the future accepted: %v", err)
+	}
+
+	broad := RejectBroadFilters()
+	if broad(ctx, nostr.Filter{Kinds: []int{1}}) == nil {
+		t.Error("filter with kinds and no limit accepted")
+	}
+	if err := broad(ctx, nostr.Filter{Kinds: []int{1}, Limit: 20}); err != nil {
+		t.Errorf("filter with kinds and limit rejected: %v", err)
+	}
+	if err := broad(ctx, nostr.Filter{Authors: []nostr.PubKey{{1}}}); err != nil {
+		t.Errorf("filter with authors rejected: %v", err)
+	}
+
+	// each connection has its own bucket
+	limit := EventRateLimit(1, 2)
+	first := context.WithValue(ctx, clientKey{}, &client{state: make(map[any]any)})
+	second := context.WithValue(ctx, clientKey{}, &client{state: make(map[any]any)})
+	for i := 0; i < 2; i++ {
+		if err := limit(first, evt); err != nil {
+			t.Fatalf("event %d rate limited: %v", i, err)
+		}
+	}
+	if err := limit(first, evt); err == nil || !strings.HasPrefix(err.Error(), "rate-limited:") {
+		t.Errorf("third event not rate limited: %v", err)
+	}
+	if err := limit(second, evt); err != nil {
+		t.Errorf("other connection rate limited: %v", err)
+	}
+}
+
+func TestRequireAuth(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	relay := New(nil)
+	relay.EventPolicies = []EventPolicy{RequireAuth()}
+	ts := httptest.NewServer(relay)
+	defer ts.Close()
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http")
+	sk := nostr.GeneratePrivateKey()
+	evt := signedEvent(t, sk, nostr.KindTextNote, "hello")
+
+	plain, err := nostr.RelayConnect(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plain.Close()
+	if _, err := plain.Publish(ctx, evt); err == nil || !strings.Contains(err.Error(), "auth-required") {
+		t.Fatalf("publishing before auth didn't fail: %v", err)
+	}
+
+	// the rejection makes the relay send a challenge, which the auth handler answers before
+	// the event is sent again
+	conn, err := nostr.RelayConnect(ctx, url,
+		nostr.WithAuthHandler(func(ctx context.Context, evt *nostr.Event) error {
+			pk, _ := nostr.GetPublicKey(sk)
+			evt.PubKey = nostr.MustPubKeyFromHex(pk)
+			return evt.Sign(sk)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if status, err := conn.Publish(ctx, evt); status != nostr.PublishStatusSucceeded {
+		t.Fatalf("publishing with auth failed: %s, %v", status, err)
+	}
+}
@@ -0,0 +1,453 @@
+// Package server is a small relay that can be mounted on any http.ServeMux, e.g. as a local
+// cache relay for an app or to have real round-trips in tests:
+//
+//	relay := server.New(nil) // keeps events in a nostr.MemoryStore
+//	http.ListenAndServe(":7447", relay)
+//
+// Events are kept in a nostr.Store and sent to matching subscriptions as they arrive.
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip11"
+	"github.com/nbd-wtf/go-nostr/nip42"
+)
+
+// Relay serves websocket connections and the NIP-11 document from Info.
+//
+// Events and filters go through EventPolicies and FilterPolicies, in order, and are rejected
+// by the first one that fails, e.g. RequirePow(20) or RequireAuth().
+type Relay struct {
+	Store nostr.Store
+	Info  *nip11.RelayInformationDocument
+
+	EventPolicies  []EventPolicy
+	FilterPolicies []FilterPolicy
+
+	// ServiceURL is the URL clients use to connect, which NIP-42 auth events must name.
+	// If empty it is taken from each request.
+	ServiceURL string
+
+	// Strict makes the relay reject messages and events that don't parse with
+	// nostr.ParseMessageStrict and Event.UnmarshalStrict, instead of reading what it can from them.
+	Strict bool
+
+	upgrader websocket.Upgrader
+
+	mutex   sync.Mutex
+	clients map[*client]struct{}
+}
+
+// New creates a Relay keeping its events in store, or in a new MemoryStore if store is nil.
+func New(store nostr.Store) *Relay {
+	if store == nil {
+		store = nostr.NewMemoryStore()
+	}
+	return &Relay{
+		Store: store,
+		Info: &nip11.RelayInformationDocument{
+			Software:      "https://github.com/nbd-wtf/go-nostr",
+			SupportedNIPs: []int{1, 11, 40, 42, 45},
+		},
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+		clients: make(map[*client]struct{}),
+	}
+}
+
+func (rl *Relay) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	nip11.NewHandler(rl.Info, http.HandlerFunc(rl.serveWebsocket)).ServeHTTP(w, r)
+}
+
+// AddEvent saves evt, unless it is ephemeral, and sends it to the subscriptions it matches,
+// as if a client had published it, but without checking its id and signature.
+func (rl *Relay) AddEvent(ctx context.Context, evt *nostr.Event) error {
+	if !nostr.IsEphemeralKind(evt.Kind) {
+		if err := rl.Store.SaveEvent(ctx, evt); err != nil {
+			return err
+		}
+	}
+	rl.broadcast(evt)
+	return nil
+}
+
+func (rl *Relay) broadcast(evt *nostr.Event) {
+	rl.mutex.Lock()
+	clients := make([]*client, 0, len(rl.clients))
+	for c := range rl.clients {
+		clients = append(clients, c)
+	}
+	rl.mutex.Unlock()
+
+	for _, c := range clients {
+		for _, id := range c.matching(evt) {
+			c.tryWrite([]any{"EVENT", id, evt})
+		}
+	}
+}
+
+const (
+	// messages waiting to be sent to each client, a client that lets more pile up is dropped
+	// instead of making everybody publishing wait for it
+	sendQueueSize = 256
+	writeTimeout  = 10 * time.Second
+)
+
+// client is one websocket connection and its open subscriptions.
+type client struct {
+	socket     *websocket.Conn
+	send       chan []byte
+	done       chan struct{}
+	closeOnce  sync.Once
+	serviceURL string
+
+	mutex         sync.Mutex
+	subscriptions map[string]*nostr.Matcher
+	challenge     string
+	challenged    bool
+	authed        *nostr.PubKey
+	state         map[any]any // kept by policies for each connection
+}
+
+type clientKey struct{}
+
+func clientFromContext(ctx context.Context) *client {
+	c, _ := ctx.Value(clientKey{}).(*client)
+	return c
+}
+
+// challengeIfNeeded sends the NIP-42 challenge the first time a policy asks for auth.
+func (c *client) challengeIfNeeded(err error) {
+	if nostr.ParseNotice(err.Error()).Prefix != nostr.NoticeAuthRequired {
+		return
+	}
+	c.mutex.Lock()
+	send := !c.challenged
+	c.challenged = true
+	c.mutex.Unlock()
+	if send {
+		c.write([]any{"AUTH", c.challenge})
+	}
+}
+
+// writeLoop sends the queued messages, it is the only one writing to the socket.
+func (c *client) writeLoop() {
+	for {
+		select {
+		case message := <-c.send:
+			c.socket.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := c.socket.WriteMessage(websocket.TextMessage, message); err != nil {
+				c.close()
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// write queues v to be sent, waiting if the queue is full. It is used for the answers to what
+// the client sends, so it only slows down the client's own reading.
+func (c *client) write(v any) {
+	message, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	select {
+	case c.send <- message:
+	case <-c.done:
+	}
+}
+
+// tryWrite queues v to be sent, and drops the client if the queue is full.
+func (c *client) tryWrite(v any) {
+	message, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	select {
+	case c.send <- message:
+	case <-c.done:
+	default:
+		c.close()
+	}
+}
+
+func (c *client) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.socket.Close()
+	})
+}
+
+func (c *client) matching(evt *nostr.Event) []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var ids []string
+	for id, matcher := range c.subscriptions {
+		if matcher.Match(evt) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (rl *Relay) serveWebsocket(w http.ResponseWriter, r *http.Request) {
+	socket, err := rl.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	if rl.Info != nil && rl.Info.Limitation != nil && rl.Info.Limitation.MaxMessageLength > 0 {
+		socket.SetReadLimit(int64(rl.Info.Limitation.MaxMessageLength))
+	}
+
+	challenge := make([]byte, 16)
+	rand.Read(challenge)
+	c := &client{
+		socket:        socket,
+		send:          make(chan []byte, sendQueueSize),
+		done:          make(chan struct{}),
+		serviceURL:    rl.serviceURL(r),
+		subscriptions: make(map[string]*nostr.Matcher),
+		challenge:     hex.EncodeToString(challenge),
+		state:         make(map[any]any),
+	}
+	rl.mutex.Lock()
+	rl.clients[c] = struct{}{}
+	rl.mutex.Unlock()
+	go c.writeLoop()
+
+	defer func() {
+		rl.mutex.Lock()
+		delete(rl.clients, c)
+		rl.mutex.Unlock()
+		c.close()
+	}()
+
+	ctx := context.WithValue(r.Context(), clientKey{}, c)
+	for {
+		typ, message, err := socket.ReadMessage()
+		if err != nil {
+			return
+		}
+		if typ != websocket.TextMessage {
+			continue
+		}
+
+		if rl.Strict {
+			if _, _, err := nostr.ParseMessageStrict(message); err != nil {
+				c.write([]any{"NOTICE", "error: invalid message: " + err.Error()})
+				continue
+			}
+		}
+
+		var jsonMessage []json.RawMessage
+		if err := json.Unmarshal(message, &jsonMessage); err != nil || len(jsonMessage) < 2 {
+			c.write([]any{"NOTICE", "error: invalid message"})
+			continue
+		}
+
+		var command string
+		json.Unmarshal(jsonMessage[0], &command)
+
+		switch command {
+		case "EVENT":
+			rl.handleEvent(ctx, c, jsonMessage[1])
+		case "AUTH":
+			rl.handleAuth(c, jsonMessage[1])
+		case "REQ", "COUNT":
+			var id string
+			json.Unmarshal(jsonMessage[1], &id)
+			filters := make(nostr.Filters, len(jsonMessage)-2)
+			var err error
+			for i, raw := range jsonMessage[2:] {
+				if err = json.Unmarshal(raw, &filters[i]); err != nil {
+					break
+				}
+			}
+			if err != nil || id == "" {
+				c.write([]any{"CLOSED", id, "error: invalid filter"})
+				continue
+			}
+			if command == "REQ" {
+				rl.handleReq(ctx, c, id, filters)
+			} else {
+				rl.handleCount(ctx, c, id, filters)
+			}
+		case "CLOSE":
+			var id string
+			json.Unmarshal(jsonMessage[1], &id)
+			c.mutex.Lock()
+			delete(c.subscriptions, id)
+			c.mutex.Unlock()
+		default:
+			c.write([]any{"NOTICE", "error: unknown command " + command})
+		}
+	}
+}
+
+func (rl *Relay) handleEvent(ctx context.Context, c *client, raw json.RawMessage) {
+	evt, err := rl.parseEvent(raw)
+	if err != nil {
+		c.write([]any{"NOTICE", "error: invalid event: " + err.Error()})
+		return
+	}
+
+	reply := func(ok bool, message string) {
+		c.write([]any{"OK", evt.ID, ok, message})
+	}
+	if evt.GetID() != evt.ID {
+		reply(false, "invalid: event id is computed incorrectly")
+		return
+	}
+	if ok, _ := evt.CheckSignature(); !ok {
+		reply(false, "invalid: signature is invalid")
+		return
+	}
+	if evt.IsExpired(nostr.Now()) {
+		reply(false, "invalid: event is expired")
+		return
+	}
+
+	for _, policy := range rl.EventPolicies {
+		if err := policy(ctx, &evt); err != nil {
+			reply(false, err.Error())
+			c.challengeIfNeeded(err)
+			return
+		}
+	}
+
+	if err := rl.AddEvent(ctx, &evt); err != nil {
+		if errors.Is(err, nostr.ErrExpired) {
+			reply(false, "invalid: event is expired")
+		} else {
+			reply(false, "error: "+err.Error())
+		}
+		return
+	}
+	reply(true, "")
+}
+
+func (rl *Relay) handleAuth(c *client, raw json.RawMessage) {
+	evt, err := rl.parseEvent(raw)
+	if err != nil {
+		c.write([]any{"NOTICE", "error: invalid auth event"})
+		return
+	}
+
+	pubkey, ok := nip42.ValidateAuthEvent(&evt, c.challenge, c.serviceURL)
+	if !ok {
+		c.write([]any{"OK", evt.ID, false, "invalid: auth event doesn't match the challenge or the relay"})
+		return
+	}
+	c.mutex.Lock()
+	c.authed = &pubkey
+	c.mutex.Unlock()
+	c.write([]any{"OK", evt.ID, true, ""})
+}
+
+// parseEvent decodes an event sent by a client, strictly if the relay is Strict.
+func (rl *Relay) parseEvent(raw json.RawMessage) (nostr.Event, error) {
+	var evt nostr.Event
+	var err error
+	if rl.Strict {
+		err = evt.UnmarshalStrict(raw)
+	} else {
+		err = json.Unmarshal(raw, &evt)
+	}
+	return evt, err
+}
+
+// checkFilters runs the FilterPolicies on each filter and sends CLOSED if one is rejected.
+func (rl *Relay) checkFilters(ctx context.Context, c *client, id string, filters nostr.Filters) bool {
+	for _, filter := range filters {
+		for _, policy := range rl.FilterPolicies {
+			if err := policy(ctx, filter); err != nil {
+				c.write([]any{"CLOSED", id, err.Error()})
+				c.challengeIfNeeded(err)
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (rl *Relay) handleReq(ctx context.Context, c *client, id string, filters nostr.Filters) {
+	if !rl.checkFilters(ctx, c, id, filters) {
+		return
+	}
+
+	// subscribe first so nothing saved while we query is missed, at worst it comes twice
+	c.mutex.Lock()
+	c.subscriptions[id] = filters.Compile()
+	c.mutex.Unlock()
+
+	events, err := rl.query(ctx, filters)
+	if err != nil {
+		c.mutex.Lock()
+		delete(c.subscriptions, id)
+		c.mutex.Unlock()
+		c.write([]any{"CLOSED", id, "error: " + err.Error()})
+		return
+	}
+	for _, evt := range events {
+		c.write([]any{"EVENT", id, evt})
+	}
+	c.write([]any{"EOSE", id})
+}
+
+func (rl *Relay) handleCount(ctx context.Context, c *client, id string, filters nostr.Filters) {
+	if !rl.checkFilters(ctx, c, id, filters) {
+		return
+	}
+
+	events, err := rl.query(ctx, filters)
+	if err != nil {
+		c.write([]any{"CLOSED", id, "error: " + err.Error()})
+		return
+	}
+	c.write([]any{"COUNT", id, map[string]int{"count": len(events)}})
+}
+
+func (rl *Relay) serviceURL(r *http.Request) string {
+	if rl.ServiceURL != "" {
+		return rl.ServiceURL
+	}
+	scheme := "ws"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "wss"
+	}
+	return scheme + "://" + r.Host + r.URL.Path
+}
+
+// query returns the stored events matching any of filters, without repeating them.
+func (rl *Relay) query(ctx context.Context, filters nostr.Filters) ([]*nostr.Event, error) {
+	seen := make(map[nostr.ID]struct{})
+	var events []*nostr.Event
+	for _, filter := range filters {
+		results, err := rl.Store.QueryEvents(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, evt := range results {
+			if _, ok := seen[evt.ID]; ok {
+				continue
+			}
+			seen[evt.ID] = struct{}{}
+			events = append(events, evt)
+		}
+	}
+	return events, nil
+}
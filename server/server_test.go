@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip11"
+)
+
+func signedEvent(t *testing.T, sk string, kind int, content string) nostr.Event {
+	pk, _ := nostr.GetPublicKey(sk)
+	evt := nostr.Event{
+		PubKey:    nostr.MustPubKeyFromHex(pk),
+		CreatedAt: time.Now(),
+		Kind:      kind,
+		Tags:      nostr.Tags{},
+		Content:   content,
+	}
+	if err := evt.Sign(sk); err != nil {
+		t.Fatal(err)
+	}
+	return evt
+}
+
+func TestRoundTrip(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	relay := New(nil)
+	relay.Info.Name = "local"
+	ts := httptest.NewServer(relay)
+	defer ts.Close()
+	url := "ws" + strings.TrimPrefix(ts.URL, "http")
+
+	info, err := nip11.Fetch(ctx, ts.URL)
+	if err != nil || info.Name != "local" {
+		t.Fatalf("wrong NIP-11 document: %v %v", info, err)
+	}
+
+	conn, err := nostr.RelayConnect(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	sk := nostr.GeneratePrivateKey()
+	stored := signedEvent(t, sk, nostr.KindTextNote, "stored")
+	if status, err := conn.Publish(ctx, stored); err != nil || status != nostr.PublishStatusSucceeded {
+		t.Fatalf("publish failed: %v %v", status, err)
+	}
+
+	bad := signedEvent(t, sk, nostr.KindTextNote, "tampered")
+	bad.Content = "something else"
+	if _, err := conn.Publish(ctx, bad); err == nil {
+		t.Fatal("event with a wrong id was accepted")
+	}
+
+	sub, err := conn.Sub(ctx, nostr.Filters{{Kinds: []int{nostr.KindTextNote}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case evt := <-sub.Events:
+		if evt.ID != stored.ID {
+			t.Fatalf("got %s instead of the stored event", evt.ID)
+		}
+	case <-ctx.Done():
+		t.Fatal("stored event not received")
+	}
+	select {
+	case <-sub.EndOfStoredEvents:
+	case <-ctx.Done():
+		t.Fatal("no EOSE")
+	}
+
+	// events added later go to the open subscription
+	live := signedEvent(t, sk, nostr.KindTextNote, "live")
+	relay.AddEvent(ctx, &live)
+	select {
+	case evt := <-sub.Events:
+		if evt.ID != live.ID {
+			t.Fatalf("got %s instead of the live event", evt.ID)
+		}
+	case <-ctx.Done():
+		t.Fatal("live event not received")
+	}
+
+	ephemeral := signedEvent(t, sk, 20001, "ephemeral")
+	conn.Publish(ctx, ephemeral)
+	if events, _ := relay.Store.QueryEvents(ctx, nostr.Filter{Kinds: []int{20001}}); len(events) != 0 {
+		t.Fatal("ephemeral event was stored")
+	}
+
+	if count, err := conn.Count(ctx, nostr.Filters{{Kinds: []int{nostr.KindTextNote}}}); err != nil || count != 2 {
+		t.Fatalf("wrong count %d: %v", count, err)
+	}
+}
+
+func TestSlowClientIsDropped(t *testing.T) {
+	relay := New(nil)
+	ts := httptest.NewServer(relay)
+	defer ts.Close()
+
+	// a client that subscribes and then never reads
+	socket, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer socket.Close()
+	socket.WriteJSON([]any{"REQ", "slow", nostr.Filter{Kinds: []int{20001}}})
+	for len(clientSubscriptions(relay)) == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		content := strings.Repeat("a", 32*1024)
+		for i := 0; i < 2000; i++ {
+			relay.AddEvent(context.Background(), &nostr.Event{Kind: 20001, Content: content})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("publishing was blocked by a client that doesn't read")
+	}
+
+	for deadline := time.Now().Add(5 * time.Second); len(clientSubscriptions(relay)) > 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("the slow client wasn't dropped")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func clientSubscriptions(relay *Relay) []string {
+	relay.mutex.Lock()
+	defer relay.mutex.Unlock()
+	var ids []string
+	for c := range relay.clients {
+		c.mutex.Lock()
+		for id := range c.subscriptions {
+			ids = append(ids, id)
+		}
+		c.mutex.Unlock()
+	}
+	return ids
+}
@@ -0,0 +1,86 @@
+package nostr
+
+import (
+	"io"
+	"sync"
+)
+
+// SignatureBackend identifies an implementation of BIP-340 signature verification.
+type SignatureBackend int
+
+const (
+	// BackendBtcec is the pure-Go implementation from btcec, always available.
+	BackendBtcec SignatureBackend = iota
+
+	// BackendLibsecp256k1 uses bitcoin-core's libsecp256k1 through CGO, which is a lot faster.
+	// It is only available when building with `-tags libsecp256k1` and CGO enabled.
+	BackendLibsecp256k1
+)
+
+// VerificationBackend is the implementation used by [Event.CheckSignature].
+// It defaults to BackendLibsecp256k1 when that is available and BackendBtcec otherwise.
+// If it is set to BackendLibsecp256k1 in a build without it, btcec will be used.
+var VerificationBackend = defaultVerificationBackend
+
+var (
+	signingRandomnessMutex sync.Mutex
+	signingRandomness      io.Reader
+)
+
+// SetSigningRandomness makes [Event.Sign] read the 32 bytes of auxiliary randomness BIP-340
+// mixes into the nonce of each signature from r. When it is nil, as by default, nonces are
+// derived from the key and the event with RFC 6979, so the same event always gets the same
+// signature. It is only meant for tests that need signatures to differ in a reproducible way,
+// with a seeded reader; reads are serialized, so it can be set while others are signing.
+func SetSigningRandomness(r io.Reader) {
+	signingRandomnessMutex.Lock()
+	defer signingRandomnessMutex.Unlock()
+	signingRandomness = r
+}
+
+// readSigningRandomness fills aux from the reader given to SetSigningRandomness, it returns
+// false if there is none.
+func readSigningRandomness(aux *[32]byte) (bool, error) {
+	signingRandomnessMutex.Lock()
+	defer signingRandomnessMutex.Unlock()
+	if signingRandomness == nil {
+		return false, nil
+	}
+	_, err := io.ReadFull(signingRandomness, aux[:])
+	return true, err
+}
+
+// Libsecp256k1Available tells if this build includes the libsecp256k1 backend.
+func Libsecp256k1Available() bool { return libsecp256k1Available }
+
+func (b SignatureBackend) String() string {
+	switch b {
+	case BackendBtcec:
+		return "btcec"
+	case BackendLibsecp256k1:
+		return "libsecp256k1"
+	}
+	return "unknown"
+}
+
+// SignatureChecker decides if an event received from a relay is valid, see Relay.SignatureChecker.
+type SignatureChecker func(evt *Event) bool
+
+// DefaultSignatureChecker is what relays use when no SignatureChecker is set.
+func DefaultSignatureChecker(evt *Event) bool {
+	ok, _ := evt.CheckSignature()
+	return ok
+}
+
+// AllOf returns a SignatureChecker that only accepts events that all checkers accept, in order,
+// which can be used to add policies on top of DefaultSignatureChecker.
+func AllOf(checkers ...SignatureChecker) SignatureChecker {
+	return func(evt *Event) bool {
+		for _, check := range checkers {
+			if !check(evt) {
+				return false
+			}
+		}
+		return true
+	}
+}
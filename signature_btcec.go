@@ -0,0 +1,12 @@
+//go:build !libsecp256k1 || !cgo
+
+package nostr
+
+const (
+	libsecp256k1Available      = false
+	defaultVerificationBackend = BackendBtcec
+)
+
+func verifyLibsecp256k1(pk PubKey, msg [32]byte, sig [64]byte) (bool, error) {
+	panic("go-nostr was built without libsecp256k1 support")
+}
@@ -0,0 +1,70 @@
+package nostr
+
+import (
+	"encoding/hex"
+	"sync/atomic"
+)
+
+// SignatureCache remembers which events had valid signatures, so an event received from many
+// relays is only verified once. It is keyed by id and signature, and the id of an event is
+// always recomputed before trusting a cached result, so a relay can't get a forged event
+// accepted by reusing the id and signature of a real one.
+//
+// Relays use DefaultSignatureCache unless given another with WithSignatureCache or a
+// SignatureChecker, which Check can be the last step of.
+type SignatureCache struct {
+	hits    uint64 // first, for 64-bit alignment of atomic operations
+	misses  uint64
+	entries *lru[signatureKey, bool]
+}
+
+type signatureKey struct {
+	id  ID
+	sig [64]byte
+}
+
+// DefaultSignatureCache is shared by all the relays that weren't given a cache, so relays of
+// the same pool, or of different pools, don't verify the same events again.
+var DefaultSignatureCache = NewSignatureCache(16384)
+
+// NewSignatureCache creates a cache for the results of the given number of events, the least
+// recently seen are forgotten first. A capacity of 0 or less caches nothing.
+func NewSignatureCache(capacity int) *SignatureCache {
+	c := &SignatureCache{}
+	if capacity > 0 {
+		c.entries = newLRU[signatureKey, bool](capacity)
+	}
+	return c
+}
+
+// Check is a SignatureChecker that verifies evt like DefaultSignatureChecker, unless the
+// result for the same event is cached.
+func (c *SignatureCache) Check(evt *Event) bool {
+	if c.entries == nil || len(evt.Sig) != 128 {
+		return DefaultSignatureChecker(evt)
+	}
+	if evt.GetID() != evt.ID {
+		// the signature may still be valid for the content, but the id can't be a key for it
+		return DefaultSignatureChecker(evt)
+	}
+
+	key := signatureKey{id: evt.ID}
+	if _, err := hex.Decode(key.sig[:], []byte(evt.Sig)); err != nil {
+		return false
+	}
+	if valid, ok := c.entries.Get(key); ok {
+		atomic.AddUint64(&c.hits, 1)
+		return valid
+	}
+	atomic.AddUint64(&c.misses, 1)
+
+	valid := DefaultSignatureChecker(evt)
+	c.entries.Add(key, valid)
+	return valid
+}
+
+// Stats returns how many checks were answered from the cache and how many had to verify the
+// signature.
+func (c *SignatureCache) Stats() (hits, misses uint64) {
+	return atomic.LoadUint64(&c.hits), atomic.LoadUint64(&c.misses)
+}
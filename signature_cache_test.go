@@ -0,0 +1,80 @@
+package nostr
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSignatureCache(t *testing.T) {
+	sk, pk := makeKeyPair(t)
+	evt := Event{Kind: 1, PubKey: pk, CreatedAt: time.Unix(1700000000, 0), Content: "hello", Tags: Tags{}}
+	evt.Sign(sk)
+
+	cache := NewSignatureCache(10)
+	for i := 0; i < 3; i++ {
+		copied := evt
+		if !cache.Check(&copied) {
+			t.Fatalf("valid event rejected")
+		}
+	}
+	if hits, misses := cache.Stats(); hits != 2 || misses != 1 {
+		t.Fatalf("unexpected stats: %d hits, %d misses", hits, misses)
+	}
+
+	// the id and signature of a cached event don't make a forged one valid
+	forged := evt
+	forged.Content = "bye"
+	if cache.Check(&forged) {
+		t.Fatalf("forged event accepted")
+	}
+	invalid := evt
+	invalid.Sig = evt.Sig[:127] + "0"
+	if invalid.Sig == evt.Sig {
+		invalid.Sig = evt.Sig[:127] + "1"
+	}
+	if cache.Check(&invalid) || cache.Check(&invalid) {
+		t.Fatalf("invalid signature accepted")
+	}
+
+	if disabled := NewSignatureCache(0); !disabled.Check(&evt) {
+		t.Fatalf("valid event rejected without caching")
+	}
+}
+
+func TestSignatureCacheSharedByPool(t *testing.T) {
+	fr := &fakeRelay{}
+	sk, pk := makeKeyPair(t)
+	for i := 0; i < 5; i++ {
+		evt := Event{Kind: 1, PubKey: pk, CreatedAt: time.Unix(int64(1700000000+i), 0), Content: "everywhere"}
+		evt.Sign(sk)
+		fr.events = append(fr.events, evt)
+	}
+	var urls []string
+	for i := 0; i < 3; i++ {
+		ws := newWebsocketServer(fr.handle)
+		defer ws.Close()
+		urls = append(urls, ws.URL)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cache := NewSignatureCache(100)
+	pool := NewSimplePool(ctx)
+	pool.RelayOptions = []RelayOption{WithSignatureCache(cache)}
+	defer pool.Close()
+
+	// one relay at a time, so they don't verify the same events at once
+	for _, url := range urls {
+		count := 0
+		for range pool.SubManyEose(ctx, []string{url}, Filters{{Kinds: []int{1}}}) {
+			count++
+		}
+		if count != 5 {
+			t.Fatalf("got %d events from %s", count, url)
+		}
+	}
+	if hits, misses := cache.Stats(); misses != 5 || hits != 10 {
+		t.Fatalf("unexpected stats: %d hits, %d misses", hits, misses)
+	}
+}
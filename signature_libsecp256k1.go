@@ -0,0 +1,43 @@
+//go:build libsecp256k1 && cgo
+
+package nostr
+
+/*
+#cgo LDFLAGS: -lsecp256k1
+#include <secp256k1.h>
+#include <secp256k1_extrakeys.h>
+#include <secp256k1_schnorrsig.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"unsafe"
+)
+
+const (
+	libsecp256k1Available      = true
+	defaultVerificationBackend = BackendLibsecp256k1
+)
+
+// a verification context can be shared by all goroutines as it is never modified
+var globalSecp256k1Context = C.secp256k1_context_create(C.SECP256K1_CONTEXT_VERIFY)
+
+func verifyLibsecp256k1(pk PubKey, msg [32]byte, sig [64]byte) (bool, error) {
+	var xonly C.secp256k1_xonly_pubkey
+	if C.secp256k1_xonly_pubkey_parse(
+		globalSecp256k1Context,
+		&xonly,
+		(*C.uchar)(unsafe.Pointer(&pk[0])),
+	) != 1 {
+		return false, errors.New("invalid pubkey")
+	}
+
+	return C.secp256k1_schnorrsig_verify(
+		globalSecp256k1Context,
+		(*C.uchar)(unsafe.Pointer(&sig[0])),
+		(*C.uchar)(unsafe.Pointer(&msg[0])),
+		32,
+		&xonly,
+	) == 1, nil
+}
@@ -0,0 +1,55 @@
+package nostr
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestSignatureCheckers(t *testing.T) {
+	sk, pk := makeKeyPair(t)
+	signed := Event{Kind: 1, CreatedAt: time.Now(), Content: "signed", PubKey: pk}
+	signed.Sign(sk)
+	unsigned := Event{Kind: 1, CreatedAt: time.Now(), Content: "trust me", PubKey: pk}
+
+	if !DefaultSignatureChecker(&signed) || DefaultSignatureChecker(&unsigned) {
+		t.Fatal("default checker is wrong")
+	}
+
+	noLongNotes := AllOf(DefaultSignatureChecker, func(evt *Event) bool { return len(evt.Content) < 5 })
+	if noLongNotes(&signed) {
+		t.Fatal("AllOf accepted an event rejected by a policy")
+	}
+}
+
+func TestSigningRandomness(t *testing.T) {
+	sk := GeneratePrivateKeyFrom(rand.New(rand.NewSource(1)))
+	if sk != GeneratePrivateKeyFrom(rand.New(rand.NewSource(1))) {
+		t.Fatal("keys from the same seed are different")
+	}
+	pk, _ := GetPublicKey(sk)
+
+	sign := func() string {
+		evt := Event{Kind: 1, CreatedAt: time.Unix(1700000000, 0), Content: "hello", PubKey: MustPubKeyFromHex(pk)}
+		if err := evt.Sign(sk); err != nil {
+			t.Fatal(err)
+		}
+		if ok, _ := evt.CheckSignature(); !ok {
+			t.Fatal("invalid signature")
+		}
+		return evt.Sig
+	}
+
+	deterministic := sign()
+	if sign() != deterministic {
+		t.Fatal("signatures without SetSigningRandomness are not deterministic")
+	}
+
+	defer SetSigningRandomness(nil)
+	SetSigningRandomness(rand.New(rand.NewSource(1)))
+	first := sign()
+	SetSigningRandomness(rand.New(rand.NewSource(1)))
+	if first == deterministic || sign() != first {
+		t.Fatal("signatures don't follow SetSigningRandomness")
+	}
+}
@@ -0,0 +1,26 @@
+package nostr
+
+import "context"
+
+// Signer is anything that can act on behalf of a Nostr identity, like a NIP-07 browser extension.
+// The keys may be local (see the keyer package), on a remote bunker (see nip46) or anywhere else,
+// so code that only depends on this interface works with all of them.
+type Signer interface {
+	// GetPublicKey returns the public key of the identity this signer controls.
+	GetPublicKey(ctx context.Context) (PubKey, error)
+
+	// SignEvent sets the PubKey, ID and Sig of the event.
+	SignEvent(ctx context.Context, evt *Event) error
+
+	// NIP04Encrypt encrypts plaintext to the given recipient as in NIP-04.
+	NIP04Encrypt(ctx context.Context, plaintext string, recipient PubKey) (string, error)
+
+	// NIP04Decrypt decrypts a NIP-04 ciphertext received from sender.
+	NIP04Decrypt(ctx context.Context, ciphertext string, sender PubKey) (string, error)
+
+	// NIP44Encrypt encrypts plaintext to the given recipient as in NIP-44.
+	NIP44Encrypt(ctx context.Context, plaintext string, recipient PubKey) (string, error)
+
+	// NIP44Decrypt decrypts a NIP-44 payload received from sender.
+	NIP44Decrypt(ctx context.Context, ciphertext string, sender PubKey) (string, error)
+}
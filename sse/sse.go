@@ -0,0 +1,194 @@
+// Package sse exposes Nostr subscriptions to browsers and other HTTP clients as
+// Server-Sent Events, so web backends don't need their own websocket plumbing.
+package sse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Handler streams the events matching the filter given in the query string, see FilterFromQuery.
+//
+// Stored events are sent first as "event" messages, followed by an "eose" message, and then new
+// events keep coming until the client disconnects. A comment is sent every KeepAlive to keep
+// proxies from closing idle connections.
+type Handler struct {
+	Pool      *nostr.SimplePool
+	Relays    []string
+	KeepAlive time.Duration
+}
+
+func NewHandler(pool *nostr.SimplePool, relays []string) *Handler {
+	return &Handler{
+		Pool:      pool,
+		Relays:    relays,
+		KeepAlive: 30 * time.Second,
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	filter, err := FilterFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// stored events are everything before now, new ones everything after, and we dedupe the overlap
+	start := nostr.Now()
+	seen := make(map[nostr.ID]struct{})
+	send := func(evt *nostr.Event) {
+		if _, ok := seen[evt.ID]; ok {
+			return
+		}
+		seen[evt.ID] = struct{}{}
+		data, _ := json.Marshal(evt)
+		fmt.Fprintf(w, "id: %s\nevent: event\ndata: %s\n\n", evt.ID, data)
+		flusher.Flush()
+	}
+
+	stored := filter
+	if stored.Until == nil || stored.Until.After(start) {
+		stored.Until = &start
+	}
+	for evt := range h.Pool.SubManyEose(ctx, h.Relays, nostr.Filters{stored}) {
+		send(evt)
+	}
+	fmt.Fprint(w, "event: eose\ndata: \n\n")
+	flusher.Flush()
+
+	if filter.Until != nil && filter.Until.Before(start) {
+		// nothing new can match
+		return
+	}
+	live := filter
+	live.Since = &start
+	live.Limit = 0
+	events := h.Pool.SubMany(ctx, h.Relays, nostr.Filters{live})
+
+	keepAlive := h.KeepAlive
+	if keepAlive <= 0 {
+		keepAlive = 30 * time.Second
+	}
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case evt, ok := <-events:
+			if !ok {
+				return
+			}
+			send(evt)
+		case <-ticker.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// FilterFromQuery builds a filter from query parameters. List parameters can be repeated or
+// comma-separated: ids, authors, kinds and tags as "#e", "#p" and so on. since, until and limit
+// are numbers and search is a string. A full JSON filter can also be given in "filter".
+func FilterFromQuery(query url.Values) (nostr.Filter, error) {
+	var filter nostr.Filter
+
+	if j := query.Get("filter"); j != "" {
+		if err := json.Unmarshal([]byte(j), &filter); err != nil {
+			return filter, fmt.Errorf("invalid filter: %w", err)
+		}
+		return filter, nil
+	}
+
+	for _, v := range values(query, "ids") {
+		id, err := nostr.IDFromHex(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid id '%s': %w", v, err)
+		}
+		filter.IDs = append(filter.IDs, id)
+	}
+	for _, v := range values(query, "authors") {
+		pk, err := nostr.PubKeyFromHex(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid author '%s': %w", v, err)
+		}
+		filter.Authors = append(filter.Authors, pk)
+	}
+	for _, v := range values(query, "kinds") {
+		kind, err := strconv.Atoi(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid kind '%s'", v)
+		}
+		filter.Kinds = append(filter.Kinds, kind)
+	}
+
+	for key := range query {
+		if len(key) == 2 && key[0] == '#' {
+			if filter.Tags == nil {
+				filter.Tags = make(nostr.TagMap)
+			}
+			filter.Tags[key[1:]] = values(query, key)
+		}
+	}
+
+	for _, param := range []struct {
+		name string
+		dst  **time.Time
+	}{{"since", &filter.Since}, {"until", &filter.Until}} {
+		if v := query.Get(param.name); v != "" {
+			ts, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return filter, fmt.Errorf("invalid %s '%s'", param.name, v)
+			}
+			t := time.Unix(ts, 0)
+			*param.dst = &t
+		}
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			return filter, fmt.Errorf("invalid limit '%s'", v)
+		}
+		filter.Limit = limit
+	}
+
+	filter.Search = query.Get("search")
+	return filter, nil
+}
+
+func values(query url.Values, key string) []string {
+	var result []string
+	for _, v := range query[key] {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				result = append(result, item)
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,42 @@
+package sse
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestFilterFromQuery(t *testing.T) {
+	pk := "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"
+	query, _ := url.ParseQuery("kinds=1,6&kinds=7&authors=" + pk + "&%23t=nostr,bitcoin&since=1672068534&limit=20")
+
+	filter, err := FilterFromQuery(query)
+	if err != nil {
+		t.Fatalf("failed to parse: %s", err)
+	}
+	if len(filter.Kinds) != 3 || filter.Kinds[2] != 7 {
+		t.Errorf("wrong kinds %v", filter.Kinds)
+	}
+	if len(filter.Authors) != 1 || filter.Authors[0] != nostr.MustPubKeyFromHex(pk) {
+		t.Errorf("wrong authors %v", filter.Authors)
+	}
+	if len(filter.Tags["t"]) != 2 {
+		t.Errorf("wrong tags %v", filter.Tags)
+	}
+	if filter.Since == nil || filter.Since.Unix() != 1672068534 || filter.Limit != 20 {
+		t.Errorf("wrong since/limit %v %d", filter.Since, filter.Limit)
+	}
+
+	for _, invalid := range []string{"kinds=abc", "authors=xyz", "since=yesterday", "limit=-1", "filter={"} {
+		query, _ := url.ParseQuery(invalid)
+		if _, err := FilterFromQuery(query); err == nil {
+			t.Errorf("%s should have failed", invalid)
+		}
+	}
+
+	query, _ = url.ParseQuery(`filter={"kinds":[1],"limit":5}`)
+	if filter, err := FilterFromQuery(query); err != nil || filter.Limit != 5 || filter.Kinds[0] != 1 {
+		t.Errorf("json filter wasn't parsed: %v %s", filter, err)
+	}
+}
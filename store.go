@@ -0,0 +1,117 @@
+package nostr
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Store is a local place to keep events, used by Client as a cache so it doesn't have
+// to ask relays for the same things again and again.
+type Store interface {
+	// SaveEvent stores evt. Replaceable and addressable events replace the older versions,
+	// see LatestVersions, and are ignored if a newer version is already stored.
+	// Events that have expired (NIP-40) are rejected with ErrExpired.
+	SaveEvent(ctx context.Context, evt *Event) error
+
+	// QueryEvents returns the events matching filter, newest first, respecting filter.Limit.
+	QueryEvents(ctx context.Context, filter Filter) ([]*Event, error)
+}
+
+var _ ExpiringStore = (*MemoryStore)(nil)
+
+// MemoryStore is a Store that keeps all events in memory. Expired events are not returned,
+// but they are only deleted by PurgeExpired, see PurgeExpiredPeriodically.
+type MemoryStore struct {
+	mutex       sync.RWMutex
+	events      map[ID]*Event
+	replaceable map[ReplaceableKey]*Event
+	expiring    map[ID]time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		events:      make(map[ID]*Event),
+		replaceable: make(map[ReplaceableKey]*Event),
+		expiring:    make(map[ID]time.Time),
+	}
+}
+
+func (ms *MemoryStore) SaveEvent(ctx context.Context, evt *Event) error {
+	expiration, expires := evt.Expiration()
+	if expires && !expiration.After(Now()) {
+		return ErrExpired
+	}
+
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	if _, ok := ms.events[evt.ID]; ok {
+		return nil
+	}
+
+	if key, ok := evt.ReplaceableKey(); ok {
+		if current, ok := ms.replaceable[key]; ok {
+			if !evt.IsNewerThan(current) {
+				return nil
+			}
+			ms.delete(current)
+		}
+		ms.replaceable[key] = evt
+	}
+
+	ms.events[evt.ID] = evt
+	if expires {
+		ms.expiring[evt.ID] = expiration
+	}
+	return nil
+}
+
+// PurgeExpired deletes the events that are expired at now and returns them.
+func (ms *MemoryStore) PurgeExpired(ctx context.Context, now time.Time) ([]*Event, error) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	var removed []*Event
+	for id, expiration := range ms.expiring {
+		if !expiration.After(now) {
+			evt := ms.events[id]
+			ms.delete(evt)
+			removed = append(removed, evt)
+		}
+	}
+	return removed, nil
+}
+
+func (ms *MemoryStore) delete(evt *Event) {
+	delete(ms.events, evt.ID)
+	delete(ms.expiring, evt.ID)
+	if key, ok := evt.ReplaceableKey(); ok && ms.replaceable[key] == evt {
+		delete(ms.replaceable, key)
+	}
+}
+
+func (ms *MemoryStore) QueryEvents(ctx context.Context, filter Filter) ([]*Event, error) {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	now := Now()
+	var results []*Event
+	for _, evt := range ms.events {
+		if expiration, ok := ms.expiring[evt.ID]; ok && !expiration.After(now) {
+			continue
+		}
+		if filter.Matches(evt) {
+			results = append(results, evt)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].CreatedAt.After(results[j].CreatedAt)
+	})
+	if filter.Limit > 0 && len(results) > filter.Limit {
+		results = results[0:filter.Limit]
+	}
+	return results, nil
+}
@@ -0,0 +1,191 @@
+package nostr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/valyala/fastjson"
+)
+
+// ErrMalformed is wrapped by the errors of strict parsing, see Event.UnmarshalStrict and
+// ParseMessageStrict.
+var ErrMalformed = errors.New("malformed")
+
+// UnmarshalStrict is like UnmarshalJSON, but fails instead of zeroing or guessing fields: all
+// NIP-01 fields must be present with their exact types, ids, keys and signatures in lowercase
+// hex, created_at and kind as plain integers, tags as arrays of one or more strings and no key
+// may be repeated. Extra fields are still kept in Extra. Relays and validators should use it on
+// events they didn't create.
+func (evt *Event) UnmarshalStrict(payload []byte) error {
+	fastjsonParser := fastjsonParsers.Get()
+	defer fastjsonParsers.Put(fastjsonParser)
+	parsed, err := fastjsonParser.ParseBytes(payload)
+	if err != nil {
+		return fmt.Errorf("failed to parse event: %w", err)
+	}
+	if err := checkStrictEvent(parsed); err != nil {
+		return err
+	}
+	return evt.UnmarshalJSON(payload)
+}
+
+// ParseMessageStrict splits a message of the relay protocol, like ["EVENT", {...}], into its
+// command and the rest of its elements, failing with ErrMalformed if it isn't an array starting
+// with a string or if any object in it repeats a key. Events in it still need UnmarshalStrict.
+func ParseMessageStrict(message []byte) (string, []json.RawMessage, error) {
+	fastjsonParser := fastjsonParsers.Get()
+	defer fastjsonParsers.Put(fastjsonParser)
+	parsed, err := fastjsonParser.ParseBytes(message)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse message: %w", err)
+	}
+
+	arr, err := parsed.Array()
+	if err != nil || len(arr) == 0 {
+		return "", nil, fmt.Errorf("message is not a non-empty array: %w", ErrMalformed)
+	}
+	command, err := arr[0].StringBytes()
+	if err != nil || len(command) == 0 {
+		return "", nil, fmt.Errorf("message doesn't start with a command: %w", ErrMalformed)
+	}
+	if err := checkDuplicateKeys(parsed); err != nil {
+		return "", nil, err
+	}
+
+	// copied, as the parser is reused
+	elements := make([]json.RawMessage, len(arr)-1)
+	for i, v := range arr[1:] {
+		elements[i] = v.MarshalTo(nil)
+	}
+	return string(command), elements, nil
+}
+
+func checkStrictEvent(v *fastjson.Value) error {
+	obj, err := v.Object()
+	if err != nil {
+		return fmt.Errorf("event is not an object: %w", ErrMalformed)
+	}
+
+	seen := make(map[string]bool, 7)
+	var visiterr error
+	obj.Visit(func(k []byte, v *fastjson.Value) {
+		if visiterr != nil {
+			return
+		}
+		key := string(k)
+		if seen[key] {
+			visiterr = fmt.Errorf("repeated '%s' field: %w", key, ErrMalformed)
+			return
+		}
+		seen[key] = true
+
+		switch key {
+		case "id", "pubkey":
+			visiterr = checkStrictHex(key, v, 64)
+		case "sig":
+			visiterr = checkStrictHex(key, v, 128)
+		case "created_at", "kind":
+			if !isPlainInteger(v) {
+				visiterr = fmt.Errorf("'%s' field is not a non-negative integer: %w", key, ErrMalformed)
+			}
+		case "content":
+			if v.Type() != fastjson.TypeString {
+				visiterr = fmt.Errorf("'content' field is not a string: %w", ErrMalformed)
+			}
+		case "tags":
+			visiterr = checkStrictTags(v)
+		default:
+			visiterr = checkDuplicateKeys(v)
+		}
+	})
+	if visiterr != nil {
+		return visiterr
+	}
+
+	for _, key := range []string{"id", "pubkey", "created_at", "kind", "tags", "content", "sig"} {
+		if !seen[key] {
+			return fmt.Errorf("missing '%s' field: %w", key, ErrMalformed)
+		}
+	}
+	return nil
+}
+
+func checkStrictHex(key string, v *fastjson.Value, size int) error {
+	sb, err := v.StringBytes()
+	if err != nil || len(sb) != size {
+		return fmt.Errorf("'%s' field is not %d hex characters: %w", key, size, ErrMalformed)
+	}
+	for _, c := range sb {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return fmt.Errorf("'%s' field is not lowercase hex: %w", key, ErrMalformed)
+		}
+	}
+	return nil
+}
+
+// isPlainInteger tells if v is a number written with digits only, without sign, fraction or
+// exponent, which other parsers would truncate or round.
+func isPlainInteger(v *fastjson.Value) bool {
+	if v.Type() != fastjson.TypeNumber {
+		return false
+	}
+	raw := v.MarshalTo(nil)
+	for _, c := range raw {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	_, err := v.Int64()
+	return len(raw) > 0 && err == nil
+}
+
+func checkStrictTags(v *fastjson.Value) error {
+	arr, err := v.Array()
+	if err != nil {
+		return fmt.Errorf("'tags' field is not an array: %w", ErrMalformed)
+	}
+	for i, tag := range arr {
+		items, err := tag.Array()
+		if err != nil || len(items) == 0 {
+			return fmt.Errorf("tag %d is not a non-empty array: %w", i, ErrMalformed)
+		}
+		for _, item := range items {
+			if item.Type() != fastjson.TypeString {
+				return fmt.Errorf("tag %d has items that aren't strings: %w", i, ErrMalformed)
+			}
+		}
+	}
+	return nil
+}
+
+// checkDuplicateKeys fails if any object inside v has the same key more than once, which
+// parsers disagree on how to read.
+func checkDuplicateKeys(v *fastjson.Value) error {
+	switch v.Type() {
+	case fastjson.TypeObject:
+		obj, _ := v.Object()
+		seen := make(map[string]bool, obj.Len())
+		var err error
+		obj.Visit(func(k []byte, v *fastjson.Value) {
+			if err != nil {
+				return
+			}
+			if seen[string(k)] {
+				err = fmt.Errorf("repeated '%s' key: %w", k, ErrMalformed)
+				return
+			}
+			seen[string(k)] = true
+			err = checkDuplicateKeys(v)
+		})
+		return err
+	case fastjson.TypeArray:
+		arr, _ := v.Array()
+		for _, item := range arr {
+			if err := checkDuplicateKeys(item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package nostr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestUnmarshalStrict(t *testing.T) {
+	sk, pk := makeKeyPair(t)
+	evt := Event{Kind: 1, PubKey: pk, CreatedAt: time.Unix(1700000000, 0), Tags: Tags{{"t", "x"}}, Content: "hello"}
+	evt.Sign(sk)
+	j, _ := evt.MarshalJSON()
+	valid := string(j)
+
+	var parsed Event
+	if err := parsed.UnmarshalStrict([]byte(valid)); err != nil {
+		t.Fatalf("valid event rejected: %v", err)
+	}
+	if parsed.ID != evt.ID || parsed.Sig != evt.Sig || !parsed.CreatedAt.Equal(evt.CreatedAt) {
+		t.Fatalf("parsed %v instead of %v", parsed, evt)
+	}
+
+	for name, malformed := range map[string]string{
+		"uppercase id":      strings.Replace(valid, evt.ID.Hex(), strings.ToUpper(evt.ID.Hex()), 1),
+		"short pubkey":      strings.Replace(valid, pk.Hex(), pk.Hex()[2:], 1),
+		"uppercase sig":     strings.Replace(valid, evt.Sig, strings.ToUpper(evt.Sig), 1),
+		"float created_at":  strings.Replace(valid, `"created_at":1700000000`, `"created_at":1700000000.5`, 1),
+		"string created_at": strings.Replace(valid, `"created_at":1700000000`, `"created_at":"1700000000"`, 1),
+		"exponent kind":     strings.Replace(valid, `"kind":1`, `"kind":1e0`, 1),
+		"negative kind":     strings.Replace(valid, `"kind":1`, `"kind":-1`, 1),
+		"duplicate key":     strings.Replace(valid, `"kind":1`, `"kind":1,"kind":7`, 1),
+		"empty tag":         strings.Replace(valid, `"tags":[["t","x"]]`, `"tags":[[]]`, 1),
+		"number in tag":     strings.Replace(valid, `"tags":[["t","x"]]`, `"tags":[["t",1]]`, 1),
+		"tag not an array":  strings.Replace(valid, `"tags":[["t","x"]]`, `"tags":["t"]`, 1),
+		"missing content":   strings.Replace(valid, `"content":"hello",`, ``, 1),
+		"not an object":     `[]`,
+	} {
+		if malformed == valid {
+			t.Fatalf("%s: replacement didn't apply", name)
+		}
+		if err := (&Event{}).UnmarshalStrict([]byte(malformed)); err == nil {
+			t.Errorf("%s: accepted", name)
+		} else if name != "not an object" && !errors.Is(err, ErrMalformed) {
+			t.Errorf("%s: error doesn't wrap ErrMalformed: %v", name, err)
+		}
+	}
+}
+
+func TestParseMessageStrict(t *testing.T) {
+	command, rest, err := ParseMessageStrict([]byte(`["REQ","sub",{"kinds":[1]},{"authors":[]}]`))
+	if err != nil || command != "REQ" || len(rest) != 3 || string(rest[0]) != `"sub"` {
+		t.Fatalf("parsed %s %s %v", command, rest, err)
+	}
+
+	for _, malformed := range []string{
+		`{"REQ":"sub"}`,
+		`[]`,
+		`[1,"sub"]`,
+		`["REQ","sub",{"kinds":[1],"kinds":[7]}]`,
+		`["EVENT",{"tags":[],"content":"a","content":"b"}]`,
+		`["REQ"`,
+	} {
+		if _, _, err := ParseMessageStrict([]byte(malformed)); err == nil {
+			t.Errorf("%s accepted", malformed)
+		}
+	}
+}
+
+func TestStrictParsingRelay(t *testing.T) {
+	sk, pk := makeKeyPair(t)
+	evt := Event{Kind: 1, PubKey: pk, CreatedAt: time.Now(), Tags: Tags{}, Content: "strict"}
+	evt.Sign(sk)
+	j, _ := evt.MarshalJSON()
+	malformed := strings.Replace(string(j), evt.ID.Hex(), strings.ToUpper(evt.ID.Hex()), 1)
+
+	ws := newWebsocketServer(func(conn *websocket.Conn) {
+		var req []json.RawMessage
+		if err := websocket.JSON.Receive(conn, &req); err != nil {
+			return
+		}
+		var id string
+		json.Unmarshal(req[1], &id)
+		websocket.Message.Send(conn, `["EVENT","`+id+`",`+malformed+`]`)
+		websocket.Message.Send(conn, `["EVENT","`+id+`",`+string(j)+`]`)
+		websocket.JSON.Send(conn, []any{"EOSE", id})
+		var rest []any
+		websocket.JSON.Receive(conn, &rest)
+	})
+	defer ws.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	relay, err := RelayConnect(ctx, ws.URL, WithStrictParsing())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer relay.Close()
+
+	sub, err := relay.Sub(ctx, Filters{{Kinds: []int{1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-relay.Errors:
+		if !errors.Is(err, ErrMalformed) {
+			t.Fatalf("unexpected error %v", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("malformed event wasn't reported")
+	}
+	select {
+	case got := <-sub.Events:
+		if got.ID != evt.ID {
+			t.Fatalf("got %s", got.ID)
+		}
+	case <-ctx.Done():
+		t.Fatal("valid event not delivered")
+	}
+	<-sub.EndOfStoredEvents
+	if stats := sub.Stats(); stats.Received != 2 || stats.Delivered != 1 {
+		t.Fatalf("stats %+v", stats)
+	}
+}
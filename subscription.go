@@ -2,16 +2,23 @@ package nostr
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"strconv"
 	"sync"
-
-	"github.com/recws-org/recws"
+	"sync/atomic"
+	"time"
 )
 
+// ErrSubscriptionClosed is returned by Subscription.Next once the subscription is over.
+var ErrSubscriptionClosed = errors.New("subscription closed")
+
 type Subscription struct {
 	label   string
+	prefix  string // random for each relay, so ids aren't reused across restarts
 	counter int
-	conn    *recws.RecConn
+	id      string // given with WithSubscriptionID, used as it is
+	shortID bool   // the label is left out of the id, see Relay.fitSubscriptionID
 	mutex   sync.Mutex
 
 	Relay             *Relay
@@ -21,8 +28,63 @@ type Subscription struct {
 	Context           context.Context
 	cancel            context.CancelFunc
 
-	stopped  bool
-	emitEose sync.Once
+	// ClosedReason gets the reason the relay gave if it ended the subscription with "CLOSED",
+	// right before Events is closed.
+	ClosedReason chan string
+
+	stopped     bool
+	emitEose    sync.Once
+	authRetried bool // the REQ was sent again after a "CLOSED" asking for auth
+	closeOnEose bool // set by WithCloseOnEOSE
+
+	// Filters as they are actually sent, one REQ per batch, see Relay.splitFilters
+	batches     []Filters
+	eosePending int32
+	seen        *lru[ID, struct{}] // the latest events received, when there is more than one batch
+
+	// only used by Relay.Count
+	countResult chan int64
+
+	statistics subscriptionStats
+
+	// set by WithDecodedEvents
+	onDecoded func(evt *Event, value any)
+
+	// set by WithCursor, one key and newest created_at for each of the original filters
+	cursorStore   CursorStore
+	cursorKeys    []string
+	cursorLatest  []time.Time
+	originalSince []*time.Time
+}
+
+// SubscriptionOption customizes a subscription, see Relay.Subscribe.
+type SubscriptionOption func(*Subscription)
+
+// WithCursor makes the subscription remember in store the newest event received for each
+// filter, and use it as "since" when the same filters are subscribed again, even after a
+// restart if the store is persistent. Subscriptions with a cursor are also resumed from
+// that point when the relay connection drops and comes back.
+func WithCursor(store CursorStore) SubscriptionOption {
+	return func(sub *Subscription) {
+		sub.cursorStore = store
+	}
+}
+
+// WithSubscriptionID makes the subscription use id instead of a generated one, it can't be the
+// id of another subscription open in the same relay.
+func WithSubscriptionID(id string) SubscriptionOption {
+	return func(sub *Subscription) {
+		sub.id = id
+	}
+}
+
+// WithCloseOnEOSE makes the subscription end as soon as the relay sends "EOSE", for queries
+// that only want stored events: "CLOSE" is sent and Events is closed right after the signal on
+// EndOfStoredEvents, and no new events are delivered.
+func WithCloseOnEOSE() SubscriptionOption {
+	return func(sub *Subscription) {
+		sub.closeOnEose = true
+	}
 }
 
 type EventMessage struct {
@@ -36,43 +98,99 @@ func (sub *Subscription) SetLabel(label string) {
 	sub.label = label
 }
 
-// GetID return the Nostr subscription ID as given to the relay, it will be a sequential number, stringified.
+// GetID return the Nostr subscription ID as given to the relay, it will be a random prefix for the
+// relay followed by a sequential number, unless it was given with WithSubscriptionID.
 func (sub *Subscription) GetID() string {
-	return sub.label + ":" + strconv.Itoa(sub.counter)
+	if sub.id != "" {
+		return sub.id
+	}
+	if sub.shortID {
+		return sub.prefix + strconv.Itoa(sub.counter)
+	}
+	return sub.label + ":" + sub.prefix + strconv.Itoa(sub.counter)
 }
 
 // Unsub closes the subscription, sending "CLOSE" to relay as in NIP-01.
-// Unsub() also closes the channel sub.Events.
+// Unsub() also closes the channel sub.Events, once "CLOSE" is sent. It can be called any number
+// of times, from any goroutine.
 func (sub *Subscription) Unsub() {
+	// an event may be waiting to be delivered with the lock held, this makes it give up
+	if sub.cancel != nil {
+		sub.cancel()
+	}
+
 	sub.mutex.Lock()
 	defer sub.mutex.Unlock()
+	if sub.stopped {
+		return
+	}
+	sub.stopped = true
 
-	sub.conn.WriteJSON([]interface{}{"CLOSE", sub.GetID()})
-	if sub.stopped == false && sub.Events != nil {
+	for i := range sub.getBatches() {
+		err := sub.Relay.send([]interface{}{"CLOSE", sub.batchID(i)})
+		if err != nil && err != ErrRelayClosed {
+			sub.Relay.reportError(ErrorWrite, fmt.Errorf("failed to close subscription %s: %w", sub.batchID(i), err))
+		}
+		sub.Relay.subscriptions.Delete(sub.batchID(i))
+	}
+	if sub.Events != nil {
 		close(sub.Events)
 	}
-	sub.stopped = true
+}
+
+// Next waits for the next event, as an alternative to reading from sub.Events: the relay isn't
+// read from while nobody asks for events, and each call can have its own deadline in ctx. It
+// returns ctx.Err() if ctx is done first and ErrSubscriptionClosed once the subscription is over,
+// with the reason, taken from ClosedReason, if the relay closed it.
+func (sub *Subscription) Next(ctx context.Context) (*Event, error) {
+	select {
+	case evt, more := <-sub.Events:
+		if more {
+			return evt, nil
+		}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	select {
+	case reason := <-sub.ClosedReason:
+		return nil, fmt.Errorf("%w by the relay: %s", ErrSubscriptionClosed, reason)
+	default:
+		return nil, ErrSubscriptionClosed
+	}
 }
 
 // Sub sets sub.Filters and then calls sub.Fire(ctx).
 func (sub *Subscription) Sub(ctx context.Context, filters Filters) {
 	sub.Filters = filters
+	sub.batches = sub.Relay.splitFilters(ctx, filters)
 	sub.Fire()
 }
 
 // Fire sends the "REQ" command to the relay.
+// If the filters had to be split it sends one "REQ" for each part, and the results are merged.
 func (sub *Subscription) Fire() error {
-	sub.Relay.subscriptions.Store(sub.GetID(), sub)
-
-	message := []interface{}{"REQ", sub.GetID()}
-	for _, filter := range sub.Filters {
-		message = append(message, filter)
+	batches := sub.getBatches()
+	atomic.StoreInt32(&sub.eosePending, int32(len(batches)))
+	sub.statistics.update(func(s *SubscriptionStats) { s.FiredAt = time.Now() })
+	if len(batches) > 1 {
+		sub.seen = newLRU[ID, struct{}](maxSeenPerSubscription)
 	}
 
-	err := sub.conn.WriteJSON(message)
-	if err != nil {
-		sub.cancel()
-		return err
+	for i, filters := range batches {
+		id := sub.batchID(i)
+		sub.Relay.subscriptions.Store(id, sub)
+		if err := sub.Relay.send(sub.reqMessage(id, filters)); err != nil {
+			// the subscription is dropped, so the batches already sent are closed
+			for j := 0; j < i; j++ {
+				sub.Relay.send([]interface{}{"CLOSE", sub.batchID(j)})
+			}
+			for j := 0; j <= i; j++ {
+				sub.Relay.subscriptions.Delete(sub.batchID(j))
+			}
+			sub.cancel()
+			return err
+		}
 	}
 
 	// the subscription ends once the context is canceled
@@ -91,3 +209,154 @@ func (sub *Subscription) Fire() error {
 
 	return nil
 }
+
+func (sub *Subscription) getBatches() []Filters {
+	if len(sub.batches) == 0 {
+		return []Filters{sub.Filters}
+	}
+	return sub.batches
+}
+
+// batchID is the id of the REQ that carries the given batch, the first one uses the subscription id.
+func (sub *Subscription) batchID(i int) string {
+	if i == 0 {
+		return sub.GetID()
+	}
+	return sub.GetID() + "." + strconv.Itoa(i)
+}
+
+// batchIndex is the inverse of batchID.
+func (sub *Subscription) batchIndex(id string) int {
+	for i := range sub.getBatches() {
+		if sub.batchID(i) == id {
+			return i
+		}
+	}
+	return 0
+}
+
+func (sub *Subscription) reqMessage(id string, filters Filters) []interface{} {
+	message := []interface{}{"REQ", id}
+	for _, filter := range filters {
+		message = append(message, filter)
+	}
+	return message
+}
+
+// fireBatch sends the "REQ" of one batch again.
+func (sub *Subscription) fireBatch(i int) error {
+	return sub.Relay.send(sub.reqMessage(sub.batchID(i), sub.getBatches()[i]))
+}
+
+// dispatchEose emits on EndOfStoredEvents once all the REQs of this subscription got their "EOSE".
+func (sub *Subscription) dispatchEose() {
+	if atomic.AddInt32(&sub.eosePending, -1) > 0 {
+		return
+	}
+	sub.emitEose.Do(func() {
+		sub.statistics.update(func(s *SubscriptionStats) {
+			s.EOSE = true
+			s.EOSELatency = time.Since(s.FiredAt)
+		})
+		sub.EndOfStoredEvents <- struct{}{}
+		if sub.closeOnEose {
+			// Unsub is called once the context is done, see Fire
+			sub.cancel()
+		}
+	})
+}
+
+// applyCursors sets the "since" of each filter to the newest event we have seen for it.
+func (sub *Subscription) applyCursors() {
+	if sub.cursorStore == nil {
+		return
+	}
+
+	if sub.cursorKeys == nil {
+		sub.cursorKeys = make([]string, len(sub.Filters))
+		sub.cursorLatest = make([]time.Time, len(sub.Filters))
+		sub.originalSince = make([]*time.Time, len(sub.Filters))
+		for i, filter := range sub.Filters {
+			sub.cursorKeys[i] = CursorKey(sub.Relay.URL, filter)
+			sub.originalSince[i] = filter.Since
+			if cursor, ok := sub.cursorStore.LoadCursor(sub.cursorKeys[i]); ok {
+				sub.cursorLatest[i] = cursor
+			}
+		}
+	}
+
+	// don't modify the filters the caller gave us
+	filters := make(Filters, len(sub.Filters))
+	copy(filters, sub.Filters)
+	for i := range filters {
+		cursor := sub.cursorLatest[i]
+		if cursor.IsZero() {
+			continue
+		}
+		if since := sub.originalSince[i]; since == nil || cursor.After(*since) {
+			filters[i].Since = &cursor
+		}
+	}
+	sub.Filters = filters
+}
+
+// how many events are remembered to drop those sent again by another batch, an event is only
+// sent again by batches waiting for their stored events, so only the latest ones matter.
+const maxSeenPerSubscription = 10000
+
+// isDuplicate tells if evt was already received in another batch, remembering it otherwise. Must
+// be called with sub.mutex held, with events whose signature was verified, so forged events can't
+// make us drop the real ones.
+func (sub *Subscription) isDuplicate(evt *Event) bool {
+	if sub.seen == nil {
+		return false
+	}
+	if _, seen := sub.seen.Get(evt.ID); seen {
+		return true
+	}
+	sub.seen.Add(evt.ID, struct{}{})
+	return false
+}
+
+// trackCursor records evt as the newest for the filters it matches. Must be called with sub.mutex
+// held, with events whose signature was verified, so a forged event from the future can't move
+// the cursor past events we still have to get.
+func (sub *Subscription) trackCursor(evt *Event) {
+	if sub.cursorStore == nil {
+		return
+	}
+	for i, filter := range sub.Filters {
+		if evt.CreatedAt.After(sub.cursorLatest[i]) && filter.Matches(evt) {
+			sub.cursorLatest[i] = evt.CreatedAt
+			sub.cursorStore.SaveCursor(sub.cursorKeys[i], evt.CreatedAt)
+		}
+	}
+}
+
+// resume sends the REQs again, starting from the cursors, after the connection was reestablished.
+func (sub *Subscription) resume() {
+	sub.mutex.Lock()
+	defer sub.mutex.Unlock()
+	if sub.stopped {
+		return
+	}
+
+	sub.applyCursors()
+	previous := len(sub.getBatches())
+	sub.batches = sub.Relay.splitFilters(sub.Context, sub.Filters)
+	batches := sub.getBatches()
+	for i := len(batches); i < previous; i++ {
+		sub.Relay.subscriptions.Delete(sub.batchID(i))
+	}
+	atomic.StoreInt32(&sub.eosePending, int32(len(batches)))
+	if len(batches) > 1 && sub.seen == nil {
+		sub.seen = newLRU[ID, struct{}](maxSeenPerSubscription)
+	} else if len(batches) <= 1 {
+		sub.seen = nil
+	}
+	for i, filters := range batches {
+		id := sub.batchID(i)
+		sub.Relay.subscriptions.Store(id, sub)
+		sub.Relay.send(sub.reqMessage(id, filters))
+	}
+}
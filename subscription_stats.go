@@ -0,0 +1,48 @@
+package nostr
+
+import (
+	"sync"
+	"time"
+)
+
+// SubscriptionStats tells how a subscription is doing, see Subscription.Stats.
+type SubscriptionStats struct {
+	Received           int // events the relay sent, including the dropped ones
+	Delivered          int // events sent to the Events channel
+	DroppedByFilter    int // events that didn't match the filters
+	DroppedBySignature int // events with an invalid signature
+
+	FiredAt     time.Time     // when the REQ was sent
+	EOSE        bool          // if the relay said it sent all stored events
+	EOSELatency time.Duration // how long after the REQ the "EOSE" came
+	LastEvent   time.Time     // when the last event was received, zero if none was
+}
+
+// Idle is how long the subscription has gone without receiving events, counted from the REQ
+// if it never got any. Subscriptions without an "EOSE" that are idle for long are probably stuck.
+func (s SubscriptionStats) Idle() time.Duration {
+	if s.LastEvent.IsZero() {
+		return time.Since(s.FiredAt)
+	}
+	return time.Since(s.LastEvent)
+}
+
+// subscriptionStats is kept apart from the subscription mutex, which may be held while an
+// event waits to be delivered, so stats can be read at any time.
+type subscriptionStats struct {
+	mu    sync.Mutex
+	stats SubscriptionStats
+}
+
+func (s *subscriptionStats) update(f func(*SubscriptionStats)) {
+	s.mu.Lock()
+	f(&s.stats)
+	s.mu.Unlock()
+}
+
+// Stats returns how many events the subscription got and dropped, and if and when it got "EOSE".
+func (sub *Subscription) Stats() SubscriptionStats {
+	sub.statistics.mu.Lock()
+	defer sub.statistics.mu.Unlock()
+	return sub.statistics.stats
+}
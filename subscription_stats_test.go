@@ -0,0 +1,73 @@
+package nostr
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestSubscriptionStats(t *testing.T) {
+	sk, pk := makeKeyPair(t)
+	good := Event{Kind: 1, PubKey: pk, CreatedAt: time.Unix(1700000000, 0), Content: "good"}
+	good.Sign(sk)
+	other := Event{Kind: 7, PubKey: pk, CreatedAt: time.Unix(1700000000, 0), Content: "+"}
+	other.Sign(sk)
+	forged := good
+	forged.Content = "forged"
+	forged.ID = forged.GetID()
+
+	ws := newWebsocketServer(func(conn *websocket.Conn) {
+		var req []json.RawMessage
+		if err := websocket.JSON.Receive(conn, &req); err != nil {
+			return
+		}
+		var id string
+		json.Unmarshal(req[1], &id)
+		time.Sleep(20 * time.Millisecond)
+		for _, evt := range []Event{good, other, forged} {
+			websocket.JSON.Send(conn, []any{"EVENT", id, evt})
+		}
+		websocket.JSON.Send(conn, []any{"EOSE", id})
+		// keep the connection open
+		for websocket.JSON.Receive(conn, &req) == nil {
+		}
+	})
+	defer ws.Close()
+
+	rl := mustRelayConnect(ws.URL)
+	defer rl.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sub, err := rl.Sub(ctx, Filters{{Kinds: []int{1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := sub.Stats(); stats.EOSE || stats.Received != 0 || stats.FiredAt.IsZero() {
+		t.Fatalf("unexpected stats before any event %+v", stats)
+	}
+
+	select {
+	case evt := <-sub.Events:
+		if evt.ID != good.ID {
+			t.Fatalf("got %s", evt.Content)
+		}
+	case <-ctx.Done():
+		t.Fatal("no event")
+	}
+	select {
+	case <-sub.EndOfStoredEvents:
+	case <-ctx.Done():
+		t.Fatal("no EOSE")
+	}
+
+	stats := sub.Stats()
+	if stats.Received != 3 || stats.Delivered != 1 || stats.DroppedByFilter != 1 || stats.DroppedBySignature != 1 {
+		t.Errorf("unexpected counts %+v", stats)
+	}
+	if !stats.EOSE || stats.EOSELatency < 20*time.Millisecond || stats.LastEvent.Before(stats.FiredAt) || stats.Idle() <= 0 {
+		t.Errorf("unexpected timings %+v", stats)
+	}
+}
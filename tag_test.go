@@ -1,6 +1,8 @@
 package nostr
 
 import (
+	"encoding/json"
+	"reflect"
 	"testing"
 )
 
@@ -46,3 +48,62 @@ func TestTagHelpers(t *testing.T) {
 		t.Error("append unique changed the order")
 	}
 }
+
+func TestTagsJSON(t *testing.T) {
+	tags := Tags{
+		Tag{"p", "abcdef", "wss://x.com"},
+		Tag{"alt", "quote \" and\nnewline"},
+		Tag{},
+	}
+	b, err := json.Marshal(tags)
+	if err != nil {
+		t.Fatalf("failed to marshal tags: %v", err)
+	}
+
+	var std [][]string
+	if err := json.Unmarshal(b, &std); err != nil {
+		t.Fatalf("tags are not valid json %s: %v", b, err)
+	}
+
+	var decoded Tags
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("failed to unmarshal tags: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, tags) {
+		t.Errorf("tags changed after round trip: %v != %v", decoded, tags)
+	}
+
+	if b, _ := json.Marshal(Tags(nil)); string(b) != "[]" {
+		t.Errorf("nil tags should be [], got %s", b)
+	}
+	if err := json.Unmarshal([]byte("null"), &decoded); err != nil || decoded != nil {
+		t.Errorf("null should be nil tags, got %v, %v", decoded, err)
+	}
+}
+
+func TestTagPositions(t *testing.T) {
+	id := MustIDFromHex("92570b321da503eac8014b23447301eb3d0bbdfbace0d11a4e4072e72bb7205d")
+	pk := MustPubKeyFromHex("e9142f724955c5854de36324dab0434f97b15ec6b33464d56ebe491e3f559d1b")
+
+	reply := EventTag(id, "", "reply")
+	if len(reply) != 4 || reply.Value() != id.Hex() || reply.Relay() != "" || reply.Marker() != "reply" {
+		t.Errorf("wrong event tag %v", reply)
+	}
+	if tag := EventTag(id, "wss://x.com", ""); len(tag) != 3 || tag.Relay() != "wss://x.com" {
+		t.Errorf("wrong event tag %v", tag)
+	}
+	if tag := PubKeyTag(pk, ""); len(tag) != 2 || tag.Value() != pk.Hex() {
+		t.Errorf("wrong pubkey tag %v", tag)
+	}
+	if tag := AddressTag("30023:"+pk.Hex()+":x", "wss://y.com"); tag.Relay() != "wss://y.com" {
+		t.Errorf("wrong address tag %v", tag)
+	}
+
+	contact := Tag{"p", pk.Hex(), "", "bob"}
+	if contact.Petname() != "bob" || contact.Marker() != "" {
+		t.Errorf("wrong petname or marker in %v", contact)
+	}
+	if (Tag{}).Relay() != "" || (Tag{"t", "x", "y"}).Relay() != "" {
+		t.Errorf("relay hint out of place")
+	}
+}
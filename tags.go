@@ -3,8 +3,10 @@ package nostr
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"strings"
 
+	"github.com/valyala/fastjson"
 	"golang.org/x/exp/slices"
 )
 
@@ -47,13 +49,53 @@ func (tag Tag) Value() string {
 	return ""
 }
 
+// Relay is the relay hint of "e", "p", "a" and "q" tags, the third item.
 func (tag Tag) Relay() string {
-	if (tag[0] == "e" || tag[0] == "p") && len(tag) > 2 {
+	if len(tag) > 2 && (tag[0] == "e" || tag[0] == "p" || tag[0] == "a" || tag[0] == "q") {
 		return tag[2]
 	}
 	return ""
 }
 
+// Marker is the NIP-10 marker of "e" tags, "root", "reply" or "mention", the fourth item.
+func (tag Tag) Marker() string {
+	if len(tag) > 3 && tag[0] == "e" {
+		return tag[3]
+	}
+	return ""
+}
+
+// Petname is the NIP-02 petname of "p" tags in contact lists, the fourth item.
+func (tag Tag) Petname() string {
+	if len(tag) > 3 && tag[0] == "p" {
+		return tag[3]
+	}
+	return ""
+}
+
+// EventTag makes an "e" tag, relay and marker can be empty.
+func EventTag(id ID, relay string, marker string) Tag {
+	return trimTag(Tag{"e", id.Hex(), relay, marker})
+}
+
+// PubKeyTag makes a "p" tag, relay can be empty.
+func PubKeyTag(pk PubKey, relay string) Tag {
+	return trimTag(Tag{"p", pk.Hex(), relay})
+}
+
+// AddressTag makes an "a" tag for an address like "<kind>:<pubkey>:<d tag>", relay can be empty.
+func AddressTag(address string, relay string) Tag {
+	return trimTag(Tag{"a", address, relay})
+}
+
+// trimTag drops empty items from the end of tag, keeping the key and value.
+func trimTag(tag Tag) Tag {
+	for len(tag) > 2 && tag[len(tag)-1] == "" {
+		tag = tag[:len(tag)-1]
+	}
+	return tag
+}
+
 type Tags []Tag
 
 // GetFirst gets the first tag in tags that matches the prefix, see [Tag.StartsWith]
@@ -174,3 +216,37 @@ func (tags Tags) marshalTo(dst []byte) []byte {
 	dst = append(dst, ']')
 	return dst
 }
+
+// MarshalJSON encodes the tags the same way they are in events, a nil Tags is "[]".
+func (tags Tags) MarshalJSON() ([]byte, error) {
+	return tags.marshalTo(make([]byte, 0, tags.sizeHint())), nil
+}
+
+// UnmarshalJSON decodes an array of arrays of strings, "null" is a nil Tags.
+func (tags *Tags) UnmarshalJSON(payload []byte) error {
+	fastjsonParser := fastjsonParsers.Get()
+	defer fastjsonParsers.Put(fastjsonParser)
+	parsed, err := fastjsonParser.ParseBytes(payload)
+	if err != nil {
+		return fmt.Errorf("failed to parse tags: %w", err)
+	}
+	if parsed.Type() == fastjson.TypeNull {
+		*tags = nil
+		return nil
+	}
+
+	*tags, err = fastjsonArrayToTags(parsed)
+	return err
+}
+
+// sizeHint is about the length of the tags encoded as JSON, to size buffers.
+func (tags Tags) sizeHint() int {
+	size := 2
+	for _, tag := range tags {
+		size += 3
+		for _, s := range tag {
+			size += len(s) + 3
+		}
+	}
+	return size
+}
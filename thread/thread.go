@@ -0,0 +1,196 @@
+// Package thread builds reply trees from events, following NIP-10 "e" tags in notes and
+// NIP-22 parent tags in comments, for clients that show conversations as threads.
+package thread
+
+import (
+	"sort"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip10"
+	"github.com/nbd-wtf/go-nostr/nip22"
+)
+
+// KindComment is the NIP-22 comment kind.
+const KindComment = nip22.KindComment
+
+// Node is an event in a thread. Nodes for events that are replied to but weren't given to the
+// tree yet have a nil Event, see Tree.Missing.
+type Node struct {
+	ID       nostr.ID
+	Event    *nostr.Event
+	Parent   *Node
+	Children []*Node
+}
+
+// Tree holds the events of one or more threads. Events can be added in any order and the tree
+// is rearranged as parents arrive.
+type Tree struct {
+	// Roots are the events without a parent, and the placeholders for missing parents of orphans.
+	Roots []*Node
+
+	nodes map[nostr.ID]*Node
+
+	// addressable events by address, and the comments on addresses that weren't added yet
+	addresses map[string]*Node
+	waiting   map[string][]*Node
+}
+
+// Build returns a tree with all the events.
+func Build(events []*nostr.Event) *Tree {
+	tree := &Tree{
+		nodes:     make(map[nostr.ID]*Node, len(events)),
+		addresses: make(map[string]*Node),
+		waiting:   make(map[string][]*Node),
+	}
+	for _, evt := range events {
+		tree.Add(evt)
+	}
+	return tree
+}
+
+// Add puts evt in the tree, under its parent, so events from a subscription can be added as
+// they come. Repeated events are ignored. Comments on an addressable event that only point to
+// its address go under the first version of it added, and are roots until then.
+func (tree *Tree) Add(evt *nostr.Event) {
+	if tree.nodes == nil {
+		tree.nodes = make(map[nostr.ID]*Node)
+		tree.addresses = make(map[string]*Node)
+		tree.waiting = make(map[string][]*Node)
+	}
+
+	node, exists := tree.nodes[evt.ID]
+	if exists && node.Event != nil {
+		return
+	}
+	if !exists {
+		node = &Node{ID: evt.ID}
+		tree.nodes[evt.ID] = node
+	} else {
+		// it was a placeholder, which is always a root
+		tree.Roots = remove(tree.Roots, node)
+	}
+	node.Event = evt
+
+	if address := nip22.EventTarget(evt, "").Address; address != "" {
+		if _, ok := tree.addresses[address]; !ok {
+			tree.addresses[address] = node
+			for _, comment := range tree.waiting[address] {
+				tree.Roots = remove(tree.Roots, comment)
+				comment.Parent = node
+				node.Children = insert(node.Children, comment)
+			}
+			delete(tree.waiting, address)
+		}
+	}
+
+	parentID, ok := ParentID(evt)
+	if !ok && evt.Kind == KindComment {
+		if parent, ok := nip22.Parent(evt); ok && parent.Address != "" {
+			if parentNode, ok := tree.addresses[parent.Address]; ok {
+				node.Parent = parentNode
+				parentNode.Children = insert(parentNode.Children, node)
+			} else {
+				tree.waiting[parent.Address] = append(tree.waiting[parent.Address], node)
+				tree.Roots = insert(tree.Roots, node)
+			}
+			return
+		}
+	}
+	if !ok || parentID == evt.ID {
+		tree.Roots = insert(tree.Roots, node)
+		return
+	}
+
+	parent, ok := tree.nodes[parentID]
+	if !ok {
+		// we don't have the parent yet, keep a placeholder for it
+		parent = &Node{ID: parentID}
+		tree.nodes[parentID] = parent
+		tree.Roots = insert(tree.Roots, parent)
+	}
+	node.Parent = parent
+	parent.Children = insert(parent.Children, node)
+}
+
+// Find returns the node for the event with the given id, or nil.
+func (tree *Tree) Find(id nostr.ID) *Node {
+	return tree.nodes[id]
+}
+
+// Missing returns the ids of events that are replied to but weren't added, so they can be fetched.
+func (tree *Tree) Missing() []nostr.ID {
+	var missing []nostr.ID
+	for _, root := range tree.Roots {
+		if root.Event == nil {
+			missing = append(missing, root.ID)
+		}
+	}
+	return missing
+}
+
+// Walk calls fn for every node, parents before children, with how deep they are in their thread.
+// Returning false skips the children of that node.
+func (tree *Tree) Walk(fn func(node *Node, depth int) bool) {
+	var walk func(nodes []*Node, depth int)
+	walk = func(nodes []*Node, depth int) {
+		for _, node := range nodes {
+			if fn(node, depth) {
+				walk(node.Children, depth+1)
+			}
+		}
+	}
+	walk(tree.Roots, 0)
+}
+
+// ParentID returns the id of the event evt replies to: the NIP-22 parent for comments and the
+// NIP-10 reply (or root) for everything else. Comments on addresses or external ids without an
+// event id have none.
+func ParentID(evt *nostr.Event) (nostr.ID, bool) {
+	if evt.Kind == KindComment {
+		parent, ok := nip22.Parent(evt)
+		if !ok || parent.ID == "" {
+			return nostr.ID{}, false
+		}
+		id, err := nostr.IDFromHex(parent.ID)
+		return id, err == nil
+	}
+
+	tag := nip10.GetImmediateReply(evt.Tags)
+	if tag == nil {
+		return nostr.ID{}, false
+	}
+	id, err := nostr.IDFromHex(tag.Value())
+	return id, err == nil
+}
+
+// insert adds node to nodes keeping them ordered by creation time, oldest first, then by id,
+// so the order doesn't depend on the order events arrived. Placeholders go first.
+func insert(nodes []*Node, node *Node) []*Node {
+	i := sort.Search(len(nodes), func(i int) bool { return less(node, nodes[i]) })
+	nodes = append(nodes, nil)
+	copy(nodes[i+1:], nodes[i:])
+	nodes[i] = node
+	return nodes
+}
+
+func less(a, b *Node) bool {
+	if a.Event == nil || b.Event == nil {
+		if a.Event == nil && b.Event == nil {
+			return string(a.ID[:]) < string(b.ID[:])
+		}
+		return a.Event == nil
+	}
+	if !a.Event.CreatedAt.Equal(b.Event.CreatedAt) {
+		return a.Event.CreatedAt.Before(b.Event.CreatedAt)
+	}
+	return string(a.ID[:]) < string(b.ID[:])
+}
+
+func remove(nodes []*Node, node *Node) []*Node {
+	for i, n := range nodes {
+		if n == node {
+			return append(nodes[:i], nodes[i+1:]...)
+		}
+	}
+	return nodes
+}
@@ -0,0 +1,93 @@
+package thread
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func note(id byte, at int64, kind int, tags ...nostr.Tag) *nostr.Event {
+	return &nostr.Event{ID: nostr.ID{id}, Kind: kind, CreatedAt: time.Unix(at, 0), Tags: tags}
+}
+
+func render(tree *Tree) string {
+	var lines []string
+	tree.Walk(func(node *Node, depth int) bool {
+		label := node.ID.Hex()[0:2]
+		if node.Event == nil {
+			label += "?"
+		}
+		lines = append(lines, strings.Repeat(" ", depth)+label)
+		return true
+	})
+	return strings.Join(lines, "\n")
+}
+
+func TestBuild(t *testing.T) {
+	root := note(1, 100, 1)
+	rootHex := root.ID.Hex()
+	reply := note(2, 110, 1, nostr.Tag{"e", rootHex, "", "root"})
+	nested := note(3, 120, 1, nostr.Tag{"e", rootHex, "", "root"}, nostr.Tag{"e", nostr.ID{2}.Hex(), "", "reply"})
+	earlier := note(4, 105, 1, nostr.Tag{"e", rootHex})
+	comment := note(5, 130, KindComment, nostr.Tag{"E", rootHex}, nostr.Tag{"e", nostr.ID{3}.Hex()})
+	orphan := note(6, 140, 1, nostr.Tag{"e", rootHex, "", "root"}, nostr.Tag{"e", nostr.ID{9}.Hex(), "", "reply"})
+
+	events := []*nostr.Event{orphan, comment, nested, reply, earlier, root}
+	tree := Build(events)
+
+	expected := "09?\n 06\n01\n 04\n 02\n  03\n   05"
+	if got := render(tree); got != expected {
+		t.Fatalf("wrong tree:\n%s\nexpected:\n%s", got, expected)
+	}
+
+	// order of arrival doesn't matter
+	tree = &Tree{}
+	for i := len(events) - 1; i >= 0; i-- {
+		tree.Add(events[i])
+		tree.Add(events[i])
+	}
+	if got := render(tree); got != expected {
+		t.Fatalf("wrong tree when added in reverse:\n%s", got)
+	}
+
+	if missing := tree.Missing(); len(missing) != 1 || missing[0] != (nostr.ID{9}) {
+		t.Fatalf("wrong missing %v", missing)
+	}
+
+	// the missing parent arrives
+	tree.Add(note(9, 135, 1, nostr.Tag{"e", rootHex, "", "root"}, nostr.Tag{"e", nostr.ID{2}.Hex(), "", "reply"}))
+	expected = "01\n 04\n 02\n  03\n   05\n  09\n   06"
+	if got := render(tree); got != expected {
+		t.Fatalf("wrong tree after filling the orphan:\n%s", got)
+	}
+	if len(tree.Missing()) != 0 {
+		t.Fatalf("still missing %v", tree.Missing())
+	}
+	if tree.Find(nostr.ID{6}).Parent.Parent != tree.Find(nostr.ID{2}) {
+		t.Fatalf("wrong parents")
+	}
+}
+
+func TestBuildCommentsOnAddress(t *testing.T) {
+	article := note(1, 100, 30023, nostr.Tag{"d", "post"})
+	address := fmt.Sprintf("30023:%s:post", article.PubKey.Hex())
+	comment := note(2, 110, KindComment, nostr.Tag{"A", address}, nostr.Tag{"K", "30023"},
+		nostr.Tag{"a", address}, nostr.Tag{"k", "30023"})
+	reply := note(3, 120, KindComment, nostr.Tag{"A", address}, nostr.Tag{"K", "30023"},
+		nostr.Tag{"e", nostr.ID{2}.Hex()}, nostr.Tag{"k", "1111"})
+
+	tree := Build([]*nostr.Event{reply, comment})
+	if got := render(tree); got != "02\n 03" {
+		t.Fatalf("wrong tree before the article:\n%s", got)
+	}
+	tree.Add(article)
+	if got := render(tree); got != "01\n 02\n  03" {
+		t.Fatalf("wrong tree with the article:\n%s", got)
+	}
+	if got := render(Build([]*nostr.Event{article, reply, comment})); got != "01\n 02\n  03" {
+		t.Fatalf("wrong tree with the article first:\n%s", got)
+	}
+}
@@ -0,0 +1,74 @@
+package nostr
+
+import "context"
+
+// Tracer creates the spans that trace what a Relay does: connecting, publishing, subscribing
+// and each event received. It is shaped after OpenTelemetry so an adapter is only a few lines,
+// without this library depending on it:
+//
+//	type otelTracer struct{ trace.Tracer }
+//
+//	func (t otelTracer) Start(ctx context.Context, name string, attrs ...nostr.Attribute) (context.Context, nostr.Span) {
+//		ctx, span := t.Tracer.Start(ctx, name)
+//		s := otelSpan{span}
+//		s.SetAttributes(attrs...)
+//		return ctx, s
+//	}
+//
+// with otelSpan converting Attributes to attribute.KeyValue. Nothing is traced unless a Tracer is
+// set with WithTracer or DefaultTracer.
+type Tracer interface {
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+// Span is one operation being traced.
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	RecordError(err error)
+	End()
+}
+
+// Attribute is a key and a string, int or bool value describing a Span, e.g. the relay URL
+// ("nostr.relay.url"), the subscription id ("nostr.subscription.id") or the event kind
+// ("nostr.event.kind").
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// DefaultTracer is used by relays without their own Tracer, see WithTracer.
+var DefaultTracer Tracer
+
+// WithTracer makes the relay trace its operations with tracer.
+func WithTracer(tracer Tracer) RelayOption {
+	return func(r *Relay) {
+		r.tracer = tracer
+	}
+}
+
+// startSpan starts a span named name with the relay URL among its attributes, or a span that
+// does nothing if there is no Tracer.
+func (r *Relay) startSpan(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	tracer := r.tracer
+	if tracer == nil {
+		tracer = DefaultTracer
+	}
+	if tracer == nil {
+		return ctx, noopSpan{}
+	}
+	return tracer.Start(ctx, name, append([]Attribute{{"nostr.relay.url", r.URL}}, attrs...)...)
+}
+
+// endSpan records err, if any, and ends span.
+func endSpan(span Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(...Attribute) {}
+func (noopSpan) RecordError(error)          {}
+func (noopSpan) End()                       {}
@@ -0,0 +1,98 @@
+package nostr
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordedSpan struct {
+	name  string
+	attrs map[string]any
+	ended bool
+}
+
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+func (rt *recordingTracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	span := &recordedSpan{name: name, attrs: make(map[string]any)}
+	rt.mu.Lock()
+	rt.spans = append(rt.spans, span)
+	rt.mu.Unlock()
+	s := recordingSpan{rt, span}
+	s.SetAttributes(attrs...)
+	return ctx, s
+}
+
+func (rt *recordingTracer) find(name string) *recordedSpan {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	for _, span := range rt.spans {
+		if span.name == name {
+			return span
+		}
+	}
+	return nil
+}
+
+type recordingSpan struct {
+	tracer *recordingTracer
+	span   *recordedSpan
+}
+
+func (s recordingSpan) SetAttributes(attrs ...Attribute) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	for _, attr := range attrs {
+		s.span.attrs[attr.Key] = attr.Value
+	}
+}
+func (s recordingSpan) RecordError(err error) { s.SetAttributes(Attribute{"error", err.Error()}) }
+func (s recordingSpan) End() {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.span.ended = true
+}
+
+func TestRelayTracing(t *testing.T) {
+	ws := newWebsocketServer((&fakeRelay{}).handle)
+	defer ws.Close()
+
+	tracer := &recordingTracer{}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	relay, err := RelayConnect(ctx, ws.URL, WithTracer(tracer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer relay.Close()
+
+	sk, pk := makeKeyPair(t)
+	evt := Event{Kind: 1, PubKey: pk, CreatedAt: time.Now(), Content: "traced"}
+	evt.Sign(sk)
+	relay.Publish(ctx, evt)
+	if _, err := relay.Query(ctx, Filter{Kinds: []int{1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	connect := tracer.find("nostr.Connect")
+	if connect == nil || !connect.ended || connect.attrs["nostr.relay.url"] != relay.URL {
+		t.Fatalf("unexpected connect span %v", connect)
+	}
+	publish := tracer.find("nostr.Publish")
+	if publish == nil || publish.attrs["nostr.event.kind"] != 1 || publish.attrs["nostr.publish.status"] != "success" {
+		t.Fatalf("unexpected publish span %v", publish)
+	}
+	subscribe := tracer.find("nostr.Subscribe")
+	if subscribe == nil || subscribe.attrs["nostr.subscription.id"] == nil {
+		t.Fatalf("unexpected subscribe span %v", subscribe)
+	}
+	receive := tracer.find("nostr.ReceiveEvent")
+	if receive == nil || receive.attrs["nostr.subscription.id"] != subscribe.attrs["nostr.subscription.id"] {
+		t.Fatalf("unexpected receive span %v", receive)
+	}
+}
@@ -0,0 +1,163 @@
+package nostr
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sync"
+)
+
+// KindValidator checks that an event has the content and tags its kind requires.
+type KindValidator func(evt *Event) error
+
+var (
+	kindValidatorsMutex sync.RWMutex
+	kindValidators      = map[int]KindValidator{
+		KindSetMetadata:       validateMetadata,
+		KindContactList:       validateContactList,
+		KindDeletion:          validateDeletion,
+		KindReaction:          validateReaction,
+		KindZapRequest:        validateZapRequest,
+		KindZap:               validateZapReceipt,
+		KindRelayListMetadata: validateRelayList,
+	}
+)
+
+// RegisterKindValidator sets the validator used by ValidateKind for events of kind, replacing
+// the one already registered. A nil validator removes it.
+func RegisterKindValidator(kind int, validator KindValidator) {
+	kindValidatorsMutex.Lock()
+	defer kindValidatorsMutex.Unlock()
+
+	if validator == nil {
+		delete(kindValidators, kind)
+	} else {
+		kindValidators[kind] = validator
+	}
+}
+
+// ValidateKind checks the event against the validator registered for its kind, which by
+// default exist for kinds 0, 3, 5, 7, 9734, 9735 and 10002, and for 1984 once nip56 is
+// imported. Events of other kinds are always valid. The signature is not checked.
+func ValidateKind(evt *Event) error {
+	kindValidatorsMutex.RLock()
+	validator, ok := kindValidators[evt.Kind]
+	kindValidatorsMutex.RUnlock()
+
+	if !ok {
+		return nil
+	}
+	if err := validator(evt); err != nil {
+		return fmt.Errorf("invalid kind %d event: %w", evt.Kind, err)
+	}
+	return nil
+}
+
+func validateMetadata(evt *Event) error {
+	var metadata map[string]any
+	if err := json.Unmarshal([]byte(evt.Content), &metadata); err != nil || metadata == nil {
+		return fmt.Errorf("content must be a JSON object")
+	}
+	return nil
+}
+
+func validateContactList(evt *Event) error {
+	for _, tag := range evt.Tags {
+		if len(tag) == 0 || tag[0] != "p" {
+			continue
+		}
+		if err := validatePubKeyTag(tag); err != nil {
+			return err
+		}
+		if len(tag) >= 3 && tag[2] != "" && !IsValidRelayURL(tag[2]) {
+			return fmt.Errorf("invalid relay '%s' for %s", tag[2], tag[1])
+		}
+	}
+	return nil
+}
+
+func validateDeletion(evt *Event) error {
+	for _, tag := range evt.Tags {
+		if len(tag) >= 2 && (tag[0] == "e" || tag[0] == "a") {
+			return nil
+		}
+	}
+	return fmt.Errorf("must have an 'e' or 'a' tag")
+}
+
+func validateReaction(evt *Event) error {
+	if evt.Tags.GetLast([]string{"e", ""}) == nil {
+		return fmt.Errorf("must have an 'e' tag")
+	}
+	return nil
+}
+
+func validateZapRequest(evt *Event) error {
+	if ps := evt.Tags.GetAll([]string{"p", ""}); len(ps) != 1 {
+		return fmt.Errorf("must have exactly one 'p' tag")
+	} else if err := validatePubKeyTag(ps[0]); err != nil {
+		return err
+	}
+	if relays := evt.Tags.GetFirst([]string{"relays", ""}); relays == nil || len(*relays) < 2 {
+		return fmt.Errorf("must have a 'relays' tag")
+	}
+	return nil
+}
+
+func validateZapReceipt(evt *Event) error {
+	if p := evt.Tags.GetFirst([]string{"p", ""}); p == nil {
+		return fmt.Errorf("must have a 'p' tag")
+	} else if err := validatePubKeyTag(*p); err != nil {
+		return err
+	}
+	if bolt11 := evt.Tags.GetFirst([]string{"bolt11", ""}); bolt11 == nil || bolt11.Value() == "" {
+		return fmt.Errorf("must have a 'bolt11' tag")
+	}
+
+	description := evt.Tags.GetFirst([]string{"description", ""})
+	if description == nil {
+		return fmt.Errorf("must have a 'description' tag")
+	}
+	var request Event
+	if err := json.Unmarshal([]byte(description.Value()), &request); err != nil {
+		return fmt.Errorf("description is not an event: %w", err)
+	}
+	if request.Kind != KindZapRequest {
+		return fmt.Errorf("description is kind %d, not a zap request", request.Kind)
+	}
+	if err := validateZapRequest(&request); err != nil {
+		return fmt.Errorf("invalid zap request: %w", err)
+	}
+	return nil
+}
+
+func validateRelayList(evt *Event) error {
+	for _, tag := range evt.Tags {
+		if len(tag) == 0 || tag[0] != "r" {
+			continue
+		}
+		if len(tag) < 2 || !IsValidRelayURL(tag[1]) {
+			return fmt.Errorf("invalid relay in %v", tag)
+		}
+		if len(tag) >= 3 && tag[2] != "read" && tag[2] != "write" {
+			return fmt.Errorf("invalid marker '%s' for %s", tag[2], tag[1])
+		}
+	}
+	return nil
+}
+
+func validatePubKeyTag(tag Tag) error {
+	if len(tag) < 2 || !IsValidPublicKeyHex(tag[1]) {
+		return fmt.Errorf("invalid pubkey in %v", tag)
+	}
+	return nil
+}
+
+// IsValidRelayURL tells if u is an absolute ws:// or wss:// URL.
+func IsValidRelayURL(u string) bool {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+	return (parsed.Scheme == "ws" || parsed.Scheme == "wss") && parsed.Host != ""
+}
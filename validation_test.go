@@ -0,0 +1,46 @@
+package nostr
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateKind(t *testing.T) {
+	pk := "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"
+	request := `{"id":"0000000000000000000000000000000000000000000000000000000000000000","pubkey":"` + pk + `","created_at":1,"kind":9734,"tags":[["p","` + pk + `"],["relays","wss://nos.lol"]],"content":"","sig":""}`
+
+	for i, test := range []struct {
+		evt   Event
+		valid bool
+	}{
+		{Event{Kind: 0, Content: `{"name":"fiatjaf"}`}, true},
+		{Event{Kind: 0, Content: `["fiatjaf"]`}, false},
+		{Event{Kind: 0, Content: `null`}, false},
+		{Event{Kind: 3, Tags: Tags{{"p", pk, "wss://nos.lol", "fiatjaf"}, {"t", "x"}}}, true},
+		{Event{Kind: 3, Tags: Tags{{"p", "fiatjaf"}}}, false},
+		{Event{Kind: 3, Tags: Tags{{"p", pk, "https://nos.lol"}}}, false},
+		{Event{Kind: 5, Tags: Tags{{"a", "30023:" + pk + ":x"}}}, true},
+		{Event{Kind: 5}, false},
+		{Event{Kind: 9735, Tags: Tags{{"p", pk}, {"bolt11", "lnbc1"}, {"description", request}}}, true},
+		{Event{Kind: 9735, Tags: Tags{{"p", pk}, {"bolt11", "lnbc1"}, {"description", "{}"}}}, false},
+		{Event{Kind: 9735, Tags: Tags{{"p", pk}, {"description", request}}}, false},
+		{Event{Kind: 10002, Tags: Tags{{"r", "wss://nos.lol"}, {"r", "wss://nostr.wine", "write"}}}, true},
+		{Event{Kind: 10002, Tags: Tags{{"r", "wss://nos.lol", "both"}}}, false},
+		{Event{Kind: 1, Content: "anything"}, true},
+	} {
+		if err := ValidateKind(&test.evt); (err == nil) != test.valid {
+			t.Errorf("%d: expected valid=%v, got %v", i, test.valid, err)
+		}
+	}
+
+	RegisterKindValidator(1, func(evt *Event) error {
+		if evt.Content == "" {
+			return errors.New("empty")
+		}
+		return nil
+	})
+	defer RegisterKindValidator(1, nil)
+	if err := ValidateKind(&Event{Kind: 1}); err == nil {
+		t.Error("registered validator wasn't used")
+	}
+}
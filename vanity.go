@@ -0,0 +1,168 @@
+package nostr
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+)
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+type vanityOptions struct {
+	workers          int
+	progress         func(attempts uint64)
+	progressInterval time.Duration
+}
+
+// VanityOption customizes MineVanityKey.
+type VanityOption func(*vanityOptions)
+
+// WithWorkers sets how many goroutines search in parallel, by default one per CPU.
+func WithWorkers(n int) VanityOption {
+	return func(o *vanityOptions) {
+		o.workers = n
+	}
+}
+
+// WithProgress makes MineVanityKey call fn with the total number of keys tried so far
+// every interval, until it finishes.
+func WithProgress(fn func(attempts uint64), interval time.Duration) VanityOption {
+	return func(o *vanityOptions) {
+		o.progress = fn
+		o.progressInterval = interval
+	}
+}
+
+// VanityDifficulty is the expected number of keys that must be tried to find one whose npub
+// starts with prefix.
+func VanityDifficulty(prefix string) float64 {
+	d := 1.0
+	for range strings.TrimPrefix(strings.ToLower(prefix), "npub1") {
+		d *= 32
+	}
+	return d
+}
+
+// MineVanityKey searches for a private key whose npub starts with "npub1" followed by prefix,
+// using all CPUs, and returns it as hex. The "npub1" part of the prefix is optional.
+// Each additional character makes it 32 times harder, so it should be used with a ctx
+// that can be canceled, in which case ctx.Err() is returned.
+func MineVanityKey(ctx context.Context, prefix string, opts ...VanityOption) (string, error) {
+	options := vanityOptions{workers: runtime.NumCPU(), progressInterval: time.Second}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.workers < 1 {
+		options.workers = 1
+	}
+
+	prefix = strings.TrimPrefix(strings.ToLower(prefix), "npub1")
+	if len(prefix) > 52 {
+		return "", fmt.Errorf("prefix is too long")
+	}
+	target := make([]byte, len(prefix))
+	for i, c := range prefix {
+		idx := strings.IndexRune(bech32Charset, c)
+		if idx == -1 {
+			return "", fmt.Errorf("'%c' can't appear in an npub, valid characters are %s", c, bech32Charset)
+		}
+		target[i] = byte(idx)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var attempts uint64
+	var found string
+	var once sync.Once
+	wg := sync.WaitGroup{}
+
+	for w := 0; w < options.workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var seed [32]byte
+			if _, err := rand.Read(seed[:]); err != nil {
+				return
+			}
+			var k btcec.ModNScalar
+			k.SetBytes(&seed)
+			one := new(btcec.ModNScalar).SetInt(1)
+
+			for batch := 0; ; batch++ {
+				if batch%256 == 0 {
+					if ctx.Err() != nil {
+						return
+					}
+				}
+
+				k.Add(one)
+				if k.IsZero() {
+					continue
+				}
+				sk := btcec.PrivKeyFromScalar(&k)
+				x := schnorr.SerializePubKey(sk.PubKey())
+				atomic.AddUint64(&attempts, 1)
+
+				if hasBech32Prefix(x, target) {
+					once.Do(func() {
+						b := k.Bytes()
+						found = hex.EncodeToString(b[:])
+						cancel()
+					})
+					return
+				}
+			}
+		}()
+	}
+
+	if options.progress != nil {
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			ticker := time.NewTicker(options.progressInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					options.progress(atomic.LoadUint64(&attempts))
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	if found != "" {
+		return found, nil
+	}
+	return "", ctx.Err()
+}
+
+// hasBech32Prefix compares the 5-bit groups at the start of data with target.
+func hasBech32Prefix(data []byte, target []byte) bool {
+	for i, want := range target {
+		bit := i * 5
+		// the 5 bits may span two bytes
+		v := uint16(data[bit/8]) << 8
+		if bit/8+1 < len(data) {
+			v |= uint16(data[bit/8+1])
+		}
+		got := byte(v>>(11-bit%8)) & 0x1f
+		if got != want {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,57 @@
+package nostr
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMineVanityKey(t *testing.T) {
+	sk, err := MineVanityKey(context.Background(), "npub1q")
+	if err != nil {
+		t.Fatalf("failed to mine: %s", err)
+	}
+	if !IsValidPrivateKey(sk) {
+		t.Fatalf("mined invalid key %s", sk)
+	}
+	pk, _ := GetPublicKey(sk)
+	if npub, _ := PublicKeyToNpub(pk); !strings.HasPrefix(npub, "npub1q") {
+		t.Errorf("%s doesn't have the prefix", npub)
+	}
+
+	for _, prefix := range []string{"ac", "dy"} {
+		sk, err := MineVanityKey(context.Background(), prefix, WithWorkers(2))
+		if err != nil {
+			t.Fatalf("failed to mine: %s", err)
+		}
+		pk, _ := GetPublicKey(sk)
+		npub, _ := PublicKeyToNpub(pk)
+		if !strings.HasPrefix(npub, "npub1"+prefix) {
+			t.Errorf("%s doesn't start with %s", npub, prefix)
+		}
+	}
+
+	if _, err := MineVanityKey(context.Background(), "bio"); err == nil {
+		t.Error("'b', 'i' and 'o' can't appear in npubs")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var attempts uint64
+	var mu sync.Mutex
+	_, err = MineVanityKey(ctx, "qqqqqqqqqqqq", WithProgress(func(n uint64) {
+		mu.Lock()
+		attempts = n
+		mu.Unlock()
+	}, time.Millisecond))
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts == 0 {
+		t.Error("progress wasn't reported")
+	}
+}
@@ -0,0 +1,136 @@
+package nostr
+
+import (
+	"log"
+	"runtime"
+	"sync"
+)
+
+// SignatureVerifier checks the signatures of events received from relays on a fixed number of
+// goroutines shared by all the relays that use it, so a burst of events from one relay is
+// verified in parallel and doesn't hold up the rest of its messages. Events are still delivered
+// in the order they arrived. See WithSignatureVerifier.
+type SignatureVerifier struct {
+	workers int
+	jobs    chan verification
+	start   sync.Once
+}
+
+type verification struct {
+	evt    *Event
+	check  SignatureChecker
+	result chan bool
+}
+
+// DefaultSignatureVerifier is used by relays that weren't given one, with a worker per CPU.
+var DefaultSignatureVerifier = NewSignatureVerifier(runtime.NumCPU())
+
+// NewSignatureVerifier creates a SignatureVerifier with the given number of workers, which are
+// only started once it is first used.
+func NewSignatureVerifier(workers int) *SignatureVerifier {
+	if workers < 1 {
+		workers = 1
+	}
+	return &SignatureVerifier{workers: workers, jobs: make(chan verification, workers*16)}
+}
+
+// verify queues evt to be checked with check, the result comes in the returned channel.
+func (v *SignatureVerifier) verify(evt *Event, check SignatureChecker) <-chan bool {
+	v.start.Do(func() {
+		for i := 0; i < v.workers; i++ {
+			go v.work()
+		}
+	})
+
+	result := make(chan bool, 1)
+	v.jobs <- verification{evt, check, result}
+	return result
+}
+
+func (v *SignatureVerifier) work() {
+	for job := range v.jobs {
+		job.result <- job.check(job.evt)
+	}
+}
+
+// delivery is something to do with a received message once the signatures of the events that
+// came before it are verified, see Relay.deliver.
+type delivery struct {
+	verified <-chan bool // nil if there is nothing to verify
+	run      func(valid bool)
+}
+
+// deliver queues d to run after the deliveries queued before it, so verifications can run in
+// parallel while subscriptions still see everything in order.
+func (r *Relay) deliver(d delivery) {
+	select {
+	case r.deliveries <- d:
+	case <-r.ConnectionContext.Done():
+	}
+}
+
+// runDeliveries runs the queued deliveries, in order, until the relay is closed.
+func (r *Relay) runDeliveries() {
+	for {
+		select {
+		case d := <-r.deliveries:
+			valid := true
+			if d.verified != nil {
+				select {
+				case valid = <-d.verified:
+				case <-r.ConnectionContext.Done():
+					return
+				}
+			}
+			d.run(valid)
+		case <-r.ConnectionContext.Done():
+			return
+		}
+	}
+}
+
+// deliverEvent sends evt, whose signature was verified as valid says, to sub, after the checks
+// that don't need to run in parallel.
+func (r *Relay) deliverEvent(sub *Subscription, evt *Event, valid bool) {
+	sub.mutex.Lock()
+	defer sub.mutex.Unlock()
+	if sub.stopped {
+		return
+	}
+
+	if !valid {
+		sub.statistics.update(func(s *SubscriptionStats) { s.DroppedBySignature++ })
+		log.Printf("bad signature on event %s\n", evt.ID)
+		return
+	}
+
+	if r.createdAtWindow != nil {
+		if err := r.createdAtWindow.Check(evt, Now()); err != nil {
+			r.reportError(ErrorEvent, err)
+			if !r.createdAtWindow.ReportOnly {
+				return
+			}
+		}
+	}
+
+	// when split into many REQs the same event may come more than once
+	if sub.isDuplicate(evt) {
+		return
+	}
+	sub.trackCursor(evt)
+
+	if !intercept(r.interceptors, r, evt) {
+		return
+	}
+
+	if sub.onDecoded != nil {
+		sub.decode(evt)
+	}
+
+	// don't block the whole connection if nobody is reading this subscription anymore
+	select {
+	case sub.Events <- evt:
+		sub.statistics.update(func(s *SubscriptionStats) { s.Delivered++ })
+	case <-sub.Context.Done():
+	}
+}
@@ -0,0 +1,57 @@
+package nostr
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSignatureVerifierKeepsOrder(t *testing.T) {
+	fr := &fakeRelay{}
+	sk, pk := makeKeyPair(t)
+	for i := 0; i < 20; i++ {
+		evt := Event{Kind: 1, PubKey: pk, CreatedAt: time.Unix(int64(1700000000+i), 0), Content: "stored"}
+		evt.Sign(sk)
+		fr.events = append(fr.events, evt)
+	}
+	ws := newWebsocketServer(fr.handle)
+	defer ws.Close()
+
+	// the first events take the longest to check, every third one is invalid
+	slow := func(evt *Event) bool {
+		i := evt.CreatedAt.Unix() - 1700000000
+		time.Sleep(time.Duration(20-i) * 5 * time.Millisecond)
+		return i%3 != 0
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	relay, err := RelayConnect(ctx, ws.URL, WithSignatureChecker(slow), WithSignatureVerifier(NewSignatureVerifier(20)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer relay.Close()
+
+	start := time.Now()
+	events, err := relay.Query(ctx, Filter{Kinds: []int{1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("signatures were checked one by one, took %s", elapsed)
+	}
+
+	var expected []ID
+	for i, evt := range fr.events {
+		if i%3 != 0 {
+			expected = append(expected, evt.ID)
+		}
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("got %d events, expected %d", len(events), len(expected))
+	}
+	for i, evt := range events {
+		if evt.ID != expected[i] {
+			t.Fatalf("event %d is out of order", i)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// Package webhook turns Nostr subscriptions into HTTP webhooks: every event matching the
+// filters is POSTed as JSON to a URL, for backend services that don't speak websockets.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	// SignatureHeader carries "sha256=<hex HMAC-SHA256 of the body>" when a secret is set.
+	SignatureHeader = "X-Nostr-Signature"
+	EventIDHeader   = "X-Nostr-Event-Id"
+)
+
+// Bridge delivers events to a webhook URL.
+type Bridge struct {
+	URL    string
+	Secret []byte // if set, requests are signed with it, see SignatureHeader
+
+	Client       *http.Client
+	MaxRetries   int           // attempts after the first one
+	RetryBackoff time.Duration // doubled after each attempt
+
+	// DeadLetter is called with events that couldn't be delivered after all the retries,
+	// or that the server rejected with a 4xx status. See DeadLetterFile.
+	DeadLetter func(evt *nostr.Event, err error)
+}
+
+// New returns a Bridge posting to url with sensible defaults for retries.
+func New(url string, secret []byte) *Bridge {
+	return &Bridge{
+		URL:          url,
+		Secret:       secret,
+		Client:       &http.Client{Timeout: 10 * time.Second},
+		MaxRetries:   5,
+		RetryBackoff: time.Second,
+	}
+}
+
+// Run subscribes to filters on the given relays and delivers every event received, in order,
+// until ctx is canceled.
+func (b *Bridge) Run(ctx context.Context, pool *nostr.SimplePool, relays []string, filters nostr.Filters, opts ...nostr.SubscriptionOption) {
+	for evt := range pool.SubMany(ctx, relays, filters, opts...) {
+		if err := b.Deliver(ctx, evt); err != nil && b.DeadLetter != nil {
+			b.DeadLetter(evt, err)
+		}
+	}
+}
+
+// Deliver posts a single event, retrying on network errors, 429 and 5xx responses.
+func (b *Bridge) Deliver(ctx context.Context, evt *nostr.Event) error {
+	body, err := json.Marshal(evt)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	backoff := b.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := b.post(ctx, evt, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= b.MaxRetries {
+			return err
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+			return fmt.Errorf("%w (last error: %s)", ctx.Err(), err)
+		}
+	}
+}
+
+func (b *Bridge) post(ctx context.Context, evt *nostr.Event, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventIDHeader, evt.ID.Hex())
+	if len(b.Secret) > 0 {
+		req.Header.Set(SignatureHeader, "sha256="+Sign(b.Secret, body))
+	}
+
+	client := b.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("webhook returned %s", resp.Status)
+	default:
+		return false, fmt.Errorf("webhook rejected event with %s", resp.Status)
+	}
+}
+
+// Sign returns the hex HMAC-SHA256 of body, as sent in SignatureHeader.
+func Sign(secret []byte, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the SignatureHeader value received by a webhook handler.
+func Verify(secret []byte, body []byte, header string) bool {
+	expected := "sha256=" + Sign(secret, body)
+	return hmac.Equal([]byte(expected), []byte(header))
+}
+
+// DeadLetterFile returns a Bridge.DeadLetter function that appends failed events to the
+// file at path, one JSON object per line, so they can be inspected and replayed later.
+func DeadLetterFile(path string) func(*nostr.Event, error) {
+	var mu sync.Mutex
+	return func(evt *nostr.Event, deliveryErr error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		line, _ := json.Marshal(struct {
+			Event  *nostr.Event `json:"event"`
+			Error  string       `json:"error"`
+			Failed int64        `json:"failed_at"`
+		}{evt, deliveryErr.Error(), time.Now().Unix()})
+
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		f.Write(append(line, '\n'))
+	}
+}
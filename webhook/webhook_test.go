@@ -0,0 +1,85 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func TestDeliverRetriesAndSigns(t *testing.T) {
+	secret := []byte("shh")
+	evt := &nostr.Event{Kind: 1, CreatedAt: time.Unix(1672068534, 0), Content: "hello"}
+	evt.Sign(nostr.GeneratePrivateKey())
+
+	var mu sync.Mutex
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !Verify(secret, body, r.Header.Get(SignatureHeader)) {
+			t.Errorf("invalid signature")
+		}
+		if r.Header.Get(EventIDHeader) != evt.ID.Hex() {
+			t.Errorf("wrong event id header")
+		}
+		var received nostr.Event
+		if err := json.Unmarshal(body, &received); err != nil || received.ID != evt.ID {
+			t.Errorf("wrong body: %s", body)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	bridge := New(server.URL, secret)
+	bridge.RetryBackoff = time.Millisecond
+	if err := bridge.Deliver(context.Background(), evt); err != nil {
+		t.Fatalf("delivery failed: %s", err)
+	}
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestDeliverDeadLetter(t *testing.T) {
+	evt := &nostr.Event{Kind: 1, CreatedAt: time.Unix(1672068534, 0), Content: "hello"}
+	evt.Sign(nostr.GeneratePrivateKey())
+
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	bridge := New(server.URL, nil)
+	bridge.RetryBackoff = time.Millisecond
+	err := bridge.Deliver(context.Background(), evt)
+	if err == nil {
+		t.Fatal("delivery should have failed")
+	}
+	if attempts != 1 {
+		t.Errorf("4xx responses shouldn't be retried, got %d attempts", attempts)
+	}
+
+	path := filepath.Join(t.TempDir(), "dead.jsonl")
+	DeadLetterFile(path)(evt, err)
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), evt.ID.Hex()) || !strings.HasSuffix(string(data), "\n") {
+		t.Errorf("dead letter wasn't written: %s", data)
+	}
+}